@@ -0,0 +1,1175 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: gaia.proto
+
+package v1
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// Job represents a single job of a pipeline or a pipeline run
+type Job struct {
+	Id                   uint32   `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Title                string   `protobuf:"bytes,2,opt,name=title" json:"title,omitempty"`
+	Description          string   `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
+	Priority             int64    `protobuf:"varint,4,opt,name=priority" json:"priority,omitempty"`
+	Status               string   `protobuf:"bytes,5,opt,name=status" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Job) Reset()         { *m = Job{} }
+func (m *Job) String() string { return proto.CompactTextString(m) }
+func (*Job) ProtoMessage()    {}
+func (*Job) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{0}
+}
+func (m *Job) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Job.Unmarshal(m, b)
+}
+func (m *Job) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Job.Marshal(b, m, deterministic)
+}
+func (dst *Job) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Job.Merge(dst, src)
+}
+func (m *Job) XXX_Size() int {
+	return xxx_messageInfo_Job.Size(m)
+}
+func (m *Job) XXX_DiscardUnknown() {
+	xxx_messageInfo_Job.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Job proto.InternalMessageInfo
+
+func (m *Job) GetId() uint32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Job) GetTitle() string {
+	if m != nil {
+		return m.Title
+	}
+	return ""
+}
+
+func (m *Job) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Job) GetPriority() int64 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *Job) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+// PipelineRun represents a single run of a pipeline.
+// Dates are unix timestamps in seconds.
+type PipelineRun struct {
+	Id                   int64             `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	PipelineId           int64             `protobuf:"varint,2,opt,name=pipeline_id,json=pipelineId" json:"pipeline_id,omitempty"`
+	Status               string            `protobuf:"bytes,3,opt,name=status" json:"status,omitempty"`
+	StartDate            int64             `protobuf:"varint,4,opt,name=start_date,json=startDate" json:"start_date,omitempty"`
+	FinishDate           int64             `protobuf:"varint,5,opt,name=finish_date,json=finishDate" json:"finish_date,omitempty"`
+	Jobs                 []*Job            `protobuf:"bytes,6,rep,name=jobs" json:"jobs,omitempty"`
+	StartedBy            string            `protobuf:"bytes,7,opt,name=started_by,json=startedBy" json:"started_by,omitempty"`
+	Params               map[string]string `protobuf:"bytes,8,rep,name=params" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *PipelineRun) Reset()         { *m = PipelineRun{} }
+func (m *PipelineRun) String() string { return proto.CompactTextString(m) }
+func (*PipelineRun) ProtoMessage()    {}
+func (*PipelineRun) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{1}
+}
+func (m *PipelineRun) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PipelineRun.Unmarshal(m, b)
+}
+func (m *PipelineRun) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PipelineRun.Marshal(b, m, deterministic)
+}
+func (dst *PipelineRun) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PipelineRun.Merge(dst, src)
+}
+func (m *PipelineRun) XXX_Size() int {
+	return xxx_messageInfo_PipelineRun.Size(m)
+}
+func (m *PipelineRun) XXX_DiscardUnknown() {
+	xxx_messageInfo_PipelineRun.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PipelineRun proto.InternalMessageInfo
+
+func (m *PipelineRun) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *PipelineRun) GetPipelineId() int64 {
+	if m != nil {
+		return m.PipelineId
+	}
+	return 0
+}
+
+func (m *PipelineRun) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *PipelineRun) GetStartDate() int64 {
+	if m != nil {
+		return m.StartDate
+	}
+	return 0
+}
+
+func (m *PipelineRun) GetFinishDate() int64 {
+	if m != nil {
+		return m.FinishDate
+	}
+	return 0
+}
+
+func (m *PipelineRun) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+func (m *PipelineRun) GetStartedBy() string {
+	if m != nil {
+		return m.StartedBy
+	}
+	return ""
+}
+
+func (m *PipelineRun) GetParams() map[string]string {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+// Pipeline represents a compiled pipeline
+type Pipeline struct {
+	Id      int64  `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Name    string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Type    string `protobuf:"bytes,3,opt,name=type" json:"type,omitempty"`
+	RepoUrl string `protobuf:"bytes,4,opt,name=repo_url,json=repoUrl" json:"repo_url,omitempty"`
+	Branch  string `protobuf:"bytes,5,opt,name=branch" json:"branch,omitempty"`
+	Jobs    []*Job `protobuf:"bytes,6,rep,name=jobs" json:"jobs,omitempty"`
+	Created int64  `protobuf:"varint,7,opt,name=created" json:"created,omitempty"`
+	// LatestRun is only set by ListPipelines
+	LatestRun            *PipelineRun `protobuf:"bytes,8,opt,name=latest_run,json=latestRun" json:"latest_run,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *Pipeline) Reset()         { *m = Pipeline{} }
+func (m *Pipeline) String() string { return proto.CompactTextString(m) }
+func (*Pipeline) ProtoMessage()    {}
+func (*Pipeline) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{2}
+}
+func (m *Pipeline) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Pipeline.Unmarshal(m, b)
+}
+func (m *Pipeline) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Pipeline.Marshal(b, m, deterministic)
+}
+func (dst *Pipeline) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Pipeline.Merge(dst, src)
+}
+func (m *Pipeline) XXX_Size() int {
+	return xxx_messageInfo_Pipeline.Size(m)
+}
+func (m *Pipeline) XXX_DiscardUnknown() {
+	xxx_messageInfo_Pipeline.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Pipeline proto.InternalMessageInfo
+
+func (m *Pipeline) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Pipeline) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Pipeline) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Pipeline) GetRepoUrl() string {
+	if m != nil {
+		return m.RepoUrl
+	}
+	return ""
+}
+
+func (m *Pipeline) GetBranch() string {
+	if m != nil {
+		return m.Branch
+	}
+	return ""
+}
+
+func (m *Pipeline) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+func (m *Pipeline) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+func (m *Pipeline) GetLatestRun() *PipelineRun {
+	if m != nil {
+		return m.LatestRun
+	}
+	return nil
+}
+
+// ListPipelinesRequest is the request of ListPipelines
+type ListPipelinesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListPipelinesRequest) Reset()         { *m = ListPipelinesRequest{} }
+func (m *ListPipelinesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPipelinesRequest) ProtoMessage()    {}
+func (*ListPipelinesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{3}
+}
+func (m *ListPipelinesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPipelinesRequest.Unmarshal(m, b)
+}
+func (m *ListPipelinesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPipelinesRequest.Marshal(b, m, deterministic)
+}
+func (dst *ListPipelinesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPipelinesRequest.Merge(dst, src)
+}
+func (m *ListPipelinesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListPipelinesRequest.Size(m)
+}
+func (m *ListPipelinesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPipelinesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPipelinesRequest proto.InternalMessageInfo
+
+// ListPipelinesResponse holds all active pipelines with their latest run
+type ListPipelinesResponse struct {
+	Pipelines            []*Pipeline `protobuf:"bytes,1,rep,name=pipelines" json:"pipelines,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *ListPipelinesResponse) Reset()         { *m = ListPipelinesResponse{} }
+func (m *ListPipelinesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListPipelinesResponse) ProtoMessage()    {}
+func (*ListPipelinesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{4}
+}
+func (m *ListPipelinesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPipelinesResponse.Unmarshal(m, b)
+}
+func (m *ListPipelinesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPipelinesResponse.Marshal(b, m, deterministic)
+}
+func (dst *ListPipelinesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPipelinesResponse.Merge(dst, src)
+}
+func (m *ListPipelinesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListPipelinesResponse.Size(m)
+}
+func (m *ListPipelinesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPipelinesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPipelinesResponse proto.InternalMessageInfo
+
+func (m *ListPipelinesResponse) GetPipelines() []*Pipeline {
+	if m != nil {
+		return m.Pipelines
+	}
+	return nil
+}
+
+// GetPipelineRequest is the request of GetPipeline and DeletePipeline
+type GetPipelineRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetPipelineRequest) Reset()         { *m = GetPipelineRequest{} }
+func (m *GetPipelineRequest) String() string { return proto.CompactTextString(m) }
+func (*GetPipelineRequest) ProtoMessage()    {}
+func (*GetPipelineRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{5}
+}
+func (m *GetPipelineRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetPipelineRequest.Unmarshal(m, b)
+}
+func (m *GetPipelineRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetPipelineRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetPipelineRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetPipelineRequest.Merge(dst, src)
+}
+func (m *GetPipelineRequest) XXX_Size() int {
+	return xxx_messageInfo_GetPipelineRequest.Size(m)
+}
+func (m *GetPipelineRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetPipelineRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetPipelineRequest proto.InternalMessageInfo
+
+func (m *GetPipelineRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+// CreatePipelineRequest holds the repo the pipeline is compiled from
+type CreatePipelineRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Type                 string   `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
+	RepoUrl              string   `protobuf:"bytes,3,opt,name=repo_url,json=repoUrl" json:"repo_url,omitempty"`
+	Branch               string   `protobuf:"bytes,4,opt,name=branch" json:"branch,omitempty"`
+	Username             string   `protobuf:"bytes,5,opt,name=username" json:"username,omitempty"`
+	Password             string   `protobuf:"bytes,6,opt,name=password" json:"password,omitempty"`
+	PrivateKey           string   `protobuf:"bytes,7,opt,name=private_key,json=privateKey" json:"private_key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreatePipelineRequest) Reset()         { *m = CreatePipelineRequest{} }
+func (m *CreatePipelineRequest) String() string { return proto.CompactTextString(m) }
+func (*CreatePipelineRequest) ProtoMessage()    {}
+func (*CreatePipelineRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{6}
+}
+func (m *CreatePipelineRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreatePipelineRequest.Unmarshal(m, b)
+}
+func (m *CreatePipelineRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreatePipelineRequest.Marshal(b, m, deterministic)
+}
+func (dst *CreatePipelineRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreatePipelineRequest.Merge(dst, src)
+}
+func (m *CreatePipelineRequest) XXX_Size() int {
+	return xxx_messageInfo_CreatePipelineRequest.Size(m)
+}
+func (m *CreatePipelineRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreatePipelineRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreatePipelineRequest proto.InternalMessageInfo
+
+func (m *CreatePipelineRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreatePipelineRequest) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *CreatePipelineRequest) GetRepoUrl() string {
+	if m != nil {
+		return m.RepoUrl
+	}
+	return ""
+}
+
+func (m *CreatePipelineRequest) GetBranch() string {
+	if m != nil {
+		return m.Branch
+	}
+	return ""
+}
+
+func (m *CreatePipelineRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *CreatePipelineRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *CreatePipelineRequest) GetPrivateKey() string {
+	if m != nil {
+		return m.PrivateKey
+	}
+	return ""
+}
+
+// CreatePipelineResponse holds the id of the started compilation
+type CreatePipelineResponse struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreatePipelineResponse) Reset()         { *m = CreatePipelineResponse{} }
+func (m *CreatePipelineResponse) String() string { return proto.CompactTextString(m) }
+func (*CreatePipelineResponse) ProtoMessage()    {}
+func (*CreatePipelineResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{7}
+}
+func (m *CreatePipelineResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreatePipelineResponse.Unmarshal(m, b)
+}
+func (m *CreatePipelineResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreatePipelineResponse.Marshal(b, m, deterministic)
+}
+func (dst *CreatePipelineResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreatePipelineResponse.Merge(dst, src)
+}
+func (m *CreatePipelineResponse) XXX_Size() int {
+	return xxx_messageInfo_CreatePipelineResponse.Size(m)
+}
+func (m *CreatePipelineResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreatePipelineResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreatePipelineResponse proto.InternalMessageInfo
+
+func (m *CreatePipelineResponse) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// StartPipelineRequest holds the options of a run
+type StartPipelineRequest struct {
+	PipelineId           int64             `protobuf:"varint,1,opt,name=pipeline_id,json=pipelineId" json:"pipeline_id,omitempty"`
+	Jobs                 []uint32          `protobuf:"varint,2,rep,packed,name=jobs" json:"jobs,omitempty"`
+	Dependencies         bool              `protobuf:"varint,3,opt,name=dependencies" json:"dependencies,omitempty"`
+	Params               map[string]string `protobuf:"bytes,4,rep,name=params" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Secrets              []string          `protobuf:"bytes,5,rep,name=secrets" json:"secrets,omitempty"`
+	Canary               bool              `protobuf:"varint,6,opt,name=canary" json:"canary,omitempty"`
+	Debug                bool              `protobuf:"varint,7,opt,name=debug" json:"debug,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *StartPipelineRequest) Reset()         { *m = StartPipelineRequest{} }
+func (m *StartPipelineRequest) String() string { return proto.CompactTextString(m) }
+func (*StartPipelineRequest) ProtoMessage()    {}
+func (*StartPipelineRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{8}
+}
+func (m *StartPipelineRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StartPipelineRequest.Unmarshal(m, b)
+}
+func (m *StartPipelineRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StartPipelineRequest.Marshal(b, m, deterministic)
+}
+func (dst *StartPipelineRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StartPipelineRequest.Merge(dst, src)
+}
+func (m *StartPipelineRequest) XXX_Size() int {
+	return xxx_messageInfo_StartPipelineRequest.Size(m)
+}
+func (m *StartPipelineRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StartPipelineRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StartPipelineRequest proto.InternalMessageInfo
+
+func (m *StartPipelineRequest) GetPipelineId() int64 {
+	if m != nil {
+		return m.PipelineId
+	}
+	return 0
+}
+
+func (m *StartPipelineRequest) GetJobs() []uint32 {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+func (m *StartPipelineRequest) GetDependencies() bool {
+	if m != nil {
+		return m.Dependencies
+	}
+	return false
+}
+
+func (m *StartPipelineRequest) GetParams() map[string]string {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+func (m *StartPipelineRequest) GetSecrets() []string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *StartPipelineRequest) GetCanary() bool {
+	if m != nil {
+		return m.Canary
+	}
+	return false
+}
+
+func (m *StartPipelineRequest) GetDebug() bool {
+	if m != nil {
+		return m.Debug
+	}
+	return false
+}
+
+// GetPipelineRunRequest is the request of GetPipelineRun
+type GetPipelineRunRequest struct {
+	PipelineId           int64    `protobuf:"varint,1,opt,name=pipeline_id,json=pipelineId" json:"pipeline_id,omitempty"`
+	RunId                int64    `protobuf:"varint,2,opt,name=run_id,json=runId" json:"run_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetPipelineRunRequest) Reset()         { *m = GetPipelineRunRequest{} }
+func (m *GetPipelineRunRequest) String() string { return proto.CompactTextString(m) }
+func (*GetPipelineRunRequest) ProtoMessage()    {}
+func (*GetPipelineRunRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{9}
+}
+func (m *GetPipelineRunRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetPipelineRunRequest.Unmarshal(m, b)
+}
+func (m *GetPipelineRunRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetPipelineRunRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetPipelineRunRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetPipelineRunRequest.Merge(dst, src)
+}
+func (m *GetPipelineRunRequest) XXX_Size() int {
+	return xxx_messageInfo_GetPipelineRunRequest.Size(m)
+}
+func (m *GetPipelineRunRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetPipelineRunRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetPipelineRunRequest proto.InternalMessageInfo
+
+func (m *GetPipelineRunRequest) GetPipelineId() int64 {
+	if m != nil {
+		return m.PipelineId
+	}
+	return 0
+}
+
+func (m *GetPipelineRunRequest) GetRunId() int64 {
+	if m != nil {
+		return m.RunId
+	}
+	return 0
+}
+
+// StreamRunLogsRequest selects the logs of a run. All jobs are
+// streamed if no job id is given.
+type StreamRunLogsRequest struct {
+	PipelineId int64  `protobuf:"varint,1,opt,name=pipeline_id,json=pipelineId" json:"pipeline_id,omitempty"`
+	RunId      int64  `protobuf:"varint,2,opt,name=run_id,json=runId" json:"run_id,omitempty"`
+	JobId      uint32 `protobuf:"varint,3,opt,name=job_id,json=jobId" json:"job_id,omitempty"`
+	// Follow keeps the stream open until the run has been finished
+	Follow               bool     `protobuf:"varint,4,opt,name=follow" json:"follow,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamRunLogsRequest) Reset()         { *m = StreamRunLogsRequest{} }
+func (m *StreamRunLogsRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamRunLogsRequest) ProtoMessage()    {}
+func (*StreamRunLogsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{10}
+}
+func (m *StreamRunLogsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamRunLogsRequest.Unmarshal(m, b)
+}
+func (m *StreamRunLogsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamRunLogsRequest.Marshal(b, m, deterministic)
+}
+func (dst *StreamRunLogsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamRunLogsRequest.Merge(dst, src)
+}
+func (m *StreamRunLogsRequest) XXX_Size() int {
+	return xxx_messageInfo_StreamRunLogsRequest.Size(m)
+}
+func (m *StreamRunLogsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamRunLogsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamRunLogsRequest proto.InternalMessageInfo
+
+func (m *StreamRunLogsRequest) GetPipelineId() int64 {
+	if m != nil {
+		return m.PipelineId
+	}
+	return 0
+}
+
+func (m *StreamRunLogsRequest) GetRunId() int64 {
+	if m != nil {
+		return m.RunId
+	}
+	return 0
+}
+
+func (m *StreamRunLogsRequest) GetJobId() uint32 {
+	if m != nil {
+		return m.JobId
+	}
+	return 0
+}
+
+func (m *StreamRunLogsRequest) GetFollow() bool {
+	if m != nil {
+		return m.Follow
+	}
+	return false
+}
+
+// LogChunk is a part of a job log. The last chunk of a job is
+// marked as finished.
+type LogChunk struct {
+	JobId                uint32   `protobuf:"varint,1,opt,name=job_id,json=jobId" json:"job_id,omitempty"`
+	Offset               int64    `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+	Data                 []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Finished             bool     `protobuf:"varint,4,opt,name=finished" json:"finished,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LogChunk) Reset()         { *m = LogChunk{} }
+func (m *LogChunk) String() string { return proto.CompactTextString(m) }
+func (*LogChunk) ProtoMessage()    {}
+func (*LogChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_gaia_00434875cd35b555, []int{11}
+}
+func (m *LogChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LogChunk.Unmarshal(m, b)
+}
+func (m *LogChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LogChunk.Marshal(b, m, deterministic)
+}
+func (dst *LogChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogChunk.Merge(dst, src)
+}
+func (m *LogChunk) XXX_Size() int {
+	return xxx_messageInfo_LogChunk.Size(m)
+}
+func (m *LogChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogChunk proto.InternalMessageInfo
+
+func (m *LogChunk) GetJobId() uint32 {
+	if m != nil {
+		return m.JobId
+	}
+	return 0
+}
+
+func (m *LogChunk) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *LogChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *LogChunk) GetFinished() bool {
+	if m != nil {
+		return m.Finished
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterType((*Job)(nil), "gaia.api.v1.Job")
+	proto.RegisterType((*PipelineRun)(nil), "gaia.api.v1.PipelineRun")
+	proto.RegisterMapType((map[string]string)(nil), "gaia.api.v1.PipelineRun.ParamsEntry")
+	proto.RegisterType((*Pipeline)(nil), "gaia.api.v1.Pipeline")
+	proto.RegisterType((*ListPipelinesRequest)(nil), "gaia.api.v1.ListPipelinesRequest")
+	proto.RegisterType((*ListPipelinesResponse)(nil), "gaia.api.v1.ListPipelinesResponse")
+	proto.RegisterType((*GetPipelineRequest)(nil), "gaia.api.v1.GetPipelineRequest")
+	proto.RegisterType((*CreatePipelineRequest)(nil), "gaia.api.v1.CreatePipelineRequest")
+	proto.RegisterType((*CreatePipelineResponse)(nil), "gaia.api.v1.CreatePipelineResponse")
+	proto.RegisterType((*StartPipelineRequest)(nil), "gaia.api.v1.StartPipelineRequest")
+	proto.RegisterMapType((map[string]string)(nil), "gaia.api.v1.StartPipelineRequest.ParamsEntry")
+	proto.RegisterType((*GetPipelineRunRequest)(nil), "gaia.api.v1.GetPipelineRunRequest")
+	proto.RegisterType((*StreamRunLogsRequest)(nil), "gaia.api.v1.StreamRunLogsRequest")
+	proto.RegisterType((*LogChunk)(nil), "gaia.api.v1.LogChunk")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Gaia service
+
+type GaiaClient interface {
+	// ListPipelines returns all active pipelines with their latest run.
+	ListPipelines(ctx context.Context, in *ListPipelinesRequest, opts ...grpc.CallOption) (*ListPipelinesResponse, error)
+	// GetPipeline returns the active pipeline with the given id.
+	GetPipeline(ctx context.Context, in *GetPipelineRequest, opts ...grpc.CallOption) (*Pipeline, error)
+	// CreatePipeline starts the compilation of a new pipeline.
+	CreatePipeline(ctx context.Context, in *CreatePipelineRequest, opts ...grpc.CallOption) (*CreatePipelineResponse, error)
+	// DeletePipeline soft deletes the pipeline with the given id.
+	DeletePipeline(ctx context.Context, in *GetPipelineRequest, opts ...grpc.CallOption) (*Pipeline, error)
+	// StartPipeline schedules a new run of the given pipeline.
+	StartPipeline(ctx context.Context, in *StartPipelineRequest, opts ...grpc.CallOption) (*PipelineRun, error)
+	// GetPipelineRun returns the given run.
+	GetPipelineRun(ctx context.Context, in *GetPipelineRunRequest, opts ...grpc.CallOption) (*PipelineRun, error)
+	// StreamRunLogs streams the job logs of the given run.
+	StreamRunLogs(ctx context.Context, in *StreamRunLogsRequest, opts ...grpc.CallOption) (Gaia_StreamRunLogsClient, error)
+}
+
+type gaiaClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewGaiaClient(cc *grpc.ClientConn) GaiaClient {
+	return &gaiaClient{cc}
+}
+
+func (c *gaiaClient) ListPipelines(ctx context.Context, in *ListPipelinesRequest, opts ...grpc.CallOption) (*ListPipelinesResponse, error) {
+	out := new(ListPipelinesResponse)
+	err := grpc.Invoke(ctx, "/gaia.api.v1.Gaia/ListPipelines", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gaiaClient) GetPipeline(ctx context.Context, in *GetPipelineRequest, opts ...grpc.CallOption) (*Pipeline, error) {
+	out := new(Pipeline)
+	err := grpc.Invoke(ctx, "/gaia.api.v1.Gaia/GetPipeline", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gaiaClient) CreatePipeline(ctx context.Context, in *CreatePipelineRequest, opts ...grpc.CallOption) (*CreatePipelineResponse, error) {
+	out := new(CreatePipelineResponse)
+	err := grpc.Invoke(ctx, "/gaia.api.v1.Gaia/CreatePipeline", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gaiaClient) DeletePipeline(ctx context.Context, in *GetPipelineRequest, opts ...grpc.CallOption) (*Pipeline, error) {
+	out := new(Pipeline)
+	err := grpc.Invoke(ctx, "/gaia.api.v1.Gaia/DeletePipeline", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gaiaClient) StartPipeline(ctx context.Context, in *StartPipelineRequest, opts ...grpc.CallOption) (*PipelineRun, error) {
+	out := new(PipelineRun)
+	err := grpc.Invoke(ctx, "/gaia.api.v1.Gaia/StartPipeline", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gaiaClient) GetPipelineRun(ctx context.Context, in *GetPipelineRunRequest, opts ...grpc.CallOption) (*PipelineRun, error) {
+	out := new(PipelineRun)
+	err := grpc.Invoke(ctx, "/gaia.api.v1.Gaia/GetPipelineRun", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gaiaClient) StreamRunLogs(ctx context.Context, in *StreamRunLogsRequest, opts ...grpc.CallOption) (Gaia_StreamRunLogsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Gaia_serviceDesc.Streams[0], c.cc, "/gaia.api.v1.Gaia/StreamRunLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gaiaStreamRunLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Gaia_StreamRunLogsClient interface {
+	Recv() (*LogChunk, error)
+	grpc.ClientStream
+}
+
+type gaiaStreamRunLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *gaiaStreamRunLogsClient) Recv() (*LogChunk, error) {
+	m := new(LogChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Gaia service
+
+type GaiaServer interface {
+	// ListPipelines returns all active pipelines with their latest run.
+	ListPipelines(context.Context, *ListPipelinesRequest) (*ListPipelinesResponse, error)
+	// GetPipeline returns the active pipeline with the given id.
+	GetPipeline(context.Context, *GetPipelineRequest) (*Pipeline, error)
+	// CreatePipeline starts the compilation of a new pipeline.
+	CreatePipeline(context.Context, *CreatePipelineRequest) (*CreatePipelineResponse, error)
+	// DeletePipeline soft deletes the pipeline with the given id.
+	DeletePipeline(context.Context, *GetPipelineRequest) (*Pipeline, error)
+	// StartPipeline schedules a new run of the given pipeline.
+	StartPipeline(context.Context, *StartPipelineRequest) (*PipelineRun, error)
+	// GetPipelineRun returns the given run.
+	GetPipelineRun(context.Context, *GetPipelineRunRequest) (*PipelineRun, error)
+	// StreamRunLogs streams the job logs of the given run.
+	StreamRunLogs(*StreamRunLogsRequest, Gaia_StreamRunLogsServer) error
+}
+
+func RegisterGaiaServer(s *grpc.Server, srv GaiaServer) {
+	s.RegisterService(&_Gaia_serviceDesc, srv)
+}
+
+func _Gaia_ListPipelines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPipelinesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GaiaServer).ListPipelines(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gaia.api.v1.Gaia/ListPipelines",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GaiaServer).ListPipelines(ctx, req.(*ListPipelinesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gaia_GetPipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPipelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GaiaServer).GetPipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gaia.api.v1.Gaia/GetPipeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GaiaServer).GetPipeline(ctx, req.(*GetPipelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gaia_CreatePipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePipelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GaiaServer).CreatePipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gaia.api.v1.Gaia/CreatePipeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GaiaServer).CreatePipeline(ctx, req.(*CreatePipelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gaia_DeletePipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPipelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GaiaServer).DeletePipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gaia.api.v1.Gaia/DeletePipeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GaiaServer).DeletePipeline(ctx, req.(*GetPipelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gaia_StartPipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartPipelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GaiaServer).StartPipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gaia.api.v1.Gaia/StartPipeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GaiaServer).StartPipeline(ctx, req.(*StartPipelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gaia_GetPipelineRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPipelineRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GaiaServer).GetPipelineRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gaia.api.v1.Gaia/GetPipelineRun",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GaiaServer).GetPipelineRun(ctx, req.(*GetPipelineRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gaia_StreamRunLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRunLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GaiaServer).StreamRunLogs(m, &gaiaStreamRunLogsServer{stream})
+}
+
+type Gaia_StreamRunLogsServer interface {
+	Send(*LogChunk) error
+	grpc.ServerStream
+}
+
+type gaiaStreamRunLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *gaiaStreamRunLogsServer) Send(m *LogChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Gaia_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "gaia.api.v1.Gaia",
+	HandlerType: (*GaiaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPipelines",
+			Handler:    _Gaia_ListPipelines_Handler,
+		},
+		{
+			MethodName: "GetPipeline",
+			Handler:    _Gaia_GetPipeline_Handler,
+		},
+		{
+			MethodName: "CreatePipeline",
+			Handler:    _Gaia_CreatePipeline_Handler,
+		},
+		{
+			MethodName: "DeletePipeline",
+			Handler:    _Gaia_DeletePipeline_Handler,
+		},
+		{
+			MethodName: "StartPipeline",
+			Handler:    _Gaia_StartPipeline_Handler,
+		},
+		{
+			MethodName: "GetPipelineRun",
+			Handler:    _Gaia_GetPipelineRun_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamRunLogs",
+			Handler:       _Gaia_StreamRunLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gaia.proto",
+}
+
+func init() { proto.RegisterFile("gaia.proto", fileDescriptor_gaia_00434875cd35b555) }
+
+var fileDescriptor_gaia_00434875cd35b555 = []byte{
+	// 866 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0x4f, 0x6f, 0xdb, 0x36,
+	0x14, 0x87, 0x24, 0xdb, 0x91, 0x9f, 0xeb, 0xa0, 0x20, 0xe2, 0x40, 0x33, 0x30, 0xd4, 0xd3, 0x72,
+	0xf0, 0x65, 0xc6, 0x9a, 0x1e, 0xf6, 0x07, 0x3b, 0xb5, 0x0d, 0xba, 0xb6, 0xc6, 0x56, 0x70, 0xd8,
+	0x80, 0xed, 0x62, 0x50, 0x16, 0x93, 0x30, 0x55, 0x48, 0x8d, 0xa4, 0x52, 0xe8, 0xb0, 0xc3, 0x3e,
+	0xd4, 0x0e, 0xfb, 0x0c, 0xbb, 0xee, 0x73, 0xec, 0x33, 0x0c, 0xa4, 0x28, 0x47, 0x92, 0x95, 0xa0,
+	0x40, 0x6e, 0xfa, 0x3d, 0x92, 0xef, 0xcf, 0xef, 0xfd, 0xde, 0xb3, 0x01, 0x2e, 0x08, 0x23, 0xab,
+	0x5c, 0x0a, 0x2d, 0xd0, 0xc4, 0x7e, 0x93, 0x9c, 0xad, 0x6e, 0x9e, 0xc6, 0x7f, 0x7a, 0x10, 0xbc,
+	0x11, 0x09, 0x3a, 0x04, 0x9f, 0xa5, 0x91, 0xb7, 0xf0, 0x96, 0x53, 0xec, 0xb3, 0x14, 0x1d, 0xc1,
+	0x50, 0x33, 0x9d, 0xd1, 0xc8, 0x5f, 0x78, 0xcb, 0x31, 0xae, 0x00, 0x5a, 0xc0, 0x24, 0xa5, 0x6a,
+	0x2b, 0x59, 0xae, 0x99, 0xe0, 0x51, 0x60, 0xcf, 0x9a, 0x26, 0x34, 0x87, 0x30, 0x97, 0x4c, 0x48,
+	0xa6, 0xcb, 0x68, 0xb0, 0xf0, 0x96, 0x01, 0xde, 0x61, 0x74, 0x0c, 0x23, 0xa5, 0x89, 0x2e, 0x54,
+	0x34, 0xb4, 0x0f, 0x1d, 0x8a, 0xff, 0xf5, 0x61, 0xf2, 0x8e, 0xe5, 0x34, 0x63, 0x9c, 0xe2, 0x82,
+	0x37, 0x72, 0x09, 0x6c, 0x2e, 0x4f, 0x60, 0x92, 0xbb, 0xe3, 0x0d, 0x4b, 0x6d, 0x46, 0x01, 0x86,
+	0xda, 0xf4, 0x3a, 0x6d, 0x38, 0x0e, 0x9a, 0x8e, 0xd1, 0xa7, 0x00, 0x4a, 0x13, 0xa9, 0x37, 0x29,
+	0xd1, 0xd4, 0xa5, 0x33, 0xb6, 0x96, 0x97, 0x44, 0x53, 0xe3, 0xf7, 0x9c, 0x71, 0xa6, 0x2e, 0xab,
+	0xf3, 0x61, 0xe5, 0xb7, 0x32, 0xd9, 0x0b, 0x27, 0x30, 0xb8, 0x12, 0x89, 0x8a, 0x46, 0x8b, 0x60,
+	0x39, 0x39, 0x7d, 0xbc, 0x6a, 0x10, 0xb7, 0x7a, 0x23, 0x12, 0x6c, 0x4f, 0x77, 0x51, 0x68, 0xba,
+	0x49, 0xca, 0xe8, 0xc0, 0x66, 0x30, 0x76, 0x96, 0xe7, 0x25, 0xfa, 0x0e, 0x46, 0x39, 0x91, 0xe4,
+	0x5a, 0x45, 0xa1, 0x75, 0x73, 0xd2, 0x72, 0xd3, 0xa8, 0x7b, 0xf5, 0xce, 0x5e, 0x3b, 0xe3, 0x5a,
+	0x96, 0xd8, 0xbd, 0x99, 0x7f, 0x03, 0x93, 0x86, 0x19, 0x3d, 0x86, 0xe0, 0x3d, 0x2d, 0x2d, 0x37,
+	0x63, 0x6c, 0x3e, 0x4d, 0xa3, 0x6e, 0x48, 0x56, 0xec, 0x1a, 0x65, 0xc1, 0xb7, 0xfe, 0xd7, 0x5e,
+	0xfc, 0x9f, 0x07, 0x61, 0xed, 0x7e, 0x8f, 0x53, 0x04, 0x03, 0x4e, 0xae, 0xeb, 0x57, 0xf6, 0xdb,
+	0xd8, 0x74, 0x99, 0x53, 0x47, 0xa2, 0xfd, 0x46, 0x9f, 0x40, 0x28, 0x69, 0x2e, 0x36, 0x85, 0xcc,
+	0x2c, 0x81, 0x63, 0x7c, 0x60, 0xf0, 0xcf, 0x32, 0x33, 0xac, 0x27, 0x92, 0xf0, 0xed, 0x65, 0xdd,
+	0xce, 0x0a, 0x7d, 0x24, 0x6b, 0x11, 0x1c, 0x6c, 0x25, 0x25, 0x9a, 0xa6, 0x96, 0xb2, 0x00, 0xd7,
+	0x10, 0x7d, 0x05, 0x90, 0x11, 0x4d, 0x95, 0xde, 0xc8, 0x82, 0x47, 0xe1, 0xc2, 0x5b, 0x4e, 0x4e,
+	0xa3, 0xbb, 0x48, 0xc3, 0xe3, 0xea, 0x2e, 0x2e, 0x78, 0x7c, 0x0c, 0x47, 0x6b, 0xa6, 0x74, 0x7d,
+	0xaa, 0x30, 0xfd, 0xbd, 0xa0, 0x4a, 0xc7, 0x6b, 0x98, 0x75, 0xec, 0x2a, 0x17, 0x5c, 0x51, 0xf4,
+	0x0c, 0xc6, 0xb5, 0x8a, 0x54, 0xe4, 0xd9, 0x74, 0x67, 0xfd, 0x81, 0x6e, 0xef, 0xc5, 0x27, 0x80,
+	0x5e, 0xd1, 0x9d, 0x33, 0x17, 0xa3, 0xcb, 0x6f, 0xfc, 0x8f, 0x07, 0xb3, 0x17, 0xb6, 0xa0, 0xee,
+	0xcd, 0x9a, 0x79, 0xaf, 0x87, 0x79, 0xff, 0x0e, 0xe6, 0x83, 0xbb, 0x98, 0x1f, 0xb4, 0x98, 0x9f,
+	0x43, 0x58, 0x28, 0x2a, 0xad, 0xfb, 0xaa, 0x27, 0x3b, 0x6c, 0xce, 0x72, 0xa2, 0xd4, 0x07, 0x21,
+	0xd3, 0x68, 0x54, 0x9d, 0xd5, 0xd8, 0x0e, 0x98, 0x64, 0x37, 0x44, 0xd3, 0x8d, 0x51, 0x57, 0x25,
+	0x61, 0x70, 0xa6, 0xb7, 0xb4, 0x8c, 0x97, 0x70, 0xdc, 0x2d, 0xc6, 0x51, 0x78, 0x5b, 0xf7, 0xd8,
+	0xd6, 0xfd, 0xb7, 0x0f, 0x47, 0x3f, 0x19, 0xed, 0x77, 0xcb, 0xee, 0x0c, 0xb1, 0xb7, 0x37, 0xc4,
+	0xc8, 0xc9, 0xc6, 0x5f, 0x04, 0xcb, 0xa9, 0x13, 0x49, 0x0c, 0x8f, 0x52, 0x9a, 0x53, 0x9e, 0x52,
+	0xbe, 0x65, 0xb4, 0x1a, 0xef, 0x10, 0xb7, 0x6c, 0xe8, 0x6c, 0x37, 0x5f, 0x03, 0xdb, 0xc1, 0x2f,
+	0x5a, 0x1d, 0xec, 0xcb, 0xa5, 0x6f, 0xd0, 0x8c, 0x1e, 0x15, 0xdd, 0x4a, 0xaa, 0xcd, 0x76, 0x0a,
+	0x0c, 0xdb, 0x0e, 0x1a, 0xb6, 0xb7, 0x84, 0x13, 0x59, 0x5a, 0xde, 0x42, 0xec, 0x90, 0x99, 0xbc,
+	0x94, 0x26, 0xc5, 0x85, 0xe5, 0x2b, 0xc4, 0x15, 0x78, 0xc8, 0xc0, 0xfe, 0x08, 0xb3, 0xa6, 0xb2,
+	0x0a, 0xfe, 0xd1, 0xdc, 0xcd, 0x60, 0x24, 0x0b, 0x7e, 0xbb, 0x1c, 0x87, 0xb2, 0xe0, 0xaf, 0xd3,
+	0xf8, 0x0f, 0xd3, 0x0b, 0x49, 0xc9, 0x35, 0x2e, 0xf8, 0x5a, 0x5c, 0xa8, 0x07, 0xfa, 0x33, 0xe6,
+	0x2b, 0x91, 0x18, 0x73, 0x60, 0x7f, 0x28, 0x86, 0x57, 0x22, 0xa9, 0xd6, 0xef, 0xb9, 0xc8, 0x32,
+	0xf1, 0xc1, 0xca, 0x31, 0xc4, 0x0e, 0xc5, 0x0c, 0xc2, 0xb5, 0xb8, 0x78, 0x71, 0x59, 0xf0, 0xf7,
+	0x8d, 0xa7, 0x5e, 0xe7, 0xa9, 0x38, 0x3f, 0x57, 0x54, 0xbb, 0x40, 0x0e, 0x19, 0x31, 0xa4, 0x44,
+	0x13, 0x1b, 0xe7, 0x11, 0xb6, 0xdf, 0x46, 0xc1, 0xd5, 0x6e, 0xa6, 0xa9, 0x0b, 0xb4, 0xc3, 0xa7,
+	0x7f, 0x0d, 0x60, 0xf0, 0x8a, 0x30, 0x82, 0x7e, 0x81, 0x69, 0x6b, 0xd6, 0xd1, 0x67, 0x2d, 0x39,
+	0xf4, 0xed, 0x87, 0x79, 0x7c, 0xdf, 0x15, 0xa7, 0xf3, 0x33, 0x98, 0x34, 0x7a, 0x83, 0x9e, 0xb4,
+	0x9e, 0xec, 0xef, 0x83, 0x79, 0xff, 0x1e, 0x41, 0xbf, 0xc2, 0x61, 0x7b, 0x90, 0x50, 0x3b, 0x78,
+	0xef, 0xca, 0x98, 0x7f, 0x7e, 0xef, 0x1d, 0x97, 0xe1, 0xf7, 0x70, 0xf8, 0x92, 0x66, 0x54, 0xd3,
+	0x07, 0x27, 0xb9, 0x86, 0x69, 0x6b, 0x6c, 0x3a, 0x1c, 0xf6, 0x8d, 0xd4, 0xfc, 0xce, 0x05, 0x8d,
+	0x7e, 0x80, 0xc3, 0xb6, 0xaa, 0x3b, 0x25, 0xf7, 0x4a, 0xfe, 0x1e, 0x7f, 0x6f, 0x61, 0xda, 0x12,
+	0xf5, 0x5e, 0x76, 0xfb, 0x82, 0xef, 0x14, 0x5a, 0x8b, 0xf2, 0x4b, 0xef, 0xf9, 0xe0, 0x37, 0xff,
+	0xe6, 0x69, 0x32, 0xb2, 0x7f, 0x8c, 0x9e, 0xfd, 0x3f, 0x00, 0x76, 0x06, 0x00, 0x0c, 0x26, 0x09,
+	0x00, 0x00,
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/scheduler"
+)
+
+const (
+	// configFileName is the name of the config file which is
+	// loaded from the home folder if no config file is given.
+	configFileName = "gaia.toml"
+
+	// configEnvPrefix is the prefix of the environment variables which
+	// overwrite options (e.g. GAIA_WORKER for -worker).
+	configEnvPrefix = "GAIA_"
+
+	// configFileEnv is the environment variable which holds the config file path.
+	configFileEnv = configEnvPrefix + "CONFIG"
+)
+
+var (
+	// configFile is the path of the config file.
+	configFile string
+
+	// printConfig prints the effective configuration and exits.
+	printConfig bool
+
+	// commandFlags are the flags which cannot be set by config file or environment.
+	commandFlags = []string{"config", "printconfig", "relocate", "version"}
+
+	// secretFlags are the flags whose values are masked in the printed configuration.
+	secretFlags = []string{"vaultpassphrase", "exporttoken", "jiratoken", "servicenowpassword", "replicationtoken"}
+)
+
+// loadConfig sets all options which have not been given as flag.
+// Environment variables take precedence over the config file.
+func loadConfig(fs *flag.FlagSet) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	// Environment variables
+	for _, name := range configurableFlags(fs) {
+		env := configEnvPrefix + strings.ToUpper(name)
+		value, ok := os.LookupEnv(env)
+		if !ok || explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q of environment variable %s: %s", value, env, err.Error())
+		}
+		explicit[name] = true
+	}
+
+	// Config file. The home folder might have been set by the environment.
+	path := configFile
+	if path == "" {
+		path = os.Getenv(configFileEnv)
+	}
+	if path == "" {
+		home := gaia.Cfg.HomePath
+		if home == "" {
+			home, _ = findExecuteablePath()
+		}
+		path = filepath.Join(home, configFileName)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	options, err := parseConfig(f, path)
+	if err != nil {
+		return err
+	}
+	configurable := configurableFlags(fs)
+	for _, o := range options {
+		if !containsFlag(configurable, o.key) {
+			return fmt.Errorf("%s:%d: unknown option %s", path, o.line, o.key)
+		}
+		if explicit[o.key] {
+			continue
+		}
+		if err := fs.Set(o.key, o.value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value %q of option %s: %s", path, o.line, o.value, o.key, err.Error())
+		}
+	}
+	return nil
+}
+
+// configOption represents a single option of the config file.
+type configOption struct {
+	key   string
+	value string
+	line  int
+}
+
+// parseConfig parses a config file in TOML format. Only top level
+// keys with string, integer and boolean values are supported since
+// all options are flat.
+func parseConfig(r io.Reader, path string) ([]configOption, error) {
+	options := []configOption{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			return nil, fmt.Errorf("%s:%d: tables are not supported", path, line)
+		}
+
+		i := strings.Index(text, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, line)
+		}
+		key := strings.TrimSpace(text[:i])
+		value, err := parseConfigValue(strings.TrimSpace(text[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err.Error())
+		}
+		options = append(options, configOption{key: key, value: value, line: line})
+	}
+	return options, scanner.Err()
+}
+
+// parseConfigValue parses a single TOML value including a trailing comment.
+func parseConfigValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		// Find the closing quote which is not escaped
+		for i := 1; i < len(value); i++ {
+			if value[i] == '\\' {
+				i++
+				continue
+			}
+			if value[i] == '"' {
+				if err := checkTrailing(value[i+1:]); err != nil {
+					return "", err
+				}
+				return strconv.Unquote(value[:i+1])
+			}
+		}
+		return "", fmt.Errorf("unterminated string %s", value)
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		if err := checkTrailing(value[end+2:]); err != nil {
+			return "", err
+		}
+		return value[1 : end+1], nil
+	}
+
+	// Bare values are integers and booleans
+	if i := strings.Index(value, "#"); i != -1 {
+		value = strings.TrimSpace(value[:i])
+	}
+	if value == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	return strings.Replace(value, "_", "", -1), nil
+}
+
+// checkTrailing makes sure only a comment follows a value.
+func checkTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %s after value", rest)
+	}
+	return nil
+}
+
+// validateConfig checks the effective configuration.
+func validateConfig() error {
+	if gaia.Cfg.CanaryWorkers < 0 {
+		return fmt.Errorf("canaryworkers must not be negative, got %d", gaia.Cfg.CanaryWorkers)
+	}
+	if port, err := strconv.Atoi(gaia.Cfg.ListenPort); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %q", gaia.Cfg.ListenPort)
+	}
+	if gaia.Cfg.GRPCPort != "" {
+		if port, err := strconv.Atoi(gaia.Cfg.GRPCPort); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("grpcport must be between 1 and 65535, got %q", gaia.Cfg.GRPCPort)
+		} else if gaia.Cfg.GRPCPort == gaia.Cfg.ListenPort {
+			return fmt.Errorf("grpcport must differ from port %s", gaia.Cfg.ListenPort)
+		}
+	}
+	switch gaia.Cfg.SecretScan {
+	case gaia.SecretScanOff, gaia.SecretScanWarn, gaia.SecretScanFail:
+	default:
+		return fmt.Errorf("secretscan must be off, warn or fail, got %q", gaia.Cfg.SecretScan)
+	}
+	switch gaia.Cfg.Export {
+	case gaia.ExportOff:
+	case gaia.ExportHTTP, gaia.ExportKafka, gaia.ExportBigQuery:
+		if gaia.Cfg.ExportURL == "" {
+			return fmt.Errorf("exporturl is required for export %s", gaia.Cfg.Export)
+		}
+	default:
+		return fmt.Errorf("export must be off, http, kafka or bigquery, got %q", gaia.Cfg.Export)
+	}
+	if (gaia.Cfg.ClientCert == "") != (gaia.Cfg.ClientKey == "") {
+		return fmt.Errorf("clientcert and clientkey must be given together")
+	}
+	if gaia.Cfg.MirrorURL != "" && gaia.Cfg.ReplicationToken == "" {
+		return fmt.Errorf("replicationtoken is required for mirror")
+	}
+	return scheduler.ValidateSettings(&settings)
+}
+
+// writeConfig writes the effective configuration in config file format.
+func writeConfig(w io.Writer, fs *flag.FlagSet) {
+	for _, name := range configurableFlags(fs) {
+		f := fs.Lookup(name)
+		value := f.Value.String()
+		if containsFlag(secretFlags, name) && value != "" {
+			value = "******"
+		}
+
+		fmt.Fprintf(w, "# %s\n", f.Usage)
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil || value == "true" || value == "false" {
+			fmt.Fprintf(w, "%s = %s\n\n", name, value)
+		} else {
+			fmt.Fprintf(w, "%s = %s\n\n", name, strconv.Quote(value))
+		}
+	}
+}
+
+// configurableFlags returns the sorted names of all flags which
+// can be set by config file or environment.
+func configurableFlags(fs *flag.FlagSet) []string {
+	names := []string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if !containsFlag(commandFlags, f.Name) {
+			names = append(names, f.Name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+// containsFlag checks if the given flag name is in the given list.
+func containsFlag(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+)
+
+const (
+	// layoutFile is the file in the home folder which records the
+	// folders used by the last start of gaia.
+	layoutFile = "layout.json"
+
+	// boltDBFileName is the name of the database in the data folder.
+	boltDBFileName = "gaia.db"
+)
+
+// layout represents the folders gaia stores its data in.
+type layout struct {
+	Data      string `json:"data"`
+	Pipelines string `json:"pipelines"`
+	Workspace string `json:"workspace"`
+}
+
+// defaultLayout returns the layout with all folders below the given home folder.
+func defaultLayout(home string) layout {
+	return layout{
+		Data:      filepath.Join(home, dataFolder),
+		Pipelines: filepath.Join(home, pipelinesFolder),
+		Workspace: filepath.Join(home, workspaceFolder),
+	}
+}
+
+// loadLayout reads the recorded layout from the given home folder.
+// Gaia used the default layout before the layout was recorded.
+func loadLayout(home string) (layout, error) {
+	l := defaultLayout(home)
+	content, err := ioutil.ReadFile(filepath.Join(home, layoutFile))
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return l, err
+	}
+	return l, json.Unmarshal(content, &l)
+}
+
+// saveLayout records the given layout in the given home folder.
+func saveLayout(home string, l layout) error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(home, layoutFile), content, 0600)
+}
+
+// applyLayout makes sure all folders of the wanted layout exist.
+// Folders which still contain data at their recorded location are moved
+// if relocate is true. Otherwise an error is returned so no data is
+// silently left behind. The stored paths of the pipelines are moved
+// along with their folders.
+func applyLayout(home string, wanted layout, relocate bool) error {
+	recorded, err := loadLayout(home)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %s", layoutFile, err.Error())
+	}
+
+	moves := []struct{ from, to string }{
+		{recorded.Data, wanted.Data},
+		{recorded.Pipelines, wanted.Pipelines},
+		{recorded.Workspace, wanted.Workspace},
+	}
+	for _, m := range moves {
+		if m.from == m.to || isEmptyFolder(m.from) {
+			continue
+		}
+		if !relocate {
+			return fmt.Errorf("folder %s moved to %s but still contains data. Start gaia with -relocate to move the data", m.from, m.to)
+		}
+		if err := moveFolder(m.from, m.to); err != nil {
+			return fmt.Errorf("cannot move %s to %s: %s", m.from, m.to, err.Error())
+		}
+	}
+
+	for _, folder := range []string{wanted.Data, wanted.Pipelines, wanted.Workspace} {
+		if err := os.MkdirAll(folder, 0700); err != nil {
+			return err
+		}
+	}
+
+	// The layout is only recorded once the stored paths point to the
+	// new folders. A failed rewrite is repeated on the next start.
+	if err = relocatePaths(moves); err != nil {
+		return fmt.Errorf("cannot rewrite stored paths: %s", err.Error())
+	}
+	return saveLayout(home, wanted)
+}
+
+// relocatePaths rewrites the paths of the stored pipelines for all
+// given moves. The database is opened in the configured data folder.
+func relocatePaths(moves []struct{ from, to string }) error {
+	if _, err := os.Stat(filepath.Join(gaia.Cfg.DataPath, boltDBFileName)); os.IsNotExist(err) {
+		return nil
+	}
+
+	var s *store.Store
+	for _, m := range moves {
+		if m.from == m.to {
+			continue
+		}
+		if s == nil {
+			s = store.NewStore()
+			if err := s.Init(); err != nil {
+				return err
+			}
+			defer s.Close()
+		}
+		if err := s.PipelineRelocate(m.from, m.to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmptyFolder returns true if the given folder does not exist or is empty.
+func isEmptyFolder(path string) bool {
+	files, err := ioutil.ReadDir(path)
+	return err != nil || len(files) == 0
+}
+
+// moveFolder moves the content of the given folder to the given
+// destination which must not contain any data. If both are on
+// different volumes, the data is copied and the source is removed
+// afterwards. A failed copy leaves the source untouched.
+func moveFolder(from, to string) error {
+	if !isEmptyFolder(to) {
+		return fmt.Errorf("destination %s is not empty", to)
+	}
+	if strings.HasPrefix(to, from+string(filepath.Separator)) {
+		return fmt.Errorf("destination %s is inside of %s", to, from)
+	}
+	os.Remove(to)
+	if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+		return err
+	}
+
+	// Same volume
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	if err := copyFolder(from, to); err != nil {
+		os.RemoveAll(to)
+		return err
+	}
+	return os.RemoveAll(from)
+}
+
+// copyFolder copies the given folder recursively and keeps the permissions.
+func copyFolder(from, to string) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(to, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(dest, info.Mode().Perm())
+		}
+		return copyFile(path, dest, info.Mode())
+	})
+}
+
+// copyFile copies the given file and syncs it to disk.
+func copyFile(from, to string, mode os.FileMode) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(to, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	if err = dest.Sync(); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
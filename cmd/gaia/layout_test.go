@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestApplyLayoutRelocatesStoredPipelines(t *testing.T) {
+	home, err := ioutil.TempDir("", "TestApplyLayout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	old := defaultLayout(home)
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: home, DataPath: old.Data}
+	gaia.Cfg.Bolt.Mode = 0600
+
+	// Pipeline built with the default layout
+	for _, folder := range []string{old.Data, old.Pipelines, filepath.Join(old.Workspace, "clone")} {
+		if err = os.MkdirAll(folder, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binary := filepath.Join(old.Pipelines, "test_golang")
+	if err = ioutil.WriteFile(binary, []byte("binary"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	s := store.NewStore()
+	if err = s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	p := &gaia.Pipeline{
+		Name:     "test",
+		ExecPath: binary,
+		Binaries: map[string]string{"linux/arm64": binary + "_linux_arm64"},
+		Repo:     gaia.GitRepo{LocalDest: filepath.Join(old.Workspace, "clone")},
+	}
+	if err = s.PipelinePut(p); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Folders without relocation keep their data
+	wanted := layout{
+		Data:      filepath.Join(home, "db"),
+		Pipelines: filepath.Join(home, "bin"),
+		Workspace: filepath.Join(home, "work"),
+	}
+	gaia.Cfg.DataPath = wanted.Data
+	if err = applyLayout(home, wanted, false); err == nil {
+		t.Fatal("expected error for folders which still contain data")
+	}
+	if err = applyLayout(home, wanted, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored pipeline points to the moved binary
+	s = store.NewStore()
+	if err = s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	p, err = s.PipelineGet(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ExecPath != filepath.Join(wanted.Pipelines, "test_golang") || p.Binaries["linux/arm64"] != filepath.Join(wanted.Pipelines, "test_golang_linux_arm64") {
+		t.Fatalf("expected binaries in %s, got %s and %v", wanted.Pipelines, p.ExecPath, p.Binaries)
+	}
+	if p.Repo.LocalDest != filepath.Join(wanted.Workspace, "clone") {
+		t.Fatalf("expected repo in %s, got %s", wanted.Workspace, p.Repo.LocalDest)
+	}
+	if content, err := ioutil.ReadFile(p.ExecPath); err != nil || string(content) != "binary" {
+		t.Fatalf("cannot load relocated binary: %v", err)
+	}
+	if l, _ := loadLayout(home); l != wanted {
+		t.Fatalf("expected recorded layout %+v, got %+v", wanted, l)
+	}
+}
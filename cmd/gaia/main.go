@@ -1,22 +1,38 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gaia-pipeline/gaia"
 	"github.com/gaia-pipeline/gaia/handlers"
 	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/remote"
+	"github.com/gaia-pipeline/gaia/sandbox"
 	scheduler "github.com/gaia-pipeline/gaia/scheduler"
 	"github.com/gaia-pipeline/gaia/store"
+	"github.com/gaia-pipeline/gaia/wasm"
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/labstack/echo"
 )
 
 var (
 	echoInstance *echo.Echo
+
+	// relocate moves existing data to the configured folders and exits.
+	relocate bool
+
+	// settings holds the operational settings given by flags. They only
+	// apply until settings have been stored through the admin API.
+	settings gaia.Settings
 )
 
 const (
@@ -26,6 +42,10 @@ const (
 	dataFolder      = "data"
 	pipelinesFolder = "pipelines"
 	workspaceFolder = "workspace"
+
+	// vaultPassphraseFile is the file in the data folder which holds
+	// the generated vault passphrase.
+	vaultPassphraseFile = "vault.passphrase"
 )
 
 func init() {
@@ -33,25 +53,96 @@ func init() {
 
 	// command line arguments
 	flag.StringVar(&gaia.Cfg.ListenPort, "port", "8080", "Listen port for gaia")
+	flag.StringVar(&gaia.Cfg.GRPCPort, "grpcport", "", "Listen port of the gRPC API. The gRPC API is disabled if not given")
+	flag.StringVar(&gaia.Cfg.TLSCert, "tlscert", "", "Path to the PEM certificate the REST and gRPC APIs are served with. The APIs are served without TLS if not given")
+	flag.StringVar(&gaia.Cfg.TLSKey, "tlskey", "", "Path to the PEM key of the certificate given with -tlscert")
 	flag.StringVar(&gaia.Cfg.HomePath, "homepath", "", "Path to the gaia home folder")
-	flag.StringVar(&gaia.Cfg.Worker, "worker", "2", "Number of worker gaia will use to execute pipelines in parallel")
+	flag.StringVar(&gaia.Cfg.DataPath, "datapath", "", "Path to the folder of the store and vault. Defaults to the data folder in the home folder")
+	flag.StringVar(&gaia.Cfg.PipelinePath, "pipelinepath", "", "Path to the folder of the pipeline binaries. Defaults to the pipelines folder in the home folder")
+	flag.StringVar(&gaia.Cfg.WorkspacePath, "workspacepath", "", "Path to the folder of the run logs and workspaces. Defaults to the workspace folder in the home folder")
+	flag.BoolVar(&relocate, "relocate", false, "If true, moves existing data to the configured folders and immediately exits")
+	flag.StringVar(&configFile, "config", "", "Path to the config file. Defaults to gaia.toml in the home folder. Options are named like the flags and can be overwritten by GAIA_<OPTION> environment variables")
+	flag.BoolVar(&printConfig, "printconfig", false, "If true, will print the effective configuration and immediately exit")
+	flag.IntVar(&settings.Worker, "worker", 2, "Number of worker gaia will use to execute pipelines in parallel")
+	flag.IntVar(&settings.BuildWorker, "buildworker", 1, "Number of pipeline builds gaia will execute in parallel. Builds do not take workers from the runs")
+	flag.IntVar(&gaia.Cfg.CanaryWorkers, "canaryworkers", 0, "Number of worker which are designated canary worker. Canary runs are only executed by them")
 	flag.BoolVar(&gaia.Cfg.DevMode, "dev", false, "If true, gaia will be started in development mode. Don't use this in production!")
 	flag.BoolVar(&gaia.Cfg.VersionSwitch, "version", false, "If true, will print the version and immediately exit")
+	flag.DurationVar(&settings.PollInterval, "pollinterval", 5*time.Second, "Interval the pipelines folder is checked for new pipelines")
+	flag.Int64Var(&settings.JobLogLimit, "joblogsize", 50*1024*1024, "Maximum size of a job log in bytes. Larger logs keep their head and tail. Zero disables the limit")
+	flag.BoolVar(&settings.JobLogColors, "joblogcolors", true, "If true, the ANSI color codes of the job output are kept in the logs. Otherwise they are stripped")
+	flag.DurationVar(&settings.JobHeartbeat, "jobheartbeat", 2*time.Minute, "Duration without heartbeat after which a job is considered hung. Zero disables the hang detection")
+	flag.DurationVar(&settings.JobCancelGrace, "jobcancelgrace", 30*time.Second, "Duration a cancelled job has to clean up before it is killed")
+	flag.IntVar(&settings.QuarantineAfter, "quarantineafter", 5, "Number of consecutive failed scheduled runs which pause the schedules of a pipeline. 0 disables the quarantine")
+	flag.DurationVar(&settings.DeleteRetention, "deleteretention", 72*time.Hour, "Duration deleted pipelines are kept and can be restored")
+	flag.StringVar((*string)(&gaia.Cfg.SecretScan), "secretscan", string(gaia.SecretScanWarn), "Scan pipeline repos for committed credentials before the build. Either off, warn or fail")
+	flag.StringVar(&gaia.Cfg.OPAURL, "opaurl", "", "URL of the OPA policy which authorizes runs before they are scheduled, e.g. http://localhost:8181/v1/data/gaia/run/allow. Runs are not authorized if not given")
+	flag.StringVar(&gaia.Cfg.Environment, "environment", "", "Name of the environment this gaia instance serves, e.g. prod. It is passed to the run authorization policy")
+	flag.StringVar((*string)(&gaia.Cfg.Export), "export", string(gaia.ExportOff), "Sink the records of finished runs and jobs are exported to. Either off, http, kafka or bigquery")
+	flag.StringVar(&gaia.Cfg.ExportURL, "exporturl", "", "URL of the export sink. Either the HTTP collector, the topic of the Kafka REST proxy or the insertAll endpoint of the BigQuery table")
+	flag.StringVar(&gaia.Cfg.ExportToken, "exporttoken", "", "Bearer token sent to the export sink")
+	flag.StringVar(&gaia.Cfg.ExternalURL, "externalurl", "", "URL gaia is reached at by users, e.g. https://gaia.example.com. It is used in links to runs")
+	flag.StringVar(&gaia.Cfg.JiraURL, "jiraurl", "", "URL of the Jira instance whose issues are updated after successful runs, e.g. https://example.atlassian.net")
+	flag.StringVar(&gaia.Cfg.JiraUser, "jirauser", "", "User gaia updates the Jira issues as")
+	flag.StringVar(&gaia.Cfg.JiraToken, "jiratoken", "", "API token of the Jira user")
+	flag.StringVar(&gaia.Cfg.ServiceNowURL, "servicenowurl", "", "URL of the ServiceNow instance the change requests of ServiceNow gates are created in, e.g. https://example.service-now.com. ServiceNow gates are disabled if not given")
+	flag.StringVar(&gaia.Cfg.ServiceNowUser, "servicenowuser", "", "User gaia creates the change requests of ServiceNow gates as")
+	flag.StringVar(&gaia.Cfg.ServiceNowPassword, "servicenowpassword", "", "Password of the ServiceNow user")
+	flag.StringVar(&gaia.Cfg.APIAllow, "apiallow", "", "Comma separated CIDRs which are allowed to access the API. All networks are allowed if not given")
+	flag.StringVar(&gaia.Cfg.APIDeny, "apideny", "", "Comma separated CIDRs which are denied to access the API")
+	flag.StringVar(&gaia.Cfg.AdminAllow, "adminallow", "", "Comma separated CIDRs which are allowed to access the admin API. All networks are allowed if not given")
+	flag.StringVar(&gaia.Cfg.AdminDeny, "admindeny", "", "Comma separated CIDRs which are denied to access the admin API")
+	flag.StringVar(&gaia.Cfg.ChildAllow, "childallow", "", "Comma separated CIDRs which are allowed to trigger child pipelines. All networks are allowed if not given")
+	flag.StringVar(&gaia.Cfg.ChildDeny, "childdeny", "", "Comma separated CIDRs which are denied to trigger child pipelines")
+	flag.BoolVar(&gaia.Cfg.TrustProxy, "trustproxy", false, "If true, the client address is taken from the X-Forwarded-For and X-Real-IP headers. Only use this behind a proxy")
+	flag.StringVar(&gaia.Cfg.ReplicationToken, "replicationtoken", "", "Token standby instances authenticate with at the replication endpoint. Replication is disabled if not given")
+	flag.StringVar(&gaia.Cfg.MirrorURL, "mirror", "", "URL of the primary instance, e.g. https://gaia.example.com. If given, gaia starts as read-only standby which replicates the primary until it is promoted")
+	flag.DurationVar(&gaia.Cfg.MirrorInterval, "mirrorinterval", 30*time.Second, "Interval a standby instance replicates the primary")
+	flag.StringVar(&gaia.Cfg.CACerts, "cacerts", "", "Path to a PEM bundle of certificate authorities which are trusted in addition to the system ones by git, webhooks, dependency downloads and notifiers")
+	flag.StringVar(&gaia.Cfg.ClientCert, "clientcert", "", "Path to the PEM client certificate presented by outbound calls")
+	flag.StringVar(&gaia.Cfg.ClientKey, "clientkey", "", "Path to the PEM key of the client certificate")
+	flag.DurationVar(&gaia.Cfg.RegistryRetention, "registryretention", 30*24*time.Hour, "Duration published pipeline binaries are kept. The binaries of active pipelines are always kept. Zero keeps all binaries")
+	flag.DurationVar(&gaia.Cfg.RunTokenTTL, "runtokenttl", 0, "Max duration the token is valid which jobs use to upload artifacts, set outputs and add annotations to their run. Zero keeps it valid until the run is finished")
+	flag.StringVar(&gaia.Cfg.APIURL, "apiurl", "", "URL of the API which is passed to the jobs, e.g. https://gaia.example.com. Must be reachable from the SSH hosts. Defaults to the local port")
+	flag.StringVar(&gaia.Cfg.TemplateIndex, "templateindex", "", "URL of the git repo which holds the index of the pipeline templates")
+	flag.StringVar(&gaia.Cfg.VaultPassphrase, "vaultpassphrase", "", "Passphrase used to encrypt the vault. Prefer -vaultpassphrasefile or the GAIA_VAULTPASSPHRASE environment variable, flags are visible in the process list")
+	flag.StringVar(&gaia.Cfg.VaultPassphraseFile, "vaultpassphrasefile", "", "Path to a file which holds the vault passphrase, e.g. a mounted secret. If neither this nor -vaultpassphrase is given, a passphrase is generated and stored unencrypted in the data folder next to the vault")
 
 	// Default values
 	gaia.Cfg.Bolt.Mode = 0600
 }
 
 func main() {
+	// Pipelines with sandbox, on SSH hosts or compiled to webassembly
+	// are started through gaia itself
+	sandbox.Launch()
+	remote.Launch()
+	wasm.Launch()
+
 	// Parse command line flgs
 	flag.Parse()
 
 	// Check version switch
+	gaia.Cfg.Version = Version
 	if gaia.Cfg.VersionSwitch {
 		fmt.Printf("Gaia Version: V%s\n", Version)
 		os.Exit(0)
 	}
 
+	// Load options from environment and config file
+	if err := loadConfig(flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load configuration: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if err := validateConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if printConfig {
+		writeConfig(os.Stdout, flag.CommandLine)
+		os.Exit(0)
+	}
+
 	// Initialize shared logger
 	gaia.Cfg.Logger = hclog.New(&hclog.LoggerOptions{
 		Level:  hclog.Trace,
@@ -59,6 +150,15 @@ func main() {
 		Name:   "Gaia",
 	})
 
+	// Outbound calls trust the configured certificate authorities
+	tlsConfig, err := loadTLSConfig()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot load certificates", "error", err.Error())
+		os.Exit(1)
+	}
+	gaia.Cfg.TLS = tlsConfig
+	pipeline.InitGitTransport()
+
 	// Find path for gaia home folder if not given by parameter
 	if gaia.Cfg.HomePath == "" {
 		// Find executeable path
@@ -71,27 +171,75 @@ func main() {
 		gaia.Cfg.Logger.Debug("executeable path found", "path", execPath)
 	}
 
-	// Set data path, workspace path and pipeline path relative to home folder and create it
-	// if not exist.
-	gaia.Cfg.DataPath = filepath.Join(gaia.Cfg.HomePath, dataFolder)
-	err := os.MkdirAll(gaia.Cfg.DataPath, 0700)
+	// Set data path, workspace path and pipeline path relative to home folder
+	// if not given and create them if not exist.
+	homePath, err := filepath.Abs(gaia.Cfg.HomePath)
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot create folder", "error", err.Error(), "path", gaia.Cfg.DataPath)
+		gaia.Cfg.Logger.Error("cannot resolve home folder", "error", err.Error(), "path", gaia.Cfg.HomePath)
 		os.Exit(1)
 	}
-	gaia.Cfg.PipelinePath = filepath.Join(gaia.Cfg.HomePath, pipelinesFolder)
-	err = os.MkdirAll(gaia.Cfg.PipelinePath, 0700)
+	gaia.Cfg.HomePath = homePath
+	l := defaultLayout(gaia.Cfg.HomePath)
+	for _, p := range []struct {
+		path     *string
+		fallback string
+	}{
+		{&gaia.Cfg.DataPath, l.Data},
+		{&gaia.Cfg.PipelinePath, l.Pipelines},
+		{&gaia.Cfg.WorkspacePath, l.Workspace},
+	} {
+		if *p.path == "" {
+			*p.path = p.fallback
+		}
+		abs, err := filepath.Abs(*p.path)
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot resolve folder", "error", err.Error(), "path", *p.path)
+			os.Exit(1)
+		}
+		*p.path = abs
+	}
+	err = applyLayout(gaia.Cfg.HomePath, layout{
+		Data:      gaia.Cfg.DataPath,
+		Pipelines: gaia.Cfg.PipelinePath,
+		Workspace: gaia.Cfg.WorkspacePath,
+	}, relocate)
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot create folder", "error", err.Error(), "path", gaia.Cfg.PipelinePath)
+		gaia.Cfg.Logger.Error("cannot set up folders", "error", err.Error())
 		os.Exit(1)
 	}
-	gaia.Cfg.WorkspacePath = filepath.Join(gaia.Cfg.HomePath, workspaceFolder)
-	err = os.MkdirAll(gaia.Cfg.WorkspacePath, 0700)
+	if relocate {
+		gaia.Cfg.Logger.Info("relocation finished", "data", gaia.Cfg.DataPath, "pipelines", gaia.Cfg.PipelinePath, "workspace", gaia.Cfg.WorkspacePath)
+		os.Exit(0)
+	}
+
+	// Dependency downloads trust the system and the configured authorities
+	gaia.Cfg.CABundle, err = writeCABundle(filepath.Join(gaia.Cfg.DataPath, caBundleFile))
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot create data folder", "error", err.Error(), "path", gaia.Cfg.WorkspacePath)
+		gaia.Cfg.Logger.Error("cannot write certificate bundle", "error", err.Error())
 		os.Exit(1)
 	}
 
+	// Load the vault passphrase if not given by parameter. A generated
+	// passphrase only protects the vault if the data folder is copied
+	// without it.
+	switch {
+	case gaia.Cfg.VaultPassphrase != "":
+	case gaia.Cfg.VaultPassphraseFile != "":
+		gaia.Cfg.VaultPassphrase, err = readVaultPassphrase(gaia.Cfg.VaultPassphraseFile)
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot read vault passphrase", "error", err.Error())
+			os.Exit(1)
+		}
+	default:
+		path := filepath.Join(gaia.Cfg.DataPath, vaultPassphraseFile)
+		gaia.Cfg.VaultPassphrase, err = loadVaultPassphrase(path)
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot load vault passphrase", "error", err.Error())
+			os.Exit(1)
+		}
+		gaia.Cfg.Logger.Warn("vault passphrase is stored unencrypted next to the vault. Use -vaultpassphrasefile or GAIA_VAULTPASSPHRASE to keep it apart", "path", path)
+	}
+
 	// Initialize echo instance
 	echoInstance = echo.New()
 
@@ -103,13 +251,32 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize scheduler
-	scheduler := scheduler.NewScheduler(store)
-	err = scheduler.Init()
+	// Settings changed through the admin API take precedence over flags
+	stored, err := store.SettingsGet(settings)
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot initialize scheduler:", "error", err.Error())
+		gaia.Cfg.Logger.Error("cannot load settings", "error", err.Error())
 		os.Exit(1)
 	}
+	if stored != nil {
+		if err = scheduler.ValidateSettings(stored); err != nil {
+			gaia.Cfg.Logger.Error("invalid stored settings", "error", err.Error())
+			os.Exit(1)
+		}
+		gaia.Cfg.Logger.Info("using stored settings instead of flags", "worker", stored.Worker, "pollinterval", stored.PollInterval.String())
+		settings = *stored
+	}
+	gaia.SetSettings(settings)
+
+	// Initialize scheduler. A standby starts its scheduler once it
+	// has been promoted.
+	scheduler := scheduler.NewScheduler(store)
+	if gaia.Cfg.MirrorURL == "" {
+		err = scheduler.Init()
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot initialize scheduler:", "error", err.Error())
+			os.Exit(1)
+		}
+	}
 
 	// Initialize handlers
 	err = handlers.InitHandlers(echoInstance, store, scheduler)
@@ -121,7 +288,32 @@ func main() {
 	// Start ticker. Periodic job to check for new plugins.
 	pipeline.InitTicker(store, scheduler)
 
+	// Replicate the primary if this is a standby
+	pipeline.InitMirror()
+
+	// Serve the gRPC API if enabled
+	if gaia.Cfg.GRPCPort != "" {
+		lis, err := net.Listen("tcp", ":"+gaia.Cfg.GRPCPort)
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot listen for the gRPC API", "error", err.Error())
+			os.Exit(1)
+		}
+		server, err := handlers.NewGRPCServer()
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot create the gRPC API", "error", err.Error())
+			os.Exit(1)
+		}
+		go func() {
+			if err := server.Serve(lis); err != nil {
+				gaia.Cfg.Logger.Error("gRPC API stopped", "error", err.Error())
+			}
+		}()
+	}
+
 	// Start listen
+	if gaia.Cfg.TLSCert != "" {
+		echoInstance.Logger.Fatal(echoInstance.StartTLS(":"+gaia.Cfg.ListenPort, gaia.Cfg.TLSCert, gaia.Cfg.TLSKey))
+	}
 	echoInstance.Logger.Fatal(echoInstance.Start(":" + gaia.Cfg.ListenPort))
 }
 
@@ -134,3 +326,38 @@ func findExecuteablePath() (string, error) {
 	}
 	return filepath.Dir(ex), nil
 }
+
+// readVaultPassphrase reads the vault passphrase from the given file.
+// Surrounding whitespace like a trailing newline is removed.
+func readVaultPassphrase(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	passphrase := strings.TrimSpace(string(content))
+	if passphrase == "" {
+		return "", fmt.Errorf("vault passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
+
+// loadVaultPassphrase reads the vault passphrase from the given file.
+// If the file does not exist, a random passphrase will be generated
+// and written to it. The file is only readable by its owner.
+func loadVaultPassphrase(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		return string(content), os.Chmod(path, 0400)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// Generate new passphrase
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	passphrase := hex.EncodeToString(b)
+
+	return passphrase, ioutil.WriteFile(path, []byte(passphrase), 0400)
+}
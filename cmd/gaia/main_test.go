@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultPassphrase(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestVaultPassphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// Mounted secrets usually end with a newline
+	secret := filepath.Join(tmp, "secret")
+	if err = ioutil.WriteFile(secret, []byte("mounted passphrase\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if passphrase, err := readVaultPassphrase(secret); err != nil || passphrase != "mounted passphrase" {
+		t.Fatalf("expected mounted passphrase, got %q, %v", passphrase, err)
+	}
+	if err = ioutil.WriteFile(secret, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readVaultPassphrase(secret); err == nil {
+		t.Fatal("expected empty passphrase to be rejected")
+	}
+
+	// Generated passphrases are only readable by the owner
+	path := filepath.Join(tmp, vaultPassphraseFile)
+	generated, err := loadVaultPassphrase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0400 {
+		t.Fatalf("expected mode 0400, got %o", info.Mode().Perm())
+	}
+
+	// Existing files are tightened
+	if err = os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadVaultPassphrase(path)
+	if err != nil || loaded != generated {
+		t.Fatalf("expected generated passphrase, got %q, %v", loaded, err)
+	}
+	if info, _ = os.Stat(path); info.Mode().Perm() != 0400 {
+		t.Fatalf("expected mode 0400, got %o", info.Mode().Perm())
+	}
+}
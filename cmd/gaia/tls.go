@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// caBundleFile is the file in the data folder which holds the system
+// authorities together with the configured ones.
+const caBundleFile = "ca-bundle.pem"
+
+// systemCABundles are the locations of the system authorities on the
+// common distributions.
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// loadTLSConfig loads the configured certificate authorities and client
+// certificate for outbound calls. Returns nil if none are configured.
+func loadTLSConfig() (*tls.Config, error) {
+	if gaia.Cfg.CACerts == "" && gaia.Cfg.ClientCert == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+
+	// The authorities are trusted in addition to the system ones
+	if gaia.Cfg.CACerts != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(gaia.Cfg.CACerts)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", gaia.Cfg.CACerts)
+		}
+		config.RootCAs = pool
+	}
+
+	if gaia.Cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(gaia.Cfg.ClientCert, gaia.Cfg.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// writeCABundle writes the system authorities and the configured ones
+// into one bundle at the given path and returns its path. Tools which
+// take a single bundle otherwise lose the system authorities. Returns
+// the configured bundle as is if the system authorities are not found.
+func writeCABundle(path string) (string, error) {
+	if gaia.Cfg.CACerts == "" {
+		return "", nil
+	}
+	extra, err := ioutil.ReadFile(gaia.Cfg.CACerts)
+	if err != nil {
+		return "", err
+	}
+
+	candidates := systemCABundles
+	if env := os.Getenv("SSL_CERT_FILE"); env != "" {
+		candidates = []string{env}
+	}
+	for _, candidate := range candidates {
+		system, err := ioutil.ReadFile(candidate)
+		if err != nil {
+			continue
+		}
+		bundle := append(bytes.TrimRight(system, "\n"), '\n')
+		if err = ioutil.WriteFile(path, append(bundle, extra...), 0644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+
+	gaia.Cfg.Logger.Warn("cannot find system certificate authorities, tools only trust the configured ones", "cacerts", gaia.Cfg.CACerts)
+	return gaia.Cfg.CACerts, nil
+}
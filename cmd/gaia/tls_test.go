@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestWriteCABundle(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestWriteCABundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	system := filepath.Join(tmp, "system.pem")
+	extra := filepath.Join(tmp, "extra.pem")
+	if err = ioutil.WriteFile(system, []byte("system"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(extra, []byte("extra\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv("SSL_CERT_FILE")
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), CACerts: extra}
+
+	// The bundle keeps the system authorities
+	systemCABundles = []string{filepath.Join(tmp, "missing.pem"), system}
+	path, err := writeCABundle(filepath.Join(tmp, caBundleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "system\nextra\n" {
+		t.Fatalf("expected system and extra authorities, got %q", content)
+	}
+
+	// Without system authorities the configured ones are used as is
+	systemCABundles = []string{filepath.Join(tmp, "missing.pem")}
+	if path, err = writeCABundle(filepath.Join(tmp, caBundleFile)); err != nil || path != extra {
+		t.Fatalf("expected %s, got %s %v", extra, path, err)
+	}
+}
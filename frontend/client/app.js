@@ -59,15 +59,15 @@ Vue.prototype.$notify = openNotification
 
 function handleError (error) {
   // if the server gave a response message, print that
-  if (error.response.data.error) {
+  if (error.response.data.message) {
     // duration should be proportional to the error message length
     openNotification({
       title: 'Error: ' + error.response.status,
-      message: error.response.data.error,
+      message: error.response.data.message,
       type: 'danger',
-      duration: error.response.data.error.length > 60 ? 20000 : 4500
+      duration: error.response.data.message.length > 60 ? 20000 : 4500
     })
-    console.log(error.response.data.error)
+    console.log(error.response.data.message, error.response.data.correlationid)
   } else {
     if (error.response.status === 404) {
       openNotification({
@@ -1,7 +1,11 @@
 package gaia
 
 import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	hclog "github.com/hashicorp/go-hclog"
@@ -21,6 +25,25 @@ type PipelineRunStatus string
 // JobStatus represents the different status a job can have.
 type JobStatus string
 
+// AuditAction represents the different actions which are
+// recorded in the audit log.
+type AuditAction string
+
+// WebhookDeliveryStatus represents the different status the delivery
+// of a webhook can have.
+type WebhookDeliveryStatus string
+
+// CalendarPolicy defines what happens with runs which are
+// triggered during a freeze period.
+type CalendarPolicy string
+
+// SecretScanMode defines what happens with pipelines whose
+// repo contains committed credentials.
+type SecretScanMode string
+
+// ExportSink defines where the records of finished runs are exported to.
+type ExportSink string
+
 const (
 	// PTypeUnknown unknown plugin type
 	PTypeUnknown PipelineType = "unknown"
@@ -28,9 +51,15 @@ const (
 	// PTypeGolang golang plugin type
 	PTypeGolang PipelineType = "golang"
 
+	// PTypeWASM experimental webassembly plugin type
+	PTypeWASM PipelineType = "wasm"
+
 	// CreatePipelineFailed status
 	CreatePipelineFailed CreatePipelineType = "failed"
 
+	// CreatePipelineQueued status
+	CreatePipelineQueued CreatePipelineType = "queued"
+
 	// CreatePipelineRunning status
 	CreatePipelineRunning CreatePipelineType = "running"
 
@@ -52,6 +81,9 @@ const (
 	// RunRunning status
 	RunRunning PipelineRunStatus = "running"
 
+	// RunCancelled status
+	RunCancelled PipelineRunStatus = "cancelled"
+
 	// JobWaitingExec status
 	JobWaitingExec JobStatus = "waiting for execution"
 
@@ -64,8 +96,129 @@ const (
 	// JobRunning status
 	JobRunning JobStatus = "running"
 
+	// JobHung status
+	JobHung JobStatus = "hung"
+
+	// JobCancelled status
+	JobCancelled JobStatus = "cancelled"
+
+	// AuditSecretGrant is recorded when an admin changes the secret grants of a pipeline
+	AuditSecretGrant AuditAction = "secret grant"
+
+	// AuditSecretDenied is recorded when a run requested a secret which is not granted
+	AuditSecretDenied AuditAction = "secret denied"
+
+	// AuditVaultRotate is recorded when an admin rotated the vault master key
+	AuditVaultRotate AuditAction = "vault rotate"
+
+	// AuditRunCancel is recorded when a user cancelled a pipeline run
+	AuditRunCancel AuditAction = "run cancel"
+
+	// AuditRunBoost is recorded when an admin moved a queued run to the front of the queue
+	AuditRunBoost AuditAction = "run boost"
+
+	// AuditSettingsChange is recorded when an admin changed the server settings
+	AuditSettingsChange AuditAction = "settings change"
+
+	// AuditFaultInjection is recorded when an admin changed the injected faults
+	AuditFaultInjection AuditAction = "fault injection"
+
+	// AuditVariablesChange is recorded when an admin changed the global variables
+	AuditVariablesChange AuditAction = "variables change"
+
+	// AuditCatalogChange is recorded when an admin changed or removed a catalog variable
+	AuditCatalogChange AuditAction = "catalog change"
+
+	// AuditPolicyChange is recorded when an admin changed the dependency policy
+	AuditPolicyChange AuditAction = "policy change"
+
+	// AuditWaiverApprove is recorded when an admin approved a policy waiver
+	AuditWaiverApprove AuditAction = "waiver approve"
+
+	// AuditRunDenied is recorded when the policy engine denied a run
+	AuditRunDenied AuditAction = "run denied"
+
+	// AuditPipelineQuarantine is recorded when the schedules of a pipeline
+	// have been paused because its scheduled runs failed repeatedly
+	AuditPipelineQuarantine AuditAction = "pipeline quarantine"
+
+	// AuditPipelineResume is recorded when a user resumed a quarantined pipeline
+	AuditPipelineResume AuditAction = "pipeline resume"
+
+	// AuditPipelineRollback is recorded when the binary of a pipeline has
+	// been rolled back because the smoke run of a rebuild did not succeed
+	AuditPipelineRollback AuditAction = "pipeline rollback"
+
+	// AuditStandbyPromote is recorded when an admin promoted a standby instance to primary
+	AuditStandbyPromote AuditAction = "standby promote"
+
+	// WebhookPending status of deliveries which are retried
+	WebhookPending WebhookDeliveryStatus = "pending"
+
+	// WebhookDelivered status
+	WebhookDelivered WebhookDeliveryStatus = "delivered"
+
+	// WebhookDead status of deliveries which failed too often. They
+	// are only sent again when they are redelivered manually.
+	WebhookDead WebhookDeliveryStatus = "dead"
+
+	// GateHTTP waits until a GET request to the target returns 200
+	GateHTTP GateType = "http"
+
+	// GateDNS waits until the target host name can be resolved
+	GateDNS GateType = "dns"
+
+	// GateDelay waits for the given delay
+	GateDelay GateType = "delay"
+
+	// GateServiceNow creates a change request in the ServiceNow
+	// instance of the target and waits until it is approved
+	GateServiceNow GateType = "servicenow"
+
+	// CalendarHold keeps triggered runs queued until the freeze period is over
+	CalendarHold CalendarPolicy = "hold"
+
+	// CalendarReject rejects triggered runs during the freeze period
+	CalendarReject CalendarPolicy = "reject"
+
+	// SecretScanOff disables the secrets scanning of pipeline repos
+	SecretScanOff SecretScanMode = "off"
+
+	// SecretScanWarn reports found credentials in the build output
+	SecretScanWarn SecretScanMode = "warn"
+
+	// SecretScanFail fails the build if credentials were found
+	SecretScanFail SecretScanMode = "fail"
+
+	// ExportOff disables the export of run records
+	ExportOff ExportSink = "off"
+
+	// ExportHTTP posts run records to an HTTP collector
+	ExportHTTP ExportSink = "http"
+
+	// ExportKafka produces run records through the Kafka REST proxy
+	ExportKafka ExportSink = "kafka"
+
+	// ExportBigQuery streams run records into a BigQuery table
+	ExportBigQuery ExportSink = "bigquery"
+
 	// LogsFolderName represents the Name of the logs folder in pipeline run folder
 	LogsFolderName = "logs"
+
+	// LogEntriesFolderName represents the Name of the structured log entries folder in pipeline run folder
+	LogEntriesFolderName = "logentries"
+
+	// TelemetryFolderName represents the Name of the resource samples folder in pipeline run folder
+	TelemetryFolderName = "telemetry"
+
+	// InputsFolderName represents the Name of the uploaded input files folder in pipeline run folder
+	InputsFolderName = "inputs"
+
+	// ArtifactsFolderName represents the Name of the artifacts folder in pipeline run folder
+	ArtifactsFolderName = "artifacts"
+
+	// DebugLogFileName represents the Name of the diagnostics file of a debug run in pipeline run folder
+	DebugLogFileName = "debug.log"
 )
 
 // User is the user object
@@ -76,6 +229,7 @@ type User struct {
 	Tokenstring string    `json:"tokenstring,omitempty"`
 	JwtExpiry   int64     `json:"jwtexpiry,omitempty"`
 	LastLogin   time.Time `json:"lastlogin,omitempty"`
+	Admin       bool      `json:"admin,omitempty"`
 }
 
 // Pipeline represents a single pipeline
@@ -88,6 +242,319 @@ type Pipeline struct {
 	SHA256Sum []byte       `json:"sha256sum,omitempty"`
 	Jobs      []Job        `json:"jobs,omitempty"`
 	Created   time.Time    `json:"created,omitempty"`
+	Sandbox   Sandbox      `json:"sandbox,omitempty"`
+	Team      string       `json:"team,omitempty"`
+
+	// SSH is the remote host which executes the jobs of the pipeline.
+	SSH *SSHTarget `json:"ssh,omitempty"`
+
+	// WASMCapabilities are the host functions the module of a wasm
+	// pipeline may import.
+	WASMCapabilities []string `json:"wasmcapabilities,omitempty"`
+
+	// CanaryPending is the checksum of the pipeline binary which has
+	// a canary run that did not succeed yet.
+	CanaryPending []byte `json:"canarypending,omitempty"`
+
+	// ShadowRebuilds keeps rebuilt binaries in shadow mode until they
+	// are promoted. ShadowExecPath is the path of the shadow binary and
+	// ShadowRunID the id of its shadow run.
+	ShadowRebuilds bool   `json:"shadowrebuilds,omitempty"`
+	ShadowExecPath string `json:"shadowexecpath,omitempty"`
+	ShadowRunID    int    `json:"shadowrunid,omitempty"`
+
+	// Platforms are the additional platforms like linux/arm64 the
+	// pipeline is built for. Binaries holds the paths of their
+	// binaries by platform. ExecPath is built for the host of gaia and
+	// executed by the workers. The other binaries are executed on the
+	// SSH host of the pipeline.
+	Platforms []string          `json:"platforms,omitempty"`
+	Binaries  map[string]string `json:"binaries,omitempty"`
+
+	// Commit is the commit the pipeline binary has been built from.
+	// ShadowCommit is the commit of the shadow binary.
+	Commit       Commit `json:"commit,omitempty"`
+	ShadowCommit Commit `json:"shadowcommit,omitempty"`
+
+	// ProblemMatchers are applied to the job logs after every run.
+	ProblemMatchers []ProblemMatcher `json:"problemmatchers,omitempty"`
+
+	// MutexGroups are named locks shared between pipelines. Only one
+	// run holding a mutex group is executed at a time.
+	MutexGroups []string `json:"mutexgroups,omitempty"`
+
+	// ConcurrencyParams are names of run params. Runs with the same
+	// value of such a param are executed one at a time, runs with
+	// different values in parallel.
+	ConcurrencyParams []string `json:"concurrencyparams,omitempty"`
+
+	// Calendars are the names of the maintenance calendars which apply
+	// to this pipeline in addition to the global calendars.
+	Calendars []string `json:"calendars,omitempty"`
+
+	// Presets are named sets of options to start the pipeline with.
+	Presets []RunPreset `json:"presets,omitempty"`
+
+	// Variables are passed to the jobs as args. They override the
+	// global and catalog variables and are overridden by the params
+	// of a run.
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// CatalogVariables are the names of the variables of the catalog
+	// which are passed to the jobs. They override the global variables.
+	CatalogVariables []string `json:"catalogvariables,omitempty"`
+
+	// Gates are conditions a run waits on before its jobs are started.
+	Gates []Gate `json:"gates,omitempty"`
+
+	// Webhooks are sent when a run of the pipeline finished.
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+
+	// Jira updates the issues of the built commits after successful runs.
+	Jira *JiraIntegration `json:"jira,omitempty"`
+
+	// Runbook holds the operating instructions of the pipeline.
+	Runbook *Runbook `json:"runbook,omitempty"`
+
+	// Contract is published by the repo of the pipeline and replaced
+	// on every build.
+	Contract *PipelineContract `json:"contract,omitempty"`
+
+	// Schedules start the pipeline periodically. The schedules of
+	// quarantined pipelines are paused until the pipeline is resumed.
+	Schedules  []Schedule  `json:"schedules,omitempty"`
+	Quarantine *Quarantine `json:"quarantine,omitempty"`
+
+	// SmokeJob is the title of the job which is executed after every
+	// rebuild. Rollback holds the previous binary until the smoke run
+	// succeeded. It is restored if the smoke run did not succeed.
+	SmokeJob string    `json:"smokejob,omitempty"`
+	Rollback *Rollback `json:"rollback,omitempty"`
+
+	// Libraries pins shared job libraries by import path to a version.
+	// The latest version is used for libraries which are not pinned.
+	Libraries map[string]string `json:"libraries,omitempty"`
+
+	// Archived pipelines keep their runs but have no active binary and
+	// no schedules until they are unarchived.
+	Archived    bool      `json:"archived,omitempty"`
+	ArchiveDate time.Time `json:"archivedate,omitempty"`
+
+	// Deleted pipelines are kept until DeleteDate plus the retention period.
+	Deleted    bool      `json:"deleted,omitempty"`
+	DeleteDate time.Time `json:"deletedate,omitempty"`
+}
+
+// Commit represents the commit of a pipeline repo a binary has
+// been built from.
+type Commit struct {
+	Hash string    `json:"hash,omitempty"`
+	Date time.Time `json:"date,omitempty"`
+
+	// Issues are the Jira issue keys mentioned by the commits since
+	// the commit of the previous build.
+	Issues []string `json:"issues,omitempty"`
+}
+
+// JiraIntegration defines how the Jira issues of the commits of a
+// pipeline are updated when a run succeeded. The issues are commented
+// with a link to the run and moved with the given transition if it is
+// available. Only issues of the given projects are updated if given.
+type JiraIntegration struct {
+	Projects   []string `json:"projects,omitempty"`
+	Transition string   `json:"transition,omitempty"`
+}
+
+// Schedule represents a cron schedule of a pipeline. The cron expression
+// is evaluated in the given IANA timezone, UTC if empty. Params are
+// passed to the jobs of the scheduled runs.
+type Schedule struct {
+	Cron     string            `json:"cron"`
+	Timezone string            `json:"timezone,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// Quarantine represents the paused schedules of a pipeline whose
+// scheduled runs failed the given number of times in a row. RunID is
+// the last failed run.
+type Quarantine struct {
+	Since    time.Time `json:"since"`
+	Failures int       `json:"failures"`
+	RunID    int       `json:"runid"`
+}
+
+// Rollback represents the build of a pipeline before a rebuild. RunID
+// is the smoke run of the rebuilt binary. ExecPath is the kept copy of
+// the previous binary and Files maps the paths of all previous binaries
+// to their kept copies. The remaining fields are the build details of
+// the previous build.
+type Rollback struct {
+	ExecPath         string            `json:"execpath"`
+	Files            map[string]string `json:"files,omitempty"`
+	Commit           Commit            `json:"commit,omitempty"`
+	Platforms        []string          `json:"platforms,omitempty"`
+	Binaries         map[string]string `json:"binaries,omitempty"`
+	Contract         *PipelineContract `json:"contract,omitempty"`
+	Runbook          *Runbook          `json:"runbook,omitempty"`
+	WASMCapabilities []string          `json:"wasmcapabilities,omitempty"`
+	RunID            int               `json:"runid"`
+}
+
+// Runbook represents the markdown operating instructions of a pipeline.
+// Runbooks which are synced from the readme of the repo are replaced
+// on every build.
+type Runbook struct {
+	Content    string    `json:"content"`
+	SyncReadme bool      `json:"syncreadme,omitempty"`
+	Updated    time.Time `json:"updated"`
+	UpdatedBy  string    `json:"updatedby,omitempty"`
+}
+
+// PipelineContract holds the JSON schemas of the params a pipeline
+// accepts and of the params its jobs pass to child pipelines.
+type PipelineContract struct {
+	Params  json.RawMessage `json:"params,omitempty"`
+	Outputs json.RawMessage `json:"outputs,omitempty"`
+}
+
+// Sandbox represents the privilege restrictions which are applied
+// when the pipeline is executed as host process.
+type Sandbox struct {
+	// UID and GID the pipeline is executed with. Zero keeps the user of gaia.
+	UID uint32 `json:"uid,omitempty"`
+	GID uint32 `json:"gid,omitempty"`
+
+	// NoNewPrivileges prevents the pipeline from gaining privileges (e.g. setuid binaries).
+	NoNewPrivileges bool `json:"nonewprivileges,omitempty"`
+
+	// SeccompProfile is the path to a compiled seccomp BPF filter.
+	SeccompProfile string `json:"seccompprofile,omitempty"`
+
+	// ReadOnlyHome mounts the gaia home folder read-only for the pipeline.
+	ReadOnlyHome bool `json:"readonlyhome,omitempty"`
+}
+
+// SSHTarget represents a remote host which executes jobs over SSH.
+// The pipeline binary is copied to the host and removed afterwards.
+// Key is the vault key of the private key which must be granted to the
+// pipeline. HostKey is the public key of the host in authorized_keys
+// format. Platform selects the binary built for the host, e.g.
+// linux/arm64. Only the jobs with the given titles are executed on the
+// host, all jobs if none are given.
+type SSHTarget struct {
+	Host     string   `json:"host"`
+	User     string   `json:"user"`
+	Key      string   `json:"key"`
+	HostKey  string   `json:"hostkey"`
+	Platform string   `json:"platform,omitempty"`
+	Jobs     []string `json:"jobs,omitempty"`
+}
+
+// GateType represents the different conditions of gates.
+type GateType string
+
+// Gate represents a condition a run waits on before its jobs are
+// started. The condition is checked every interval until it is met
+// or the timeout passed.
+type Gate struct {
+	Name     string        `json:"name"`
+	Type     GateType      `json:"type"`
+	Target   string        `json:"target,omitempty"`
+	Delay    time.Duration `json:"delay,omitempty"`
+	Interval time.Duration `json:"interval,omitempty"`
+	Timeout  time.Duration `json:"timeout,omitempty"`
+
+	// Fields are the fields of the change request of ServiceNow gates.
+	// The values are Go templates which are rendered like webhooks.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Webhook represents an HTTP request which is sent when a run finished
+// with one of the given statuses. All statuses are sent if none are
+// given. The url, the header values and the body are Go templates
+// which are rendered with the pipeline and the run.
+type Webhook struct {
+	Name    string              `json:"name"`
+	URL     string              `json:"url"`
+	Method  string              `json:"method,omitempty"`
+	Events  []PipelineRunStatus `json:"events,omitempty"`
+	Headers map[string]string   `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+// WebhookDelivery represents the delivery of a webhook for an event of
+// a run. Data is the event the webhook is rendered with on every attempt.
+type WebhookDelivery struct {
+	ID          int                   `json:"id"`
+	PipelineID  int                   `json:"pipelineid"`
+	RunID       int                   `json:"runid"`
+	Webhook     string                `json:"webhook"`
+	Event       PipelineRunStatus     `json:"event"`
+	Status      WebhookDeliveryStatus `json:"status"`
+	Attempts    int                   `json:"attempts"`
+	LastError   string                `json:"lasterror,omitempty"`
+	Created     time.Time             `json:"created"`
+	NextAttempt time.Time             `json:"nextattempt,omitempty"`
+	Delivered   time.Time             `json:"delivered,omitempty"`
+	Data        json.RawMessage       `json:"data"`
+}
+
+// ProblemMatcher represents a regex which detects problems in job logs.
+type ProblemMatcher struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern"`
+	Severity string `json:"severity"`
+}
+
+// Annotation represents the problems of a job log detected by a matcher.
+type Annotation struct {
+	Matcher  string `json:"matcher"`
+	Severity string `json:"severity"`
+	JobID    uint32 `json:"jobid"`
+	Count    int    `json:"count"`
+
+	// First occurrence in the job log
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Offset  int64  `json:"offset"`
+}
+
+// SourceReference represents a file:line reference in a job log which
+// has been resolved to a file of the pipeline repo at the built commit.
+// Start and End are the byte offsets of the reference in the log.
+// URL is empty if the web interface of the repo host is unknown.
+type SourceReference struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+	Commit string `json:"commit"`
+	URL    string `json:"url,omitempty"`
+}
+
+// LogEntry represents a structured log entry emitted by a job.
+// Plain output lines are stored as entries with level info.
+type LogEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// ResourceSample represents the resource usage of a job process at a given time.
+type ResourceSample struct {
+	Time time.Time `json:"time"`
+
+	// CPU is the used cpu time since the last sample in percent of one core
+	CPU float64 `json:"cpu"`
+
+	// RSS is the resident memory in bytes
+	RSS uint64 `json:"rss"`
+
+	// ReadBytes and WriteBytes are the total bytes read and written to storage
+	ReadBytes  uint64 `json:"readbytes"`
+	WriteBytes uint64 `json:"writebytes"`
 }
 
 // GitRepo represents a single git repository
@@ -108,6 +575,19 @@ type Job struct {
 	Description string    `json:"desc,omitempty"`
 	Priority    int64     `json:"priority"`
 	Status      JobStatus `json:"status,omitempty"`
+
+	// Timestamps of a job which has been executed in a pipeline run
+	StartDate     time.Time `json:"startdate,omitempty"`
+	ConnectedDate time.Time `json:"connecteddate,omitempty"`
+	FinishDate    time.Time `json:"finishdate,omitempty"`
+}
+
+// Library represents a published version of a shared job library.
+// The builders make all libraries resolvable by their import path.
+type Library struct {
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	Published time.Time `json:"published"`
 }
 
 // CreatePipeline represents a pipeline which is not yet
@@ -119,6 +599,97 @@ type CreatePipeline struct {
 	StatusType CreatePipelineType `json:"statustype,omitempty"`
 	Output     string             `json:"output,omitempty"`
 	Created    time.Time          `json:"created,omitempty"`
+	Shadow     bool               `json:"shadow,omitempty"`
+
+	// Priority orders the queued builds. Builds with a higher priority
+	// are started first.
+	Priority int `json:"priority,omitempty"`
+
+	// Template is the name of the template the pipeline has been
+	// imported from.
+	Template string `json:"template,omitempty"`
+
+	// Dependencies are the third-party dependencies of the pipeline
+	// which have been found by the builder. DependenciesUnknown is set
+	// if the builder cannot list them, e.g. for committed modules.
+	Dependencies        []Dependency `json:"dependencies,omitempty"`
+	DependenciesUnknown bool         `json:"dependenciesunknown,omitempty"`
+
+	// CorrelationID is the id of the request which created the pipeline.
+	CorrelationID string `json:"correlationid,omitempty"`
+
+	// Metadata describes the build. Warnings report regressions
+	// compared to the previous build of the pipeline.
+	Metadata *BuildMetadata `json:"metadata,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// BuildMetadata represents the details of a pipeline build. The
+// duration of the compilation is given in seconds and the size of
+// the binary in bytes.
+type BuildMetadata struct {
+	Duration     float64 `json:"duration"`
+	BinarySize   int64   `json:"binarysize"`
+	Toolchain    string  `json:"toolchain,omitempty"`
+	Dependencies int     `json:"dependencies"`
+
+	// CacheHit is true if the dependencies have not been resolved
+	// because they did not change since a previous build.
+	CacheHit bool `json:"cachehit,omitempty"`
+}
+
+// Dependency represents a third-party dependency of a pipeline.
+// Version is the tag or commit of the dependency if known.
+type Dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	License string `json:"license"`
+}
+
+// DependencyPolicy represents the rules the dependencies of all
+// pipelines must follow. Pipelines which violate the policy are
+// not built unless an approved waiver exists.
+type DependencyPolicy struct {
+	ForbiddenLicenses []string               `json:"forbiddenlicenses,omitempty"`
+	Vulnerable        []VulnerableDependency `json:"vulnerable,omitempty"`
+	Waivers           []PolicyWaiver         `json:"waivers,omitempty"`
+}
+
+// VulnerableDependency represents versions of a dependency which
+// must not be used. A version matches the tag or the beginning of
+// the commit of a dependency.
+type VulnerableDependency struct {
+	Path     string   `json:"path"`
+	Versions []string `json:"versions"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// PolicyWaiver allows the given pipeline to use the given dependency
+// despite the dependency policy. Waivers are requested by users and
+// only apply after an admin approved them.
+type PolicyWaiver struct {
+	ID          string    `json:"id"`
+	Pipeline    string    `json:"pipeline"`
+	Dependency  string    `json:"dependency"`
+	Reason      string    `json:"reason"`
+	RequestedBy string    `json:"requestedby,omitempty"`
+	Approved    bool      `json:"approved,omitempty"`
+	ApprovedBy  string    `json:"approvedby,omitempty"`
+	Expires     time.Time `json:"expires,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// PipelineTemplate represents a pipeline of the template index
+// which can be imported. Secrets are the vault keys the pipeline
+// needs and Params the parameters with their default values.
+type PipelineTemplate struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	URL         string            `json:"url"`
+	Branch      string            `json:"branch,omitempty"`
+	Type        PipelineType      `json:"type,omitempty"`
+	Secrets     []string          `json:"secrets,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
 }
 
 // PrivateKey represents a pem encoded private key
@@ -136,30 +707,394 @@ type PipelineRun struct {
 	StartDate    time.Time         `json:"startdate,omitempty"`
 	FinishDate   time.Time         `json:"finishdate,omitempty"`
 	ScheduleDate time.Time         `json:"scheduledate,omitempty"`
+	DispatchDate time.Time         `json:"dispatchdate,omitempty"`
 	Status       PipelineRunStatus `json:"status,omitempty"`
 	Jobs         []Job             `json:"jobs,omitempty"`
+	Secrets      []string          `json:"secrets,omitempty"`
+	Worker       int               `json:"worker,omitempty"`
+	Canary       bool              `json:"canary,omitempty"`
+	Debug        bool              `json:"debug,omitempty"`
+	Shadow       bool              `json:"shadow,omitempty"`
+	ShadowOf     int               `json:"shadowof,omitempty"`
+	Smoke        bool              `json:"smoke,omitempty"`
+	Annotations  []Annotation      `json:"annotations,omitempty"`
+	OnlyJobs     []uint32          `json:"onlyjobs,omitempty"`
+	RerunOf      int               `json:"rerunof,omitempty"`
+	Boosted      bool              `json:"boosted,omitempty"`
+	HeldBy       string            `json:"heldby,omitempty"`
+	Inputs       []string          `json:"inputs,omitempty"`
+	StartedBy    string            `json:"startedby,omitempty"`
+	Scheduled    bool              `json:"scheduled,omitempty"`
+	Commit       Commit            `json:"commit,omitempty"`
+
+	// CorrelationID is the id of the request which started the run.
+	// Child runs have the id of their parent run.
+	CorrelationID string `json:"correlationid,omitempty"`
+
+	// Params are passed to the jobs as args. Child runs have been
+	// triggered by a job of the given parent run.
+	Params           map[string]string `json:"params,omitempty"`
+	ParentPipelineID int               `json:"parentpipelineid,omitempty"`
+	ParentRunID      int               `json:"parentrunid,omitempty"`
+	Depth            int               `json:"depth,omitempty"`
+
+	// Outputs and Artifacts have been reported by the jobs of the run
+	// with the run token.
+	Outputs   map[string]string `json:"outputs,omitempty"`
+	Artifacts []string          `json:"artifacts,omitempty"`
+
+	// Gate is the name of the gate the run waits on or which
+	// did not open in time.
+	Gate string `json:"gate,omitempty"`
+
+	// ChangeRequest is the number of the change request which has
+	// been created by a ServiceNow gate.
+	ChangeRequest string `json:"changerequest,omitempty"`
+
+	// Slow marks runs which took much longer than the baseline
+	// duration of the pipeline. The baseline is given in seconds.
+	Slow            bool    `json:"slow,omitempty"`
+	BaselineSeconds float64 `json:"baselineseconds,omitempty"`
+
+	// Checksum is the SHA256 checksum of the executed binary, Platform
+	// the platform of the host of gaia and Environment the environment of
+	// the instance which executed the run.
+	Checksum    []byte `json:"checksum,omitempty"`
+	Platform    string `json:"platform,omitempty"`
+	Environment string `json:"environment,omitempty"`
+
+	// SSHChecksum is the SHA256 checksum of the binary which executed
+	// the jobs on the SSH host of the pipeline, SSHPlatform the
+	// platform it has been built for. Both are empty if no job of the
+	// run has been executed on the SSH host.
+	SSHChecksum []byte `json:"sshchecksum,omitempty"`
+	SSHPlatform string `json:"sshplatform,omitempty"`
+
+	// PinnedBinary and PinnedSSHBinary are the paths of the published
+	// binaries a rerun executes instead of the current binaries of the
+	// pipeline. They are the binaries of the original run.
+	PinnedBinary    string `json:"pinnedbinary,omitempty"`
+	PinnedSSHBinary string `json:"pinnedsshbinary,omitempty"`
+}
+
+// DORAMetrics represents the delivery performance of one pipeline or
+// of all pipelines in a period. Every successful run is a deployment.
+// Durations are given in seconds and are the median of the period.
+type DORAMetrics struct {
+	PipelineID int       `json:"pipelineid,omitempty"`
+	Since      time.Time `json:"since"`
+
+	// DeploymentFrequency is the number of deployments per day.
+	Deployments         int     `json:"deployments"`
+	DeploymentFrequency float64 `json:"deploymentfrequency"`
+
+	// LeadTime is the time from a commit to its first deployment.
+	// Only runs of binaries with known commit are considered.
+	LeadTime float64 `json:"leadtime"`
+
+	// ChangeFailureRate is the ratio of failed runs to finished runs.
+	Failures          int     `json:"failures"`
+	ChangeFailureRate float64 `json:"changefailurerate"`
+
+	// RecoveryTime is the time from the first failed run to the next
+	// successful run of the same pipeline.
+	Recoveries   int     `json:"recoveries"`
+	RecoveryTime float64 `json:"recoverytime"`
+}
+
+// RunMetrics represents the rolled-up metrics of the finished runs
+// of one pipeline on one day. They are kept after the runs are gone.
+type RunMetrics struct {
+	PipelineID int    `json:"pipelineid"`
+	Day        string `json:"day"`
+	Runs       int    `json:"runs"`
+	Failed     int    `json:"failed"`
+	Slow       int    `json:"slow"`
+
+	// Durations of the successful runs in seconds
+	Seconds        float64 `json:"seconds"`
+	SquaredSeconds float64 `json:"squaredseconds"`
+	MinSeconds     float64 `json:"minseconds"`
+	MaxSeconds     float64 `json:"maxseconds"`
+}
+
+// RunPreset represents a named set of options to start a pipeline with.
+type RunPreset struct {
+	Name         string   `json:"name"`
+	Secrets      []string `json:"secrets,omitempty"`
+	Canary       bool     `json:"canary,omitempty"`
+	Debug        bool     `json:"debug,omitempty"`
+	Jobs         []uint32 `json:"jobs,omitempty"`
+	Dependencies bool     `json:"dependencies,omitempty"`
+
+	// Params are passed to the jobs as args.
+	Params map[string]string `json:"params,omitempty"`
 }
 
-// Cfg represents the global config instance
-var Cfg *Config
+// Calendar represents reusable freeze periods like holidays.
+// Global calendars apply to all pipelines.
+type Calendar struct {
+	Name    string           `json:"name"`
+	Global  bool             `json:"global,omitempty"`
+	Policy  CalendarPolicy   `json:"policy"`
+	Windows []CalendarWindow `json:"windows"`
+}
+
+// CatalogVariable represents a non-secret variable which is managed
+// by admins and referenced by pipelines by its name. Only pipelines
+// of the given teams can reference it. All pipelines can if no team
+// is given.
+type CatalogVariable struct {
+	Name        string    `json:"name"`
+	Value       string    `json:"value"`
+	Description string    `json:"description,omitempty"`
+	Teams       []string  `json:"teams,omitempty"`
+	Updated     time.Time `json:"updated"`
+	UpdatedBy   string    `json:"updatedby,omitempty"`
+}
+
+// CalendarWindow represents a single freeze period of a calendar.
+type CalendarWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// ReplicationSnapshot holds the replicated state of a primary instance.
+// The buckets are copied as they are, vault entries stay encrypted.
+type ReplicationSnapshot struct {
+	Created  time.Time           `json:"created"`
+	Buckets  []ReplicationBucket `json:"buckets"`
+	Binaries []ReplicationBinary `json:"binaries"`
+	Files    []ReplicationFile   `json:"files"`
+}
+
+// ReplicationBucket holds all entries of one replicated store bucket.
+type ReplicationBucket struct {
+	Name    string             `json:"name"`
+	Entries []ReplicationEntry `json:"entries"`
+}
+
+// ReplicationEntry represents a single raw entry of a store bucket.
+type ReplicationEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// ReplicationBinary represents the binary of an active pipeline of
+// the primary instance.
+type ReplicationBinary struct {
+	PipelineID int    `json:"pipelineid"`
+	File       string `json:"file"`
+	SHA256Sum  []byte `json:"sha256sum"`
+}
+
+// ReplicationFile represents a log or artifact file of a run of the
+// primary instance. The path is relative to the workspace folder.
+type ReplicationFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// RegistryBinary represents a pipeline binary which has been published
+// to the registry. Binaries are addressed by their checksum.
+type RegistryBinary struct {
+	SHA256Sum string       `json:"sha256sum"`
+	Pipeline  string       `json:"pipeline"`
+	Type      PipelineType `json:"type"`
+	Platform  string       `json:"platform"`
+	Commit    Commit       `json:"commit,omitempty"`
+	Shadow    bool         `json:"shadow,omitempty"`
+	Size      int64        `json:"size"`
+	Published time.Time    `json:"published"`
+}
+
+// AuditEntry represents a single entry in the audit log.
+type AuditEntry struct {
+	ID      int         `json:"id"`
+	Created time.Time   `json:"created"`
+	Actor   string      `json:"actor"`
+	Action  AuditAction `json:"action"`
+	Target  string      `json:"target,omitempty"`
+	Message string      `json:"message,omitempty"`
+
+	// CorrelationID is the id of the request which caused the entry.
+	CorrelationID string `json:"correlationid,omitempty"`
+}
+
+// Settings holds the operational settings which can be changed at
+// runtime through the admin API. They are persisted in the store.
+type Settings struct {
+	PollInterval    time.Duration `json:"pollinterval"`
+	Worker          int           `json:"worker"`
+	DeleteRetention time.Duration `json:"deleteretention"`
+	JobLogLimit     int64         `json:"joblogsize"`
+	JobHeartbeat    time.Duration `json:"jobheartbeat"`
+	JobCancelGrace  time.Duration `json:"jobcancelgrace"`
+
+	// JobLogColors keeps the ANSI color codes of the job output in
+	// the logs. Otherwise they are stripped before the logs are stored.
+	JobLogColors bool `json:"joblogcolors"`
+
+	// QuarantineAfter is the number of consecutive failed scheduled
+	// runs which quarantine a pipeline. Zero disables the quarantine.
+	QuarantineAfter int `json:"quarantineafter"`
+
+	// BuildWorker is the number of pipeline builds which are executed
+	// in parallel. Builds do not take workers from the runs.
+	BuildWorker int `json:"buildworker"`
+}
+
+var (
+	// Cfg represents the global config instance
+	Cfg *Config
+
+	// settings holds the current operational settings.
+	settings     Settings
+	settingsLock sync.RWMutex
+)
 
 // Config holds all config options
 type Config struct {
-	DevMode       bool
-	VersionSwitch bool
-	ListenPort    string
-	HomePath      string
-	DataPath      string
-	PipelinePath  string
-	WorkspacePath string
-	Worker        string
-	Logger        hclog.Logger
+	DevMode         bool
+	VersionSwitch   bool
+	ListenPort      string
+	GRPCPort        string
+	TLSCert         string
+	TLSKey          string
+	HomePath        string
+	DataPath        string
+	PipelinePath    string
+	WorkspacePath   string
+	CanaryWorkers   int
+	Version         string
+	Logger          hclog.Logger
+	VaultPassphrase string
+	TemplateIndex   string
+	SecretScan      SecretScanMode
+	OPAURL          string
+	Environment     string
+	TrustProxy      bool
+
+	// VaultPassphraseFile holds the vault passphrase, e.g. a secret
+	// mounted by the container runtime.
+	VaultPassphraseFile string
+
+	// Export holds the sink the records of finished runs are
+	// streamed to. The token is sent as bearer token.
+	Export      ExportSink
+	ExportURL   string
+	ExportToken string
+
+	// ExternalURL is the address gaia is reached at by users. It is
+	// used in links to runs.
+	ExternalURL string
+
+	// Jira holds the connection which updates the issues of the
+	// built commits. The token is an API token of the user.
+	JiraURL   string
+	JiraUser  string
+	JiraToken string
+
+	// ServiceNow holds the instance and the credentials for the change
+	// requests of ServiceNow gates. Gates can only target this instance.
+	ServiceNowURL      string
+	ServiceNowUser     string
+	ServiceNowPassword string
+
+	// Comma separated CIDRs which are allowed and denied to access
+	// the API, the admin API and the child pipeline endpoints.
+	APIAllow   string
+	APIDeny    string
+	AdminAllow string
+	AdminDeny  string
+	ChildAllow string
+	ChildDeny  string
+
+	// ReplicationToken authenticates standby instances at the
+	// replication endpoint. Replication is disabled if not given.
+	// MirrorURL is the address of the primary a standby instance
+	// replicates from every MirrorInterval.
+	ReplicationToken string
+	MirrorURL        string
+	MirrorInterval   time.Duration
+
+	// RegistryRetention is the duration published binaries are kept
+	// after they have been published the last time.
+	RegistryRetention time.Duration
+
+	// RunTokenTTL is the max duration the token of a run is valid.
+	// Jobs use it to call the API on behalf of their run. Tokens are
+	// valid until the run is finished if it is zero.
+	RunTokenTTL time.Duration
+
+	// APIURL is the url of the API which is passed to the jobs. It must
+	// be reachable from the SSH hosts if jobs are executed there.
+	APIURL string
+
+	// CACerts is the PEM bundle of additional certificate authorities
+	// which are trusted by outbound calls. ClientCert and ClientKey
+	// are the optional client certificate presented to the servers.
+	// TLS is the resulting config. It is nil if none of them is given.
+	CACerts    string
+	ClientCert string
+	ClientKey  string
+	TLS        *tls.Config
+
+	// CABundle is the PEM bundle of the system authorities and CACerts
+	// which is handed to tools like go and git.
+	CABundle string
+
+	// transport is shared by all outbound clients so their
+	// connections are reused.
+	transport     *http.Transport
+	transportOnce sync.Once
 
 	Bolt struct {
 		Mode os.FileMode
 	}
 }
 
+// GetSettings returns the current operational settings.
+func GetSettings() Settings {
+	settingsLock.RLock()
+	defer settingsLock.RUnlock()
+
+	return settings
+}
+
+// SetSettings replaces the current operational settings.
+// The new settings are picked up by all components without restart.
+func SetSettings(s Settings) {
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+
+	settings = s
+}
+
+// HTTPClient returns a client for outbound calls with the given timeout.
+// The client trusts the configured certificate authorities.
+func (c *Config) HTTPClient(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if c.TLS != nil {
+		c.transportOnce.Do(func() {
+			c.transport = &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     c.TLS,
+				TLSHandshakeTimeout: 10 * time.Second,
+				IdleConnTimeout:     90 * time.Second,
+			}
+		})
+		client.Transport = c.transport
+	}
+	return client
+}
+
+// Enabled returns true if at least one restriction is configured.
+func (s Sandbox) Enabled() bool {
+	return s.UID != 0 || s.GID != 0 || s.NoNewPrivileges || s.SeccompProfile != "" || s.ReadOnlyHome
+}
+
 // String returns a pipeline type string back
 func (p PipelineType) String() string {
 	return string(p)
@@ -141,6 +141,11 @@ func UserAdd(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "Invalid parameters given for add user request")
 	}
 
+	// Only admins are allowed to create other admins
+	if u.Admin && !isAdmin(c) {
+		return c.String(http.StatusForbidden, errNotAdmin.Error())
+	}
+
 	// Add user
 	u.LastLogin = time.Now()
 	err := storeService.UserPut(u, true)
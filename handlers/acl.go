@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+// errNetworkDenied is thrown when a request was sent from a network
+// which is not allowed to access the endpoint.
+var errNetworkDenied = errors.New("access from your network is not allowed")
+
+// networkDeniedDetails tells the client which address has been denied.
+type networkDeniedDetails struct {
+	Address string `json:"address"`
+}
+
+// networkACL represents the networks which are allowed and denied to
+// access a group of endpoints. Denied networks take precedence. If no
+// network is allowed, all networks which are not denied are allowed.
+type networkACL struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+var (
+	// apiACL applies to all API endpoints except the child endpoints.
+	apiACL *networkACL
+
+	// adminACL applies to the admin endpoints in addition to apiACL.
+	adminACL *networkACL
+
+	// childACL applies to the endpoints jobs use to trigger child pipelines
+	// and to report to their run.
+	childACL *networkACL
+)
+
+// initACLs parses the configured network ACLs of all endpoint groups.
+func initACLs() error {
+	var err error
+	if apiACL, err = parseACL(gaia.Cfg.APIAllow, gaia.Cfg.APIDeny); err != nil {
+		return err
+	}
+	if adminACL, err = parseACL(gaia.Cfg.AdminAllow, gaia.Cfg.AdminDeny); err != nil {
+		return err
+	}
+	childACL, err = parseACL(gaia.Cfg.ChildAllow, gaia.Cfg.ChildDeny)
+	return err
+}
+
+// parseACL parses the given comma separated lists of CIDRs. Single
+// addresses are accepted as well.
+func parseACL(allow, deny string) (*networkACL, error) {
+	acl := &networkACL{}
+	var err error
+	if acl.allow, err = parseNetworks(allow); err != nil {
+		return nil, err
+	}
+	if acl.deny, err = parseNetworks(deny); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// parseNetworks parses the given comma separated list of CIDRs.
+func parseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(list, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		// Single addresses are networks with one address
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", cidr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// permits returns true if the given address may access the endpoints.
+func (a *networkACL) permits(ip net.IP) bool {
+	if a == nil {
+		return true
+	}
+	if ip == nil {
+		return len(a.allow) == 0 && len(a.deny) == 0
+	}
+	for _, n := range a.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(a.allow) == 0 {
+		return true
+	}
+	for _, n := range a.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address the request has been sent from.
+// Forwarded headers are only trusted behind a proxy.
+func clientIP(c echo.Context) net.IP {
+	if gaia.Cfg.TrustProxy {
+		return net.ParseIP(c.RealIP())
+	}
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		host = c.Request().RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// networkBarrier is the middleware which rejects requests from networks
+// which are not allowed to access the API or the child endpoints.
+func networkBarrier(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		acl := apiACL
+		if strings.HasPrefix(c.Path(), childPathPrefix) || strings.HasPrefix(c.Path(), jobPathPrefix) {
+			acl = childACL
+		} else if !strings.HasPrefix(c.Path(), "/api/") {
+			return next(c)
+		}
+
+		if ip := clientIP(c); !acl.permits(ip) {
+			return errorResponse(c, http.StatusForbidden, errNetworkDenied.Error(), networkDeniedDetails{Address: ip.String()})
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+const (
+	// archiveDateFormat is the date format of the from and to parameters.
+	archiveDateFormat = "2006-01-02"
+
+	// archiveRunFileName is the name of the run metadata file in the archive.
+	archiveRunFileName = "run.json"
+)
+
+// PipelineRunGetArchive returns all logs of the given pipeline run
+// as tar.gz archive.
+// Required parameters are pipelineid and runid.
+func PipelineRunGetArchive(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	// Find pipeline run in store
+	pipelineRun, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if pipelineRun == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+
+	name := fmt.Sprintf("pipeline-%d-run-%d.tar.gz", pipelineID, runID)
+	return writeRunArchive(c, name, []gaia.PipelineRun{*pipelineRun})
+}
+
+// PipelineGetRunsArchive returns all logs of the runs of the given
+// pipeline which have been scheduled in the given date range as tar.gz archive.
+// Required parameter is pipelineid.
+// Optional parameters are from and to (YYYY-MM-DD, both inclusive).
+func PipelineGetRunsArchive(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	var from, to time.Time
+	if f := c.QueryParam("from"); f != "" {
+		if from, err = time.Parse(archiveDateFormat, f); err != nil {
+			return c.String(http.StatusBadRequest, "invalid from date given. Expected format is YYYY-MM-DD")
+		}
+	}
+	if t := c.QueryParam("to"); t != "" {
+		if to, err = time.Parse(archiveDateFormat, t); err != nil {
+			return c.String(http.StatusBadRequest, "invalid to date given. Expected format is YYYY-MM-DD")
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	runs, err := storeService.PipelineGetAllRuns(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	selected := []gaia.PipelineRun{}
+	for _, r := range runs {
+		if !from.IsZero() && r.ScheduleDate.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !r.ScheduleDate.Before(to) {
+			continue
+		}
+		selected = append(selected, r)
+	}
+
+	name := fmt.Sprintf("pipeline-%d-runs.tar.gz", pipelineID)
+	return writeRunArchive(c, name, selected)
+}
+
+// writeRunArchive streams the metadata and the run folder of all
+// given runs as tar.gz archive. Every run has its own folder.
+func writeRunArchive(c echo.Context, name string, runs []gaia.PipelineRun) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/gzip")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+	c.Response().WriteHeader(http.StatusOK)
+
+	gw := gzip.NewWriter(c.Response())
+	tw := tar.NewWriter(gw)
+	for _, r := range runs {
+		if err := addRunToArchive(tw, &r); err != nil {
+			// Headers are already sent. We can only abort the archive.
+			gaia.Cfg.Logger.Error("cannot add pipeline run to archive", "error", err.Error(), "run", r.ID)
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// addRunToArchive adds the metadata and all files of the run folder.
+func addRunToArchive(tw *tar.Writer, r *gaia.PipelineRun) error {
+	prefix := fmt.Sprintf("run-%d", r.ID)
+
+	// Metadata of the run
+	meta, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    path.Join(prefix, archiveRunFileName),
+		Mode:    0600,
+		Size:    int64(len(meta)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = tw.Write(meta); err != nil {
+		return err
+	}
+
+	// Everything in the run folder. Queued runs have no folder yet.
+	root := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(r.PipelineID), strconv.Itoa(r.ID))
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// Running jobs might still write. Only copy the announced size.
+		_, err = io.CopyN(tw, f, header.Size)
+		return err
+	})
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// AuditGetAll returns all entries of the audit log.
+func AuditGetAll(c echo.Context) error {
+	entries, err := storeService.AuditGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Authenticator authenticates the users of the REST and gRPC API by one
+// scheme. Deployments add their own schemes like Kerberos or headers
+// signed by an API gateway with RegisterAuthenticator.
+type Authenticator interface {
+	// Authenticate returns the name of the user who sent the given
+	// request. The name is empty if the request does not carry
+	// credentials of this scheme. Invalid credentials return an error
+	// and are not passed on to the other schemes.
+	Authenticate(r *http.Request) (string, error)
+}
+
+// AuthenticatorFunc is an adapter to use ordinary functions as
+// Authenticator.
+type AuthenticatorFunc func(r *http.Request) (string, error)
+
+// Authenticate calls f(r).
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (string, error) {
+	return f(r)
+}
+
+var (
+	// authenticators is the chain of schemes which authenticate requests.
+	// The jwt tokens issued by the login are always accepted.
+	authenticators     = []Authenticator{AuthenticatorFunc(jwtAuthenticate)}
+	authenticatorsLock sync.RWMutex
+)
+
+// RegisterAuthenticator appends the given authenticator to the chain.
+// Schemes are tried in the order they have been registered.
+func RegisterAuthenticator(a Authenticator) {
+	authenticatorsLock.Lock()
+	defer authenticatorsLock.Unlock()
+
+	authenticators = append(authenticators, a)
+}
+
+// authenticate passes the given request through the authenticator
+// chain and returns the name of the user of the first scheme which
+// recognized the request.
+func authenticate(r *http.Request) (string, error) {
+	authenticatorsLock.RLock()
+	chain := authenticators
+	authenticatorsLock.RUnlock()
+
+	for _, a := range chain {
+		username, err := a.Authenticate(r)
+		if err != nil {
+			return "", err
+		} else if username != "" {
+			return username, nil
+		}
+	}
+	return "", errNotAuthorized
+}
+
+// jwtAuthenticate authenticates requests by the bearer jwt token which
+// has been issued by the login. Other authorization schemes are left
+// to the other authenticators.
+func jwtAuthenticate(r *http.Request) (string, error) {
+	authorization := r.Header.Get("Authorization")
+	split := strings.SplitN(authorization, " ", 2)
+	if len(split) != 2 || !strings.EqualFold(split[0], "Bearer") {
+		return "", nil
+	}
+
+	claims, err := validateToken(authorization)
+	if err != nil {
+		return "", err
+	}
+	username, _ := claims["username"].(string)
+	if username == "" {
+		return "", errNotAuthorized
+	}
+	return username, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc/metadata"
+)
+
+// withAuthenticators replaces the authenticator chain for a test and
+// returns a function which restores it.
+func withAuthenticators(chain ...Authenticator) func() {
+	authenticatorsLock.Lock()
+	previous := authenticators
+	authenticators = chain
+	authenticatorsLock.Unlock()
+	return func() {
+		authenticatorsLock.Lock()
+		authenticators = previous
+		authenticatorsLock.Unlock()
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	jwtKey = []byte("test key")
+	sign := func(claims jwt.MapClaims, key []byte) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	request := func(authorization string) *http.Request {
+		r := &http.Request{Header: http.Header{}}
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		return r
+	}
+
+	username, err := jwtAuthenticate(request("Bearer " + sign(jwt.MapClaims{"username": "dev"}, jwtKey)))
+	if err != nil || username != "dev" {
+		t.Fatalf("expected user dev, got %q, %v", username, err)
+	}
+
+	// Other schemes are left to the other authenticators
+	for _, authorization := range []string{"", "Basic ZGV2OmRldg==", "Negotiate token"} {
+		if username, err = jwtAuthenticate(request(authorization)); err != nil || username != "" {
+			t.Fatalf("expected %q to be skipped, got %q, %v", authorization, username, err)
+		}
+	}
+
+	// Invalid tokens are errors
+	expired := jwt.MapClaims{"username": "dev", "exp": time.Now().Add(-time.Hour).Unix()}
+	for _, token := range []string{"invalid", sign(jwt.MapClaims{"username": "dev"}, []byte("other key")), sign(expired, jwtKey), sign(jwt.MapClaims{}, jwtKey)} {
+		if username, err = jwtAuthenticate(request("Bearer " + token)); err == nil {
+			t.Fatalf("expected token %q to be rejected, got %q", token, username)
+		}
+	}
+}
+
+func TestAuthenticatorChain(t *testing.T) {
+	var called []string
+	scheme := func(name, header, username string, err error) Authenticator {
+		return AuthenticatorFunc(func(r *http.Request) (string, error) {
+			called = append(called, name)
+			if r.Header.Get(header) == "" {
+				return "", nil
+			}
+			return username, err
+		})
+	}
+	defer withAuthenticators()()
+	RegisterAuthenticator(scheme("gateway", "X-Gateway-User", "gateway-user", nil))
+	RegisterAuthenticator(scheme("kerberos", "X-Kerberos", "kerberos-user", nil))
+	RegisterAuthenticator(scheme("broken", "X-Broken", "", errors.New("invalid credentials")))
+
+	// Schemes are tried in the order they have been registered
+	r := &http.Request{Header: http.Header{}}
+	r.Header.Set("X-Gateway-User", "1")
+	r.Header.Set("X-Kerberos", "1")
+	username, err := authenticate(r)
+	if err != nil || username != "gateway-user" || len(called) != 1 {
+		t.Fatalf("expected first scheme to authenticate, got %q, %v after %v", username, err, called)
+	}
+
+	// Requests without credentials are not authorized
+	called = nil
+	if _, err = authenticate(&http.Request{Header: http.Header{}}); err != errNotAuthorized || len(called) != 3 {
+		t.Fatalf("expected error %v after all schemes, got %v after %v", errNotAuthorized, err, called)
+	}
+
+	// Errors are not passed on to the other schemes
+	defer withAuthenticators()()
+	RegisterAuthenticator(scheme("broken", "X-Broken", "", errors.New("invalid credentials")))
+	RegisterAuthenticator(scheme("gateway", "X-Gateway-User", "gateway-user", nil))
+	called = nil
+	r.Header.Set("X-Broken", "1")
+	if username, err = authenticate(r); err == nil || username != "" || len(called) != 1 {
+		t.Fatalf("expected error to end the chain, got %q, %v after %v", username, err, called)
+	}
+}
+
+func TestGRPCAuthenticateWithCustomScheme(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestGRPCAuthenticateWithCustomScheme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	initGRPCTest(t, tmp)
+	defer storeService.Close()
+
+	// gRPC metadata keys are lower case, headers are canonicalized
+	defer withAuthenticators(AuthenticatorFunc(jwtAuthenticate))()
+	RegisterAuthenticator(AuthenticatorFunc(func(r *http.Request) (string, error) {
+		return r.Header.Get("X-Gateway-User"), nil
+	}))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-gateway-user", "gateway-user"))
+	ctx, err = grpcAuthenticate(ctx, "/gaia.api.v1.Gaia/ListPipelines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username := ctx.Value(grpcUsernameKey); username != "gateway-user" {
+		t.Fatalf("expected user of the custom scheme, got %v", username)
+	}
+}
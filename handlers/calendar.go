@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// CalendarGetAll returns all maintenance calendars.
+func CalendarGetAll(c echo.Context) error {
+	calendars, err := storeService.CalendarGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, calendars)
+}
+
+// CalendarPut creates or replaces a maintenance calendar.
+func CalendarPut(c echo.Context) error {
+	calendar := &gaia.Calendar{}
+	if err := c.Bind(calendar); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateCalendar(calendar); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := storeService.CalendarPut(calendar); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, calendar)
+}
+
+// CalendarDelete deletes the given maintenance calendar.
+func CalendarDelete(c echo.Context) error {
+	name := c.Param("name")
+	calendar, err := storeService.CalendarGet(name)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if calendar == nil {
+		return c.String(http.StatusNotFound, "calendar not found with the given name")
+	}
+
+	if err = storeService.CalendarDelete(name); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.String(http.StatusOK, "Calendar has been deleted")
+}
+
+// PipelinePutCalendars replaces the maintenance calendars of the given pipeline.
+func PipelinePutCalendars(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	names := []string{}
+	if err := c.Bind(&names); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	for _, name := range names {
+		calendar, err := storeService.CalendarGet(name)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		} else if calendar == nil {
+			return c.String(http.StatusBadRequest, "calendar not found with the given name")
+		}
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Calendars = names
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// errCatalogVariableNotFound is thrown when a catalog variable was not found with the given name
+var errCatalogVariableNotFound = errors.New("catalog variable not found with the given name")
+
+// CatalogGetAll returns all variables of the catalog. Only admins get
+// the values of variables which are restricted to teams.
+func CatalogGetAll(c echo.Context) error {
+	variables, err := storeService.CatalogVariableGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	if !isAdmin(c) {
+		for i := range variables {
+			if len(variables[i].Teams) > 0 {
+				variables[i].Value = ""
+			}
+		}
+	}
+	return c.JSON(http.StatusOK, variables)
+}
+
+// CatalogPut creates or replaces a variable of the catalog. The runs
+// of the pipelines which reference it get the new value from now on.
+// The change is recorded in the audit log.
+func CatalogPut(c echo.Context) error {
+	v := &gaia.CatalogVariable{}
+	if err := c.Bind(v); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateCatalogVariable(v); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	username, _ := c.Get(contextUsernameKey).(string)
+	v.Updated = time.Now()
+	v.UpdatedBy = username
+	if err := storeService.CatalogVariablePut(v); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	// Record change in audit log
+	err := storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditCatalogChange,
+		Target:        v.Name,
+		Message:       v.Value,
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+// CatalogDelete removes the given variable from the catalog. Pipelines
+// which reference it are executed without it. The removal is recorded
+// in the audit log.
+func CatalogDelete(c echo.Context) error {
+	name := c.Param("name")
+	v, err := storeService.CatalogVariableGet(name)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if v == nil {
+		return c.String(http.StatusNotFound, errCatalogVariableNotFound.Error())
+	}
+
+	if err = storeService.CatalogVariableDelete(name); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	// Record removal in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	err = storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditCatalogChange,
+		Target:        name,
+		Message:       "removed",
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.String(http.StatusOK, "Catalog variable has been deleted")
+}
+
+// PipelinePutCatalogVariables replaces the catalog variables which are
+// referenced by the given pipeline.
+func PipelinePutCatalogVariables(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	names := []string{}
+	if err := c.Bind(&names); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+	catalog, err := storeService.CatalogVariableGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if err = scheduler.ValidateCatalogReferences(p, catalog, names); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err = pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.CatalogVariables = names
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+func TestCatalogGetAll(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestCatalogGetAll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	initGRPCTest(t, tmp)
+	defer storeService.Close()
+
+	for _, v := range []gaia.CatalogVariable{{Name: "region", Value: "eu-west-1"}, {Name: "account", Value: "1234", Teams: []string{"payments"}}} {
+		if err = storeService.CatalogVariablePut(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(username string) map[string]string {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(echo.GET, "/", nil), rec)
+		c.Set(contextUsernameKey, username)
+		if err := CatalogGetAll(c); err != nil {
+			t.Fatal(err)
+		}
+		variables := []gaia.CatalogVariable{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &variables); err != nil {
+			t.Fatal(err)
+		}
+		values := map[string]string{}
+		for _, v := range variables {
+			values[v.Name] = v.Value
+		}
+		return values
+	}
+
+	// Values of team variables are only returned to admins
+	if values := get("admin"); values["region"] != "eu-west-1" || values["account"] != "1234" {
+		t.Fatalf("expected all values for admins, got %v", values)
+	}
+	values := get("dev")
+	if values["region"] != "eu-west-1" || values["account"] != "" {
+		t.Fatalf("expected team values to be hidden, got %v", values)
+	}
+	if _, ok := values["account"]; !ok {
+		t.Fatal("expected team variable to be listed")
+	}
+}
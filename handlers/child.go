@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+const (
+	// headerChildToken is the header which holds the token a job
+	// uses to trigger child pipelines.
+	headerChildToken = "X-Child-Token"
+
+	// contextParentRunKey is the key in the request context which holds
+	// the run whose job triggers child pipelines.
+	contextParentRunKey = "parentrun"
+
+	// maxChildWait is the maximum duration a request waits for a child run.
+	maxChildWait = 5 * time.Minute
+
+	// childPollInterval is the interval a waiting request looks up a child run.
+	childPollInterval = time.Second
+)
+
+var (
+	// errInvalidChildToken is thrown when a job sent no or an invalid child token.
+	errInvalidChildToken = errors.New("no or invalid child token provided")
+)
+
+type childStartRequest struct {
+	// Pipeline is the name of the child pipeline.
+	Pipeline string `json:"pipeline"`
+
+	// Params are passed to the jobs of the child pipeline.
+	Params map[string]string `json:"params"`
+}
+
+// childTokenBarrier is the middleware which authenticates jobs by the
+// child token of their run. Replaces authBarrier for child routes.
+func childTokenBarrier(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		parent := schedulerService.ParentRunByToken(c.Request().Header.Get(headerChildToken))
+		if parent == nil {
+			return c.String(http.StatusForbidden, errInvalidChildToken.Error())
+		}
+		c.Set(contextParentRunKey, parent)
+		return next(c)
+	}
+}
+
+// ChildPipelineStart starts the given pipeline as child of the run
+// whose job sent the request.
+func ChildPipelineStart(c echo.Context) error {
+	parent := c.Get(contextParentRunKey).(*scheduler.ParentRun)
+
+	r := &childStartRequest{}
+	if err := c.Bind(r); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p := pipeline.GlobalActivePipelines.GetByName(r.Pipeline)
+	if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	run, err := schedulerService.ScheduleChildPipeline(p, parent, r.Params)
+	if contractErr, ok := err.(*scheduler.ContractError); ok {
+		return errorResponse(c, http.StatusBadRequest, errContractViolated.Error(), contractErr)
+	} else if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, run)
+}
+
+// ChildPipelineRunGet returns the given child run of the run whose job
+// sent the request.
+//
+// Optional parameter wait (e.g. 30s) waits until the child run has been
+// finished. Returns the unfinished run after the given duration.
+func ChildPipelineRunGet(c echo.Context) error {
+	parent := c.Get(contextParentRunKey).(*scheduler.ParentRun)
+
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid pipeline run id given")
+	}
+
+	var wait time.Duration
+	if w := c.QueryParam("wait"); w != "" {
+		if wait, err = time.ParseDuration(w); err != nil || wait < 0 {
+			return c.String(http.StatusBadRequest, "invalid wait duration given")
+		}
+		if wait > maxChildWait {
+			wait = maxChildWait
+		}
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		run, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		if run == nil || run.ParentPipelineID != parent.PipelineID || run.ParentRunID != parent.RunID {
+			return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+		}
+		if isRunFinished(run) || run.Status == gaia.RunCancelled || !time.Now().Before(deadline) {
+			return c.JSON(http.StatusOK, run)
+		}
+
+		select {
+		case <-time.After(childPollInterval):
+		case <-c.Request().Context().Done():
+			return nil
+		}
+	}
+}
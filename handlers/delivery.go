@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+// WebhookDeliveryGetAll returns the webhook deliveries, the latest first.
+//
+// Optional parameters:
+// status - Only deliveries with this status, e.g. dead for the dead-letter list
+// pipelineid - Only deliveries of this pipeline
+func WebhookDeliveryGetAll(c echo.Context) error {
+	status := gaia.WebhookDeliveryStatus(c.QueryParam("status"))
+	pipelineID := 0
+	if id := c.QueryParam("pipelineid"); id != "" {
+		var err error
+		if pipelineID, err = strconv.Atoi(id); err != nil {
+			return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+		}
+	}
+
+	deliveries, err := storeService.WebhookDeliveryGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	selected := []gaia.WebhookDelivery{}
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		d := deliveries[i]
+		if (status == "" || d.Status == status) && (pipelineID == 0 || d.PipelineID == pipelineID) {
+			selected = append(selected, d)
+		}
+	}
+	return c.JSON(http.StatusOK, selected)
+}
+
+// WebhookDeliveryRedeliver queues the given webhook delivery again.
+func WebhookDeliveryRedeliver(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("deliveryid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid webhook delivery id given")
+	}
+
+	d, err := schedulerService.RedeliverWebhook(id)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, d)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// headerCorrelationID is the header which holds the id of a request.
+	// Clients can provide their own id.
+	headerCorrelationID = "X-Correlation-ID"
+
+	// contextCorrelationIDKey is the key in the request context which
+	// holds the correlation id of the request.
+	contextCorrelationIDKey = "correlationid"
+
+	// maxCorrelationIDLength is the maximum length of a correlation id
+	// given by a client.
+	maxCorrelationIDLength = 128
+)
+
+// apiError is the body of all error responses.
+type apiError struct {
+	// Code is the machine-readable error code.
+	Code string `json:"code"`
+
+	// Message is the human-readable error message. It is translated
+	// into the negotiated language.
+	Message string `json:"message"`
+
+	// Details hold additional information about the error.
+	Details interface{} `json:"details,omitempty"`
+
+	// CorrelationID identifies the request in the server logs.
+	CorrelationID string `json:"correlationid"`
+}
+
+// errorCodes holds the codes of the known error messages. Other
+// errors get a code derived from the status code.
+// The key is the original english message.
+var errorCodes = map[string]string{
+	errNotAuthorized.Error():                                   "not_authorized",
+	errNotAdmin.Error():                                        "admin_required",
+	errNetworkDenied.Error():                                   "network_denied",
+	errPathLength.Error():                                      "invalid_pipeline_name",
+	errPipelineNotFound.Error():                                "pipeline_not_found",
+	errInvalidPipelineID.Error():                               "invalid_pipeline_id",
+	errPipelineRunNotFound.Error():                             "pipeline_run_not_found",
+	errLogNotFound.Error():                                     "log_not_found",
+	errBinaryNotFound.Error():                                  "binary_not_found",
+	errRerunBinaryNotFound.Error():                             "rerun_binary_not_found",
+	errPresetNotFound.Error():                                  "preset_not_found",
+	errWaiverNotFound.Error():                                  "waiver_not_found",
+	errInvalidDays.Error():                                     "invalid_days",
+	errInvalidChildToken.Error():                               "invalid_child_token",
+	errCatalogVariableNotFound.Error():                         "catalog_variable_not_found",
+	errInvalidRunToken.Error():                                 "invalid_run_token",
+	errContractViolated.Error():                                "contract_violation",
+	errArtifactNotFound.Error():                                "artifact_not_found",
+	errRecoveryReportNotFound.Error():                          "recovery_report_not_found",
+	"invalid pipeline id given":                                "invalid_pipeline_id",
+	"invalid pipeline run id given":                            "invalid_pipeline_run_id",
+	"invalid worker id given":                                  "invalid_worker_id",
+	"worker not found with the given id":                       "worker_not_found",
+	"invalid username and/or password":                         "invalid_credentials",
+	"Invalid secret key given":                                 "invalid_secret_key",
+	"Invalid username given":                                   "invalid_username",
+	"Cannot find user with the given username":                 "user_not_found",
+	"Wrong password given for password change":                 "wrong_password",
+	"New password does not match new password confirmation":    "password_mismatch",
+	"no canary worker configured":                              "no_canary_worker",
+	"canary run for this pipeline version did not succeed yet": "canary_pending",
+	"pipeline run was not started in debug mode":               "not_debug_run",
+}
+
+// errorContext sends all plain text error responses as structured
+// error envelope.
+type errorContext struct {
+	echo.Context
+}
+
+// String sends the given message as plain text response. Error
+// messages are sent as structured error envelope.
+func (c *errorContext) String(code int, s string) error {
+	if code < http.StatusBadRequest {
+		return c.Context.String(code, s)
+	}
+	return errorResponse(c.Context, code, s, nil)
+}
+
+// structuredErrors is a middleware which assigns a correlation id to
+// every request and sends all error responses as structured error
+// envelope. It must be used after localize.
+func structuredErrors(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Request().Header.Get(headerCorrelationID)
+		if !validCorrelationID(id) {
+			id = uuid.Must(uuid.NewV4(), nil).String()
+		}
+		c.Set(contextCorrelationIDKey, id)
+		c.Response().Header().Set(headerCorrelationID, id)
+
+		return next(&errorContext{Context: c})
+	}
+}
+
+// errorResponse sends the structured error envelope with the given
+// message and details. The error is logged with the correlation id.
+func errorResponse(c echo.Context, code int, msg string, details interface{}) error {
+	id := correlationID(c)
+	errCode, ok := errorCodes[msg]
+	if !ok {
+		errCode = strings.ToLower(strings.Replace(http.StatusText(code), " ", "_", -1))
+	}
+
+	if code >= http.StatusInternalServerError {
+		gaia.Cfg.Logger.Error("request failed", "status", code, "code", errCode, "error", msg, "path", c.Path(), "correlationid", id)
+	} else {
+		gaia.Cfg.Logger.Info("request rejected", "status", code, "code", errCode, "error", msg, "path", c.Path(), "correlationid", id)
+	}
+
+	lang, _ := c.Get(contextLanguageKey).(string)
+	return c.JSON(code, &apiError{
+		Code:          errCode,
+		Message:       translate(lang, msg),
+		Details:       details,
+		CorrelationID: id,
+	})
+}
+
+// httpErrorHandler sends the errors returned by handlers and
+// middlewares (e.g. unknown routes) as structured error envelope.
+func httpErrorHandler(err error, c echo.Context) {
+	code, msg := http.StatusInternalServerError, err.Error()
+	if he, ok := err.(*echo.HTTPError); ok {
+		code = he.Code
+		if m, ok := he.Message.(string); ok {
+			msg = m
+		} else {
+			msg = http.StatusText(code)
+		}
+	}
+
+	if c.Response().Committed {
+		return
+	}
+	if err := errorResponse(c, code, msg, nil); err != nil {
+		gaia.Cfg.Logger.Error("cannot send error response", "error", err.Error())
+	}
+}
+
+// correlationID returns the correlation id of the given request.
+func correlationID(c echo.Context) string {
+	id, _ := c.Get(contextCorrelationIDKey).(string)
+	return id
+}
+
+// validCorrelationID returns true if the given correlation id of a
+// client can be safely used in the logs and headers.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
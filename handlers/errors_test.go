@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/labstack/echo"
+)
+
+func TestContractErrorResponse(t *testing.T) {
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger()}
+	contractErr := &scheduler.ContractError{
+		Pipeline:   "deploy",
+		Contract:   "params",
+		Violations: []scheduler.ContractViolation{{Path: "region", Message: "is required"}},
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/", nil)
+	req.Header.Set(headerCorrelationID, "request-1")
+	rec := httptest.NewRecorder()
+	h := structuredErrors(func(c echo.Context) error {
+		return errorResponse(c, http.StatusBadRequest, errContractViolated.Error(), contractErr)
+	})
+	if err := h(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Violations are sent as details of the error envelope
+	resp := struct {
+		apiError
+		Details scheduler.ContractError `json:"details"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusBadRequest || resp.Code != "contract_violation" || resp.CorrelationID != "request-1" {
+		t.Fatalf("unexpected error envelope %d %+v", rec.Code, resp.apiError)
+	}
+	if len(resp.Details.Violations) != 1 || resp.Details.Violations[0].Path != "region" {
+		t.Fatalf("unexpected details %+v", resp.Details)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+// FaultsGet returns the faults which are currently injected.
+func FaultsGet(c echo.Context) error {
+	return c.JSON(http.StatusOK, schedulerService.Faults())
+}
+
+// FaultsPut replaces the injected faults. Omitted faults keep their
+// current value. Faults are only injected in development mode and are
+// not stored. The change is recorded in the audit log.
+func FaultsPut(c echo.Context) error {
+	faults := schedulerService.Faults()
+	if err := c.Bind(&faults); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := schedulerService.SetFaults(faults); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Record change in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	m, _ := json.Marshal(faults)
+	err := storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditFaultInjection,
+		Message:       string(m),
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, faults)
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	apiv1 "github.com/gaia-pipeline/gaia/api/v1"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// metadataCorrelationID is the metadata key which holds the id of
+	// a gRPC request. Clients can provide their own id.
+	metadataCorrelationID = "x-correlation-id"
+
+	// logPollInterval is the interval followed logs are checked for
+	// new lines.
+	logPollInterval = time.Second
+)
+
+// grpcContextKey is the type of the keys gRPC requests keep their
+// values in the context with.
+type grpcContextKey string
+
+const (
+	grpcUsernameKey      grpcContextKey = contextUsernameKey
+	grpcCorrelationIDKey grpcContextKey = contextCorrelationIDKey
+)
+
+var (
+	// grpcWriteMethods are the methods which change the state. They
+	// are rejected while this instance is a standby.
+	grpcWriteMethods = []string{
+		"/gaia.api.v1.Gaia/CreatePipeline",
+		"/gaia.api.v1.Gaia/DeletePipeline",
+		"/gaia.api.v1.Gaia/StartPipeline",
+	}
+
+	// grpcAdminMethods are the methods which are only allowed for
+	// admins like the admin routes of the REST API.
+	grpcAdminMethods = []string{
+		"/gaia.api.v1.Gaia/DeletePipeline",
+	}
+)
+
+// grpcServer implements the gRPC API. It mirrors the core operations
+// of the REST handlers.
+type grpcServer struct{}
+
+// NewGRPCServer returns the server of the gRPC API. It is served with
+// the certificate of the REST API if one has been configured.
+// The handlers must have been initialized before.
+func NewGRPCServer() (*grpc.Server, error) {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(grpcUnaryBarrier), grpc.StreamInterceptor(grpcStreamBarrier)}
+	if gaia.Cfg.TLSCert != "" {
+		creds, err := credentials.NewServerTLSFromFile(gaia.Cfg.TLSCert, gaia.Cfg.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s := grpc.NewServer(opts...)
+	apiv1.RegisterGaiaServer(s, &grpcServer{})
+	return s, nil
+}
+
+// grpcUnaryBarrier applies the barriers of the REST API to unary calls.
+func grpcUnaryBarrier(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := grpcAuthenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// grpcStreamBarrier applies the barriers of the REST API to streams.
+func grpcStreamBarrier(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := grpcAuthenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// grpcServerStream is a stream with the context of the barriers.
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAuthenticate checks the network, the credentials, the admin rights
+// and the standby state for the given call like the middlewares of the
+// REST API. The metadata is passed to the authenticators as request
+// headers. The returned context holds the user and the correlation id.
+func grpcAuthenticate(ctx context.Context, method string) (context.Context, error) {
+	var ip net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		ip = net.ParseIP(host)
+		if !apiACL.permits(ip) {
+			return nil, status.Error(codes.PermissionDenied, errNetworkDenied.Error())
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	req := &http.Request{Header: http.Header{}}
+	for key, values := range md {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	username, err := authenticate(req)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if isGRPCMethod(grpcAdminMethods, method) {
+		if !adminACL.permits(ip) {
+			return nil, status.Error(codes.PermissionDenied, errNetworkDenied.Error())
+		}
+		user, err := storeService.UserGet(username)
+		if err != nil || user == nil || !user.Admin {
+			return nil, status.Error(codes.PermissionDenied, errNotAdmin.Error())
+		}
+	}
+
+	if pipeline.IsStandby() && isGRPCMethod(grpcWriteMethods, method) {
+		return nil, status.Error(codes.Unavailable, errStandbyReadOnly.Error())
+	}
+
+	id := uuid.Must(uuid.NewV4(), nil).String()
+	if values := md.Get(metadataCorrelationID); len(values) > 0 && validCorrelationID(values[0]) {
+		id = values[0]
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(metadataCorrelationID, id))
+
+	ctx = context.WithValue(ctx, grpcUsernameKey, username)
+	return context.WithValue(ctx, grpcCorrelationIDKey, id), nil
+}
+
+// isGRPCMethod returns true if the given method is one of the given methods.
+func isGRPCMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ListPipelines returns all active pipelines with their latest run.
+func (s *grpcServer) ListPipelines(ctx context.Context, req *apiv1.ListPipelinesRequest) (*apiv1.ListPipelinesResponse, error) {
+	var pipelines []gaia.Pipeline
+	for p := range pipeline.GlobalActivePipelines.Iter() {
+		pipelines = append(pipelines, p)
+	}
+
+	resp := &apiv1.ListPipelinesResponse{}
+	for _, p := range pipelines {
+		run, err := storeService.PipelineGetLatestRun(p.ID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		ap := toAPIPipeline(&p)
+		if run != nil {
+			ap.LatestRun = toAPIRun(run)
+		}
+		resp.Pipelines = append(resp.Pipelines, ap)
+	}
+	return resp, nil
+}
+
+// GetPipeline returns the active pipeline with the given id.
+func (s *grpcServer) GetPipeline(ctx context.Context, req *apiv1.GetPipelineRequest) (*apiv1.Pipeline, error) {
+	p := findActivePipeline(int(req.Id))
+	if p == nil {
+		return nil, status.Error(codes.NotFound, errPipelineNotFound.Error())
+	}
+	return toAPIPipeline(p), nil
+}
+
+// CreatePipeline starts the compilation of a new pipeline.
+func (s *grpcServer) CreatePipeline(ctx context.Context, req *apiv1.CreatePipelineRequest) (*apiv1.CreatePipelineResponse, error) {
+	p := &gaia.CreatePipeline{
+		Pipeline: gaia.Pipeline{
+			Name: req.Name,
+			Type: gaia.PipelineType(req.Type),
+			Repo: gaia.GitRepo{
+				URL:            req.RepoUrl,
+				Username:       req.Username,
+				Password:       req.Password,
+				PrivateKey:     gaia.PrivateKey{Key: req.PrivateKey},
+				SelectedBranch: req.Branch,
+			},
+		},
+	}
+
+	username, _ := ctx.Value(grpcUsernameKey).(string)
+	if err := validateCreatePipeline(p, username); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	correlationID, _ := ctx.Value(grpcCorrelationIDKey).(string)
+	if err := startCreatePipeline(p, correlationID); err != nil {
+		gaia.Cfg.Logger.Debug("cannot put pipeline into store", "error", err.Error())
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &apiv1.CreatePipelineResponse{Id: p.ID}, nil
+}
+
+// DeletePipeline soft deletes the pipeline with the given id.
+func (s *grpcServer) DeletePipeline(ctx context.Context, req *apiv1.GetPipelineRequest) (*apiv1.Pipeline, error) {
+	p, err := pipeline.DeletePipeline(int(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if p == nil {
+		return nil, status.Error(codes.NotFound, errPipelineNotFound.Error())
+	}
+	return toAPIPipeline(p), nil
+}
+
+// StartPipeline schedules a new run of the given pipeline.
+func (s *grpcServer) StartPipeline(ctx context.Context, req *apiv1.StartPipelineRequest) (*apiv1.PipelineRun, error) {
+	p := findActivePipeline(int(req.PipelineId))
+	if p == nil {
+		return nil, status.Error(codes.NotFound, errPipelineNotFound.Error())
+	}
+
+	username, _ := ctx.Value(grpcUsernameKey).(string)
+	correlationID, _ := ctx.Value(grpcCorrelationIDKey).(string)
+	run, err := schedulerService.SchedulePipeline(p, scheduler.ScheduleOptions{
+		Secrets:       req.Secrets,
+		Canary:        req.Canary,
+		Debug:         req.Debug,
+		Jobs:          req.Jobs,
+		Dependencies:  req.Dependencies,
+		Params:        req.Params,
+		User:          username,
+		CorrelationID: correlationID,
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if run == nil {
+		return nil, status.Error(codes.NotFound, errPipelineNotFound.Error())
+	}
+	return toAPIRun(run), nil
+}
+
+// GetPipelineRun returns the given run.
+func (s *grpcServer) GetPipelineRun(ctx context.Context, req *apiv1.GetPipelineRunRequest) (*apiv1.PipelineRun, error) {
+	run, err := getVisibleRun(int(req.PipelineId), int(req.RunId))
+	if err != nil {
+		return nil, err
+	}
+	return toAPIRun(run), nil
+}
+
+// StreamRunLogs streams the job logs of the given run. Jobs are
+// streamed in the order of their priority. If follow is set, the logs
+// are streamed until the run has been finished.
+func (s *grpcServer) StreamRunLogs(req *apiv1.StreamRunLogsRequest, stream apiv1.Gaia_StreamRunLogsServer) error {
+	pipelineID := strconv.FormatInt(req.PipelineId, 10)
+	runID := strconv.FormatInt(req.RunId, 10)
+	offsets := map[uint32]int64{}
+	finished := map[uint32]bool{}
+	for {
+		run, err := getVisibleRun(int(req.PipelineId), int(req.RunId))
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(run.Jobs, func(i, j int) bool {
+			return run.Jobs[i].Priority < run.Jobs[j].Priority
+		})
+		found := false
+		for _, job := range run.Jobs {
+			if req.JobId != 0 && job.ID != req.JobId {
+				continue
+			}
+			found = true
+			if finished[job.ID] {
+				continue
+			}
+
+			// The status is checked first. The log of a finished job
+			// is complete.
+			done := isJobFinished(job.Status)
+			for {
+				jL, err := getLogs(pipelineID, runID, strconv.FormatUint(uint64(job.ID), 10), true, logRange{Offset: offsets[job.ID], Limit: maxLogChunkSize}, true)
+				if err != nil {
+					return status.Error(codes.Internal, err.Error())
+				} else if jL == nil || jL.Log == "" {
+					break
+				}
+				if err = stream.Send(&apiv1.LogChunk{JobId: job.ID, Offset: jL.Offset, Data: []byte(jL.Log)}); err != nil {
+					return err
+				}
+				offsets[job.ID] = jL.Offset + int64(len(jL.Log))
+			}
+			if done {
+				finished[job.ID] = true
+				if err = stream.Send(&apiv1.LogChunk{JobId: job.ID, Offset: offsets[job.ID], Finished: true}); err != nil {
+					return err
+				}
+			}
+		}
+		if req.JobId != 0 && !found {
+			return status.Error(codes.NotFound, "cannot find job with given job id")
+		}
+
+		if !req.Follow || isRunFinished(run) || run.Status == gaia.RunCancelled {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, stream.Context().Err().Error())
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// findActivePipeline returns the active pipeline with the given id.
+// Returns nil if the pipeline does not exist.
+func findActivePipeline(id int) *gaia.Pipeline {
+	var found *gaia.Pipeline
+	for p := range pipeline.GlobalActivePipelines.Iter() {
+		if p.ID == id {
+			p := p
+			found = &p
+		}
+	}
+	return found
+}
+
+// getVisibleRun returns the given run. Runs of deleted pipelines are
+// hidden like by the deletedPipelineBarrier.
+func getVisibleRun(pipelineID, runID int) (*gaia.PipelineRun, error) {
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	} else if p.Deleted {
+		return nil, status.Error(codes.NotFound, errPipelineNotFound.Error())
+	}
+
+	run, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	} else if run == nil {
+		return nil, status.Error(codes.NotFound, errPipelineRunNotFound.Error())
+	}
+	return run, nil
+}
+
+// isJobFinished returns true if a job with the given status has been finished.
+func isJobFinished(s gaia.JobStatus) bool {
+	return s == gaia.JobSuccess || s == gaia.JobFailed || s == gaia.JobHung || s == gaia.JobCancelled
+}
+
+// toAPIPipeline converts the given pipeline to its gRPC message.
+func toAPIPipeline(p *gaia.Pipeline) *apiv1.Pipeline {
+	return &apiv1.Pipeline{
+		Id:      int64(p.ID),
+		Name:    p.Name,
+		Type:    string(p.Type),
+		RepoUrl: p.Repo.URL,
+		Branch:  p.Repo.SelectedBranch,
+		Jobs:    toAPIJobs(p.Jobs),
+		Created: unixTime(p.Created),
+	}
+}
+
+// toAPIRun converts the given run to its gRPC message.
+func toAPIRun(r *gaia.PipelineRun) *apiv1.PipelineRun {
+	return &apiv1.PipelineRun{
+		Id:         int64(r.ID),
+		PipelineId: int64(r.PipelineID),
+		Status:     string(r.Status),
+		StartDate:  unixTime(r.StartDate),
+		FinishDate: unixTime(r.FinishDate),
+		Jobs:       toAPIJobs(r.Jobs),
+		StartedBy:  r.StartedBy,
+		Params:     r.Params,
+	}
+}
+
+// toAPIJobs converts the given jobs to their gRPC messages.
+func toAPIJobs(jobs []gaia.Job) []*apiv1.Job {
+	result := []*apiv1.Job{}
+	for _, j := range jobs {
+		result = append(result, &apiv1.Job{
+			Id:          j.ID,
+			Title:       j.Title,
+			Description: j.Description,
+			Priority:    j.Priority,
+			Status:      string(j.Status),
+		})
+	}
+	return result
+}
+
+// unixTime returns the given time in unix seconds. The zero time is 0.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
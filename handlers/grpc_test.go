@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gaia-pipeline/gaia"
+	apiv1 "github.com/gaia-pipeline/gaia/api/v1"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// initGRPCTest creates a store with an admin and a normal user in the
+// given folder and returns their tokens.
+func initGRPCTest(t *testing.T, tmp string) (string, string) {
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: tmp, DataPath: tmp}
+	gaia.Cfg.Bolt.Mode = 0600
+	storeService = store.NewStore()
+	if err := storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	jwtKey = []byte("test key")
+	apiACL, adminACL = nil, nil
+
+	tokens := []string{}
+	for _, u := range []gaia.User{{Username: "admin", Password: "admin", Admin: true}, {Username: "dev", Password: "dev"}} {
+		if err := storeService.UserPut(&u, true); err != nil {
+			t.Fatal(err)
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"username": u.Username}).SignedString(jwtKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens[0], tokens[1]
+}
+
+func TestGRPCAuthenticate(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestGRPCAuthenticate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	admin, dev := initGRPCTest(t, tmp)
+	defer storeService.Close()
+
+	call := func(token, method, address string) (context.Context, error) {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token, metadataCorrelationID, "request-1"))
+		}
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(address), Port: 4000}})
+		return grpcAuthenticate(ctx, method)
+	}
+
+	// Metadata is mapped to the request headers of the authenticators
+	ctx, err := call(dev, "/gaia.api.v1.Gaia/ListPipelines", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username := ctx.Value(grpcUsernameKey); username != "dev" {
+		t.Fatalf("expected user dev, got %v", username)
+	}
+	if id := ctx.Value(grpcCorrelationIDKey); id != "request-1" {
+		t.Fatalf("expected correlation id of the client, got %v", id)
+	}
+
+	if _, err = call("", "/gaia.api.v1.Gaia/ListPipelines", "10.0.0.1"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated call, got %v", err)
+	}
+	if _, err = call("invalid", "/gaia.api.v1.Gaia/ListPipelines", "10.0.0.1"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected invalid token to be rejected, got %v", err)
+	}
+
+	// Admin methods are only allowed for admins from the admin networks
+	if _, err = call(dev, "/gaia.api.v1.Gaia/DeletePipeline", "10.0.0.1"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected permission denied for normal user, got %v", err)
+	}
+	if _, err = call(admin, "/gaia.api.v1.Gaia/DeletePipeline", "10.0.0.1"); err != nil {
+		t.Fatalf("expected admin to be allowed, got %v", err)
+	}
+	if adminACL, err = parseACL("192.168.0.0/16", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = call(admin, "/gaia.api.v1.Gaia/DeletePipeline", "10.0.0.1"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected admin network to be enforced, got %v", err)
+	}
+	if apiACL, err = parseACL("", "10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = call(dev, "/gaia.api.v1.Gaia/ListPipelines", "10.0.0.1"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected denied network to be rejected, got %v", err)
+	}
+	apiACL, adminACL = nil, nil
+}
+
+func TestGRPCListPipelinesWithTLS(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestGRPCListPipelinesWithTLS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	_, dev := initGRPCTest(t, tmp)
+	defer storeService.Close()
+	certPEM := writeTestCertificate(t, tmp)
+
+	pipeline.GlobalActivePipelines = pipeline.NewActivePipelines()
+	pipeline.GlobalActivePipelines.Append(gaia.Pipeline{ID: 1, Name: "pipeline", Type: gaia.PTypeGolang})
+
+	server, err := NewGRPCServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+
+	// The server only speaks TLS with the configured certificate
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := apiv1.NewGaiaClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.ListPipelines(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+dev), &apiv1.ListPipelinesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Pipelines) != 1 || resp.Pipelines[0].Name != "pipeline" {
+		t.Fatalf("unexpected pipelines %+v", resp.Pipelines)
+	}
+
+	if _, err = client.ListPipelines(ctx, &apiv1.ListPipelinesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated call, got %v", err)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for localhost
+// to the given folder and configures it as certificate of the APIs.
+func writeTestCertificate(t *testing.T, folder string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	gaia.Cfg.TLSCert = filepath.Join(folder, "cert.pem")
+	gaia.Cfg.TLSKey = filepath.Join(folder, "key.pem")
+	if err = ioutil.WriteFile(gaia.Cfg.TLSCert, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(gaia.Cfg.TLSKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPEM
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/GeertJohan/go.rice"
@@ -20,6 +21,10 @@ import (
 const (
 	// apiVersion represents the current API version
 	apiVersion = "v1"
+
+	// childPathPrefix is the prefix of all routes which are used by
+	// jobs to trigger child pipelines.
+	childPathPrefix = "/api/" + apiVersion + "/child/"
 )
 
 var (
@@ -40,6 +45,27 @@ var (
 
 	// errLogNotFound is thrown when a job log file was not found
 	errLogNotFound = errors.New("job log file not found")
+
+	// errBinaryNotFound is thrown when no binary was published with the given checksum
+	errBinaryNotFound = errors.New("no binary published with the given checksum")
+
+	// errRerunBinaryNotFound is thrown when the binary of a run to re-run is no longer published
+	errRerunBinaryNotFound = errors.New("the binary of the pipeline run is no longer published")
+
+	// errInvalidManifestKey is thrown when the stored manifest signing key is corrupt
+	errInvalidManifestKey = errors.New("invalid manifest signing key")
+
+	// errContractViolated is thrown when the params of a run violate the contract of the pipeline
+	errContractViolated = errors.New("the given parameters violate the contract of the pipeline")
+
+	// errNotAdmin is thrown when a user without admin role wants to access an admin resource
+	errNotAdmin = errors.New("you are not authorized. Admin role required")
+)
+
+const (
+	// contextUsernameKey is the key in the request context which holds
+	// the name of the authenticated user.
+	contextUsernameKey = "username"
 )
 
 // storeService is an instance of store.
@@ -64,6 +90,16 @@ func InitHandlers(e *echo.Echo, store *store.Store, scheduler *scheduler.Schedul
 		return err
 	}
 
+	// Parse network ACLs of the endpoint groups
+	if err = initACLs(); err != nil {
+		return err
+	}
+
+	// Load signing key of run manifests
+	if err = initManifestKey(); err != nil {
+		return err
+	}
+
 	// Define prefix
 	p := "/api/" + apiVersion + "/"
 
@@ -84,22 +120,172 @@ func InitHandlers(e *echo.Echo, store *store.Store, scheduler *scheduler.Schedul
 	e.GET(p+"pipeline", PipelineGetAll)
 	e.GET(p+"pipeline/:pipelineid", PipelineGet)
 	e.POST(p+"pipeline/:pipelineid/start", PipelineStart)
+	e.GET(p+"pipeline/:pipelineid/presets", PipelineGetPresets)
+	e.PUT(p+"pipeline/:pipelineid/presets/:name", PipelinePutPreset)
+	e.DELETE(p+"pipeline/:pipelineid/presets/:name", PipelineDeletePreset)
 	e.GET(p+"pipeline/latest", PipelineGetAllWithLatestRun)
+	e.GET(p+"pipeline/deleted", PipelineGetDeleted)
+	e.GET(p+"pipeline/archived", PipelineGetArchived)
+	e.DELETE(p+"pipeline/:pipelineid", PipelineDelete, adminBarrier)
+	e.POST(p+"pipeline/:pipelineid/restore", PipelineRestore, adminBarrier)
+	e.POST(p+"pipeline/:pipelineid/archive", PipelineArchive, adminBarrier)
+	e.POST(p+"pipeline/:pipelineid/unarchive", PipelineUnarchive, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/sandbox", PipelinePutSandbox, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/ssh", PipelinePutSSH, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/wasm", PipelinePutWASMCapabilities, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/shadow", PipelinePutShadow, adminBarrier)
+	e.GET(p+"pipeline/:pipelineid/shadow", PipelineGetShadow)
+	e.POST(p+"pipeline/:pipelineid/shadow/promote", PipelinePromoteShadow, adminBarrier)
+	e.DELETE(p+"pipeline/:pipelineid/shadow", PipelineDeleteShadow, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/smoke", PipelinePutSmokeJob)
+	e.PUT(p+"pipeline/:pipelineid/team", PipelinePutTeam, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/matchers", PipelinePutProblemMatchers)
+	e.PUT(p+"pipeline/:pipelineid/mutex", PipelinePutMutexGroups, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/concurrency", PipelinePutConcurrencyParams)
+	e.PUT(p+"pipeline/:pipelineid/calendars", PipelinePutCalendars, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/gates", PipelinePutGates, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/webhooks", PipelinePutWebhooks, adminBarrier)
+	e.GET(p+"webhook/deliveries", WebhookDeliveryGetAll, adminBarrier)
+	e.POST(p+"webhook/deliveries/:deliveryid/redeliver", WebhookDeliveryRedeliver, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/jira", PipelinePutJira, adminBarrier)
+	e.GET(p+"pipeline/:pipelineid/variables", PipelineGetVariables)
+	e.PUT(p+"pipeline/:pipelineid/variables", PipelinePutVariables)
+	e.GET(p+"pipeline/:pipelineid/runbook", PipelineGetRunbook)
+	e.PUT(p+"pipeline/:pipelineid/runbook", PipelinePutRunbook)
+	e.GET(p+"pipeline/:pipelineid/schedules", PipelineGetSchedules)
+	e.GET(p+"pipeline/:pipelineid/builds", PipelineGetBuilds)
+	e.PUT(p+"pipeline/:pipelineid/schedules", PipelinePutSchedules)
+	e.POST(p+"pipeline/:pipelineid/resume", PipelineResume)
+	e.GET(p+"pipeline/:pipelineid/lint", PipelineLint)
+	e.GET(p+"pipeline/:pipelineid/metrics", PipelineGetMetrics)
+	e.GET(p+"pipeline/:pipelineid/dora", PipelineGetDORAMetrics)
+	e.GET(p+"pipeline/:pipelineid/queuewait", PipelineGetQueueWaitTimes)
 
 	// PipelineRun
-	e.GET(p+"pipelinerun/:pipelineid/:runid", PipelineRunGet)
-	e.GET(p+"pipelinerun/:pipelineid", PipelineGetAllRuns)
-	e.GET(p+"pipelinerun/:pipelineid/latest", PipelineGetLatestRun)
-	e.GET(p+"pipelinerun/:pipelineid/:runid/log", GetJobLogs)
+	e.GET(p+"pipelinerun/:pipelineid/:runid", PipelineRunGet, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid", PipelineGetAllRuns, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/latest", PipelineGetLatestRun, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/log", GetJobLogs, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/log/:jobid", GetJobLogRaw, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/log/:jobid/entries", GetJobLogEntries, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/debug", PipelineRunGetDebugLog, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/timeline", PipelineRunGetTimeline, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/telemetry", PipelineRunGetTelemetry, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/manifest", PipelineRunGetManifest, deletedPipelineBarrier)
+	e.GET(p+"manifest/publickey", ManifestGetPublicKey)
+	e.POST(p+"pipelinerun/:pipelineid/:runid/rerun", PipelineRunRerun, deletedPipelineBarrier)
+	e.POST(p+"pipelinerun/:pipelineid/:runid/cancel", PipelineRunCancel, deletedPipelineBarrier)
+	e.POST(p+"pipelinerun/:pipelineid/:runid/boost", PipelineRunBoost, adminBarrier, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/archive", PipelineRunGetArchive, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/:runid/artifacts/:name", PipelineRunGetArtifact, deletedPipelineBarrier)
+	e.GET(p+"pipelinerun/:pipelineid/archive", PipelineGetRunsArchive, deletedPipelineBarrier)
+
+	// Recovery
+	e.GET(p+"recovery", RecoveryGetReport, adminBarrier)
+	e.POST(p+"recovery/repair", RecoveryRepair, adminBarrier)
+
+	// Vault
+	e.GET(p+"vault", VaultGetKeys, adminBarrier)
+	e.POST(p+"vault", VaultPutSecret, adminBarrier)
+	e.DELETE(p+"vault/:key", VaultDeleteSecret, adminBarrier)
+	e.POST(p+"vault/rotate", VaultRotateKey, adminBarrier)
+	e.GET(p+"pipeline/:pipelineid/secrets", PipelineGetSecretGrants, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/secrets", PipelinePutSecretGrants, adminBarrier)
+
+	// Audit log
+	e.GET(p+"audit", AuditGetAll, adminBarrier)
+
+	// Usage
+	e.GET(p+"usage", UsageGet, adminBarrier)
+	e.GET(p+"metrics/dora", DORAMetricsGet)
+	e.GET(p+"queue", QueueGet)
+	e.GET(p+"queue/wait", QueueWaitTimesGet)
+	e.GET(p+"queue/builds", BuildQueueGet)
+
+	// Simulation
+	e.GET(p+"simulation", SimulationGet, adminBarrier)
+
+	// Settings
+	e.GET(p+"settings", SettingsGet, adminBarrier)
+	e.PUT(p+"settings", SettingsPut, adminBarrier)
+
+	// Fault injection is never exposed in production mode
+	if gaia.Cfg.DevMode {
+		e.GET(p+"faults", FaultsGet, adminBarrier)
+		e.PUT(p+"faults", FaultsPut, adminBarrier)
+	}
+
+	// Global variables
+	e.GET(p+"variables", VariablesGet)
+	e.PUT(p+"variables", VariablesPut, adminBarrier)
+
+	// Variable catalog
+	e.GET(p+"catalog", CatalogGetAll)
+	e.POST(p+"catalog", CatalogPut, adminBarrier)
+	e.DELETE(p+"catalog/:name", CatalogDelete, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/catalog", PipelinePutCatalogVariables)
+
+	// Worker
+	e.GET(p+"worker", WorkerGetAll)
+	e.GET(p+"worker/:workerid", WorkerGet)
+
+	// Mutex groups
+	e.GET(p+"mutex", MutexGetAll)
+
+	// Maintenance calendars
+	e.GET(p+"calendar", CalendarGetAll)
+	e.POST(p+"calendar", CalendarPut, adminBarrier)
+	e.DELETE(p+"calendar/:name", CalendarDelete, adminBarrier)
+
+	// Shared job libraries
+	e.GET(p+"library", LibraryGetAll)
+	e.POST(p+"library", LibraryPublish, adminBarrier)
+	e.DELETE(p+"library", LibraryDelete, adminBarrier)
+	e.PUT(p+"pipeline/:pipelineid/libraries", PipelinePutLibraries, adminBarrier)
+
+	// Dependency policy
+	e.GET(p+"policy", PolicyGet)
+	e.PUT(p+"policy", PolicyPut, adminBarrier)
+	e.POST(p+"policy/waiver", PolicyWaiverRequest)
+	e.POST(p+"policy/waiver/:id/approve", PolicyWaiverApprove, adminBarrier)
+	e.DELETE(p+"policy/waiver/:id", PolicyWaiverDelete, adminBarrier)
+
+	// Pipeline templates
+	e.GET(p+"template", TemplateGetAll)
+	e.GET(p+"registry", RegistryGetAll)
+	e.GET(p+"registry/:checksum", RegistryGetBinary)
+	e.POST(p+"template/import", TemplateImport, adminBarrier)
+
+	// Child pipelines triggered by jobs
+	e.POST(childPathPrefix+"pipeline", ChildPipelineStart, childTokenBarrier)
+	e.GET(childPathPrefix+"pipelinerun/:pipelineid/:runid", ChildPipelineRunGet, childTokenBarrier)
+
+	// Reports of jobs on behalf of their run
+	e.PUT(jobPathPrefix+"artifacts/:name", JobArtifactUpload, runTokenBarrier)
+	e.PUT(jobPathPrefix+"outputs", JobOutputsPut, runTokenBarrier)
+	e.POST(jobPathPrefix+"annotations", JobAnnotationAdd, runTokenBarrier)
+
+	// Replication by standby instances
+	e.GET(replicationPathPrefix+"snapshot", ReplicationGetSnapshot, replicationTokenBarrier)
+	e.GET(replicationPathPrefix+"binary/:pipelineid", ReplicationGetBinary, replicationTokenBarrier)
+	e.GET(replicationPathPrefix+"file", ReplicationGetFile, replicationTokenBarrier)
+	e.GET(replicationPathPrefix+"registry/:checksum", RegistryGetBinary, replicationTokenBarrier)
+	e.GET(p+"standby", StandbyGet, adminBarrier)
+	e.POST(p+"standby/promote", StandbyPromote, adminBarrier)
 
 	// Middleware
 	e.Use(middleware.Recover())
 	//e.Use(middleware.Logger())
 	e.Use(middleware.BodyLimit("32M"))
+	e.Use(localize)
+	e.Use(structuredErrors)
+	e.Use(networkBarrier)
 	e.Use(authBarrier)
+	e.Use(standbyBarrier)
 
 	// Extra options
 	e.HideBanner = true
+	e.HTTPErrorHandler = httpErrorHandler
 
 	// Are we in production mode?
 	if !gaia.Cfg.DevMode {
@@ -123,7 +309,8 @@ func InitHandlers(e *echo.Echo, store *store.Store, scheduler *scheduler.Schedul
 }
 
 // authBarrier is the middleware which prevents user exploits.
-// It makes sure that the request contains a valid jwt token.
+// It makes sure that the request is authenticated by one of the
+// registered authenticators.
 // TODO: Role based access
 func authBarrier(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -132,33 +319,98 @@ func authBarrier(next echo.HandlerFunc) echo.HandlerFunc {
 			return next(c)
 		}
 
-		// Get JWT token
-		jwtRaw := c.Request().Header.Get("Authorization")
-		split := strings.Split(jwtRaw, " ")
-		if len(split) != 2 {
-			return c.String(http.StatusForbidden, errNotAuthorized.Error())
+		// Jobs authenticate with their child or run token and standby
+		// instances with the replication token
+		if strings.HasPrefix(c.Path(), childPathPrefix) || strings.HasPrefix(c.Path(), jobPathPrefix) || strings.HasPrefix(c.Path(), replicationPathPrefix) {
+			return next(c)
 		}
-		jwtString := split[1]
-
-		// Parse token
-		token, err := jwt.Parse(jwtString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// return secret
-			return jwtKey, nil
-		})
+
+		// Pass request through the authenticator chain
+		username, err := authenticate(c.Request())
 		if err != nil {
 			return c.String(http.StatusForbidden, err.Error())
 		}
 
-		// Validate token
-		if _, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// All ok, continue
-			return next(c)
+		// All ok, remember the user and continue
+		c.Set(contextUsernameKey, username)
+		return next(c)
+	}
+}
+
+// validateToken parses the jwt token of the given authorization header
+// and returns its claims.
+func validateToken(authorization string) (jwt.MapClaims, error) {
+	split := strings.Split(authorization, " ")
+	if len(split) != 2 {
+		return nil, errNotAuthorized
+	}
+	jwtString := split[1]
+
+	// Parse token
+	token, err := jwt.Parse(jwtString, func(token *jwt.Token) (interface{}, error) {
+		// Validate signing method
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		// return secret
+		return jwtKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate token
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, errNotAuthorized
+}
+
+// adminBarrier is the middleware which protects admin resources.
+// It must be used after authBarrier. Admin resources are only
+// reachable from the networks of the admin ACL.
+func adminBarrier(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if ip := clientIP(c); !adminACL.permits(ip) {
+			return errorResponse(c, http.StatusForbidden, errNetworkDenied.Error(), networkDeniedDetails{Address: ip.String()})
 		}
-		return c.String(http.StatusForbidden, errNotAuthorized.Error())
+		if !isAdmin(c) {
+			return c.String(http.StatusForbidden, errNotAdmin.Error())
+		}
+		return next(c)
+	}
+}
+
+// deletedPipelineBarrier is the middleware which hides the runs of deleted pipelines.
+func deletedPipelineBarrier(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+		if err != nil {
+			return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+		}
+
+		p, err := storeService.PipelineGet(pipelineID)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		} else if p.Deleted {
+			return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+		}
+
+		return next(c)
+	}
+}
+
+// isAdmin checks if the authenticated user of the request has the admin role.
+func isAdmin(c echo.Context) bool {
+	username, ok := c.Get(contextUsernameKey).(string)
+	if !ok {
+		return false
+	}
+
+	user, err := storeService.UserGet(username)
+	if err != nil || user == nil {
+		return false
 	}
+	return user.Admin
 }
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	// defaultLanguage is the language of all messages in the code.
+	defaultLanguage = "en"
+
+	headerAcceptLanguage  = "Accept-Language"
+	headerContentLanguage = "Content-Language"
+
+	// contextLanguageKey is the key in the request context which holds
+	// the negotiated language.
+	contextLanguageKey = "language"
+)
+
+// translations holds the translated user-facing messages by language.
+// The key is the original english message. The catalog covers the
+// messages of the handlers and the known error messages. Errors of
+// other packages which are passed through are sent untranslated.
+// New messages must be added to every language.
+var translations = map[string]map[string]string{
+	"de": {
+		// Errors
+		"no or invalid jwt token provided. You are not authorized":                           "Kein oder ungültiges JWT-Token angegeben. Sie sind nicht autorisiert",
+		"you are not authorized. Admin role required":                                        "Sie sind nicht autorisiert. Administratorrolle erforderlich",
+		"name of pipeline is empty or one of the path elements length exceeds 50 characters": "Der Name der Pipeline ist leer oder ein Pfadelement ist länger als 50 Zeichen",
+		"pipeline not found with the given id":                                               "Keine Pipeline mit der angegebenen ID gefunden",
+		"the given pipeline id is not valid":                                                 "Die angegebene Pipeline-ID ist ungültig",
+		"pipeline run not found with the given id":                                           "Kein Pipeline-Lauf mit der angegebenen ID gefunden",
+		"job log file not found":                                                             "Log-Datei des Jobs nicht gefunden",
+		"invalid month given. Expected format is YYYY-MM":                                    "Ungültiger Monat angegeben. Erwartetes Format ist JJJJ-MM",
+		"invalid groupby given. Must be pipeline, team or worker":                            "Ungültige Gruppierung angegeben. Erlaubt sind pipeline, team oder worker",
+		"invalid username and/or password":                                                   "Ungültiger Benutzername und/oder Passwort",
+		"invalid pipeline id given":                                                          "Ungültige Pipeline-ID angegeben",
+		"invalid pipeline run id given":                                                      "Ungültige Pipeline-Lauf-ID angegeben",
+		"invalid worker id given":                                                            "Ungültige Worker-ID angegeben",
+		"worker not found with the given id":                                                 "Kein Worker mit der angegebenen ID gefunden",
+		"cannot find pipeline run with given pipeline id and pipeline run id":                "Kein Pipeline-Lauf mit der angegebenen Pipeline-ID und Lauf-ID gefunden",
+		"cannot find job with given job id":                                                  "Kein Job mit der angegebenen Job-ID gefunden",
+		"pipeline run was not started in debug mode":                                         "Der Pipeline-Lauf wurde nicht im Debug-Modus gestartet",
+		"no canary worker configured":                                                        "Kein Canary-Worker konfiguriert",
+		"canary run for this pipeline version did not succeed yet":                           "Der Canary-Lauf dieser Pipeline-Version war noch nicht erfolgreich",
+		"invalid action for stuck runs given":                                                "Ungültige Aktion für hängende Läufe angegeben",
+		"no recovery report has been created during startup":                                 "Beim Start wurde kein Wiederherstellungsbericht erstellt",
+		"Invalid secret key given":                                                           "Ungültiger Schlüssel für das Geheimnis angegeben",
+		"Invalid username given":                                                             "Ungültiger Benutzername angegeben",
+		"Invalid parameters given for password change request":                               "Ungültige Parameter für die Passwortänderung angegeben",
+		"Invalid parameters given for add user request":                                      "Ungültige Parameter für das Anlegen des Benutzers angegeben",
+		"Cannot find user with the given username":                                           "Kein Benutzer mit dem angegebenen Benutzernamen gefunden",
+		"Wrong password given for password change":                                           "Falsches Passwort für die Passwortänderung angegeben",
+		"New password does not match new password confirmation":                              "Das neue Passwort stimmt nicht mit der Bestätigung überein",
+		"Cannot update user in store":                                                        "Der Benutzer kann nicht gespeichert werden",
+		"smoke job is not a job of the pipeline":                                             "Der Smoke-Job ist kein Job der Pipeline",
+		"invalid number of days given":                                                       "Ungültige Anzahl an Tagen angegeben",
+		"catalog variable not found with the given name":                                     "Keine Katalogvariable mit dem angegebenen Namen gefunden",
+		"replication is disabled":                                                            "Die Replikation ist deaktiviert",
+		"no or invalid replication token provided":                                           "Kein oder ungültiges Replikationstoken angegeben",
+		"instance is a read-only standby. Promote it to make changes":                        "Die Instanz ist ein schreibgeschützter Standby. Befördern Sie sie, um Änderungen vorzunehmen",
+		"waiver not found with the given id":                                                 "Keine Ausnahme mit der angegebenen ID gefunden",
+		"no binary published with the given checksum":                                        "Keine Binärdatei mit der angegebenen Prüfsumme veröffentlicht",
+		"the binary of the pipeline run is no longer published":                              "Die Binärdatei des Pipeline-Laufs ist nicht mehr veröffentlicht",
+		"invalid manifest signing key":                                                       "Ungültiger Signaturschlüssel für das Manifest",
+		"the given parameters violate the contract of the pipeline":                          "Die angegebenen Parameter verletzen den Vertrag der Pipeline",
+		"no or invalid child token provided":                                                 "Kein oder ungültiges Kind-Token angegeben",
+		"no or invalid run token provided":                                                   "Kein oder ungültiges Lauf-Token angegeben",
+		"artifact not found with the given name":                                             "Kein Artefakt mit dem angegebenen Namen gefunden",
+		"access from your network is not allowed":                                            "Der Zugriff aus Ihrem Netzwerk ist nicht erlaubt",
+		"preset not found with the given name":                                               "Keine Vorlage mit dem angegebenen Namen gefunden",
+		"calendar not found with the given name":                                             "Kein Kalender mit dem angegebenen Namen gefunden",
+		"invalid from date given. Expected format is YYYY-MM-DD":                             "Ungültiges Startdatum angegeben. Erwartetes Format ist JJJJ-MM-TT",
+		"invalid to date given. Expected format is YYYY-MM-DD":                               "Ungültiges Enddatum angegeben. Erwartetes Format ist JJJJ-MM-TT",
+		"invalid log level given":                                                            "Ungültige Log-Stufe angegeben",
+		"invalid number of workers given":                                                    "Ungültige Anzahl an Workern angegeben",
+		"invalid wait duration given":                                                        "Ungültige Wartezeit angegeben",
+		"invalid webhook delivery id given":                                                  "Ungültige Webhook-Zustellungs-ID angegeben",
+		"only failed pipeline runs can be re-run":                                            "Nur fehlgeschlagene Pipeline-Läufe können wiederholt werden",
+		"pipeline has no shadow run":                                                         "Die Pipeline hat keinen Schattenlauf",
+		"preset name must not be empty":                                                      "Der Name der Vorlage darf nicht leer sein",
+
+		// Status
+		"User has been added":               "Benutzer wurde angelegt",
+		"User has been deleted":             "Benutzer wurde gelöscht",
+		"Password has been changed":         "Passwort wurde geändert",
+		"Secret has been stored":            "Geheimnis wurde gespeichert",
+		"Secret has been deleted":           "Geheimnis wurde gelöscht",
+		"Vault key has been rotated":        "Tresorschlüssel wurde rotiert",
+		"Annotation has been added":         "Anmerkung wurde hinzugefügt",
+		"Artifact has been stored":          "Artefakt wurde gespeichert",
+		"Calendar has been deleted":         "Kalender wurde gelöscht",
+		"Catalog variable has been deleted": "Katalogvariable wurde gelöscht",
+		"Library has been deleted":          "Bibliothek wurde gelöscht",
+		"Outputs have been set":             "Ausgaben wurden gesetzt",
+		"Preset has been deleted":           "Vorlage wurde gelöscht",
+		"Waiver has been deleted":           "Ausnahme wurde gelöscht",
+	},
+	"fr": {
+		// Errors
+		"no or invalid jwt token provided. You are not authorized":                           "Jeton JWT absent ou invalide. Vous n'êtes pas autorisé",
+		"you are not authorized. Admin role required":                                        "Vous n'êtes pas autorisé. Rôle administrateur requis",
+		"name of pipeline is empty or one of the path elements length exceeds 50 characters": "Le nom du pipeline est vide ou un élément du chemin dépasse 50 caractères",
+		"pipeline not found with the given id":                                               "Aucun pipeline trouvé avec l'identifiant donné",
+		"the given pipeline id is not valid":                                                 "L'identifiant de pipeline donné n'est pas valide",
+		"pipeline run not found with the given id":                                           "Aucune exécution de pipeline trouvée avec l'identifiant donné",
+		"job log file not found":                                                             "Fichier journal du job introuvable",
+		"invalid month given. Expected format is YYYY-MM":                                    "Mois invalide. Le format attendu est AAAA-MM",
+		"invalid groupby given. Must be pipeline, team or worker":                            "Regroupement invalide. Valeurs possibles : pipeline, team ou worker",
+		"invalid username and/or password":                                                   "Nom d'utilisateur et/ou mot de passe invalide",
+		"invalid pipeline id given":                                                          "Identifiant de pipeline invalide",
+		"invalid pipeline run id given":                                                      "Identifiant d'exécution de pipeline invalide",
+		"invalid worker id given":                                                            "Identifiant de worker invalide",
+		"worker not found with the given id":                                                 "Aucun worker trouvé avec l'identifiant donné",
+		"cannot find pipeline run with given pipeline id and pipeline run id":                "Aucune exécution trouvée pour l'identifiant de pipeline et d'exécution donnés",
+		"cannot find job with given job id":                                                  "Aucun job trouvé avec l'identifiant donné",
+		"pipeline run was not started in debug mode":                                         "L'exécution du pipeline n'a pas été lancée en mode debug",
+		"no canary worker configured":                                                        "Aucun worker canary configuré",
+		"canary run for this pipeline version did not succeed yet":                           "L'exécution canary de cette version du pipeline n'a pas encore réussi",
+		"invalid action for stuck runs given":                                                "Action invalide pour les exécutions bloquées",
+		"no recovery report has been created during startup":                                 "Aucun rapport de récupération n'a été créé au démarrage",
+		"Invalid secret key given":                                                           "Clé de secret invalide",
+		"Invalid username given":                                                             "Nom d'utilisateur invalide",
+		"Invalid parameters given for password change request":                               "Paramètres invalides pour le changement de mot de passe",
+		"Invalid parameters given for add user request":                                      "Paramètres invalides pour l'ajout de l'utilisateur",
+		"Cannot find user with the given username":                                           "Aucun utilisateur trouvé avec ce nom",
+		"Wrong password given for password change":                                           "Mot de passe incorrect pour le changement de mot de passe",
+		"New password does not match new password confirmation":                              "Le nouveau mot de passe ne correspond pas à la confirmation",
+		"Cannot update user in store":                                                        "Impossible d'enregistrer l'utilisateur",
+		"smoke job is not a job of the pipeline":                                             "Le job smoke n'est pas un job du pipeline",
+		"invalid number of days given":                                                       "Nombre de jours invalide",
+		"catalog variable not found with the given name":                                     "Aucune variable du catalogue trouvée avec ce nom",
+		"replication is disabled":                                                            "La réplication est désactivée",
+		"no or invalid replication token provided":                                           "Jeton de réplication absent ou invalide",
+		"instance is a read-only standby. Promote it to make changes":                        "L'instance est un standby en lecture seule. Promouvez-la pour effectuer des modifications",
+		"waiver not found with the given id":                                                 "Aucune dérogation trouvée avec l'identifiant donné",
+		"no binary published with the given checksum":                                        "Aucun binaire publié avec cette somme de contrôle",
+		"the binary of the pipeline run is no longer published":                              "Le binaire de l'exécution du pipeline n'est plus publié",
+		"invalid manifest signing key":                                                       "Clé de signature du manifeste invalide",
+		"the given parameters violate the contract of the pipeline":                          "Les paramètres donnés violent le contrat du pipeline",
+		"no or invalid child token provided":                                                 "Jeton enfant absent ou invalide",
+		"no or invalid run token provided":                                                   "Jeton d'exécution absent ou invalide",
+		"artifact not found with the given name":                                             "Aucun artefact trouvé avec ce nom",
+		"access from your network is not allowed":                                            "L'accès depuis votre réseau n'est pas autorisé",
+		"preset not found with the given name":                                               "Aucun préréglage trouvé avec ce nom",
+		"calendar not found with the given name":                                             "Aucun calendrier trouvé avec ce nom",
+		"invalid from date given. Expected format is YYYY-MM-DD":                             "Date de début invalide. Le format attendu est AAAA-MM-JJ",
+		"invalid to date given. Expected format is YYYY-MM-DD":                               "Date de fin invalide. Le format attendu est AAAA-MM-JJ",
+		"invalid log level given":                                                            "Niveau de journalisation invalide",
+		"invalid number of workers given":                                                    "Nombre de workers invalide",
+		"invalid wait duration given":                                                        "Durée d'attente invalide",
+		"invalid webhook delivery id given":                                                  "Identifiant de livraison de webhook invalide",
+		"only failed pipeline runs can be re-run":                                            "Seules les exécutions de pipeline échouées peuvent être relancées",
+		"pipeline has no shadow run":                                                         "Le pipeline n'a pas d'exécution fantôme",
+		"preset name must not be empty":                                                      "Le nom du préréglage ne doit pas être vide",
+
+		// Status
+		"User has been added":               "L'utilisateur a été ajouté",
+		"User has been deleted":             "L'utilisateur a été supprimé",
+		"Password has been changed":         "Le mot de passe a été changé",
+		"Secret has been stored":            "Le secret a été enregistré",
+		"Secret has been deleted":           "Le secret a été supprimé",
+		"Vault key has been rotated":        "La clé du coffre a été renouvelée",
+		"Annotation has been added":         "L'annotation a été ajoutée",
+		"Artifact has been stored":          "L'artefact a été enregistré",
+		"Calendar has been deleted":         "Le calendrier a été supprimé",
+		"Catalog variable has been deleted": "La variable du catalogue a été supprimée",
+		"Library has been deleted":          "La bibliothèque a été supprimée",
+		"Outputs have been set":             "Les sorties ont été définies",
+		"Preset has been deleted":           "Le préréglage a été supprimé",
+		"Waiver has been deleted":           "La dérogation a été supprimée",
+	},
+}
+
+// localizedContext translates all plain text responses into
+// the negotiated language.
+type localizedContext struct {
+	echo.Context
+	language string
+}
+
+// String translates the given message if a translation exists
+// and sends it as plain text response.
+func (c *localizedContext) String(code int, s string) error {
+	return c.Context.String(code, translate(c.language, s))
+}
+
+// localize is a middleware which negotiates the language of the
+// user-facing messages via the Accept-Language header.
+func localize(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Add(echo.HeaderVary, headerAcceptLanguage)
+		lang := negotiateLanguage(c.Request().Header.Get(headerAcceptLanguage))
+		c.Set(contextLanguageKey, lang)
+		if lang == defaultLanguage {
+			return next(c)
+		}
+
+		c.Response().Header().Set(headerContentLanguage, lang)
+		return next(&localizedContext{Context: c, language: lang})
+	}
+}
+
+// translate returns the translation of the given message.
+// If no translation exists, the message is returned unchanged.
+func translate(lang, msg string) string {
+	if t, ok := translations[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// negotiateLanguage returns the supported language with the
+// highest quality from the given Accept-Language header.
+func negotiateLanguage(header string) string {
+	type languageQuality struct {
+		language string
+		quality  float64
+	}
+
+	languages := []languageQuality{}
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		l := languageQuality{quality: 1}
+		if i := strings.Index(part, ";"); i != -1 {
+			params := strings.TrimSpace(part[i+1:])
+			part = strings.TrimSpace(part[:i])
+			if strings.HasPrefix(params, "q=") {
+				q, err := strconv.ParseFloat(params[2:], 64)
+				if err != nil {
+					continue
+				}
+				l.quality = q
+			}
+		}
+
+		// We only differ between primary languages (e.g. de-CH is de)
+		l.language = strings.ToLower(strings.SplitN(part, "-", 2)[0])
+		if l.quality > 0 {
+			languages = append(languages, l)
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+	for _, l := range languages {
+		if l.language == defaultLanguage {
+			return defaultLanguage
+		}
+		if _, ok := translations[l.language]; ok {
+			return l.language
+		}
+	}
+
+	return defaultLanguage
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import "testing"
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"fr-FR", "fr"},
+		{"de-CH, fr;q=0.8", "de"},
+		{"DE-at", "de"},
+		{"it, fr;q=0.5", "fr"},
+		{"fr;q=0.5, de;q=0.9", "de"},
+		{"de;q=0.5, en;q=0.9", "en"},
+		{"de;q=0, fr;q=0.1", "fr"},
+		{"de;q=invalid, fr;q=0.1", "fr"},
+		{"it, es", "en"},
+		{"*", "en"},
+		{" , de ,", "de"},
+	}
+	for _, test := range tests {
+		if lang := negotiateLanguage(test.header); lang != test.expected {
+			t.Fatalf("expected language %s for %q, got %s", test.expected, test.header, lang)
+		}
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	if msg := translate("de", errPipelineNotFound.Error()); msg == errPipelineNotFound.Error() {
+		t.Fatal("expected translated message")
+	}
+	if msg := translate("de", "unknown message"); msg != "unknown message" {
+		t.Fatalf("expected untranslated message, got %s", msg)
+	}
+
+	// Every known error and every message of one language is translated
+	// into all languages
+	for lang, catalog := range translations {
+		for msg := range errorCodes {
+			if _, ok := catalog[msg]; !ok {
+				t.Fatalf("missing %s translation of %q", lang, msg)
+			}
+		}
+		for other, otherCatalog := range translations {
+			for msg := range otherCatalog {
+				if _, ok := catalog[msg]; !ok {
+					t.Fatalf("message %q is translated into %s but not into %s", msg, other, lang)
+				}
+			}
+		}
+	}
+}
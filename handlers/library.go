@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+const (
+	// libraryFormFile is the field of a publish request which holds
+	// the tar.gz archive of the library.
+	libraryFormFile = "file"
+)
+
+// LibraryGetAll returns all published versions of the shared job libraries.
+func LibraryGetAll(c echo.Context) error {
+	libraries, err := pipeline.GetLibraries()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, libraries)
+}
+
+// LibraryPublish publishes a new version of a shared job library.
+// The multipart body holds the import path in the name field, the
+// version in the version field and the tar.gz archive of the package
+// folder in the file field.
+func LibraryPublish(c echo.Context) error {
+	file, err := c.FormFile(libraryFormFile)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	archive, err := file.Open()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	defer archive.Close()
+
+	l, err := pipeline.PublishLibrary(c.FormValue("name"), c.FormValue("version"), archive)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, l)
+}
+
+// LibraryDelete deletes the version of a shared job library given by
+// the name and version parameters.
+func LibraryDelete(c echo.Context) error {
+	if err := pipeline.DeleteLibrary(c.QueryParam("name"), c.QueryParam("version")); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.String(http.StatusOK, "Library has been deleted")
+}
+
+// PipelinePutLibraries replaces the library pins of the given pipeline.
+// The body maps import paths to published versions. Libraries which are
+// not pinned are built in their latest version. Pins take effect with
+// the next build of the pipeline.
+func PipelinePutLibraries(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	pins := map[string]string{}
+	if err := c.Bind(&pins); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := pipeline.ValidateLibraryPins(pins); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if len(pins) == 0 {
+		pins = nil
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Libraries = pins
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
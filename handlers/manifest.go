@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// manifestKeyFile is the file in the data folder which held the
+	// seed of the signing key before it has been moved to the store.
+	manifestKeyFile = "manifest.key"
+
+	// manifestAlgorithm is the algorithm run manifests are signed with.
+	manifestAlgorithm = "ed25519"
+)
+
+// runManifest holds everything which is needed to reproduce a run.
+type runManifest struct {
+	PipelineID   int               `json:"pipelineid"`
+	PipelineName string            `json:"pipelinename"`
+	PipelineType gaia.PipelineType `json:"pipelinetype"`
+	Repo         string            `json:"repo"`
+	Branch       string            `json:"branch,omitempty"`
+	RunID        int               `json:"runid"`
+	UniqueID     string            `json:"uniqueid"`
+	Status       string            `json:"status"`
+	Commit       string            `json:"commit,omitempty"`
+	Checksum     string            `json:"checksum,omitempty"`
+	Platform     string            `json:"platform,omitempty"`
+	SSHChecksum  string            `json:"sshchecksum,omitempty"`
+	SSHPlatform  string            `json:"sshplatform,omitempty"`
+	Environment  string            `json:"environment,omitempty"`
+	Params       map[string]string `json:"params,omitempty"`
+	Jobs         []uint32          `json:"jobs,omitempty"`
+	Secrets      []string          `json:"secrets,omitempty"`
+	StartedBy    string            `json:"startedby,omitempty"`
+	StartDate    time.Time         `json:"startdate,omitempty"`
+	FinishDate   time.Time         `json:"finishdate,omitempty"`
+	Exported     time.Time         `json:"exported"`
+}
+
+// signedManifest is a manifest with its signature. The manifest is
+// kept as JSON string because the signature is calculated over its
+// exact bytes.
+type signedManifest struct {
+	Manifest  string `json:"manifest"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publickey"`
+	Signature string `json:"signature"`
+}
+
+// manifestPublicKey is the response of ManifestGetPublicKey.
+type manifestPublicKey struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publickey"`
+}
+
+// initManifestKey makes sure a key to sign run manifests with exists.
+// A key from the data folder of older versions is moved to the store,
+// otherwise a new key is generated. The key is kept in the store so
+// standby instances replicate it and sign with the same key.
+func initManifestKey() error {
+	seed, err := storeService.ManifestKeyGet()
+	if err != nil || seed != nil {
+		return err
+	}
+
+	path := filepath.Join(gaia.Cfg.DataPath, manifestKeyFile)
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		if seed, err = hex.DecodeString(string(content)); err != nil || len(seed) != ed25519.SeedSize {
+			return errInvalidManifestKey
+		}
+	} else if os.IsNotExist(err) {
+		// Generate new key
+		_, key, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return err
+		}
+		seed = key.Seed()
+	} else {
+		return err
+	}
+
+	if err = storeService.ManifestKeyPut(seed); err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// manifestKey returns the key run manifests are signed with. The key
+// is read from the store every time because a standby instance gets
+// the key of its primary with the next replication.
+func manifestKey() (ed25519.PrivateKey, error) {
+	seed, err := storeService.ManifestKeyGet()
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errInvalidManifestKey
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// PipelineRunGetManifest exports everything which is needed to
+// reproduce the given run as signed manifest.
+// Required parameters are pipelineid and runid.
+func PipelineRunGetManifest(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	run, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if run == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	key, err := manifestKey()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	manifest, err := json.Marshal(&runManifest{
+		PipelineID:   p.ID,
+		PipelineName: p.Name,
+		PipelineType: p.Type,
+		Repo:         p.Repo.URL,
+		Branch:       p.Repo.SelectedBranch,
+		RunID:        run.ID,
+		UniqueID:     run.UniqueID,
+		Status:       string(run.Status),
+		Commit:       run.Commit.Hash,
+		Checksum:     hex.EncodeToString(run.Checksum),
+		Platform:     run.Platform,
+		SSHChecksum:  hex.EncodeToString(run.SSHChecksum),
+		SSHPlatform:  run.SSHPlatform,
+		Environment:  run.Environment,
+		Params:       run.Params,
+		Jobs:         run.OnlyJobs,
+		Secrets:      run.Secrets,
+		StartedBy:    run.StartedBy,
+		StartDate:    run.StartDate,
+		FinishDate:   run.FinishDate,
+		Exported:     time.Now(),
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, signedManifest{
+		Manifest:  string(manifest),
+		Algorithm: manifestAlgorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)),
+	})
+}
+
+// ManifestGetPublicKey returns the public key run manifests can be
+// verified with.
+func ManifestGetPublicKey(c echo.Context) error {
+	key, err := manifestKey()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, manifestPublicKey{
+		Algorithm: manifestAlgorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	})
+}
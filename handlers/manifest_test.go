@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/labstack/echo"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestInitManifestKey(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestInitManifestKey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: tmp, DataPath: tmp}
+	gaia.Cfg.Bolt.Mode = 0600
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer storeService.Close()
+
+	// The key of older versions is moved to the store
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 1
+	path := filepath.Join(tmp, manifestKeyFile)
+	if err = ioutil.WriteFile(path, []byte(hex.EncodeToString(seed)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = initManifestKey(); err != nil {
+		t.Fatal(err)
+	}
+	key, err := manifestKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, ed25519.NewKeyFromSeed(seed)) {
+		t.Fatal("expected key of the data folder")
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected key file to be removed, got %v", err)
+	}
+
+	// The stored key is kept
+	if err = initManifestKey(); err != nil {
+		t.Fatal(err)
+	}
+	if key, err = manifestKey(); err != nil || !bytes.Equal(key, ed25519.NewKeyFromSeed(seed)) {
+		t.Fatalf("expected stored key to be kept, got %v", err)
+	}
+
+	// Replicated keys replace the own key
+	other := make([]byte, ed25519.SeedSize)
+	if err = storeService.ManifestKeyPut(other); err != nil {
+		t.Fatal(err)
+	}
+	if key, err = manifestKey(); err != nil || !bytes.Equal(key, ed25519.NewKeyFromSeed(other)) {
+		t.Fatalf("expected replicated key, got %v", err)
+	}
+}
+
+func TestPipelineRunGetManifest(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestPipelineRunGetManifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: tmp, DataPath: tmp}
+	gaia.Cfg.Bolt.Mode = 0600
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer storeService.Close()
+	if err = initManifestKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &gaia.Pipeline{Name: "pipeline", Type: gaia.PTypeGolang, SSH: &gaia.SSHTarget{Host: "build-host", Platform: "linux/arm64"}}
+	if err = storeService.PipelinePut(p); err != nil {
+		t.Fatal(err)
+	}
+	run := &gaia.PipelineRun{
+		UniqueID:    "run-1",
+		ID:          1,
+		PipelineID:  p.ID,
+		Status:      gaia.RunSuccess,
+		Checksum:    []byte{1, 2},
+		Platform:    "linux/amd64",
+		SSHChecksum: []byte{3, 4},
+		SSHPlatform: "linux/arm64",
+	}
+	if err = storeService.PipelinePutRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(echo.GET, "/", nil), rec)
+	c.SetParamNames("pipelineid", "runid")
+	c.SetParamValues("1", "1")
+	if err = PipelineRunGetManifest(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	// The manifest is signed with the stored key
+	signed := signedManifest{}
+	if err = json.Unmarshal(rec.Body.Bytes(), &signed); err != nil {
+		t.Fatal(err)
+	}
+	key, err := manifestKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte(signed.Manifest), signature) {
+		t.Fatal("expected valid signature")
+	}
+
+	// Both executed binaries are recorded
+	manifest := runManifest{}
+	if err = json.Unmarshal([]byte(signed.Manifest), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Checksum != "0102" || manifest.Platform != "linux/amd64" {
+		t.Fatalf("unexpected local binary %q for %q", manifest.Checksum, manifest.Platform)
+	}
+	if manifest.SSHChecksum != "0304" || manifest.SSHPlatform != "linux/arm64" {
+		t.Fatalf("unexpected ssh binary %q for %q", manifest.SSHChecksum, manifest.SSHPlatform)
+	}
+}
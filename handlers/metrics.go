@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+const (
+	// defaultMetricsDays is the number of days of run metrics
+	// which are returned if not given.
+	defaultMetricsDays = 90
+
+	// defaultDORADays is the number of days the delivery metrics and
+	// the queue wait times are calculated for if not given.
+	defaultDORADays = 30
+)
+
+// errInvalidDays is thrown when an invalid number of days was given.
+var errInvalidDays = errors.New("invalid number of days given")
+
+// PipelineGetMetrics returns the daily rolled-up run metrics of the
+// given pipeline.
+//
+// Optional parameter days limits the metrics to the last days (default 90).
+func PipelineGetMetrics(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	days, err := metricsDays(c, defaultMetricsDays)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	metrics, err := storeService.MetricsGet(pipelineID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, metrics)
+}
+
+// PipelineGetDORAMetrics returns the delivery metrics of the given pipeline.
+//
+// Optional parameter days limits the metrics to the last days (default 30).
+func PipelineGetDORAMetrics(c echo.Context) error {
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+	return getDORAMetrics(c, pipelineID)
+}
+
+// DORAMetricsGet returns the delivery metrics of all pipelines.
+//
+// Optional parameter days limits the metrics to the last days (default 30).
+func DORAMetricsGet(c echo.Context) error {
+	return getDORAMetrics(c, 0)
+}
+
+// getDORAMetrics sends the delivery metrics of the pipeline with the
+// given id. A zero id sends the metrics of all pipelines.
+func getDORAMetrics(c echo.Context, pipelineID int) error {
+	days, err := metricsDays(c, defaultDORADays)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	metrics, err := pipeline.GetDORAMetrics(pipelineID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, metrics)
+}
+
+// metricsDays returns the number of days given by the days parameter.
+func metricsDays(c echo.Context, days int) (int, error) {
+	d := c.QueryParam("days")
+	if d == "" {
+		return days, nil
+	}
+	days, err := strconv.Atoi(d)
+	if err != nil || days <= 0 {
+		return 0, errInvalidDays
+	}
+	return days, nil
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// MutexGetAll returns all currently held mutex groups and their holders.
+func MutexGetAll(c echo.Context) error {
+	return c.JSON(http.StatusOK, schedulerService.Mutexes())
+}
+
+// PipelinePutMutexGroups replaces the mutex groups of the given pipeline.
+func PipelinePutMutexGroups(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	groups := []string{}
+	if err := c.Bind(&groups); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	groups, err = scheduler.ValidateMutexGroups(groups)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.MutexGroups = groups
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelinePutConcurrencyParams replaces the concurrency params of the
+// given pipeline.
+func PipelinePutConcurrencyParams(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	params := []string{}
+	if err := c.Bind(&params); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	params, err = scheduler.ValidateConcurrencyParams(params)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.ConcurrencyParams = params
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
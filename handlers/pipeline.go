@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gaia-pipeline/gaia"
 	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/remote"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/gaia-pipeline/gaia/wasm"
 	"github.com/labstack/echo"
 	uuid "github.com/satori/go.uuid"
 )
@@ -15,6 +21,10 @@ import (
 const (
 	// Split char to separate path from pipeline and name
 	pipelinePathSplitChar = "/"
+
+	// startFormOptionsField is the field of a multipart start request
+	// which holds the start request as json.
+	startFormOptionsField = "options"
 )
 
 // PipelineGitLSRemote checks for available git remote branches.
@@ -43,21 +53,52 @@ func CreatePipeline(c echo.Context) error {
 		return c.String(http.StatusBadRequest, err.Error())
 	}
 
-	// Set initial value
-	p.Created = time.Now()
-	p.StatusType = gaia.CreatePipelineRunning
-	p.ID = uuid.Must(uuid.NewV4(), nil).String()
+	username, _ := c.Get(contextUsernameKey).(string)
+	if err := validateCreatePipeline(p, username); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 
 	// Save this pipeline to our store
-	err := storeService.CreatePipelinePut(p)
-	if err != nil {
+	if err := startCreatePipeline(p, correlationID(c)); err != nil {
 		gaia.Cfg.Logger.Debug("cannot put pipeline into store", "error", err.Error())
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
+	return nil
+}
 
-	// Cloning the repo and compiling the pipeline will be done async
-	go pipeline.CreatePipeline(p)
+// validateCreatePipeline checks the settings of the given pipeline
+// which is about to be created by the given user.
+func validateCreatePipeline(p *gaia.CreatePipeline, username string) error {
+	if err := pipeline.ValidatePlatforms(p.Pipeline.Platforms); err != nil {
+		return err
+	}
+	if err := wasm.ValidateCapabilities(p.Pipeline.WASMCapabilities); err != nil {
+		return err
+	}
+	if p.Pipeline.Runbook != nil {
+		if err := pipeline.ValidateRunbook(p.Pipeline.Runbook); err != nil {
+			return err
+		}
+		p.Pipeline.Runbook.Updated = time.Now()
+		p.Pipeline.Runbook.UpdatedBy = username
+	}
+	return nil
+}
 
+// startCreatePipeline saves the given pipeline and queues its
+// creation. Cloning the repo and compiling the pipeline is done by a
+// build worker.
+func startCreatePipeline(p *gaia.CreatePipeline, correlationID string) error {
+	// Set initial value
+	p.Created = time.Now()
+	p.StatusType = gaia.CreatePipelineQueued
+	p.ID = uuid.Must(uuid.NewV4(), nil).String()
+	p.CorrelationID = correlationID
+
+	if err := storeService.CreatePipelinePut(p); err != nil {
+		return err
+	}
+	pipeline.EnqueueBuild(p)
 	return nil
 }
 
@@ -76,6 +117,39 @@ func CreatePipelineGetAll(c echo.Context) error {
 	return c.JSON(http.StatusOK, pipelineList)
 }
 
+// PipelineGetBuilds returns all builds of the given pipeline with their
+// metadata, the latest build first.
+func PipelineGetBuilds(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	pipelineList, err := storeService.CreatePipelineGet()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	builds := []gaia.CreatePipeline{}
+	for _, b := range pipelineList {
+		if b.Pipeline.Name == p.Name {
+			builds = append(builds, b)
+		}
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Created.After(builds[j].Created)
+	})
+
+	return c.JSON(http.StatusOK, builds)
+}
+
 // PipelineNameAvailable looks up if the given pipeline name is
 // available and valid.
 func PipelineNameAvailable(c echo.Context) error {
@@ -136,6 +210,28 @@ func PipelineGet(c echo.Context) error {
 	return c.String(http.StatusNotFound, errPipelineNotFound.Error())
 }
 
+// pipelineStartRequest represents the optional json body of PipelineStart.
+// Files can be attached with a multipart body. Then the start request
+// is passed in the options field.
+type pipelineStartRequest struct {
+	// Secrets are the vault keys which should be passed to the jobs.
+	Secrets []string `json:"secrets"`
+
+	// Canary runs are only executed by canary workers.
+	Canary bool `json:"canary"`
+
+	// Debug captures extra diagnostics for this run.
+	Debug bool `json:"debug"`
+
+	// Jobs are the ids of the jobs which should be executed. If
+	// dependencies is set, all jobs with lower priority are executed too.
+	Jobs         []uint32 `json:"jobs"`
+	Dependencies bool     `json:"dependencies"`
+
+	// Params are passed to the jobs as args.
+	Params map[string]string `json:"params"`
+}
+
 // PipelineStart starts a pipeline by the given id.
 // Afterwards it returns the created/scheduled pipeline run.
 func PipelineStart(c echo.Context) error {
@@ -155,9 +251,55 @@ func PipelineStart(c echo.Context) error {
 		}
 	}
 
+	// The options of a preset can be overwritten by the body
+	r := &pipelineStartRequest{}
+	if name := c.QueryParam("preset"); name != "" && foundPipeline.Name != "" {
+		preset := findPreset(&foundPipeline, name)
+		if preset == nil {
+			return c.String(http.StatusNotFound, errPresetNotFound.Error())
+		}
+		// Copy the slices. Binding the body must not change the preset.
+		r = &pipelineStartRequest{
+			Secrets:      append([]string(nil), preset.Secrets...),
+			Canary:       preset.Canary,
+			Debug:        preset.Debug,
+			Jobs:         append([]uint32(nil), preset.Jobs...),
+			Dependencies: preset.Dependencies,
+			Params:       map[string]string{},
+		}
+		for k, v := range preset.Params {
+			r.Params[k] = v
+		}
+	}
+
+	// The request body is optional
+	var inputs []scheduler.RunInput
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		if inputs, err = bindStartForm(c, r); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	} else if c.Request().ContentLength != 0 {
+		if err := c.Bind(r); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+
 	if foundPipeline.Name != "" {
-		pipelineRun, err := schedulerService.SchedulePipeline(&foundPipeline)
-		if err != nil {
+		username, _ := c.Get(contextUsernameKey).(string)
+		pipelineRun, err := schedulerService.SchedulePipeline(&foundPipeline, scheduler.ScheduleOptions{
+			Secrets:       r.Secrets,
+			Canary:        r.Canary,
+			Debug:         r.Debug,
+			Jobs:          r.Jobs,
+			Dependencies:  r.Dependencies,
+			Inputs:        inputs,
+			Params:        r.Params,
+			User:          username,
+			CorrelationID: correlationID(c),
+		})
+		if contractErr, ok := err.(*scheduler.ContractError); ok {
+			return errorResponse(c, http.StatusBadRequest, errContractViolated.Error(), contractErr)
+		} else if err != nil {
 			return c.String(http.StatusBadRequest, err.Error())
 		} else if pipelineRun != nil {
 			return c.JSON(http.StatusCreated, pipelineRun)
@@ -168,6 +310,35 @@ func PipelineStart(c echo.Context) error {
 	return c.String(http.StatusNotFound, errPipelineNotFound.Error())
 }
 
+// bindStartForm binds a multipart start request. The options field
+// holds the start request as json. All attached files are returned
+// as input files of the run.
+func bindStartForm(c echo.Context, r *pipelineStartRequest) ([]scheduler.RunInput, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if options := form.Value[startFormOptionsField]; len(options) > 0 {
+		if err = json.Unmarshal([]byte(options[0]), r); err != nil {
+			return nil, err
+		}
+	}
+
+	inputs := []scheduler.RunInput{}
+	for _, files := range form.File {
+		for _, file := range files {
+			file := file
+			inputs = append(inputs, scheduler.RunInput{
+				Name: file.Filename,
+				Open: func() (io.ReadCloser, error) {
+					return file.Open()
+				},
+			})
+		}
+	}
+	return inputs, nil
+}
+
 type getAllWithLatestRun struct {
 	Pipeline    gaia.Pipeline    `json:"p"`
 	PipelineRun gaia.PipelineRun `json:"r"`
@@ -204,3 +375,326 @@ func PipelineGetAllWithLatestRun(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, pipelinesWithLatestRun)
 }
+
+// PipelineDelete soft deletes the given pipeline.
+// It can be restored until the retention period passed.
+// Admin role is required.
+func PipelineDelete(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := pipeline.DeletePipeline(pipelineID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelineRestore restores the given deleted pipeline.
+// Admin role is required.
+func PipelineRestore(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := pipeline.RestorePipeline(pipelineID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelineGetDeleted returns all deleted pipelines which can be restored.
+func PipelineGetDeleted(c echo.Context) error {
+	pipelines, err := pipeline.GetDeletedPipelines()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pipelines)
+}
+
+// PipelineArchive archives the given pipeline. Its runs are kept but
+// it is not active and its schedules are disabled until it is unarchived.
+// Admin role is required.
+func PipelineArchive(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := pipeline.ArchivePipeline(pipelineID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelineUnarchive restores the given archived pipeline.
+// Admin role is required.
+func PipelineUnarchive(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := pipeline.UnarchivePipeline(pipelineID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelineGetArchived returns all archived pipelines.
+func PipelineGetArchived(c echo.Context) error {
+	pipelines, err := pipeline.GetArchivedPipelines()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pipelines)
+}
+
+// PipelinePutSandbox replaces the sandbox settings of the given pipeline.
+// They are applied from the next job execution on.
+func PipelinePutSandbox(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	sb := gaia.Sandbox{}
+	if err := c.Bind(&sb); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Sandbox = sb
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelinePutSSH replaces the SSH host of the given pipeline. An empty
+// host executes all jobs locally again.
+func PipelinePutSSH(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	target := &gaia.SSHTarget{}
+	if err := c.Bind(target); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if target.Host == "" {
+		target = nil
+	} else if err := remote.ValidateTarget(target); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.SSH = target
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelinePutWASMCapabilities replaces the host functions the module of
+// the given wasm pipeline may import. They apply from the next job on.
+func PipelinePutWASMCapabilities(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	capabilities := []string{}
+	if err := c.Bind(&capabilities); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := wasm.ValidateCapabilities(capabilities); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.WASMCapabilities = capabilities
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelinePutProblemMatchers replaces the problem matchers of the given
+// pipeline. They are applied from the next finished run on.
+func PipelinePutProblemMatchers(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	matchers := []gaia.ProblemMatcher{}
+	if err := c.Bind(&matchers); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateProblemMatchers(matchers); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.ProblemMatchers = matchers
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelineLint statically checks the given pipeline and returns
+// all findings. No job is executed.
+func PipelineLint(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" || p.Deleted || p.Archived {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	findings, err := schedulerService.LintPipeline(p)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, findings)
+}
+
+// PipelinePutGates replaces the gates of the given pipeline.
+// Runs wait until all gates are open before their jobs are started.
+func PipelinePutGates(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	gates := []gaia.Gate{}
+	if err := c.Bind(&gates); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateGates(gates); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Gates = gates
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelinePutWebhooks replaces the webhooks of the given pipeline.
+// Webhooks can read the secrets granted to the pipeline, so only
+// admins can change them.
+func PipelinePutWebhooks(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	webhooks := []gaia.Webhook{}
+	if err := c.Bind(&webhooks); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateWebhooks(webhooks); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Webhooks = webhooks
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelinePutJira replaces the Jira integration of the given pipeline.
+// An empty body disables the integration. The integration updates the
+// issues with the credentials of the server, so only admins can change it.
+func PipelinePutJira(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	var jira *gaia.JiraIntegration
+	if err := c.Bind(&jira); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Jira = jira
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
@@ -1,19 +1,41 @@
 package handlers
 
 import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/plugin"
+	"github.com/gaia-pipeline/gaia/scheduler"
 	"github.com/labstack/echo"
 )
 
 const (
 	maxMaxBufferLen = 1024
+
+	// maxLogChunkSize is the max length of a requested job log part.
+	maxLogChunkSize = 4 * 1024 * 1024
+
+	// defaultLogChunkSize is the length of a job log part if no limit
+	// has been requested.
+	defaultLogChunkSize = 1024 * 1024
+
+	// maxCachedSourceResolvers is the max number of commits whose
+	// source resolver is kept between log polls.
+	maxCachedSourceResolvers = 32
 )
 
 // jobLogs represents the json format which is returned
@@ -21,6 +43,36 @@ const (
 type jobLogs struct {
 	Log      string `json:"log"`
 	Finished bool   `json:"finished"`
+
+	// Offset is the byte offset of the returned log part and
+	// Size the current size of the whole log.
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+
+	// Sources are the file references in the returned log which link
+	// to the repo of the pipeline at the built commit.
+	Sources []gaia.SourceReference `json:"sources,omitempty"`
+}
+
+// logLevels are the levels of structured log entries by severity.
+var logLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+var (
+	// sourceResolvers caches the source resolvers by pipeline, repo
+	// and commit because the file list of a commit never changes.
+	sourceResolvers     = map[string]*scheduler.SourceResolver{}
+	sourceResolversLock sync.Mutex
+)
+
+// logRange represents the requested part of a job log.
+type logRange struct {
+	// Offset is the byte offset the part starts at.
+	// Negative offsets count from the end of the log.
+	Offset int64
+
+	// Limit is the max length of the part. Zero reads the default
+	// chunk size.
+	Limit int64
 }
 
 // PipelineRunGet returns details about a specific pipeline run.
@@ -50,6 +102,249 @@ func PipelineRunGet(c echo.Context) error {
 	return c.JSON(http.StatusOK, pipelineRun)
 }
 
+// PipelineRunGetDebugLog returns the diagnostics of a debug run.
+// Required parameters are pipelineid and runid.
+func PipelineRunGetDebugLog(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	// Find pipeline run in store
+	pipelineRun, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if pipelineRun == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	} else if !pipelineRun.Debug {
+		return c.String(http.StatusNotFound, "pipeline run was not started in debug mode")
+	}
+
+	// Read diagnostics. They might not exist yet if the run is still in the queue.
+	path := filepath.Join(gaia.Cfg.WorkspacePath, c.Param("pipelineid"), c.Param("runid"), gaia.DebugLogFileName)
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.String(http.StatusOK, string(content))
+}
+
+// PipelineRunGetTelemetry returns the resource samples of all jobs
+// of the given pipeline run by job id.
+// Required parameters are pipelineid and runid.
+func PipelineRunGetTelemetry(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	// Find pipeline run in store
+	pipelineRun, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if pipelineRun == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+
+	// Read samples of all jobs which have been executed
+	telemetry := map[string][]gaia.ResourceSample{}
+	path := scheduler.GetTelemetryPath(pipelineID, runID)
+	for _, job := range pipelineRun.Jobs {
+		jobID := strconv.FormatUint(uint64(job.ID), 10)
+		content, err := ioutil.ReadFile(filepath.Join(path, jobID+".json"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+
+		samples := []gaia.ResourceSample{}
+		if err = json.Unmarshal(content, &samples); err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		telemetry[jobID] = samples
+	}
+
+	return c.JSON(http.StatusOK, telemetry)
+}
+
+// PipelineRunRerun re-runs the failed jobs of the given failed run.
+// Jobs which succeeded are skipped. The secrets, the debug mode and
+// the binaries of the original run are reused. The binaries are taken
+// from the registry.
+// Required parameters are pipelineid and runid.
+func PipelineRunRerun(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	// Find pipeline run in store
+	pipelineRun, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if pipelineRun == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	} else if pipelineRun.Status != gaia.RunFailed {
+		return c.String(http.StatusBadRequest, "only failed pipeline runs can be re-run")
+	}
+
+	// Look up pipeline for the given id
+	var foundPipeline gaia.Pipeline
+	for pipeline := range pipeline.GlobalActivePipelines.Iter() {
+		if pipeline.ID == pipelineID {
+			foundPipeline = pipeline
+		}
+	}
+	if foundPipeline.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	// The rerun executes the binaries of the original run
+	binary, err := publishedBinary(pipelineRun.Checksum)
+	if err != nil {
+		return c.String(http.StatusConflict, err.Error())
+	}
+	sshBinary, err := publishedBinary(pipelineRun.SSHChecksum)
+	if err != nil {
+		return c.String(http.StatusConflict, err.Error())
+	}
+
+	username, _ := c.Get(contextUsernameKey).(string)
+	run, err := schedulerService.SchedulePipeline(&foundPipeline, scheduler.ScheduleOptions{
+		Secrets:         pipelineRun.Secrets,
+		Debug:           pipelineRun.Debug,
+		Jobs:            scheduler.GetUnsuccessfulJobs(pipelineRun),
+		RerunOf:         pipelineRun.ID,
+		Params:          pipelineRun.Params,
+		Inputs:          scheduler.RunInputsOf(pipelineRun),
+		User:            username,
+		CorrelationID:   correlationID(c),
+		PinnedBinary:    binary,
+		PinnedSSHBinary: sshBinary,
+	})
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, run)
+}
+
+// publishedBinary returns the path of the published binary with the
+// given checksum. Runs which have been executed before checksums were
+// recorded have no checksum and execute the current binary.
+func publishedBinary(checksum []byte) (string, error) {
+	if len(checksum) == 0 {
+		return "", nil
+	}
+	path, err := pipeline.RegistryBinaryPath(hex.EncodeToString(checksum))
+	if err != nil {
+		return "", err
+	} else if path == "" {
+		return "", errRerunBinaryNotFound
+	}
+	return path, nil
+}
+
+// PipelineRunCancel cancels the given queued or running pipeline run.
+// Running jobs get a grace period to clean up before they are killed.
+func PipelineRunCancel(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	// Find pipeline run in store
+	pipelineRun, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if pipelineRun == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+
+	if err = schedulerService.CancelPipelineRun(pipelineRun); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Record cancellation in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	err = storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditRunCancel,
+		Target:        fmt.Sprintf("pipeline %d run %d", pipelineID, runID),
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pipelineRun)
+}
+
+// PipelineRunBoost moves the given queued pipeline run to the front
+// of the queue. The change is recorded in the audit log.
+func PipelineRunBoost(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	pipelineRun, err := schedulerService.BoostPipelineRun(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Record boost in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	err = storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditRunBoost,
+		Target:        fmt.Sprintf("pipeline %d run %d", pipelineID, runID),
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pipelineRun)
+}
+
 // PipelineGetAllRuns returns all runs about the given pipeline.
 func PipelineGetAllRuns(c echo.Context) error {
 	// Convert string to int because id is int
@@ -93,11 +388,23 @@ func PipelineGetLatestRun(c echo.Context) error {
 //
 // Optional parameters:
 // jobid - Job id
+// colors - If false, ANSI color codes are stripped from the logs
+// offset - Byte offset of the returned part. Negative offsets read the tail
+// limit - Max length of the returned part. Without offset and limit the
+// last megabyte of every log is returned
 func GetJobLogs(c echo.Context) error {
 	// Get parameters and validate
 	pipelineID := c.Param("pipelineid")
 	pipelineRunID := c.Param("runid")
 	jobID := c.QueryParam("jobid")
+	rng, err := parseLogRange(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	colors, err := parseLogColors(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 
 	// Transform pipelineid to int
 	p, err := strconv.Atoi(pipelineID)
@@ -113,22 +420,26 @@ func GetJobLogs(c echo.Context) error {
 
 	// Get pipeline run from store
 	run, err := storeService.PipelineGetRunByPipelineIDAndID(p, r)
-	if err != nil {
+	if err != nil || run == nil {
 		return c.String(http.StatusBadRequest, "cannot find pipeline run with given pipeline id and pipeline run id")
 	}
+	sources := logSourceResolver(run)
 
 	// jobID is not empty, just return the logs from this job
 	if jobID != "" {
 		for _, job := range run.Jobs {
 			if strconv.FormatUint(uint64(job.ID), 10) == jobID {
 				// Get logs
-				jL, err := getLogs(pipelineID, pipelineRunID, jobID, false)
+				jL, err := getLogs(pipelineID, pipelineRunID, jobID, false, rng, colors)
 				if err != nil {
 					return c.String(http.StatusBadRequest, err.Error())
 				}
+				if sources != nil {
+					jL.Sources = sources.Resolve(jL.Log)
+				}
 
 				// Check if job is finished
-				if job.Status == gaia.JobSuccess || job.Status == gaia.JobFailed {
+				if job.Status == gaia.JobSuccess || job.Status == gaia.JobFailed || job.Status == gaia.JobHung || job.Status == gaia.JobCancelled {
 					jL.Finished = true
 				}
 
@@ -153,7 +464,7 @@ func GetJobLogs(c echo.Context) error {
 	jobs := []jobLogs{}
 	for _, job := range run.Jobs {
 		// Get logs
-		jL, err := getLogs(pipelineID, pipelineRunID, strconv.FormatUint(uint64(job.ID), 10), true)
+		jL, err := getLogs(pipelineID, pipelineRunID, strconv.FormatUint(uint64(job.ID), 10), true, rng, colors)
 		if err != nil {
 			return c.String(http.StatusBadRequest, err.Error())
 		}
@@ -163,9 +474,12 @@ func GetJobLogs(c echo.Context) error {
 		if jL == nil {
 			continue
 		}
+		if sources != nil {
+			jL.Sources = sources.Resolve(jL.Log)
+		}
 
 		// Check if job is finished
-		if job.Status == gaia.JobSuccess || job.Status == gaia.JobFailed {
+		if job.Status == gaia.JobSuccess || job.Status == gaia.JobFailed || job.Status == gaia.JobHung || job.Status == gaia.JobCancelled {
 			jL.Finished = true
 		}
 
@@ -176,27 +490,321 @@ func GetJobLogs(c echo.Context) error {
 	return c.JSON(http.StatusOK, jobs)
 }
 
-func getLogs(pipelineID, pipelineRunID, jobID string, getAllJobLogs bool) (*jobLogs, error) {
+// logSourceResolver returns the resolver of the file references in the
+// job logs of the given run. Returns nil if the files of the built
+// commit are not known.
+func logSourceResolver(run *gaia.PipelineRun) *scheduler.SourceResolver {
+	p, err := storeService.PipelineGet(run.PipelineID)
+	if err != nil || p.Name == "" {
+		return nil
+	}
+	commit := run.Commit.Hash
+	if commit == "" {
+		commit = p.Commit.Hash
+	}
+
+	key := fmt.Sprintf("%s/%s/%s/%s", p.Name, p.Type, p.Repo.URL, commit)
+	sourceResolversLock.Lock()
+	defer sourceResolversLock.Unlock()
+	if r, ok := sourceResolvers[key]; ok {
+		return r
+	}
+
+	files, err := pipeline.GetSourceFiles(p, commit)
+	if err != nil {
+		gaia.Cfg.Logger.Warn("cannot read files of built commit", "error", err.Error(), "pipeline", p.Name, "commit", commit)
+		return nil
+	} else if files == nil {
+		return nil
+	}
+
+	// Forget an arbitrary commit when the cache is full
+	if len(sourceResolvers) >= maxCachedSourceResolvers {
+		for k := range sourceResolvers {
+			delete(sourceResolvers, k)
+			break
+		}
+	}
+	sourceResolvers[key] = scheduler.NewSourceResolver(p.Repo.URL, commit, files)
+	return sourceResolvers[key]
+}
+
+// getLogs reads the requested part of the given job log. Without
+// colors, offset and size refer to the log without escape sequences.
+func getLogs(pipelineID, pipelineRunID, jobID string, getAllJobLogs bool, rng logRange, colors bool) (*jobLogs, error) {
 	// Lookup log file
 	logFilePath := filepath.Join(gaia.Cfg.WorkspacePath, pipelineID, pipelineRunID, gaia.LogsFolderName, jobID)
 
 	// We only check if logs exist when a specific job log was requested.
 	// If we don't do this, get all job logs will fail during a pipeline run.
-	if _, err := os.Stat(logFilePath); os.IsNotExist(err) {
+	f, err := os.Open(logFilePath)
+	if os.IsNotExist(err) {
 		if !getAllJobLogs {
 			return nil, err
 		}
 		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	// Read file
-	content, err := ioutil.ReadFile(logFilePath)
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var log io.ReadSeeker = f
+	size := info.Size()
+	if !colors {
+		stripped, err := newStrippedLog(f)
+		if err != nil {
+			return nil, err
+		}
+		log, size = stripped, stripped.size
+	}
+
+	// Only read the requested part
+	start := rng.Offset
+	if start < 0 {
+		start += size
+	}
+	if start < 0 {
+		start = 0
+	} else if start > size {
+		start = size
+	}
+	limit := rng.Limit
+	if limit <= 0 {
+		limit = defaultLogChunkSize
+	}
+	length := size - start
+	if limit < length {
+		length = limit
+	}
+
+	if _, err = log.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(log, length))
 	if err != nil {
 		return nil, err
 	}
 
 	// Create return struct
 	return &jobLogs{
-		Log: string(content),
+		Log:    string(content),
+		Offset: start,
+		Size:   size,
 	}, nil
 }
+
+// parseLogRange parses the optional offset and limit query parameters.
+// The limit is capped to the max chunk size. Without offset and limit
+// the tail of the default chunk size is read.
+func parseLogRange(c echo.Context) (logRange, error) {
+	rng := logRange{}
+	if c.QueryParam("offset") == "" && c.QueryParam("limit") == "" {
+		rng.Offset = -defaultLogChunkSize
+		return rng, nil
+	}
+
+	var err error
+	if offset := c.QueryParam("offset"); offset != "" {
+		if rng.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil {
+			return rng, errors.New("invalid offset given")
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		if rng.Limit, err = strconv.ParseInt(limit, 10, 64); err != nil || rng.Limit < 0 {
+			return rng, errors.New("invalid limit given")
+		}
+		if rng.Limit > maxLogChunkSize {
+			rng.Limit = maxLogChunkSize
+		}
+	}
+	return rng, nil
+}
+
+// parseLogColors parses the optional colors query parameter. Colors
+// are kept as stored if not given.
+func parseLogColors(c echo.Context) (bool, error) {
+	colors := c.QueryParam("colors")
+	if colors == "" {
+		return true, nil
+	}
+	keep, err := strconv.ParseBool(colors)
+	if err != nil {
+		return false, errors.New("invalid colors given")
+	}
+	return keep, nil
+}
+
+// GetJobLogRaw returns the plain log of the given job. Range requests
+// are supported, so big logs can be read in parts and tailed.
+//
+// Optional parameters:
+// colors - If false, ANSI color codes are stripped from the log
+func GetJobLogRaw(c echo.Context) error {
+	// Transform ids to int to make sure no path is injected
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+	jobID, err := strconv.ParseUint(c.Param("jobid"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "cannot find job with given job id")
+	}
+	colors, err := parseLogColors(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	logFilePath := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(pipelineID), strconv.Itoa(runID), gaia.LogsFolderName, strconv.FormatUint(jobID, 10))
+	f, err := os.Open(logFilePath)
+	if os.IsNotExist(err) {
+		return c.String(http.StatusNotFound, errLogNotFound.Error())
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	if colors {
+		http.ServeContent(c.Response(), c.Request(), info.Name(), info.ModTime(), f)
+		return nil
+	}
+
+	// Ranges refer to the stripped log
+	stripped, err := newStrippedLog(f)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	http.ServeContent(c.Response(), c.Request(), info.Name(), info.ModTime(), stripped)
+	return nil
+}
+
+// strippedLog reads a log file without escape sequences. The file is
+// streamed, seeking rereads it from the start.
+type strippedLog struct {
+	f    *os.File
+	size int64
+	pos  int64
+	r    io.Reader
+}
+
+// newStrippedLog returns the given log without escape sequences.
+func newStrippedLog(f *os.File) (*strippedLog, error) {
+	size, err := io.Copy(ioutil.Discard, plugin.NewColorStripper(f))
+	if err != nil {
+		return nil, err
+	}
+	return &strippedLog{f: f, size: size}, nil
+}
+
+// Read reads the stripped log from the current position.
+func (l *strippedLog) Read(p []byte) (int, error) {
+	if l.r == nil {
+		if _, err := l.f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		l.r = plugin.NewColorStripper(l.f)
+		if _, err := io.CopyN(ioutil.Discard, l.r, l.pos); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.r.Read(p)
+	l.pos += int64(n)
+	return n, err
+}
+
+// Seek sets the position in the stripped log.
+func (l *strippedLog) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += l.pos
+	case io.SeekEnd:
+		offset += l.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	if offset != l.pos {
+		l.r = nil
+		l.pos = offset
+	}
+	return offset, nil
+}
+
+// GetJobLogEntries returns the structured log entries of the given job.
+//
+// Optional parameters:
+// level - Only entries with this or a higher level are returned
+func GetJobLogEntries(c echo.Context) error {
+	// Transform ids to int to make sure no path is injected
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+	jobID, err := strconv.ParseUint(c.Param("jobid"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "cannot find job with given job id")
+	}
+	minLevel := -1
+	if level := strings.ToLower(c.QueryParam("level")); level != "" {
+		if minLevel = logLevelRank(level); minLevel < 0 {
+			return c.String(http.StatusBadRequest, "invalid log level given")
+		}
+	}
+
+	entriesPath := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(pipelineID), strconv.Itoa(runID), gaia.LogEntriesFolderName, strconv.FormatUint(jobID, 10))
+	f, err := os.Open(entriesPath)
+	if os.IsNotExist(err) {
+		return c.String(http.StatusNotFound, errLogNotFound.Error())
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	defer f.Close()
+
+	// Lines which are not valid entries, e.g. the marker of a
+	// truncated log, are skipped.
+	entries := []gaia.LogEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogChunkSize)
+	for scanner.Scan() {
+		entry := gaia.LogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if logLevelRank(entry.Level) >= minLevel {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// logLevelRank returns the severity of the given log level. Unknown
+// levels return -1.
+func logLevelRank(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+func TestGetLogsWithoutColors(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestGetLogsWithoutColors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{WorkspacePath: tmp}
+	logs := filepath.Join(tmp, "1", "1", gaia.LogsFolderName)
+	if err = os.MkdirAll(logs, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(logs, "1"), []byte("\x1b[32mok\x1b[0m first\n\x1b[31mfailed\x1b[0m second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Paging through the stripped log returns every byte once
+	log := ""
+	offset := int64(0)
+	for i := 0; i < 10; i++ {
+		jL, err := getLogs("1", "1", "1", false, logRange{Offset: offset, Limit: 5}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jL.Offset != offset || jL.Size != int64(len("ok first\nfailed second\n")) {
+			t.Fatalf("unexpected offset %d and size %d", jL.Offset, jL.Size)
+		}
+		log += jL.Log
+		offset += int64(len(jL.Log))
+		if offset == jL.Size {
+			break
+		}
+	}
+	if log != "ok first\nfailed second\n" {
+		t.Fatalf("unexpected stripped log %q", log)
+	}
+
+	// Tails refer to the stripped log
+	jL, err := getLogs("1", "1", "1", false, logRange{Offset: -7}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jL.Log != "second\n" {
+		t.Fatalf("expected tail of the stripped log, got %q", jL.Log)
+	}
+
+	// Reads are limited to the default chunk size
+	big := bytes.Repeat([]byte("x"), defaultLogChunkSize+10)
+	if err = ioutil.WriteFile(filepath.Join(logs, "2"), big, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if jL, err = getLogs("1", "1", "2", false, logRange{}, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(jL.Log) != defaultLogChunkSize || jL.Offset != 0 {
+		t.Fatalf("expected first %d bytes, got %d at offset %d", defaultLogChunkSize, len(jL.Log), jL.Offset)
+	}
+
+	// Requests without range read the tail
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	rng, err := parseLogRange(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jL, err = getLogs("1", "1", "2", false, rng, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(jL.Log) != defaultLogChunkSize || jL.Offset != 10 {
+		t.Fatalf("expected last %d bytes, got %d at offset %d", defaultLogChunkSize, len(jL.Log), jL.Offset)
+	}
+}
+
+func TestPublishedBinary(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestPublishedBinary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{HomePath: tmp}
+
+	checksum := sha256.Sum256([]byte("binary"))
+	published := filepath.Join(tmp, "registry", hex.EncodeToString(checksum[:]))
+	if err = os.MkdirAll(filepath.Dir(published), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(published, []byte("binary"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if path, err := publishedBinary(checksum[:]); err != nil || path != published {
+		t.Fatalf("expected published binary, got %q, %v", path, err)
+	}
+	if path, err := publishedBinary(nil); err != nil || path != "" {
+		t.Fatalf("expected runs without checksum to execute the current binary, got %q, %v", path, err)
+	}
+	other := sha256.Sum256([]byte("other"))
+	if _, err := publishedBinary(other[:]); err != errRerunBinaryNotFound {
+		t.Fatalf("expected error %v, got %v", errRerunBinaryNotFound, err)
+	}
+}
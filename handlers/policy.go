@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+// errWaiverNotFound is thrown when a waiver was not found with the given id
+var errWaiverNotFound = errors.New("waiver not found with the given id")
+
+// waiverApproveRequest represents the optional body of PolicyWaiverApprove.
+type waiverApproveRequest struct {
+	// Expires is the date the waiver stops to apply. Zero never expires.
+	Expires time.Time `json:"expires"`
+}
+
+// PolicyGet returns the dependency policy including all waivers.
+func PolicyGet(c echo.Context) error {
+	policy, err := pipeline.GetDependencyPolicy()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// PolicyPut replaces the forbidden licenses and vulnerable dependencies
+// of the dependency policy. The change is recorded in the audit log.
+func PolicyPut(c echo.Context) error {
+	rules := &gaia.DependencyPolicy{}
+	if err := c.Bind(rules); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	policy, err := pipeline.SetDependencyPolicy(rules)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Record change in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	m, _ := json.Marshal(rules)
+	err = storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditPolicyChange,
+		Message:       string(m),
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// PolicyWaiverRequest requests a waiver which allows a pipeline to use
+// a dependency despite the dependency policy.
+func PolicyWaiverRequest(c echo.Context) error {
+	w := &gaia.PolicyWaiver{}
+	if err := c.Bind(w); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	w.RequestedBy, _ = c.Get(contextUsernameKey).(string)
+
+	if err := pipeline.RequestWaiver(w); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, w)
+}
+
+// PolicyWaiverApprove approves the given waiver. The approval is
+// recorded in the audit log.
+func PolicyWaiverApprove(c echo.Context) error {
+	r := &waiverApproveRequest{}
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(r); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	username, _ := c.Get(contextUsernameKey).(string)
+	w, err := pipeline.ApproveWaiver(c.Param("id"), username, r.Expires)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if w == nil {
+		return c.String(http.StatusNotFound, errWaiverNotFound.Error())
+	}
+
+	// Record approval in audit log
+	err = storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditWaiverApprove,
+		Target:        w.Pipeline,
+		Message:       w.Dependency,
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, w)
+}
+
+// PolicyWaiverDelete deletes the given waiver.
+func PolicyWaiverDelete(c echo.Context) error {
+	found, err := pipeline.DeleteWaiver(c.Param("id"))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if !found {
+		return c.String(http.StatusNotFound, errWaiverNotFound.Error())
+	}
+
+	return c.String(http.StatusOK, "Waiver has been deleted")
+}
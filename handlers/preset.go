@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+// errPresetNotFound is thrown when a preset was not found with the given name
+var errPresetNotFound = errors.New("preset not found with the given name")
+
+// PipelineGetPresets returns all presets of the given pipeline.
+func PipelineGetPresets(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	presets := p.Presets
+	if presets == nil {
+		presets = []gaia.RunPreset{}
+	}
+	return c.JSON(http.StatusOK, presets)
+}
+
+// PipelinePutPreset creates or replaces the preset with the given name.
+func PipelinePutPreset(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	preset := gaia.RunPreset{}
+	if err := c.Bind(&preset); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	preset.Name = strings.TrimSpace(c.Param("name"))
+	if preset.Name == "" {
+		return c.String(http.StatusBadRequest, "preset name must not be empty")
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		for i := range p.Presets {
+			if p.Presets[i].Name == preset.Name {
+				p.Presets[i] = preset
+				return
+			}
+		}
+		p.Presets = append(p.Presets, preset)
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, preset)
+}
+
+// PipelineDeletePreset deletes the preset with the given name.
+func PipelineDeletePreset(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	name := c.Param("name")
+	found := false
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		for i := range p.Presets {
+			if p.Presets[i].Name == name {
+				p.Presets = append(p.Presets[:i], p.Presets[i+1:]...)
+				found = true
+				return
+			}
+		}
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	} else if !found {
+		return c.String(http.StatusNotFound, errPresetNotFound.Error())
+	}
+
+	return c.String(http.StatusOK, "Preset has been deleted")
+}
+
+// findPreset returns the preset with the given name of the given pipeline.
+// Returns nil if the pipeline has no such preset.
+func findPreset(p *gaia.Pipeline, name string) *gaia.RunPreset {
+	for i := range p.Presets {
+		if p.Presets[i].Name == name {
+			return &p.Presets[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// QueueGet returns all runs which wait for a worker with their
+// position, the reason and the estimated start.
+//
+// Optional parameter pipelineid limits the queue to the runs of the
+// given pipeline. The positions are kept.
+func QueueGet(c echo.Context) error {
+	pipelineID := 0
+	if id := c.QueryParam("pipelineid"); id != "" {
+		var err error
+		if pipelineID, err = strconv.Atoi(id); err != nil {
+			return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+		}
+	}
+
+	queue, err := schedulerService.Queue()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if pipelineID != 0 {
+		filtered := []scheduler.QueueEntry{}
+		for _, e := range queue {
+			if e.PipelineID == pipelineID {
+				filtered = append(filtered, e)
+			}
+		}
+		queue = filtered
+	}
+
+	return c.JSON(http.StatusOK, queue)
+}
+
+// BuildQueueGet returns all pipeline builds which wait for a build
+// worker in the order they will be started.
+func BuildQueueGet(c echo.Context) error {
+	return c.JSON(http.StatusOK, pipeline.BuildQueue())
+}
+
+// QueueWaitTimesGet returns the distribution of the queue wait times
+// of all pipelines.
+//
+// Optional parameter days limits the runs to the last days (default 30).
+func QueueWaitTimesGet(c echo.Context) error {
+	return getQueueWaitTimes(c, 0)
+}
+
+// PipelineGetQueueWaitTimes returns the distribution of the queue wait
+// times of the given pipeline.
+//
+// Optional parameter days limits the runs to the last days (default 30).
+func PipelineGetQueueWaitTimes(c echo.Context) error {
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+	return getQueueWaitTimes(c, pipelineID)
+}
+
+// getQueueWaitTimes sends the queue wait times of the pipeline with the
+// given id. A zero id sends the wait times of all pipelines.
+func getQueueWaitTimes(c echo.Context, pipelineID int) error {
+	days, err := metricsDays(c, defaultDORADays)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	waits, err := schedulerService.GetQueueWaitTimes(pipelineID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, waits)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+// errRecoveryReportNotFound is thrown when no recovery report has been
+// created during startup.
+var errRecoveryReportNotFound = errors.New("no recovery report has been created during startup")
+
+// RecoveryGetReport returns the recovery report which has been
+// created during startup.
+func RecoveryGetReport(c echo.Context) error {
+	report := pipeline.GetRecoveryReport()
+	if report == nil {
+		return c.String(http.StatusNotFound, errRecoveryReportNotFound.Error())
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// RecoveryRepair repairs the inconsistencies from the recovery report
+// which are selected by the given options.
+// Afterwards it returns the remaining recovery report.
+func RecoveryRepair(c echo.Context) error {
+	o := &pipeline.RecoveryRepairOptions{}
+	if err := c.Bind(o); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	report, err := pipeline.RepairRecovery(o)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	} else if report == nil {
+		return c.String(http.StatusNotFound, errRecoveryReportNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+// RegistryGetAll returns all published pipeline binaries.
+func RegistryGetAll(c echo.Context) error {
+	binaries, err := pipeline.GetRegistryBinaries()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, binaries)
+}
+
+// RegistryGetBinary returns the published pipeline binary with the
+// given sha256 checksum. Standby instances fetch it with the
+// replication token.
+func RegistryGetBinary(c echo.Context) error {
+	path, err := pipeline.RegistryBinaryPath(c.Param("checksum"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	} else if path == "" {
+		return c.String(http.StatusNotFound, errBinaryNotFound.Error())
+	}
+
+	// Content addressed binaries never change. Only the authenticated
+	// client may cache them.
+	c.Response().Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	return c.File(path)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+const (
+	// replicationPathPrefix is the prefix of all routes which are used
+	// by standby instances to replicate this instance.
+	replicationPathPrefix = "/api/" + apiVersion + "/replication/"
+)
+
+var (
+	// errReplicationDisabled is thrown when the replication endpoint is
+	// used but no replication token has been configured.
+	errReplicationDisabled = errors.New("replication is disabled")
+
+	// errInvalidReplicationToken is thrown when a standby sent a wrong token.
+	errInvalidReplicationToken = errors.New("no or invalid replication token provided")
+
+	// errStandbyReadOnly is thrown when a standby instance should be changed.
+	errStandbyReadOnly = errors.New("instance is a read-only standby. Promote it to make changes")
+)
+
+// replicationTokenBarrier is the middleware which authenticates standby
+// instances with the replication token.
+func replicationTokenBarrier(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if gaia.Cfg.ReplicationToken == "" {
+			return c.String(http.StatusNotFound, errReplicationDisabled.Error())
+		}
+		token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(gaia.Cfg.ReplicationToken)) != 1 {
+			return c.String(http.StatusForbidden, errInvalidReplicationToken.Error())
+		}
+		return next(c)
+	}
+}
+
+// standbyBarrier is the middleware which rejects all changes while
+// this instance is a standby. Only login and promotion are allowed.
+func standbyBarrier(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		method := c.Request().Method
+		if method == http.MethodGet || method == http.MethodHead || !pipeline.IsStandby() {
+			return next(c)
+		}
+		if strings.Contains(c.Path(), "/login") || strings.HasSuffix(c.Path(), "/standby/promote") {
+			return next(c)
+		}
+		return c.String(http.StatusServiceUnavailable, errStandbyReadOnly.Error())
+	}
+}
+
+// ReplicationGetSnapshot returns the replicated state of this instance
+// together with the checksums of the active pipeline binaries.
+func ReplicationGetSnapshot(c echo.Context) error {
+	snapshot, err := storeService.ReplicationExport()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	snapshot.Binaries = []gaia.ReplicationBinary{}
+	for p := range pipeline.GlobalActivePipelines.Iter() {
+		snapshot.Binaries = append(snapshot.Binaries, gaia.ReplicationBinary{
+			PipelineID: p.ID,
+			File:       filepath.Base(p.ExecPath),
+			SHA256Sum:  p.SHA256Sum,
+		})
+	}
+
+	snapshot.Files, err = pipeline.ReplicationFiles()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, snapshot)
+}
+
+// ReplicationGetFile returns the given log or artifact file of a run.
+// Ranges are supported so standby instances fetch only new content.
+func ReplicationGetFile(c echo.Context) error {
+	path, err := pipeline.ReplicationFilePath(c.QueryParam("path"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return c.String(http.StatusNotFound, errLogNotFound.Error())
+	}
+	return c.File(path)
+}
+
+// ReplicationGetBinary returns the binary of the given active pipeline.
+func ReplicationGetBinary(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	for p := range pipeline.GlobalActivePipelines.Iter() {
+		if p.ID == pipelineID {
+			return c.File(p.ExecPath)
+		}
+	}
+	return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+}
+
+// StandbyGet returns the replication state of this instance.
+func StandbyGet(c echo.Context) error {
+	return c.JSON(http.StatusOK, pipeline.GetMirrorStatus())
+}
+
+// StandbyPromote stops the replication and makes this standby instance
+// the primary.
+func StandbyPromote(c echo.Context) error {
+	if err := pipeline.PromoteStandby(); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Record change in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	err := storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditStandbyPromote,
+		Target:        gaia.Cfg.MirrorURL,
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pipeline.GetMirrorStatus())
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+// PipelineGetRunbook returns the runbook of the given pipeline.
+// Pipelines without runbook return an empty runbook.
+func PipelineGetRunbook(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	if p.Runbook == nil {
+		return c.JSON(http.StatusOK, &gaia.Runbook{})
+	}
+	return c.JSON(http.StatusOK, p.Runbook)
+}
+
+// PipelinePutRunbook replaces the runbook of the given pipeline. Synced
+// runbooks are replaced by the readme of the repo on the next build.
+func PipelinePutRunbook(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	runbook := &gaia.Runbook{}
+	if err := c.Bind(runbook); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := pipeline.ValidateRunbook(runbook); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	runbook.Updated = time.Now()
+	runbook.UpdatedBy, _ = c.Get(contextUsernameKey).(string)
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Runbook = runbook
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, runbook)
+}
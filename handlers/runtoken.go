@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+const (
+	// jobPathPrefix is the prefix of all routes which are used by jobs
+	// to report artifacts, outputs and annotations of their run.
+	jobPathPrefix = "/api/" + apiVersion + "/job/"
+
+	// headerRunToken is the header which holds the token a job uses to
+	// call the api on behalf of its run.
+	headerRunToken = "X-Run-Token"
+
+	// contextRunTokenKey is the key in the request context which holds
+	// the run the job which sent the request belongs to.
+	contextRunTokenKey = "runtoken"
+)
+
+var (
+	// errInvalidRunToken is thrown when a job sent no, an expired or an invalid run token.
+	errInvalidRunToken = errors.New("no or invalid run token provided")
+
+	// errArtifactNotFound is thrown when a run has no artifact with the given name.
+	errArtifactNotFound = errors.New("artifact not found with the given name")
+)
+
+// runTokenBarrier is the middleware which authenticates jobs by the run
+// token of their run. Replaces authBarrier for job routes.
+func runTokenBarrier(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		t := schedulerService.RunByToken(c.Request().Header.Get(headerRunToken))
+		if t == nil {
+			return c.String(http.StatusForbidden, errInvalidRunToken.Error())
+		}
+		c.Set(contextRunTokenKey, t)
+		return next(c)
+	}
+}
+
+// JobArtifactUpload stores the request body as artifact with the given
+// name of the run whose job sent the request.
+func JobArtifactUpload(c echo.Context) error {
+	t := c.Get(contextRunTokenKey).(*scheduler.RunToken)
+
+	if err := schedulerService.StoreRunArtifact(t, c.Param("name"), c.Request().Body); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return c.String(http.StatusCreated, "Artifact has been stored")
+}
+
+// JobOutputsPut sets the given outputs of the run whose job sent the request.
+func JobOutputsPut(c echo.Context) error {
+	t := c.Get(contextRunTokenKey).(*scheduler.RunToken)
+
+	outputs := map[string]string{}
+	if err := c.Bind(&outputs); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := schedulerService.SetRunOutputs(t, outputs); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return c.String(http.StatusOK, "Outputs have been set")
+}
+
+// JobAnnotationAdd adds the given annotation to the run whose job sent
+// the request.
+func JobAnnotationAdd(c echo.Context) error {
+	t := c.Get(contextRunTokenKey).(*scheduler.RunToken)
+
+	a := gaia.Annotation{}
+	if err := c.Bind(&a); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := schedulerService.AddRunAnnotation(t, a); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return c.String(http.StatusCreated, "Annotation has been added")
+}
+
+// PipelineRunGetArtifact returns the given artifact of the given run.
+func PipelineRunGetArtifact(c echo.Context) error {
+	// Transform ids to int to make sure no path is injected
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid pipeline run id given")
+	}
+
+	r, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if r == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+
+	path, err := scheduler.RunArtifactPath(r, c.Param("name"))
+	if err != nil {
+		return c.String(http.StatusNotFound, errArtifactNotFound.Error())
+	}
+	return c.Attachment(path, c.Param("name"))
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// scheduleInfo is a schedule with the time of its next run.
+type scheduleInfo struct {
+	gaia.Schedule
+	NextRun time.Time `json:"nextrun,omitempty"`
+}
+
+// PipelineGetSchedules returns the schedules of the given pipeline
+// together with their next run.
+func PipelineGetSchedules(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	now := time.Now()
+	schedules := []scheduleInfo{}
+	for _, sc := range p.Schedules {
+		next, err := scheduler.NextScheduleRun(&sc, now)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		schedules = append(schedules, scheduleInfo{Schedule: sc, NextRun: next})
+	}
+	return c.JSON(http.StatusOK, schedules)
+}
+
+// PipelinePutSchedules replaces the schedules of the given pipeline.
+func PipelinePutSchedules(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	schedules := []gaia.Schedule{}
+	if err := c.Bind(&schedules); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateSchedules(schedules); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Schedules = schedules
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p.Schedules)
+}
+
+// PipelineResume lifts the quarantine of the given pipeline. Its
+// schedules start runs again.
+func PipelineResume(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	var quarantined bool
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		quarantined = p.Quarantine != nil
+		p.Quarantine = nil
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	// Record change in audit log
+	if quarantined {
+		username, _ := c.Get(contextUsernameKey).(string)
+		err = storeService.AuditPut(&gaia.AuditEntry{
+			Actor:         username,
+			Action:        gaia.AuditPipelineResume,
+			Target:        p.Name,
+			CorrelationID: correlationID(c),
+		})
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
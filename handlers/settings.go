@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// SettingsGet returns the current operational settings.
+func SettingsGet(c echo.Context) error {
+	return c.JSON(http.StatusOK, gaia.GetSettings())
+}
+
+// SettingsPut changes the operational settings. Omitted settings keep
+// their current value. The settings are stored and applied without
+// restart. The change is recorded in the audit log.
+func SettingsPut(c echo.Context) error {
+	settings := gaia.GetSettings()
+	if err := c.Bind(&settings); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateSettings(&settings); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := storeService.SettingsPut(&settings); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	gaia.SetSettings(settings)
+
+	// Record change in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	m, _ := json.Marshal(settings)
+	err := storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditSettingsChange,
+		Message:       string(m),
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, settings)
+}
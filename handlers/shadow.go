@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+// shadowSettings represents the shadow settings of a pipeline.
+type shadowSettings struct {
+	Enabled bool `json:"enabled"`
+}
+
+// shadowJobComparison compares a single job of the current and
+// the shadow run.
+type shadowJobComparison struct {
+	Title        string         `json:"title"`
+	Status       gaia.JobStatus `json:"status,omitempty"`
+	ShadowStatus gaia.JobStatus `json:"shadowstatus,omitempty"`
+}
+
+// shadowComparison represents the comparison of the current
+// pipeline run and its shadow run.
+type shadowComparison struct {
+	Run       *gaia.PipelineRun     `json:"run"`
+	ShadowRun *gaia.PipelineRun     `json:"shadowrun"`
+	Finished  bool                  `json:"finished"`
+	Match     bool                  `json:"match"`
+	Jobs      []shadowJobComparison `json:"jobs"`
+}
+
+// PipelinePutShadow enables or disables shadow rebuilds of the given pipeline.
+func PipelinePutShadow(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	settings := shadowSettings{}
+	if err := c.Bind(&settings); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.ShadowRebuilds = settings.Enabled
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelineGetShadow compares the shadow run of the given pipeline
+// with the run it has been executed alongside.
+func PipelineGetShadow(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	} else if p.ShadowRunID == 0 {
+		return c.String(http.StatusNotFound, "pipeline has no shadow run")
+	}
+
+	shadowRun, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, p.ShadowRunID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if shadowRun == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+	run, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, shadowRun.ShadowOf)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if run == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, compareShadowRun(run, shadowRun))
+}
+
+// PipelinePromoteShadow replaces the binary of the given pipeline
+// with its shadow binary.
+func PipelinePromoteShadow(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := pipeline.PromoteShadow(pipelineID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PipelineDeleteShadow discards the shadow binary of the given pipeline.
+func PipelineDeleteShadow(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := pipeline.DiscardShadow(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// compareShadowRun compares the job results of both runs by job title.
+func compareShadowRun(run, shadowRun *gaia.PipelineRun) *shadowComparison {
+	cmp := &shadowComparison{
+		Run:       run,
+		ShadowRun: shadowRun,
+		Finished:  isRunFinished(run) && isRunFinished(shadowRun),
+		Jobs:      []shadowJobComparison{},
+	}
+
+	index := map[string]int{}
+	for _, job := range run.Jobs {
+		index[job.Title] = len(cmp.Jobs)
+		cmp.Jobs = append(cmp.Jobs, shadowJobComparison{Title: job.Title, Status: job.Status})
+	}
+	for _, job := range shadowRun.Jobs {
+		if i, ok := index[job.Title]; ok {
+			cmp.Jobs[i].ShadowStatus = job.Status
+			continue
+		}
+		cmp.Jobs = append(cmp.Jobs, shadowJobComparison{Title: job.Title, ShadowStatus: job.Status})
+	}
+
+	cmp.Match = cmp.Finished && run.Status == shadowRun.Status
+	for _, job := range cmp.Jobs {
+		if job.Status != job.ShadowStatus {
+			cmp.Match = false
+		}
+	}
+
+	return cmp
+}
+
+// isRunFinished returns true if the given run has been finished.
+func isRunFinished(r *gaia.PipelineRun) bool {
+	return r.Status == gaia.RunSuccess || r.Status == gaia.RunFailed
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// SimulationGet simulates the scheduling of the next 24 hours based on
+// the runs of the last 24 hours and the cron schedules of the pipelines.
+// It reports the expected queue waits and the saturation of the worker
+// slots.
+//
+// Optional parameter workers overrides the number of worker slots.
+func SimulationGet(c echo.Context) error {
+	var err error
+	workers := gaia.GetSettings().Worker
+	if w := c.QueryParam("workers"); w != "" {
+		if workers, err = strconv.Atoi(w); err != nil || workers <= 0 {
+			return c.String(http.StatusBadRequest, "invalid number of workers given")
+		}
+	}
+
+	runs, err := storeService.PipelineGetRunsByStatus(gaia.RunSuccess, gaia.RunFailed)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	pipelines, err := storeService.PipelineGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, scheduler.Simulate(runs, pipelines, workers, time.Now()))
+}
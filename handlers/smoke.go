@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+// errSmokeJobNotFound is thrown when the given smoke job is not a job
+// of the pipeline.
+var errSmokeJobNotFound = errors.New("smoke job is not a job of the pipeline")
+
+// smokeSettings represents the smoke job of a pipeline.
+type smokeSettings struct {
+	Job string `json:"job"`
+}
+
+// PipelinePutSmokeJob sets the job which is executed after every
+// rebuild of the given pipeline. An empty job disables the smoke runs.
+func PipelinePutSmokeJob(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	settings := smokeSettings{}
+	if err := c.Bind(&settings); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	settings.Job = strings.TrimSpace(settings.Job)
+
+	// The job has to exist in the active binary
+	var active gaia.Pipeline
+	for p := range pipeline.GlobalActivePipelines.Iter() {
+		if p.ID == pipelineID {
+			active = p
+		}
+	}
+	if settings.Job != "" && active.Name != "" && !hasJobTitle(active.Jobs, settings.Job) {
+		return c.String(http.StatusBadRequest, errSmokeJobNotFound.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.SmokeJob = settings.Job
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// hasJobTitle returns true if one of the given jobs has the given title.
+func hasJobTitle(jobs []gaia.Job, title string) bool {
+	for _, job := range jobs {
+		if job.Title == title {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+	uuid "github.com/satori/go.uuid"
+)
+
+// templateImportRequest represents the body of TemplateImport.
+type templateImportRequest struct {
+	// Template is the name of the template in the template index.
+	Template string `json:"template"`
+
+	// Name is the name of the new pipeline.
+	Name string `json:"name"`
+}
+
+// TemplateGetAll returns all templates of the template index.
+func TemplateGetAll(c echo.Context) error {
+	templates, err := pipeline.GetTemplates()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, templates)
+}
+
+// TemplateImport creates a new pipeline from a template of the
+// template index. The secrets and parameters of the template are
+// placed in the template preset of the pipeline and have to be
+// filled in before the pipeline is started with it. Secrets of the
+// template are not granted to the pipeline. Admins grant them after
+// they reviewed the template.
+func TemplateImport(c echo.Context) error {
+	r := &templateImportRequest{}
+	if err := c.Bind(r); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	for _, s := range strings.Split(r.Name, pipelinePathSplitChar) {
+		if len(s) < 1 || len(s) > 50 {
+			return c.String(http.StatusBadRequest, errPathLength.Error())
+		}
+	}
+
+	p, err := pipeline.ImportTemplate(r.Template, r.Name)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Set initial value
+	p.Created = time.Now()
+	p.StatusType = gaia.CreatePipelineQueued
+	p.ID = uuid.Must(uuid.NewV4(), nil).String()
+	p.CorrelationID = correlationID(c)
+
+	// Save this pipeline to our store
+	if err = storeService.CreatePipelinePut(p); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	// Cloning the repo and compiling the pipeline will be done by a build worker
+	pipeline.EnqueueBuild(p)
+
+	return c.JSON(http.StatusCreated, p)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+const (
+	// Phases of a pipeline run timeline
+	phaseQueued     = "queued"
+	phaseDispatched = "dispatched"
+	phaseStartup    = "startup"
+	phaseExecution  = "execution"
+	phaseTeardown   = "teardown"
+)
+
+// timelinePhase represents a single phase of a pipeline run.
+type timelinePhase struct {
+	Phase    string    `json:"phase"`
+	Job      string    `json:"job,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration"`
+}
+
+// PipelineRunGetTimeline returns the timeline of a pipeline run which
+// shows where the run spent its time.
+// Required parameters are pipelineid and runid.
+func PipelineRunGetTimeline(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	// Convert string to int because id is int
+	runID, err := strconv.Atoi(c.Param("runid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errPipelineRunNotFound.Error())
+	}
+
+	// Find pipeline run in store
+	pipelineRun, err := storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if pipelineRun == nil {
+		return c.String(http.StatusNotFound, errPipelineRunNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, getTimeline(pipelineRun))
+}
+
+// getTimeline creates the timeline of the given run.
+// Phases which have not been finished yet are left out.
+func getTimeline(r *gaia.PipelineRun) []timelinePhase {
+	phases := []timelinePhase{}
+	add := func(phase, job string, start, end time.Time) {
+		if start.IsZero() || end.IsZero() {
+			return
+		}
+		phases = append(phases, timelinePhase{
+			Phase:    phase,
+			Job:      job,
+			Start:    start,
+			End:      end,
+			Duration: end.Sub(start).Seconds(),
+		})
+	}
+
+	add(phaseQueued, "", r.ScheduleDate, r.DispatchDate)
+	add(phaseDispatched, "", r.DispatchDate, r.StartDate)
+
+	// Jobs ordered by their start
+	jobs := make([]gaia.Job, len(r.Jobs))
+	copy(jobs, r.Jobs)
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].StartDate.Before(jobs[j].StartDate)
+	})
+
+	var lastJobFinish time.Time
+	for _, job := range jobs {
+		add(phaseStartup, job.Title, job.StartDate, job.ConnectedDate)
+		add(phaseExecution, job.Title, job.ConnectedDate, job.FinishDate)
+		if job.FinishDate.After(lastJobFinish) {
+			lastJobFinish = job.FinishDate
+		}
+	}
+	add(phaseTeardown, "", lastJobFinish, r.FinishDate)
+
+	return phases
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/labstack/echo"
+)
+
+const (
+	// usageMonthFormat is the format of the month query parameter.
+	usageMonthFormat = "2006-01"
+
+	usageGroupByPipeline = "pipeline"
+	usageGroupByTeam     = "team"
+	usageGroupByWorker   = "worker"
+
+	// usageNoTeam is the key of pipelines which are not assigned to a team.
+	usageNoTeam = "unassigned"
+)
+
+var (
+	// errInvalidUsageMonth is thrown when the given month cannot be parsed
+	errInvalidUsageMonth = errors.New("invalid month given. Expected format is YYYY-MM")
+
+	// errInvalidUsageGroupBy is thrown when the usage should be grouped by an unknown attribute
+	errInvalidUsageGroupBy = errors.New("invalid groupby given. Must be pipeline, team or worker")
+)
+
+// usageEntry represents the aggregated execution time of one
+// pipeline, team or worker in one month.
+type usageEntry struct {
+	Month   string `json:"month"`
+	Key     string `json:"key"`
+	Runs    int    `json:"runs"`
+	Seconds int64  `json:"seconds"`
+}
+
+type pipelineTeamRequest struct {
+	Team string `json:"team"`
+}
+
+// PipelinePutTeam assigns the given pipeline to a team.
+// The team is used to charge back the execution time.
+func PipelinePutTeam(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	r := &pipelineTeamRequest{}
+	if err := c.Bind(r); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Team = r.Team
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// UsageGet returns the monthly execution seconds of all finished runs.
+//
+// Optional parameters:
+// month - Only return the given month (YYYY-MM)
+// groupby - pipeline (default), team or worker
+// format - json (default) or csv
+func UsageGet(c echo.Context) error {
+	month := c.QueryParam("month")
+	if month != "" {
+		if _, err := time.Parse(usageMonthFormat, month); err != nil {
+			return c.String(http.StatusBadRequest, errInvalidUsageMonth.Error())
+		}
+	}
+	groupBy := c.QueryParam("groupby")
+	if groupBy == "" {
+		groupBy = usageGroupByPipeline
+	}
+	if groupBy != usageGroupByPipeline && groupBy != usageGroupByTeam && groupBy != usageGroupByWorker {
+		return c.String(http.StatusBadRequest, errInvalidUsageGroupBy.Error())
+	}
+
+	// Get all pipelines to resolve names and teams
+	pipelines, err := storeService.PipelineGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	pipelineByID := make(map[int]gaia.Pipeline)
+	for _, p := range pipelines {
+		pipelineByID[p.ID] = p
+	}
+
+	// Get all finished runs
+	runs, err := storeService.PipelineGetRunsByStatus(gaia.RunSuccess, gaia.RunFailed)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	// Aggregate
+	entries := make(map[string]*usageEntry)
+	for _, r := range runs {
+		if r.StartDate.IsZero() || r.FinishDate.Before(r.StartDate) {
+			continue
+		}
+		m := r.StartDate.Format(usageMonthFormat)
+		if month != "" && m != month {
+			continue
+		}
+
+		var key string
+		switch groupBy {
+		case usageGroupByPipeline:
+			key = pipelineByID[r.PipelineID].Name
+			if key == "" {
+				key = strconv.Itoa(r.PipelineID)
+			}
+		case usageGroupByTeam:
+			key = pipelineByID[r.PipelineID].Team
+			if key == "" {
+				key = usageNoTeam
+			}
+		case usageGroupByWorker:
+			key = strconv.Itoa(r.Worker)
+		}
+
+		e, ok := entries[m+"/"+key]
+		if !ok {
+			e = &usageEntry{Month: m, Key: key}
+			entries[m+"/"+key] = e
+		}
+		e.Runs++
+		e.Seconds += int64(r.FinishDate.Sub(r.StartDate).Seconds())
+	}
+
+	// Sort by month and key
+	usage := []usageEntry{}
+	for _, e := range entries {
+		usage = append(usage, *e)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Month != usage[j].Month {
+			return usage[i].Month < usage[j].Month
+		}
+		return usage[i].Key < usage[j].Key
+	})
+
+	if c.QueryParam("format") != "csv" {
+		return c.JSON(http.StatusOK, usage)
+	}
+
+	// Write csv
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=usage.csv")
+	c.Response().WriteHeader(http.StatusOK)
+	w := csv.NewWriter(c.Response())
+	w.Write([]string{"month", groupBy, "runs", "seconds"})
+	for _, e := range usage {
+		w.Write([]string{e.Month, e.Key, strconv.Itoa(e.Runs), strconv.FormatInt(e.Seconds, 10)})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/pipeline"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+// VariablesGet returns the global variables.
+func VariablesGet(c echo.Context) error {
+	variables, err := storeService.VariablesGet()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, variables)
+}
+
+// VariablesPut replaces the global variables which are passed to the
+// jobs of all pipelines. The change is recorded in the audit log.
+func VariablesPut(c echo.Context) error {
+	variables := map[string]string{}
+	if err := c.Bind(&variables); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateVariables(variables); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := storeService.VariablesPut(variables); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	// Record change in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	m, _ := json.Marshal(variables)
+	err := storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditVariablesChange,
+		Message:       string(m),
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, variables)
+}
+
+// PipelineGetVariables returns the variables the jobs of the given
+// pipeline get if the run has no params: the global variables
+// overridden by the referenced catalog variables and the variables
+// of the pipeline.
+func PipelineGetVariables(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	p, err := storeService.PipelineGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p.Name == "" {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	global, err := storeService.VariablesGet()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	catalog, err := storeService.CatalogVariableGetAll()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, scheduler.ResolveVariables(global, scheduler.CatalogValues(p, catalog), p.Variables))
+}
+
+// PipelinePutVariables replaces the variables of the given pipeline.
+func PipelinePutVariables(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	variables := map[string]string{}
+	if err := c.Bind(&variables); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := scheduler.ValidateVariables(variables); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	p, err := pipeline.UpdatePipeline(pipelineID, func(p *gaia.Pipeline) {
+		p.Variables = variables
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	} else if p == nil {
+		return c.String(http.StatusNotFound, errPipelineNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, p.Variables)
+}
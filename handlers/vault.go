@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/labstack/echo"
+)
+
+// vaultSecret represents a single vault entry as given by the user.
+type vaultSecret struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// VaultGetKeys returns all keys stored in the vault.
+// Values are never returned.
+func VaultGetKeys(c echo.Context) error {
+	keys, err := storeService.VaultKeys()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// VaultPutSecret adds or overwrites a secret in the vault.
+func VaultPutSecret(c echo.Context) error {
+	s := &vaultSecret{}
+	if err := c.Bind(s); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if s.Key == "" {
+		return c.String(http.StatusBadRequest, "Invalid secret key given")
+	}
+
+	err := storeService.VaultPut(s.Key, []byte(s.Value))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.String(http.StatusCreated, "Secret has been stored")
+}
+
+// VaultDeleteSecret deletes the given secret from the vault.
+func VaultDeleteSecret(c echo.Context) error {
+	key := c.Param("key")
+	if key == "" {
+		return c.String(http.StatusBadRequest, "Invalid secret key given")
+	}
+
+	err := storeService.VaultDelete(key)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.String(http.StatusOK, "Secret has been deleted")
+}
+
+// VaultRotateKey generates a new vault master key and re-encrypts
+// all secrets with it. The rotation is recorded in the audit log.
+func VaultRotateKey(c echo.Context) error {
+	err := storeService.VaultRotateKey()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	// Record rotation in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	err = storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditVaultRotate,
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.String(http.StatusOK, "Vault key has been rotated")
+}
+
+// PipelineGetSecretGrants returns the vault keys which are granted
+// to the given pipeline.
+func PipelineGetSecretGrants(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	keys, err := storeService.SecretGrantsGet(pipelineID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// PipelinePutSecretGrants replaces the vault keys which are granted
+// to the given pipeline. The change is recorded in the audit log.
+func PipelinePutSecretGrants(c echo.Context) error {
+	// Convert string to int because id is int
+	pipelineID, err := strconv.Atoi(c.Param("pipelineid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, errInvalidPipelineID.Error())
+	}
+
+	keys := []string{}
+	if err := c.Bind(&keys); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	err = storeService.SecretGrantsPut(pipelineID, keys)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	// Record change in audit log
+	username, _ := c.Get(contextUsernameKey).(string)
+	err = storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         username,
+		Action:        gaia.AuditSecretGrant,
+		Target:        fmt.Sprintf("pipeline %d", pipelineID),
+		Message:       fmt.Sprintf("%v", keys),
+		CorrelationID: correlationID(c),
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
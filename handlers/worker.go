@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/scheduler"
+	"github.com/labstack/echo"
+)
+
+const (
+	// workerHistoryWindow is the time window for the historical utilization.
+	workerHistoryWindow = 24 * time.Hour
+
+	// workerRecentFailures is the max number of returned recent failures.
+	workerRecentFailures = 10
+)
+
+// workerDetails represents a worker with its history.
+type workerDetails struct {
+	scheduler.WorkerState
+
+	// Utilization is the busy share of the last 24 hours between 0 and 1.
+	Utilization    float64            `json:"utilization"`
+	Runs           int                `json:"runs"`
+	RecentFailures []gaia.PipelineRun `json:"recentfailures"`
+}
+
+// WorkerGetAll returns all workers with their current state and history.
+func WorkerGetAll(c echo.Context) error {
+	workers, err := getWorkerDetails()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, workers)
+}
+
+// WorkerGet returns the worker with the given id and its state and history.
+func WorkerGet(c echo.Context) error {
+	workerID, err := strconv.Atoi(c.Param("workerid"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid worker id given")
+	}
+
+	workers, err := getWorkerDetails()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	for _, w := range workers {
+		if w.ID == workerID {
+			return c.JSON(http.StatusOK, w)
+		}
+	}
+
+	return c.String(http.StatusNotFound, "worker not found with the given id")
+}
+
+// getWorkerDetails combines the current worker state with the
+// run history of the last 24 hours.
+func getWorkerDetails() ([]workerDetails, error) {
+	runs, err := storeService.PipelineGetRunsByStatus(gaia.RunSuccess, gaia.RunFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest runs first
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].FinishDate.After(runs[j].FinishDate)
+	})
+
+	windowStart := time.Now().Add(-workerHistoryWindow)
+	workers := []workerDetails{}
+	for _, state := range schedulerService.Workers() {
+		w := workerDetails{
+			WorkerState:    state,
+			RecentFailures: []gaia.PipelineRun{},
+		}
+
+		// The window starts earliest when the worker started
+		start := windowStart
+		if state.Started.After(start) {
+			start = state.Started
+		}
+
+		var busy time.Duration
+		for _, r := range runs {
+			if r.Worker != state.ID || r.FinishDate.Before(start) {
+				continue
+			}
+			w.Runs++
+
+			from := r.StartDate
+			if from.Before(start) {
+				from = start
+			}
+			busy += r.FinishDate.Sub(from)
+
+			if r.Status == gaia.RunFailed && len(w.RecentFailures) < workerRecentFailures {
+				w.RecentFailures = append(w.RecentFailures, r)
+			}
+		}
+
+		// Current run counts as busy too
+		if state.Status == scheduler.WorkerBusy {
+			busy += time.Since(state.Since)
+		}
+		if window := time.Since(start); window > 0 {
+			w.Utilization = busy.Seconds() / window.Seconds()
+			if w.Utilization > 1 {
+				w.Utilization = 1
+			}
+		}
+
+		workers = append(workers, w)
+	}
+
+	return workers, nil
+}
@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// archiveFolder is the folder in the home folder where binaries of
+	// archived pipelines are kept until they are unarchived.
+	archiveFolder = "archive"
+)
+
+var (
+	// errPipelineArchived is thrown when an archived pipeline should be archived again.
+	errPipelineArchived = errors.New("pipeline has been already archived")
+
+	// errPipelineNotArchived is thrown when a pipeline which is not archived should be unarchived.
+	errPipelineNotArchived = errors.New("pipeline is not archived")
+)
+
+// fileMove represents a file which is moved from one path to another.
+type fileMove struct {
+	from, to string
+}
+
+// getArchivePath returns the path of the binary of the given archived pipeline.
+func getArchivePath(p *gaia.Pipeline) string {
+	return filepath.Join(gaia.Cfg.HomePath, archiveFolder, filepath.Base(p.ExecPath))
+}
+
+// archiveMoves returns all binaries of the given pipeline with their
+// path in the archive folder. The binary for the host of gaia comes
+// first, followed by the binaries of the additional platforms and the
+// shadow binary.
+func archiveMoves(p *gaia.Pipeline) []fileMove {
+	moves := []fileMove{{from: p.ExecPath, to: getArchivePath(p)}}
+	for _, path := range p.Binaries {
+		moves = append(moves, fileMove{
+			from: path,
+			to:   filepath.Join(gaia.Cfg.HomePath, archiveFolder, platformsFolder, appendTypeToName(p.Name, p.Type), filepath.Base(path)),
+		})
+	}
+	if p.ShadowExecPath != "" {
+		moves = append(moves, fileMove{
+			from: p.ShadowExecPath,
+			to:   filepath.Join(gaia.Cfg.HomePath, archiveFolder, shadowFolder, filepath.Base(p.ShadowExecPath)),
+		})
+	}
+	return moves
+}
+
+// reverseMoves returns the given moves in reverse direction.
+func reverseMoves(moves []fileMove) []fileMove {
+	reversed := make([]fileMove, len(moves))
+	for i, m := range moves {
+		reversed[i] = fileMove{from: m.to, to: m.from}
+	}
+	return reversed
+}
+
+// moveFiles moves all given files. Missing files are skipped. If one
+// file cannot be moved, the files moved before are moved back.
+func moveFiles(moves []fileMove) error {
+	for i, m := range moves {
+		err := os.MkdirAll(filepath.Dir(m.to), 0700)
+		if err == nil {
+			err = os.Rename(m.from, m.to)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			for _, done := range reverseMoves(moves[:i]) {
+				os.Rename(done.from, done.to)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchivePipeline archives the pipeline with the given id. All binaries
+// are moved to the archive folder and the pipeline is removed from the
+// active pipelines. Its schedules are disabled but its runs are kept
+// until it is unarchived. Returns nil if the pipeline was not found.
+func ArchivePipeline(id int) (*gaia.Pipeline, error) {
+	p, err := storeService.PipelineGet(id)
+	if err != nil {
+		return nil, err
+	} else if p.Name == "" {
+		return nil, nil
+	} else if p.Deleted {
+		return nil, errPipelineDeleted
+	} else if p.Archived {
+		return nil, errPipelineArchived
+	}
+
+	// Move binaries out of the active folders so the ticker ignores
+	// them and no shadow run can be started
+	if err = moveFiles(archiveMoves(p)); err != nil {
+		return nil, err
+	}
+
+	p.Archived = true
+	p.ArchiveDate = time.Now()
+	if err = storeService.PipelineUpdate(p); err != nil {
+		return nil, err
+	}
+	GlobalActivePipelines.Remove(p.Name)
+
+	return p, nil
+}
+
+// UnarchivePipeline restores the archived pipeline with the given id.
+// The ticker picks up the restored binaries afterwards.
+// Returns nil if the pipeline was not found.
+func UnarchivePipeline(id int) (*gaia.Pipeline, error) {
+	p, err := storeService.PipelineGet(id)
+	if err != nil {
+		return nil, err
+	} else if p.Name == "" {
+		return nil, nil
+	} else if !p.Archived {
+		return nil, errPipelineNotArchived
+	}
+
+	if _, err = os.Stat(p.ExecPath); err == nil {
+		return nil, errPipelineBinaryExists
+	}
+	if err = moveFiles(reverseMoves(archiveMoves(p))); err != nil {
+		return nil, err
+	}
+
+	p.Archived = false
+	p.ArchiveDate = time.Time{}
+	return p, storeService.PipelineUpdate(p)
+}
+
+// removeArchivedBinaries removes all archived binaries of the given pipeline.
+func removeArchivedBinaries(p *gaia.Pipeline) error {
+	for _, m := range archiveMoves(p) {
+		if err := os.Remove(m.to); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetArchivedPipelines returns all archived pipelines.
+func GetArchivedPipelines() ([]gaia.Pipeline, error) {
+	pipelines, err := storeService.PipelineGetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	archived := []gaia.Pipeline{}
+	for _, p := range pipelines {
+		if p.Archived {
+			archived = append(archived, p)
+		}
+	}
+	return archived, nil
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestArchivePipeline(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestArchivePipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{
+		Logger:       hclog.NewNullLogger(),
+		HomePath:     tmp,
+		DataPath:     tmp,
+		PipelinePath: filepath.Join(tmp, "pipelines"),
+	}
+	gaia.Cfg.Bolt.Mode = 0600
+
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer storeService.Close()
+	GlobalActivePipelines = NewActivePipelines()
+
+	p := &gaia.Pipeline{Name: "archive", Type: gaia.PTypeGolang}
+	p.ExecPath = filepath.Join(gaia.Cfg.PipelinePath, appendTypeToName(p.Name, p.Type))
+	p.Binaries = map[string]string{"linux/arm64": getPlatformBinaryDest(p, "linux/arm64")}
+	p.ShadowExecPath = filepath.Join(tmp, shadowFolder, appendTypeToName(p.Name, p.Type))
+	binaries := []string{p.ExecPath, p.Binaries["linux/arm64"], p.ShadowExecPath}
+	for _, path := range binaries {
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = storeService.PipelinePut(p); err != nil {
+		t.Fatal(err)
+	}
+	GlobalActivePipelines.Append(*p)
+
+	// Every binary leaves the active folders
+	if p, err = ArchivePipeline(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Archived || GlobalActivePipelines.Contains(p.Name) {
+		t.Fatalf("expected inactive archived pipeline, got %+v", p)
+	}
+	for _, path := range binaries {
+		if _, err = os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("binary %s has not been archived", path)
+		}
+	}
+	if _, err = ArchivePipeline(p.ID); err != errPipelineArchived {
+		t.Fatalf("expected error %v, got %v", errPipelineArchived, err)
+	}
+
+	// Every binary is restored
+	if p, err = UnarchivePipeline(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if p.Archived {
+		t.Fatal("expected unarchived pipeline")
+	}
+	for _, path := range binaries {
+		if content, err := ioutil.ReadFile(path); err != nil || string(content) != path {
+			t.Fatalf("binary %s has not been restored: %v", path, err)
+		}
+	}
+	if _, err = UnarchivePipeline(p.ID); err != errPipelineNotArchived {
+		t.Fatalf("expected error %v, got %v", errPipelineNotArchived, err)
+	}
+
+	// A new binary with the same name blocks the unarchive
+	if _, err = ArchivePipeline(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(p.ExecPath, []byte("new"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = UnarchivePipeline(p.ID); err != errPipelineBinaryExists {
+		t.Fatalf("expected error %v, got %v", errPipelineBinaryExists, err)
+	}
+	os.Remove(p.ExecPath)
+
+	// Deleted archived pipelines can be restored with all binaries
+	if _, err = DeletePipeline(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = RestorePipeline(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range binaries {
+		if _, err = os.Stat(path); err != nil {
+			t.Fatalf("binary %s has not been restored: %v", path, err)
+		}
+	}
+
+	// Unknown pipelines
+	if p, err = ArchivePipeline(99); p != nil || err != nil {
+		t.Fatalf("expected no pipeline, got %+v (%v)", p, err)
+	}
+}
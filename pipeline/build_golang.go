@@ -2,10 +2,14 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/gaia-pipeline/gaia"
@@ -16,9 +20,20 @@ const (
 	golangBinaryName = "go"
 	golangFolder     = "golang"
 	srcFolder        = "src"
+
+	// platformsFolder holds the binaries of additional platforms.
+	platformsFolder = "platforms"
 )
 
-var execCommandContext = exec.CommandContext
+var (
+	execCommandContext = exec.CommandContext
+
+	// errInvalidPlatform is thrown when a platform is not given as os/arch.
+	errInvalidPlatform = errors.New("invalid or duplicated platform given. Must be os/arch like linux/arm64")
+
+	// platformPattern matches a platform like linux/arm64.
+	platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+$`)
+)
 
 // BuildPipelineGolang is the real implementation of BuildPipeline for golang
 type BuildPipelineGolang struct {
@@ -60,13 +75,56 @@ func (b *BuildPipelineGolang) ExecuteBuild(p *gaia.CreatePipeline) error {
 		"./...",
 	}
 
-	env := append(os.Environ(), "GOPATH="+goPath)
+	// Dependencies are resolved into the build environment of the
+	// dependency files if the repo has any. Unchanged dependencies
+	// are not resolved again.
+	gopath := goPath
+	depRoots := []string{filepath.Join(goPath, srcFolder)}
+	cache, err := openBuildCache(p.Pipeline.Type, p.Pipeline.Repo.LocalDest)
+	if err != nil {
+		gaia.Cfg.Logger.Warn("cannot open build environment", "error", err.Error())
+	} else if cache != nil {
+		defer cache.release()
+		gopath = cache.Dir + string(os.PathListSeparator) + goPath
+		depRoots = append([]string{filepath.Join(cache.Dir, srcFolder)}, depRoots...)
+	}
+
+	env := append(os.Environ(), "GOPATH="+gopath)
+	env = append(env, tlsEnv()...)
+	buildEnv := env
+
+	// Make the shared job libraries resolvable. Dependencies are downloaded
+	// into the first GOPATH entry, libraries take precedence during build.
+	libPath, err := prepareLibraries(&p.Pipeline)
+	if err != nil {
+		gaia.Cfg.Logger.Debug("cannot prepare libraries", "error", err.Error())
+		p.Output = err.Error()
+		return err
+	}
+	if libPath != "" {
+		defer os.RemoveAll(libPath)
+		sep := string(os.PathListSeparator)
+		env = append(os.Environ(), "GOPATH="+gopath+sep+libPath)
+		env = append(env, tlsEnv()...)
+		buildEnv = append(os.Environ(), "GOPATH="+libPath+sep+gopath)
+		buildEnv = append(buildEnv, tlsEnv()...)
+	}
 
 	// Execute and wait until finish or timeout
-	output, err := executeCmd(path, args, env, p.Pipeline.Repo.LocalDest)
+	getDependencies := func() error {
+		output, err := executeCmd(path, args, env, p.Pipeline.Repo.LocalDest)
+		if err != nil {
+			gaia.Cfg.Logger.Debug("cannot get dependencies", "error", err.Error(), "output", string(output))
+			p.Output = string(output)
+		}
+		return err
+	}
+	if cache != nil {
+		err = cache.prepare(getDependencies)
+	} else {
+		err = getDependencies()
+	}
 	if err != nil {
-		gaia.Cfg.Logger.Debug("cannot get dependencies", "error", err.Error(), "output", string(output))
-		p.Output = string(output)
 		return err
 	}
 
@@ -78,16 +136,110 @@ func (b *BuildPipelineGolang) ExecuteBuild(p *gaia.CreatePipeline) error {
 	}
 
 	// Execute and wait until finish or timeout
-	output, err = executeCmd(path, args, env, p.Pipeline.Repo.LocalDest)
+	output, err := executeCmd(path, args, buildEnv, p.Pipeline.Repo.LocalDest)
 	p.Output = string(output)
 	if err != nil {
 		gaia.Cfg.Logger.Debug("cannot build pipeline", "error", err.Error(), "output", string(output))
+
+		// A broken environment must not break the following builds
+		if cache != nil && cache.Hit && cache.corrupted(output) {
+			cache.invalidate()
+		}
 		return err
 	}
 
+	// Cross compile the binaries of the additional platforms. Shadow
+	// builds are only executed on the host.
+	if !p.Shadow {
+		for _, platform := range p.Pipeline.Platforms {
+			if platform == HostPlatform() {
+				continue
+			}
+			goos, goarch := splitPlatform(platform)
+			args = []string{
+				"build",
+				"-o",
+				platformBinaryName(&p.Pipeline, platform),
+			}
+			platformEnv := append(buildEnv, "GOOS="+goos, "GOARCH="+goarch, "CGO_ENABLED=0")
+			output, err = executeCmd(path, args, platformEnv, p.Pipeline.Repo.LocalDest)
+			if err != nil {
+				gaia.Cfg.Logger.Debug("cannot build pipeline for platform", "error", err.Error(), "platform", platform, "output", string(output))
+				p.Output = string(output)
+				return err
+			}
+		}
+	}
+
+	// Remember the toolchain for the build metadata
+	if output, err = executeCmd(path, []string{"version"}, buildEnv, p.Pipeline.Repo.LocalDest); err == nil {
+		if p.Metadata == nil {
+			p.Metadata = &gaia.BuildMetadata{}
+		}
+		p.Metadata.Toolchain = strings.TrimSpace(string(output))
+	}
+	if cache != nil && cache.Hit {
+		if p.Metadata == nil {
+			p.Metadata = &gaia.BuildMetadata{}
+		}
+		p.Metadata.CacheHit = true
+	}
+
+	// Collect the dependencies for the dependency policy. Shared job
+	// libraries are not third-party dependencies. The build fails if
+	// they are unknown so the policy cannot be bypassed.
+	p.Dependencies, err = listGolangDependencies(path, buildEnv, p.Pipeline.Repo.LocalDest, depRoots)
+	if err != nil {
+		p.Output += "\ncannot list dependencies: " + err.Error()
+		return err
+	}
 	return nil
 }
 
+// listGolangDependencies returns the third-party dependencies of the
+// pipeline in the given folder. Only dependencies below the given
+// src folders and vendored dependencies are returned.
+func listGolangDependencies(path string, env []string, dir string, srcs []string) ([]gaia.Dependency, error) {
+	// Get the import paths of all dependencies
+	output, err := executeCmd(path, []string{"list", "-f", `{{join .Deps "\n"}}`, "./..."}, env, dir)
+	if err != nil {
+		gaia.Cfg.Logger.Warn("cannot list dependencies", "error", err.Error(), "output", string(output))
+		return nil, err
+	}
+	imports := strings.Fields(string(output))
+	if len(imports) == 0 {
+		return nil, nil
+	}
+
+	// Get the folders of all dependencies which are not part of the standard library
+	args := append([]string{"list", "-e", "-f", "{{if not .Standard}}{{.Dir}}{{end}}"}, imports...)
+	output, err = executeCmd(path, args, env, dir)
+	if err != nil {
+		gaia.Cfg.Logger.Warn("cannot list dependencies", "error", err.Error(), "output", string(output))
+		return nil, err
+	}
+
+	sep := string(filepath.Separator)
+	deps := []gaia.Dependency{}
+	seen := map[string]bool{}
+	for _, pkgDir := range strings.Split(string(output), "\n") {
+		pkgDir = strings.TrimSpace(pkgDir)
+
+		// Skip the packages of the pipeline itself
+		if strings.HasPrefix(pkgDir+sep, dir+sep) && !strings.Contains(strings.TrimPrefix(pkgDir, dir)+sep, sep+vendorFolder+sep) {
+			continue
+		}
+
+		dep, ok := resolveDependency(pkgDir, srcs)
+		if !ok || seen[dep.Path] {
+			continue
+		}
+		seen[dep.Path] = true
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
 // executeCmd wraps a context around the command and executes it.
 func executeCmd(path string, args []string, env []string, dir string) ([]byte, error) {
 	// Create context with timeout
@@ -103,12 +255,27 @@ func executeCmd(path string, args []string, env []string, dir string) ([]byte, e
 	return cmd.CombinedOutput()
 }
 
+// tlsEnv returns the environment which makes go and git trust the
+// configured certificate authorities while dependencies are fetched.
+// Both tools use the bundle instead of the system authorities, so the
+// bundle holds the system authorities as well.
+func tlsEnv() []string {
+	env := []string{}
+	if gaia.Cfg.CABundle != "" {
+		env = append(env, "SSL_CERT_FILE="+gaia.Cfg.CABundle, "GIT_SSL_CAINFO="+gaia.Cfg.CABundle)
+	}
+	if gaia.Cfg.ClientCert != "" {
+		env = append(env, "GIT_SSL_CERT="+gaia.Cfg.ClientCert, "GIT_SSL_KEY="+gaia.Cfg.ClientKey)
+	}
+	return env
+}
+
 // CopyBinary copies the final compiled archive to the
 // destination folder.
 func (b *BuildPipelineGolang) CopyBinary(p *gaia.CreatePipeline) error {
 	// Define src and destination
 	src := filepath.Join(p.Pipeline.Repo.LocalDest, appendTypeToName(p.Pipeline.Name, p.Pipeline.Type))
-	dest := filepath.Join(gaia.Cfg.PipelinePath, appendTypeToName(p.Pipeline.Name, p.Pipeline.Type))
+	dest := getBinaryDest(p)
 
 	// Copy binary
 	if err := copyFileContents(src, dest); err != nil {
@@ -116,7 +283,71 @@ func (b *BuildPipelineGolang) CopyBinary(p *gaia.CreatePipeline) error {
 	}
 
 	// Set +x (execution right) for pipeline
-	return os.Chmod(dest, 0766)
+	if err := os.Chmod(dest, 0766); err != nil {
+		return err
+	}
+	if p.Shadow {
+		return nil
+	}
+
+	// Copy the binaries of the additional platforms
+	p.Pipeline.Binaries = nil
+	for _, platform := range p.Pipeline.Platforms {
+		if platform == HostPlatform() {
+			continue
+		}
+		src = filepath.Join(p.Pipeline.Repo.LocalDest, platformBinaryName(&p.Pipeline, platform))
+		dest = getPlatformBinaryDest(&p.Pipeline, platform)
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return err
+		}
+		if err := copyFileContents(src, dest); err != nil {
+			return err
+		}
+		if err := os.Chmod(dest, 0766); err != nil {
+			return err
+		}
+		if p.Pipeline.Binaries == nil {
+			p.Pipeline.Binaries = map[string]string{}
+		}
+		p.Pipeline.Binaries[platform] = dest
+	}
+	return nil
+}
+
+// ValidatePlatforms checks the given additional platforms of a pipeline.
+func ValidatePlatforms(platforms []string) error {
+	seen := map[string]bool{}
+	for _, platform := range platforms {
+		if !platformPattern.MatchString(platform) || seen[platform] {
+			return errInvalidPlatform
+		}
+		seen[platform] = true
+	}
+	return nil
+}
+
+// HostPlatform returns the platform gaia runs on.
+func HostPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// splitPlatform returns the os and the architecture of the given platform.
+func splitPlatform(platform string) (string, string) {
+	parts := strings.SplitN(platform, "/", 2)
+	return parts[0], parts[1]
+}
+
+// platformBinaryName returns the name of the binary of the given
+// pipeline for the given platform, e.g. mypipeline_golang.linux_arm64.
+func platformBinaryName(p *gaia.Pipeline, platform string) string {
+	return appendTypeToName(p.Name, p.Type) + "." + strings.Replace(platform, "/", "_", 1)
+}
+
+// getPlatformBinaryDest returns the path of the binary of the given
+// pipeline for the given platform.
+func getPlatformBinaryDest(p *gaia.Pipeline, platform string) string {
+	return filepath.Join(gaia.Cfg.HomePath, platformsFolder, appendTypeToName(p.Name, p.Type), strings.Replace(platform, "/", "_", 1))
 }
 
 // copyFileContents copies the content from source to destination.
@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/wasm"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	wasmFolder = "wasm"
+
+	// wasmModuleName is the module a repository without build
+	// manifest must contain.
+	wasmModuleName = "pipeline.wasm"
+
+	// cargoBinaryName and cargoManifest build rust pipelines.
+	cargoBinaryName = "cargo"
+	cargoManifest   = "Cargo.toml"
+
+	// wasmTarget is the target modules are compiled for.
+	wasmTarget = "wasm32-unknown-unknown"
+)
+
+var (
+	// errNoWASMModule is thrown when a repository has neither a
+	// module nor a build manifest.
+	errNoWASMModule = errors.New("repository has no " + wasmModuleName + " and no " + cargoManifest)
+)
+
+// cargoMetadata is the part of the output of cargo metadata which
+// describes the packages of the build.
+type cargoMetadata struct {
+	Packages []struct {
+		ID      string  `json:"id"`
+		Name    string  `json:"name"`
+		Version string  `json:"version"`
+		License string  `json:"license"`
+		Source  *string `json:"source"`
+	} `json:"packages"`
+}
+
+// BuildPipelineWASM is the implementation of BuildPipeline for
+// experimental webassembly pipelines. Rust repositories are compiled,
+// other repositories must contain the compiled module.
+type BuildPipelineWASM struct {
+	Type gaia.PipelineType
+}
+
+// PrepareEnvironment prepares the environment before we start the build process.
+func (b *BuildPipelineWASM) PrepareEnvironment(p *gaia.CreatePipeline) error {
+	// create uuid for destination folder
+	uuid := uuid.Must(uuid.NewV4(), nil)
+
+	// Create local temp folder for clone
+	cloneFolder := filepath.Join(gaia.Cfg.HomePath, tmpFolder, wasmFolder, uuid.String())
+	err := os.MkdirAll(cloneFolder, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Set new generated path in pipeline obj for later usage
+	p.Pipeline.Repo.LocalDest = cloneFolder
+	return nil
+}
+
+// ExecuteBuild compiles the module if the repository has a build
+// manifest. The module must define the jobs of the pipeline.
+func (b *BuildPipelineWASM) ExecuteBuild(p *gaia.CreatePipeline) error {
+	dir := p.Pipeline.Repo.LocalDest
+	if _, err := os.Stat(filepath.Join(dir, cargoManifest)); err == nil {
+		path, err := exec.LookPath(cargoBinaryName)
+		if err != nil {
+			gaia.Cfg.Logger.Debug("cannot find cargo executeable", "error", err.Error())
+			return err
+		}
+
+		args := []string{"build", "--release", "--target", wasmTarget}
+		output, err := executeCmd(path, args, os.Environ(), dir)
+		p.Output = string(output)
+		if err != nil {
+			gaia.Cfg.Logger.Debug("cannot build pipeline", "error", err.Error(), "output", string(output))
+			return err
+		}
+
+		// Remember the toolchain for the build metadata
+		if output, err = executeCmd(path, []string{"--version"}, os.Environ(), dir); err == nil {
+			if p.Metadata == nil {
+				p.Metadata = &gaia.BuildMetadata{}
+			}
+			p.Metadata.Toolchain = strings.TrimSpace(string(output))
+		}
+
+		// Collect the dependencies for the dependency policy
+		if p.Dependencies, err = listCargoDependencies(path, dir); err != nil {
+			p.Output += "\ncannot list dependencies: " + err.Error()
+			return err
+		}
+	} else {
+		// The dependencies of committed modules are unknown
+		p.DependenciesUnknown = true
+	}
+
+	src, err := wasmModulePath(dir)
+	if err != nil {
+		p.Output += err.Error()
+		return err
+	}
+	raw, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err = wasm.Validate(raw); err != nil {
+		gaia.Cfg.Logger.Debug("invalid pipeline module", "error", err.Error(), "module", src)
+		p.Output += err.Error()
+		return err
+	}
+	return nil
+}
+
+// listCargoDependencies returns the third-party crates of the rust
+// pipeline in the given folder. Crates of the repository itself have
+// no source and are left out.
+func listCargoDependencies(path, dir string) ([]gaia.Dependency, error) {
+	output, err := executeCmd(path, []string{"metadata", "--format-version", "1", "--locked"}, os.Environ(), dir)
+	if err != nil {
+		gaia.Cfg.Logger.Warn("cannot list dependencies", "error", err.Error(), "output", string(output))
+		return nil, err
+	}
+	metadata := cargoMetadata{}
+	if err = json.Unmarshal(output, &metadata); err != nil {
+		return nil, err
+	}
+
+	deps := []gaia.Dependency{}
+	for _, pkg := range metadata.Packages {
+		if pkg.Source == nil {
+			continue
+		}
+		license := pkg.License
+		if license == "" {
+			license = licenseUnknown
+		}
+		deps = append(deps, gaia.Dependency{Path: pkg.Name, Version: pkg.Version, License: license})
+	}
+	return deps, nil
+}
+
+// wasmModulePath returns the path of the module in the given repository.
+// Compiled modules take precedence over a committed module.
+func wasmModulePath(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "target", wasmTarget, "release", "*.wasm"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	} else if len(matches) > 1 {
+		return "", errors.New("build produced more than one module")
+	}
+
+	path := filepath.Join(dir, wasmModuleName)
+	if _, err = os.Stat(path); err != nil {
+		return "", errNoWASMModule
+	}
+	return path, nil
+}
+
+// CopyBinary copies the module to the destination folder.
+// Modules are executed by gaia itself and need no platform binaries.
+func (b *BuildPipelineWASM) CopyBinary(p *gaia.CreatePipeline) error {
+	src, err := wasmModulePath(p.Pipeline.Repo.LocalDest)
+	if err != nil {
+		return err
+	}
+	dest := getBinaryDest(p)
+	if err = copyFileContents(src, dest); err != nil {
+		return err
+	}
+	p.Pipeline.Binaries = nil
+	return os.Chmod(dest, 0644)
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestWASMModulePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestWASMModulePath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = wasmModulePath(dir); err != errNoWASMModule {
+		t.Fatalf("expected %v, got %v", errNoWASMModule, err)
+	}
+
+	committed := filepath.Join(dir, wasmModuleName)
+	if err = ioutil.WriteFile(committed, []byte("\x00asm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := wasmModulePath(dir); path != committed {
+		t.Fatalf("expected %s, got %s", committed, path)
+	}
+
+	// Compiled modules take precedence
+	release := filepath.Join(dir, "target", wasmTarget, "release")
+	if err = os.MkdirAll(release, 0700); err != nil {
+		t.Fatal(err)
+	}
+	compiled := filepath.Join(release, "mypipeline.wasm")
+	if err = ioutil.WriteFile(compiled, []byte("\x00asm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := wasmModulePath(dir); path != compiled {
+		t.Fatalf("expected %s, got %s", compiled, path)
+	}
+}
+
+func TestListCargoDependencies(t *testing.T) {
+	execCommandContext = fakeExecCommandContext
+	defer func() {
+		execCommandContext = exec.CommandContext
+		os.Unsetenv("GO_WANT_HELPER_PROCESS")
+		os.Unsetenv("STDOUT")
+		os.Unsetenv("EXIT_STATUS")
+	}()
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger()}
+	os.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	os.Setenv("STDOUT", `{"packages": [
+		{"id": "pipeline 0.1.0", "name": "pipeline", "version": "0.1.0", "license": "MIT", "source": null},
+		{"id": "serde 1.0.0", "name": "serde", "version": "1.0.0", "license": "MIT OR Apache-2.0", "source": "registry+https://github.com/rust-lang/crates.io-index"},
+		{"id": "private 0.2.0", "name": "private", "version": "0.2.0", "license": null, "source": "git+https://example.com/private"}
+	]}`)
+
+	// Crates of the repository itself are no dependencies
+	deps, err := listCargoDependencies("cargo", os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 2 || deps[0].Path != "serde" || deps[0].Version != "1.0.0" || deps[1].License != licenseUnknown {
+		t.Fatalf("unexpected dependencies %+v", deps)
+	}
+
+	// Builds fail if the dependencies cannot be listed
+	os.Setenv("EXIT_STATUS", "1")
+	if _, err = listCargoDependencies("cargo", os.TempDir()); err == nil {
+		t.Fatal("expected error when cargo metadata fails")
+	}
+	if _, err = listGolangDependencies("go", os.Environ(), os.TempDir(), nil); err == nil {
+		t.Fatal("expected error when go list fails")
+	}
+}
+
+func TestCheckDependencyPolicyUnknownDependencies(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestCheckDependencyPolicyUnknownDependencies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: tmp, DataPath: tmp}
+	gaia.Cfg.Bolt.Mode = 0600
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer storeService.Close()
+
+	// Committed modules pass as long as there is no policy
+	p := &gaia.CreatePipeline{Pipeline: gaia.Pipeline{Name: "module", Type: gaia.PTypeWASM}, DependenciesUnknown: true}
+	if _, err = checkDependencyPolicy(p); err != nil {
+		t.Fatal(err)
+	}
+	if err = storeService.PolicyPut(&gaia.DependencyPolicy{ForbiddenLicenses: []string{"agpl-3.0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = checkDependencyPolicy(p); err != errUnknownDependencies {
+		t.Fatalf("expected error %v, got %v", errUnknownDependencies, err)
+	}
+}
@@ -0,0 +1,233 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// buildCacheFolder is the folder in the home folder which holds the
+	// prepared build environments.
+	buildCacheFolder = "buildcache"
+
+	// buildCacheMarker is the file which marks a completely prepared
+	// build environment. Its modification time is the last usage.
+	buildCacheMarker = ".prepared"
+
+	// buildCacheRetention is the duration unused build environments
+	// are kept.
+	buildCacheRetention = 14 * 24 * time.Hour
+)
+
+var (
+	// dependencyFiles are the files which pin the dependencies of a repo.
+	// Only the golang builder resolves dependencies into a build
+	// environment.
+	dependencyFiles = []string{"go.sum", "Gopkg.lock", "glide.lock"}
+
+	// buildCacheLocks holds the lock of every build environment by key.
+	// Builds hold the read lock while they use the environment, the
+	// preparation and the removal hold the write lock.
+	buildCacheLocks = map[string]*sync.RWMutex{}
+
+	// buildCacheLock protects buildCacheLocks.
+	buildCacheLock sync.Mutex
+)
+
+// buildCache is a prepared build environment. Environments are keyed
+// by the hash of the dependency files of a repo. Builds with unchanged
+// dependencies share the environment and skip the dependency resolution.
+type buildCache struct {
+	// Dir is the folder the dependencies are resolved into.
+	Dir string
+
+	// Hit is true if the environment has been prepared before.
+	Hit bool
+
+	lock *sync.RWMutex
+}
+
+// openBuildCache returns the build environment of the repo in the given
+// folder. Returns nil if the repo has no dependency files. The returned
+// environment is in use until it is released.
+func openBuildCache(t gaia.PipelineType, dir string) (*buildCache, error) {
+	key, err := buildCacheKey(t, dir)
+	if err != nil || key == "" {
+		return nil, err
+	}
+
+	c := &buildCache{Dir: filepath.Join(gaia.Cfg.HomePath, buildCacheFolder, key), lock: buildCacheKeyLock(key)}
+	c.lock.RLock()
+	if c.prepared() {
+		c.Hit = true
+		return c, nil
+	}
+	if err = os.MkdirAll(c.Dir, 0700); err != nil {
+		c.release()
+		return nil, err
+	}
+	return c, nil
+}
+
+// buildCacheKeyLock returns the lock of the environment with the given key.
+func buildCacheKeyLock(key string) *sync.RWMutex {
+	buildCacheLock.Lock()
+	defer buildCacheLock.Unlock()
+
+	l, ok := buildCacheLocks[key]
+	if !ok {
+		l = &sync.RWMutex{}
+		buildCacheLocks[key] = l
+	}
+	return l
+}
+
+// release marks the environment as no longer used by the build.
+func (c *buildCache) release() {
+	c.lock.RUnlock()
+}
+
+// exclusive runs the given function while no other build uses the
+// environment.
+func (c *buildCache) exclusive(f func()) {
+	c.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.RLock()
+	defer c.lock.Unlock()
+	f()
+}
+
+// buildCacheKey returns the hash of the dependency files of the repo
+// in the given folder. Returns an empty key if there are none.
+func buildCacheKey(t gaia.PipelineType, dir string) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(t))
+	found := false
+	for _, name := range dependencyFiles {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		found = true
+		fmt.Fprintf(h, "\x00%s\x00%d\x00", name, len(content))
+		h.Write(content)
+	}
+	if !found {
+		return "", nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// prepared returns true if the environment is completely prepared.
+// The usage is recorded.
+func (c *buildCache) prepared() bool {
+	marker := filepath.Join(c.Dir, buildCacheMarker)
+	if _, err := os.Stat(marker); err != nil {
+		return false
+	}
+	now := time.Now()
+	os.Chtimes(marker, now, now)
+	return true
+}
+
+// prepare resolves the dependencies with the given function unless the
+// environment has been prepared before. Environments which could not
+// be prepared are removed.
+func (c *buildCache) prepare(resolve func() error) (err error) {
+	if c.Hit {
+		return nil
+	}
+
+	c.exclusive(func() {
+		// Another build may have prepared the environment meanwhile
+		if c.prepared() {
+			c.Hit = true
+			return
+		}
+		if err = resolve(); err != nil {
+			c.remove()
+			return
+		}
+		if err = ioutil.WriteFile(filepath.Join(c.Dir, buildCacheMarker), nil, 0600); err != nil {
+			return
+		}
+		pruneBuildCache(time.Now().Add(-buildCacheRetention), c.Dir)
+	})
+	return
+}
+
+// corrupted returns true if the given output of a failed build points
+// to a broken environment, e.g. files of a dependency which have been
+// removed or truncated. Failures in the code of the pipeline itself do
+// not reference the environment.
+func (c *buildCache) corrupted(output []byte) bool {
+	return bytes.Contains(output, []byte(c.Dir)) || bytes.Contains(output, []byte("checksum mismatch"))
+}
+
+// invalidate removes the environment once no other build uses it.
+// The next build with the same dependencies prepares it again.
+func (c *buildCache) invalidate() {
+	c.exclusive(c.remove)
+}
+
+// remove removes the environment. The caller holds the write lock.
+func (c *buildCache) remove() {
+	if err := removeBuildCache(c.Dir); err != nil {
+		gaia.Cfg.Logger.Warn("cannot remove build environment", "error", err.Error(), "path", c.Dir)
+	}
+}
+
+// pruneBuildCache removes the build environments which have not been
+// used since the given time. The given environment is skipped, its
+// lock is held by the caller.
+func pruneBuildCache(before time.Time, skip string) {
+	dirs, err := filepath.Glob(filepath.Join(gaia.Cfg.HomePath, buildCacheFolder, "*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if dir == skip || !buildCacheUnused(dir, before) {
+			continue
+		}
+
+		// Check again once no build uses the environment
+		l := buildCacheKeyLock(filepath.Base(dir))
+		l.Lock()
+		if buildCacheUnused(dir, before) {
+			if err = removeBuildCache(dir); err != nil {
+				gaia.Cfg.Logger.Warn("cannot remove build environment", "error", err.Error(), "path", dir)
+			}
+		}
+		l.Unlock()
+	}
+}
+
+// buildCacheUnused returns true if the given environment has not been
+// used since the given time.
+func buildCacheUnused(dir string, before time.Time) bool {
+	info, err := os.Stat(filepath.Join(dir, buildCacheMarker))
+	return err == nil && info.ModTime().Before(before)
+}
+
+// removeBuildCache removes the given environment. Package managers
+// like go keep their downloads read-only.
+func removeBuildCache(dir string) error {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(path, 0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(dir)
+}
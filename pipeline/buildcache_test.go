@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestBuildCacheKey(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestBuildCacheKey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	key, err := buildCacheKey(gaia.PTypeGolang, tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		t.Fatalf("expected no key without dependency files but got %s", key)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(tmp, "go.sum"), []byte("a v1.0.0"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := buildCacheKey(gaia.PTypeGolang, tmp)
+	second, _ := buildCacheKey(gaia.PTypeGolang, tmp)
+	if first == "" || first != second {
+		t.Fatalf("expected stable key but got %s and %s", first, second)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(tmp, "go.sum"), []byte("a v1.1.0"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	changed, _ := buildCacheKey(gaia.PTypeGolang, tmp)
+	if changed == first {
+		t.Fatal("expected key to change with the dependencies")
+	}
+}
+
+func TestBuildCachePrepare(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestBuildCachePrepare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = new(gaia.Config)
+	gaia.Cfg.HomePath = tmp
+	gaia.Cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Level:  hclog.Trace,
+		Output: hclog.DefaultOutput,
+		Name:   "Gaia",
+	})
+
+	repo := filepath.Join(tmp, "repo")
+	if err := os.MkdirAll(repo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(repo, "go.sum"), []byte("a v1.0.0"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed resolution must not leave a prepared environment
+	c, err := openBuildCache(gaia.PTypeGolang, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.prepare(func() error { return errors.New("failed") }); err == nil {
+		t.Fatal("expected error from resolution")
+	}
+	c.release()
+
+	resolved := 0
+	resolve := func() error {
+		resolved++
+		return nil
+	}
+	for i := 0; i < 2; i++ {
+		c, err = openBuildCache(gaia.PTypeGolang, repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Hit != (i > 0) {
+			t.Fatalf("unexpected cache hit %t in build %d", c.Hit, i)
+		}
+		if err := c.prepare(resolve); err != nil {
+			t.Fatal(err)
+		}
+		c.release()
+	}
+	if resolved != 1 {
+		t.Fatalf("expected dependencies to be resolved once but got %d", resolved)
+	}
+
+	// Errors in the code of the pipeline keep the environment
+	c, err = openBuildCache(gaia.PTypeGolang, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.corrupted([]byte("./main.go:12:2: undefined: foo")) {
+		t.Fatal("expected compile error of the pipeline to keep the environment")
+	}
+	if !c.corrupted([]byte(filepath.Join(c.Dir, "src", "dep", "dep.go") + ":1:1: unexpected EOF")) {
+		t.Fatal("expected error in the environment to invalidate it")
+	}
+
+	// The environment is removed once the other builds released it
+	other, err := openBuildCache(gaia.PTypeGolang, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		c.invalidate()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if !other.prepared() {
+		t.Fatal("expected environment to stay while it is used")
+	}
+	other.release()
+	<-done
+	c.release()
+	if _, err := os.Stat(c.Dir); !os.IsNotExist(err) {
+		t.Fatalf("expected environment to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// sizeRegression is the growth of the binary size compared to the
+	// previous build which is reported as regression.
+	sizeRegression = 0.25
+
+	// durationRegression is the growth of the build duration compared
+	// to the previous build which is reported as regression. Builds
+	// which take less than minRegressionDuration are not reported.
+	durationRegression    = 0.5
+	minRegressionDuration = 10 * time.Second
+)
+
+// recordBuildMetadata completes the metadata of the given build which
+// compiled for the given duration and reports regressions compared to
+// the previous build of the pipeline.
+func recordBuildMetadata(p *gaia.CreatePipeline, duration time.Duration) {
+	if p.Metadata == nil {
+		p.Metadata = &gaia.BuildMetadata{}
+	}
+	p.Metadata.Duration = duration.Seconds()
+	p.Metadata.Dependencies = len(p.Dependencies)
+	if info, err := os.Stat(getBinaryDest(p)); err == nil {
+		p.Metadata.BinarySize = info.Size()
+	}
+
+	if previous := latestBuild(p.Pipeline.Name); previous != nil && previous.ID != p.ID {
+		p.Warnings = buildRegressions(previous.Metadata, p.Metadata)
+	}
+}
+
+// buildRegressions returns warnings if the binary size or the build
+// duration of the current build grew significantly.
+func buildRegressions(previous, current *gaia.BuildMetadata) []string {
+	if previous == nil {
+		return nil
+	}
+
+	var warnings []string
+	if previous.BinarySize > 0 && float64(current.BinarySize) > float64(previous.BinarySize)*(1+sizeRegression) {
+		warnings = append(warnings, fmt.Sprintf("binary size grew from %d to %d bytes", previous.BinarySize, current.BinarySize))
+	}
+	if previous.Duration > 0 && current.Duration >= minRegressionDuration.Seconds() && current.Duration > previous.Duration*(1+durationRegression) {
+		warnings = append(warnings, fmt.Sprintf("build time grew from %.1fs to %.1fs", previous.Duration, current.Duration))
+	}
+	return warnings
+}
@@ -0,0 +1,24 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+func TestBuildRegressions(t *testing.T) {
+	previous := &gaia.BuildMetadata{Duration: 20, BinarySize: 1000}
+
+	if w := buildRegressions(previous, &gaia.BuildMetadata{Duration: 25, BinarySize: 1200}); len(w) != 0 {
+		t.Fatalf("expected no regressions, got %v", w)
+	}
+	if w := buildRegressions(previous, &gaia.BuildMetadata{Duration: 31, BinarySize: 1300}); len(w) != 2 {
+		t.Fatalf("expected size and duration regressions, got %v", w)
+	}
+	if w := buildRegressions(&gaia.BuildMetadata{Duration: 2}, &gaia.BuildMetadata{Duration: 8}); len(w) != 0 {
+		t.Fatalf("expected short builds not to regress, got %v", w)
+	}
+	if w := buildRegressions(nil, &gaia.BuildMetadata{Duration: 8}); len(w) != 0 {
+		t.Fatalf("expected no regressions without previous build, got %v", w)
+	}
+}
@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// defaultBuildWorker is the number of parallel builds if no build
+	// worker have been set.
+	defaultBuildWorker = 1
+)
+
+var (
+	// buildPipeline executes the build of a queued pipeline.
+	buildPipeline = CreatePipeline
+
+	// queuedBuilds holds the builds which wait for a build worker in
+	// the order they have been queued.
+	queuedBuilds []*gaia.CreatePipeline
+
+	// runningBuilds is the number of builds which are executed.
+	runningBuilds int
+
+	// buildQueueLock protects queuedBuilds and runningBuilds.
+	buildQueueLock sync.Mutex
+)
+
+// BuildQueueEntry represents a build which waits for a build worker.
+type BuildQueueEntry struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Priority int       `json:"priority"`
+	Created  time.Time `json:"created"`
+
+	// Position is the position of the build in the queue starting at one.
+	Position int `json:"position"`
+}
+
+// EnqueueBuild queues the build of the given pipeline. Builds are
+// executed by their own workers so rebuilds never delay runs. Builds
+// with a higher priority are started first, builds with the same
+// priority in the order they have been queued.
+func EnqueueBuild(p *gaia.CreatePipeline) {
+	buildQueueLock.Lock()
+	defer buildQueueLock.Unlock()
+
+	queuedBuilds = append(queuedBuilds, p)
+	startBuilds()
+}
+
+// resumeBuilds queues the stored builds which have been queued before
+// a restart again in the order they have been queued. Builds which
+// were running have been interrupted and are marked as failed.
+func resumeBuilds() {
+	builds, err := storeService.CreatePipelineGet()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get queued builds", "error", err.Error())
+		return
+	}
+	sort.SliceStable(builds, func(i, j int) bool {
+		return builds[i].Created.Before(builds[j].Created)
+	})
+
+	for i := range builds {
+		p := &builds[i]
+		switch p.StatusType {
+		case gaia.CreatePipelineQueued:
+			EnqueueBuild(p)
+		case gaia.CreatePipelineRunning:
+			p.StatusType = gaia.CreatePipelineFailed
+			p.Output += "\nbuild has been interrupted by a restart"
+			if err = storeService.CreatePipelinePut(p); err != nil {
+				gaia.Cfg.Logger.Error("cannot put create pipeline into store", "error", err.Error())
+			}
+		}
+	}
+}
+
+// BuildQueue returns all builds which wait for a build worker in the
+// order they will be started.
+func BuildQueue() []BuildQueueEntry {
+	buildQueueLock.Lock()
+	defer buildQueueLock.Unlock()
+
+	entries := []BuildQueueEntry{}
+	for i, p := range queuedBuilds {
+		entries = append(entries, BuildQueueEntry{
+			ID:       p.ID,
+			Name:     p.Pipeline.Name,
+			Priority: p.Priority,
+			Created:  p.Created,
+			Position: i + 1,
+		})
+	}
+	return entries
+}
+
+// startBuilds starts queued builds until all build workers are busy.
+// Changed build worker settings are applied to the next build.
+// buildQueueLock must be held.
+func startBuilds() {
+	sort.SliceStable(queuedBuilds, func(i, j int) bool {
+		return queuedBuilds[i].Priority > queuedBuilds[j].Priority
+	})
+
+	for len(queuedBuilds) > 0 && runningBuilds < buildWorker() {
+		p := queuedBuilds[0]
+		queuedBuilds = queuedBuilds[1:]
+		runningBuilds++
+
+		go func() {
+			buildPipeline(p)
+
+			buildQueueLock.Lock()
+			defer buildQueueLock.Unlock()
+			runningBuilds--
+			startBuilds()
+		}()
+	}
+}
+
+// buildWorker returns the current number of build workers.
+func buildWorker() int {
+	if n := gaia.GetSettings().BuildWorker; n > 0 {
+		return n
+	}
+	return defaultBuildWorker
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestBuildQueue(t *testing.T) {
+	previous := gaia.GetSettings()
+	defer func() {
+		gaia.SetSettings(previous)
+		buildPipeline = CreatePipeline
+	}()
+	gaia.SetSettings(gaia.Settings{BuildWorker: 1})
+
+	started := make(chan string)
+	release := make(chan struct{})
+	buildPipeline = func(p *gaia.CreatePipeline) {
+		started <- p.ID
+		<-release
+	}
+
+	EnqueueBuild(&gaia.CreatePipeline{ID: "a"})
+	if id := <-started; id != "a" {
+		t.Fatalf("expected build a to be started but got %s", id)
+	}
+
+	// The only build worker is busy
+	EnqueueBuild(&gaia.CreatePipeline{ID: "b"})
+	EnqueueBuild(&gaia.CreatePipeline{ID: "c", Priority: 5})
+	EnqueueBuild(&gaia.CreatePipeline{ID: "d", Priority: 5})
+	queue := BuildQueue()
+	if len(queue) != 3 {
+		t.Fatalf("expected 3 queued builds but got %d", len(queue))
+	}
+	for i, id := range []string{"c", "d", "b"} {
+		if queue[i].ID != id || queue[i].Position != i+1 {
+			t.Fatalf("expected build %s at position %d but got %+v", id, i+1, queue[i])
+		}
+	}
+
+	// Finished builds start the next one
+	for _, id := range []string{"c", "d", "b"} {
+		release <- struct{}{}
+		if started := <-started; started != id {
+			t.Fatalf("expected build %s to be started but got %s", id, started)
+		}
+	}
+	release <- struct{}{}
+
+	if queue = BuildQueue(); len(queue) != 0 {
+		t.Fatalf("expected empty build queue but got %d builds", len(queue))
+	}
+}
+
+func TestResumeBuilds(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestResumeBuilds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: tmp, DataPath: tmp}
+	gaia.Cfg.Bolt.Mode = 0600
+
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer storeService.Close()
+
+	previous := gaia.GetSettings()
+	defer func() {
+		gaia.SetSettings(previous)
+		buildPipeline = CreatePipeline
+	}()
+	gaia.SetSettings(gaia.Settings{BuildWorker: 1})
+
+	started := make(chan string, 2)
+	buildPipeline = func(p *gaia.CreatePipeline) {
+		started <- p.ID
+	}
+
+	now := time.Now()
+	builds := []gaia.CreatePipeline{
+		{ID: "second", StatusType: gaia.CreatePipelineQueued, Created: now.Add(time.Second)},
+		{ID: "first", StatusType: gaia.CreatePipelineQueued, Created: now},
+		{ID: "interrupted", StatusType: gaia.CreatePipelineRunning, Created: now},
+		{ID: "done", StatusType: gaia.CreatePipelineSuccess, Created: now},
+	}
+	for i := range builds {
+		if err = storeService.CreatePipelinePut(&builds[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Queued builds are started again in their order
+	resumeBuilds()
+	for _, id := range []string{"first", "second"} {
+		if started := <-started; started != id {
+			t.Fatalf("expected build %s to be started but got %s", id, started)
+		}
+	}
+
+	stored, err := storeService.CreatePipelineGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range stored {
+		if p.ID == "interrupted" && p.StatusType != gaia.CreatePipelineFailed {
+			t.Fatalf("expected interrupted build to fail, got %s", p.StatusType)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/scheduler"
+)
+
+const (
+	// contractFileName is the file in the root of the repo which holds
+	// the contract of the pipeline.
+	contractFileName = "gaia.contract.json"
+
+	// maxContractSize is the maximum size of a contract in bytes.
+	maxContractSize = 256 * 1024
+)
+
+var (
+	// errContractTooLarge is thrown when a contract exceeds the maximum size.
+	errContractTooLarge = errors.New("contract must not be larger than 256KB")
+)
+
+// readContract returns the contract of the repo in the given folder.
+// Returns nil if the repo has no contract.
+func readContract(dir string) (*gaia.PipelineContract, error) {
+	path := filepath.Join(dir, contractFileName)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxContractSize {
+		return nil, errContractTooLarge
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	contract := &gaia.PipelineContract{}
+	if err = json.Unmarshal(raw, contract); err != nil {
+		return nil, err
+	}
+	if err = scheduler.ValidateContract(contract); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
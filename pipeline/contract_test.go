@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadContract(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestReadContract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	contract, err := readContract(tmp)
+	if err != nil || contract != nil {
+		t.Fatalf("expected no contract, got %v, %v", contract, err)
+	}
+
+	path := filepath.Join(tmp, contractFileName)
+	if err = ioutil.WriteFile(path, []byte(`{"params":{"type":"object","required":["env"]}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if contract, err = readContract(tmp); err != nil || contract == nil || len(contract.Params) == 0 {
+		t.Fatalf("expected params schema, got %v, %v", contract, err)
+	}
+
+	if err = ioutil.WriteFile(path, []byte(`{"params":{"type":"text"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readContract(tmp); err == nil {
+		t.Fatal("expected error for invalid schema")
+	}
+}
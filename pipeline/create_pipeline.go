@@ -2,6 +2,10 @@ package pipeline
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gaia-pipeline/gaia"
 )
@@ -21,6 +25,17 @@ const (
 // of a plugin.
 // After each step, the status is written to store and can be retrieved via API.
 func CreatePipeline(p *gaia.CreatePipeline) {
+	log := gaia.Cfg.Logger.With("correlationid", p.CorrelationID)
+
+	// Queued builds are picked up by a build worker now
+	if p.StatusType == gaia.CreatePipelineQueued {
+		p.StatusType = gaia.CreatePipelineRunning
+		if err := storeService.CreatePipelinePut(p); err != nil {
+			log.Error("cannot put create pipeline into store", "error", err.Error())
+			return
+		}
+	}
+
 	// Define build process for the given type
 	bP := newBuildPipeline(p.Pipeline.Type)
 	if bP == nil {
@@ -49,29 +64,162 @@ func CreatePipeline(p *gaia.CreatePipeline) {
 		return
 	}
 
+	// Remember the commit for the delivery metrics
+	p.Pipeline.Commit, err = gitHeadCommit(p.Pipeline.Repo.LocalDest)
+	if err != nil {
+		log.Warn("cannot get commit of pipeline repo", "error", err.Error(), "pipeline", p.Pipeline.Name)
+	} else {
+		// Issues of all commits since the previous build
+		var previous gaia.Commit
+		if existing, err := storeService.PipelineGetByName(p.Pipeline.Name); err == nil && existing != nil {
+			previous = existing.Commit
+		}
+		if previous.Hash == p.Pipeline.Commit.Hash {
+			p.Pipeline.Commit.Issues = previous.Issues
+		} else if p.Pipeline.Commit.Issues, err = gitIssueKeys(p.Pipeline.Repo.LocalDest, previous.Hash); err != nil {
+			log.Warn("cannot get issues of pipeline repo", "error", err.Error(), "pipeline", p.Pipeline.Name)
+		}
+
+		// File references in the job logs are linked to this commit
+		if err = recordSourceFiles(&p.Pipeline); err != nil {
+			log.Warn("cannot record files of pipeline repo", "error", err.Error(), "pipeline", p.Pipeline.Name)
+		}
+	}
+
+	// Synced runbooks are replaced by the readme of the repo
+	if err = syncRunbook(p); err != nil {
+		log.Warn("cannot sync runbook of pipeline", "error", err.Error(), "pipeline", p.Pipeline.Name)
+	}
+
+	// The contract is always taken from the repo
+	if p.Pipeline.Contract, err = readContract(p.Pipeline.Repo.LocalDest); err != nil {
+		p.StatusType = gaia.CreatePipelineFailed
+		p.Output = fmt.Sprintf("invalid contract %s: %s", contractFileName, err.Error())
+		storeService.CreatePipelinePut(p)
+		return
+	}
+
+	// Scan repo for committed credentials
+	var scanReport string
+	if gaia.Cfg.SecretScan == gaia.SecretScanWarn || gaia.Cfg.SecretScan == gaia.SecretScanFail {
+		findings, err := scanSecrets(p.Pipeline.Repo.LocalDest)
+		if err != nil {
+			p.StatusType = gaia.CreatePipelineFailed
+			p.Output = fmt.Sprintf("cannot scan repo for secrets: %s", err.Error())
+			storeService.CreatePipelinePut(p)
+			return
+		}
+		if len(findings) > 0 {
+			scanReport = secretScanReport(findings)
+			log.Warn("found committed credentials in pipeline repo", "pipeline", p.Pipeline.Name, "findings", len(findings))
+			if gaia.Cfg.SecretScan == gaia.SecretScanFail {
+				p.StatusType = gaia.CreatePipelineFailed
+				p.Output = scanReport
+				storeService.CreatePipelinePut(p)
+				return
+			}
+		}
+	}
+
 	// Update status of our pipeline build
 	p.Status = pipelineCloneStatus
 	err = storeService.CreatePipelinePut(p)
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot put create pipeline into store", "error", err.Error())
+		log.Error("cannot put create pipeline into store", "error", err.Error())
 		return
 	}
 
 	// Run compile process
+	buildStart := time.Now()
 	err = bP.ExecuteBuild(p)
+	buildDuration := time.Since(buildStart)
+	p.Output = scanReport + p.Output
 	if err != nil {
 		p.StatusType = gaia.CreatePipelineFailed
 		storeService.CreatePipelinePut(p)
 		return
 	}
 
+	// Enforce dependency policy
+	violations, err := checkDependencyPolicy(p)
+	if err != nil {
+		p.StatusType = gaia.CreatePipelineFailed
+		p.Output = fmt.Sprintf("cannot check dependency policy: %s", err.Error())
+		storeService.CreatePipelinePut(p)
+		return
+	}
+	if len(violations) > 0 {
+		p.StatusType = gaia.CreatePipelineFailed
+		p.Output += fmt.Sprintf("\npipeline violates the dependency policy. Request a waiver to use these dependencies:\n%s\n", strings.Join(violations, "\n"))
+		storeService.CreatePipelinePut(p)
+		return
+	}
+
 	// Update status of our pipeline build
 	p.Status = pipelineCompileStatus
 	err = storeService.CreatePipelinePut(p)
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot put create pipeline into store", "error", err.Error())
+		log.Error("cannot put create pipeline into store", "error", err.Error())
+		return
+	}
+
+	// Rebuilds of pipelines with shadow rebuilds are kept aside
+	existing, err := storeService.PipelineGetByName(p.Pipeline.Name)
+	if err != nil {
+		log.Error("cannot get pipeline from store", "error", err.Error())
 		return
 	}
+	p.Shadow = existing != nil && existing.ShadowRebuilds
+	if p.Shadow {
+		if err = os.MkdirAll(filepath.Join(gaia.Cfg.HomePath, shadowFolder), 0700); err != nil {
+			p.StatusType = gaia.CreatePipelineFailed
+			p.Output = fmt.Sprintf("cannot create shadow folder: %s", err.Error())
+			storeService.CreatePipelinePut(p)
+			return
+		}
+	}
+
+	// Pipelines imported from a template are registered before the
+	// ticker finds their binary.
+	if p.Template != "" && !p.Shadow {
+		if err = registerTemplatePipeline(p); err != nil {
+			p.StatusType = gaia.CreatePipelineFailed
+			p.Output = fmt.Sprintf("cannot register template pipeline: %s", err.Error())
+			storeService.CreatePipelinePut(p)
+			return
+		}
+	}
+
+	// Rebuilds with a smoke job keep the previous build for the rollback.
+	// A build whose smoke run is pending is not kept. Other runs are held
+	// before the binary is replaced until the smoke run succeeded.
+	var previous *gaia.Rollback
+	if !p.Shadow && existing != nil && existing.SmokeJob != "" && existing.Rollback != nil {
+		previous = existing.Rollback
+	} else if !p.Shadow && existing != nil && existing.SmokeJob != "" {
+		if previous, err = keepPreviousBuild(existing); err != nil {
+			p.StatusType = gaia.CreatePipelineFailed
+			p.Output = fmt.Sprintf("cannot keep previous binary: %s", err.Error())
+			storeService.CreatePipelinePut(p)
+			return
+		}
+	}
+	if previous != nil && existing.Rollback == nil {
+		if err = holdForSmokeRun(existing.ID, previous); err != nil {
+			removeKeptBuild(previous)
+			p.StatusType = gaia.CreatePipelineFailed
+			p.Output = fmt.Sprintf("cannot hold pipeline for smoke run: %s", err.Error())
+			storeService.CreatePipelinePut(p)
+			return
+		}
+
+		// Release the pipeline if no smoke run has been started
+		defer func() {
+			if previous.RunID == 0 {
+				releaseSmokeHold(existing.ID, previous)
+			}
+		}()
+	}
 
 	// Copy compiled binary to plugins folder
 	err = bP.CopyBinary(p)
@@ -82,12 +230,81 @@ func CreatePipeline(p *gaia.CreatePipeline) {
 		return
 	}
 
+	// Exact binary versions can be fetched from the registry
+	if err = publishBinaries(p); err != nil {
+		log.Warn("cannot publish pipeline binary", "error", err.Error(), "pipeline", p.Pipeline.Name)
+	}
+
+	// Register the shadow binary. It will be executed on the next trigger.
+	if p.Shadow {
+		_, err = UpdatePipeline(existing.ID, func(s *gaia.Pipeline) {
+			s.ShadowExecPath = getBinaryDest(p)
+			s.ShadowRunID = 0
+			s.ShadowCommit = p.Pipeline.Commit
+		})
+		if err != nil {
+			p.StatusType = gaia.CreatePipelineFailed
+			p.Output = fmt.Sprintf("cannot register shadow binary: %s", err.Error())
+			storeService.CreatePipelinePut(p)
+			return
+		}
+	}
+
+	// Report regressions of the binary size and the build time
+	recordBuildMetadata(p, buildDuration)
+	for _, warning := range p.Warnings {
+		log.Warn("pipeline build regressed", "pipeline", p.Pipeline.Name, "warning", warning)
+	}
+
 	// Set create pipeline status to complete
 	p.Status = pipelineCompleteStatus
 	p.StatusType = gaia.CreatePipelineSuccess
 	err = storeService.CreatePipelinePut(p)
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot put create pipeline into store", "error", err.Error())
+		log.Error("cannot put create pipeline into store", "error", err.Error())
 		return
 	}
+
+	// New pipelines get the build details from this build once the
+	// ticker registered them. Registered pipelines are updated.
+	if !p.Shadow {
+		if err = updateBuildDetails(p); err != nil {
+			log.Error("cannot update build details of pipeline", "error", err.Error(), "pipeline", p.Pipeline.Name)
+		}
+	}
+
+	// Verify the rebuilt binary. It is kept without a smoke run.
+	if previous != nil {
+		if err = startSmokeRun(existing.ID, previous); err != nil {
+			log.Error("cannot start smoke run of pipeline", "error", err.Error(), "pipeline", p.Pipeline.Name)
+			previous.RunID = 0
+		}
+	}
+}
+
+// updateBuildDetails applies the commit, the platform binaries and the
+// synced runbook of the given build to the registered pipeline.
+func updateBuildDetails(p *gaia.CreatePipeline) error {
+	registered, err := storeService.PipelineGetByName(p.Pipeline.Name)
+	if err != nil || registered == nil {
+		return err
+	}
+	_, err = UpdatePipeline(registered.ID, func(s *gaia.Pipeline) {
+		applyBuildDetails(s, p)
+	})
+	return err
+}
+
+// applyBuildDetails copies the details of the given build to the given pipeline.
+func applyBuildDetails(s *gaia.Pipeline, p *gaia.CreatePipeline) {
+	s.Commit = p.Pipeline.Commit
+	s.Platforms = p.Pipeline.Platforms
+	s.Binaries = p.Pipeline.Binaries
+	s.Contract = p.Pipeline.Contract
+	if p.Pipeline.Runbook != nil {
+		s.Runbook = p.Pipeline.Runbook
+	}
+	if p.Pipeline.WASMCapabilities != nil {
+		s.WASMCapabilities = p.Pipeline.WASMCapabilities
+	}
 }
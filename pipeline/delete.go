@@ -0,0 +1,160 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// trashFolder is the folder in the home folder where binaries of
+	// deleted pipelines are kept until the retention period passed.
+	trashFolder = "trash"
+)
+
+var (
+	// errPipelineDeleted is thrown when a deleted pipeline should be deleted again.
+	errPipelineDeleted = errors.New("pipeline has been already deleted")
+
+	// errPipelineNotDeleted is thrown when a pipeline which is not deleted should be restored.
+	errPipelineNotDeleted = errors.New("pipeline is not deleted")
+
+	// errPipelineBinaryExists is thrown when a deleted pipeline should be restored
+	// but a new binary with the same name exists.
+	errPipelineBinaryExists = errors.New("a pipeline with the same name exists")
+)
+
+// getTrashPath returns the path of the binary of the given deleted pipeline.
+func getTrashPath(p *gaia.Pipeline) string {
+	return filepath.Join(gaia.Cfg.HomePath, trashFolder, filepath.Base(p.ExecPath))
+}
+
+// DeletePipeline soft deletes the pipeline with the given id.
+// The binary is moved to the trash folder and the pipeline is removed
+// from the active pipelines. It can be restored until the retention
+// period passed. Returns nil if the pipeline was not found.
+func DeletePipeline(id int) (*gaia.Pipeline, error) {
+	p, err := storeService.PipelineGet(id)
+	if err != nil {
+		return nil, err
+	} else if p.Name == "" {
+		return nil, nil
+	} else if p.Deleted {
+		return nil, errPipelineDeleted
+	}
+
+	// Move binary out of the pipeline folder so the ticker ignores it
+	if err = os.MkdirAll(filepath.Join(gaia.Cfg.HomePath, trashFolder), 0700); err != nil {
+		return nil, err
+	}
+	binary := p.ExecPath
+	if p.Archived {
+		// The other archived binaries go back to their folders and are
+		// removed with the pipeline when it is purged
+		if err = moveFiles(reverseMoves(archiveMoves(p)[1:])); err != nil {
+			return nil, err
+		}
+		binary = getArchivePath(p)
+	}
+	if err = os.Rename(binary, getTrashPath(p)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	p.Deleted = true
+	p.DeleteDate = time.Now()
+	p.Archived = false
+	p.ArchiveDate = time.Time{}
+	if err = storeService.PipelineUpdate(p); err != nil {
+		return nil, err
+	}
+	GlobalActivePipelines.Remove(p.Name)
+
+	return p, nil
+}
+
+// RestorePipeline restores the deleted pipeline with the given id.
+// The ticker picks up the restored binary afterwards.
+// Returns nil if the pipeline was not found.
+func RestorePipeline(id int) (*gaia.Pipeline, error) {
+	p, err := storeService.PipelineGet(id)
+	if err != nil {
+		return nil, err
+	} else if p.Name == "" {
+		return nil, nil
+	} else if !p.Deleted {
+		return nil, errPipelineNotDeleted
+	}
+
+	if _, err = os.Stat(p.ExecPath); err == nil {
+		return nil, errPipelineBinaryExists
+	}
+	if err = os.Rename(getTrashPath(p), p.ExecPath); err != nil {
+		return nil, err
+	}
+
+	p.Deleted = false
+	p.DeleteDate = time.Time{}
+	return p, storeService.PipelineUpdate(p)
+}
+
+// GetDeletedPipelines returns all pipelines which can be restored.
+func GetDeletedPipelines() ([]gaia.Pipeline, error) {
+	pipelines, err := storeService.PipelineGetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []gaia.Pipeline{}
+	for _, p := range pipelines {
+		if p.Deleted {
+			deleted = append(deleted, p)
+		}
+	}
+	return deleted, nil
+}
+
+// purgeDeletedPipelines removes all deleted pipelines whose
+// retention period passed, including their runs and logs.
+func purgeDeletedPipelines() {
+	deleted, err := GetDeletedPipelines()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get deleted pipelines", "error", err.Error())
+		return
+	}
+
+	for _, p := range deleted {
+		if time.Since(p.DeleteDate) < gaia.GetSettings().DeleteRetention {
+			continue
+		}
+		if err := purgePipeline(&p); err != nil {
+			gaia.Cfg.Logger.Error("cannot purge deleted pipeline", "error", err.Error(), "pipeline", p.Name)
+		}
+	}
+}
+
+// purgePipeline irrevocably removes the given pipeline.
+func purgePipeline(p *gaia.Pipeline) error {
+	if err := os.Remove(getTrashPath(p)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(p.ID))); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(gaia.Cfg.HomePath, platformsFolder, appendTypeToName(p.Name, p.Type))); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(gaia.Cfg.HomePath, sourcesFolder, appendTypeToName(p.Name, p.Type))); err != nil {
+		return err
+	}
+	if err := storeService.PipelineDeleteRuns(p.ID); err != nil {
+		return err
+	}
+	if err := storeService.MetricsDelete(p.ID); err != nil {
+		return err
+	}
+	return storeService.PipelineDelete(p.ID)
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// GetDORAMetrics returns the delivery metrics of the pipeline with the
+// given id since the given date. A zero id returns the metrics of all
+// pipelines.
+func GetDORAMetrics(pipelineID int, since time.Time) (*gaia.DORAMetrics, error) {
+	var ids []int
+	if pipelineID != 0 {
+		ids = []int{pipelineID}
+	} else {
+		pipelines, err := storeService.PipelineGetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pipelines {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	d := &doraSamples{}
+	for _, id := range ids {
+		runs, err := storeService.PipelineGetAllRuns(id)
+		if err != nil {
+			return nil, err
+		}
+		d.add(runs, since)
+	}
+
+	metrics := d.metrics(since, time.Now())
+	metrics.PipelineID = pipelineID
+	return metrics, nil
+}
+
+// doraSamples collects the deployments, failures, lead times and
+// recovery times of the runs of one or more pipelines.
+type doraSamples struct {
+	deployments   int
+	failures      int
+	leadTimes     []float64
+	recoveryTimes []float64
+}
+
+// add collects the given runs of one pipeline which finished since the
+// given date. Shadow and debug runs are no deployments.
+func (d *doraSamples) add(runs []gaia.PipelineRun, since time.Time) {
+	var finished []gaia.PipelineRun
+	for _, r := range runs {
+		if r.Shadow || r.Debug || (r.Status != gaia.RunSuccess && r.Status != gaia.RunFailed) {
+			continue
+		}
+		finished = append(finished, r)
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishDate.Before(finished[j].FinishDate) })
+
+	// Earlier runs are still needed to find first deployments and
+	// failures which have been going on before the period.
+	deployed := map[string]bool{}
+	var failingSince time.Time
+	for _, r := range finished {
+		inPeriod := !r.FinishDate.Before(since)
+		if r.Status == gaia.RunFailed {
+			if inPeriod {
+				d.failures++
+			}
+			if failingSince.IsZero() {
+				failingSince = r.FinishDate
+			}
+			continue
+		}
+
+		if inPeriod {
+			d.deployments++
+			if r.Commit.Hash != "" && !deployed[r.Commit.Hash] && !r.Commit.Date.IsZero() {
+				d.leadTimes = append(d.leadTimes, r.FinishDate.Sub(r.Commit.Date).Seconds())
+			}
+			if !failingSince.IsZero() {
+				d.recoveryTimes = append(d.recoveryTimes, r.FinishDate.Sub(failingSince).Seconds())
+			}
+		}
+		deployed[r.Commit.Hash] = true
+		failingSince = time.Time{}
+	}
+}
+
+// metrics returns the metrics of the collected runs in the period
+// from since until now.
+func (d *doraSamples) metrics(since, now time.Time) *gaia.DORAMetrics {
+	m := &gaia.DORAMetrics{
+		Since:        since,
+		Deployments:  d.deployments,
+		Failures:     d.failures,
+		LeadTime:     median(d.leadTimes),
+		Recoveries:   len(d.recoveryTimes),
+		RecoveryTime: median(d.recoveryTimes),
+	}
+	if days := now.Sub(since).Hours() / 24; days > 0 {
+		m.DeploymentFrequency = float64(d.deployments) / days
+	}
+	if total := d.deployments + d.failures; total > 0 {
+		m.ChangeFailureRate = float64(d.failures) / float64(total)
+	}
+	return m
+}
+
+// median returns the median of the given values or zero if there are none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+func TestDORASamples(t *testing.T) {
+	since := time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return since.Add(time.Duration(hours) * time.Hour) }
+	commit := func(hash string, hours int) gaia.Commit { return gaia.Commit{Hash: hash, Date: at(hours)} }
+
+	runs := []gaia.PipelineRun{
+		// Failure before the period which is recovered in the period
+		{Status: gaia.RunFailed, FinishDate: at(-2), Commit: commit("a", -3)},
+		{Status: gaia.RunSuccess, FinishDate: at(2), Commit: commit("b", 0)},
+		// Redeploying a commit has no lead time
+		{Status: gaia.RunSuccess, FinishDate: at(4), Commit: commit("b", 0)},
+		{Status: gaia.RunFailed, FinishDate: at(10), Commit: commit("c", 6)},
+		{Status: gaia.RunFailed, FinishDate: at(11), Commit: commit("c", 6)},
+		{Status: gaia.RunSuccess, FinishDate: at(14), Commit: commit("d", 12)},
+		// Ignored runs
+		{Status: gaia.RunSuccess, FinishDate: at(15), Shadow: true},
+		{Status: gaia.RunCancelled, FinishDate: at(16)},
+		{Status: gaia.RunSuccess, FinishDate: at(17), Debug: true},
+	}
+
+	d := &doraSamples{}
+	d.add(runs, since)
+	m := d.metrics(since, at(48))
+
+	if m.Deployments != 3 || m.DeploymentFrequency != 1.5 {
+		t.Fatalf("expected 3 deployments and 1.5 per day, got %d and %f", m.Deployments, m.DeploymentFrequency)
+	}
+	if m.Failures != 2 || m.ChangeFailureRate != 0.4 {
+		t.Fatalf("expected 2 failures and rate 0.4, got %d and %f", m.Failures, m.ChangeFailureRate)
+	}
+	if m.LeadTime != 2*3600 {
+		t.Fatalf("expected lead time of 2h, got %fs", m.LeadTime)
+	}
+	if m.Recoveries != 2 || m.RecoveryTime != 4*3600 {
+		t.Fatalf("expected 2 recoveries with 4h, got %d with %fs", m.Recoveries, m.RecoveryTime)
+	}
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"regexp"
 	"strings"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -15,8 +16,24 @@ import (
 
 const (
 	refHead = "refs/heads"
+
+	// maxCommitRange is the maximum number of commits which are
+	// looked at for issue keys.
+	maxCommitRange = 100
 )
 
+// issueKeyPattern matches Jira issue keys like GAIA-123.
+var issueKeyPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`)
+
+// InitGitTransport makes git trust the configured certificate
+// authorities for repos which are cloned over https.
+func InitGitTransport() {
+	if gaia.Cfg.TLS == nil {
+		return
+	}
+	client.InstallProtocol("https", http.NewClient(gaia.Cfg.HTTPClient(0)))
+}
+
 // GitLSRemote get remote branches from a git repo
 // without actually cloning the repo. This is great
 // for looking if we have access to this repo.
@@ -106,3 +123,67 @@ func gitCloneRepo(repo *gaia.GitRepo) error {
 
 	return nil
 }
+
+// gitHeadCommit returns the checked out commit of the repo in the given folder.
+func gitHeadCommit(dir string) (gaia.Commit, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return gaia.Commit{}, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return gaia.Commit{}, err
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return gaia.Commit{}, err
+	}
+	return gaia.Commit{Hash: commit.Hash.String(), Date: commit.Committer.When}, nil
+}
+
+// gitIssueKeys returns the Jira issue keys mentioned by the commits of
+// the repo in the given folder. The commits from the checked out commit
+// back to the given previous commit are looked at. If the previous
+// commit is not given, only the checked out commit is looked at.
+func gitIssueKeys(dir, previous string) ([]string, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := r.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, err
+	}
+	defer commits.Close()
+
+	keys := []string{}
+	for i := 0; i < maxCommitRange; i++ {
+		c, err := commits.Next()
+		if err != nil || c.Hash.String() == previous {
+			break
+		}
+		for _, key := range issueKeyPattern.FindAllString(c.Message, -1) {
+			if !containsString(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+		if previous == "" {
+			break
+		}
+	}
+	return keys, nil
+}
+
+// containsString returns true if the given string is in the given list.
+func containsString(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
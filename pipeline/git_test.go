@@ -1,10 +1,16 @@
 package pipeline
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gaia-pipeline/gaia"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 func TestGitCloneRepo(t *testing.T) {
@@ -19,3 +25,62 @@ func TestGitCloneRepo(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestGitIssueKeys(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestGitIssueKeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	r, err := git.PlainInit(tmp, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashes []string
+	for i, msg := range []string{"GAIA-1 initial commit", "Fix GAIA-2 and OPS-10", "Refs GAIA-2, not-an-issue and UTF-8"} {
+		if err = ioutil.WriteFile(filepath.Join(tmp, "file"), []byte(msg), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Add("file"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := w.Commit(msg, &git.CommitOptions{
+			Author: &object.Signature{Name: "gaia", Email: "gaia@gaia.io", When: time.Now().Add(time.Duration(i) * time.Second)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash.String())
+	}
+
+	// Everything which looks like an issue key is taken. The projects
+	// of the Jira integration filter them.
+	keys, err := gitIssueKeys(tmp, hashes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"GAIA-2", "UTF-8", "OPS-10"}) {
+		t.Fatalf("unexpected issue keys %v", keys)
+	}
+
+	// Without previous build only the checked out commit is looked at
+	if keys, err = gitIssueKeys(tmp, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"GAIA-2", "UTF-8"}) {
+		t.Fatalf("unexpected issue keys %v", keys)
+	}
+
+	commit, err := gitHeadCommit(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Hash != hashes[2] || commit.Date.IsZero() {
+		t.Fatalf("unexpected head commit %+v", commit)
+	}
+}
@@ -0,0 +1,292 @@
+package pipeline
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// librariesFolder is the folder in the home folder which holds all
+	// published versions of the shared job libraries.
+	librariesFolder = "libraries"
+
+	// libraryMetaExt is the extension of the file next to a version
+	// folder which holds the library metadata.
+	libraryMetaExt = ".json"
+)
+
+var (
+	// errInvalidLibraryName is thrown when a library has an invalid import path.
+	errInvalidLibraryName = errors.New("invalid library import path given")
+
+	// errInvalidLibraryVersion is thrown when a library has an invalid version.
+	errInvalidLibraryVersion = errors.New("invalid library version given")
+
+	// errLibraryVersionExists is thrown when a published version should be published again.
+	errLibraryVersionExists = errors.New("library version has already been published")
+
+	// errLibraryNotFound is thrown when a library version does not exist.
+	errLibraryNotFound = errors.New("library version not found")
+
+	// errInvalidLibraryArchive is thrown when an archive entry would be
+	// extracted outside of the library folder.
+	errInvalidLibraryArchive = errors.New("invalid path in library archive")
+
+	// libraryVersionPattern matches valid library versions like v1.2.0.
+	libraryVersionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+-]*$`)
+
+	// librariesLock serializes publishing and deleting libraries.
+	librariesLock sync.Mutex
+)
+
+// PublishLibrary publishes the given version of a shared job library.
+// The given archive is a tar.gz file of the package folder.
+// Published versions are immutable.
+func PublishLibrary(name, version string, archive io.Reader) (*gaia.Library, error) {
+	if err := validateLibrary(name, version); err != nil {
+		return nil, err
+	}
+
+	librariesLock.Lock()
+	defer librariesLock.Unlock()
+
+	folder := getLibraryFolder(name, version)
+	if _, err := os.Stat(folder); err == nil {
+		return nil, errLibraryVersionExists
+	}
+
+	// Extract into a temp folder first. Builds must never see a partial library.
+	tmp := folder + "." + uuid.Must(uuid.NewV4(), nil).String()
+	if err := os.MkdirAll(tmp, 0700); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractTarGz(archive, tmp); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, folder); err != nil {
+		return nil, err
+	}
+
+	l := &gaia.Library{
+		Name:      name,
+		Version:   version,
+		Published: time.Now(),
+	}
+	m, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(folder+libraryMetaExt, m, 0600); err != nil {
+		os.RemoveAll(folder)
+		return nil, err
+	}
+	return l, nil
+}
+
+// GetLibraries returns all published library versions ordered by
+// name and publish date.
+func GetLibraries() ([]gaia.Library, error) {
+	files, err := filepath.Glob(filepath.Join(gaia.Cfg.HomePath, librariesFolder, "*", "*"+libraryMetaExt))
+	if err != nil {
+		return nil, err
+	}
+
+	libraries := []gaia.Library{}
+	for _, file := range files {
+		m, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		l := gaia.Library{}
+		if err = json.Unmarshal(m, &l); err != nil {
+			return nil, err
+		}
+		libraries = append(libraries, l)
+	}
+
+	sort.Slice(libraries, func(i, j int) bool {
+		if libraries[i].Name != libraries[j].Name {
+			return libraries[i].Name < libraries[j].Name
+		}
+		return libraries[i].Published.Before(libraries[j].Published)
+	})
+	return libraries, nil
+}
+
+// ValidateLibraryPins checks that every pinned library version has
+// been published. Pins are only checked when they are set; a version
+// deleted later fails the next build of the pipeline.
+func ValidateLibraryPins(pins map[string]string) error {
+	for name, version := range pins {
+		if err := validateLibrary(name, version); err != nil {
+			return err
+		}
+		if _, err := os.Stat(getLibraryFolder(name, version) + libraryMetaExt); os.IsNotExist(err) {
+			return errors.New(errLibraryNotFound.Error() + ": " + name + "@" + version)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteLibrary deletes the given version of a shared job library.
+func DeleteLibrary(name, version string) error {
+	if err := validateLibrary(name, version); err != nil {
+		return err
+	}
+
+	librariesLock.Lock()
+	defer librariesLock.Unlock()
+
+	folder := getLibraryFolder(name, version)
+	if err := os.Remove(folder + libraryMetaExt); os.IsNotExist(err) {
+		return errLibraryNotFound
+	} else if err != nil {
+		return err
+	}
+	return os.RemoveAll(folder)
+}
+
+// prepareLibraries creates a GOPATH folder which contains the libraries
+// the given pipeline is built with. Pinned libraries are used in the
+// pinned version, all other libraries in the latest version.
+// Returns an empty string if no library has been published.
+func prepareLibraries(p *gaia.Pipeline) (string, error) {
+	libraries, err := GetLibraries()
+	if err != nil {
+		return "", err
+	}
+
+	// Libraries are ordered by publish date. The last one wins.
+	selected := map[string]gaia.Library{}
+	for _, l := range libraries {
+		if pinned, ok := p.Libraries[l.Name]; !ok || pinned == l.Version {
+			selected[l.Name] = l
+		}
+	}
+	for name, version := range p.Libraries {
+		if l, ok := selected[name]; !ok || l.Version != version {
+			return "", errors.New(errLibraryNotFound.Error() + ": " + name + "@" + version)
+		}
+	}
+	if len(selected) == 0 {
+		return "", nil
+	}
+
+	goPath := filepath.Join(gaia.Cfg.HomePath, tmpFolder, librariesFolder, uuid.Must(uuid.NewV4(), nil).String())
+	for _, l := range selected {
+		dest := filepath.Join(goPath, srcFolder, filepath.FromSlash(l.Name))
+		if err = copyFolder(getLibraryFolder(l.Name, l.Version), dest); err != nil {
+			os.RemoveAll(goPath)
+			return "", err
+		}
+	}
+	return goPath, nil
+}
+
+// validateLibrary checks the import path and the version of a library.
+func validateLibrary(name, version string) error {
+	if name == "" || strings.HasPrefix(name, "/") || path.Clean(name) != name || strings.Contains(name, `\`) {
+		return errInvalidLibraryName
+	}
+	for _, e := range strings.Split(name, "/") {
+		if e == "." || e == ".." {
+			return errInvalidLibraryName
+		}
+	}
+	if !libraryVersionPattern.MatchString(version) || strings.HasSuffix(version, libraryMetaExt) {
+		return errInvalidLibraryVersion
+	}
+	return nil
+}
+
+// getLibraryFolder returns the folder of the given library version.
+func getLibraryFolder(name, version string) string {
+	return filepath.Join(gaia.Cfg.HomePath, librariesFolder, url.PathEscape(name), version)
+}
+
+// extractTarGz extracts the given tar.gz archive into the given folder.
+// Only folders and regular files are extracted.
+func extractTarGz(r io.Reader, dest string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := path.Clean(strings.TrimPrefix(h.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return errInvalidLibraryArchive
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err = os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// copyFolder copies the given folder recursively.
+func copyFolder(src, dest string) error {
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		return copyFileContents(file, target)
+	})
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+func newLibraryArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		h := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestLibraries(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestLibraries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{HomePath: tmp}
+
+	name := "gaia/jobs/notify"
+	for _, version := range []string{"v1.0.0", "v1.1.0"} {
+		archive := newLibraryArchive(t, map[string]string{"notify.go": "package notify // " + version})
+		if _, err := PublishLibrary(name, version, archive); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := PublishLibrary(name, "v1.0.0", newLibraryArchive(t, nil)); err != errLibraryVersionExists {
+		t.Fatalf("expected error %v, got %v", errLibraryVersionExists, err)
+	}
+	if _, err := PublishLibrary("../jobs", "v1.0.0", newLibraryArchive(t, nil)); err != errInvalidLibraryName {
+		t.Fatalf("expected error %v, got %v", errInvalidLibraryName, err)
+	}
+	archive := newLibraryArchive(t, map[string]string{"../evil.go": "package evil"})
+	if _, err := PublishLibrary("gaia/evil", "v1", archive); err != errInvalidLibraryArchive {
+		t.Fatalf("expected error %v, got %v", errInvalidLibraryArchive, err)
+	}
+
+	libraries, err := GetLibraries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(libraries) != 2 || libraries[1].Version != "v1.1.0" {
+		t.Fatalf("expected two versions ordered by publish date, got %+v", libraries)
+	}
+
+	// The latest version is used if not pinned
+	for version, pinned := range map[string]map[string]string{
+		"v1.1.0": nil,
+		"v1.0.0": {name: "v1.0.0"},
+	} {
+		goPath, err := prepareLibraries(&gaia.Pipeline{Libraries: pinned})
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(goPath, srcFolder, "gaia", "jobs", "notify", "notify.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "package notify // "+version {
+			t.Fatalf("expected version %s, got %q", version, string(content))
+		}
+		os.RemoveAll(goPath)
+	}
+
+	if _, err = prepareLibraries(&gaia.Pipeline{Libraries: map[string]string{name: "v2.0.0"}}); err == nil {
+		t.Fatal("expected error for missing pinned version")
+	}
+
+	if err = ValidateLibraryPins(map[string]string{name: "v1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, pins := range []map[string]string{{name: "v2.0.0"}, {"../jobs": "v1.0.0"}, {name: "../v1"}} {
+		if err = ValidateLibraryPins(pins); err == nil {
+			t.Fatalf("expected pins %v to be rejected", pins)
+		}
+	}
+
+	if err = DeleteLibrary(name, "v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeleteLibrary(name, "v1.1.0"); err != errLibraryNotFound {
+		t.Fatalf("expected error %v, got %v", errLibraryNotFound, err)
+	}
+}
@@ -0,0 +1,428 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// defaultMirrorInterval defines how often a standby instance
+	// replicates if no interval has been set.
+	defaultMirrorInterval = 30 * time.Second
+
+	// mirrorTimeout is the maximum duration of one replication request.
+	mirrorTimeout = 5 * time.Minute
+
+	// mirrorPathPrefix is the prefix of the replication endpoint of
+	// the primary instance.
+	mirrorPathPrefix = "/api/v1/replication/"
+)
+
+var (
+	// errNotStandby is thrown when an instance which is no standby
+	// should be promoted.
+	errNotStandby = errors.New("instance is not a standby")
+
+	// errInvalidReplicationFile is thrown when a replicated file is not
+	// a log or artifact file of a run.
+	errInvalidReplicationFile = errors.New("invalid replication file")
+
+	// replicatedRunFolders are the folders of a run which are copied
+	// to standby instances.
+	replicatedRunFolders = []string{gaia.LogsFolderName, gaia.LogEntriesFolderName, gaia.ArtifactsFolderName}
+
+	// mirror holds the replication state of a standby instance.
+	mirror = &MirrorStatus{}
+
+	// mirrorLock protects mirror.
+	mirrorLock sync.Mutex
+
+	// replicationLock serializes the replication and the promotion.
+	replicationLock sync.Mutex
+)
+
+// MirrorStatus represents the replication state of a standby instance.
+type MirrorStatus struct {
+	Standby   bool      `json:"standby"`
+	Primary   string    `json:"primary,omitempty"`
+	LastSync  time.Time `json:"lastsync,omitempty"`
+	LastError string    `json:"lasterror,omitempty"`
+}
+
+// InitMirror starts the replication from the primary instance if one
+// has been configured. The instance stays a read-only standby until it
+// has been promoted. The scheduler must not be initialized before.
+func InitMirror() {
+	if gaia.Cfg.MirrorURL == "" {
+		return
+	}
+
+	mirrorLock.Lock()
+	mirror = &MirrorStatus{Standby: true, Primary: gaia.Cfg.MirrorURL}
+	mirrorLock.Unlock()
+
+	go func() {
+		for syncMirror() {
+			time.Sleep(mirrorInterval())
+		}
+	}()
+}
+
+// mirrorInterval returns the interval of the replication.
+func mirrorInterval() time.Duration {
+	if gaia.Cfg.MirrorInterval > 0 {
+		return gaia.Cfg.MirrorInterval
+	}
+	return defaultMirrorInterval
+}
+
+// IsStandby returns true if this instance is a standby which only
+// serves read-only queries.
+func IsStandby() bool {
+	mirrorLock.Lock()
+	defer mirrorLock.Unlock()
+
+	return mirror.Standby
+}
+
+// GetMirrorStatus returns the replication state of this instance.
+func GetMirrorStatus() MirrorStatus {
+	mirrorLock.Lock()
+	defer mirrorLock.Unlock()
+
+	return *mirror
+}
+
+// PromoteStandby stops the replication and makes this standby instance
+// the primary. Afterwards the scheduler starts to execute runs.
+func PromoteStandby() error {
+	replicationLock.Lock()
+	defer replicationLock.Unlock()
+
+	mirrorLock.Lock()
+	if !mirror.Standby {
+		mirrorLock.Unlock()
+		return errNotStandby
+	}
+	mirror.Standby = false
+	mirrorLock.Unlock()
+
+	if err := storeService.ReplicationPromote(); err != nil {
+		return err
+	}
+	gaia.Cfg.Logger.Info("promoted standby to primary", "primary", gaia.Cfg.MirrorURL)
+	if err := schedulerService.Init(); err != nil {
+		return err
+	}
+	resumeBuilds()
+	return nil
+}
+
+// syncMirror replicates the state of the primary once. Returns false
+// if the instance has been promoted.
+func syncMirror() bool {
+	replicationLock.Lock()
+	defer replicationLock.Unlock()
+	if !IsStandby() {
+		return false
+	}
+
+	err := replicate()
+	mirrorLock.Lock()
+	defer mirrorLock.Unlock()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot replicate from primary", "error", err.Error(), "primary", gaia.Cfg.MirrorURL)
+		mirror.LastError = err.Error()
+		return true
+	}
+	mirror.LastSync = time.Now()
+	mirror.LastError = ""
+	return true
+}
+
+// replicate imports the snapshot of the primary and fetches the
+// pipeline binaries and the run files which have been changed.
+func replicate() error {
+	resp, err := mirrorRequest("snapshot", 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	snapshot := &gaia.ReplicationSnapshot{}
+	if err = json.NewDecoder(resp.Body).Decode(snapshot); err != nil {
+		return err
+	}
+	if err = storeService.ReplicationImport(snapshot); err != nil {
+		return err
+	}
+
+	files := map[string]bool{}
+	for _, b := range snapshot.Binaries {
+		files[b.File] = true
+		if err = replicateBinary(&b); err != nil {
+			return err
+		}
+	}
+	removeOrphanedBinaries(files)
+
+	files = map[string]bool{}
+	for _, f := range snapshot.Files {
+		files[f.Path] = true
+		if err = replicateFile(&f); err != nil {
+			return err
+		}
+	}
+	removeOrphanedFiles(files)
+	return nil
+}
+
+// replicateBinary fetches the given binary if it differs from the local
+// one. The stored path of the binary is changed to the local pipelines
+// folder before the ticker picks up the binary.
+func replicateBinary(b *gaia.ReplicationBinary) error {
+	if b.File != filepath.Base(b.File) {
+		return fmt.Errorf("invalid binary name %s", b.File)
+	}
+	path := filepath.Join(gaia.Cfg.PipelinePath, b.File)
+
+	p, err := storeService.PipelineGet(b.PipelineID)
+	if err != nil {
+		return err
+	}
+	if p.Name != "" && p.ExecPath != path {
+		p.ExecPath = path
+		if err = storeService.PipelineUpdate(p); err != nil {
+			return err
+		}
+	}
+
+	checksum, err := getSHA256Sum(path)
+	if err == nil && bytes.Equal(checksum, b.SHA256Sum) {
+		return nil
+	}
+	return downloadBinary(b, path)
+}
+
+// downloadBinary fetches the given binary from the primary and moves it
+// to the given path once its checksum has been verified.
+func downloadBinary(b *gaia.ReplicationBinary, path string) error {
+	resp, err := mirrorRequest("binary/"+strconv.Itoa(b.PipelineID), 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	tmp, err := ioutil.TempFile(gaia.Cfg.DataPath, "mirror")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	checksum, err := getSHA256Sum(tmp.Name())
+	if err != nil {
+		return err
+	} else if !bytes.Equal(checksum, b.SHA256Sum) {
+		return fmt.Errorf("checksum of binary %s does not match", b.File)
+	}
+	if err = os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	gaia.Cfg.Logger.Debug("replicated pipeline binary", "file", b.File)
+	return os.Rename(tmp.Name(), path)
+}
+
+// removeOrphanedBinaries removes the binaries of the pipelines folder
+// which are not in the given files of the primary.
+func removeOrphanedBinaries(files map[string]bool) {
+	infos, err := ioutil.ReadDir(gaia.Cfg.PipelinePath)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot read pipelines folder", "error", err.Error(), "path", gaia.Cfg.PipelinePath)
+		return
+	}
+	for _, info := range infos {
+		n := strings.TrimSpace(info.Name())
+		pType, err := getPipelineType(n)
+		if err != nil || files[info.Name()] {
+			continue
+		}
+		if err = os.Remove(filepath.Join(gaia.Cfg.PipelinePath, info.Name())); err != nil {
+			gaia.Cfg.Logger.Error("cannot remove pipeline binary", "error", err.Error(), "file", info.Name())
+			continue
+		}
+		GlobalActivePipelines.Remove(getRealPipelineName(n, pType))
+	}
+}
+
+// replicateFile fetches the part of the given run file which is
+// missing locally. Files which are larger than on the primary have
+// been rewritten and are fetched again.
+func replicateFile(f *gaia.ReplicationFile) error {
+	path, err := ReplicationFilePath(f.Path)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+		offset = info.Size()
+	}
+	if offset == f.Size {
+		return nil
+	} else if offset > f.Size {
+		offset = 0
+	}
+
+	resp, err := mirrorRequest("file?path="+url.QueryEscape(f.Path), offset)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The primary may ignore the range and send the whole file
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resp.StatusCode == http.StatusPartialContent {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return err
+	}
+
+	// Logs grow while they are fetched. Only the listed size is fetched
+	// so the next replication continues from there.
+	_, err = io.Copy(file, io.LimitReader(resp.Body, f.Size-offset))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// removeOrphanedFiles removes the run files of the workspace which are
+// not in the given files of the primary.
+func removeOrphanedFiles(files map[string]bool) {
+	local, err := ReplicationFiles()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot list run files", "error", err.Error(), "path", gaia.Cfg.WorkspacePath)
+		return
+	}
+	for _, f := range local {
+		if files[f.Path] {
+			continue
+		}
+		if err = os.Remove(filepath.Join(gaia.Cfg.WorkspacePath, filepath.FromSlash(f.Path))); err != nil {
+			gaia.Cfg.Logger.Error("cannot remove run file", "error", err.Error(), "file", f.Path)
+		}
+	}
+}
+
+// ReplicationFiles returns the log and artifact files of all runs which
+// are copied to standby instances.
+func ReplicationFiles() ([]gaia.ReplicationFile, error) {
+	files := []gaia.ReplicationFile{}
+	for _, folder := range replicatedRunFolders {
+		roots, err := filepath.Glob(filepath.Join(gaia.Cfg.WorkspacePath, "*", "*", folder))
+		if err != nil {
+			return nil, err
+		}
+		for _, root := range roots {
+			err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return err
+				}
+				rel, err := filepath.Rel(gaia.Cfg.WorkspacePath, path)
+				if err != nil {
+					return err
+				}
+				rel = filepath.ToSlash(rel)
+				if _, err = ReplicationFilePath(rel); err != nil {
+					return nil
+				}
+				files = append(files, gaia.ReplicationFile{Path: rel, Size: info.Size()})
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// ReplicationFilePath returns the local path of the given replicated
+// run file. The file must be in a replicated folder of a run.
+func ReplicationFilePath(rel string) (string, error) {
+	parts := strings.Split(rel, "/")
+	if len(parts) < 4 {
+		return "", errInvalidReplicationFile
+	}
+	for _, id := range parts[:2] {
+		if n, err := strconv.Atoi(id); err != nil || n < 1 || strconv.Itoa(n) != id {
+			return "", errInvalidReplicationFile
+		}
+	}
+	replicated := false
+	for _, folder := range replicatedRunFolders {
+		replicated = replicated || parts[2] == folder
+	}
+	if !replicated {
+		return "", errInvalidReplicationFile
+	}
+	for _, part := range parts[3:] {
+		if part == "" || part == "." || part == ".." {
+			return "", errInvalidReplicationFile
+		}
+	}
+	return filepath.Join(gaia.Cfg.WorkspacePath, filepath.FromSlash(rel)), nil
+}
+
+// mirrorRequest requests the given path of the replication endpoint of
+// the primary. The response starts at the given offset if the primary
+// honours the range.
+func mirrorRequest(path string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(gaia.Cfg.MirrorURL, "/")+mirrorPathPrefix+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+gaia.Cfg.ReplicationToken)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	client := gaia.Cfg.HTTPClient(mirrorTimeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("primary returned status %s for %s", resp.Status, path)
+	}
+	return resp, nil
+}
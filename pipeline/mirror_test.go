@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestDownloadBinary(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestDownloadBinary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	content := []byte("pipeline binary")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Path != mirrorPathPrefix+"binary/1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(content)
+	}))
+	defer ts.Close()
+	gaia.Cfg = &gaia.Config{
+		Logger:           hclog.NewNullLogger(),
+		DataPath:         tmp,
+		MirrorURL:        ts.URL,
+		ReplicationToken: "token",
+	}
+
+	checksum := sha256.Sum256(content)
+	b := &gaia.ReplicationBinary{PipelineID: 1, File: "test_golang", SHA256Sum: checksum[:]}
+	path := filepath.Join(tmp, b.File)
+	if err = downloadBinary(b, path); err != nil {
+		t.Fatal(err)
+	}
+	if downloaded, _ := ioutil.ReadFile(path); string(downloaded) != string(content) {
+		t.Fatalf("expected downloaded binary, got %q", downloaded)
+	}
+
+	// Modified binaries are rejected
+	b.SHA256Sum = []byte("invalid")
+	if err = downloadBinary(b, filepath.Join(tmp, "other_golang")); err == nil {
+		t.Fatal("expected checksum error")
+	}
+	if _, err = os.Stat(filepath.Join(tmp, "other_golang")); !os.IsNotExist(err) {
+		t.Fatalf("expected no binary, got %v", err)
+	}
+}
+
+func TestReplicateFile(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestReplicateFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	content := "first line\nsecond line\n"
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("path") != "1/2/logs/3" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "3", time.Time{}, strings.NewReader(content))
+	}))
+	defer ts.Close()
+	gaia.Cfg = &gaia.Config{
+		Logger:           hclog.NewNullLogger(),
+		WorkspacePath:    tmp,
+		MirrorURL:        ts.URL,
+		ReplicationToken: "token",
+	}
+
+	// Only the missing part of a grown log is fetched
+	path := filepath.Join(tmp, "1", "2", "logs", "3")
+	os.MkdirAll(filepath.Dir(path), 0700)
+	ioutil.WriteFile(path, []byte("first line\n"), 0600)
+	if err = replicateFile(&gaia.ReplicationFile{Path: "1/2/logs/3", Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if replicated, _ := ioutil.ReadFile(path); string(replicated) != content {
+		t.Fatalf("expected replicated log, got %q", replicated)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=11-" {
+		t.Fatalf("expected ranged request, got %v", ranges)
+	}
+
+	// Unchanged files are not fetched again
+	if err = replicateFile(&gaia.ReplicationFile{Path: "1/2/logs/3", Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 1 {
+		t.Fatalf("expected no request, got %v", ranges)
+	}
+
+	// Files outside of the run folders are rejected
+	for _, rel := range []string{"1/2/logs/../../../data/gaia.db", "1/2/workspace/file", "a/2/logs/3", "1/2/logs"} {
+		if err = replicateFile(&gaia.ReplicationFile{Path: rel, Size: 1}); err != errInvalidReplicationFile {
+			t.Fatalf("expected invalid file error for %s, got %v", rel, err)
+		}
+	}
+
+	// Files which are gone on the primary are removed
+	files, err := ReplicationFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "1/2/logs/3" {
+		t.Fatalf("expected replicated file, got %v", files)
+	}
+	removeOrphanedFiles(map[string]bool{})
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected removed file, got %v", err)
+	}
+}
@@ -65,6 +65,10 @@ func newBuildPipeline(t gaia.PipelineType) BuildPipeline {
 		bP = &BuildPipelineGolang{
 			Type: t,
 		}
+	case gaia.PTypeWASM:
+		bP = &BuildPipelineWASM{
+			Type: t,
+		}
 	}
 
 	return bP
@@ -128,6 +132,23 @@ func (ap *ActivePipelines) Replace(p gaia.Pipeline) bool {
 	return true
 }
 
+// Remove removes the pipeline with the given name from the ActivePipelines
+// slice. Return true when success otherwise false.
+func (ap *ActivePipelines) Remove(n string) bool {
+	ap.Lock()
+	defer ap.Unlock()
+
+	// Search for the name
+	for id, pipeline := range ap.Pipelines {
+		if pipeline.Name == n {
+			ap.Pipelines = append(ap.Pipelines[:id], ap.Pipelines[id+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
 // Iter iterates over the pipelines in the concurrent slice.
 func (ap *ActivePipelines) Iter() <-chan gaia.Pipeline {
 	c := make(chan gaia.Pipeline)
@@ -157,6 +178,32 @@ func (ap *ActivePipelines) Contains(n string) bool {
 	return foundPipeline
 }
 
+// UpdatePipeline applies the given change to the stored pipeline with
+// the given id and to its active counterpart.
+// Returns nil if the pipeline was not found.
+func UpdatePipeline(id int, change func(p *gaia.Pipeline)) (*gaia.Pipeline, error) {
+	p, err := storeService.PipelineGet(id)
+	if err != nil {
+		return nil, err
+	} else if p.Name == "" {
+		return nil, nil
+	}
+
+	// Update store
+	change(p)
+	if err = storeService.PipelineUpdate(p); err != nil {
+		return nil, err
+	}
+
+	// Update active pipeline
+	if active := GlobalActivePipelines.GetByName(p.Name); active != nil {
+		change(active)
+		GlobalActivePipelines.Replace(*active)
+	}
+
+	return p, nil
+}
+
 // appendTypeToName appends the type to the output binary name.
 // This allows us later to define the pipeline type by the name.
 func appendTypeToName(n string, pType gaia.PipelineType) string {
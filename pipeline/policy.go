@@ -0,0 +1,344 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/satori/go.uuid"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+const (
+	// licenseUnknown is the license of dependencies whose license
+	// could not be detected.
+	licenseUnknown = "unknown"
+
+	// vendorFolder is the folder which holds vendored dependencies.
+	vendorFolder = "vendor"
+
+	// commitLength is the length of a full git commit hash.
+	commitLength = 40
+)
+
+var (
+	// errInvalidPolicy is thrown when a vulnerable dependency of the
+	// dependency policy is missing the path or the versions.
+	errInvalidPolicy = errors.New("vulnerable dependencies need a path and at least one version")
+
+	// errInvalidWaiver is thrown when a waiver is missing the
+	// pipeline, the dependency or the reason.
+	errInvalidWaiver = errors.New("waiver needs a pipeline, a dependency and a reason")
+
+	// errUnknownDependencies is thrown when the dependencies of a build
+	// cannot be checked against the dependency policy.
+	errUnknownDependencies = errors.New("the dependencies of committed webassembly modules cannot be checked against the dependency policy. Commit the " + cargoManifest + " instead")
+
+	// policyLock protects the stored dependency policy.
+	policyLock sync.Mutex
+)
+
+// licenseFiles are the names of the files which hold the license of
+// a dependency.
+var licenseFiles = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "COPYING", "COPYING.md"}
+
+// licenseRules detect the license by a phrase of the license text.
+// More specific licenses come first.
+var licenseRules = []struct {
+	license string
+	phrase  string
+}{
+	{"AGPL-3.0", "gnu affero general public license"},
+	{"LGPL", "gnu lesser general public license"},
+	{"LGPL", "gnu library general public license"},
+	{"GPL", "gnu general public license"},
+	{"MPL-2.0", "mozilla public license"},
+	{"EPL", "eclipse public license"},
+	{"Apache-2.0", "apache license"},
+	{"BSD", "redistribution and use in source and binary forms"},
+	{"MIT", "permission is hereby granted, free of charge"},
+	{"ISC", "permission to use, copy, modify, and/or distribute this software"},
+	{"Unlicense", "this is free and unencumbered software released into the public domain"},
+}
+
+// GetDependencyPolicy returns the dependency policy.
+func GetDependencyPolicy() (*gaia.DependencyPolicy, error) {
+	policy, err := storeService.PolicyGet()
+	if err != nil {
+		return nil, err
+	} else if policy == nil {
+		policy = &gaia.DependencyPolicy{}
+	}
+	return policy, nil
+}
+
+// SetDependencyPolicy replaces the rules of the dependency policy.
+// The waivers of the stored policy are kept.
+func SetDependencyPolicy(rules *gaia.DependencyPolicy) (*gaia.DependencyPolicy, error) {
+	for _, v := range rules.Vulnerable {
+		if v.Path == "" || len(v.Versions) == 0 {
+			return nil, errInvalidPolicy
+		}
+	}
+
+	policyLock.Lock()
+	defer policyLock.Unlock()
+
+	policy, err := GetDependencyPolicy()
+	if err != nil {
+		return nil, err
+	}
+	policy.ForbiddenLicenses = rules.ForbiddenLicenses
+	policy.Vulnerable = rules.Vulnerable
+	return policy, storeService.PolicyPut(policy)
+}
+
+// RequestWaiver adds the given waiver to the dependency policy.
+// It does not apply until an admin approved it.
+func RequestWaiver(w *gaia.PolicyWaiver) error {
+	if w.Pipeline == "" || w.Dependency == "" || w.Reason == "" {
+		return errInvalidWaiver
+	}
+	w.ID = uuid.Must(uuid.NewV4(), nil).String()
+	w.Created = time.Now()
+	w.Approved = false
+	w.ApprovedBy = ""
+
+	policyLock.Lock()
+	defer policyLock.Unlock()
+
+	policy, err := GetDependencyPolicy()
+	if err != nil {
+		return err
+	}
+	policy.Waivers = append(policy.Waivers, *w)
+	return storeService.PolicyPut(policy)
+}
+
+// ApproveWaiver approves the waiver with the given id. A zero
+// expiry date lets the waiver apply forever.
+// Returns nil if the waiver was not found.
+func ApproveWaiver(id, approver string, expires time.Time) (*gaia.PolicyWaiver, error) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+
+	policy, err := GetDependencyPolicy()
+	if err != nil {
+		return nil, err
+	}
+	for i := range policy.Waivers {
+		w := &policy.Waivers[i]
+		if w.ID == id {
+			w.Approved = true
+			w.ApprovedBy = approver
+			w.Expires = expires
+			return w, storeService.PolicyPut(policy)
+		}
+	}
+	return nil, nil
+}
+
+// DeleteWaiver removes the waiver with the given id.
+// Returns false if the waiver was not found.
+func DeleteWaiver(id string) (bool, error) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+
+	policy, err := GetDependencyPolicy()
+	if err != nil {
+		return false, err
+	}
+	for i := range policy.Waivers {
+		if policy.Waivers[i].ID == id {
+			policy.Waivers = append(policy.Waivers[:i], policy.Waivers[i+1:]...)
+			return true, storeService.PolicyPut(policy)
+		}
+	}
+	return false, nil
+}
+
+// checkDependencyPolicy evaluates the dependencies which have been
+// found by the builder against the dependency policy.
+// Returns the violations which are not waived.
+func checkDependencyPolicy(p *gaia.CreatePipeline) ([]string, error) {
+	policy, err := storeService.PolicyGet()
+	if err != nil || policy == nil {
+		return nil, err
+	}
+	if p.DependenciesUnknown && (len(policy.ForbiddenLicenses) > 0 || len(policy.Vulnerable) > 0) {
+		return nil, errUnknownDependencies
+	}
+	return policyViolations(policy, p.Pipeline.Name, p.Dependencies, time.Now()), nil
+}
+
+// policyViolations returns all violations of the given policy by the
+// given dependencies of the given pipeline.
+func policyViolations(policy *gaia.DependencyPolicy, pipeline string, deps []gaia.Dependency, now time.Time) []string {
+	var violations []string
+	for _, dep := range deps {
+		if waived(policy, pipeline, dep.Path, now) {
+			continue
+		}
+
+		for _, l := range policy.ForbiddenLicenses {
+			if strings.EqualFold(dep.License, l) {
+				violations = append(violations, fmt.Sprintf("%s: license %s is forbidden", dep.Path, dep.License))
+			}
+		}
+
+		for _, v := range policy.Vulnerable {
+			if v.Path != dep.Path || !matchesVersion(dep.Version, v.Versions) {
+				continue
+			}
+			violation := fmt.Sprintf("%s@%s: version is vulnerable", dep.Path, dep.Version)
+			if v.Reason != "" {
+				violation += " (" + v.Reason + ")"
+			}
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// waived returns true if an approved and not expired waiver allows
+// the given pipeline to use the given dependency.
+func waived(policy *gaia.DependencyPolicy, pipeline, dependency string, now time.Time) bool {
+	for _, w := range policy.Waivers {
+		if w.Approved && w.Pipeline == pipeline && w.Dependency == dependency && (w.Expires.IsZero() || now.Before(w.Expires)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesVersion returns true if the given version is one of the
+// given versions. Commits also match by their beginning.
+func matchesVersion(version string, versions []string) bool {
+	if version == "" {
+		return false
+	}
+	for _, v := range versions {
+		if version == v || (len(version) == commitLength && v != "" && strings.HasPrefix(version, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDependency returns the dependency the package in the given
+// folder belongs to. The given roots are the folders which hold the
+// import paths (e.g. the src folder of a GOPATH). Vendored packages
+// are resolved by the path below the vendor folder.
+// Returns false if the folder is not below one of the roots.
+func resolveDependency(dir string, roots []string) (gaia.Dependency, bool) {
+	root := ""
+	for _, r := range roots {
+		if r != "" && strings.HasPrefix(dir, r+string(filepath.Separator)) {
+			root = r
+			break
+		}
+	}
+	if root == "" {
+		return gaia.Dependency{}, false
+	}
+
+	// Vendored packages are resolved below the innermost vendor folder
+	rel := filepath.ToSlash(strings.TrimPrefix(dir, root+string(filepath.Separator)))
+	if i := strings.LastIndex("/"+rel, "/"+vendorFolder+"/"); i != -1 {
+		root = filepath.Join(root, filepath.FromSlash(rel[:i+len(vendorFolder)]))
+	}
+
+	// Look for the repo root and the license from the package upwards
+	repoRoot, licenseFile := "", ""
+	for d := dir; d != root && strings.HasPrefix(d, root); d = filepath.Dir(d) {
+		if licenseFile == "" {
+			licenseFile = findLicenseFile(d)
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			repoRoot = d
+			break
+		}
+	}
+
+	dep := gaia.Dependency{License: licenseUnknown}
+	depDir := dir
+	if repoRoot != "" {
+		depDir = repoRoot
+		dep.Version = gitVersion(repoRoot)
+	} else if licenseFile != "" {
+		depDir = filepath.Dir(licenseFile)
+	}
+	dep.Path = filepath.ToSlash(strings.TrimPrefix(depDir, root+string(filepath.Separator)))
+	if licenseFile != "" {
+		dep.License = detectLicense(licenseFile)
+	}
+	return dep, true
+}
+
+// findLicenseFile returns the license file of the given folder.
+// Returns an empty string if the folder has no license file.
+func findLicenseFile(dir string) string {
+	for _, name := range licenseFiles {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// detectLicense returns the license of the given license file.
+func detectLicense(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return licenseUnknown
+	}
+
+	// Line breaks and indentation differ between license files
+	text := strings.Join(strings.Fields(strings.ToLower(string(content))), " ")
+	for _, rule := range licenseRules {
+		if strings.Contains(text, rule.phrase) {
+			return rule.license
+		}
+	}
+	return licenseUnknown
+}
+
+// gitVersion returns the tag of the checked out commit of the given
+// repo. If the commit is not tagged, the commit itself is returned.
+func gitVersion(dir string) string {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return ""
+	}
+	head, err := r.Head()
+	if err != nil {
+		return ""
+	}
+
+	version := head.Hash().String()
+	tags, err := r.Tags()
+	if err != nil {
+		return version
+	}
+	tags.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		if tag, err := r.TagObject(target); err == nil {
+			target = tag.Target
+		}
+		if target == head.Hash() {
+			version = ref.Name().Short()
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return version
+}
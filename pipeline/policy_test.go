@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+func TestResolveDependency(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestResolveDependency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string]string{
+		"github.com/gaia/agpl/LICENSE":                "GNU AFFERO GENERAL PUBLIC LICENSE\n   Version 3",
+		"github.com/gaia/agpl/sub/sub.go":             "package sub",
+		"pipeline/vendor/github.com/gaia/mit/LICENSE": "Permission is hereby granted,\nfree of charge, to any person",
+		"pipeline/vendor/github.com/gaia/mit/mit.go":  "package mit",
+		"github.com/gaia/nolicense/nolicense.go":      "package nolicense",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]gaia.Dependency{
+		"github.com/gaia/agpl/sub":            {Path: "github.com/gaia/agpl", License: "AGPL-3.0"},
+		"pipeline/vendor/github.com/gaia/mit": {Path: "github.com/gaia/mit", License: "MIT"},
+		"github.com/gaia/nolicense":           {Path: "github.com/gaia/nolicense", License: licenseUnknown},
+	}
+	for dir, dep := range expected {
+		resolved, ok := resolveDependency(filepath.Join(tmp, filepath.FromSlash(dir)), []string{tmp})
+		if !ok {
+			t.Fatalf("cannot resolve dependency %s", dir)
+		}
+		if resolved != dep {
+			t.Fatalf("expected %+v, got %+v", dep, resolved)
+		}
+	}
+	if _, ok := resolveDependency("/usr/lib/go/src/fmt", []string{tmp}); ok {
+		t.Fatal("expected folder outside of the roots not to be resolved")
+	}
+}
+
+func TestPolicyViolations(t *testing.T) {
+	now := time.Now()
+	policy := &gaia.DependencyPolicy{
+		ForbiddenLicenses: []string{"agpl-3.0"},
+		Vulnerable: []gaia.VulnerableDependency{
+			{Path: "github.com/gaia/crypto", Versions: []string{"v1.0.0", "3f2a9c1"}, Reason: "CVE-2018-0001"},
+		},
+		Waivers: []gaia.PolicyWaiver{
+			{Pipeline: "release", Dependency: "github.com/gaia/agpl", Approved: true},
+			{Pipeline: "release", Dependency: "github.com/gaia/crypto", Approved: false},
+			{Pipeline: "nightly", Dependency: "github.com/gaia/agpl", Approved: true, Expires: now.Add(-time.Hour)},
+		},
+	}
+	deps := []gaia.Dependency{
+		{Path: "github.com/gaia/agpl", License: "AGPL-3.0"},
+		{Path: "github.com/gaia/crypto", Version: "3f2a9c1d8e7b6a5f4e3d2c1b0a9f8e7d6c5b4a39", License: "MIT"},
+		{Path: "github.com/gaia/clean", Version: "v1.0.0", License: "MIT"},
+	}
+
+	// Only the vulnerable dependency has no approved waiver
+	violations := policyViolations(policy, "release", deps, now)
+	if len(violations) != 1 || violations[0] != "github.com/gaia/crypto@3f2a9c1d8e7b6a5f4e3d2c1b0a9f8e7d6c5b4a39: version is vulnerable (CVE-2018-0001)" {
+		t.Fatalf("unexpected violations %v", violations)
+	}
+
+	// The waiver of the nightly pipeline is expired
+	violations = policyViolations(policy, "nightly", deps, now)
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", violations)
+	}
+}
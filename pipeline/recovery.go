@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// StuckRunsFail marks stuck runs as failed during repair.
+	StuckRunsFail = "fail"
+
+	// StuckRunsReschedule puts stuck runs back into the queue during repair.
+	StuckRunsReschedule = "reschedule"
+)
+
+var (
+	// errInvalidStuckRunsAction is thrown when an unknown repair action
+	// for stuck runs was given.
+	errInvalidStuckRunsAction = errors.New("invalid action for stuck runs given")
+
+	// recoveryReport holds the inconsistencies found during startup.
+	recoveryReport *RecoveryReport
+
+	// recoveryLock protects recoveryReport.
+	recoveryLock sync.Mutex
+)
+
+// RecoveryReport represents all inconsistencies which have been
+// detected during startup.
+type RecoveryReport struct {
+	Created         time.Time          `json:"created"`
+	TempDirs        []string           `json:"tempdirs"`
+	OrphanBinaries  []string           `json:"orphanbinaries"`
+	MissingBinaries []gaia.Pipeline    `json:"missingbinaries"`
+	StuckRuns       []gaia.PipelineRun `json:"stuckruns"`
+}
+
+// RecoveryRepairOptions defines which inconsistencies of the
+// recovery report should be repaired.
+type RecoveryRepairOptions struct {
+	// RemoveTempDirs removes all orphaned temp build folders.
+	RemoveTempDirs bool `json:"removetempdirs"`
+
+	// RemoveOrphanBinaries removes all pipeline binaries which had
+	// no store record.
+	RemoveOrphanBinaries bool `json:"removeorphanbinaries"`
+
+	// RemoveMissingBinaries removes all store records of pipelines
+	// whose binary does not exist.
+	RemoveMissingBinaries bool `json:"removemissingbinaries"`
+
+	// StuckRuns is either StuckRunsFail, StuckRunsReschedule or empty.
+	StuckRuns string `json:"stuckruns"`
+}
+
+// detectInconsistencies looks for leftovers of a previous gaia
+// instance. This must be called before the ticker and the scheduler
+// touch the pipelines folder and the store.
+func detectInconsistencies() (*RecoveryReport, error) {
+	report := &RecoveryReport{
+		Created: time.Now(),
+	}
+
+	// No build is running during startup. Every temp folder is orphaned.
+	srcFolders, err := filepath.Glob(filepath.Join(gaia.Cfg.HomePath, tmpFolder, "*", srcFolder, "*"))
+	if err != nil {
+		return nil, err
+	}
+	report.TempDirs = srcFolders
+
+	// Look for binaries without a store record
+	files, err := ioutil.ReadDir(gaia.Cfg.PipelinePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		n := strings.TrimSpace(file.Name())
+		pType, err := getPipelineType(n)
+		if err != nil {
+			continue
+		}
+
+		p, err := storeService.PipelineGetByName(getRealPipelineName(n, pType))
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			report.OrphanBinaries = append(report.OrphanBinaries, file.Name())
+		}
+	}
+
+	// Look for store records without a binary
+	pipelines, err := storeService.PipelineGetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pipelines {
+		// Binaries of deleted and archived pipelines are in the trash
+		// and the archive
+		if p.Deleted || p.Archived {
+			continue
+		}
+		if _, err := os.Stat(p.ExecPath); os.IsNotExist(err) {
+			report.MissingBinaries = append(report.MissingBinaries, p)
+		}
+	}
+
+	// The scheduler queue lives in memory. Scheduled and running
+	// runs will never be finished.
+	report.StuckRuns, err = storeService.PipelineGetRunsByStatus(gaia.RunScheduled, gaia.RunRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// GetRecoveryReport returns the recovery report which has been
+// created during startup.
+func GetRecoveryReport() *RecoveryReport {
+	recoveryLock.Lock()
+	defer recoveryLock.Unlock()
+
+	return recoveryReport
+}
+
+// RepairRecovery repairs the inconsistencies of the recovery report
+// which are selected in the given options.
+// It returns the remaining recovery report. Returns nil if no report
+// has been created during startup.
+func RepairRecovery(o *RecoveryRepairOptions) (*RecoveryReport, error) {
+	if o.StuckRuns != "" && o.StuckRuns != StuckRunsFail && o.StuckRuns != StuckRunsReschedule {
+		return nil, errInvalidStuckRunsAction
+	}
+
+	recoveryLock.Lock()
+	defer recoveryLock.Unlock()
+
+	// Nothing to repair
+	if recoveryReport == nil {
+		return nil, nil
+	}
+
+	if o.RemoveTempDirs {
+		for len(recoveryReport.TempDirs) > 0 {
+			if err := os.RemoveAll(recoveryReport.TempDirs[0]); err != nil {
+				return recoveryReport, err
+			}
+			recoveryReport.TempDirs = recoveryReport.TempDirs[1:]
+		}
+	}
+
+	if o.RemoveOrphanBinaries {
+		for len(recoveryReport.OrphanBinaries) > 0 {
+			n := recoveryReport.OrphanBinaries[0]
+			if err := os.Remove(filepath.Join(gaia.Cfg.PipelinePath, n)); err != nil && !os.IsNotExist(err) {
+				return recoveryReport, err
+			}
+
+			// The ticker might have already registered the binary.
+			pType, _ := getPipelineType(n)
+			pName := getRealPipelineName(n, pType)
+			p, err := storeService.PipelineGetByName(pName)
+			if err != nil {
+				return recoveryReport, err
+			}
+			if p != nil {
+				if err = storeService.PipelineDelete(p.ID); err != nil {
+					return recoveryReport, err
+				}
+			}
+			GlobalActivePipelines.Remove(pName)
+			recoveryReport.OrphanBinaries = recoveryReport.OrphanBinaries[1:]
+		}
+	}
+
+	if o.RemoveMissingBinaries {
+		for len(recoveryReport.MissingBinaries) > 0 {
+			if err := storeService.PipelineDelete(recoveryReport.MissingBinaries[0].ID); err != nil {
+				return recoveryReport, err
+			}
+			recoveryReport.MissingBinaries = recoveryReport.MissingBinaries[1:]
+		}
+	}
+
+	if o.StuckRuns != "" {
+		for len(recoveryReport.StuckRuns) > 0 {
+			r := recoveryReport.StuckRuns[0]
+			if o.StuckRuns == StuckRunsFail {
+				r.Status = gaia.RunFailed
+				r.FinishDate = time.Now()
+			} else {
+				r.Status = gaia.RunNotScheduled
+			}
+			if err := storeService.PipelinePutRun(&r); err != nil {
+				return recoveryReport, err
+			}
+			recoveryReport.StuckRuns = recoveryReport.StuckRuns[1:]
+		}
+	}
+
+	return recoveryReport, nil
+}
@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestRecovery(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestRecovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{
+		Logger:       hclog.NewNullLogger(),
+		HomePath:     tmp,
+		DataPath:     tmp,
+		PipelinePath: filepath.Join(tmp, "pipelines"),
+	}
+	gaia.Cfg.Bolt.Mode = 0600
+
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	GlobalActivePipelines = NewActivePipelines()
+	defer func() {
+		recoveryReport = nil
+	}()
+
+	// Leftovers of a previous instance
+	tempDir := filepath.Join(tmp, tmpFolder, golangFolder, srcFolder, "clone")
+	if err = os.MkdirAll(tempDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(gaia.Cfg.PipelinePath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"orphan_golang", "known_golang"} {
+		if err = ioutil.WriteFile(filepath.Join(gaia.Cfg.PipelinePath, n), []byte("binary"), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pipelines := []gaia.Pipeline{
+		{ID: 1, Name: "known", ExecPath: filepath.Join(gaia.Cfg.PipelinePath, "known_golang")},
+		{ID: 2, Name: "missing", ExecPath: filepath.Join(gaia.Cfg.PipelinePath, "missing_golang")},
+		{ID: 3, Name: "archived", ExecPath: filepath.Join(gaia.Cfg.PipelinePath, "archived_golang"), Archived: true},
+	}
+	for i := range pipelines {
+		if err = storeService.PipelinePut(&pipelines[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := []gaia.PipelineRun{
+		{ID: 1, PipelineID: 1, Status: gaia.RunRunning},
+		{ID: 2, PipelineID: 1, Status: gaia.RunScheduled},
+		{ID: 3, PipelineID: 1, Status: gaia.RunSuccess},
+	}
+	for i := range runs {
+		runs[i].UniqueID = uuid.Must(uuid.NewV4(), nil).String()
+		if err = storeService.PipelinePutRun(&runs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := detectInconsistencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.TempDirs) != 1 || report.TempDirs[0] != tempDir {
+		t.Fatalf("expected temp dir %s, got %v", tempDir, report.TempDirs)
+	}
+	if len(report.OrphanBinaries) != 1 || report.OrphanBinaries[0] != "orphan_golang" {
+		t.Fatalf("expected orphan binary orphan_golang, got %v", report.OrphanBinaries)
+	}
+	if len(report.MissingBinaries) != 1 || report.MissingBinaries[0].Name != "missing" {
+		t.Fatalf("expected missing binary of pipeline missing, got %v", report.MissingBinaries)
+	}
+	if len(report.StuckRuns) != 2 {
+		t.Fatalf("expected 2 stuck runs, got %d", len(report.StuckRuns))
+	}
+
+	// Nothing is repaired without a report
+	if report, err := RepairRecovery(&RecoveryRepairOptions{RemoveTempDirs: true}); report != nil || err != nil {
+		t.Fatalf("expected no report, got %+v (%v)", report, err)
+	}
+	recoveryReport = report
+
+	if _, err = RepairRecovery(&RecoveryRepairOptions{StuckRuns: "ignore"}); err != errInvalidStuckRunsAction {
+		t.Fatalf("expected error %v, got %v", errInvalidStuckRunsAction, err)
+	}
+
+	// Unselected inconsistencies are kept
+	report, err = RepairRecovery(&RecoveryRepairOptions{RemoveTempDirs: true, RemoveOrphanBinaries: true, StuckRuns: StuckRunsReschedule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.TempDirs) != 0 || len(report.OrphanBinaries) != 0 || len(report.StuckRuns) != 0 || len(report.MissingBinaries) != 1 {
+		t.Fatalf("expected only the missing binary to remain, got %+v", report)
+	}
+	if _, err = os.Stat(tempDir); !os.IsNotExist(err) {
+		t.Fatal("temp dir has not been removed")
+	}
+	if _, err = os.Stat(filepath.Join(gaia.Cfg.PipelinePath, "orphan_golang")); !os.IsNotExist(err) {
+		t.Fatal("orphan binary has not been removed")
+	}
+	if _, err = os.Stat(filepath.Join(gaia.Cfg.PipelinePath, "known_golang")); err != nil {
+		t.Fatal("binary of known pipeline has been removed")
+	}
+	for _, id := range []int{1, 2} {
+		r, err := storeService.PipelineGetRunByPipelineIDAndID(1, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != gaia.RunNotScheduled {
+			t.Fatalf("expected stuck run %d to be rescheduled, got %s", id, r.Status)
+		}
+	}
+
+	report, err = RepairRecovery(&RecoveryRepairOptions{RemoveMissingBinaries: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.MissingBinaries) != 0 {
+		t.Fatalf("expected no missing binaries, got %v", report.MissingBinaries)
+	}
+	if p, _ := storeService.PipelineGet(2); p.Name != "" {
+		t.Fatal("pipeline without binary has not been removed")
+	}
+	if p, _ := storeService.PipelineGet(3); p.Name != "archived" {
+		t.Fatal("archived pipeline has been removed")
+	}
+}
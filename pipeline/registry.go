@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// registryFolder is the folder in the home folder which holds the
+	// published pipeline binaries by their checksum.
+	registryFolder = "registry"
+
+	// registryMetaSuffix is the suffix of the file which describes the
+	// publications of a binary.
+	registryMetaSuffix = ".json"
+
+	// registryPruneInterval is the minimum interval between two prunes
+	// of the registry.
+	registryPruneInterval = time.Hour
+)
+
+var (
+	// errInvalidChecksum is thrown when a binary is requested with a
+	// checksum which is not a hex encoded sha256 checksum.
+	errInvalidChecksum = errors.New("invalid checksum given. Must be a hex encoded sha256 checksum")
+
+	// checksumPattern matches a hex encoded sha256 checksum.
+	checksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	// lastRegistryPrune is the time the registry has been pruned.
+	lastRegistryPrune time.Time
+
+	// registryLock serializes the changes of the registry meta files.
+	registryLock sync.Mutex
+)
+
+// publishBinaries publishes the binaries of the given build to the
+// registry. The binaries of the additional platforms are published too.
+func publishBinaries(p *gaia.CreatePipeline) error {
+	binaries := map[string]string{HostPlatform(): getBinaryDest(p)}
+	for platform, path := range p.Pipeline.Binaries {
+		binaries[platform] = path
+	}
+	for platform, path := range binaries {
+		err := publishBinary(path, gaia.RegistryBinary{
+			Pipeline: p.Pipeline.Name,
+			Type:     p.Pipeline.Type,
+			Platform: platform,
+			Commit:   p.Pipeline.Commit,
+			Shadow:   p.Shadow,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishBinary copies the binary at the given path to the registry
+// unless it has been published before. Identical binaries of several
+// pipelines or platforms keep a publication each. The publish date of
+// an existing publication is renewed.
+func publishBinary(path string, meta gaia.RegistryBinary) error {
+	checksum, err := getSHA256Sum(path)
+	if err != nil {
+		return err
+	}
+	folder := filepath.Join(gaia.Cfg.HomePath, registryFolder)
+	if err = os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+
+	// Binaries are never changed once they are published
+	meta.SHA256Sum = hex.EncodeToString(checksum)
+	dest := filepath.Join(folder, meta.SHA256Sum)
+	if _, err = os.Stat(dest); os.IsNotExist(err) {
+		if err = copyFileContents(path, dest+".tmp"); err != nil {
+			return err
+		}
+		if err = os.Rename(dest+".tmp", dest); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		return err
+	}
+	meta.Size = info.Size()
+	meta.Published = time.Now()
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	publications, err := readRegistryMeta(dest + registryMetaSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	updated := false
+	for i, b := range publications {
+		if b.Pipeline == meta.Pipeline && b.Platform == meta.Platform && b.Shadow == meta.Shadow {
+			publications[i] = meta
+			updated = true
+		}
+	}
+	if !updated {
+		publications = append(publications, meta)
+	}
+	content, err := json.Marshal(publications)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest+registryMetaSuffix, content, 0600)
+}
+
+// readRegistryMeta returns the publications of the given meta file.
+func readRegistryMeta(file string) ([]gaia.RegistryBinary, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	publications := []gaia.RegistryBinary{}
+	if err = json.Unmarshal(content, &publications); err == nil {
+		return publications, nil
+	}
+
+	// Older meta files hold a single publication
+	b := gaia.RegistryBinary{}
+	if err = json.Unmarshal(content, &b); err != nil {
+		return nil, err
+	}
+	return []gaia.RegistryBinary{b}, nil
+}
+
+// RegistryBinaryPath returns the path of the published binary with
+// the given checksum. Returns an empty path if it does not exist.
+func RegistryBinaryPath(checksum string) (string, error) {
+	checksum = strings.ToLower(checksum)
+	if !checksumPattern.MatchString(checksum) {
+		return "", errInvalidChecksum
+	}
+	path := filepath.Join(gaia.Cfg.HomePath, registryFolder, checksum)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// GetRegistryBinaries returns all publications of binaries. The
+// latest publications come first.
+func GetRegistryBinaries() ([]gaia.RegistryBinary, error) {
+	files, err := filepath.Glob(filepath.Join(gaia.Cfg.HomePath, registryFolder, "*"+registryMetaSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	binaries := []gaia.RegistryBinary{}
+	for _, file := range files {
+		publications, err := readRegistryMeta(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			gaia.Cfg.Logger.Warn("cannot read published binary", "error", err.Error(), "path", file)
+			continue
+		}
+		binaries = append(binaries, publications...)
+	}
+	sort.Slice(binaries, func(i, j int) bool {
+		return binaries[i].Published.After(binaries[j].Published)
+	})
+	return binaries, nil
+}
+
+// pruneRegistry removes the published binaries which have not been
+// published within the retention period. All binaries of the active
+// pipelines are kept, including their platform and shadow binaries.
+// The registry is pruned at most once per registryPruneInterval.
+func pruneRegistry() {
+	retention := gaia.Cfg.RegistryRetention
+	if retention <= 0 || time.Since(lastRegistryPrune) < registryPruneInterval {
+		return
+	}
+	lastRegistryPrune = time.Now()
+	binaries, err := GetRegistryBinaries()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get published binaries", "error", err.Error())
+		return
+	}
+
+	// A binary is kept as long as one of its publications is kept
+	active := activeChecksums()
+	keep := map[string]bool{}
+	for _, b := range binaries {
+		keep[b.SHA256Sum] = keep[b.SHA256Sum] || active[b.SHA256Sum] || time.Since(b.Published) < retention
+	}
+
+	// The file lists of the commits of kept binaries are kept with them
+	commits := map[string]bool{}
+	for _, b := range binaries {
+		if keep[b.SHA256Sum] {
+			commits[filepath.Join(appendTypeToName(b.Pipeline, b.Type), b.Commit.Hash)] = true
+		}
+	}
+	pruneSources(commits, retention)
+	for checksum, kept := range keep {
+		if kept {
+			continue
+		}
+		path := filepath.Join(gaia.Cfg.HomePath, registryFolder, checksum)
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			gaia.Cfg.Logger.Error("cannot remove published binary", "error", err.Error(), "path", path)
+			continue
+		}
+		os.Remove(path + registryMetaSuffix)
+	}
+}
+
+// activeChecksums returns the hex encoded checksums of all binaries of
+// the active pipelines.
+func activeChecksums() map[string]bool {
+	active := map[string]bool{}
+	for p := range GlobalActivePipelines.Iter() {
+		active[hex.EncodeToString(p.SHA256Sum)] = true
+		paths := []string{p.ShadowExecPath}
+		for _, path := range p.Binaries {
+			paths = append(paths, path)
+		}
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			if checksum, err := getSHA256Sum(path); err == nil {
+				active[hex.EncodeToString(checksum)] = true
+			}
+		}
+	}
+	return active
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestRegistry(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestRegistry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{HomePath: tmp, RegistryRetention: time.Hour, Logger: hclog.NewNullLogger()}
+	GlobalActivePipelines = NewActivePipelines()
+
+	binary := filepath.Join(tmp, "pipeline_golang")
+	if err := ioutil.WriteFile(binary, []byte("v1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := publishBinary(binary, gaia.RegistryBinary{Pipeline: "pipeline", Platform: "linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	checksum, _ := getSHA256Sum(binary)
+
+	path, err := RegistryBinaryPath(hex.EncodeToString(checksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "v1" {
+		t.Fatalf("expected published binary v1, got %q", content)
+	}
+	if _, err := RegistryBinaryPath("../data"); err != errInvalidChecksum {
+		t.Fatalf("expected error %v, got %v", errInvalidChecksum, err)
+	}
+
+	binaries, err := GetRegistryBinaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(binaries) != 1 || binaries[0].Pipeline != "pipeline" || binaries[0].Size != 2 {
+		t.Fatalf("unexpected published binaries %+v", binaries)
+	}
+
+	// Identical binaries keep a publication for each pipeline
+	if err := publishBinary(binary, gaia.RegistryBinary{Pipeline: "other", Platform: "linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := publishBinary(binary, gaia.RegistryBinary{Pipeline: "other", Platform: "linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	if binaries, _ = GetRegistryBinaries(); len(binaries) != 2 {
+		t.Fatalf("expected two publications, got %+v", binaries)
+	}
+
+	// Binaries within the retention and of active pipelines are kept,
+	// including the binaries of the other platforms
+	arm := filepath.Join(tmp, "pipeline_golang_linux_arm64")
+	if err := ioutil.WriteFile(arm, []byte("v1 arm"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := publishBinary(arm, gaia.RegistryBinary{Pipeline: "pipeline", Platform: "linux/arm64"}); err != nil {
+		t.Fatal(err)
+	}
+	armChecksum, _ := getSHA256Sum(arm)
+	pruneRegistry()
+	gaia.Cfg.RegistryRetention = time.Nanosecond
+	GlobalActivePipelines.Append(gaia.Pipeline{Name: "pipeline", SHA256Sum: checksum, Binaries: map[string]string{"linux/arm64": arm}})
+	lastRegistryPrune = time.Time{}
+	pruneRegistry()
+	if path, _ = RegistryBinaryPath(hex.EncodeToString(checksum)); path == "" {
+		t.Fatal("published binary of active pipeline has been pruned")
+	}
+	if path, _ = RegistryBinaryPath(hex.EncodeToString(armChecksum)); path == "" {
+		t.Fatal("published platform binary of active pipeline has been pruned")
+	}
+
+	// The registry is not pruned on every tick
+	GlobalActivePipelines = NewActivePipelines()
+	pruneRegistry()
+	if path, _ = RegistryBinaryPath(hex.EncodeToString(checksum)); path == "" {
+		t.Fatal("expected registry not to be pruned again")
+	}
+
+	lastRegistryPrune = time.Time{}
+	pruneRegistry()
+	if path, _ = RegistryBinaryPath(hex.EncodeToString(checksum)); path != "" {
+		t.Fatal("expected published binary to be pruned")
+	}
+	if path, _ = RegistryBinaryPath(hex.EncodeToString(armChecksum)); path != "" {
+		t.Fatal("expected published platform binary to be pruned")
+	}
+}
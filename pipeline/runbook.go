@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// maxRunbookSize is the maximum size of a runbook in bytes.
+	maxRunbookSize = 256 * 1024
+
+	// readmeUpdater is the updater of runbooks which have been synced
+	// from the readme of the repo.
+	readmeUpdater = "readme"
+)
+
+var (
+	// errRunbookTooLarge is thrown when a runbook exceeds the maximum size.
+	errRunbookTooLarge = errors.New("runbook must not be larger than 256KB")
+
+	// errReadmeNotRegular is thrown when the readme of a repo is a
+	// symlink or no regular file.
+	errReadmeNotRegular = errors.New("readme must be a regular file inside of the repo")
+
+	// readmeNames are the file names of readmes in the order they are looked up.
+	readmeNames = []string{"README.md", "README.markdown", "Readme.md", "readme.md", "README"}
+)
+
+// ValidateRunbook checks the given runbook.
+func ValidateRunbook(r *gaia.Runbook) error {
+	if len(r.Content) > maxRunbookSize {
+		return errRunbookTooLarge
+	}
+	return nil
+}
+
+// syncRunbook sets the runbook of the given build to the readme of the
+// cloned repo if the runbook of the pipeline is synced. Runbooks of
+// registered pipelines are only changed by syncs.
+func syncRunbook(p *gaia.CreatePipeline) error {
+	registered, err := storeService.PipelineGetByName(p.Pipeline.Name)
+	if err != nil {
+		return err
+	}
+	runbook := p.Pipeline.Runbook
+	if registered != nil {
+		runbook = registered.Runbook
+	}
+	if runbook == nil || !runbook.SyncReadme {
+		if registered != nil {
+			p.Pipeline.Runbook = nil
+		}
+		return nil
+	}
+
+	content, err := readReadme(p.Pipeline.Repo.LocalDest)
+	if err != nil {
+		return err
+	}
+	p.Pipeline.Runbook = &gaia.Runbook{
+		Content:    content,
+		SyncReadme: true,
+		Updated:    time.Now(),
+		UpdatedBy:  readmeUpdater,
+	}
+	return nil
+}
+
+// readReadme returns the readme of the repo in the given folder.
+// Returns an empty string if the repo has no readme. Symlinks are
+// rejected, so a repo cannot show files outside of the repo.
+func readReadme(dir string) (string, error) {
+	for _, name := range readmeNames {
+		path := filepath.Join(dir, name)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		if !info.Mode().IsRegular() {
+			return "", errReadmeNotRegular
+		}
+		if info.Size() > maxRunbookSize {
+			return "", errRunbookTooLarge
+		}
+
+		// The repo folder itself may be behind a symlink
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return "", err
+		}
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", err
+		}
+		if filepath.Dir(resolved) != root {
+			return "", errReadmeNotRegular
+		}
+
+		f, err := os.Open(resolved)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		content, err := ioutil.ReadAll(io.LimitReader(f, maxRunbookSize+1))
+		if err != nil {
+			return "", err
+		} else if len(content) > maxRunbookSize {
+			return "", errRunbookTooLarge
+		}
+		return string(content), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadReadme(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestReadReadme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	content, err := readReadme(tmp)
+	if err != nil || content != "" {
+		t.Fatalf("expected no readme, got %q, %v", content, err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(tmp, "README"), []byte("plain"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(tmp, "README.md"), []byte("# Runbook"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = readReadme(tmp); err != nil || content != "# Runbook" {
+		t.Fatalf("expected markdown readme, got %q, %v", content, err)
+	}
+
+	large := strings.Repeat("x", maxRunbookSize+1)
+	if err = ioutil.WriteFile(filepath.Join(tmp, "README.md"), []byte(large), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readReadme(tmp); err != errRunbookTooLarge {
+		t.Fatalf("expected error %v, got %v", errRunbookTooLarge, err)
+	}
+
+	// Readmes must not point outside of the repo
+	secret := filepath.Join(tmp, "vault.passphrase")
+	if err = ioutil.WriteFile(secret, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	repo := filepath.Join(tmp, "repo")
+	if err = os.Mkdir(repo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(secret, filepath.Join(repo, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = readReadme(repo); err != errReadmeNotRegular {
+		t.Fatalf("expected error %v, got %q, %v", errReadmeNotRegular, content, err)
+	}
+}
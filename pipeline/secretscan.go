@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// secretScanAllowMarker excludes the line it is placed on from
+	// the secrets scanning.
+	secretScanAllowMarker = "gaia:allow-secret"
+
+	// maxSecretScanFileSize is the size of the largest file which is
+	// scanned. Larger files are most likely not source files.
+	maxSecretScanFileSize = 1024 * 1024
+)
+
+// secretRule detects one kind of committed credentials.
+type secretRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// secretRules are the rules the pipeline repos are scanned with.
+var secretRules = []secretRule{
+	{"aws access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP) )?PRIVATE KEY( BLOCK)?-----`)},
+	{"github token", regexp.MustCompile(`\bgh[pousr]_[0-9A-Za-z]{36}\b`)},
+	{"slack token", regexp.MustCompile(`\bxox[abprs]-[0-9A-Za-z-]{10,48}\b`)},
+	{"google api key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{"generic secret", regexp.MustCompile(`(?i)(password|passwd|secret|api_?key|access_?token)\s*[:=]\s*["'][^"'\s]{8,}["']`)},
+}
+
+// secretFinding represents credentials found in a pipeline repo.
+// The credentials itself are not kept.
+type secretFinding struct {
+	File string
+	Line int
+	Rule string
+}
+
+// String returns the finding in a human readable form.
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Rule)
+}
+
+// scanSecrets scans all files of the given folder for committed
+// credentials. The file names of the findings are relative to the
+// given folder. Binary files and the git folder are skipped.
+func scanSecrets(folder string) ([]secretFinding, error) {
+	findings := []secretFinding{}
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() > maxSecretScanFileSize {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(content, 0) != -1 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, scanSecretsInFile(filepath.ToSlash(rel), content)...)
+		return nil
+	})
+	return findings, err
+}
+
+// scanSecretsInFile applies the secret rules to every line of the
+// given file content. Lines with the allow marker are skipped.
+func scanSecretsInFile(name string, content []byte) []secretFinding {
+	var findings []secretFinding
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSecretScanFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.Contains(text, secretScanAllowMarker) {
+			continue
+		}
+		for _, rule := range secretRules {
+			if rule.pattern.MatchString(text) {
+				findings = append(findings, secretFinding{File: name, Line: line, Rule: rule.name})
+			}
+		}
+	}
+	return findings
+}
+
+// secretScanReport returns the build output which lists the given findings.
+func secretScanReport(findings []secretFinding) string {
+	report := fmt.Sprintf("found %d committed credentials in repo. Mark false positives with %s\n", len(findings), secretScanAllowMarker)
+	for _, f := range findings {
+		report += f.String() + "\n"
+	}
+	return report
+}
@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// shadowFolder is the folder in the home folder where rebuilt
+	// binaries are kept until they are promoted.
+	shadowFolder = "shadow"
+)
+
+var (
+	// errNoShadowBuild is thrown when a pipeline has no shadow binary.
+	errNoShadowBuild = errors.New("pipeline has no shadow build")
+)
+
+// getBinaryDest returns the destination of the compiled binary.
+// Shadow builds are kept aside, all others go to the pipeline folder.
+func getBinaryDest(p *gaia.CreatePipeline) string {
+	n := appendTypeToName(p.Pipeline.Name, p.Pipeline.Type)
+	if p.Shadow {
+		return filepath.Join(gaia.Cfg.HomePath, shadowFolder, n)
+	}
+	return filepath.Join(gaia.Cfg.PipelinePath, n)
+}
+
+// PromoteShadow replaces the binary of the pipeline with the given id
+// by its shadow binary. The ticker picks up the new binary afterwards.
+// Returns nil if the pipeline was not found.
+func PromoteShadow(id int) (*gaia.Pipeline, error) {
+	p, err := storeService.PipelineGet(id)
+	if err != nil {
+		return nil, err
+	} else if p.Name == "" {
+		return nil, nil
+	} else if p.ShadowExecPath == "" {
+		return nil, errNoShadowBuild
+	}
+
+	// Replace binary
+	if err = copyFileContents(p.ShadowExecPath, p.ExecPath); err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(p.ExecPath, 0766); err != nil {
+		return nil, err
+	}
+
+	// The runs count towards the commit of the promoted binary
+	if _, err = UpdatePipeline(id, func(p *gaia.Pipeline) { p.Commit = p.ShadowCommit }); err != nil {
+		return nil, err
+	}
+	return DiscardShadow(id)
+}
+
+// DiscardShadow removes the shadow binary of the pipeline with the given id.
+// Returns nil if the pipeline was not found.
+func DiscardShadow(id int) (*gaia.Pipeline, error) {
+	var shadowExecPath string
+	p, err := UpdatePipeline(id, func(p *gaia.Pipeline) {
+		if p.ShadowExecPath != "" {
+			shadowExecPath = p.ShadowExecPath
+		}
+		p.ShadowExecPath = ""
+		p.ShadowRunID = 0
+		p.ShadowCommit = gaia.Commit{}
+	})
+	if err != nil || p == nil {
+		return p, err
+	}
+
+	if shadowExecPath != "" {
+		if err = os.Remove(shadowExecPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestShadow(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestShadow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: tmp, DataPath: tmp}
+	gaia.Cfg.Bolt.Mode = 0600
+
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	GlobalActivePipelines = NewActivePipelines()
+
+	write := func(path, content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := &gaia.Pipeline{
+		ID:             1,
+		Name:           "shadow",
+		ExecPath:       filepath.Join(tmp, "current"),
+		ShadowExecPath: filepath.Join(tmp, "shadow"),
+		ShadowRunID:    -2,
+		ShadowCommit:   gaia.Commit{Hash: "new"},
+		Commit:         gaia.Commit{Hash: "old"},
+	}
+	write(p.ExecPath, "v1")
+	write(p.ShadowExecPath, "v2")
+	if err = storeService.PipelinePut(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// Promoted shadow binary replaces the binary
+	if p, err = PromoteShadow(1); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(p.ExecPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "v2" || p.Commit.Hash != "new" {
+		t.Fatalf("expected binary v2 of commit new, got %s of commit %s", content, p.Commit.Hash)
+	}
+	if p.ShadowExecPath != "" || p.ShadowRunID != 0 {
+		t.Fatalf("expected shadow to be cleared, got %+v", p)
+	}
+	if _, err = os.Stat(filepath.Join(tmp, "shadow")); !os.IsNotExist(err) {
+		t.Fatal("shadow binary has not been removed")
+	}
+	if _, err = PromoteShadow(1); err != errNoShadowBuild {
+		t.Fatalf("expected error %v, got %v", errNoShadowBuild, err)
+	}
+
+	// Discarded shadow binary keeps the binary
+	write(filepath.Join(tmp, "shadow"), "v3")
+	if _, err = UpdatePipeline(1, func(p *gaia.Pipeline) { p.ShadowExecPath = filepath.Join(tmp, "shadow") }); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = DiscardShadow(1); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ = ioutil.ReadFile(p.ExecPath); string(content) != "v2" || p.ShadowExecPath != "" {
+		t.Fatalf("expected binary v2 without shadow, got %s and %+v", content, p)
+	}
+	if _, err = os.Stat(filepath.Join(tmp, "shadow")); !os.IsNotExist(err) {
+		t.Fatal("shadow binary has not been removed")
+	}
+
+	// Unknown pipelines
+	if p, err = DiscardShadow(2); p != nil || err != nil {
+		t.Fatalf("expected no pipeline, got %+v (%v)", p, err)
+	}
+}
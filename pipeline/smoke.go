@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// rollbackFolder is the folder in the home folder where the previous
+	// binaries of rebuilt pipelines are kept until their smoke run succeeded.
+	rollbackFolder = "rollback"
+)
+
+// keepPreviousBuild copies all binaries of the given pipeline aside
+// and records its build details, so the build can be restored if the
+// smoke run of the rebuild does not succeed.
+func keepPreviousBuild(p *gaia.Pipeline) (*gaia.Rollback, error) {
+	folder := filepath.Join(gaia.Cfg.HomePath, rollbackFolder)
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return nil, err
+	}
+	rollback := &gaia.Rollback{
+		ExecPath:         filepath.Join(folder, filepath.Base(p.ExecPath)),
+		Files:            map[string]string{},
+		Commit:           p.Commit,
+		Platforms:        p.Platforms,
+		Binaries:         p.Binaries,
+		Contract:         p.Contract,
+		Runbook:          p.Runbook,
+		WASMCapabilities: p.WASMCapabilities,
+	}
+	rollback.Files[p.ExecPath] = rollback.ExecPath
+	for _, path := range p.Binaries {
+		rollback.Files[path] = filepath.Join(folder, filepath.Base(path))
+	}
+	for path, kept := range rollback.Files {
+		if err := copyFileContents(path, kept); err != nil {
+			removeKeptBuild(rollback)
+			return nil, err
+		}
+	}
+	return rollback, nil
+}
+
+// removeKeptBuild removes the kept copies of the given previous build.
+func removeKeptBuild(rollback *gaia.Rollback) {
+	os.Remove(rollback.ExecPath)
+	for _, kept := range rollback.Files {
+		os.Remove(kept)
+	}
+}
+
+// holdForSmokeRun marks the pipeline with the given id as waiting for
+// the smoke run of the given previous build. Other runs of the pipeline
+// are held until the smoke run finished.
+func holdForSmokeRun(id int, previous *gaia.Rollback) error {
+	_, err := UpdatePipeline(id, func(p *gaia.Pipeline) {
+		p.Rollback = previous
+	})
+	return err
+}
+
+// releaseSmokeHold removes the hold of the pipeline with the given id
+// and the kept copies of the given previous build. The rebuilt binary
+// is kept as it is without a smoke run.
+func releaseSmokeHold(id int, previous *gaia.Rollback) {
+	_, err := UpdatePipeline(id, func(p *gaia.Pipeline) {
+		p.Rollback = nil
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot release pipeline after smoke run", "error", err.Error(), "pipeline", id)
+		return
+	}
+	removeKeptBuild(previous)
+}
+
+// startSmokeRun schedules the smoke run of the rebuilt pipeline with the
+// given id. The given previous build is restored if the smoke run does
+// not succeed.
+func startSmokeRun(id int, previous *gaia.Rollback) error {
+	p, err := storeService.PipelineGet(id)
+	if err != nil {
+		return err
+	}
+	r, err := schedulerService.ScheduleSmokeRun(p)
+	if err != nil {
+		return err
+	}
+	_, err = UpdatePipeline(id, func(p *gaia.Pipeline) {
+		previous.RunID = r.ID
+		p.Rollback = previous
+	})
+	return err
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const (
+	// sourcesFolder is the folder in the home folder which holds the
+	// file lists of the built commits by pipeline.
+	sourcesFolder = "sources"
+)
+
+// getSourcesPath returns the path of the file list of the given commit
+// of the given pipeline.
+func getSourcesPath(p *gaia.Pipeline, commit string) string {
+	return filepath.Join(gaia.Cfg.HomePath, sourcesFolder, appendTypeToName(p.Name, p.Type), commit)
+}
+
+// recordSourceFiles stores the paths of all files of the commit which
+// is checked out in the repo folder of the given pipeline. File
+// references in the job logs are resolved against them.
+func recordSourceFiles(p *gaia.Pipeline) error {
+	r, err := git.PlainOpen(p.Repo.LocalDest)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	path := getSourcesPath(p, head.Hash().String())
+	if _, err = os.Stat(path); err == nil {
+		return nil
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+	var paths []string
+	err = files.ForEach(func(f *object.File) error {
+		paths = append(paths, f.Name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(strings.Join(paths, "\n")), 0600)
+}
+
+// GetSourceFiles returns the paths of all files of the given commit of
+// the given pipeline. Returns nil if the commit has not been built.
+func GetSourceFiles(p *gaia.Pipeline, commit string) ([]string, error) {
+	if commit == "" || strings.ContainsAny(commit, `/\.`) {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(getSourcesPath(p, commit))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(content), "\n"), nil
+}
+
+// pruneSources removes the file lists which are older than the given
+// retention. Lists of the current commits of the active pipelines and
+// of the given commits are kept. Commits are given as pipeline folder
+// and commit hash.
+func pruneSources(keep map[string]bool, retention time.Duration) {
+	for p := range GlobalActivePipelines.Iter() {
+		keep[filepath.Join(appendTypeToName(p.Name, p.Type), p.Commit.Hash)] = true
+	}
+
+	root := filepath.Join(gaia.Cfg.HomePath, sourcesFolder)
+	folders, err := ioutil.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, folder := range folders {
+		lists, err := ioutil.ReadDir(filepath.Join(root, folder.Name()))
+		if err != nil {
+			continue
+		}
+		for _, list := range lists {
+			commit := filepath.Join(folder.Name(), list.Name())
+			if keep[commit] || time.Since(list.ModTime()) < retention {
+				continue
+			}
+			if err = os.Remove(filepath.Join(root, commit)); err != nil {
+				gaia.Cfg.Logger.Error("cannot remove file list of commit", "error", err.Error(), "commit", commit)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestPruneSources(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestPruneSources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{HomePath: tmp, Logger: hclog.NewNullLogger()}
+	GlobalActivePipelines = NewActivePipelines()
+	p := gaia.Pipeline{Name: "pipeline", Type: gaia.PTypeGolang, Commit: gaia.Commit{Hash: "current"}}
+	GlobalActivePipelines.Append(p)
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, commit := range []string{"current", "published", "outdated", "recent"} {
+		path := getSourcesPath(&p, commit)
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte("main.go"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if commit != "recent" {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	pruneSources(map[string]bool{filepath.Join("pipeline_golang", "published"): true}, time.Hour)
+	for commit, kept := range map[string]bool{"current": true, "published": true, "outdated": false, "recent": true} {
+		files, err := GetSourceFiles(&p, commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (files != nil) != kept {
+			t.Fatalf("expected file list of commit %s to be kept: %t", commit, kept)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	// templateIndexFile is the file in the root of the template index
+	// repo which lists all templates.
+	templateIndexFile = "index.json"
+
+	// templatePreset is the name of the preset which holds the secrets
+	// and parameters of an imported template.
+	templatePreset = "template"
+
+	// templateSecretPlaceholder is stored for secrets of an imported
+	// template which do not exist in the vault yet.
+	templateSecretPlaceholder = "CHANGEME"
+
+	// defaultTemplateBranch is used for templates without branch.
+	defaultTemplateBranch = "master"
+)
+
+var (
+	// errNoTemplateIndex is thrown when templates were requested but
+	// no template index has been configured.
+	errNoTemplateIndex = errors.New("no template index configured")
+
+	// errTemplateNotFound is thrown when a template was not found
+	// in the template index.
+	errTemplateNotFound = errors.New("template not found with the given name")
+
+	// errPipelineExists is thrown when a template should be imported
+	// with the name of an existing pipeline.
+	errPipelineExists = errors.New("pipeline with the given name already exists")
+)
+
+// GetTemplates clones the configured template index and returns
+// all templates listed in it.
+func GetTemplates() ([]gaia.PipelineTemplate, error) {
+	if gaia.Cfg.TemplateIndex == "" {
+		return nil, errNoTemplateIndex
+	}
+
+	// Clone index into a temp folder
+	dest := filepath.Join(gaia.Cfg.HomePath, tmpFolder, "templates", uuid.Must(uuid.NewV4(), nil).String())
+	defer os.RemoveAll(dest)
+	repo := &gaia.GitRepo{
+		URL:            gaia.Cfg.TemplateIndex,
+		SelectedBranch: templateBranch(""),
+		LocalDest:      dest,
+	}
+	if err := gitCloneRepo(repo); err != nil {
+		return nil, err
+	}
+
+	return readTemplateIndex(dest)
+}
+
+// ImportTemplate looks up the template with the given name and
+// prepares the creation of a pipeline with the given name from it.
+// The secrets and parameters of the template are kept in the
+// template preset of the new pipeline.
+func ImportTemplate(template, name string) (*gaia.CreatePipeline, error) {
+	existing, err := storeService.PipelineGetByName(name)
+	if err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, errPipelineExists
+	}
+
+	templates, err := GetTemplates()
+	if err != nil {
+		return nil, err
+	}
+	t := findTemplate(templates, template)
+	if t == nil {
+		return nil, errTemplateNotFound
+	}
+
+	return newTemplatePipeline(t, name), nil
+}
+
+// readTemplateIndex reads the template index file of the given folder.
+func readTemplateIndex(folder string) ([]gaia.PipelineTemplate, error) {
+	data, err := ioutil.ReadFile(filepath.Join(folder, templateIndexFile))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := []gaia.PipelineTemplate{}
+	if err = json.Unmarshal(data, &templates); err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		if templates[i].Type == "" {
+			templates[i].Type = gaia.PTypeGolang
+		}
+	}
+	return templates, nil
+}
+
+// findTemplate returns the template with the given name.
+// Returns nil if no such template exists.
+func findTemplate(templates []gaia.PipelineTemplate, name string) *gaia.PipelineTemplate {
+	for i := range templates {
+		if templates[i].Name == name {
+			return &templates[i]
+		}
+	}
+	return nil
+}
+
+// newTemplatePipeline creates the pipeline with the given name from
+// the given template.
+func newTemplatePipeline(t *gaia.PipelineTemplate, name string) *gaia.CreatePipeline {
+	params := map[string]string{}
+	for k, v := range t.Params {
+		params[k] = v
+	}
+
+	return &gaia.CreatePipeline{
+		Template: t.Name,
+		Pipeline: gaia.Pipeline{
+			Name: name,
+			Type: t.Type,
+			Repo: gaia.GitRepo{
+				URL:            t.URL,
+				SelectedBranch: templateBranch(t.Branch),
+			},
+			Presets: []gaia.RunPreset{{
+				Name:    templatePreset,
+				Secrets: append([]string(nil), t.Secrets...),
+				Params:  params,
+			}},
+		},
+	}
+}
+
+// templateBranch returns the reference of the given template branch.
+func templateBranch(branch string) string {
+	if branch == "" {
+		branch = defaultTemplateBranch
+	}
+	if strings.HasPrefix(branch, refHead) {
+		return branch
+	}
+	return refHead + "/" + branch
+}
+
+// registerTemplatePipeline stores the pipeline which is created from
+// a template before its binary exists. This way the ticker picks up
+// the template preset. Missing secrets of the template are created
+// with a placeholder value. They are not granted to the pipeline as
+// the template is third-party code. Admins grant them explicitly after
+// they reviewed the template.
+func registerTemplatePipeline(p *gaia.CreatePipeline) error {
+	pipeline := &gaia.Pipeline{
+		Name:     p.Pipeline.Name,
+		Type:     p.Pipeline.Type,
+		ExecPath: getBinaryDest(p),
+		Presets:  p.Pipeline.Presets,
+		Created:  time.Now(),
+	}
+	if err := storeService.PipelinePut(pipeline); err != nil {
+		return err
+	}
+
+	var secrets []string
+	for _, preset := range p.Pipeline.Presets {
+		if preset.Name == templatePreset {
+			secrets = preset.Secrets
+		}
+	}
+	for _, key := range secrets {
+		value, err := storeService.VaultGet(key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			if err = storeService.VaultPut(key, []byte(templateSecretPlaceholder)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
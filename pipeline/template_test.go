@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+	"github.com/gaia-pipeline/gaia/store"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestReadTemplateIndex(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestReadTemplateIndex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	index := `[
+		{"name": "go-service", "url": "https://github.com/gaia-pipeline/go-service", "secrets": ["DOCKER_PASSWORD"], "params": {"image": ""}},
+		{"name": "release", "url": "https://github.com/gaia-pipeline/release", "branch": "stable", "type": "golang"}
+	]`
+	if err = ioutil.WriteFile(filepath.Join(tmp, templateIndexFile), []byte(index), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := readTemplateIndex(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 2 {
+		t.Fatalf("expected 2 templates, got %d", len(templates))
+	}
+	if templates[0].Type != gaia.PTypeGolang {
+		t.Fatalf("expected default type %s, got %s", gaia.PTypeGolang, templates[0].Type)
+	}
+	if findTemplate(templates, "missing") != nil {
+		t.Fatal("expected no template for unknown name")
+	}
+
+	tpl := findTemplate(templates, "go-service")
+	if tpl == nil {
+		t.Fatal("expected to find template")
+	}
+	p := newTemplatePipeline(tpl, "my-service")
+	if p.Template != "go-service" || p.Pipeline.Name != "my-service" {
+		t.Fatalf("unexpected pipeline %#v", p)
+	}
+	if p.Pipeline.Repo.SelectedBranch != "refs/heads/master" {
+		t.Fatalf("expected default branch, got %s", p.Pipeline.Repo.SelectedBranch)
+	}
+	preset := p.Pipeline.Presets[0]
+	if preset.Name != templatePreset || len(preset.Secrets) != 1 || preset.Secrets[0] != "DOCKER_PASSWORD" {
+		t.Fatalf("unexpected preset %#v", preset)
+	}
+	if _, ok := preset.Params["image"]; !ok {
+		t.Fatal("expected placeholder for parameter image")
+	}
+
+	// The preset must not share the params of the template
+	preset.Params["image"] = "gaia"
+	if tpl.Params["image"] != "" {
+		t.Fatal("template params have been changed")
+	}
+
+	p = newTemplatePipeline(findTemplate(templates, "release"), "release")
+	if p.Pipeline.Repo.SelectedBranch != "refs/heads/stable" {
+		t.Fatalf("expected branch refs/heads/stable, got %s", p.Pipeline.Repo.SelectedBranch)
+	}
+}
+
+func TestRegisterTemplatePipeline(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestRegisterTemplatePipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	gaia.Cfg = &gaia.Config{Logger: hclog.NewNullLogger(), HomePath: tmp, DataPath: tmp, PipelinePath: tmp, VaultPassphrase: "secret"}
+	gaia.Cfg.Bolt.Mode = 0600
+
+	storeService = store.NewStore()
+	if err = storeService.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer storeService.Close()
+	if err = storeService.VaultPut("DOCKER_PASSWORD", []byte("password")); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &gaia.PipelineTemplate{Name: "go-service", Type: gaia.PTypeGolang, Secrets: []string{"DOCKER_PASSWORD", "NPM_TOKEN"}}
+	if err = registerTemplatePipeline(newTemplatePipeline(tpl, "my-service")); err != nil {
+		t.Fatal(err)
+	}
+	p, err := storeService.PipelineGetByName("my-service")
+	if err != nil || p == nil {
+		t.Fatalf("expected stored pipeline, got %v", err)
+	}
+
+	// Existing secrets are not handed to third-party code
+	if grants, _ := storeService.SecretGrantsGet(p.ID); len(grants) != 0 {
+		t.Fatalf("expected no granted secrets, got %v", grants)
+	}
+	if value, _ := storeService.VaultGet("NPM_TOKEN"); string(value) != templateSecretPlaceholder {
+		t.Fatalf("expected placeholder for missing secret, got %q", value)
+	}
+	if value, _ := storeService.VaultGet("DOCKER_PASSWORD"); string(value) != "password" {
+		t.Fatalf("existing secret has been changed to %q", value)
+	}
+}
@@ -16,9 +16,9 @@ import (
 )
 
 const (
-	// tickerIntervalSeconds defines how often the ticker will tick.
-	// Definition in seconds.
-	tickerIntervalSeconds = 5
+	// defaultPollInterval defines how often the ticker will tick if
+	// no poll interval has been set.
+	defaultPollInterval = 5 * time.Second
 )
 
 // storeService is an instance of store.
@@ -38,22 +38,47 @@ func InitTicker(store *store.Store, scheduler *scheduler.Scheduler) {
 	storeService = store
 	schedulerService = scheduler
 
+	// Look for leftovers of a previous instance before we touch anything.
+	report, err := detectInconsistencies()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot create recovery report", "error", err.Error())
+	} else {
+		recoveryLock.Lock()
+		recoveryReport = report
+		recoveryLock.Unlock()
+	}
+
 	// Check immediately to make sure we fill the list as fast as possible.
 	checkActivePipelines()
 
-	// Create ticker
-	ticker := time.NewTicker(tickerIntervalSeconds * time.Second)
+	// A standby resumes the builds once it has been promoted
+	if gaia.Cfg.MirrorURL == "" {
+		resumeBuilds()
+	}
+
+	// Tick with the current poll interval. It can be changed at runtime.
 	go func() {
-		defer ticker.Stop()
 		for {
-			select {
-			case <-ticker.C:
-				checkActivePipelines()
+			time.Sleep(pollInterval())
+
+			// The primary purges the pipelines of a standby
+			if !IsStandby() {
+				purgeDeletedPipelines()
 			}
+			pruneRegistry()
+			checkActivePipelines()
 		}
 	}()
 }
 
+// pollInterval returns the current poll interval of the ticker.
+func pollInterval() time.Duration {
+	if i := gaia.GetSettings().PollInterval; i > 0 {
+		return i
+	}
+	return defaultPollInterval
+}
+
 // checkActivePipelines looks up all files in the pipeline folder.
 // Every file will be handled as an active pipeline and therefore
 // saved in the global active pipelines slice.
@@ -113,6 +138,29 @@ func checkActivePipelines() {
 				continue
 			}
 
+			// A new binary replaces a deleted pipeline with the same name.
+			if pipeline != nil && pipeline.Deleted {
+				if err = purgePipeline(pipeline); err != nil {
+					gaia.Cfg.Logger.Error("cannot purge deleted pipeline", "error", err.Error(), "pipeline", pipeline.Name)
+					continue
+				}
+				pipeline = nil
+			}
+
+			// A new binary revives an archived pipeline with the same name.
+			if pipeline != nil && pipeline.Archived {
+				if err = removeArchivedBinaries(pipeline); err != nil {
+					gaia.Cfg.Logger.Error("cannot remove archived pipeline binary", "error", err.Error(), "pipeline", pipeline.Name)
+					continue
+				}
+				pipeline.Archived = false
+				pipeline.ArchiveDate = time.Time{}
+				if err = storeService.PipelineUpdate(pipeline); err != nil {
+					gaia.Cfg.Logger.Error("cannot unarchive pipeline", "error", err.Error(), "pipeline", pipeline.Name)
+					continue
+				}
+			}
+
 			// We couldn't finde the pipeline. Create a new one.
 			var shouldStore = false
 			if pipeline == nil {
@@ -123,6 +171,9 @@ func checkActivePipelines() {
 					ExecPath: filepath.Join(gaia.Cfg.PipelinePath, file.Name()),
 					Created:  time.Now(),
 				}
+				if build := latestBuild(pName); build != nil {
+					applyBuildDetails(pipeline, build)
+				}
 
 				// We should store it
 				shouldStore = true
@@ -154,6 +205,25 @@ func checkActivePipelines() {
 	}
 }
 
+// latestBuild returns the latest successful build of the pipeline with
+// the given name. Returns nil if the binary has not been built by gaia.
+func latestBuild(name string) *gaia.CreatePipeline {
+	builds, err := storeService.CreatePipelineGet()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get pipeline builds from store", "error", err.Error())
+		return nil
+	}
+
+	var latest *gaia.CreatePipeline
+	for i := range builds {
+		b := &builds[i]
+		if b.Pipeline.Name == name && b.StatusType == gaia.CreatePipelineSuccess && !b.Shadow && (latest == nil || b.Created.After(latest.Created)) {
+			latest = b
+		}
+	}
+	return latest
+}
+
 // getPipelineType looks up for specific suffix on the given file name.
 // If found, returns the pipeline type.
 func getPipelineType(n string) (gaia.PipelineType, error) {
@@ -169,6 +239,8 @@ func getPipelineType(n string) (gaia.PipelineType, error) {
 	switch t {
 	case gaia.PTypeGolang.String():
 		return gaia.PTypeGolang, nil
+	case gaia.PTypeWASM.String():
+		return gaia.PTypeWASM, nil
 	}
 
 	return gaia.PTypeUnknown, errMissingType
@@ -0,0 +1,253 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// entryTimeFormat is the time format of structured log entries in
+	// the plain job log.
+	entryTimeFormat = "2006-01-02T15:04:05.000Z0700"
+
+	// Keys of the structured log entries written by hclog with json format.
+	entryLevelKey     = "@level"
+	entryMessageKey   = "@message"
+	entryTimestampKey = "@timestamp"
+)
+
+// colorPattern matches the ANSI escape sequences which format terminal
+// output, e.g. colors and cursor movements.
+var colorPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// entryWriter splits the output of a job into lines. Every line is
+// stored as structured log entry. Jobs emit structured entries by
+// writing json lines to stderr, e.g. with a hclog logger in json
+// format. These entries are written human readable to the plain log.
+type entryWriter struct {
+	plain   io.Writer
+	entries io.Writer
+
+	// colors keeps the ANSI color codes in the plain log. They are
+	// always stripped from the structured entries.
+	colors bool
+
+	// line holds the incomplete last line.
+	line []byte
+}
+
+// newEntryWriter creates a new writer which writes the plain log to
+// plain and the structured entries to entries. Entries are dropped if
+// entries is nil. If colors is false, ANSI color codes are stripped
+// from the plain log.
+func newEntryWriter(plain, entries io.Writer, colors bool) *entryWriter {
+	return &entryWriter{plain: plain, entries: entries, colors: colors}
+}
+
+// Write implements io.Writer.
+func (w *entryWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(string(w.line[:i+1])); err != nil {
+			return 0, err
+		}
+		w.line = w.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the incomplete last line.
+func (w *entryWriter) Flush() error {
+	if len(w.line) == 0 {
+		return nil
+	}
+	err := w.writeLine(string(w.line))
+	w.line = nil
+	return err
+}
+
+// writeLine writes the given line to the plain log and the entries.
+func (w *entryWriter) writeLine(line string) error {
+	entry, ok := parseLogEntry(strings.TrimRight(line, "\r\n"))
+	if ok {
+		line = formatLogEntry(entry)
+	} else {
+		entry = &gaia.LogEntry{Time: time.Now(), Level: "info", Message: StripColors(strings.TrimRight(line, "\r\n"))}
+	}
+	if !w.colors {
+		line = StripColors(line)
+	}
+	if _, err := io.WriteString(w.plain, line); err != nil {
+		return err
+	}
+	if w.entries == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = w.entries.Write(append(b, '\n'))
+	return err
+}
+
+// parseLogEntry parses the given json line of a hclog logger. Returns
+// false if the line is not a structured log entry.
+func parseLogEntry(line string) (*gaia.LogEntry, bool) {
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return nil, false
+	}
+	msg, ok := raw[entryMessageKey].(string)
+	if !ok {
+		return nil, false
+	}
+
+	entry := &gaia.LogEntry{Time: time.Now(), Level: "info", Message: msg}
+	if level, ok := raw[entryLevelKey].(string); ok && level != "" {
+		entry.Level = strings.ToLower(level)
+	}
+	if ts, ok := raw[entryTimestampKey].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			entry.Time = t
+		}
+	}
+	for key, value := range raw {
+		if key == entryLevelKey || key == entryMessageKey || key == entryTimestampKey {
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = map[string]interface{}{}
+		}
+		entry.Fields[key] = value
+	}
+	return entry, true
+}
+
+// formatLogEntry returns the given entry as line of the plain log.
+func formatLogEntry(e *gaia.LogEntry) string {
+	line := fmt.Sprintf("%s [%s] %s", e.Time.Format(entryTimeFormat), strings.ToUpper(e.Level), e.Message)
+
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		line += fmt.Sprintf(" %s=%v", key, e.Fields[key])
+	}
+	return line + "\n"
+}
+
+// StripColors removes the ANSI escape sequences from the given output.
+func StripColors(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return colorPattern.ReplaceAllString(s, "")
+}
+
+// States of the colorStripper.
+const (
+	stripText = iota
+	stripEscape
+	stripParams
+	stripIntermediates
+)
+
+// colorStripper removes the ANSI escape sequences from a stream. It
+// matches the same sequences as StripColors.
+type colorStripper struct {
+	r       *bufio.Reader
+	state   int
+	pending []byte
+	out     []byte
+	err     error
+}
+
+// NewColorStripper returns a reader which reads the given output
+// without ANSI escape sequences.
+func NewColorStripper(r io.Reader) io.Reader {
+	return &colorStripper{r: bufio.NewReader(r)}
+}
+
+// Read reads the stripped output.
+func (s *colorStripper) Read(p []byte) (int, error) {
+	for len(s.out) < len(p) && s.err == nil {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			// Incomplete sequences at the end are kept
+			s.out = append(s.out, s.pending...)
+			s.pending, s.err = nil, err
+			break
+		}
+		s.feed(b)
+	}
+	if len(s.out) == 0 {
+		return 0, s.err
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// feed processes the next byte of the output.
+func (s *colorStripper) feed(b byte) {
+	switch s.state {
+	case stripText:
+		if b == 0x1b {
+			s.pending = append(s.pending[:0], b)
+			s.state = stripEscape
+			return
+		}
+		s.out = append(s.out, b)
+		return
+	case stripEscape:
+		if b == '[' {
+			s.pending = append(s.pending, b)
+			s.state = stripParams
+			return
+		}
+	case stripParams:
+		if (b >= '0' && b <= '9') || b == ';' || b == '?' {
+			s.pending = append(s.pending, b)
+			return
+		}
+		fallthrough
+	case stripIntermediates:
+		switch {
+		case b >= ' ' && b <= '/':
+			s.pending = append(s.pending, b)
+			s.state = stripIntermediates
+			return
+		case b >= '@' && b <= '~':
+			// Complete sequence
+			s.pending = s.pending[:0]
+			s.state = stripText
+			return
+		}
+	}
+
+	// Not an escape sequence, keep it as is
+	s.out = append(s.out, s.pending...)
+	s.pending = s.pending[:0]
+	s.state = stripText
+	s.feed(b)
+}
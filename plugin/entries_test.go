@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+func TestEntryWriter(t *testing.T) {
+	plain, entries := &bytes.Buffer{}, &bytes.Buffer{}
+	w := newEntryWriter(plain, entries, true)
+
+	// Lines can be split across writes
+	output := []string{
+		"plain output\n",
+		`{"@level":"warn","@message":"disk almost full","@timestamp":"2018-08-01T10:00:00.000000Z",`,
+		`"free":"2GB"}` + "\nlast line",
+	}
+	for _, s := range output {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "plain output\n2018-08-01T10:00:00.000Z [WARN] disk almost full free=2GB\nlast line"
+	if plain.String() != expected {
+		t.Fatalf("expected plain log %q, got %q", expected, plain.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(entries.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(lines))
+	}
+	entry := gaia.LogEntry{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != "warn" || entry.Message != "disk almost full" || entry.Fields["free"] != "2GB" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil || entry.Level != "info" || entry.Message != "plain output" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
+
+func TestEntryWriterColors(t *testing.T) {
+	line := "\x1b[1;32mok\x1b[0m build\n"
+	for _, colors := range []bool{true, false} {
+		plain, entries := &bytes.Buffer{}, &bytes.Buffer{}
+		w := newEntryWriter(plain, entries, colors)
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "ok build\n"
+		if colors {
+			expected = line
+		}
+		if plain.String() != expected {
+			t.Fatalf("expected plain log %q, got %q", expected, plain.String())
+		}
+		entry := gaia.LogEntry{}
+		if err := json.Unmarshal(entries.Bytes(), &entry); err != nil || entry.Message != "ok build" {
+			t.Fatalf("unexpected entry %+v", entry)
+		}
+	}
+}
+
+func TestColorStripper(t *testing.T) {
+	for _, output := range []string{
+		"plain output\n",
+		"\x1b[1;32mok\x1b[0m build\n",
+		"\x1b[?25l\x1b[2K progress\x1b[1 q",
+		"\x1bnot a sequence \x1b[1:2m\x1b[",
+		"\x1b\x1b[31mred",
+	} {
+		stripped, err := ioutil.ReadAll(iotest.OneByteReader(NewColorStripper(strings.NewReader(output))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := StripColors(output); string(stripped) != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, output, stripped)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gaia-pipeline/protobuf"
 	plugin "github.com/hashicorp/go-plugin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // PluginGRPC is the Gaia plugin interface used for communication
@@ -18,12 +19,18 @@ type PluginGRPC interface {
 // GRPCClient represents gRPC client
 type GRPCClient struct {
 	client proto.PluginClient
+
+	// token authenticates gaia to the plugin.
+	token string
 }
 
 // PluginGRPCImpl represents the plugin implementation on client side.
 type PluginGRPCImpl struct {
 	Impl PluginGRPC
 
+	// token is sent with every call to the plugin.
+	token string
+
 	plugin.NetRPCUnsupportedPlugin
 }
 
@@ -37,16 +44,21 @@ func (p *PluginGRPCImpl) GRPCServer(b *plugin.GRPCBroker, s *grpc.Server) error
 
 // GRPCClient is the passing method for the gRPC client.
 func (p *PluginGRPCImpl) GRPCClient(context context.Context, b *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
-	return &GRPCClient{client: proto.NewPluginClient(c)}, nil
+	return &GRPCClient{client: proto.NewPluginClient(c), token: p.token}, nil
+}
+
+// callContext returns the context of a call which carries the auth token.
+func (m *GRPCClient) callContext() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), tokenMetadataKey, m.token)
 }
 
 // GetJobs requests all jobs from the plugin.
 // We get a stream of proto.Job back.
 func (m *GRPCClient) GetJobs() (proto.Plugin_GetJobsClient, error) {
-	return m.client.GetJobs(context.Background(), &proto.Empty{})
+	return m.client.GetJobs(m.callContext(), &proto.Empty{})
 }
 
 // ExecuteJob triggers the execution of the given job in the plugin.
 func (m *GRPCClient) ExecuteJob(job *proto.Job) (*proto.JobResult, error) {
-	return m.client.ExecuteJob(context.Background(), job)
+	return m.client.ExecuteJob(m.callContext(), job)
 }
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// truncatedMarker is written between head and tail of a truncated log.
+	truncatedMarker = "\n... log too large: %d bytes truncated ...\n"
+)
+
+// limitWriter writes the head of the output directly to the underlying
+// writer and keeps only the tail of everything beyond. The tail is
+// written when the writer gets closed. This preserves the start and
+// the end of a log while the total size is capped to the limit.
+type limitWriter struct {
+	w io.Writer
+
+	// head is the number of bytes which still can be written directly.
+	head int64
+
+	// tail holds the latest output beyond the head.
+	tail      []byte
+	tailLimit int
+
+	// truncated counts the bytes which have been dropped from tail.
+	truncated int64
+}
+
+// newLimitWriter creates a new writer which caps the output to the
+// given limit in bytes. Half of the limit is used for the head and
+// half for the tail. A limit of zero or less disables the cap.
+func newLimitWriter(w io.Writer, limit int64) io.WriteCloser {
+	if limit <= 0 {
+		return nopCloser{w}
+	}
+
+	return &limitWriter{
+		w:         w,
+		head:      limit - limit/2,
+		tailLimit: int(limit / 2),
+	}
+}
+
+// Write implements io.Writer.
+func (l *limitWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if l.head > 0 {
+		h := p
+		if int64(len(h)) > l.head {
+			h = h[:l.head]
+		}
+		if _, err := l.w.Write(h); err != nil {
+			return 0, err
+		}
+		l.head -= int64(len(h))
+		p = p[len(h):]
+	}
+
+	// Keep the tail. Only reallocate if the buffer is twice the limit.
+	l.tail = append(l.tail, p...)
+	if len(l.tail) > 2*l.tailLimit {
+		drop := len(l.tail) - l.tailLimit
+		l.truncated += int64(drop)
+		l.tail = append(l.tail[:0], l.tail[drop:]...)
+	}
+
+	return n, nil
+}
+
+// Close writes the marker and the tail to the underlying writer.
+func (l *limitWriter) Close() error {
+	if len(l.tail) > l.tailLimit {
+		drop := len(l.tail) - l.tailLimit
+		l.truncated += int64(drop)
+		l.tail = l.tail[drop:]
+	}
+
+	if l.truncated > 0 {
+		if _, err := fmt.Fprintf(l.w, truncatedMarker, l.truncated); err != nil {
+			return err
+		}
+	}
+	_, err := l.w.Write(l.tail)
+	l.tail = nil
+	return err
+}
+
+// nopCloser adds a no-op Close method to the given writer.
+type nopCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer.
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLimitWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := newLimitWriter(buf, 10)
+
+	for _, s := range []string{"head", "-and-", "some", "-middle-", "tail!"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "head-" + fmt.Sprintf(truncatedMarker, 16) + "tail!"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestLimitWriterNotTruncated(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := newLimitWriter(buf, 100)
+
+	if _, err := w.Write([]byte("short log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "short log" {
+		t.Fatalf("expected %q, got %q", "short log", buf.String())
+	}
+}
+
+func TestLimitWriterLarge(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := newLimitWriter(buf, 1000)
+
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < 1000; i++ {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	marker := fmt.Sprintf(truncatedMarker, 100000-1000)
+	if buf.Len() != 1000+len(marker) {
+		t.Fatalf("expected log size %d, got %d", 1000+len(marker), buf.Len())
+	}
+}
@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+)
+
+const (
+	// Port range the plugins of windows bind their loopback listener
+	// to. The plugin tries the ports from a random start on.
+	minPluginPort = 10000
+	maxPluginPort = 25000
+)
+
+var (
+	// errUntrustedPlugin is thrown when the plugin listens on an address
+	// which can be reached by other processes or the listening process
+	// is not the launched pipeline binary.
+	errUntrustedPlugin = errors.New("plugin connection is not trusted")
+)
+
+// verifyPeer checks the address the plugin with the given pid listens
+// on. Unix sockets must not be writable by others and must be served
+// by the launched process. TCP is only accepted on windows and only on
+// the loopback interface.
+func verifyPeer(addr net.Addr, pid int) error {
+	switch a := addr.(type) {
+	case *net.UnixAddr:
+		info, err := os.Stat(a.Name)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s: %s is not a socket", errUntrustedPlugin.Error(), a.Name)
+		}
+
+		// Restrict the socket to the owner
+		if info.Mode().Perm()&0077 != 0 {
+			if err = os.Chmod(a.Name, info.Mode().Perm()&0700); err != nil {
+				return fmt.Errorf("%s: %s", errUntrustedPlugin.Error(), err.Error())
+			}
+		}
+		return verifyPeerProcess(a, pid)
+	case *net.TCPAddr:
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("%s: plugins must listen on a unix socket", errUntrustedPlugin.Error())
+		}
+		if !a.IP.IsLoopback() {
+			return fmt.Errorf("%s: %s is not a loopback address", errUntrustedPlugin.Error(), a.String())
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: unknown network %s", errUntrustedPlugin.Error(), addr.Network())
+}
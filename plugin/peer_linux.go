@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// verifyPeerProcess checks that the given unix socket is served by the
+// process with the given pid.
+func verifyPeerProcess(addr *net.UnixAddr, pid int) error {
+	conn, err := net.DialUnix("unix", nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	} else if credErr != nil {
+		return credErr
+	}
+
+	if int(cred.Pid) != pid {
+		return fmt.Errorf("%s: socket is served by process %d instead of %d", errUntrustedPlugin.Error(), cred.Pid, pid)
+	}
+	return nil
+}
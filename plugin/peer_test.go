@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVerifyPeer(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "TestVerifyPeer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	path := filepath.Join(tmp, "plugin")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	if err = os.Chmod(path, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := &net.UnixAddr{Name: path, Net: "unix"}
+	if err = verifyPeer(addr, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0700 {
+		t.Fatalf("expected socket to be restricted to the owner, got %s", info.Mode().Perm())
+	}
+	if runtime.GOOS == "linux" {
+		if err = verifyPeer(addr, os.Getpid()+1); err == nil {
+			t.Fatal("expected socket of another process to be rejected")
+		}
+	}
+	if err = verifyPeer(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 12000}, os.Getpid()); err == nil {
+		t.Fatal("expected tcp address to be rejected")
+	}
+}
@@ -2,18 +2,31 @@ package plugin
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	"github.com/gaia-pipeline/gaia"
 	"github.com/gaia-pipeline/protobuf"
+	hclog "github.com/hashicorp/go-hclog"
 	plugin "github.com/hashicorp/go-plugin"
 )
 
 const (
 	pluginMapKey = "Plugin"
+
+	// tokenEnvKey is the env variable which passes the auth token of the
+	// connection to the plugin. Gaia sends the token with every call.
+	tokenEnvKey = "GAIA_PLUGIN_TOKEN"
+
+	// tokenMetadataKey is the gRPC metadata key of the auth token.
+	tokenMetadataKey = "gaia-plugin-token"
 )
 
 var handshake = plugin.HandshakeConfig{
@@ -23,10 +36,6 @@ var handshake = plugin.HandshakeConfig{
 	MagicCookieValue: "FdXjW27mN6XuG2zDBP4LixXUwDAGCEkidxwqBGYpUhxiWHzctATYZvpz4ZJdALmh",
 }
 
-var pluginMap = map[string]plugin.Plugin{
-	pluginMapKey: &PluginGRPCImpl{},
-}
-
 // Plugin represents a single plugin instance which uses gRPC
 // to connect to exactly one plugin.
 type Plugin struct {
@@ -36,19 +45,34 @@ type Plugin struct {
 	// Interface to the connected plugin.
 	pluginConn PluginGRPC
 
+	// Protocol of the connection. Used for heartbeats.
+	protocol plugin.ClientProtocol
+
 	// Log file where all output is stored.
 	logFile *os.File
 
+	// Caps the size of the log file
+	limiter io.WriteCloser
+
 	// Writer used to write logs from execution to file
 	writer *bufio.Writer
+
+	// File where the structured log entries are stored and the
+	// limiter which caps its size.
+	entriesFile    *os.File
+	entriesLimiter io.WriteCloser
+
+	// Writer which splits the output into structured log entries
+	entries *entryWriter
 }
 
 // NewPlugin creates a new instance of Plugin.
 // One Plugin instance represents one connection to a plugin.
 //
 // It expects the start command to start the plugin and the log path (including file)
-// where the output should be logged to.
-func NewPlugin(command *exec.Cmd, logPath *string) (p *Plugin, err error) {
+// where the output should be logged to. The given logger receives the log entries
+// of the plugin. If nil, the default logger of the plugin system is used.
+func NewPlugin(command *exec.Cmd, logPath *string, logger hclog.Logger) (p *Plugin, err error) {
 	// Allocate
 	p = &Plugin{}
 
@@ -65,21 +89,76 @@ func NewPlugin(command *exec.Cmd, logPath *string) (p *Plugin, err error) {
 		}
 	}
 
-	// Create new writer
-	p.writer = bufio.NewWriter(p.logFile)
+	// Create new writer which caps the log size
+	p.limiter = newLimitWriter(p.logFile, gaia.GetSettings().JobLogLimit)
+	p.writer = bufio.NewWriter(p.limiter)
+
+	// The structured log entries are stored next to the logs folder
+	var entries io.Writer
+	if logPath != nil {
+		entriesPath := LogEntriesPath(*logPath)
+		if err = os.MkdirAll(filepath.Dir(entriesPath), 0700); err != nil {
+			p.logFile.Close()
+			return nil, err
+		}
+		p.entriesFile, err = os.OpenFile(entriesPath, os.O_CREATE|os.O_WRONLY, 0666)
+		if err != nil {
+			p.logFile.Close()
+			return nil, err
+		}
+		p.entriesLimiter = newLimitWriter(p.entriesFile, gaia.GetSettings().JobLogLimit)
+		entries = p.entriesLimiter
+	}
+	p.entries = newEntryWriter(p.writer, entries, gaia.GetSettings().JobLogColors)
+
+	// Every connection gets its own auth token. Plugins which listen on
+	// the loopback interface start to search a free port at a random port.
+	token, err := newToken()
+	if err != nil {
+		p.logFile.Close()
+		p.entriesFile.Close()
+		return nil, err
+	}
+	command.Env = append(command.Env, tokenEnvKey+"="+token)
+	minPort, err := rand.Int(rand.Reader, big.NewInt(maxPluginPort-minPluginPort))
+	if err != nil {
+		p.logFile.Close()
+		p.entriesFile.Close()
+		return nil, err
+	}
 
 	// Get new client
 	p.client = plugin.NewClient(&plugin.ClientConfig{
-		HandshakeConfig:  handshake,
-		Plugins:          pluginMap,
+		HandshakeConfig: handshake,
+		Plugins: map[string]plugin.Plugin{
+			pluginMapKey: &PluginGRPCImpl{token: token},
+		},
 		Cmd:              command,
 		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
-		Stderr:           p.writer,
+		MinPort:          uint(minPluginPort + minPort.Int64()),
+		MaxPort:          maxPluginPort,
+		Stderr:           p.entries,
+		Logger:           logger,
 	})
 
 	return p, nil
 }
 
+// newToken returns a new random auth token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// LogEntriesPath returns the path of the structured log entries of the
+// job log with the given path.
+func LogEntriesPath(logPath string) string {
+	return filepath.Join(filepath.Dir(filepath.Dir(logPath)), gaia.LogEntriesFolderName, filepath.Base(logPath))
+}
+
 // Connect starts the plugin, initiates the gRPC connection and looks up the plugin.
 // It's up to the caller to call plugin.Close to shutdown the plugin
 // and close the gRPC connection.
@@ -90,6 +169,16 @@ func (p *Plugin) Connect() error {
 		return err
 	}
 
+	p.protocol = gRPCClient
+
+	// Only talk to the launched binary
+	if reattach := p.client.ReattachConfig(); reattach == nil {
+		return errors.New("plugin is not connected")
+	} else if err = verifyPeer(reattach.Addr, reattach.Pid); err != nil {
+		p.client.Kill()
+		return err
+	}
+
 	// Request the plugin
 	raw, err := gRPCClient.Dispense(pluginMapKey)
 	if err != nil {
@@ -106,17 +195,35 @@ func (p *Plugin) Connect() error {
 }
 
 // Execute triggers the execution of one single job
-// for the given plugin.
-func (p *Plugin) Execute(j *gaia.Job) error {
-	// Create new proto job object and just set the id.
+// for the given plugin. The given args are passed to the job.
+func (p *Plugin) Execute(j *gaia.Job, args map[string]string) error {
+	// Create new proto job object and just set the id and args.
 	// The rest is currently not important.
 	job := &proto.Job{
 		UniqueId: j.ID,
+		Args:     args,
 	}
 
 	// Execute the job
-	_, err := p.pluginConn.ExecuteJob(job)
-	return err
+	result, err := p.pluginConn.ExecuteJob(job)
+	if err != nil {
+		return err
+	}
+
+	// The job itself reports failures in the result
+	if result.Failed {
+		return fmt.Errorf("job failed: %s", result.Message)
+	}
+	return nil
+}
+
+// Heartbeat checks if the plugin is still alive.
+// It uses the health service every plugin has to serve.
+func (p *Plugin) Heartbeat() error {
+	if p.protocol == nil {
+		return errors.New("plugin is not connected")
+	}
+	return p.protocol.Ping()
 }
 
 // GetJobs receives all implemented jobs from the given plugin.
@@ -167,10 +274,16 @@ func (p *Plugin) Close() {
 	go func() {
 		p.client.Kill()
 
-		// Flush the writer
+		// Flush the writers
+		p.entries.Flush()
 		p.writer.Flush()
+		p.limiter.Close()
+		if p.entriesLimiter != nil {
+			p.entriesLimiter.Close()
+		}
 
-		// Close log file
+		// Close log files
 		p.logFile.Close()
+		p.entriesFile.Close()
 	}()
 }
@@ -0,0 +1,401 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// launcherArg is the first argument which switches the gaia
+	// binary into SSH launcher mode.
+	launcherArg = "__gaia-ssh-exec"
+
+	// configEnvKey is the environment variable which holds the
+	// SSH configuration for the launcher on Windows. Other systems
+	// pass it through the file descriptor configFD so the private key
+	// never appears in the process environment.
+	configEnvKey = "GAIA_SSH_CONFIG"
+	configFD     = 3
+
+	// defaultPort is the port of hosts which are given without port.
+	defaultPort = "22"
+
+	// dialTimeout is the maximum duration of the SSH connection setup.
+	dialTimeout = 30 * time.Second
+)
+
+var (
+	// errInvalidTarget is thrown when an SSH target misses the host, the
+	// user or the vault key.
+	errInvalidTarget = errors.New("invalid ssh target given. Host, user and key are required")
+
+	// errInvalidHostKey is thrown when the host key of an SSH target
+	// cannot be parsed.
+	errInvalidHostKey = errors.New("invalid host key given. Must be in authorized_keys format")
+
+	// errInvalidHandshake is thrown when the remote pipeline did not
+	// print the plugin handshake.
+	errInvalidHandshake = errors.New("remote pipeline did not print a valid plugin handshake")
+
+	// forwardedEnv are the environment variables which are passed to
+	// the remote pipeline. They hold the plugin handshake, the auth
+	// token of the plugin connection and the log level. Everything else
+	// of the server environment, e.g. secrets given as GAIA_<OPTION>,
+	// stays on the server.
+	forwardedEnv = []string{"GAIA_PLUGIN", "GAIA_PLUGIN_TOKEN", "GAIA_LOG_LEVEL", "PLUGIN_MIN_PORT", "PLUGIN_MAX_PORT", "PLUGIN_PROTOCOL_VERSIONS"}
+)
+
+// launchConfig is passed to the launcher process.
+type launchConfig struct {
+	Host    string `json:"host"`
+	User    string `json:"user"`
+	Key     []byte `json:"key"`
+	HostKey string `json:"hostkey"`
+}
+
+// ValidateTarget checks the given SSH target.
+func ValidateTarget(t *gaia.SSHTarget) error {
+	if strings.TrimSpace(t.Host) == "" || t.User == "" || t.Key == "" {
+		return errInvalidTarget
+	}
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(t.HostKey)); err != nil {
+		return errInvalidHostKey
+	}
+	return nil
+}
+
+// Wrap rewrites the given pipeline command so that it is started by
+// the SSH launcher which executes the pipeline binary on the given
+// host. The given private key authenticates the launcher.
+func Wrap(c *exec.Cmd, t *gaia.SSHTarget, key []byte) error {
+	// The launcher is the gaia binary itself
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	host := t.Host
+	if _, _, err = net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, defaultPort)
+	}
+	raw, err := json.Marshal(launchConfig{Host: host, User: t.User, Key: key, HostKey: t.HostKey})
+	if err != nil {
+		return err
+	}
+
+	c.Args = []string{self, launcherArg, c.Path}
+	c.Path = self
+	if runtime.GOOS == "windows" {
+		c.Env = append(c.Env, configEnvKey+"="+string(raw))
+		return nil
+	}
+
+	// The configuration is small enough to fit into the pipe buffer.
+	// The read end must be closed by the caller once the launcher has
+	// been started.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err = w.Write(raw); err != nil {
+		r.Close()
+		return err
+	}
+	c.ExtraFiles = append(c.ExtraFiles, r)
+	return nil
+}
+
+// Launch turns the current process into the SSH launcher if it has
+// been started by a wrapped command. In that case Launch never returns.
+// Otherwise it returns immediately.
+// This should be called at the very beginning of main.
+func Launch() {
+	if len(os.Args) != 3 || os.Args[1] != launcherArg {
+		return
+	}
+
+	// Get configuration
+	cfg := &launchConfig{}
+	if runtime.GOOS == "windows" {
+		if err := json.Unmarshal([]byte(os.Getenv(configEnvKey)), cfg); err != nil {
+			fail(err)
+		}
+		os.Unsetenv(configEnvKey)
+	} else {
+		f := os.NewFile(configFD, "config")
+		err := json.NewDecoder(f).Decode(cfg)
+		f.Close()
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	code, err := launch(cfg, os.Args[2])
+	if err != nil {
+		fail(err)
+	}
+	os.Exit(code)
+}
+
+// fail writes the error to stderr which ends up in the job logs
+// and exits the launcher.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "gaia ssh: %s\n", err.Error())
+	os.Exit(1)
+}
+
+// launch copies the given binary to the host and executes it. The
+// plugin connection is forwarded through the SSH connection and the
+// output of the pipeline is streamed back. Returns the exit code of
+// the remote pipeline.
+func launch(cfg *launchConfig, binary string) (int, error) {
+	client, err := dial(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	path, err := upload(client, binary)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := command(client, "rm -f "+shellQuote(path), nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "gaia ssh: cannot remove remote binary: %s\n", err.Error())
+		}
+	}()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, err
+	}
+	defer session.Close()
+	session.Stderr = os.Stderr
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	// The environment is sent over stdin and exported by the remote
+	// shell. Command lines are visible to every user of the host.
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err = session.Start(remoteCommand(path)); err != nil {
+		return 0, err
+	}
+	for _, e := range remoteEnv(os.Environ()) {
+		fmt.Fprintln(stdin, e)
+	}
+	fmt.Fprintln(stdin)
+	stdin.Close()
+
+	// Forward stop requests to the remote pipeline
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		for range signals {
+			session.Signal(ssh.SIGTERM)
+		}
+	}()
+
+	// The handshake points the plugin client to the forwarded listener
+	stop, err := forwardHandshake(client, bufio.NewReader(stdout))
+	if err != nil {
+		return 0, err
+	}
+	defer stop()
+
+	err = session.Wait()
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		return exitErr.ExitStatus(), nil
+	}
+	return 0, err
+}
+
+// dial connects to the host of the given configuration. The host must
+// present the configured host key.
+func dial(cfg *launchConfig) (*ssh.Client, error) {
+	signer, err := ssh.ParsePrivateKey(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return nil, errInvalidHostKey
+	}
+	return ssh.Dial("tcp", cfg.Host, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         dialTimeout,
+	})
+}
+
+// upload copies the given binary to a temporary file on the host and
+// returns its path.
+func upload(client *ssh.Client, binary string) (string, error) {
+	f, err := os.Open(binary)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	out := &strings.Builder{}
+	err = command(client, `f=$(mktemp) && cat > "$f" && chmod 700 "$f" && echo "$f"`, f, out)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// command runs the given shell command on the host.
+func command(client *ssh.Client, cmd string, stdin io.Reader, stdout io.Writer) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = os.Stderr
+	return session.Run(cmd)
+}
+
+// forwardHandshake reads the plugin handshake of the remote pipeline
+// and prints it with the address of a local listener whose connections
+// are forwarded to the remote pipeline. Afterwards the remaining output
+// is copied to stdout. The returned function stops the listener.
+func forwardHandshake(client *ssh.Client, stdout *bufio.Reader) (func(), error) {
+	line, err := stdout.ReadString('\n')
+	if err != nil {
+		return nil, errInvalidHandshake
+	}
+
+	dir, err := ioutil.TempDir("", "gaia-ssh")
+	if err != nil {
+		return nil, err
+	}
+	ln, err := listenLocal(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	stop := func() {
+		ln.Close()
+		os.RemoveAll(dir)
+	}
+	network, addr, handshake, err := rewriteHandshake(line, ln.Addr())
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go forward(client, conn, network, addr)
+		}
+	}()
+
+	fmt.Fprint(os.Stdout, handshake)
+	go io.Copy(os.Stdout, stdout)
+	return stop, nil
+}
+
+// listenLocal returns the listener the plugin client connects to. Unix
+// sockets are created in the given folder. Windows uses loopback TCP.
+func listenLocal(dir string) (net.Listener, error) {
+	if runtime.GOOS == "windows" {
+		return net.Listen("tcp", "127.0.0.1:0")
+	}
+	return net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+}
+
+// rewriteHandshake returns the network and the address of the given
+// plugin handshake line and the handshake with the given local address.
+func rewriteHandshake(line string, local net.Addr) (string, string, string, error) {
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) < 4 {
+		return "", "", "", errInvalidHandshake
+	}
+	network, addr := parts[2], parts[3]
+	if network != "unix" && network != "tcp" {
+		return "", "", "", errInvalidHandshake
+	}
+	parts[2], parts[3] = local.Network(), local.String()
+	return network, addr, strings.Join(parts, "|") + "\n", nil
+}
+
+// forward copies the given local connection from and to the given
+// remote address.
+func forward(client *ssh.Client, conn net.Conn, network, addr string) {
+	defer conn.Close()
+	remote, err := client.Dial(network, addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gaia ssh: cannot connect to remote pipeline: %s\n", err.Error())
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// remoteEnv returns the variables of the given environment which are
+// passed to the remote pipeline. Values with line breaks cannot be
+// sent and are skipped.
+func remoteEnv(environ []string) []string {
+	env := []string{}
+	for _, e := range environ {
+		name := strings.SplitN(e, "=", 2)[0]
+		for _, forwarded := range forwardedEnv {
+			if name == forwarded && !strings.ContainsAny(e, "\r\n") {
+				env = append(env, e)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// remoteCommand returns the shell command which executes the given
+// remote binary. The environment of the binary is read from stdin
+// line by line until an empty line.
+func remoteCommand(path string) string {
+	return `while IFS= read -r v && [ -n "$v" ]; do export "$v"; done; exec ` + shellQuote(path)
+}
+
+// shellQuote quotes the given value for the shell of the host.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
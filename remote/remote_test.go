@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"encoding/json"
+	"net"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// testHostKey is the public ed25519 key of a test host.
+const testHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl test@host"
+
+func TestWrap(t *testing.T) {
+	c := &exec.Cmd{Path: "/gaia/pipelines/test_golang"}
+	target := &gaia.SSHTarget{Host: "legacy.example.com", User: "deploy", Key: "legacy-key", HostKey: testHostKey}
+
+	if err := Wrap(c, target, []byte("private key")); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Args) != 3 || c.Args[1] != launcherArg || c.Args[2] != "/gaia/pipelines/test_golang" {
+		t.Fatalf("unexpected launcher args %v", c.Args)
+	}
+	if len(c.Env) != 0 || len(c.ExtraFiles) != 1 {
+		t.Fatalf("expected ssh config in extra file and not in environment, got %v", c.Env)
+	}
+	defer c.ExtraFiles[0].Close()
+
+	cfg := &launchConfig{}
+	if err := json.NewDecoder(c.ExtraFiles[0]).Decode(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "legacy.example.com:22" || cfg.User != "deploy" || string(cfg.Key) != "private key" {
+		t.Fatalf("unexpected launch config %+v", cfg)
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	target := &gaia.SSHTarget{Host: "legacy.example.com:2222", User: "deploy", Key: "legacy-key", HostKey: testHostKey}
+	if err := ValidateTarget(target); err != nil {
+		t.Fatal(err)
+	}
+
+	target.Key = ""
+	if err := ValidateTarget(target); err != errInvalidTarget {
+		t.Fatalf("expected error %v, got %v", errInvalidTarget, err)
+	}
+	target.Key = "legacy-key"
+	target.HostKey = "legacy.example.com"
+	if err := ValidateTarget(target); err != errInvalidHostKey {
+		t.Fatalf("expected error %v, got %v", errInvalidHostKey, err)
+	}
+}
+
+func TestRewriteHandshake(t *testing.T) {
+	local := &net.UnixAddr{Name: "/tmp/gaia-ssh1/plugin.sock", Net: "unix"}
+	network, addr, handshake, err := rewriteHandshake("1|1|unix|/tmp/plugin123|grpc\n", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network != "unix" || addr != "/tmp/plugin123" {
+		t.Fatalf("unexpected remote address %s %s", network, addr)
+	}
+	if handshake != "1|1|unix|/tmp/gaia-ssh1/plugin.sock|grpc\n" {
+		t.Fatalf("unexpected handshake %q", handshake)
+	}
+
+	if _, _, _, err = rewriteHandshake("listening\n", local); err != errInvalidHandshake {
+		t.Fatalf("expected error %v, got %v", errInvalidHandshake, err)
+	}
+}
+
+func TestRemoteEnv(t *testing.T) {
+	env := remoteEnv([]string{
+		"HOME=/home/gaia",
+		"PLUGIN_MIN_PORT=10000",
+		"GAIA_PLUGIN_TOKEN=token",
+		"PATH=/usr/bin",
+		"GAIA_VAULTPASSPHRASE=secret",
+		"GAIA_SSH_CONFIG={}",
+		"GAIA_LOG_LEVEL=trace\nGAIA_VAULTPASSPHRASE=secret",
+	})
+	if len(env) != 2 || env[0] != "PLUGIN_MIN_PORT=10000" || env[1] != "GAIA_PLUGIN_TOKEN=token" {
+		t.Fatalf("unexpected remote environment %v", env)
+	}
+
+	if runtime.GOOS != "windows" {
+		// The environment is read from stdin, not from the command line
+		cmd := exec.Command("sh", "-c", remoteCommand("/usr/bin/env"))
+		cmd.Env = []string{"PATH=/usr/bin:/bin"}
+		cmd.Stdin = strings.NewReader("GAIA_PLUGIN_TOKEN=it's a token\n\nignored\n")
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(out), "GAIA_PLUGIN_TOKEN=it's a token\n") || strings.Contains(cmd.Args[2], "token") {
+			t.Fatalf("expected token in environment of the binary, got %s", out)
+		}
+	}
+
+	if quoted := shellQuote("it's"); quoted != `'it'"'"'s'` {
+		t.Fatalf("unexpected quoted value %s", quoted)
+	}
+}
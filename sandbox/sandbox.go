@@ -0,0 +1,95 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// launcherArg is the first argument which switches the gaia
+	// binary into sandbox launcher mode.
+	launcherArg = "__gaia-sandbox-exec"
+
+	// configEnvKey is the environment variable which holds the
+	// sandbox configuration for the launcher.
+	configEnvKey = "GAIA_SANDBOX_CONFIG"
+)
+
+// launchConfig is passed to the launcher process.
+type launchConfig struct {
+	UID             uint32 `json:"uid,omitempty"`
+	GID             uint32 `json:"gid,omitempty"`
+	NoNewPrivileges bool   `json:"nonewprivileges,omitempty"`
+	SeccompProfile  string `json:"seccompprofile,omitempty"`
+	ReadOnlyPath    string `json:"readonlypath,omitempty"`
+}
+
+// Wrap rewrites the given pipeline command so that it is started
+// by the sandbox launcher which applies the given restrictions
+// before the pipeline binary is executed.
+func Wrap(c *exec.Cmd, s *gaia.Sandbox) error {
+	// The launcher is the gaia binary itself
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cfg := launchConfig{
+		UID:             s.UID,
+		GID:             s.GID,
+		NoNewPrivileges: s.NoNewPrivileges,
+		SeccompProfile:  s.SeccompProfile,
+	}
+	if s.ReadOnlyHome {
+		cfg.ReadOnlyPath = gaia.Cfg.HomePath
+	}
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	c.SysProcAttr, err = sysProcAttr(&cfg)
+	if err != nil {
+		return err
+	}
+	// Keep the arguments of commands which are already wrapped,
+	// e.g. by the WASM runtime
+	args := c.Args
+	if len(args) == 0 {
+		args = []string{c.Path}
+	}
+	c.Args = append([]string{self, launcherArg}, args...)
+	c.Path = self
+	c.Env = append(c.Env, configEnvKey+"="+string(raw))
+	return nil
+}
+
+// Launch turns the current process into the sandbox launcher if
+// it has been started by a wrapped command. In that case Launch never
+// returns. Otherwise it returns immediately.
+// This should be called at the very beginning of main.
+func Launch() {
+	if len(os.Args) < 3 || os.Args[1] != launcherArg {
+		return
+	}
+
+	// Get configuration and remove it from the pipeline environment
+	cfg := &launchConfig{}
+	if err := json.Unmarshal([]byte(os.Getenv(configEnvKey)), cfg); err != nil {
+		fail(err)
+	}
+	os.Unsetenv(configEnvKey)
+
+	fail(launch(cfg, os.Args[2:]))
+}
+
+// fail writes the error to stderr which ends up in the job logs
+// and exits the launcher.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "gaia sandbox: %s\n", err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,149 @@
+package sandbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetNoNewPrivs   = 38
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+
+	// sockFilterSize is the size of a single struct sock_filter.
+	sockFilterSize = 8
+)
+
+// errInvalidSeccompProfile is thrown when the seccomp profile is not
+// a list of struct sock_filter.
+var errInvalidSeccompProfile = errors.New("seccomp profile is not a valid compiled BPF filter")
+
+// sockFilter represents struct sock_filter from linux/filter.h.
+type sockFilter struct {
+	Code uint16
+	Jt   uint8
+	Jf   uint8
+	K    uint32
+}
+
+// sockFprog represents struct sock_fprog from linux/filter.h.
+type sockFprog struct {
+	Len    uint16
+	Filter *sockFilter
+}
+
+// sysProcAttr returns the process attributes for the launcher.
+// A private mount namespace is required to mount paths read-only.
+func sysProcAttr(cfg *launchConfig) (*syscall.SysProcAttr, error) {
+	attr := &syscall.SysProcAttr{
+		Pdeathsig: syscall.SIGKILL,
+	}
+	if cfg.ReadOnlyPath != "" {
+		attr.Cloneflags = syscall.CLONE_NEWNS
+	}
+	return attr, nil
+}
+
+// launch applies all restrictions and replaces the launcher
+// with the command of the given arguments. Returns only on error.
+func launch(cfg *launchConfig, args []string) error {
+	// Credentials, no_new_privs and seccomp are per thread.
+	// The thread which applies them must also call exec.
+	runtime.LockOSThread()
+
+	if cfg.ReadOnlyPath != "" {
+		// Do not propagate our mounts back to the host
+		if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+			return err
+		}
+		if err := syscall.Mount(cfg.ReadOnlyPath, cfg.ReadOnlyPath, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return err
+		}
+		if err := syscall.Mount(cfg.ReadOnlyPath, cfg.ReadOnlyPath, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return err
+		}
+	}
+
+	// Load the profile before we drop privileges
+	var filter []sockFilter
+	if cfg.SeccompProfile != "" {
+		var err error
+		filter, err = loadSeccompProfile(cfg.SeccompProfile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Drop group before user, otherwise we are not allowed anymore
+	if cfg.GID != 0 {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETGROUPS, 0, 0, 0); errno != 0 {
+			return errno
+		}
+		gid := uintptr(cfg.GID)
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESGID, gid, gid, gid); errno != 0 {
+			return errno
+		}
+	}
+	if cfg.UID != 0 {
+		uid := uintptr(cfg.UID)
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESUID, uid, uid, uid); errno != 0 {
+			return errno
+		}
+	}
+
+	// Seccomp filters require no_new_privs for unprivileged processes
+	if cfg.NoNewPrivileges || filter != nil {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+			return errno
+		}
+	}
+	if filter != nil {
+		prog := sockFprog{
+			Len:    uint16(len(filter)),
+			Filter: &filter[0],
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+			return errno
+		}
+	}
+
+	return syscall.Exec(args[0], args, os.Environ())
+}
+
+// loadSeccompProfile reads a compiled BPF filter, e.g. exported by
+// libseccomp's seccomp_export_bpf, in native byte order.
+func loadSeccompProfile(path string) ([]sockFilter, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 || len(raw)%sockFilterSize != 0 || len(raw)/sockFilterSize > 0xffff {
+		return nil, errInvalidSeccompProfile
+	}
+
+	filter := make([]sockFilter, len(raw)/sockFilterSize)
+	for i := range filter {
+		b := raw[i*sockFilterSize:]
+		filter[i] = sockFilter{
+			Code: nativeEndian.Uint16(b[0:2]),
+			Jt:   b[2],
+			Jf:   b[3],
+			K:    nativeEndian.Uint32(b[4:8]),
+		}
+	}
+	return filter, nil
+}
+
+// nativeEndian is the byte order of the running system.
+var nativeEndian = func() binary.ByteOrder {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errNotSupported is thrown when a sandbox is configured on a
+// platform which does not support it.
+var errNotSupported = errors.New("pipeline sandboxing is only supported on linux")
+
+func sysProcAttr(cfg *launchConfig) (*syscall.SysProcAttr, error) {
+	return nil, errNotSupported
+}
+
+func launch(cfg *launchConfig, args []string) error {
+	return errNotSupported
+}
@@ -0,0 +1,54 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+func TestWrap(t *testing.T) {
+	gaia.Cfg = &gaia.Config{}
+	gaia.Cfg.HomePath = "/gaia"
+	c := &exec.Cmd{Path: "/gaia/pipelines/test_golang"}
+	s := &gaia.Sandbox{
+		UID:          1000,
+		ReadOnlyHome: true,
+	}
+
+	err := Wrap(c, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Args) != 3 || c.Args[1] != launcherArg || c.Args[2] != "/gaia/pipelines/test_golang" {
+		t.Fatalf("unexpected launcher args %v", c.Args)
+	}
+	if len(c.Env) != 1 || !strings.HasPrefix(c.Env[0], configEnvKey+"=") {
+		t.Fatalf("expected sandbox config in environment, got %v", c.Env)
+	}
+
+	cfg := &launchConfig{}
+	err = json.Unmarshal([]byte(strings.TrimPrefix(c.Env[0], configEnvKey+"=")), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.UID != 1000 || cfg.ReadOnlyPath != "/gaia" {
+		t.Fatalf("unexpected launch config %+v", cfg)
+	}
+}
+
+func TestWrapWrappedCommand(t *testing.T) {
+	gaia.Cfg = &gaia.Config{}
+	c := &exec.Cmd{Path: "/usr/bin/gaia", Args: []string{"/usr/bin/gaia", "__gaia-wasm-exec", "/gaia/pipelines/test_wasm"}}
+	if err := Wrap(c, &gaia.Sandbox{UID: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The launcher executes the runtime with all of its arguments
+	if len(c.Args) != 5 || c.Args[1] != launcherArg || c.Args[3] != "__gaia-wasm-exec" || c.Args[4] != "/gaia/pipelines/test_wasm" {
+		t.Fatalf("unexpected launcher args %v", c.Args)
+	}
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// baselineDays is the number of days the baseline duration
+	// of a pipeline is calculated from.
+	baselineDays = 30
+
+	// minBaselineRuns is the minimum number of successful runs
+	// required before runs are compared with the baseline.
+	minBaselineRuns = 5
+
+	// slowDeviations is the number of standard deviations a run has
+	// to be slower than the baseline to be marked as slow.
+	slowDeviations = 3
+
+	// minSlowFactor is the factor a run has to be slower than the
+	// baseline at least. Protects pipelines with very stable durations.
+	minSlowFactor = 1.5
+)
+
+// recordRunMetrics marks the given finished run as slow if it took much
+// longer than the baseline of the pipeline. Afterwards the run is
+// rolled up into the metrics of the pipeline.
+func (s *Scheduler) recordRunMetrics(r *gaia.PipelineRun) {
+	// Shadow runs execute another binary and cancelled runs are incomplete
+	if r.Shadow || r.StartDate.IsZero() || (r.Status != gaia.RunSuccess && r.Status != gaia.RunFailed) {
+		return
+	}
+
+	metrics, err := s.storeService.MetricsGet(r.PipelineID, r.FinishDate.AddDate(0, 0, -baselineDays))
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get run metrics", "error", err.Error(), "pipeline", r.PipelineID)
+		return
+	}
+	mean, stddev, n := baseline(metrics)
+	seconds := r.FinishDate.Sub(r.StartDate).Seconds()
+	if n >= minBaselineRuns && isSlow(seconds, mean, stddev) {
+		r.Slow = true
+		r.BaselineSeconds = mean
+		gaia.Cfg.Logger.Warn("pipeline run was unusually slow", "pipeline", r.PipelineID, "run", r.ID,
+			"duration", time.Duration(seconds*float64(time.Second)).String(), "baseline", time.Duration(mean*float64(time.Second)).String())
+	}
+
+	if err = s.storeService.MetricsAddRun(r); err != nil {
+		gaia.Cfg.Logger.Error("cannot store run metrics", "error", err.Error(), "pipeline", r.PipelineID)
+	}
+}
+
+// baseline returns the mean and the standard deviation of the durations
+// of the successful runs in the given metrics and the number of runs.
+func baseline(metrics []gaia.RunMetrics) (float64, float64, int) {
+	var n int
+	var sum, squaredSum float64
+	for _, m := range metrics {
+		n += m.Runs - m.Failed
+		sum += m.Seconds
+		squaredSum += m.SquaredSeconds
+	}
+	if n == 0 {
+		return 0, 0, 0
+	}
+
+	mean := sum / float64(n)
+	variance := squaredSum/float64(n) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return mean, math.Sqrt(variance), n
+}
+
+// isSlow returns true if the given duration deviates significantly
+// from the given baseline.
+func isSlow(seconds, mean, stddev float64) bool {
+	return seconds > mean+slowDeviations*stddev && seconds > mean*minSlowFactor
+}
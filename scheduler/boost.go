@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+var (
+	// errRunNotQueued is thrown when a run which is not queued should be boosted.
+	errRunNotQueued = errors.New("only queued pipeline runs can be boosted")
+
+	// errBoostCanary is thrown when a canary run should be boosted.
+	// Canary runs have their own queue.
+	errBoostCanary = errors.New("canary runs cannot be boosted")
+
+	// errPriorityQueueFull is thrown when too many runs have been boosted.
+	errPriorityQueueFull = errors.New("too many boosted pipeline runs are queued")
+)
+
+// BoostPipelineRun moves the given queued run to the front of the queue.
+func (s *Scheduler) BoostPipelineRun(pipelineID, runID int) (*gaia.PipelineRun, error) {
+	s.scheduleLock.Lock()
+	defer s.scheduleLock.Unlock()
+
+	// Only the store knows the current status
+	r, err := s.storeService.PipelineGetRunByPipelineIDAndID(pipelineID, runID)
+	if err != nil {
+		return nil, err
+	} else if r == nil || (r.Status != gaia.RunNotScheduled && r.Status != gaia.RunScheduled) {
+		return nil, errRunNotQueued
+	} else if r.Canary {
+		return nil, errBoostCanary
+	} else if r.Boosted {
+		return r, nil
+	}
+
+	// Already queued runs are taken twice. Remember to skip the stale copy.
+	key := getRunKey(r)
+	if r.Status == gaia.RunScheduled {
+		s.boosted[key] = false
+	} else {
+		r.DispatchDate = time.Now()
+	}
+	r.Boosted = true
+
+	select {
+	case s.scheduledPriorityRuns <- *r:
+	default:
+		delete(s.boosted, key)
+		return nil, errPriorityQueueFull
+	}
+
+	r.Status = gaia.RunScheduled
+	if err = s.storeService.PipelinePutRun(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// isBoostedCopy returns true if the given run has been taken already
+// from another queue because it was boosted.
+func (s *Scheduler) isBoostedCopy(r *gaia.PipelineRun) bool {
+	s.scheduleLock.Lock()
+	defer s.scheduleLock.Unlock()
+
+	key := getRunKey(r)
+	taken, ok := s.boosted[key]
+	if !ok {
+		return false
+	}
+	if !taken {
+		s.boosted[key] = true
+		return false
+	}
+	delete(s.boosted, key)
+	return true
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+var (
+	// errInvalidCalendarName is thrown when a calendar has no name.
+	errInvalidCalendarName = errors.New("calendar name must not be empty")
+
+	// errInvalidCalendarPolicy is thrown when a calendar has an unknown policy.
+	errInvalidCalendarPolicy = errors.New("calendar policy must be hold or reject")
+
+	// errInvalidCalendarWindow is thrown when a freeze period ends before it starts.
+	errInvalidCalendarWindow = errors.New("calendar window must end after it starts")
+)
+
+// freeze represents an active freeze period of a calendar.
+type freeze struct {
+	calendar string
+	policy   gaia.CalendarPolicy
+	window   gaia.CalendarWindow
+}
+
+// Error returns a message which explains the freeze.
+func (f *freeze) Error() string {
+	msg := fmt.Sprintf("pipeline is frozen by calendar %s until %s", f.calendar, f.window.End.Format(time.RFC3339))
+	if f.window.Reason != "" {
+		msg += ": " + f.window.Reason
+	}
+	return msg
+}
+
+// ValidateCalendar checks the given calendar.
+func ValidateCalendar(c *gaia.Calendar) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errInvalidCalendarName
+	}
+	if c.Policy != gaia.CalendarHold && c.Policy != gaia.CalendarReject {
+		return errInvalidCalendarPolicy
+	}
+	for _, w := range c.Windows {
+		if !w.End.After(w.Start) {
+			return errInvalidCalendarWindow
+		}
+	}
+	return nil
+}
+
+// activeFreeze returns the freeze period which applies to the given
+// pipeline at the given time. Returns nil if the pipeline is not frozen.
+func (s *Scheduler) activeFreeze(p *gaia.Pipeline, now time.Time) (*freeze, error) {
+	calendars, err := s.storeService.CalendarGetAll()
+	if err != nil {
+		return nil, err
+	}
+	return findFreeze(calendars, p.Calendars, now), nil
+}
+
+// findFreeze returns the active freeze period of the given calendars.
+// Only global calendars and calendars with the given names apply.
+// Freeze periods which reject runs take precedence.
+func findFreeze(calendars []gaia.Calendar, names []string, now time.Time) *freeze {
+	var found *freeze
+	for _, c := range calendars {
+		if !c.Global && !contains(names, c.Name) {
+			continue
+		}
+
+		for _, w := range c.Windows {
+			if now.Before(w.Start) || !now.Before(w.End) {
+				continue
+			}
+			if found == nil || (found.policy != gaia.CalendarReject && c.Policy == gaia.CalendarReject) {
+				found = &freeze{
+					calendar: c.Name,
+					policy:   c.Policy,
+					window:   w,
+				}
+			}
+		}
+	}
+	return found
+}
+
+// heldBy returns the freeze period which holds the given run. Already
+// queued runs are held regardless of the calendar policy. The calendars
+// of the pipelines are given by pipeline id. Returns nil if the run is
+// not held.
+func heldBy(r *gaia.PipelineRun, calendars []gaia.Calendar, pipelineCalendars map[int][]string, now time.Time) *freeze {
+	if len(calendars) == 0 {
+		return nil
+	}
+	return findFreeze(calendars, pipelineCalendars[r.PipelineID], now)
+}
+
+// calendarsByPipeline returns the calendar names of the given
+// pipelines by pipeline id.
+func calendarsByPipeline(pipelines []gaia.Pipeline) map[int][]string {
+	names := map[int][]string{}
+	for _, p := range pipelines {
+		names[p.ID] = p.Calendars
+	}
+	return names
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+var (
+	// errNoCanaryWorker is thrown when a canary run was requested but
+	// no canary worker has been configured.
+	errNoCanaryWorker = errors.New("no canary worker configured")
+
+	// errCanaryPending is thrown when a normal run was requested for a
+	// pipeline version whose canary run did not succeed yet.
+	errCanaryPending = errors.New("canary run for this pipeline version did not succeed yet")
+)
+
+// isCanaryWorker returns true if the worker with the given id is
+// designated as canary worker. The first workers are canary workers.
+func isCanaryWorker(id int) bool {
+	return id <= gaia.Cfg.CanaryWorkers
+}
+
+// nextRun takes the next scheduled run for the given worker.
+// Canary workers prefer canary runs. Boosted runs are preferred over
+// normal runs. Blocks if there is no work. Returns false if the given
+// stop channel has been closed while waiting.
+func (s *Scheduler) nextRun(id int, stop <-chan struct{}) (gaia.PipelineRun, bool) {
+	for {
+		r, ok := s.takeRun(id, stop)
+		if !ok || !s.isBoostedCopy(&r) {
+			return r, ok
+		}
+	}
+}
+
+// takeRun takes the next run from the queues the given worker serves.
+func (s *Scheduler) takeRun(id int, stop <-chan struct{}) (gaia.PipelineRun, bool) {
+	if isCanaryWorker(id) {
+		select {
+		case r := <-s.scheduledCanaryRuns:
+			return r, true
+		default:
+		}
+	}
+
+	select {
+	case r := <-s.scheduledPriorityRuns:
+		return r, true
+	default:
+	}
+
+	if !isCanaryWorker(id) {
+		select {
+		case r := <-s.scheduledPriorityRuns:
+			return r, true
+		case r := <-s.scheduledRuns:
+			return r, true
+		case <-stop:
+			return gaia.PipelineRun{}, false
+		}
+	}
+
+	select {
+	case r := <-s.scheduledCanaryRuns:
+		return r, true
+	case r := <-s.scheduledPriorityRuns:
+		return r, true
+	case r := <-s.scheduledRuns:
+		return r, true
+	case <-stop:
+		return gaia.PipelineRun{}, false
+	}
+}
+
+// prepareCanary checks if the given pipeline can be scheduled.
+// For canary runs the current pipeline version is marked as pending.
+// Normal runs are rejected while the current version is pending.
+func (s *Scheduler) prepareCanary(p *gaia.Pipeline, canary bool) error {
+	if canary && gaia.Cfg.CanaryWorkers <= 0 {
+		return errNoCanaryWorker
+	}
+
+	stored, err := s.storeService.PipelineGet(p.ID)
+	if err != nil {
+		return err
+	} else if stored.Name == "" {
+		return nil
+	}
+
+	// Nothing pending and no new canary run
+	if !canary && stored.CanaryPending == nil {
+		return nil
+	}
+
+	checksum, err := getSHA256Sum(stored.ExecPath)
+	if err != nil {
+		return err
+	}
+
+	if !canary {
+		if bytes.Equal(stored.CanaryPending, checksum) {
+			return errCanaryPending
+		}
+		return nil
+	}
+
+	stored.CanaryPending = checksum
+	return s.storeService.PipelineUpdate(stored)
+}
+
+// approveCanary removes the pending mark of the pipeline version
+// which has been executed by the given canary run.
+func (s *Scheduler) approveCanary(r *gaia.PipelineRun) {
+	p, err := s.storeService.PipelineGet(r.PipelineID)
+	if err != nil || p.CanaryPending == nil {
+		return
+	}
+
+	// The binary might have changed since the canary run was scheduled.
+	checksum, err := getSHA256Sum(p.ExecPath)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot calculate checksum of canary pipeline", "error", err.Error(), "pipeline", p.Name)
+		return
+	}
+	if !bytes.Equal(p.CanaryPending, checksum) {
+		return
+	}
+
+	p.CanaryPending = nil
+	if err = s.storeService.PipelineUpdate(p); err != nil {
+		gaia.Cfg.Logger.Error("cannot release canary pipeline", "error", err.Error(), "pipeline", p.Name)
+	}
+}
+
+// getSHA256Sum calculates the SHA256 checksum of the given file.
+func getSHA256Sum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
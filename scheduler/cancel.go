@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// errRunNotCancellable is thrown when a finished run should be cancelled.
+var errRunNotCancellable = errors.New("only queued or running pipeline runs can be cancelled")
+
+// CancelPipelineRun cancels the given run. Queued runs are never started.
+// Running jobs are asked to clean up and are killed after the grace period.
+func (s *Scheduler) CancelPipelineRun(r *gaia.PipelineRun) error {
+	switch r.Status {
+	case gaia.RunNotScheduled, gaia.RunScheduled, gaia.RunRunning:
+	default:
+		return errRunNotCancellable
+	}
+
+	// Runs which have not been dispatched do not need a cancel channel
+	if r.Status == gaia.RunNotScheduled {
+		cancelled, err := s.cancelQueuedRun(r)
+		if err != nil || cancelled {
+			return err
+		}
+	}
+	return s.cancelDispatchedRun(r)
+}
+
+// cancelQueuedRun cancels the given run in the store if it has not been
+// dispatched yet. The dispatcher is blocked meanwhile. Otherwise the
+// given run is updated with the stored run and false is returned.
+func (s *Scheduler) cancelQueuedRun(r *gaia.PipelineRun) (bool, error) {
+	s.scheduleLock.Lock()
+	defer s.scheduleLock.Unlock()
+
+	stored, err := s.storeService.PipelineGetRunByPipelineIDAndID(r.PipelineID, r.ID)
+	if err != nil {
+		return false, err
+	} else if stored == nil {
+		return false, errRunNotCancellable
+	}
+	*r = *stored
+	if r.Status != gaia.RunNotScheduled {
+		return false, nil
+	}
+	r.Status = gaia.RunCancelled
+	r.FinishDate = time.Now()
+	return true, s.storeService.PipelinePutRun(r)
+}
+
+// cancelDispatchedRun closes the cancel channel of the given dispatched
+// run. The channel is created for runs which wait in the buffer of the
+// dispatcher and removed by the worker which takes them. Runs which
+// have finished in the meantime are not cancelled.
+func (s *Scheduler) cancelDispatchedRun(r *gaia.PipelineRun) error {
+	s.cancelsLock.Lock()
+	defer s.cancelsLock.Unlock()
+
+	key := getRunKey(r)
+	cancel, ok := s.cancels[key]
+	if !ok {
+		// Workers only release the channel of finished runs
+		stored, err := s.storeService.PipelineGetRunByPipelineIDAndID(r.PipelineID, r.ID)
+		if err != nil {
+			return err
+		} else if stored == nil || stored.Status != gaia.RunScheduled {
+			return errRunNotCancellable
+		}
+		*r = *stored
+		cancel = make(chan struct{})
+		s.cancels[key] = cancel
+	}
+	if !isCancelled(cancel) {
+		close(cancel)
+	}
+
+	// Running runs are finished by their worker
+	if r.Status == gaia.RunRunning {
+		return nil
+	}
+	r.Status = gaia.RunCancelled
+	r.FinishDate = time.Now()
+	return s.storeService.PipelinePutRun(r)
+}
+
+// runCancel returns the cancel channel of the given run.
+func (s *Scheduler) runCancel(r *gaia.PipelineRun) <-chan struct{} {
+	s.cancelsLock.Lock()
+	defer s.cancelsLock.Unlock()
+
+	key := getRunKey(r)
+	cancel, ok := s.cancels[key]
+	if !ok {
+		cancel = make(chan struct{})
+		s.cancels[key] = cancel
+	}
+	return cancel
+}
+
+// releaseRunCancel removes the cancel channel of the given run once its
+// worker is done with it.
+func (s *Scheduler) releaseRunCancel(r *gaia.PipelineRun) {
+	s.cancelsLock.Lock()
+	defer s.cancelsLock.Unlock()
+
+	delete(s.cancels, getRunKey(r))
+}
+
+// isCancelled returns true if the given cancel channel has been closed.
+func isCancelled(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// terminateProcess interrupts the given process so it can run its cleanup
+// handlers. If the process did not exit within the grace period, which is
+// signaled by closing exited, it is killed.
+func terminateProcess(proc *os.Process, grace time.Duration, exited <-chan struct{}) {
+	if err := interruptProcess(proc); err == nil {
+		select {
+		case <-exited:
+			return
+		case <-time.After(grace):
+		}
+	}
+	proc.Kill()
+}
+
+// getRunKey returns the unique key of the given run.
+func getRunKey(r *gaia.PipelineRun) string {
+	return fmt.Sprintf("%d/%d", r.PipelineID, r.ID)
+}
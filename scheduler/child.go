@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// maxChildDepth is the maximum nesting depth of child pipelines.
+	maxChildDepth = 5
+
+	// childTokenArgKey is the arg which holds the token a job uses to
+	// trigger child pipelines.
+	childTokenArgKey = "gaia_child_token"
+
+	// apiURLArgKey is the arg which holds the url of the gaia api.
+	apiURLArgKey = "gaia_api_url"
+
+	// correlationIDArgKey is the arg which holds the correlation id of
+	// the run. Jobs can pass it on to trace their actions back to the run.
+	correlationIDArgKey = "gaia_correlation_id"
+
+	// reservedArgPrefix prefixes all args which are set by gaia.
+	reservedArgPrefix = "gaia_"
+)
+
+var (
+	// errChildDepthExceeded is thrown when a child pipeline would exceed
+	// the maximum nesting depth.
+	errChildDepthExceeded = errors.New("maximum depth of child pipelines exceeded")
+
+	// errInvalidParamKey is thrown when a parameter of a run has an
+	// empty or reserved key.
+	errInvalidParamKey = errors.New("parameter keys must not be empty or start with gaia_")
+)
+
+// ParentRun identifies the running run which triggers child pipelines.
+type ParentRun struct {
+	PipelineID    int
+	RunID         int
+	Depth         int
+	CorrelationID string
+}
+
+// ScheduleChildPipeline schedules the given pipeline as child of the
+// given parent run. The given params are passed to the jobs as args.
+func (s *Scheduler) ScheduleChildPipeline(p *gaia.Pipeline, parent *ParentRun, params map[string]string) (*gaia.PipelineRun, error) {
+	if parent.Depth >= maxChildDepth {
+		return nil, errChildDepthExceeded
+	}
+
+	// The params are outputs of the parent pipeline
+	parentPipeline, err := s.storeService.PipelineGet(parent.PipelineID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkContract(parentPipeline, contractOutputs, params); err != nil {
+		return nil, err
+	}
+	return s.SchedulePipeline(p, ScheduleOptions{
+		Params:        params,
+		Parent:        parent,
+		CorrelationID: parent.CorrelationID,
+	})
+}
+
+// ParentRunByToken returns the running run the given child token has
+// been issued to. Returns nil if the token is unknown.
+func (s *Scheduler) ParentRunByToken(token string) *ParentRun {
+	s.childTokensLock.Lock()
+	defer s.childTokensLock.Unlock()
+
+	parent, ok := s.childTokens[token]
+	if !ok {
+		return nil
+	}
+	return &parent
+}
+
+// issueChildToken creates the token the jobs of the given run use to
+// trigger child pipelines. The token is valid until it is revoked.
+func (s *Scheduler) issueChildToken(r *gaia.PipelineRun) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	s.childTokensLock.Lock()
+	defer s.childTokensLock.Unlock()
+
+	s.childTokens[token] = ParentRun{
+		PipelineID:    r.PipelineID,
+		RunID:         r.ID,
+		Depth:         r.Depth,
+		CorrelationID: r.CorrelationID,
+	}
+	return token, nil
+}
+
+// revokeChildToken invalidates the given child token.
+func (s *Scheduler) revokeChildToken(token string) {
+	s.childTokensLock.Lock()
+	defer s.childTokensLock.Unlock()
+
+	delete(s.childTokens, token)
+}
+
+// validateParams checks the keys of the given run parameters.
+func validateParams(params map[string]string) error {
+	for key := range params {
+		if strings.TrimSpace(key) == "" || strings.HasPrefix(key, reservedArgPrefix) {
+			return errInvalidParamKey
+		}
+	}
+	return nil
+}
+
+// jobAPIURL returns the url of the api which is passed to the jobs.
+// The local port is used if no url has been configured.
+func jobAPIURL() string {
+	if gaia.Cfg.APIURL != "" {
+		return strings.TrimSuffix(gaia.Cfg.APIURL, "/")
+	}
+	if gaia.Cfg.TLSCert != "" {
+		return "https://localhost:" + gaia.Cfg.ListenPort
+	}
+	return "http://localhost:" + gaia.Cfg.ListenPort
+}
@@ -0,0 +1,390 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// contractParams and contractOutputs name the schemas of a contract.
+	contractParams  = "params"
+	contractOutputs = "outputs"
+)
+
+var (
+	// schemaAnnotations are the keywords which do not constrain values.
+	schemaAnnotations = []string{"$schema", "$id", "$comment", "title", "description", "default", "examples", "format", "readOnly", "writeOnly", "deprecated"}
+)
+
+// ContractViolation is a value which does not match the schema.
+// Path is the JSON pointer of the value.
+type ContractViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ContractError is thrown when the params of a run violate the contract
+// of a pipeline.
+type ContractError struct {
+	Pipeline   string              `json:"pipeline"`
+	Contract   string              `json:"contract"`
+	Violations []ContractViolation `json:"violations"`
+}
+
+// Error returns all violations.
+func (e *ContractError) Error() string {
+	msgs := []string{}
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Path+": "+v.Message)
+	}
+	return fmt.Sprintf("%s violate the contract of pipeline %s: %s", e.Contract, e.Pipeline, strings.Join(msgs, "; "))
+}
+
+// jsonSchema is a compiled schema. The validation keywords of JSON
+// Schema draft 7 are supported except for references and combinations.
+type jsonSchema struct {
+	never      bool
+	types      []string
+	properties map[string]*jsonSchema
+	required   []string
+	additional *jsonSchema
+	items      *jsonSchema
+	enum       []interface{}
+	constant   interface{}
+	hasConst   bool
+	pattern    *regexp.Regexp
+	minLength  *float64
+	maxLength  *float64
+	minimum    *float64
+	maximum    *float64
+	exclMin    *float64
+	exclMax    *float64
+	minItems   *float64
+	maxItems   *float64
+}
+
+// ValidateContract checks the schemas of the given contract.
+func ValidateContract(c *gaia.PipelineContract) error {
+	for name, raw := range map[string]json.RawMessage{contractParams: c.Params, contractOutputs: c.Outputs} {
+		if len(raw) == 0 {
+			continue
+		}
+		if _, err := compileSchema(raw, ""); err != nil {
+			return fmt.Errorf("invalid %s schema: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// checkContract validates the given params against the given schema of
+// the contract of the given pipeline. Pipelines without schema accept
+// all params.
+func checkContract(p *gaia.Pipeline, contract string, params map[string]string) error {
+	if p.Contract == nil {
+		return nil
+	}
+	raw := p.Contract.Params
+	if contract == contractOutputs {
+		raw = p.Contract.Outputs
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	s, err := compileSchema(raw, "")
+	if err != nil {
+		return fmt.Errorf("invalid %s schema of pipeline %s: %s", contract, p.Name, err.Error())
+	}
+	violations := []ContractViolation{}
+	s.validate(paramsDocument(s, params), "", &violations)
+	if len(violations) > 0 {
+		return &ContractError{Pipeline: p.Name, Contract: contract, Violations: violations}
+	}
+	return nil
+}
+
+// paramsDocument converts the given params to the document which is
+// validated. Params are strings. Values of properties which must not
+// be strings are parsed.
+func paramsDocument(s *jsonSchema, params map[string]string) map[string]interface{} {
+	doc := map[string]interface{}{}
+	for key, value := range params {
+		prop := s.properties[key]
+		if prop == nil {
+			prop = s.additional
+		}
+		doc[key] = coerceParam(prop, value)
+	}
+	return doc
+}
+
+// coerceParam parses the given param as the first type of the given
+// schema it is valid for. Unparsable values stay strings.
+func coerceParam(s *jsonSchema, value string) interface{} {
+	if s == nil || len(s.types) == 0 || contains(s.types, "string") {
+		return value
+	}
+	for _, t := range s.types {
+		switch t {
+		case "integer", "number":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				return f
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(value); err == nil {
+				return b
+			}
+		case "null":
+			if value == "" || value == "null" {
+				return nil
+			}
+		case "object", "array":
+			var v interface{}
+			if err := json.Unmarshal([]byte(value), &v); err == nil {
+				return v
+			}
+		}
+	}
+	return value
+}
+
+// compileSchema parses the given schema. The given path is the location
+// of the schema used in errors.
+func compileSchema(raw json.RawMessage, path string) (*jsonSchema, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return &jsonSchema{never: !b}, nil
+	}
+	keywords := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &keywords); err != nil {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", pointer(path))
+	}
+
+	s := &jsonSchema{}
+	for key, value := range keywords {
+		var err error
+		switch key {
+		case "type":
+			var t string
+			if err = json.Unmarshal(value, &t); err == nil {
+				s.types = []string{t}
+			} else {
+				err = json.Unmarshal(value, &s.types)
+			}
+			for _, t := range s.types {
+				if !contains([]string{"string", "number", "integer", "boolean", "object", "array", "null"}, t) {
+					return nil, fmt.Errorf("%s: unknown type %s", pointer(path), t)
+				}
+			}
+		case "properties":
+			props := map[string]json.RawMessage{}
+			if err = json.Unmarshal(value, &props); err == nil {
+				s.properties = map[string]*jsonSchema{}
+				for name, prop := range props {
+					if s.properties[name], err = compileSchema(prop, path+"/properties/"+escapePointer(name)); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case "required":
+			err = json.Unmarshal(value, &s.required)
+		case "additionalProperties":
+			if s.additional, err = compileSchema(value, path+"/additionalProperties"); err != nil {
+				return nil, err
+			}
+		case "items":
+			if s.items, err = compileSchema(value, path+"/items"); err != nil {
+				return nil, err
+			}
+		case "enum":
+			err = json.Unmarshal(value, &s.enum)
+		case "const":
+			s.hasConst = true
+			err = json.Unmarshal(value, &s.constant)
+		case "pattern":
+			var pattern string
+			if err = json.Unmarshal(value, &pattern); err == nil {
+				s.pattern, err = regexp.Compile(pattern)
+			}
+		case "minLength":
+			err = json.Unmarshal(value, &s.minLength)
+		case "maxLength":
+			err = json.Unmarshal(value, &s.maxLength)
+		case "minimum":
+			err = json.Unmarshal(value, &s.minimum)
+		case "maximum":
+			err = json.Unmarshal(value, &s.maximum)
+		case "exclusiveMinimum":
+			err = json.Unmarshal(value, &s.exclMin)
+		case "exclusiveMaximum":
+			err = json.Unmarshal(value, &s.exclMax)
+		case "minItems":
+			err = json.Unmarshal(value, &s.minItems)
+		case "maxItems":
+			err = json.Unmarshal(value, &s.maxItems)
+		default:
+			if !contains(schemaAnnotations, key) {
+				return nil, fmt.Errorf("%s: unsupported keyword %s", pointer(path), key)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid value of %s: %s", pointer(path), key, err.Error())
+		}
+	}
+	return s, nil
+}
+
+// validate appends the violations of the given value at the given path.
+func (s *jsonSchema) validate(v interface{}, path string, violations *[]ContractViolation) {
+	violate := func(format string, args ...interface{}) {
+		*violations = append(*violations, ContractViolation{Path: pointer(path), Message: fmt.Sprintf(format, args...)})
+	}
+	if s.never {
+		violate("is not allowed")
+		return
+	}
+
+	if len(s.types) > 0 && !matchesType(s.types, v) {
+		violate("must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+	if len(s.enum) > 0 && !containsValue(s.enum, v) {
+		violate("must be one of %s", mustJSON(s.enum))
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		violate("must be %s", mustJSON(s.constant))
+	}
+
+	switch value := v.(type) {
+	case string:
+		n := float64(utf8.RuneCountInString(value))
+		if s.minLength != nil && n < *s.minLength {
+			violate("must be at least %v characters long", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			violate("must be at most %v characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			violate("must match pattern %s", s.pattern.String())
+		}
+	case float64:
+		if s.minimum != nil && value < *s.minimum {
+			violate("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && value > *s.maximum {
+			violate("must be <= %v", *s.maximum)
+		}
+		if s.exclMin != nil && value <= *s.exclMin {
+			violate("must be > %v", *s.exclMin)
+		}
+		if s.exclMax != nil && value >= *s.exclMax {
+			violate("must be < %v", *s.exclMax)
+		}
+	case []interface{}:
+		n := float64(len(value))
+		if s.minItems != nil && n < *s.minItems {
+			violate("must have at least %v items", *s.minItems)
+		}
+		if s.maxItems != nil && n > *s.maxItems {
+			violate("must have at most %v items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range value {
+				s.items.validate(item, path+"/"+strconv.Itoa(i), violations)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := value[name]; !ok {
+				*violations = append(*violations, ContractViolation{Path: pointer(path + "/" + escapePointer(name)), Message: "is required"})
+			}
+		}
+
+		// Report the properties in a stable order
+		names := []string{}
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop := s.properties[name]
+			if prop == nil {
+				prop = s.additional
+			}
+			if prop != nil {
+				prop.validate(value[name], path+"/"+escapePointer(name), violations)
+			}
+		}
+	}
+}
+
+// matchesType returns true if the given value has one of the given types.
+func matchesType(types []string, v interface{}) bool {
+	for _, t := range types {
+		switch value := v.(type) {
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && value == math.Trunc(value)) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsValue returns true if the given value is in the given list.
+func containsValue(l []interface{}, v interface{}) bool {
+	for _, e := range l {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustJSON returns the given decoded value as JSON.
+func mustJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// pointer returns the given JSON pointer. The root is "/".
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// escapePointer escapes the given name for a JSON pointer.
+func escapePointer(name string) string {
+	return strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+}
@@ -0,0 +1,267 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// cronInterval is the interval the schedules of the pipelines are checked.
+	cronInterval = 30 * time.Second
+
+	// maxCronYears is the maximum number of years the next run of a
+	// schedule is searched for.
+	maxCronYears = 5
+)
+
+// cronField is the range of a single field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+// cronFields are the fields of a cron expression in their order.
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// cronSchedule is a parsed cron expression. Every field is a bitmask
+// of the matching values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// Day of month and day of week are combined with or if both are
+	// restricted.
+	domStar, dowStar bool
+
+	loc *time.Location
+}
+
+// ValidateSchedules checks the given schedules of a pipeline.
+func ValidateSchedules(schedules []gaia.Schedule) error {
+	for _, sc := range schedules {
+		if _, err := parseSchedule(&sc); err != nil {
+			return err
+		}
+		if err := validateParams(sc.Params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NextScheduleRun returns the next time after the given time the given
+// schedule starts a run. Returns a zero time if the schedule never matches.
+func NextScheduleRun(sc *gaia.Schedule, after time.Time) (time.Time, error) {
+	cron, err := parseSchedule(sc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cron.next(after), nil
+}
+
+// parseSchedule parses the cron expression and the timezone of the given schedule.
+func parseSchedule(sc *gaia.Schedule) (*cronSchedule, error) {
+	loc := time.UTC
+	if sc.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(sc.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %s given", sc.Timezone)
+		}
+	}
+	cron, err := parseCron(sc.Cron)
+	if err != nil {
+		return nil, err
+	}
+	cron.loc = loc
+	return cron, nil
+}
+
+// parseCron parses the given cron expression with the five fields
+// minute, hour, day of month, month and day of week. Fields support
+// lists, ranges and steps like 1,15 or 9-17 or */5.
+func parseCron(expr string) (*cronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q given. Must have five fields", expr)
+	}
+
+	masks := make([]uint64, len(parts))
+	for i, part := range parts {
+		var err error
+		if masks[i], err = parseCronField(part, cronFields[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sunday is 0 and 7
+	dow := masks[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+	}
+	return &cronSchedule{
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     dow,
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}, nil
+}
+
+// parseCronField parses one field of a cron expression into a bitmask.
+func parseCronField(field string, f cronField) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rng = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, field)
+			}
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, field)
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, field)
+			}
+		default:
+			v, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field %q", f.name, field)
+			}
+			start = v
+			if strings.Contains(item, "/") {
+				end = f.max
+			} else {
+				end = v
+			}
+		}
+		if start < f.min || end > f.max || start > end {
+			return 0, fmt.Errorf("%s field %q must be between %d and %d", f.name, field, f.min, f.max)
+		}
+
+		for v := start; v <= end; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+// next returns the first time after the given time the schedule
+// matches. The fields are matched against the wall clock of the
+// location of the schedule. Times which are skipped by a daylight
+// saving transition are shifted by the transition, times which repeat
+// only match once.
+func (c *cronSchedule) next(after time.Time) time.Time {
+	// The wall clock is iterated in UTC which has no transitions
+	wall := wallClock(after.In(c.loc)).Add(time.Minute)
+	limit := wall.AddDate(maxCronYears, 0, 0)
+
+	for wall.Before(limit) {
+		switch {
+		case c.month&(1<<uint(wall.Month())) == 0:
+			wall = time.Date(wall.Year(), wall.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchDay(wall):
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(wall.Hour())) == 0:
+			wall = wall.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(wall.Minute())) == 0:
+			wall = wall.Add(time.Minute)
+		default:
+			// Skipped wall clocks are moved forward by the transition
+			t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, c.loc)
+			if actual := wallClock(t.In(c.loc)); !actual.Equal(wall) {
+				t = t.Add(wall.Sub(actual))
+			}
+			if t.After(after) {
+				return t
+			}
+			wall = wall.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+// wallClock returns the wall clock of the given time truncated to the
+// minute as UTC time.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// matchDay returns true if the day of the given wall clock matches.
+func (c *cronSchedule) matchDay(wall time.Time) bool {
+	dom := c.dom&(1<<uint(wall.Day())) != 0
+	dow := c.dow&(1<<uint(wall.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// runSchedules starts the runs of all pipeline schedules which matched
+// since the last check. Runs missed while gaia was down are not started.
+func (s *Scheduler) runSchedules() {
+	last := time.Now()
+	ticker := time.NewTicker(cronInterval)
+	for now := range ticker.C {
+		s.triggerSchedules(last, now)
+		last = now
+	}
+}
+
+// triggerSchedules starts one run for every schedule which matched in
+// the given period.
+func (s *Scheduler) triggerSchedules(from, to time.Time) {
+	pipelines, err := s.storeService.PipelineGetAll()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get pipelines from store", "error", err.Error())
+		return
+	}
+
+	for i := range pipelines {
+		p := &pipelines[i]
+		if schedulesPaused(p) {
+			continue
+		}
+		for _, sc := range p.Schedules {
+			next, err := NextScheduleRun(&sc, from)
+			if err != nil {
+				gaia.Cfg.Logger.Error("invalid pipeline schedule", "error", err.Error(), "pipeline", p.Name, "cron", sc.Cron)
+				continue
+			}
+			if next.IsZero() || next.After(to) {
+				continue
+			}
+
+			gaia.Cfg.Logger.Info("starting scheduled run", "pipeline", p.Name, "cron", sc.Cron, "timezone", sc.Timezone)
+			if _, err = s.SchedulePipeline(p, ScheduleOptions{Params: sc.Params, Scheduled: true}); err != nil {
+				gaia.Cfg.Logger.Error("cannot schedule pipeline", "error", err.Error(), "pipeline", p.Name, "cron", sc.Cron)
+			}
+		}
+	}
+}
+
+// schedulesPaused returns true if the schedules of the given pipeline
+// do not start runs.
+func schedulesPaused(p *gaia.Pipeline) bool {
+	return p.Deleted || p.Archived || p.Quarantine != nil
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+const (
+	// debugLogLevelEnv is the environment variable which tells the
+	// pipeline (sdk) which log level should be used.
+	debugLogLevelEnv = "GAIA_LOG_LEVEL"
+
+	// maskedValue replaces secret values in diagnostics.
+	maskedValue = "********"
+)
+
+// newRunLogger creates the diagnostics logger for the given run.
+// Debug runs write into the debug log file of the run folder.
+// All other runs get a logger which discards everything.
+// The returned function must be called when the run has been finished.
+func newRunLogger(r *gaia.PipelineRun) (hclog.Logger, func()) {
+	if !r.Debug {
+		return hclog.NewNullLogger(), func() {}
+	}
+
+	path := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(r.PipelineID), strconv.Itoa(r.ID), gaia.DebugLogFileName)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot create debug log file for run", "error", err.Error(), "path", path)
+		return hclog.NewNullLogger(), func() {}
+	}
+
+	l := hclog.New(&hclog.LoggerOptions{
+		Name:   "run",
+		Level:  hclog.Trace,
+		Output: f,
+	})
+	return l.With("correlationid", r.CorrelationID), func() { f.Close() }
+}
+
+// logRunDiagnostics writes the environment and the resolved args of
+// the given run to the diagnostics logger. Only the names of the
+// environment variables of the server are written and the values of
+// secret args are masked, because every user of the pipeline can read
+// the debug log.
+func logRunDiagnostics(diag hclog.Logger, r *gaia.PipelineRun, p *gaia.Pipeline, args map[string]string) {
+	if !diag.IsDebug() {
+		return
+	}
+
+	diag.Debug("run started", "pipeline", p.Name, "run", r.ID, "worker", r.Worker, "scheduled", r.ScheduleDate.String(), "waited", r.StartDate.Sub(r.ScheduleDate).String())
+
+	env := os.Environ()
+	sort.Strings(env)
+	for _, e := range env {
+		diag.Debug("environment", "variable", strings.SplitN(e, "=", 2)[0])
+	}
+
+	keys := []string{}
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := args[key]
+		if isSecretArg(r, key) {
+			value = maskedValue
+		}
+		diag.Debug("resolved arg", "key", key, "value", value)
+	}
+	for _, key := range r.Secrets {
+		if _, ok := args[key]; !ok {
+			diag.Debug("requested secret not resolved", "key", key)
+		}
+	}
+
+	for _, job := range r.Jobs {
+		diag.Debug("job", "title", job.Title, "priority", job.Priority, "status", job.Status)
+	}
+}
+
+// isSecretArg returns true if the given arg holds a secret of the vault
+// or a token which grants access to the api.
+func isSecretArg(r *gaia.PipelineRun, key string) bool {
+	return key == childTokenArgKey || key == runTokenArgKey || contains(r.Secrets, key)
+}
+
+// setDebugEnv raises the log level of the given pipeline command.
+func setDebugEnv(c *exec.Cmd) {
+	if c.Env == nil {
+		c.Env = os.Environ()
+	}
+	c.Env = append(c.Env, debugLogLevelEnv+"=trace")
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// deliveryInterval is the interval the queued webhooks are checked
+	// for due deliveries.
+	deliveryInterval = 5 * time.Second
+
+	// deliveryMaxAttempts is the number of failed attempts after which
+	// a delivery is moved to the dead-letter list.
+	deliveryMaxAttempts = 8
+
+	// deliveryBackoff is the delay before the first retry. It doubles
+	// with every failed attempt up to deliveryMaxBackoff.
+	deliveryBackoff    = 10 * time.Second
+	deliveryMaxBackoff = time.Hour
+
+	// deliveryRetention is the duration delivered and dead webhooks
+	// are kept.
+	deliveryRetention = 7 * 24 * time.Hour
+
+	// deliveryWorkers is the number of endpoints which are delivered
+	// to concurrently.
+	deliveryWorkers = 4
+)
+
+var (
+	// errDeliveryNotFound is thrown when a webhook delivery does not exist.
+	errDeliveryNotFound = errors.New("webhook delivery not found with the given id")
+
+	// errWebhookRemoved is thrown when the webhook of a delivery has
+	// been removed from the pipeline.
+	errWebhookRemoved = errors.New("webhook has been removed from the pipeline")
+)
+
+// deliverWebhooks delivers the queued webhooks whenever they are due
+// or new webhooks have been queued.
+func (s *Scheduler) deliverWebhooks() {
+	ticker := time.NewTicker(deliveryInterval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDueWebhooks(time.Now())
+		select {
+		case <-ticker.C:
+		case <-s.deliveryTrigger:
+		}
+	}
+}
+
+// triggerDeliveries wakes up the delivery of the queued webhooks.
+func (s *Scheduler) triggerDeliveries() {
+	select {
+	case s.deliveryTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDueWebhooks attempts all pending deliveries which are due at
+// the given time. Each endpoint is delivered to in order by its own
+// worker, so a slow or dead endpoint does not hold up the others.
+// Delivered and dead webhooks beyond the retention are removed.
+func (s *Scheduler) deliverDueWebhooks(now time.Time) {
+	deliveries, err := s.storeService.WebhookDeliveryGetAll()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get webhook deliveries", "error", err.Error())
+		return
+	}
+
+	endpoints := [][]gaia.WebhookDelivery{}
+	index := map[string]int{}
+	for _, d := range deliveries {
+		switch {
+		// Dead deliveries keep the due date of their last attempt
+		case d.Status == gaia.WebhookDelivered && now.Sub(d.Delivered) > deliveryRetention,
+			d.Status == gaia.WebhookDead && now.Sub(d.NextAttempt) > deliveryRetention:
+			if err = s.storeService.WebhookDeliveryDelete(d.ID); err != nil {
+				gaia.Cfg.Logger.Error("cannot remove webhook delivery", "error", err.Error(), "delivery", d.ID)
+			}
+		case d.Status == gaia.WebhookPending && !d.NextAttempt.After(now):
+			endpoint := fmt.Sprintf("%d/%s", d.PipelineID, d.Webhook)
+			i, ok := index[endpoint]
+			if !ok {
+				i = len(endpoints)
+				index[endpoint] = i
+				endpoints = append(endpoints, nil)
+			}
+			endpoints[i] = append(endpoints[i], d)
+		}
+	}
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, deliveryWorkers)
+	for _, e := range endpoints {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(e []gaia.WebhookDelivery) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			s.deliverEndpoint(e, now)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// deliverEndpoint attempts the given due deliveries of one endpoint in
+// order. The remaining deliveries wait for the next round once an
+// attempt failed, so a dead endpoint costs one timeout per round.
+func (s *Scheduler) deliverEndpoint(deliveries []gaia.WebhookDelivery, now time.Time) {
+	for _, d := range deliveries {
+		s.attemptDelivery(&d, now)
+		if err := s.storeService.WebhookDeliveryPut(&d); err != nil {
+			gaia.Cfg.Logger.Error("cannot store webhook delivery", "error", err.Error(), "delivery", d.ID)
+		}
+		if d.Status != gaia.WebhookDelivered {
+			return
+		}
+	}
+}
+
+// attemptDelivery renders and sends the webhook of the given delivery.
+// The webhook is rendered with the current config of the pipeline.
+// Failed deliveries are retried with backoff until they are dead.
+func (s *Scheduler) attemptDelivery(d *gaia.WebhookDelivery, now time.Time) {
+	d.Attempts++
+	err := s.sendDelivery(d)
+	if err == nil {
+		d.Status = gaia.WebhookDelivered
+		d.Delivered = now
+		d.LastError = ""
+		return
+	}
+
+	d.LastError = err.Error()
+	if d.Attempts >= deliveryMaxAttempts || err == errWebhookRemoved {
+		d.Status = gaia.WebhookDead
+		gaia.Cfg.Logger.Error("webhook delivery failed permanently", "error", err.Error(), "webhook", d.Webhook, "pipeline", d.PipelineID, "run", d.RunID)
+		return
+	}
+
+	backoff := deliveryBackoff << uint(d.Attempts-1)
+	if backoff > deliveryMaxBackoff {
+		backoff = deliveryMaxBackoff
+	}
+	d.NextAttempt = now.Add(backoff)
+	gaia.Cfg.Logger.Warn("cannot send webhook", "error", err.Error(), "webhook", d.Webhook, "pipeline", d.PipelineID, "run", d.RunID, "retry", d.NextAttempt)
+}
+
+// sendDelivery renders and sends the webhook of the given delivery.
+func (s *Scheduler) sendDelivery(d *gaia.WebhookDelivery) error {
+	p, err := s.storeService.PipelineGet(d.PipelineID)
+	if err != nil {
+		return err
+	}
+	var w *gaia.Webhook
+	for i := range p.Webhooks {
+		if p.Webhooks[i].Name == d.Webhook {
+			w = &p.Webhooks[i]
+		}
+	}
+	if w == nil {
+		return errWebhookRemoved
+	}
+
+	data := &webhookData{}
+	if err = json.Unmarshal(d.Data, data); err != nil {
+		return err
+	}
+	req, err := renderWebhook(w, data, s.webhookSecrets(d.PipelineID))
+	if err != nil {
+		return err
+	}
+	return sendWebhook(req)
+}
+
+// RedeliverWebhook queues the webhook delivery with the given id again.
+// The delivery gets the full number of attempts.
+func (s *Scheduler) RedeliverWebhook(id int) (*gaia.WebhookDelivery, error) {
+	d, err := s.storeService.WebhookDeliveryGet(id)
+	if err != nil {
+		return nil, err
+	} else if d == nil {
+		return nil, errDeliveryNotFound
+	}
+
+	d.Status = gaia.WebhookPending
+	d.Attempts = 0
+	d.NextAttempt = time.Now()
+	if err = s.storeService.WebhookDeliveryPut(d); err != nil {
+		return nil, err
+	}
+	s.triggerDeliveries()
+	return d, nil
+}
@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// exportBufferLimit is the number of finished runs which wait for
+	// the export. Further runs are dropped until the sink caught up.
+	exportBufferLimit = 500
+
+	// exportTimeout is the maximum duration of one export request.
+	exportTimeout = 10 * time.Second
+
+	// exportRetries is the number of attempts to export a run.
+	exportRetries = 3
+
+	// exportRetryInterval is the interval between two attempts.
+	// It grows with every attempt.
+	exportRetryInterval = 2 * time.Second
+
+	// exportTypeRun and exportTypeJob are the types of the records.
+	exportTypeRun = "run"
+	exportTypeJob = "job"
+)
+
+// exportRecord is the record of a finished run or job which is
+// exported to the configured sink. Durations are given in seconds.
+type exportRecord struct {
+	Type         string    `json:"type"`
+	PipelineID   int       `json:"pipelineid"`
+	PipelineName string    `json:"pipelinename"`
+	RunID        int       `json:"runid"`
+	UniqueID     string    `json:"uniqueid"`
+	JobID        uint32    `json:"jobid,omitempty"`
+	JobTitle     string    `json:"jobtitle,omitempty"`
+	Status       string    `json:"status"`
+	ScheduleDate time.Time `json:"scheduledate,omitempty"`
+	StartDate    time.Time `json:"startdate,omitempty"`
+	FinishDate   time.Time `json:"finishdate,omitempty"`
+	Duration     float64   `json:"duration"`
+	QueueTime    float64   `json:"queuetime,omitempty"`
+	StartedBy    string    `json:"startedby,omitempty"`
+	Canary       bool      `json:"canary,omitempty"`
+	Rerun        bool      `json:"rerun,omitempty"`
+	Child        bool      `json:"child,omitempty"`
+	Slow         bool      `json:"slow,omitempty"`
+}
+
+// exportEnabled returns true if a sink for the run records has been configured.
+func exportEnabled() bool {
+	return gaia.Cfg.Export != "" && gaia.Cfg.Export != gaia.ExportOff
+}
+
+// exportRun queues the records of the given finished run for the export.
+// The run is dropped if the sink cannot keep up.
+func (s *Scheduler) exportRun(r *gaia.PipelineRun) {
+	if !exportEnabled() || r.Shadow {
+		return
+	}
+
+	name := ""
+	p, err := s.storeService.PipelineGet(r.PipelineID)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get pipeline from store", "error", err.Error(), "pipeline", r.PipelineID)
+	} else {
+		name = p.Name
+	}
+
+	select {
+	case s.exports <- runRecords(r, name):
+	default:
+		gaia.Cfg.Logger.Warn("export queue is full. Dropping run", "pipeline", r.PipelineID, "run", r.ID)
+	}
+}
+
+// exportRuns sends the queued run records to the sink. A run is
+// retried a few times before it is dropped.
+func (s *Scheduler) exportRuns() {
+	for records := range s.exports {
+		var err error
+		for attempt := 1; attempt <= exportRetries; attempt++ {
+			if err = sendExport(gaia.Cfg.Export, gaia.Cfg.ExportURL, gaia.Cfg.ExportToken, records); err == nil {
+				break
+			}
+			if attempt < exportRetries {
+				time.Sleep(time.Duration(attempt) * exportRetryInterval)
+			}
+		}
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot export run", "error", err.Error(), "pipeline", records[0].PipelineID, "run", records[0].RunID)
+		}
+	}
+}
+
+// runRecords returns the record of the given run followed by the
+// records of its executed jobs.
+func runRecords(r *gaia.PipelineRun, pipelineName string) []exportRecord {
+	run := exportRecord{
+		Type:         exportTypeRun,
+		PipelineID:   r.PipelineID,
+		PipelineName: pipelineName,
+		RunID:        r.ID,
+		UniqueID:     r.UniqueID,
+		Status:       string(r.Status),
+		ScheduleDate: r.ScheduleDate,
+		StartDate:    r.StartDate,
+		FinishDate:   r.FinishDate,
+		StartedBy:    r.StartedBy,
+		Canary:       r.Canary,
+		Rerun:        r.RerunOf != 0,
+		Child:        r.ParentRunID != 0,
+		Slow:         r.Slow,
+	}
+	if !r.StartDate.IsZero() {
+		run.Duration = r.FinishDate.Sub(r.StartDate).Seconds()
+		if !r.ScheduleDate.IsZero() {
+			run.QueueTime = r.StartDate.Sub(r.ScheduleDate).Seconds()
+		}
+	}
+
+	records := []exportRecord{run}
+	for _, job := range r.Jobs {
+		if job.StartDate.IsZero() {
+			continue
+		}
+		records = append(records, exportRecord{
+			Type:         exportTypeJob,
+			PipelineID:   r.PipelineID,
+			PipelineName: pipelineName,
+			RunID:        r.ID,
+			UniqueID:     r.UniqueID,
+			JobID:        job.ID,
+			JobTitle:     job.Title,
+			Status:       string(job.Status),
+			StartDate:    job.StartDate,
+			FinishDate:   job.FinishDate,
+			Duration:     job.FinishDate.Sub(job.StartDate).Seconds(),
+		})
+	}
+	return records
+}
+
+// exportBody returns the request body and content type which deliver
+// the given records to the given sink.
+func exportBody(sink gaia.ExportSink, records []exportRecord) ([]byte, string, error) {
+	switch sink {
+	case gaia.ExportKafka:
+		type kafkaRecord struct {
+			Key   string       `json:"key"`
+			Value exportRecord `json:"value"`
+		}
+		msg := struct {
+			Records []kafkaRecord `json:"records"`
+		}{}
+		for _, r := range records {
+			msg.Records = append(msg.Records, kafkaRecord{Key: r.UniqueID, Value: r})
+		}
+		body, err := json.Marshal(msg)
+		return body, "application/vnd.kafka.json.v2+json", err
+	case gaia.ExportBigQuery:
+		// The insert id lets BigQuery drop rows of retried requests
+		type bigQueryRow struct {
+			InsertID string       `json:"insertId"`
+			JSON     exportRecord `json:"json"`
+		}
+		msg := struct {
+			Rows []bigQueryRow `json:"rows"`
+		}{}
+		for _, r := range records {
+			msg.Rows = append(msg.Rows, bigQueryRow{InsertID: r.UniqueID + "-" + strconv.FormatUint(uint64(r.JobID), 10), JSON: r})
+		}
+		body, err := json.Marshal(msg)
+		return body, "application/json", err
+	case gaia.ExportHTTP:
+		body, err := json.Marshal(records)
+		return body, "application/json", err
+	}
+	return nil, "", fmt.Errorf("unknown export sink %s", sink)
+}
+
+// sendExport posts the given records to the sink at the given url.
+func sendExport(sink gaia.ExportSink, url, token string, records []exportRecord) error {
+	body, contentType, err := exportBody(sink, records)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := gaia.Cfg.HTTPClient(exportTimeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("export sink returned status %d", resp.StatusCode)
+	}
+
+	// BigQuery reports rejected rows in a successful response
+	if sink == gaia.ExportBigQuery {
+		result := struct {
+			InsertErrors []json.RawMessage `json:"insertErrors"`
+		}{}
+		if err = json.NewDecoder(resp.Body).Decode(&result); err == nil && len(result.InsertErrors) > 0 {
+			return fmt.Errorf("bigquery rejected %d rows", len(result.InsertErrors))
+		}
+	}
+	return nil
+}
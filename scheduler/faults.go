@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+var (
+	// errFaultInjectionDisabled is thrown when faults should be injected
+	// outside of the development mode.
+	errFaultInjectionDisabled = errors.New("fault injection is only available in development mode")
+
+	// errInjectedHeartbeatDrop is returned by heartbeats which have been dropped.
+	errInjectedHeartbeatDrop = errors.New("heartbeat dropped by fault injection")
+
+	// faults holds the currently injected faults.
+	faults     Faults
+	faultsLock sync.RWMutex
+
+	// faultRoll returns a random number in [0.0,1.0) which decides
+	// if a fault is injected.
+	faultRoll = rand.Float64
+)
+
+// Faults represents the faults which are injected into the scheduler,
+// the workers and the store to test their recovery. Rates are given
+// as probability between zero and one.
+type Faults struct {
+	// HeartbeatDropRate is the rate of dropped job heartbeats.
+	HeartbeatDropRate float64 `json:"heartbeatdroprate"`
+
+	// StoreWriteDelay delays every write to the store.
+	StoreWriteDelay time.Duration `json:"storewritedelay"`
+
+	// ProcessKillRate is the rate of pipeline processes which are
+	// killed ProcessKillAfter after their job started.
+	ProcessKillRate  float64       `json:"processkillrate"`
+	ProcessKillAfter time.Duration `json:"processkillafter"`
+}
+
+// Faults returns the currently injected faults.
+func (s *Scheduler) Faults() Faults {
+	faultsLock.RLock()
+	defer faultsLock.RUnlock()
+
+	return faults
+}
+
+// SetFaults replaces the injected faults. Faults can only be injected
+// in development mode. Zero faults stop the injection.
+func (s *Scheduler) SetFaults(f Faults) error {
+	if !gaia.Cfg.DevMode {
+		return errFaultInjectionDisabled
+	}
+	if f.HeartbeatDropRate < 0 || f.HeartbeatDropRate > 1 || f.ProcessKillRate < 0 || f.ProcessKillRate > 1 {
+		return fmt.Errorf("fault rates must be between 0 and 1")
+	}
+	if f.StoreWriteDelay < 0 || f.ProcessKillAfter < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+
+	faults = f
+	s.storeService.SetWriteDelay(f.StoreWriteDelay)
+	gaia.Cfg.Logger.Warn("injecting faults", "heartbeatdroprate", f.HeartbeatDropRate, "storewritedelay", f.StoreWriteDelay.String(), "processkillrate", f.ProcessKillRate)
+	return nil
+}
+
+// injectFault returns true if a fault with the given rate should be injected.
+func injectFault(rate float64) bool {
+	return rate > 0 && faultRoll() < rate
+}
+
+// faultyHeartbeater drops the heartbeats of the wrapped heartbeater
+// with the injected rate.
+type faultyHeartbeater struct {
+	heartbeater
+}
+
+// Heartbeat pings the wrapped heartbeater unless the heartbeat is dropped.
+func (h faultyHeartbeater) Heartbeat() error {
+	faultsLock.RLock()
+	rate := faults.HeartbeatDropRate
+	faultsLock.RUnlock()
+
+	if injectFault(rate) {
+		return errInjectedHeartbeatDrop
+	}
+	return h.heartbeater.Heartbeat()
+}
+
+// injectProcessKill kills the given pipeline process with the injected
+// rate once the injected delay passed. Nothing is killed if stop is
+// closed before.
+func injectProcessKill(proc *os.Process, stop <-chan struct{}) {
+	faultsLock.RLock()
+	f := faults
+	faultsLock.RUnlock()
+
+	if !injectFault(f.ProcessKillRate) {
+		return
+	}
+	select {
+	case <-stop:
+	case <-time.After(f.ProcessKillAfter):
+		gaia.Cfg.Logger.Warn("killing pipeline process by fault injection", "pid", proc.Pid)
+		proc.Kill()
+	}
+}
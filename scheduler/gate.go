@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// defaultGateInterval is the interval a gate is checked if not given.
+	defaultGateInterval = 10 * time.Second
+
+	// defaultGateTimeout is the duration a run waits for a gate if not given.
+	defaultGateTimeout = 10 * time.Minute
+
+	// maxGateCheckTimeout is the maximum duration of a single check.
+	maxGateCheckTimeout = 30 * time.Second
+)
+
+var (
+	// errInvalidGateName is thrown when a gate has an empty or duplicated name.
+	errInvalidGateName = errors.New("invalid or duplicated gate name given")
+
+	// errInvalidGateType is thrown when a gate has an unknown type.
+	errInvalidGateType = errors.New("invalid gate type given. Must be http, dns, delay or servicenow")
+
+	// errInvalidGateTarget is thrown when the target does not fit the gate type.
+	errInvalidGateTarget = errors.New("invalid gate target given")
+
+	// errInternalGateTarget is thrown when an HTTP gate targets an
+	// internal address of the server network.
+	errInternalGateTarget = errors.New("gate target must not be an internal address")
+
+	// errInvalidGateDuration is thrown when a gate has a negative duration
+	// or a delay gate has no delay.
+	errInvalidGateDuration = errors.New("invalid gate duration given")
+
+	// errGateCancelled is returned when the run was cancelled while
+	// waiting for a gate.
+	errGateCancelled = errors.New("run cancelled while waiting for gate")
+
+	// gateAddressAllowed returns true if HTTP gates may connect to the
+	// given address. Internal addresses are refused so gates cannot be
+	// used to probe the network of the server.
+	gateAddressAllowed = isPublicAddress
+
+	// gateTransport is the transport HTTP gates are checked with.
+	gateTransport     *http.Transport
+	gateTransportOnce sync.Once
+
+	// gateChecks holds the check of every gate type which is polled.
+	// The check returns nil if the gate is open.
+	gateChecks = map[gaia.GateType]func(target string, timeout time.Duration) error{
+		gaia.GateHTTP:       checkHTTPGate,
+		gaia.GateDNS:        checkDNSGate,
+		gaia.GateServiceNow: checkChangeRequest,
+	}
+)
+
+// gateRejectedError is returned by gate checks when the gate will
+// never open. The gate is not checked again.
+type gateRejectedError struct {
+	reason string
+}
+
+// Error returns the reason of the rejection.
+func (e *gateRejectedError) Error() string {
+	return e.reason
+}
+
+// ValidateGates checks the given gates.
+func ValidateGates(gates []gaia.Gate) error {
+	names := []string{}
+	for _, g := range gates {
+		if strings.TrimSpace(g.Name) == "" || contains(names, g.Name) {
+			return errInvalidGateName
+		}
+		names = append(names, g.Name)
+
+		if g.Interval < 0 || g.Timeout < 0 || g.Delay < 0 {
+			return errInvalidGateDuration
+		}
+		switch g.Type {
+		case gaia.GateHTTP:
+			u, err := url.Parse(g.Target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errInvalidGateTarget
+			}
+			if !gateHostAllowed(u.Hostname()) {
+				return errInternalGateTarget
+			}
+		case gaia.GateServiceNow:
+			// The change requests are always created in the configured
+			// instance as they carry its credentials
+			if serviceNowBase() == "" {
+				return errServiceNowNotConfigured
+			}
+			if g.Target != "" && strings.TrimSuffix(g.Target, "/") != serviceNowBase() {
+				return errInvalidGateTarget
+			}
+			for name, value := range g.Fields {
+				if _, err := parseWebhookTemplate(name, value, nil); err != nil {
+					return fmt.Errorf("invalid %s field of gate %s: %s", name, g.Name, err.Error())
+				}
+			}
+		case gaia.GateDNS:
+			if strings.TrimSpace(g.Target) == "" {
+				return errInvalidGateTarget
+			}
+		case gaia.GateDelay:
+			if g.Delay == 0 {
+				return errInvalidGateDuration
+			}
+		default:
+			return errInvalidGateType
+		}
+	}
+	return nil
+}
+
+// waitForGates blocks until all given gates are open. The gates are
+// waited on in order. Returns an error if a gate did not open in time
+// or the given cancel channel has been closed. The given function is
+// called with every gate before it is waited on. It can change the
+// target of the gate.
+func waitForGates(gates []gaia.Gate, cancel <-chan struct{}, waiting func(g *gaia.Gate) error) error {
+	for i := range gates {
+		g := &gates[i]
+		if err := waiting(g); err != nil {
+			return fmt.Errorf("cannot open gate %s: %s", g.Name, err.Error())
+		}
+
+		if g.Type == gaia.GateDelay {
+			select {
+			case <-time.After(g.Delay):
+				continue
+			case <-cancel:
+				return errGateCancelled
+			}
+		}
+
+		if err := waitForGate(g, cancel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForGate polls the check of the given gate until it is open.
+func waitForGate(g *gaia.Gate, cancel <-chan struct{}) error {
+	interval, timeout := g.Interval, g.Timeout
+	if interval == 0 {
+		interval = defaultGateInterval
+	}
+	if timeout == 0 {
+		timeout = defaultGateTimeout
+	}
+	checkTimeout := interval
+	if checkTimeout > maxGateCheckTimeout {
+		checkTimeout = maxGateCheckTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := gateChecks[g.Type](g.Target, checkTimeout)
+		if err == nil {
+			return nil
+		} else if _, ok := err.(*gateRejectedError); ok {
+			return fmt.Errorf("gate %s has been rejected: %s", g.Name, err.Error())
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("gate %s did not open within %s: %s", g.Name, timeout, err.Error())
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-cancel:
+			return errGateCancelled
+		}
+	}
+}
+
+// checkHTTPGate returns nil if a GET request to the given url returns 200.
+func checkHTTPGate(target string, timeout time.Duration) error {
+	gateTransportOnce.Do(func() {
+		// Gates connect directly. The address is checked after the
+		// name has been resolved so it cannot be changed by DNS.
+		dialer := &net.Dialer{Timeout: maxGateCheckTimeout, Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !gateAddressAllowed(ip) {
+				return errInternalGateTarget
+			}
+			return nil
+		}}
+		gateTransport = &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     gaia.Cfg.TLS,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		}
+	})
+
+	client := &http.Client{Transport: gateTransport, Timeout: timeout}
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// gateHostAllowed returns true if the given host of a gate target is
+// no internal address. Names are checked again when connecting.
+func gateHostAllowed(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return gateAddressAllowed(ip)
+	}
+	return !strings.EqualFold(host, "localhost") && !strings.HasSuffix(strings.ToLower(host), ".localhost")
+}
+
+// isPublicAddress returns true if the given address is neither a
+// loopback, private, link-local, multicast nor unspecified address.
+func isPublicAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	// Shared address space of carrier-grade NAT
+	_, cgnat, _ := net.ParseCIDR("100.64.0.0/10")
+	return !cgnat.Contains(ip)
+}
+
+// checkDNSGate returns nil if the given host name can be resolved.
+// The lookup is abandoned after the given timeout.
+func checkDNSGate(target string, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := net.LookupHost(target)
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("lookup of %s timed out", target)
+	}
+}
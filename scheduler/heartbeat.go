@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	// heartbeatInterval is the interval the pipeline process is pinged.
+	heartbeatInterval = 5 * time.Second
+
+	// stackDumpGracePeriod is the duration a hung process has to write
+	// its stack dump before it is killed.
+	stackDumpGracePeriod = 3 * time.Second
+)
+
+// heartbeater is implemented by everything which can be checked
+// for liveness.
+type heartbeater interface {
+	Heartbeat() error
+}
+
+// watchHeartbeat pings the given heartbeater in the given interval until
+// stop is closed. If no heartbeat succeeded for the given timeout, the
+// returned channel is closed and onHang is called afterwards.
+func watchHeartbeat(h heartbeater, interval, timeout time.Duration, stop <-chan struct{}, onHang func()) chan struct{} {
+	hung := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// A ping might block forever if the process is frozen.
+		// Therefore the pings are done asynchronously.
+		beats := make(chan error, 1)
+		pending := false
+		last := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case err := <-beats:
+				pending = false
+				if err == nil {
+					last = time.Now()
+				}
+			case <-ticker.C:
+				// The hang is signaled before the process is killed
+				// so its exit is already recognized as hang.
+				if time.Since(last) > timeout {
+					close(hung)
+					onHang()
+					return
+				}
+				if !pending {
+					pending = true
+					go func() {
+						beats <- h.Heartbeat()
+					}()
+				}
+			}
+		}
+	}()
+
+	return hung
+}
+
+// killHungProcess requests a stack dump from the given hung process
+// and kills it after the grace period. The dump is written to the
+// job log.
+func killHungProcess(proc *os.Process) {
+	if err := dumpStack(proc); err == nil {
+		time.Sleep(stackDumpGracePeriod)
+	}
+	proc.Kill()
+}
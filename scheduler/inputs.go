@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// inputArgPrefix prefixes the args which hold the paths
+	// of the input files of a run.
+	inputArgPrefix = "gaia_input_"
+)
+
+var (
+	// errInvalidInputName is thrown when an input file has an empty,
+	// invalid or duplicated name.
+	errInvalidInputName = errors.New("invalid or duplicated input file name given")
+)
+
+// RunInput is a file which is placed in the workspace of a run
+// before its jobs are started.
+type RunInput struct {
+	// Name is the file name in the inputs folder of the run.
+	Name string
+
+	// Open opens the content of the file.
+	Open func() (io.ReadCloser, error)
+}
+
+// RunInputsOf returns the input files of the given run.
+// They can be passed to a re-run of the given run.
+func RunInputsOf(r *gaia.PipelineRun) []RunInput {
+	inputs := []RunInput{}
+	for _, name := range r.Inputs {
+		path := filepath.Join(inputsFolder(r), name)
+		inputs = append(inputs, RunInput{
+			Name: name,
+			Open: func() (io.ReadCloser, error) {
+				return os.Open(path)
+			},
+		})
+	}
+	return inputs
+}
+
+// validateInputs checks the names of the given input files.
+// Returns the names of the input files.
+func validateInputs(inputs []RunInput) ([]string, error) {
+	names := []string{}
+	for _, input := range inputs {
+		name := input.Name
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || contains(names, name) {
+			return nil, errInvalidInputName
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// storeInputs copies the given input files into the inputs folder
+// of the given run.
+func storeInputs(r *gaia.PipelineRun, inputs []RunInput) error {
+	folder := inputsFolder(r)
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+
+	for _, input := range inputs {
+		if err := storeInput(filepath.Join(folder, input.Name), input); err != nil {
+			os.RemoveAll(folder)
+			return err
+		}
+	}
+	return nil
+}
+
+// storeInput copies the given input file to the given path.
+func storeInput(path string, input RunInput) error {
+	src, err := input.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// addInputArgs passes the paths of the input files of the given run
+// to the jobs.
+func addInputArgs(r *gaia.PipelineRun, args map[string]string) {
+	for _, name := range r.Inputs {
+		args[inputArgPrefix+name] = filepath.Join(inputsFolder(r), name)
+	}
+}
+
+// inputsFolder returns the folder of the input files of the given run.
+func inputsFolder(r *gaia.PipelineRun) string {
+	return filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(r.PipelineID), strconv.Itoa(r.ID), gaia.InputsFolderName)
+}
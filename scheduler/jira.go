@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// jiraTimeout is the maximum duration of one Jira request.
+	jiraTimeout = 10 * time.Second
+)
+
+// jiraClient talks to the REST API of Jira.
+type jiraClient struct {
+	url   string
+	user  string
+	token string
+}
+
+// jiraTransition is a transition of a Jira issue.
+type jiraTransition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+// updateJiraIssues comments on the Jira issues of the commit of the
+// given successful run and transitions them. Only the first successful
+// run of a commit updates the issues.
+func (s *Scheduler) updateJiraIssues(p *gaia.Pipeline, r *gaia.PipelineRun) {
+	if p.Jira == nil || gaia.Cfg.JiraURL == "" || len(r.Commit.Issues) == 0 {
+		return
+	}
+	runs, err := s.storeService.PipelineGetAllRuns(r.PipelineID)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get pipeline runs", "error", err.Error(), "pipeline", r.PipelineID)
+		return
+	}
+	for _, other := range runs {
+		if other.ID != r.ID && !other.Shadow && other.Status == gaia.RunSuccess && other.Commit.Hash == r.Commit.Hash {
+			return
+		}
+	}
+
+	client := &jiraClient{url: strings.TrimSuffix(gaia.Cfg.JiraURL, "/"), user: gaia.Cfg.JiraUser, token: gaia.Cfg.JiraToken}
+	integration := *p.Jira
+	comment := jiraComment(p, r)
+	go func() {
+		for _, key := range r.Commit.Issues {
+			if len(integration.Projects) > 0 && !contains(integration.Projects, strings.SplitN(key, "-", 2)[0]) {
+				continue
+			}
+			if err := client.updateIssue(key, comment, integration.Transition); err != nil {
+				gaia.Cfg.Logger.Error("cannot update jira issue", "error", err.Error(), "issue", key, "pipeline", p.Name, "run", r.ID)
+			}
+		}
+	}()
+}
+
+// jiraComment returns the comment which links the given run.
+func jiraComment(p *gaia.Pipeline, r *gaia.PipelineRun) string {
+	comment := fmt.Sprintf("Deployed by run %d of pipeline %s (commit %s).", r.ID, p.Name, shortCommit(r.Commit.Hash))
+	if gaia.Cfg.ExternalURL != "" {
+		comment += fmt.Sprintf("\n%s/pipeline/detail?pipelineid=%d&runid=%d", strings.TrimSuffix(gaia.Cfg.ExternalURL, "/"), p.ID, r.ID)
+	}
+	return comment
+}
+
+// shortCommit returns the abbreviated form of the given commit hash.
+func shortCommit(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+// updateIssue comments on the issue with the given key and moves it
+// with the transition of the given name. The transition is skipped if
+// it is not available for the issue, e.g. because it has already
+// been moved.
+func (c *jiraClient) updateIssue(key, comment, transition string) error {
+	body := map[string]string{"body": comment}
+	if err := c.do(http.MethodPost, "/rest/api/2/issue/"+key+"/comment", body, nil); err != nil {
+		return err
+	}
+	if transition == "" {
+		return nil
+	}
+
+	transitions := struct {
+		Transitions []jiraTransition `json:"transitions"`
+	}{}
+	if err := c.do(http.MethodGet, "/rest/api/2/issue/"+key+"/transitions", nil, &transitions); err != nil {
+		return err
+	}
+	for _, t := range transitions.Transitions {
+		if strings.EqualFold(t.Name, transition) || strings.EqualFold(t.To.Name, transition) {
+			body := map[string]interface{}{"transition": map[string]string{"id": t.ID}}
+			return c.do(http.MethodPost, "/rest/api/2/issue/"+key+"/transitions", body, nil)
+		}
+	}
+	return nil
+}
+
+// do sends a request to the given path of the Jira API. The response
+// is decoded into the given result if given.
+func (c *jiraClient) do(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.token)
+	}
+
+	client := gaia.Cfg.HTTPClient(jiraTimeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("jira returned status %d for %s", resp.StatusCode, path)
+	}
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"errors"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+var (
+	// errJobNotFound is thrown when a selected job does not exist in the pipeline.
+	errJobNotFound = errors.New("selected job does not exist in pipeline")
+)
+
+// selectJobs returns the ids of the jobs which should be executed for
+// the given selection. With dependencies, all jobs which have a lower
+// priority than one of the selected jobs are included.
+// Returns nil if all jobs should be executed.
+func selectJobs(jobs []gaia.Job, selected []uint32, dependencies bool) ([]uint32, error) {
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	// Find the highest priority of the selected jobs
+	var maxPrio int64
+	for i, id := range selected {
+		job := findJob(jobs, id)
+		if job == nil {
+			return nil, errJobNotFound
+		}
+		if i == 0 || job.Priority > maxPrio {
+			maxPrio = job.Priority
+		}
+	}
+
+	ids := []uint32{}
+	for _, job := range jobs {
+		if containsJob(selected, job.ID) || (dependencies && job.Priority < maxPrio) {
+			ids = append(ids, job.ID)
+		}
+	}
+	return ids, nil
+}
+
+// GetUnsuccessfulJobs returns the ids of all jobs of the given run
+// which did not succeed. These are the failed jobs and all jobs which
+// have not been executed because of the failure.
+func GetUnsuccessfulJobs(r *gaia.PipelineRun) []uint32 {
+	ids := []uint32{}
+	for _, job := range r.Jobs {
+		if job.Status != gaia.JobSuccess {
+			ids = append(ids, job.ID)
+		}
+	}
+	return ids
+}
+
+// filterJobs returns all given jobs which are in the given ids.
+// All jobs are returned if ids is empty.
+func filterJobs(jobs []gaia.Job, ids []uint32) []gaia.Job {
+	if len(ids) == 0 {
+		return jobs
+	}
+
+	filtered := []gaia.Job{}
+	for _, job := range jobs {
+		if containsJob(ids, job.ID) {
+			filtered = append(filtered, job)
+		}
+	}
+	return filtered
+}
+
+// findJob returns the job with the given id or nil.
+func findJob(jobs []gaia.Job, id uint32) *gaia.Job {
+	for i := range jobs {
+		if jobs[i].ID == id {
+			return &jobs[i]
+		}
+	}
+	return nil
+}
+
+// containsJob checks if the given ids contain the given job id.
+func containsJob(ids []uint32, id uint32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,299 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/template/parse"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// LintFinding represents a single problem of a pipeline definition.
+type LintFinding struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Job      string `json:"job,omitempty"`
+}
+
+// LintPipeline statically checks the given pipeline. The binary is only
+// started to receive the declared jobs. No job is executed.
+// Jobs are ordered by their priority only, so their dependencies cannot
+// form cycles and are not checked.
+func (s *Scheduler) LintPipeline(p *gaia.Pipeline) ([]LintFinding, error) {
+	findings := []LintFinding{}
+
+	// Without binary there is nothing else to check
+	if _, err := os.Stat(p.ExecPath); err != nil {
+		findings = append(findings, LintFinding{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("pipeline binary is not accessible: %s", err.Error()),
+		})
+		return findings, nil
+	}
+
+	jobs, err := s.getPipelineJobs(p)
+	if err != nil {
+		findings = append(findings, LintFinding{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("cannot receive jobs from pipeline: %s", err.Error()),
+		})
+	} else {
+		findings = append(findings, lintJobs(jobs)...)
+	}
+
+	// Granted secrets must exist in the vault
+	granted, err := s.storeService.SecretGrantsGet(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, lintSecretReferences(p, granted)...)
+	findings = append(findings, lintParams(p)...)
+	for _, key := range granted {
+		value, err := s.storeService.VaultGet(key)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			findings = append(findings, LintFinding{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("granted secret %s does not exist in vault", key),
+			})
+		}
+	}
+
+	if p.SSH != nil {
+		if _, err := sshBinary(p); err != nil {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	if p.Sandbox.SeccompProfile != "" {
+		if _, err := os.Stat(p.Sandbox.SeccompProfile); err != nil {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("seccomp profile is not accessible: %s", err.Error()),
+			})
+		}
+	}
+
+	if err := ValidateProblemMatchers(p.ProblemMatchers); err != nil {
+		findings = append(findings, LintFinding{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("invalid problem matcher: %s", err.Error()),
+		})
+	}
+
+	return findings, nil
+}
+
+// lintJobs checks the declared jobs of a pipeline.
+func lintJobs(jobs []gaia.Job) []LintFinding {
+	findings := []LintFinding{}
+	if len(jobs) == 0 {
+		findings = append(findings, LintFinding{
+			Severity: SeverityWarning,
+			Message:  "pipeline does not declare any job",
+		})
+	}
+
+	titles := map[string]bool{}
+	ids := map[uint32]string{}
+	for _, job := range jobs {
+		if job.Title == "" {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  "job without title",
+			})
+			continue
+		}
+
+		// Job ids are derived from the title
+		if titles[job.Title] {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  "duplicate job title",
+				Job:      job.Title,
+			})
+			continue
+		}
+		titles[job.Title] = true
+
+		if other, ok := ids[job.ID]; ok {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("job id collides with job %s", other),
+				Job:      job.Title,
+			})
+		}
+		ids[job.ID] = job.Title
+
+		if job.Priority < 0 {
+			findings = append(findings, LintFinding{
+				Severity: SeverityWarning,
+				Message:  "negative job priority",
+				Job:      job.Title,
+			})
+		}
+	}
+
+	return findings
+}
+
+// lintSecretReferences checks that the secrets which are referenced by
+// the presets, the SSH host, the webhooks and the gates of the given
+// pipeline are granted to it.
+func lintSecretReferences(p *gaia.Pipeline, granted []string) []LintFinding {
+	findings := []LintFinding{}
+	refs := map[string][]string{}
+	for _, preset := range p.Presets {
+		for _, key := range preset.Secrets {
+			refs[key] = append(refs[key], "preset "+preset.Name)
+		}
+	}
+	if p.SSH != nil && p.SSH.Key != "" {
+		refs[p.SSH.Key] = append(refs[p.SSH.Key], "ssh host "+p.SSH.Host)
+	}
+
+	// Templates reference secrets with the secret function
+	templates := map[string]string{}
+	for _, w := range p.Webhooks {
+		templates["webhook "+w.Name+" url"] = w.URL
+		templates["webhook "+w.Name+" body"] = w.Body
+		for name, value := range w.Headers {
+			templates["webhook "+w.Name+" header "+name] = value
+		}
+	}
+	for _, g := range p.Gates {
+		for name, value := range g.Fields {
+			templates["gate "+g.Name+" field "+name] = value
+		}
+	}
+	for name, text := range templates {
+		keys, err := templateSecrets(name, text)
+		if err != nil {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("invalid template of %s: %s", name, err.Error()),
+			})
+			continue
+		}
+		for _, key := range keys {
+			refs[key] = append(refs[key], name)
+		}
+	}
+
+	for key, users := range refs {
+		if contains(granted, key) {
+			continue
+		}
+		sort.Strings(users)
+		for _, user := range users {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("%s references secret %s which is not granted to the pipeline", user, key),
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Message < findings[j].Message
+	})
+	return findings
+}
+
+// templateSecrets returns the keys of the secrets the given template
+// looks up with constant keys.
+func templateSecrets(name, text string) ([]string, error) {
+	t, err := parseWebhookTemplate(name, text, nil)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) == 2 {
+				fn, isIdent := n.Args[0].(*parse.IdentifierNode)
+				key, isString := n.Args[1].(*parse.StringNode)
+				if isIdent && isString && fn.Ident == "secret" {
+					keys = append(keys, key.Text)
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		}
+	}
+	if t.Tree != nil {
+		walk(t.Tree.Root)
+	}
+	return keys, nil
+}
+
+// lintParams checks that the schedules and presets of the given
+// pipeline pass the params its contract requires. Their runs would be
+// rejected otherwise.
+func lintParams(p *gaia.Pipeline) []LintFinding {
+	findings := []LintFinding{}
+	sources := map[string]map[string]string{}
+	for i, schedule := range p.Schedules {
+		sources[fmt.Sprintf("schedule %d (%s)", i+1, schedule.Cron)] = schedule.Params
+	}
+	for _, preset := range p.Presets {
+		sources["preset "+preset.Name] = preset.Params
+	}
+
+	for source, params := range sources {
+		err := checkContract(p, contractParams, params)
+		if err == nil {
+			continue
+		}
+		contractErr, ok := err.(*ContractError)
+		if !ok {
+			// The schema itself is invalid
+			return []LintFinding{{Severity: SeverityError, Message: err.Error()}}
+		}
+		for _, v := range contractErr.Violations {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("%s passes invalid params: %s: %s", source, v.Path, v.Message),
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Message < findings[j].Message
+	})
+	return findings
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// SeverityError marks problems which are errors.
+	SeverityError = "error"
+
+	// SeverityWarning marks problems which are warnings.
+	SeverityWarning = "warning"
+
+	// maxMatcherLineLength is the longest log line which is matched.
+	maxMatcherLineLength = 1024 * 1024
+)
+
+var (
+	// errInvalidSeverity is thrown when a problem matcher has an unknown severity.
+	errInvalidSeverity = errors.New("invalid severity given. Must be error or warning")
+
+	// errMissingMatcherName is thrown when a problem matcher has no name.
+	errMissingMatcherName = errors.New("problem matcher name is missing")
+)
+
+// ValidateProblemMatchers checks that all given matchers are valid.
+func ValidateProblemMatchers(matchers []gaia.ProblemMatcher) error {
+	for _, m := range matchers {
+		if m.Name == "" {
+			return errMissingMatcherName
+		}
+		if m.Severity != SeverityError && m.Severity != SeverityWarning {
+			return errInvalidSeverity
+		}
+		if _, err := regexp.Compile(m.Pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotateRun applies the problem matchers of the pipeline to all
+// job logs of the given run and sets the annotations of the run.
+func (s *Scheduler) annotateRun(r *gaia.PipelineRun) {
+	p, err := s.storeService.PipelineGet(r.PipelineID)
+	if err != nil || len(p.ProblemMatchers) == 0 {
+		return
+	}
+
+	path := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(r.PipelineID), strconv.Itoa(r.ID), gaia.LogsFolderName)
+	r.Annotations = matchJobLogs(path, r.Jobs, p.ProblemMatchers)
+}
+
+// matchJobLogs scans the logs of the given jobs in the given logs folder
+// line by line and returns one annotation per job and matcher.
+func matchJobLogs(path string, jobs []gaia.Job, matchers []gaia.ProblemMatcher) []gaia.Annotation {
+	// Matchers have been validated when they were stored
+	patterns := make([]*regexp.Regexp, 0, len(matchers))
+	for _, m := range matchers {
+		re, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			gaia.Cfg.Logger.Error("invalid problem matcher", "error", err.Error(), "matcher", m.Name)
+			return nil
+		}
+		patterns = append(patterns, re)
+	}
+
+	var annotations []gaia.Annotation
+	for _, job := range jobs {
+		f, err := os.Open(filepath.Join(path, strconv.FormatUint(uint64(job.ID), 10)))
+		if err != nil {
+			// Jobs which have not been executed have no log
+			continue
+		}
+
+		found := make([]*gaia.Annotation, len(matchers))
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxMatcherLineLength)
+		var offset int64
+		for line := 1; scanner.Scan(); line++ {
+			text := scanner.Text()
+			for i, re := range patterns {
+				if !re.MatchString(text) {
+					continue
+				}
+				if found[i] == nil {
+					found[i] = &gaia.Annotation{
+						Matcher:  matchers[i].Name,
+						Severity: matchers[i].Severity,
+						JobID:    job.ID,
+						Message:  text,
+						Line:     line,
+						Offset:   offset,
+					}
+				}
+				found[i].Count++
+			}
+			offset += int64(len(scanner.Bytes())) + 1
+		}
+		if err := scanner.Err(); err != nil {
+			gaia.Cfg.Logger.Debug("cannot scan whole job log for problems", "error", err.Error(), "job", job.Title)
+		}
+		f.Close()
+
+		for _, a := range found {
+			if a != nil {
+				annotations = append(annotations, *a)
+			}
+		}
+	}
+
+	return annotations
+}
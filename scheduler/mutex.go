@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+var (
+	// errInvalidMutexGroup is thrown when a mutex group name is empty.
+	errInvalidMutexGroup = errors.New("mutex group name must not be empty")
+
+	// errInvalidConcurrencyParam is thrown when a concurrency param name is empty.
+	errInvalidConcurrencyParam = errors.New("concurrency param name must not be empty")
+)
+
+// MutexState represents the holder of an acquired mutex group.
+type MutexState struct {
+	Name       string    `json:"name"`
+	PipelineID int       `json:"pipelineid"`
+	RunID      int       `json:"runid"`
+	Since      time.Time `json:"since"`
+}
+
+// ValidateMutexGroups checks the given mutex group names and returns
+// them sorted and without duplicates.
+func ValidateMutexGroups(groups []string) ([]string, error) {
+	unique := []string{}
+	for _, group := range groups {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			return nil, errInvalidMutexGroup
+		}
+		if !contains(unique, group) {
+			unique = append(unique, group)
+		}
+	}
+	sort.Strings(unique)
+	return unique, nil
+}
+
+// ValidateConcurrencyParams checks the given concurrency param names
+// and returns them sorted and without duplicates.
+func ValidateConcurrencyParams(params []string) ([]string, error) {
+	unique, err := ValidateMutexGroups(params)
+	if err == errInvalidMutexGroup {
+		return nil, errInvalidConcurrencyParam
+	}
+	return unique, err
+}
+
+// runMutexGroups returns the mutex groups the given run of the given
+// pipeline has to hold. Every concurrency param adds a group of the
+// pipeline and the resolved value of the param.
+func runMutexGroups(p *gaia.Pipeline, variables map[string]string) []string {
+	groups := append([]string{}, p.MutexGroups...)
+	for _, param := range p.ConcurrencyParams {
+		groups = append(groups, fmt.Sprintf("pipeline-%d/%s=%s", p.ID, param, variables[param]))
+	}
+	return groups
+}
+
+// Mutexes returns the holders of all acquired mutex groups sorted by name.
+func (s *Scheduler) Mutexes() []MutexState {
+	s.mutexesLock.Lock()
+	defer s.mutexesLock.Unlock()
+
+	mutexes := []MutexState{}
+	for _, m := range s.mutexes {
+		mutexes = append(mutexes, *m)
+	}
+	sort.Slice(mutexes, func(i, j int) bool {
+		return mutexes[i].Name < mutexes[j].Name
+	})
+	return mutexes
+}
+
+// acquireMutexGroups acquires all given mutex groups for the given run.
+// Either all groups are acquired or none. Returns the holder of the
+// first group which is held by another run or nil if all groups have
+// been acquired.
+func (s *Scheduler) acquireMutexGroups(groups []string, r *gaia.PipelineRun) *MutexState {
+	s.mutexesLock.Lock()
+	defer s.mutexesLock.Unlock()
+
+	for _, group := range groups {
+		if m, ok := s.mutexes[group]; ok {
+			held := *m
+			return &held
+		}
+	}
+
+	now := time.Now()
+	for _, group := range groups {
+		s.mutexes[group] = &MutexState{
+			Name:       group,
+			PipelineID: r.PipelineID,
+			RunID:      r.ID,
+			Since:      now,
+		}
+	}
+	return nil
+}
+
+// releaseMutexGroups releases the given mutex groups. Queued runs which
+// have been held by one of the groups are no longer marked as held.
+func (s *Scheduler) releaseMutexGroups(groups []string) {
+	s.mutexesLock.Lock()
+	released := map[string]bool{}
+	for _, group := range groups {
+		if m, ok := s.mutexes[group]; ok {
+			released[heldByMutex(m)] = true
+			delete(s.mutexes, group)
+		}
+	}
+	s.mutexesLock.Unlock()
+	if len(released) == 0 {
+		return
+	}
+
+	// The dispatcher must not overwrite the updated runs
+	s.scheduleLock.Lock()
+	defer s.scheduleLock.Unlock()
+
+	runs, err := s.storeService.PipelineGetRunsByStatus(gaia.RunNotScheduled)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get queued runs", "error", err.Error())
+		return
+	}
+	for i := range runs {
+		if !released[runs[i].HeldBy] {
+			continue
+		}
+		runs[i].HeldBy = ""
+		if err = s.storeService.PipelinePutRun(&runs[i]); err != nil {
+			gaia.Cfg.Logger.Error("cannot put pipeline run into store", "error", err.Error())
+		}
+	}
+}
+
+// heldMutexReasons returns the reasons of runs which are held by one
+// of the currently acquired mutex groups.
+func (s *Scheduler) heldMutexReasons() map[string]bool {
+	s.mutexesLock.Lock()
+	defer s.mutexesLock.Unlock()
+
+	reasons := map[string]bool{}
+	for _, m := range s.mutexes {
+		reasons[heldByMutex(m)] = true
+	}
+	return reasons
+}
+
+// heldByMutex returns the reason of runs which are held by the given
+// mutex group.
+func heldByMutex(m *MutexState) string {
+	return fmt.Sprintf("mutex group %s is held by run %d of pipeline %d", m.Name, m.RunID, m.PipelineID)
+}
@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// opaTimeout is the maximum duration of a policy decision.
+	opaTimeout = 5 * time.Second
+)
+
+// errNoPolicyDecision is thrown when the policy engine returned no
+// decision for a run. Runs are denied in that case.
+var errNoPolicyDecision = errors.New("policy engine returned no decision")
+
+// runDeniedError is thrown when the policy engine denied a run.
+type runDeniedError struct {
+	reason string
+}
+
+// Error returns the reason why the run has been denied.
+func (e *runDeniedError) Error() string {
+	if e.reason == "" {
+		return "run denied by policy"
+	}
+	return "run denied by policy: " + e.reason
+}
+
+// opaInput is the input document of a run authorization.
+type opaInput struct {
+	User        *opaUser          `json:"user,omitempty"`
+	Pipeline    opaPipeline       `json:"pipeline"`
+	Params      map[string]string `json:"params"`
+	Secrets     []string          `json:"secrets"`
+	Jobs        []uint32          `json:"jobs"`
+	Canary      bool              `json:"canary"`
+	Debug       bool              `json:"debug"`
+	Rerun       bool              `json:"rerun"`
+	Child       bool              `json:"child"`
+	Environment string            `json:"environment"`
+	Time        opaTime           `json:"time"`
+}
+
+// opaUser is the user who started a run. Triggered runs have no user.
+type opaUser struct {
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+}
+
+// opaPipeline is the pipeline a run is started for. Branch is the
+// branch the pipeline has been built from and Commit the hash of the
+// built commit.
+type opaPipeline struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Team   string `json:"team"`
+	Branch string `json:"branch"`
+	Commit string `json:"commit"`
+}
+
+// opaTime is the local time a run is started at. Weekday and hour
+// are given to keep policies about business hours short.
+type opaTime struct {
+	RFC3339 string `json:"rfc3339"`
+	Weekday string `json:"weekday"`
+	Hour    int    `json:"hour"`
+}
+
+// opaDecision is the result of a run authorization. The policy either
+// returns a boolean or an object with the decision and a reason.
+type opaDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// UnmarshalJSON accepts a boolean or a decision object.
+func (d *opaDecision) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &d.Allow); err == nil {
+		return nil
+	}
+
+	type decision opaDecision
+	return json.Unmarshal(data, (*decision)(d))
+}
+
+// authorizeRun asks the policy engine if the given run may be started.
+// Authorization is skipped if no policy engine has been configured.
+// Denied runs are recorded in the audit log.
+func (s *Scheduler) authorizeRun(p *gaia.Pipeline, o *ScheduleOptions, now time.Time) error {
+	if gaia.Cfg.OPAURL == "" {
+		return nil
+	}
+
+	input := opaInput{
+		Pipeline: opaPipeline{
+			ID:     p.ID,
+			Name:   p.Name,
+			Team:   p.Team,
+			Branch: p.Repo.SelectedBranch,
+			Commit: p.Commit.Hash,
+		},
+		Params:      o.Params,
+		Secrets:     o.Secrets,
+		Jobs:        o.Jobs,
+		Canary:      o.Canary,
+		Debug:       o.Debug,
+		Rerun:       o.RerunOf != 0,
+		Child:       o.Parent != nil,
+		Environment: gaia.Cfg.Environment,
+		Time: opaTime{
+			RFC3339: now.Format(time.RFC3339),
+			Weekday: now.Weekday().String(),
+			Hour:    now.Hour(),
+		},
+	}
+	if o.User != "" {
+		u, err := s.storeService.UserGet(o.User)
+		if err != nil {
+			return err
+		}
+		input.User = &opaUser{Username: o.User, Admin: u != nil && u.Admin}
+	}
+
+	decision, err := queryOPA(gaia.Cfg.OPAURL, &input)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get policy decision", "error", err.Error(), "pipeline", p.Name)
+		return err
+	} else if decision.Allow {
+		return nil
+	}
+
+	denied := &runDeniedError{reason: decision.Reason}
+	err = s.storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         o.User,
+		Action:        gaia.AuditRunDenied,
+		Target:        fmt.Sprintf("pipeline %d", p.ID),
+		Message:       denied.Error(),
+		CorrelationID: o.CorrelationID,
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+	return denied
+}
+
+// queryOPA evaluates the policy at the given OPA data API url with
+// the given input.
+func queryOPA(url string, input *opaInput) (*opaDecision, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+
+	client := gaia.Cfg.HTTPClient(opaTimeout)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy engine returned status %d", resp.StatusCode)
+	}
+
+	// An undefined decision has no result
+	result := struct {
+		Result *opaDecision `json:"result"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Result == nil {
+		return nil, errNoPolicyDecision
+	}
+	return result.Result, nil
+}
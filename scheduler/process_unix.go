@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package scheduler
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpStack lets the go runtime of the given process write
+// the stacks of all goroutines to stderr.
+func dumpStack(proc *os.Process) error {
+	return proc.Signal(syscall.SIGQUIT)
+}
+
+// interruptProcess asks the given process to shut down.
+func interruptProcess(proc *os.Process) error {
+	return proc.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package scheduler
+
+import (
+	"errors"
+	"os"
+)
+
+// errSignalUnsupported is thrown when a process should be
+// signaled on windows.
+var errSignalUnsupported = errors.New("signals are not supported on windows")
+
+// dumpStack is not supported on windows.
+func dumpStack(proc *os.Process) error {
+	return errSignalUnsupported
+}
+
+// interruptProcess is not supported on windows.
+func interruptProcess(proc *os.Process) error {
+	return errSignalUnsupported
+}
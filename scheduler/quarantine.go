@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// eventQuarantined is the webhook event which is sent when a pipeline
+// has been quarantined. The run of the event is the last failed run.
+const eventQuarantined gaia.PipelineRunStatus = "quarantined"
+
+// checkQuarantine pauses the schedules of the given pipeline if the
+// given failed scheduled run is one too many in a row. The owners are
+// notified by the webhooks of the pipeline.
+func (s *Scheduler) checkQuarantine(p *gaia.Pipeline, r *gaia.PipelineRun) {
+	limit := gaia.GetSettings().QuarantineAfter
+	if limit <= 0 || p.Quarantine != nil {
+		return
+	}
+	runs, err := s.storeService.PipelineGetAllRuns(r.PipelineID)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get pipeline runs", "error", err.Error(), "pipeline", r.PipelineID)
+		return
+	}
+	failures := consecutiveFailures(runs)
+	if failures < limit {
+		return
+	}
+
+	// The pipeline might have been edited or resumed during the run
+	quarantined := false
+	updated, err := s.updatePipeline(p.ID, func(p *gaia.Pipeline) {
+		if p.Quarantine == nil {
+			p.Quarantine = &gaia.Quarantine{Since: time.Now(), Failures: failures, RunID: r.ID}
+			quarantined = true
+		}
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot quarantine pipeline", "error", err.Error(), "pipeline", p.Name)
+		return
+	} else if updated == nil || !quarantined {
+		return
+	}
+	p = updated
+	gaia.Cfg.Logger.Warn("quarantined pipeline after failed scheduled runs", "pipeline", p.Name, "failures", failures)
+
+	err = s.storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         auditActorScheduler,
+		Action:        gaia.AuditPipelineQuarantine,
+		Target:        p.Name,
+		Message:       fmt.Sprintf("%d scheduled runs failed in a row, last run %d", failures, r.ID),
+		CorrelationID: r.CorrelationID,
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+	s.fireWebhooks(p, r, eventQuarantined)
+}
+
+// consecutiveFailures returns the number of the latest finished
+// scheduled runs which failed in a row. Cancelled runs are skipped.
+func consecutiveFailures(runs []gaia.PipelineRun) int {
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID > runs[j].ID
+	})
+
+	failures := 0
+	for _, r := range runs {
+		if !r.Scheduled || r.Shadow || r.Status == gaia.RunCancelled {
+			continue
+		}
+		if r.Status != gaia.RunFailed {
+			break
+		}
+		failures++
+	}
+	return failures
+}
@@ -0,0 +1,260 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// QueueEntry represents a run which waits for a worker.
+type QueueEntry struct {
+	PipelineID   int                    `json:"pipelineid"`
+	RunID        int                    `json:"runid"`
+	Status       gaia.PipelineRunStatus `json:"status"`
+	ScheduleDate time.Time              `json:"scheduledate"`
+
+	// Waiting is the time the run waits already in seconds.
+	Waiting float64 `json:"waiting"`
+
+	// Position is the position of the run in the queue starting at
+	// one. Held and blocked runs have no position.
+	Position int `json:"position,omitempty"`
+
+	// Reason tells why the run has not been started yet.
+	Reason string `json:"reason"`
+
+	// EstimatedStart is derived from the baseline durations of the
+	// running and the queued runs. It is zero if it is unknown.
+	EstimatedStart time.Time `json:"estimatedstart,omitempty"`
+}
+
+// QueueWaitTimes represents the distribution of the times the runs of
+// one or all pipelines waited for a worker. Times are given in seconds.
+type QueueWaitTimes struct {
+	PipelineID int       `json:"pipelineid,omitempty"`
+	Since      time.Time `json:"since"`
+	Runs       int       `json:"runs"`
+	Mean       float64   `json:"mean"`
+	P50        float64   `json:"p50"`
+	P90        float64   `json:"p90"`
+	P99        float64   `json:"p99"`
+	Max        float64   `json:"max"`
+}
+
+// Queue returns all runs which wait for a worker in the order they
+// will most likely be started. Runs which are blocked by a calendar, a
+// mutex group, a gate or the smoke run of a rebuilt binary are given
+// with their blocker.
+func (s *Scheduler) Queue() ([]QueueEntry, error) {
+	all, err := s.storeService.PipelineGetRunsByStatus(gaia.RunScheduled, gaia.RunNotScheduled, gaia.RunRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	// Running runs only wait if they wait on a gate
+	runs := []gaia.PipelineRun{}
+	for _, r := range all {
+		if r.Status != gaia.RunRunning || r.Gate != "" {
+			runs = append(runs, r)
+		}
+	}
+	calendars, err := s.storeService.CalendarGetAll()
+	if err != nil {
+		return nil, err
+	}
+	pipelines, err := s.storeService.PipelineGetAll()
+	if err != nil {
+		return nil, err
+	}
+	pipelineCalendars := calendarsByPipeline(pipelines)
+	smoking := map[int]bool{}
+	for _, p := range pipelines {
+		smoking[p.ID] = p.Rollback != nil
+	}
+	mutexReasons := s.heldMutexReasons()
+
+	// Dispatched runs are taken first. Boosted runs jump the queue.
+	sort.SliceStable(runs, func(i, j int) bool {
+		a, b := &runs[i], &runs[j]
+		if a.Status != b.Status {
+			return a.Status == gaia.RunScheduled
+		}
+		if a.Boosted != b.Boosted {
+			return a.Boosted
+		}
+		if a.Status == gaia.RunScheduled {
+			return a.DispatchDate.Before(b.DispatchDate)
+		}
+		return false
+	})
+
+	now := time.Now()
+	durations := map[int]float64{}
+	duration := func(pipelineID int) float64 {
+		if d, ok := durations[pipelineID]; ok {
+			return d
+		}
+		metrics, err := s.storeService.MetricsGet(pipelineID, now.AddDate(0, 0, -baselineDays))
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot get run metrics", "error", err.Error(), "pipeline", pipelineID)
+		}
+		mean, _, _ := baseline(metrics)
+		durations[pipelineID] = mean
+		return mean
+	}
+
+	// The time every worker becomes free. Unknown durations make all
+	// following estimates unknown.
+	free := map[int]time.Time{}
+	for _, w := range s.Workers() {
+		free[w.ID] = now
+		if w.Status == WorkerBusy {
+			free[w.ID] = estimateFinish(w.Since, duration(w.PipelineID), now)
+		}
+	}
+
+	entries := []QueueEntry{}
+	position := 0
+	for i := range runs {
+		r := &runs[i]
+		e := QueueEntry{
+			PipelineID:   r.PipelineID,
+			RunID:        r.ID,
+			Status:       r.Status,
+			ScheduleDate: r.ScheduleDate,
+			Waiting:      now.Sub(r.ScheduleDate).Seconds(),
+		}
+
+		var f *freeze
+		if r.Status == gaia.RunNotScheduled {
+			f = heldBy(r, calendars, pipelineCalendars, now)
+		}
+
+		switch {
+		case f != nil:
+			e.Reason = "held by maintenance calendar: " + f.Error()
+		case r.Status == gaia.RunRunning:
+			e.Reason = "waiting for gate " + r.Gate
+		case mutexReasons[r.HeldBy]:
+			e.Reason = "waiting for mutex group: " + r.HeldBy
+		case r.Status == gaia.RunNotScheduled && smoking[r.PipelineID] && !r.Smoke:
+			e.Reason = "waiting for the smoke run of the rebuilt binary"
+		default:
+			position++
+			e.Position = position
+			e.Reason = "waiting for a free worker"
+			if r.Canary {
+				e.Reason = "waiting for a free canary worker"
+			}
+			e.EstimatedStart = assignWorker(free, r, duration(r.PipelineID))
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// estimateFinish returns the time a run which started at the given
+// time most likely finishes. Runs which took longer than the given
+// baseline duration are expected to finish now.
+func estimateFinish(started time.Time, seconds float64, now time.Time) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	finish := started.Add(time.Duration(seconds * float64(time.Second)))
+	if finish.Before(now) {
+		return now
+	}
+	return finish
+}
+
+// assignWorker assigns the given run to the worker which becomes free
+// first and returns the estimated start of the run. Canary runs are
+// only assigned to canary workers.
+func assignWorker(free map[int]time.Time, r *gaia.PipelineRun, seconds float64) time.Time {
+	id := -1
+	for w, t := range free {
+		if r.Canary && !isCanaryWorker(w) {
+			continue
+		}
+		if id == -1 || earlier(t, free[id]) || (t.Equal(free[id]) && w < id) {
+			id = w
+		}
+	}
+	if id == -1 {
+		return time.Time{}
+	}
+
+	start := free[id]
+	if start.IsZero() || seconds == 0 {
+		free[id] = time.Time{}
+	} else {
+		free[id] = start.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return start
+}
+
+// earlier returns true if a is before b. Zero times are unknown and
+// come last.
+func earlier(a, b time.Time) bool {
+	if a.IsZero() {
+		return false
+	}
+	return b.IsZero() || a.Before(b)
+}
+
+// GetQueueWaitTimes returns the distribution of the queue wait times of
+// the runs of the pipeline with the given id which were started since
+// the given date. A zero id returns the distribution of all pipelines.
+func (s *Scheduler) GetQueueWaitTimes(pipelineID int, since time.Time) (*QueueWaitTimes, error) {
+	var runs []gaia.PipelineRun
+	var err error
+	if pipelineID != 0 {
+		runs, err = s.storeService.PipelineGetAllRuns(pipelineID)
+	} else {
+		runs, err = s.storeService.PipelineGetRunsByStatus(gaia.RunSuccess, gaia.RunFailed, gaia.RunCancelled, gaia.RunRunning)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	waits := []float64{}
+	for _, r := range runs {
+		if r.StartDate.IsZero() || r.ScheduleDate.IsZero() || r.StartDate.Before(since) {
+			continue
+		}
+		waits = append(waits, r.StartDate.Sub(r.ScheduleDate).Seconds())
+	}
+	return queueWaitTimes(pipelineID, since, waits), nil
+}
+
+// queueWaitTimes returns the distribution of the given wait times.
+func queueWaitTimes(pipelineID int, since time.Time, waits []float64) *QueueWaitTimes {
+	q := &QueueWaitTimes{PipelineID: pipelineID, Since: since, Runs: len(waits)}
+	if len(waits) == 0 {
+		return q
+	}
+
+	sort.Float64s(waits)
+	var sum float64
+	for _, w := range waits {
+		sum += w
+	}
+	q.Mean = sum / float64(len(waits))
+	q.P50 = percentile(waits, 50)
+	q.P90 = percentile(waits, 90)
+	q.P99 = percentile(waits, 99)
+	q.Max = waits[len(waits)-1]
+	return q
+}
+
+// percentile returns the given percentile of the given sorted values
+// by the nearest rank.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,266 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// runTokenArgKey is the arg which holds the token a job uses to
+	// call the api on behalf of its run.
+	runTokenArgKey = "gaia_run_token"
+
+	// runReportFileName is the file in the run folder which holds the
+	// outputs and annotations reported by the jobs.
+	runReportFileName = "report.json"
+
+	// jobAnnotationMatcher is the matcher of annotations which have
+	// been reported by jobs.
+	jobAnnotationMatcher = "job"
+
+	// maxJobAnnotations is the max number of annotations the jobs of
+	// a run can report.
+	maxJobAnnotations = 100
+
+	// maxJobAnnotationLength is the max length of the message of an
+	// annotation reported by a job.
+	maxJobAnnotationLength = 4096
+)
+
+var (
+	// errInvalidArtifactName is thrown when an artifact has an empty
+	// or invalid name.
+	errInvalidArtifactName = errors.New("invalid artifact name given")
+
+	// errInvalidOutputKey is thrown when a job reports an output with
+	// an empty key.
+	errInvalidOutputKey = errors.New("output keys must not be empty")
+
+	// errTooManyAnnotations is thrown when the jobs of a run reported
+	// more annotations than allowed.
+	errTooManyAnnotations = errors.New("too many annotations reported for this run")
+
+	// errAnnotationTooLong is thrown when a job reports an annotation
+	// with a message which is too long.
+	errAnnotationTooLong = errors.New("annotation message is too long")
+)
+
+// RunToken identifies the running run a run token has been issued to.
+// Tokens without expiry are valid until they are revoked.
+type RunToken struct {
+	PipelineID int
+	RunID      int
+	Expires    time.Time
+}
+
+// runReport holds the outputs and annotations reported by the jobs
+// of a run until the run is finished.
+type runReport struct {
+	Outputs     map[string]string `json:"outputs,omitempty"`
+	Annotations []gaia.Annotation `json:"annotations,omitempty"`
+}
+
+// RunByToken returns the running run the given run token has been
+// issued to. Returns nil if the token is unknown or expired.
+func (s *Scheduler) RunByToken(token string) *RunToken {
+	s.runTokensLock.Lock()
+	defer s.runTokensLock.Unlock()
+
+	t, ok := s.runTokens[token]
+	if !ok {
+		return nil
+	}
+	if !t.Expires.IsZero() && time.Now().After(t.Expires) {
+		delete(s.runTokens, token)
+		return nil
+	}
+	return &t
+}
+
+// issueRunToken creates the token the jobs of the given run use to call
+// the api on behalf of the run. The token is revoked when the run is
+// finished. It expires earlier only if a max lifetime is configured.
+func (s *Scheduler) issueRunToken(r *gaia.PipelineRun) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	s.runTokensLock.Lock()
+	defer s.runTokensLock.Unlock()
+
+	t := RunToken{
+		PipelineID: r.PipelineID,
+		RunID:      r.ID,
+	}
+	if gaia.Cfg.RunTokenTTL != 0 {
+		t.Expires = time.Now().Add(gaia.Cfg.RunTokenTTL)
+	}
+	s.runTokens[token] = t
+	return token, nil
+}
+
+// revokeRunToken invalidates the given run token.
+func (s *Scheduler) revokeRunToken(token string) {
+	s.runTokensLock.Lock()
+	defer s.runTokensLock.Unlock()
+
+	delete(s.runTokens, token)
+}
+
+// StoreRunArtifact stores the given content as artifact with the given
+// name of the run of the given token. Existing artifacts are replaced.
+func (s *Scheduler) StoreRunArtifact(t *RunToken, name string, content io.Reader) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errInvalidArtifactName
+	}
+	folder := filepath.Join(runFolder(t.PipelineID, t.RunID), gaia.ArtifactsFolderName)
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file so downloads never see partial artifacts
+	tmp, err := ioutil.TempFile(folder, "."+name)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(folder, name))
+}
+
+// RunArtifactPath returns the path of the artifact with the given name
+// of the given run.
+func RunArtifactPath(r *gaia.PipelineRun, name string) (string, error) {
+	if !contains(r.Artifacts, name) {
+		return "", os.ErrNotExist
+	}
+	return filepath.Join(runFolder(r.PipelineID, r.ID), gaia.ArtifactsFolderName, name), nil
+}
+
+// SetRunOutputs sets the given outputs of the run of the given token.
+// Outputs which have been set before are overwritten.
+func (s *Scheduler) SetRunOutputs(t *RunToken, outputs map[string]string) error {
+	for key := range outputs {
+		if strings.TrimSpace(key) == "" {
+			return errInvalidOutputKey
+		}
+	}
+	return s.updateRunReport(t, func(report *runReport) error {
+		if report.Outputs == nil {
+			report.Outputs = map[string]string{}
+		}
+		for key, value := range outputs {
+			report.Outputs[key] = value
+		}
+		return nil
+	})
+}
+
+// AddRunAnnotation adds the given annotation to the run of the given token.
+func (s *Scheduler) AddRunAnnotation(t *RunToken, a gaia.Annotation) error {
+	if a.Severity != SeverityError && a.Severity != SeverityWarning {
+		return errInvalidSeverity
+	}
+	if len(a.Message) > maxJobAnnotationLength {
+		return errAnnotationTooLong
+	}
+	a.Matcher = jobAnnotationMatcher
+	a.Count = 1
+
+	return s.updateRunReport(t, func(report *runReport) error {
+		if len(report.Annotations) >= maxJobAnnotations {
+			return errTooManyAnnotations
+		}
+		report.Annotations = append(report.Annotations, a)
+		return nil
+	})
+}
+
+// updateRunReport applies the given change to the report of the run
+// of the given token. The report is not changed if the change fails.
+func (s *Scheduler) updateRunReport(t *RunToken, change func(*runReport) error) error {
+	s.runTokensLock.Lock()
+	defer s.runTokensLock.Unlock()
+
+	path := filepath.Join(runFolder(t.PipelineID, t.RunID), runReportFileName)
+	report, err := readRunReport(path)
+	if err != nil {
+		return err
+	}
+	if err = change(report); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// collectRunReport adds the outputs, annotations and artifacts which
+// have been reported by the jobs to the given run.
+func (s *Scheduler) collectRunReport(r *gaia.PipelineRun) {
+	folder := runFolder(r.PipelineID, r.ID)
+
+	s.runTokensLock.Lock()
+	report, err := readRunReport(filepath.Join(folder, runReportFileName))
+	s.runTokensLock.Unlock()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot read run report", "error", err.Error(), "run", r.ID)
+	} else {
+		r.Outputs = report.Outputs
+		r.Annotations = append(r.Annotations, report.Annotations...)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(folder, gaia.ArtifactsFolderName))
+	if err != nil {
+		return
+	}
+	r.Artifacts = nil
+	for _, f := range files {
+		if !f.IsDir() && !strings.HasPrefix(f.Name(), ".") {
+			r.Artifacts = append(r.Artifacts, f.Name())
+		}
+	}
+	sort.Strings(r.Artifacts)
+}
+
+// readRunReport reads the run report at the given path. Returns an
+// empty report if nothing has been reported yet.
+func readRunReport(path string) (*runReport, error) {
+	report := &runReport{}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return report, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return report, json.Unmarshal(content, report)
+}
+
+// runFolder returns the folder of the given run in the workspace.
+func runFolder(pipelineID, runID int) string {
+	return filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(pipelineID), strconv.Itoa(runID))
+}
@@ -11,7 +11,11 @@ import (
 
 	"github.com/gaia-pipeline/gaia"
 	"github.com/gaia-pipeline/gaia/plugin"
+	"github.com/gaia-pipeline/gaia/remote"
+	"github.com/gaia-pipeline/gaia/sandbox"
 	"github.com/gaia-pipeline/gaia/store"
+	"github.com/gaia-pipeline/gaia/wasm"
+	hclog "github.com/hashicorp/go-hclog"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -22,6 +26,10 @@ const (
 	// schedulerIntervalSeconds defines the interval the scheduler will look
 	// for new work to schedule. Definition in seconds.
 	schedulerIntervalSeconds = 3
+
+	// statusFlushInterval is the max interval the job results of a
+	// running pipeline are stored.
+	statusFlushInterval = time.Second
 )
 
 var (
@@ -35,17 +43,78 @@ type Scheduler struct {
 	// buffered channel which is used as queue
 	scheduledRuns chan gaia.PipelineRun
 
+	// buffered channel which is used as queue for canary runs
+	scheduledCanaryRuns chan gaia.PipelineRun
+
+	// buffered channel which is used as queue for boosted runs
+	scheduledPriorityRuns chan gaia.PipelineRun
+
+	// boosted holds the runs which have been boosted while they were
+	// already queued. The value is true once one copy has been taken.
+	boosted map[string]bool
+
+	// scheduleLock serializes the dispatching of queued runs.
+	scheduleLock sync.Mutex
+
+	// mutexes holds the holders of all acquired mutex groups by name.
+	mutexes     map[string]*MutexState
+	mutexesLock sync.Mutex
+
 	// storeService is an instance of store.
 	// Use this to talk to the store.
 	storeService *store.Store
+
+	// workers holds the current state of all workers by id.
+	workers     map[int]*WorkerState
+	workersLock sync.RWMutex
+
+	// workerStops holds the stop channels of all running workers by id
+	// and workersStopping the ids of the workers which have been told to
+	// stop but are still finishing their run. Protected by workersLock.
+	workerStops     map[int]chan struct{}
+	workersStopping map[int]bool
+
+	// cancels holds the cancel channels of all started or cancelled runs.
+	cancels     map[string]chan struct{}
+	cancelsLock sync.Mutex
+
+	// childTokens holds the parent runs by the tokens their jobs use
+	// to trigger child pipelines.
+	childTokens     map[string]ParentRun
+	childTokensLock sync.Mutex
+
+	// runTokens holds the runs by the tokens their jobs use to call
+	// the api on behalf of the run. runTokensLock also protects the
+	// reports written with the tokens.
+	runTokens     map[string]RunToken
+	runTokensLock sync.Mutex
+
+	// buffered channel which holds the records of finished runs
+	// until they are exported.
+	exports chan []exportRecord
+
+	// deliveryTrigger wakes up the delivery of queued webhooks.
+	deliveryTrigger chan struct{}
 }
 
 // NewScheduler creates a new instance of Scheduler.
 func NewScheduler(store *store.Store) *Scheduler {
 	// Create new scheduler
 	s := &Scheduler{
-		scheduledRuns: make(chan gaia.PipelineRun, schedulerBufferLimit),
-		storeService:  store,
+		scheduledRuns:         make(chan gaia.PipelineRun, schedulerBufferLimit),
+		scheduledCanaryRuns:   make(chan gaia.PipelineRun, schedulerBufferLimit),
+		scheduledPriorityRuns: make(chan gaia.PipelineRun, schedulerBufferLimit),
+		boosted:               make(map[string]bool),
+		mutexes:               make(map[string]*MutexState),
+		storeService:          store,
+		workers:               make(map[int]*WorkerState),
+		workerStops:           make(map[int]chan struct{}),
+		workersStopping:       make(map[int]bool),
+		cancels:               make(map[string]chan struct{}),
+		childTokens:           make(map[string]ParentRun),
+		runTokens:             make(map[string]RunToken),
+		exports:               make(chan []exportRecord, exportBufferLimit),
+		deliveryTrigger:       make(chan struct{}, 1),
 	}
 
 	return s
@@ -53,23 +122,29 @@ func NewScheduler(store *store.Store) *Scheduler {
 
 // Init initializes the scheduler.
 func (s *Scheduler) Init() error {
-	// Get number of worker
-	w, err := strconv.Atoi(gaia.Cfg.Worker)
-	if err != nil {
-		return err
-	}
-
 	// Setup worker
-	for i := 0; i < w; i++ {
-		go s.work()
+	s.scaleWorkers(gaia.GetSettings().Worker)
+
+	// Stream finished runs to the export sink
+	if exportEnabled() {
+		go s.exportRuns()
 	}
 
+	// Start the runs of the pipeline schedules
+	go s.runSchedules()
+
+	// Deliver the queued webhooks
+	go s.deliverWebhooks()
+
 	// Create a periodic job that fills the scheduler with new pipelines.
 	schedulerJob := time.NewTicker(schedulerIntervalSeconds * time.Second)
 	go func() {
 		for {
 			select {
 			case <-schedulerJob.C:
+				// Apply changed worker settings
+				s.scaleWorkers(gaia.GetSettings().Worker)
+
 				// Do the scheduling
 				s.schedule()
 			}
@@ -81,92 +156,303 @@ func (s *Scheduler) Init() error {
 
 // work takes work from the scheduled run buffer channel
 // and executes the pipeline. Then repeats.
-func (s *Scheduler) work() {
-	// This worker never stops working.
-	for {
+// The given id identifies the worker in the executed runs.
+// The worker stops once the given stop channel has been closed.
+func (s *Scheduler) work(id int, stop <-chan struct{}) {
+	defer s.removeWorker(id)
+
+	for !isCancelled(stop) {
+		s.setWorkerIdle(id)
+
 		// Take one scheduled run, block if there are no scheduled pipelines
-		r := <-s.scheduledRuns
+		r, ok := s.nextRun(id, stop)
+		if !ok {
+			return
+		}
+		s.setWorkerBusy(id, &r)
+
+		// The run might have been cancelled while it was queued
+		cancel := s.runCancel(&r)
+		select {
+		case <-cancel:
+			s.finishPipelineRun(&r, gaia.RunCancelled)
+		default:
+			s.executeRun(id, &r, cancel)
+		}
+		s.releaseRunCancel(&r)
+	}
+}
+
+// executeRun executes the given run on the worker with the given id.
+// The run is cancelled when the given channel is closed.
+func (s *Scheduler) executeRun(id int, r *gaia.PipelineRun, cancel <-chan struct{}) {
+	log := gaia.Cfg.Logger.With("correlationid", r.CorrelationID)
+
+	// Mark the scheduled run as running
+	r.Status = gaia.RunRunning
+	r.StartDate = time.Now()
+	r.Worker = id
 
-		// Mark the scheduled run as running
-		r.Status = gaia.RunRunning
-		r.StartDate = time.Now()
+	// Update entry in store
+	err := s.storeService.PipelinePutRun(r)
+	if err != nil {
+		log.Debug("could not put pipeline run into store during executing work", "error", err.Error())
+		return
+	}
+
+	// Get related pipeline from pipeline run
+	pipeline, err := s.storeService.PipelineGet(r.PipelineID)
+	if err != nil {
+		log.Debug("cannot access pipeline during execution", "error", err.Error())
+		r.Status = gaia.RunFailed
+	} else if pipeline == nil {
+		log.Debug("wanted to execute job for pipeline which does not exist", "run", r)
+		r.Status = gaia.RunFailed
+	} else if r.Shadow && pipeline.ShadowExecPath == "" {
+		log.Debug("shadow binary has been removed before the shadow run", "run", r)
+		r.Status = gaia.RunFailed
+	} else if !pinnedBinariesExist(r) {
+		log.Debug("pinned binary has been removed before the run", "run", r)
+		r.Status = gaia.RunFailed
+	}
 
+	if r.Status == gaia.RunFailed {
 		// Update entry in store
-		err := s.storeService.PipelinePutRun(&r)
+		err = s.storeService.PipelinePutRun(r)
 		if err != nil {
-			gaia.Cfg.Logger.Debug("could not put pipeline run into store during executing work", "error", err.Error())
-			continue
+			log.Debug("could not put pipeline run into store during executing work", "error", err.Error())
 		}
+		return
+	}
 
-		// Get related pipeline from pipeline run
-		pipeline, err := s.storeService.PipelineGet(r.PipelineID)
-		if err != nil {
-			gaia.Cfg.Logger.Debug("cannot access pipeline during execution", "error", err.Error())
-			r.Status = gaia.RunFailed
-		} else if pipeline == nil {
-			gaia.Cfg.Logger.Debug("wanted to execute job for pipeline which does not exist", "run", r)
-			r.Status = gaia.RunFailed
+	// Put the run back into the queue if another run holds a mutex group.
+	// The mutex groups are acquired before the gates are waited on so a
+	// queued run does not wait on them again.
+	variables := s.runVariables(r, pipeline)
+	mutexGroups := runMutexGroups(pipeline, variables)
+	if held := s.acquireMutexGroups(mutexGroups, r); held != nil {
+		if isCancelled(cancel) {
+			s.finishPipelineRun(r, gaia.RunCancelled)
+			return
 		}
-
-		if r.Status == gaia.RunFailed {
-			// Update entry in store
-			err = s.storeService.PipelinePutRun(&r)
-			if err != nil {
-				gaia.Cfg.Logger.Debug("could not put pipeline run into store during executing work", "error", err.Error())
+		log.Debug("mutex group is held by another run", "run", r.ID, "pipeline", pipeline.Name)
+		r.HeldBy = heldByMutex(held)
+		r.Status = gaia.RunNotScheduled
+		r.StartDate = time.Time{}
+		r.Worker = 0
+		if err = s.storeService.PipelinePutRun(r); err != nil {
+			log.Debug("could not put pipeline run into store during executing work", "error", err.Error())
+		}
+		return
+	}
+	defer s.releaseMutexGroups(mutexGroups)
+	r.HeldBy = ""
+
+	// Wait until the gates of the pipeline are open
+	if len(pipeline.Gates) > 0 {
+		err = waitForGates(pipeline.Gates, cancel, func(g *gaia.Gate) error {
+			r.Gate = g.Name
+			if g.Type == gaia.GateServiceNow {
+				if err := s.openChangeRequest(g, pipeline, r); err != nil {
+					return err
+				}
 			}
-			continue
+			if err := s.storeService.PipelinePutRun(r); err != nil {
+				log.Debug("could not put pipeline run into store during executing work", "error", err.Error())
+			}
+			return nil
+		})
+		switch err {
+		case nil:
+			r.Gate = ""
+		case errGateCancelled:
+			s.finishPipelineRun(r, gaia.RunCancelled)
+			return
+		default:
+			log.Warn("gate did not open", "error", err.Error(), "pipeline", pipeline.Name, "run", r.ID)
+			s.finishPipelineRun(r, gaia.RunFailed)
+			return
 		}
+	}
 
-		// Get all jobs
-		r.Jobs, err = s.getPipelineJobs(pipeline)
-		if err != nil {
-			gaia.Cfg.Logger.Error("cannot get pipeline jobs before execution", "error", err.Error())
+	// Shadow runs execute the rebuilt binary
+	r.Platform = hostPlatform()
+	if r.Shadow {
+		pipeline.ExecPath = pipeline.ShadowExecPath
+	}
+	pinBinaries(pipeline, r)
 
-			// Update store
-			r.Status = gaia.RunFailed
-			s.storeService.PipelinePutRun(&r)
-			continue
-		}
+	// Remember what is executed so the run can be reproduced
+	r.Environment = gaia.Cfg.Environment
+	if r.Checksum, err = getSHA256Sum(pipeline.ExecPath); err != nil {
+		log.Debug("cannot calculate checksum of pipeline binary", "error", err.Error(), "pipeline", pipeline.Name)
+	}
 
-		// Check if this pipeline has jobs declared
-		if len(r.Jobs) == 0 {
-			// Finish pipeline run
-			s.finishPipelineRun(&r, gaia.RunSuccess)
+	// Get all jobs
+	r.Jobs, err = s.getPipelineJobs(pipeline)
+	if err != nil {
+		log.Error("cannot get pipeline jobs before execution", "error", err.Error())
+
+		// Update store
+		r.Status = gaia.RunFailed
+		s.storeService.PipelinePutRun(r)
+		return
+	}
+	r.Jobs = filterJobs(r.Jobs, r.OnlyJobs)
+
+	// Jobs of the SSH host execute the binary built for its platform.
+	// The run fails if the pipeline has not been built for it.
+	for i := range r.Jobs {
+		if !runsOnSSHHost(pipeline, &r.Jobs[i]) {
 			continue
 		}
-
-		// Create logs folder for this run
-		path := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(r.PipelineID), strconv.Itoa(r.ID), gaia.LogsFolderName)
-		err = os.MkdirAll(path, 0700)
+		binary, err := sshBinary(pipeline)
 		if err != nil {
-			gaia.Cfg.Logger.Error("cannot create pipeline run folder", "error", err.Error(), "path", path)
+			log.Warn("cannot execute jobs on ssh host", "error", err.Error(), "pipeline", pipeline.Name, "run", r.ID)
+			s.finishPipelineRun(r, gaia.RunFailed)
+			return
+		}
+		r.SSHPlatform = pipeline.SSH.Platform
+		if r.SSHChecksum, err = getSHA256Sum(binary); err != nil {
+			log.Debug("cannot calculate checksum of pipeline binary", "error", err.Error(), "pipeline", pipeline.Name)
 		}
+		break
+	}
+
+	// Check if this pipeline has jobs declared
+	if len(r.Jobs) == 0 {
+		// Finish pipeline run
+		s.finishPipelineRun(r, gaia.RunSuccess)
+		return
+	}
+
+	// Create logs folder for this run
+	path := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(r.PipelineID), strconv.Itoa(r.ID), gaia.LogsFolderName)
+	err = os.MkdirAll(path, 0700)
+	if err != nil {
+		log.Error("cannot create pipeline run folder", "error", err.Error(), "path", path)
+	}
+
+	// Resolve all secrets which should be passed to the jobs
+	args := s.resolveSecrets(r)
+	if r.Shadow {
+		args[shadowArgKey] = "true"
+	}
+	addInputArgs(r, args)
+	if r.CorrelationID != "" {
+		args[correlationIDArgKey] = r.CorrelationID
+	}
+	addVariableArgs(variables, args)
 
-		// Schedule jobs and execute them.
-		// Also update the run in the store.
-		s.scheduleJobsByPriority(&r, pipeline)
+	// Jobs can trigger child pipelines while the run is executed
+	token, err := s.issueChildToken(r)
+	if err != nil {
+		log.Error("cannot issue child pipeline token", "error", err.Error())
+	} else {
+		defer s.revokeChildToken(token)
+		args[childTokenArgKey] = token
+		args[apiURLArgKey] = jobAPIURL()
 	}
+
+	// Jobs can report artifacts, outputs and annotations of the run
+	runToken, err := s.issueRunToken(r)
+	if err != nil {
+		log.Error("cannot issue run token", "error", err.Error())
+	} else {
+		defer s.revokeRunToken(runToken)
+		args[runTokenArgKey] = runToken
+		args[apiURLArgKey] = jobAPIURL()
+	}
+
+	// Capture diagnostics for debug runs
+	diag, closeDiag := newRunLogger(r)
+	logRunDiagnostics(diag, r, pipeline, args)
+
+	// Schedule jobs and execute them.
+	// Also update the run in the store.
+	s.scheduleJobsByPriority(r, pipeline, args, diag, cancel)
+	closeDiag()
 }
 
 // schedule looks in the store for new work to do and schedules it.
 func (s *Scheduler) schedule() {
-	// Do we have space left in our buffer?
-	if len(s.scheduledRuns) >= schedulerBufferLimit {
+	s.scheduleLock.Lock()
+	defer s.scheduleLock.Unlock()
+
+	// Do we have space left in our buffers?
+	space := schedulerBufferLimit - len(s.scheduledRuns)
+	if canarySpace := schedulerBufferLimit - len(s.scheduledCanaryRuns); canarySpace < space {
+		space = canarySpace
+	}
+	if space <= 0 {
 		// No space left. Exit.
 		return
 	}
 
-	// Get scheduled pipelines but limit the returning number of elements.
-	scheduled, err := s.storeService.PipelineGetScheduled(schedulerBufferLimit)
+	// Get scheduled pipelines. Runs of frozen pipelines are held back
+	// and do not count against the limit.
+	runs, err := s.storeService.PipelineGetRunsByStatus(gaia.RunNotScheduled)
 	if err != nil {
 		gaia.Cfg.Logger.Debug("cannot get scheduled pipelines", "error", err.Error())
 		return
 	}
+	calendars, err := s.storeService.CalendarGetAll()
+	if err != nil {
+		gaia.Cfg.Logger.Debug("cannot get calendars", "error", err.Error())
+		return
+	}
+
+	// Rebuilt binaries only execute their smoke run until it succeeded
+	pipelines, err := s.storeService.PipelineGetAll()
+	if err != nil {
+		gaia.Cfg.Logger.Debug("cannot get pipelines", "error", err.Error())
+		return
+	}
+	smoking := map[int]bool{}
+	for _, p := range pipelines {
+		smoking[p.ID] = p.Rollback != nil
+	}
+	pipelineCalendars := calendarsByPipeline(pipelines)
+	mutexReasons := s.heldMutexReasons()
+
+	now := time.Now()
+	scheduled := []*gaia.PipelineRun{}
+	for id := range runs {
+		if len(scheduled) >= space {
+			break
+		}
+		if smoking[runs[id].PipelineID] && !runs[id].Smoke {
+			continue
+		}
+
+		// Held runs carry the freeze which holds them
+		if f := heldBy(&runs[id], calendars, pipelineCalendars, now); f != nil {
+			if runs[id].HeldBy != f.Error() {
+				runs[id].HeldBy = f.Error()
+				if err = s.storeService.PipelinePutRun(&runs[id]); err != nil {
+					gaia.Cfg.Logger.Debug("could not put pipeline run into store", "error", err.Error())
+				}
+			}
+			continue
+		}
+		scheduled = append(scheduled, &runs[id])
+	}
 
 	// Iterate scheduled runs
 	for id := range scheduled {
 		// push scheduled run into our channel
-		s.scheduledRuns <- (*scheduled[id])
+		scheduled[id].DispatchDate = time.Now()
+
+		// Runs which are still held by a mutex group are marked until
+		// their worker acquires it. The freeze which held the run is over.
+		if !mutexReasons[scheduled[id].HeldBy] {
+			scheduled[id].HeldBy = ""
+		}
+		if scheduled[id].Canary {
+			s.scheduledCanaryRuns <- (*scheduled[id])
+		} else {
+			s.scheduledRuns <- (*scheduled[id])
+		}
 
 		// Mark them as scheduled
 		scheduled[id].Status = gaia.RunScheduled
@@ -179,10 +465,91 @@ func (s *Scheduler) schedule() {
 	}
 }
 
+// ScheduleOptions holds the options for a single pipeline run.
+type ScheduleOptions struct {
+	// Secrets are the vault keys which should be passed to the jobs.
+	// Only secrets which have been granted to the pipeline will be passed.
+	Secrets []string
+
+	// Canary runs are only executed by canary workers. Until a canary run
+	// succeeded, no normal runs of the same pipeline version can be scheduled.
+	Canary bool
+
+	// Debug raises the log level of the pipeline and captures extra
+	// diagnostics for this run.
+	Debug bool
+
+	// Jobs are the ids of the jobs which should be executed. All jobs
+	// are executed if empty. With Dependencies, all jobs with a lower
+	// priority than the selected jobs are executed too.
+	Jobs         []uint32
+	Dependencies bool
+
+	// RerunOf is the id of the run which is re-run.
+	RerunOf int
+
+	// Inputs are placed in the workspace of the run. Their paths are
+	// passed to the jobs as gaia_input_<name> args.
+	Inputs []RunInput
+
+	// Params are passed to the jobs as args.
+	Params map[string]string
+
+	// Parent is the run which triggered this run as child pipeline.
+	Parent *ParentRun
+
+	// User is the name of the user who started the run. It is empty
+	// for triggered runs.
+	User string
+
+	// Scheduled runs have been started by a schedule of the pipeline.
+	Scheduled bool
+
+	// CorrelationID is the id of the request which started the run.
+	CorrelationID string
+
+	// Smoke runs verify a rebuilt binary. The binary is rolled back
+	// if they do not succeed.
+	Smoke bool
+	// PinnedBinary and PinnedSSHBinary are executed instead of the
+	// current binaries of the pipeline. Reruns execute the binaries
+	// of the original run.
+	PinnedBinary    string
+	PinnedSSHBinary string
+}
+
 // SchedulePipeline schedules a pipeline. We create a new schedule object
 // and save it in our store. The scheduler will later pick up this schedule object
 // and will continue the work.
-func (s *Scheduler) SchedulePipeline(p *gaia.Pipeline) (*gaia.PipelineRun, error) {
+func (s *Scheduler) SchedulePipeline(p *gaia.Pipeline, o ScheduleOptions) (*gaia.PipelineRun, error) {
+	// Runs are rejected or held during freeze periods
+	f, err := s.activeFreeze(p, time.Now())
+	if err != nil {
+		return nil, err
+	} else if f != nil && f.policy == gaia.CalendarReject {
+		return nil, f
+	}
+
+	inputs, err := validateInputs(o.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateParams(o.Params); err != nil {
+		return nil, err
+	}
+	if err = checkContract(p, contractParams, o.Params); err != nil {
+		return nil, err
+	}
+
+	// Custom guardrails of the policy engine
+	if err = s.authorizeRun(p, &o, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.prepareCanary(p, o.Canary); err != nil {
+		return nil, err
+	}
+
 	// Get highest public id used for this pipeline
 	highestID, err := s.storeService.PipelineGetRunHighestID(p)
 	if err != nil {
@@ -200,59 +567,184 @@ func (s *Scheduler) SchedulePipeline(p *gaia.Pipeline) (*gaia.PipelineRun, error
 		return nil, err
 	}
 
+	// Only execute the selected jobs
+	onlyJobs, err := selectJobs(jobs, o.Jobs, o.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+	jobs = filterJobs(jobs, onlyJobs)
+
 	// Create new not scheduled pipeline run
 	run := gaia.PipelineRun{
-		UniqueID:     uuid.Must(uuid.NewV4(), nil).String(),
-		ID:           highestID,
-		PipelineID:   p.ID,
-		ScheduleDate: time.Now(),
-		Jobs:         jobs,
-		Status:       gaia.RunNotScheduled,
+		UniqueID:      uuid.Must(uuid.NewV4(), nil).String(),
+		ID:            highestID,
+		PipelineID:    p.ID,
+		ScheduleDate:  time.Now(),
+		Jobs:          jobs,
+		Status:        gaia.RunNotScheduled,
+		Secrets:       o.Secrets,
+		Canary:        o.Canary,
+		Debug:         o.Debug,
+		OnlyJobs:      onlyJobs,
+		RerunOf:       o.RerunOf,
+		Params:        o.Params,
+		StartedBy:     o.User,
+		Scheduled:     o.Scheduled,
+		Smoke:         o.Smoke,
+		Commit:        p.Commit,
+		CorrelationID: o.CorrelationID,
+
+		PinnedBinary:    o.PinnedBinary,
+		PinnedSSHBinary: o.PinnedSSHBinary,
+	}
+	if o.Parent != nil {
+		run.ParentPipelineID = o.Parent.PipelineID
+		run.ParentRunID = o.Parent.RunID
+		run.Depth = o.Parent.Depth + 1
+	}
+	if f != nil {
+		run.HeldBy = f.Error()
+	}
+
+	// Place input files in the workspace
+	if len(inputs) > 0 {
+		run.Inputs = inputs
+		if err = storeInputs(&run, o.Inputs); err != nil {
+			gaia.Cfg.Logger.Error("cannot store input files", "error", err.Error(), "pipeline", p.Name)
+			return nil, err
+		}
 	}
 
 	// Put run into store
-	return &run, s.storeService.PipelinePutRun(&run)
+	if err = s.storeService.PipelinePutRun(&run); err != nil {
+		return nil, err
+	}
+
+	// Execute a pending shadow build alongside
+	if !o.Canary {
+		if err = s.scheduleShadowRun(&run); err != nil {
+			gaia.Cfg.Logger.Error("cannot schedule shadow run", "error", err.Error(), "pipeline", p.Name)
+		}
+	}
+
+	return &run, nil
 }
 
 // executeJob executes a single job.
+// Diagnostics of debug runs are written to the given diag logger.
 // This method is blocking.
-func executeJob(job *gaia.Job, p *gaia.Pipeline, logPath string, wg *sync.WaitGroup, triggerSave chan bool) {
+// Jobs with an SSH key are executed on the SSH host of the pipeline.
+func executeJob(job *gaia.Job, p *gaia.Pipeline, args map[string]string, logPath string, sshKey []byte, diag hclog.Logger, cancel <-chan struct{}, wg *sync.WaitGroup, triggerSave chan bool) {
 	defer wg.Done()
+	log := gaia.Cfg.Logger.With("correlationid", args[correlationIDArgKey])
 	defer func() {
 		triggerSave <- true
 	}()
 
 	// Set Job to running
 	job.Status = gaia.JobRunning
+	job.StartDate = time.Now()
+	diag.Debug("job started", "job", job.Title)
+	defer func() {
+		job.FinishDate = time.Now()
+		diag.Debug("job finished", "job", job.Title, "status", job.Status, "duration", job.FinishDate.Sub(job.StartDate).String())
+	}()
 
 	// Create the start command for the pipeline
 	c := createPipelineCmd(p)
+	if sshKey != nil {
+		c = createRemoteCmd(p, sshKey)
+	}
 	if c == nil {
-		gaia.Cfg.Logger.Debug("cannot execute pipeline job", "error", errCreateCMDForPipeline.Error(), "job", job)
+		log.Debug("cannot execute pipeline job", "error", errCreateCMDForPipeline.Error(), "job", job)
 		job.Status = gaia.JobFailed
 		return
 	}
 
+	// Files passed to the pipeline process are not needed once it runs
+	for _, f := range c.ExtraFiles {
+		defer f.Close()
+	}
+
+	// Raise the log level of the pipeline for debug runs
+	var pluginLogger hclog.Logger
+	if diag.IsDebug() {
+		setDebugEnv(c)
+		pluginLogger = diag.Named("plugin")
+	}
+
 	// Create new plugin instance
-	pC, err := plugin.NewPlugin(c, &logPath)
+	pC, err := plugin.NewPlugin(c, &logPath, pluginLogger)
 	if err != nil {
-		gaia.Cfg.Logger.Error("cannot initiate plugin before job execution", "error", err.Error())
+		log.Error("cannot initiate plugin before job execution", "error", err.Error())
 		return
 	}
 
 	// Connect to plugin(pipeline)
 	if err := pC.Connect(); err != nil {
-		gaia.Cfg.Logger.Debug("cannot connect to pipeline", "error", err.Error(), "pipeline", p)
+		log.Debug("cannot connect to pipeline", "error", err.Error(), "pipeline", p)
 		job.Status = gaia.JobFailed
 		return
 	}
 	defer pC.Close()
+	job.ConnectedDate = time.Now()
+
+	// Sample the resource usage of the pipeline process.
+	// The telemetry folder is next to the logs folder.
+	if c.Process != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		telemetryPath := filepath.Join(filepath.Dir(filepath.Dir(logPath)), gaia.TelemetryFolderName, filepath.Base(logPath)+".json")
+		go sampleJobUsage(c.Process.Pid, telemetryPath, stop)
+	}
+
+	// Ask the pipeline process to stop if the run is cancelled
+	if c.Process != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-stop:
+			case <-cancel:
+				grace := gaia.GetSettings().JobCancelGrace
+				diag.Debug("job cancelled", "job", job.Title, "grace", grace.String())
+				terminateProcess(c.Process, grace, stop)
+			}
+		}()
+	}
+
+	// Kill the pipeline process if the fault injection asks for it
+	if c.Process != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go injectProcessKill(c.Process, stop)
+	}
+
+	// Watch the heartbeat of the pipeline process
+	hung := make(chan struct{})
+	if timeout := gaia.GetSettings().JobHeartbeat; c.Process != nil && timeout > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		hung = watchHeartbeat(faultyHeartbeater{pC}, heartbeatInterval, timeout, stop, func() {
+			log.Warn("job stopped heartbeating", "job", job.Title, "pipeline", p.Name)
+			diag.Warn("job stopped heartbeating", "job", job.Title, "timeout", timeout.String())
+			killHungProcess(c.Process)
+		})
+	}
 
 	// Execute job
-	if err := pC.Execute(job); err != nil {
+	if err := pC.Execute(job, args); err != nil {
 		// TODO: Show it to user
-		gaia.Cfg.Logger.Debug("error during job execution", "error", err.Error(), "job", job)
+		log.Debug("error during job execution", "error", err.Error(), "job", job)
 		job.Status = gaia.JobFailed
+		select {
+		case <-hung:
+			job.Status = gaia.JobHung
+		case <-cancel:
+			job.Status = gaia.JobCancelled
+		default:
+		}
+		return
 	}
 
 	// If we are here, the job execution was ok
@@ -262,7 +754,13 @@ func executeJob(job *gaia.Job, p *gaia.Pipeline, logPath string, wg *sync.WaitGr
 // scheduleJobsByPriority schedules the given jobs by their respective
 // priority. This method is designed to be recursive and blocking.
 // If jobs have the same priority, they will be executed in parallel.
-func (s *Scheduler) scheduleJobsByPriority(r *gaia.PipelineRun, p *gaia.Pipeline) {
+func (s *Scheduler) scheduleJobsByPriority(r *gaia.PipelineRun, p *gaia.Pipeline, args map[string]string, diag hclog.Logger, cancel <-chan struct{}) {
+	// Do not start new jobs if the run has been cancelled
+	if isCancelled(cancel) {
+		s.finishPipelineRun(r, gaia.RunCancelled)
+		return
+	}
+
 	// Do a prescheduling and set it to the first waiting job
 	var lowestPrio int64
 	for _, job := range r.Jobs {
@@ -285,29 +783,46 @@ func (s *Scheduler) scheduleJobsByPriority(r *gaia.PipelineRun, p *gaia.Pipeline
 	triggerSave := make(chan bool)
 	for id, job := range r.Jobs {
 		if job.Priority == lowestPrio && job.Status == gaia.JobWaitingExec {
+			// Jobs of the SSH host connect with the key from the vault
+			key, err := s.sshKey(p, &job)
+			if err != nil {
+				gaia.Cfg.Logger.Error("cannot get ssh key of pipeline", "error", err.Error(), "pipeline", p.Name, "job", job.Title)
+				r.Jobs[id].Status = gaia.JobFailed
+				continue
+			}
+
 			// Increase wait group by one
 			wg.Add(1)
 
 			// Execute this job in a separate goroutine
 			path := filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(r.PipelineID), strconv.Itoa(r.ID), gaia.LogsFolderName)
 			path = filepath.Join(path, strconv.FormatUint(uint64(job.ID), 10))
-			go executeJob(&r.Jobs[id], p, path, &wg, triggerSave)
+			go executeJob(&r.Jobs[id], p, args, path, key, diag, cancel, &wg, triggerSave)
 		}
 	}
 
 	// Create channel for storing job run results and spawn results routine
-	go s.getJobResultsAndStore(triggerSave, r)
+	flushed := make(chan struct{})
+	go s.getJobResultsAndStore(triggerSave, r, flushed)
 
-	// Wait until all jobs have been finished and close results channel
+	// Wait until all jobs have been finished and close results channel.
+	// The pending results must be stored before the run continues.
 	wg.Wait()
 	close(triggerSave)
+	<-flushed
+
+	// Interrupted jobs have not failed
+	if isCancelled(cancel) {
+		s.finishPipelineRun(r, gaia.RunCancelled)
+		return
+	}
 
 	// Check if a job has been failed. If so, stop execution.
 	// We also check if all jobs has been executed.
 	var notExecJob bool
 	for _, job := range r.Jobs {
 		switch job.Status {
-		case gaia.JobFailed:
+		case gaia.JobFailed, gaia.JobHung:
 			s.finishPipelineRun(r, gaia.RunFailed)
 			return
 		case gaia.JobWaitingExec:
@@ -322,14 +837,36 @@ func (s *Scheduler) scheduleJobsByPriority(r *gaia.PipelineRun, p *gaia.Pipeline
 	}
 
 	// Run scheduleJobsByPriority again until all jobs have been executed
-	s.scheduleJobsByPriority(r, p)
+	s.scheduleJobsByPriority(r, p, args, diag, cancel)
 }
 
-// getJobResultsAndStore
-func (s *Scheduler) getJobResultsAndStore(triggerSave chan bool, r *gaia.PipelineRun) {
-	for range triggerSave {
-		// Store update
-		s.storeService.PipelinePutRun(r)
+// getJobResultsAndStore stores the given run when triggered. Writes are
+// batched and happen at most once per flush interval. The last changes are
+// stored when triggerSave is closed. Afterwards flushed is closed.
+func (s *Scheduler) getJobResultsAndStore(triggerSave chan bool, r *gaia.PipelineRun, flushed chan struct{}) {
+	defer close(flushed)
+
+	ticker := time.NewTicker(statusFlushInterval)
+	defer ticker.Stop()
+
+	dirty := false
+	for {
+		select {
+		case _, ok := <-triggerSave:
+			if !ok {
+				if dirty {
+					s.storeService.PipelinePutRun(r)
+				}
+				return
+			}
+			dirty = true
+		case <-ticker.C:
+			if dirty {
+				// Store update
+				s.storeService.PipelinePutRun(r)
+				dirty = false
+			}
+		}
 	}
 }
 
@@ -343,7 +880,7 @@ func (s *Scheduler) getPipelineJobs(p *gaia.Pipeline) ([]gaia.Job, error) {
 	}
 
 	// Create new plugin instance
-	pC, err := plugin.NewPlugin(c, nil)
+	pC, err := plugin.NewPlugin(c, nil, nil)
 	if err != nil {
 		gaia.Cfg.Logger.Error("cannot initiate plugin", "error", err.Error())
 		return nil, err
@@ -382,13 +919,44 @@ func createPipelineCmd(p *gaia.Pipeline) *exec.Cmd {
 	switch p.Type {
 	case gaia.PTypeGolang:
 		c.Path = p.ExecPath
+	case gaia.PTypeWASM:
+		// The runtime process is sandboxed like any other pipeline
+		c.Path = p.ExecPath
+		if err := wasm.Wrap(c, p.WASMCapabilities); err != nil {
+			gaia.Cfg.Logger.Error("cannot start wasm runtime for pipeline", "error", err.Error(), "pipeline", p.Name)
+			return nil
+		}
 	default:
-		c = nil
+		return nil
+	}
+
+	// Restrict privileges of the pipeline process
+	if p.Sandbox.Enabled() {
+		if err := sandbox.Wrap(c, &p.Sandbox); err != nil {
+			gaia.Cfg.Logger.Error("cannot apply sandbox to pipeline", "error", err.Error(), "pipeline", p.Name)
+			return nil
+		}
 	}
 
 	return c
 }
 
+// createRemoteCmd creates the execute command which runs the pipeline
+// binary built for the platform of the SSH host on the host.
+func createRemoteCmd(p *gaia.Pipeline, key []byte) *exec.Cmd {
+	binary, err := sshBinary(p)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot execute pipeline on ssh host", "error", err.Error(), "pipeline", p.Name)
+		return nil
+	}
+	c := &exec.Cmd{Path: binary}
+	if err := remote.Wrap(c, p.SSH, key); err != nil {
+		gaia.Cfg.Logger.Error("cannot execute pipeline on ssh host", "error", err.Error(), "pipeline", p.Name)
+		return nil
+	}
+	return c
+}
+
 // finishPipelineRun finishes the pipeline run and stores the results.
 func (s *Scheduler) finishPipelineRun(r *gaia.PipelineRun, status gaia.PipelineRunStatus) {
 	// Mark pipeline run as success
@@ -397,9 +965,46 @@ func (s *Scheduler) finishPipelineRun(r *gaia.PipelineRun, status gaia.PipelineR
 	// Finish date
 	r.FinishDate = time.Now()
 
+	// Look for problems in the job logs
+	s.annotateRun(r)
+
+	// Add what the jobs reported with the run token
+	s.collectRunReport(r)
+
+	// Compare the duration with the baseline of the pipeline
+	s.recordRunMetrics(r)
+
+	// A successful canary run releases the pipeline version
+	if r.Canary && status == gaia.RunSuccess {
+		s.approveCanary(r)
+	}
+
+	// A smoke run keeps or rolls back the rebuilt binary
+	if r.Smoke {
+		s.finishSmokeRun(r)
+	}
+
 	// Store it
 	err := s.storeService.PipelinePutRun(r)
 	if err != nil {
 		gaia.Cfg.Logger.Error("cannot store finished pipeline", "error", err.Error())
 	}
+
+	// Export it
+	s.exportRun(r)
+
+	// Notify other systems
+	s.notifyRun(r)
+}
+
+// updatePipeline applies the given change to the stored pipeline with
+// the given id. Edits which have been made while a run was executed
+// are kept. Returns nil if the pipeline does not exist.
+func (s *Scheduler) updatePipeline(id int, change func(p *gaia.Pipeline)) (*gaia.Pipeline, error) {
+	p, err := s.storeService.PipelineGet(id)
+	if err != nil || p == nil || p.Name == "" {
+		return nil, err
+	}
+	change(p)
+	return p, s.storeService.PipelineUpdate(p)
 }
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// auditActorScheduler is the actor name used for audit entries
+	// created by the scheduler.
+	auditActorScheduler = "scheduler"
+)
+
+// resolveSecrets looks up all secrets requested by the given run.
+// Secrets which have not been granted to the pipeline by an admin
+// are refused and the denial is recorded in the audit log.
+func (s *Scheduler) resolveSecrets(r *gaia.PipelineRun) map[string]string {
+	args := map[string]string{}
+	if len(r.Secrets) == 0 {
+		return args
+	}
+
+	// Get granted secrets
+	granted, err := s.storeService.SecretGrantsGet(r.PipelineID)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get secret grants for pipeline", "error", err.Error(), "pipeline", r.PipelineID)
+		return args
+	}
+
+	for _, key := range r.Secrets {
+		if !contains(granted, key) {
+			gaia.Cfg.Logger.Warn("refused to inject secret which is not granted", "secret", key, "pipeline", r.PipelineID, "run", r.ID)
+			err = s.storeService.AuditPut(&gaia.AuditEntry{
+				Actor:         auditActorScheduler,
+				Action:        gaia.AuditSecretDenied,
+				Target:        fmt.Sprintf("pipeline %d run %d", r.PipelineID, r.ID),
+				Message:       key,
+				CorrelationID: r.CorrelationID,
+			})
+			if err != nil {
+				gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+			}
+			continue
+		}
+
+		// Get secret from vault
+		value, err := s.storeService.VaultGet(key)
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot get secret from vault", "error", err.Error(), "secret", key)
+			continue
+		} else if value == nil {
+			gaia.Cfg.Logger.Warn("granted secret does not exist in vault", "secret", key)
+			continue
+		}
+		args[key] = string(value)
+	}
+
+	return args
+}
+
+// contains checks if the given slice contains the given string.
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// changeRequestTimeout is the maximum duration of the creation of
+	// a change request.
+	changeRequestTimeout = 30 * time.Second
+
+	// changeRequestTable is the path of the change request table in
+	// the ServiceNow table API.
+	changeRequestTable = "/api/now/table/change_request"
+)
+
+// errServiceNowNotConfigured is thrown when a ServiceNow gate is used
+// without ServiceNow instance or with another instance.
+var errServiceNowNotConfigured = errors.New("servicenow gates require the configured servicenow instance")
+
+// changeRequest is a change request record of ServiceNow.
+type changeRequest struct {
+	SysID    string `json:"sys_id"`
+	Number   string `json:"number"`
+	Approval string `json:"approval"`
+}
+
+// openChangeRequest creates the change request of the given ServiceNow
+// gate for the given run in the configured ServiceNow instance.
+// Afterwards the target of the gate is the record of the change request.
+func (s *Scheduler) openChangeRequest(g *gaia.Gate, p *gaia.Pipeline, r *gaia.PipelineRun) error {
+	data := &webhookData{
+		Event:    r.Status,
+		Pipeline: webhookPipeline{ID: p.ID, Name: p.Name, Team: p.Team},
+		Run:      r,
+	}
+	fields, err := changeRequestFields(g, data, s.webhookSecrets(p.ID))
+	if err != nil {
+		return err
+	}
+
+	base := serviceNowBase()
+	cr, err := serviceNowRequest(http.MethodPost, base+changeRequestTable, fields, changeRequestTimeout)
+	if err != nil {
+		return err
+	}
+	gaia.Cfg.Logger.Info("created change request", "number", cr.Number, "pipeline", p.Name, "run", r.ID)
+
+	r.ChangeRequest = cr.Number
+	g.Target = base + changeRequestTable + "/" + cr.SysID
+	return nil
+}
+
+// changeRequestFields returns the fields of the change request of the
+// given gate. The fields of the gate override the default fields.
+func changeRequestFields(g *gaia.Gate, data *webhookData, secret func(string) (string, error)) (map[string]string, error) {
+	description := fmt.Sprintf("Run %d of pipeline %s", data.Run.ID, data.Pipeline.Name)
+	if data.Run.Commit.Hash != "" {
+		description += fmt.Sprintf(" deploys commit %s", data.Run.Commit.Hash)
+	}
+	if data.Run.StartedBy != "" {
+		description += fmt.Sprintf(" started by %s", data.Run.StartedBy)
+	}
+	fields := map[string]string{
+		"short_description":   fmt.Sprintf("Deploy %s (run %d)", data.Pipeline.Name, data.Run.ID),
+		"description":         description,
+		"correlation_id":      data.Run.UniqueID,
+		"correlation_display": "gaia",
+	}
+
+	for name, value := range g.Fields {
+		v, err := executeWebhookTemplate(name, value, data, secret)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = v
+	}
+	return fields, nil
+}
+
+// checkChangeRequest returns nil if the change request of the given
+// record url has been approved. Rejected change requests reject the gate.
+func checkChangeRequest(target string, timeout time.Duration) error {
+	cr, err := serviceNowRequest(http.MethodGet, target+"?sysparm_fields=sys_id,number,approval", nil, timeout)
+	if err != nil {
+		return err
+	}
+	switch cr.Approval {
+	case "approved":
+		return nil
+	case "rejected":
+		return &gateRejectedError{reason: fmt.Sprintf("change request %s has been rejected", cr.Number)}
+	}
+	return fmt.Errorf("change request %s is %s", cr.Number, cr.Approval)
+}
+
+// serviceNowBase returns the configured ServiceNow instance without
+// trailing slash.
+func serviceNowBase() string {
+	return strings.TrimSuffix(gaia.Cfg.ServiceNowURL, "/")
+}
+
+// serviceNowRequest sends a request to the table API of ServiceNow and
+// returns the change request of the response. Requests are only sent
+// to the configured instance as they carry its credentials.
+func serviceNowRequest(method, url string, body interface{}, timeout time.Duration) (*changeRequest, error) {
+	if base := serviceNowBase(); base == "" || !strings.HasPrefix(url, base+"/") {
+		return nil, errServiceNowNotConfigured
+	}
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if gaia.Cfg.ServiceNowUser != "" {
+		req.SetBasicAuth(gaia.Cfg.ServiceNowUser, gaia.Cfg.ServiceNowPassword)
+	}
+
+	client := gaia.Cfg.HTTPClient(timeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("servicenow returned status %d", resp.StatusCode)
+	}
+
+	result := struct {
+		Result changeRequest `json:"result"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Result.SysID == "" {
+		return nil, fmt.Errorf("servicenow returned no change request")
+	}
+	return &result.Result, nil
+}
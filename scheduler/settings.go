@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// minPollInterval is the smallest allowed poll interval of the
+// pipeline ticker.
+const minPollInterval = time.Second
+
+// ValidateSettings checks the given operational settings.
+func ValidateSettings(s *gaia.Settings) error {
+	if s.PollInterval < minPollInterval {
+		return fmt.Errorf("pollinterval must be at least %s, got %s", minPollInterval, s.PollInterval)
+	}
+	if s.Worker < 1 {
+		return fmt.Errorf("worker must be a positive number, got %d", s.Worker)
+	}
+	if gaia.Cfg.CanaryWorkers > s.Worker {
+		return fmt.Errorf("worker must not be less than canaryworkers (%d), got %d", gaia.Cfg.CanaryWorkers, s.Worker)
+	}
+	if s.JobLogLimit < 0 {
+		return fmt.Errorf("joblogsize must not be negative, got %d", s.JobLogLimit)
+	}
+	if s.JobHeartbeat < 0 || s.JobCancelGrace < 0 || s.DeleteRetention < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if s.BuildWorker < 0 {
+		return fmt.Errorf("buildworker must not be negative, got %d", s.BuildWorker)
+	}
+	if s.QuarantineAfter < 0 {
+		return fmt.Errorf("quarantineafter must not be negative, got %d", s.QuarantineAfter)
+	}
+	return nil
+}
+
+// scaleWorkers starts or stops workers until the given number of
+// workers is running. Stopped workers finish their current run first.
+func (s *Scheduler) scaleWorkers(n int) {
+	s.workersLock.Lock()
+	defer s.workersLock.Unlock()
+
+	// Stop the workers with the highest ids. A stopping worker gives up
+	// its slot right away, so scaling up again does not wait for it.
+	ids := []int{}
+	for id := range s.workerStops {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for i := len(ids) - 1; i >= n; i-- {
+		close(s.workerStops[ids[i]])
+		delete(s.workerStops, ids[i])
+		s.workersStopping[ids[i]] = true
+	}
+
+	// New workers take the lowest ids which are not used by running or
+	// stopping workers.
+	for id := 1; len(s.workerStops) < n; id++ {
+		if _, ok := s.workerStops[id]; ok || s.workersStopping[id] {
+			continue
+		}
+		stop := make(chan struct{})
+		s.workerStops[id] = stop
+		go s.work(id, stop)
+	}
+}
+
+// removeWorker forgets the given stopped worker.
+func (s *Scheduler) removeWorker(id int) {
+	s.workersLock.Lock()
+	defer s.workersLock.Unlock()
+
+	delete(s.workersStopping, id)
+	delete(s.workers, id)
+}
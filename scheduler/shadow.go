@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// shadowArgKey is the arg passed to the jobs of a shadow run.
+	// Jobs should not cause side effects when it is set.
+	shadowArgKey = "gaia_shadow"
+)
+
+// shadowJobs returns the jobs of the given shadow binary.
+var shadowJobs = (*Scheduler).getPipelineJobs
+
+// scheduleShadowRun schedules a shadow run of the given run if the
+// pipeline has a shadow binary which has not been executed yet.
+// Shadow runs have their own id space: the shadow run of run n has
+// the id -n, so they never take the id of a public run.
+func (s *Scheduler) scheduleShadowRun(r *gaia.PipelineRun) error {
+	p, err := s.storeService.PipelineGet(r.PipelineID)
+	if err != nil {
+		return err
+	} else if p.ShadowExecPath == "" || p.ShadowRunID != 0 {
+		return nil
+	}
+
+	// Get jobs of the shadow binary
+	shadow := *p
+	shadow.ExecPath = p.ShadowExecPath
+	jobs, err := shadowJobs(s, &shadow)
+	if err != nil {
+		return err
+	}
+
+	run := gaia.PipelineRun{
+		UniqueID:      uuid.Must(uuid.NewV4(), nil).String(),
+		ID:            -r.ID,
+		PipelineID:    r.PipelineID,
+		ScheduleDate:  time.Now(),
+		Jobs:          filterJobs(jobs, r.OnlyJobs),
+		Status:        gaia.RunNotScheduled,
+		Secrets:       r.Secrets,
+		Params:        r.Params,
+		Debug:         r.Debug,
+		Shadow:        true,
+		ShadowOf:      r.ID,
+		OnlyJobs:      r.OnlyJobs,
+		Commit:        p.ShadowCommit,
+		CorrelationID: r.CorrelationID,
+	}
+	if len(r.Inputs) > 0 {
+		run.Inputs = r.Inputs
+		if err = storeInputs(&run, RunInputsOf(r)); err != nil {
+			return err
+		}
+	}
+	if err = s.storeService.PipelinePutRun(&run); err != nil {
+		return err
+	}
+
+	p.ShadowRunID = run.ID
+	return s.storeService.PipelineUpdate(p)
+}
@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// simulationWindow is the time span which is simulated.
+	simulationWindow = 24 * time.Hour
+
+	// simulationBucket is the resolution of the simulation report.
+	simulationBucket = time.Hour
+)
+
+// SimulationResult represents the expected scheduling of the next 24 hours.
+type SimulationResult struct {
+	Start   time.Time `json:"start"`
+	Workers int       `json:"workers"`
+	Runs    int       `json:"runs"`
+
+	// ScheduledRuns is the number of runs started by cron schedules.
+	ScheduledRuns int `json:"scheduledruns"`
+
+	// Queue waits in seconds
+	AvgWait float64 `json:"avgwait"`
+	MaxWait float64 `json:"maxwait"`
+
+	Hours []SimulationHour `json:"hours"`
+}
+
+// SimulationHour represents one hour of the simulation.
+type SimulationHour struct {
+	Start time.Time `json:"start"`
+	Runs  int       `json:"runs"`
+
+	// AvgWait is the average queue wait in seconds of the runs scheduled in this hour.
+	AvgWait float64 `json:"avgwait"`
+
+	// Saturation is the busy share of all worker slots between 0 and 1.
+	Saturation float64 `json:"saturation"`
+}
+
+// simulationRun represents a single expected run.
+type simulationRun struct {
+	schedule time.Time
+	duration time.Duration
+}
+
+// Simulate simulates the scheduling of the next 24 hours from start with the
+// given number of workers. The expected runs are the finished runs of the
+// last 24 hours before start, scheduled at the same time of day again, and
+// the runs the cron schedules of the given pipelines start. Scheduled runs
+// of the history are left out as their schedules are simulated instead.
+// Scheduled runs take the average duration of the finished runs of their
+// pipeline.
+func Simulate(history []gaia.PipelineRun, pipelines []gaia.Pipeline, workers int, start time.Time) *SimulationResult {
+	var runs []simulationRun
+	total := map[int]time.Duration{}
+	count := map[int]int{}
+	for _, r := range history {
+		if r.StartDate.IsZero() || r.FinishDate.Before(r.StartDate) {
+			continue
+		}
+		total[r.PipelineID] += r.FinishDate.Sub(r.StartDate)
+		count[r.PipelineID]++
+
+		if r.Scheduled || r.ScheduleDate.Before(start.Add(-simulationWindow)) || !r.ScheduleDate.Before(start) {
+			continue
+		}
+		runs = append(runs, simulationRun{
+			schedule: r.ScheduleDate.Add(simulationWindow),
+			duration: r.FinishDate.Sub(r.StartDate),
+		})
+	}
+
+	scheduled := 0
+	for i := range pipelines {
+		p := &pipelines[i]
+		if schedulesPaused(p) {
+			continue
+		}
+		var duration time.Duration
+		if count[p.ID] > 0 {
+			duration = total[p.ID] / time.Duration(count[p.ID])
+		}
+		for _, sc := range p.Schedules {
+			cron, err := parseSchedule(&sc)
+			if err != nil {
+				continue
+			}
+			for next := cron.next(start); !next.IsZero() && next.Before(start.Add(simulationWindow)); next = cron.next(next) {
+				runs = append(runs, simulationRun{schedule: next, duration: duration})
+				scheduled++
+			}
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].schedule.Before(runs[j].schedule)
+	})
+
+	result := simulate(runs, workers, start)
+	result.ScheduledRuns = scheduled
+	return result
+}
+
+// simulate executes the given runs ordered by schedule date first come
+// first served on the given number of workers.
+func simulate(runs []simulationRun, workers int, start time.Time) *SimulationResult {
+	result := &SimulationResult{
+		Start:   start,
+		Workers: workers,
+		Runs:    len(runs),
+		Hours:   make([]SimulationHour, int(simulationWindow/simulationBucket)),
+	}
+	for i := range result.Hours {
+		result.Hours[i].Start = start.Add(time.Duration(i) * simulationBucket)
+	}
+	if workers <= 0 {
+		return result
+	}
+
+	free := make([]time.Time, workers)
+	busy := make([]time.Duration, len(result.Hours))
+	var totalWait time.Duration
+	for _, r := range runs {
+		// Take the worker which is free first
+		w := 0
+		for i := range free {
+			if free[i].Before(free[w]) {
+				w = i
+			}
+		}
+
+		begin := r.schedule
+		if free[w].After(begin) {
+			begin = free[w]
+		}
+		end := begin.Add(r.duration)
+		free[w] = end
+
+		wait := begin.Sub(r.schedule)
+		totalWait += wait
+		if wait.Seconds() > result.MaxWait {
+			result.MaxWait = wait.Seconds()
+		}
+
+		// Account wait to the hour the run has been scheduled
+		if h := int(r.schedule.Sub(start) / simulationBucket); h >= 0 && h < len(result.Hours) {
+			result.Hours[h].Runs++
+			result.Hours[h].AvgWait += wait.Seconds()
+		}
+
+		// Account busy time to all hours the run overlaps
+		for h := range result.Hours {
+			hStart := result.Hours[h].Start
+			hEnd := hStart.Add(simulationBucket)
+			from, to := begin, end
+			if from.Before(hStart) {
+				from = hStart
+			}
+			if to.After(hEnd) {
+				to = hEnd
+			}
+			if to.After(from) {
+				busy[h] += to.Sub(from)
+			}
+		}
+	}
+
+	if len(runs) > 0 {
+		result.AvgWait = totalWait.Seconds() / float64(len(runs))
+	}
+	for h := range result.Hours {
+		if result.Hours[h].Runs > 0 {
+			result.Hours[h].AvgWait /= float64(result.Hours[h].Runs)
+		}
+		result.Hours[h].Saturation = busy[h].Seconds() / (float64(workers) * simulationBucket.Seconds())
+	}
+
+	return result
+}
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// eventRolledBack is the webhook event which is sent when the binary
+// of a pipeline has been rolled back. The run of the event is the
+// smoke run which did not succeed.
+const eventRolledBack gaia.PipelineRunStatus = "rolledback"
+
+// errSmokeJobNotFound is thrown when the smoke job of a pipeline does
+// not exist in the pipeline binary.
+var errSmokeJobNotFound = errors.New("smoke job not found in pipeline")
+
+// ScheduleSmokeRun schedules a run of the smoke job of the given
+// pipeline. Only the smoke job is executed.
+func (s *Scheduler) ScheduleSmokeRun(p *gaia.Pipeline) (*gaia.PipelineRun, error) {
+	jobs, err := s.getPipelineJobs(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.Title == p.SmokeJob {
+			return s.SchedulePipeline(p, ScheduleOptions{Jobs: []uint32{job.ID}, Smoke: true})
+		}
+	}
+	return nil, errSmokeJobNotFound
+}
+
+// finishSmokeRun discards the previous build of the pipeline of the
+// given smoke run if the run succeeded. Otherwise all binaries and the
+// build details of the previous build are restored and the owners are
+// notified by the webhooks of the pipeline. Held runs of the pipeline
+// are released either way.
+func (s *Scheduler) finishSmokeRun(r *gaia.PipelineRun) {
+	p, err := s.storeService.PipelineGet(r.PipelineID)
+	if err != nil || p == nil || p.Rollback == nil || p.Rollback.RunID != r.ID {
+		return
+	}
+	rollback := *p.Rollback
+	p.Rollback = nil
+
+	// Rollbacks without files only kept the pipeline binary
+	files := rollback.Files
+	if len(files) == 0 {
+		files = map[string]string{p.ExecPath: rollback.ExecPath}
+	}
+	if r.Status != gaia.RunSuccess {
+		for current, previous := range files {
+			if err = restoreBinary(previous, current); err != nil {
+				gaia.Cfg.Logger.Error("cannot roll back pipeline binary", "error", err.Error(), "pipeline", p.Name)
+				return
+			}
+		}
+		p.Commit = rollback.Commit
+		if len(rollback.Files) > 0 {
+			p.Platforms = rollback.Platforms
+			p.Binaries = rollback.Binaries
+			p.Contract = rollback.Contract
+			p.Runbook = rollback.Runbook
+			p.WASMCapabilities = rollback.WASMCapabilities
+		}
+	}
+	if err = s.storeService.PipelineUpdate(p); err != nil {
+		gaia.Cfg.Logger.Error("cannot update pipeline after smoke run", "error", err.Error(), "pipeline", p.Name)
+		return
+	}
+	for _, previous := range files {
+		if err = os.Remove(previous); err != nil && !os.IsNotExist(err) {
+			gaia.Cfg.Logger.Warn("cannot remove previous pipeline binary", "error", err.Error(), "path", previous)
+		}
+	}
+	if r.Status == gaia.RunSuccess {
+		return
+	}
+	gaia.Cfg.Logger.Warn("rolled back pipeline binary after smoke run", "pipeline", p.Name, "run", r.ID, "status", string(r.Status))
+
+	err = s.storeService.AuditPut(&gaia.AuditEntry{
+		Actor:         auditActorScheduler,
+		Action:        gaia.AuditPipelineRollback,
+		Target:        p.Name,
+		Message:       fmt.Sprintf("smoke run %d of the rebuilt binary did not succeed", r.ID),
+		CorrelationID: r.CorrelationID,
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot write audit entry", "error", err.Error())
+	}
+	s.fireWebhooks(p, r, eventRolledBack)
+}
+
+// restoreBinary copies the previous binary over the current one. The
+// ticker picks up the restored binary afterwards.
+func restoreBinary(previous, current string) error {
+	in, err := os.Open(previous)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(current, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0766)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
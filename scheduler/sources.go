@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// maxSourceReferences is the maximum number of source references
+	// which are resolved in one log.
+	maxSourceReferences = 1000
+)
+
+var (
+	// sourceReferencePattern matches file:line and file:line:column
+	// references like compilers, linters and test runners print them.
+	sourceReferencePattern = regexp.MustCompile(`([\w./\\-]*[\w-]\.[A-Za-z]\w*):([1-9][0-9]*)(?::([1-9][0-9]*))?`)
+
+	// scpLikeURLPattern matches scp like git urls like git@github.com:owner/repo.git.
+	scpLikeURLPattern = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):(.+)$`)
+)
+
+// SourceResolver resolves the file references in job logs to the files
+// of a pipeline repo at one commit.
+type SourceResolver struct {
+	commit  string
+	webURL  string
+	byName  map[string][]string
+	hasFile map[string]bool
+}
+
+// NewSourceResolver creates a resolver for the given files of the given
+// commit of the repo with the given url.
+func NewSourceResolver(repoURL, commit string, files []string) *SourceResolver {
+	r := &SourceResolver{
+		commit:  commit,
+		webURL:  repoWebURL(repoURL),
+		byName:  map[string][]string{},
+		hasFile: map[string]bool{},
+	}
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		r.byName[path.Base(f)] = append(r.byName[path.Base(f)], f)
+		r.hasFile[f] = true
+	}
+	return r
+}
+
+// Resolve returns the references in the given log which point to
+// exactly one file of the repo. Ambiguous references are left out.
+func (r *SourceResolver) Resolve(log string) []gaia.SourceReference {
+	var refs []gaia.SourceReference
+	for _, m := range sourceReferencePattern.FindAllStringSubmatchIndex(log, -1) {
+		file := r.resolveFile(strings.Replace(log[m[2]:m[3]], `\`, "/", -1))
+		if file == "" {
+			continue
+		}
+		line, _ := strconv.Atoi(log[m[4]:m[5]])
+		ref := gaia.SourceReference{
+			Start:  m[0],
+			End:    m[1],
+			Path:   file,
+			Line:   line,
+			Commit: r.commit,
+		}
+		if m[6] >= 0 {
+			ref.Column, _ = strconv.Atoi(log[m[6]:m[7]])
+		}
+		ref.URL = r.sourceURL(file, line)
+
+		refs = append(refs, ref)
+		if len(refs) >= maxSourceReferences {
+			break
+		}
+	}
+	return refs
+}
+
+// resolveFile returns the file of the repo the given reference points
+// to. Absolute references point to the file of the repo they end with,
+// relative ones to the repo file which ends with them.
+func (r *SourceResolver) resolveFile(ref string) string {
+	ref = strings.TrimPrefix(ref, "./")
+	if r.hasFile[ref] {
+		return ref
+	}
+
+	// The longest repo path an absolute reference ends with wins
+	longest := ""
+	var endsWith []string
+	for _, f := range r.byName[path.Base(ref)] {
+		if strings.HasSuffix(ref, "/"+f) && len(f) > len(longest) {
+			longest = f
+		} else if strings.HasSuffix(f, "/"+ref) {
+			endsWith = append(endsWith, f)
+		}
+	}
+	if longest != "" {
+		return longest
+	} else if len(endsWith) == 1 {
+		return endsWith[0]
+	}
+	return ""
+}
+
+// sourceURL returns the link to the given line of the given file in the
+// web interface of the repo host. Returns an empty url for unknown hosts.
+func (r *SourceResolver) sourceURL(file string, line int) string {
+	u, err := url.Parse(r.webURL)
+	if err != nil || r.webURL == "" {
+		return ""
+	}
+	switch strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") {
+	case "github.com":
+		return fmt.Sprintf("%s/blob/%s/%s#L%d", r.webURL, r.commit, file, line)
+	case "gitlab.com":
+		return fmt.Sprintf("%s/-/blob/%s/%s#L%d", r.webURL, r.commit, file, line)
+	case "bitbucket.org":
+		return fmt.Sprintf("%s/src/%s/%s#lines-%d", r.webURL, r.commit, file, line)
+	}
+	return ""
+}
+
+// repoWebURL converts the given git url to the https url of the web
+// interface of the repo. Credentials and the .git suffix are removed.
+func repoWebURL(repoURL string) string {
+	if !strings.Contains(repoURL, "://") {
+		m := scpLikeURLPattern.FindStringSubmatch(repoURL)
+		if m == nil {
+			return ""
+		}
+		repoURL = "ssh://" + m[1] + "/" + m[2]
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return "https://" + u.Hostname() + strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+}
@@ -0,0 +1,24 @@
+package scheduler
+
+import (
+	"testing"
+)
+
+func TestSourceResolverURL(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/owner/repo.git":      "https://github.com/owner/repo/blob/abc/cmd/main.go#L3",
+		"git@gitlab.com:owner/repo.git":          "https://gitlab.com/owner/repo/-/blob/abc/cmd/main.go#L3",
+		"https://user@bitbucket.org/owner/repo":  "https://bitbucket.org/owner/repo/src/abc/cmd/main.go#lines-3",
+		"https://github.example.com/owner/repo":  "",
+		"https://git.internal/github/owner/repo": "",
+	}
+	for repoURL, expected := range tests {
+		refs := NewSourceResolver(repoURL, "abc", []string{"cmd/main.go"}).Resolve("main.go:3: undefined: x")
+		if len(refs) != 1 || refs[0].Path != "cmd/main.go" {
+			t.Fatalf("expected reference to cmd/main.go, got %+v", refs)
+		}
+		if refs[0].URL != expected {
+			t.Fatalf("expected url %q for %s, got %q", expected, repoURL, refs[0].URL)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"github.com/gaia-pipeline/gaia"
+)
+
+// sshKey returns the private key the given job connects to the SSH host
+// of the given pipeline with. Returns nil if the job is executed locally.
+func (s *Scheduler) sshKey(p *gaia.Pipeline, job *gaia.Job) ([]byte, error) {
+	if !runsOnSSHHost(p, job) {
+		return nil, nil
+	}
+	key, err := s.webhookSecrets(p.ID)(p.SSH.Key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(key), nil
+}
+
+// runsOnSSHHost returns true if the given job is executed on the SSH
+// host of the given pipeline.
+func runsOnSSHHost(p *gaia.Pipeline, job *gaia.Job) bool {
+	if p.SSH == nil {
+		return false
+	}
+	return len(p.SSH.Jobs) == 0 || contains(p.SSH.Jobs, job.Title)
+}
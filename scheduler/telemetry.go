@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// telemetryInterval is the initial interval between two samples.
+	telemetryInterval = 2 * time.Second
+
+	// maxTelemetrySamples is the max number of samples per job. If reached,
+	// every second sample is dropped and the interval is doubled.
+	maxTelemetrySamples = 1800
+)
+
+var (
+	// errTelemetryUnsupported is thrown when process usage cannot be
+	// sampled on this platform.
+	errTelemetryUnsupported = errors.New("resource telemetry is not supported on this platform")
+)
+
+// processUsage represents the cumulative resource usage of a process.
+type processUsage struct {
+	// CPUTime is the user and system time the process used.
+	CPUTime    time.Duration
+	RSS        uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+// GetTelemetryPath returns the folder of the resource samples of the given run.
+func GetTelemetryPath(pipelineID, runID int) string {
+	return filepath.Join(gaia.Cfg.WorkspacePath, strconv.Itoa(pipelineID), strconv.Itoa(runID), gaia.TelemetryFolderName)
+}
+
+// sampleJobUsage samples the resource usage of the process with the
+// given pid until stop is closed. The series is written to the given
+// file after every sample so it can be watched during execution.
+func sampleJobUsage(pid int, path string, stop <-chan struct{}) {
+	var samples []gaia.ResourceSample
+	var last processUsage
+	lastTime := time.Now()
+	interval := telemetryInterval
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+
+		usage, err := readProcessUsage(pid)
+		if err != nil {
+			// Process already exited or platform not supported
+			if err == errTelemetryUnsupported {
+				return
+			}
+			continue
+		}
+
+		now := time.Now()
+		sample := gaia.ResourceSample{
+			Time:       now,
+			CPU:        100 * float64(usage.CPUTime-last.CPUTime) / float64(now.Sub(lastTime)),
+			RSS:        usage.RSS,
+			ReadBytes:  usage.ReadBytes,
+			WriteBytes: usage.WriteBytes,
+		}
+		last, lastTime = usage, now
+		samples = append(samples, sample)
+
+		// Downsample to keep the series bounded
+		if len(samples) >= maxTelemetrySamples {
+			for i := 0; i < len(samples)/2; i++ {
+				samples[i] = samples[2*i+1]
+			}
+			samples = samples[:len(samples)/2]
+			interval *= 2
+		}
+
+		if err = writeSamples(path, samples); err != nil {
+			gaia.Cfg.Logger.Debug("cannot write resource samples", "error", err.Error(), "path", path)
+		}
+	}
+}
+
+// writeSamples writes the given samples as json to the given file.
+func writeSamples(path string, samples []gaia.ResourceSample) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first. The API might read concurrently.
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
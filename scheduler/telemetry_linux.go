@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// clockTicks is the number of clock ticks per second (USER_HZ)
+	// used in /proc. It is 100 on all common architectures.
+	clockTicks = 100
+)
+
+var (
+	// errInvalidProcStat is thrown when /proc/<pid>/stat cannot be parsed.
+	errInvalidProcStat = errors.New("invalid process stat")
+)
+
+// readProcessUsage reads the resource usage of the given process from /proc.
+func readProcessUsage(pid int) (processUsage, error) {
+	u := processUsage{}
+
+	// CPU time from stat. The command name might contain spaces
+	// and is therefore skipped until the last closing bracket.
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return u, err
+	}
+	i := strings.LastIndex(string(stat), ")")
+	if i == -1 {
+		return u, errInvalidProcStat
+	}
+	fields := strings.Fields(string(stat)[i+1:])
+
+	// utime and stime are field 14 and 15. Fields start with field 3 here.
+	if len(fields) < 13 {
+		return u, errInvalidProcStat
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return u, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return u, err
+	}
+	u.CPUTime = time.Duration(utime+stime) * time.Second / clockTicks
+
+	// Resident memory from status
+	if err = scanProcFile(fmt.Sprintf("/proc/%d/status", pid), func(key, value string) {
+		if key == "VmRSS" {
+			// Value is given in kB
+			kb, _ := strconv.ParseUint(strings.TrimSuffix(value, " kB"), 10, 64)
+			u.RSS = kb * 1024
+		}
+	}); err != nil {
+		return u, err
+	}
+
+	// I/O is only readable with the same user or root. Ignore errors.
+	scanProcFile(fmt.Sprintf("/proc/%d/io", pid), func(key, value string) {
+		switch key {
+		case "read_bytes":
+			u.ReadBytes, _ = strconv.ParseUint(value, 10, 64)
+		case "write_bytes":
+			u.WriteBytes, _ = strconv.ParseUint(value, 10, 64)
+		}
+	})
+
+	return u, nil
+}
+
+// scanProcFile calls the given function for every "key: value" line.
+func scanProcFile(path string, f func(key, value string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) == 2 {
+			f(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+	return scanner.Err()
+}
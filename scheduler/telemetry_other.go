@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package scheduler
+
+// readProcessUsage is only supported on linux.
+func readProcessUsage(pid int) (processUsage, error) {
+	return processUsage{}, errTelemetryUnsupported
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+var (
+	// errInvalidVariableKey is thrown when a variable has an empty or reserved key.
+	errInvalidVariableKey = errors.New("variable keys must not be empty or start with gaia_")
+
+	// errCatalogVariableNotFound is thrown when a pipeline references a
+	// variable which is not in the catalog.
+	errCatalogVariableNotFound = errors.New("catalog variable not found with the given name")
+
+	// errCatalogVariableScope is thrown when a pipeline references a
+	// catalog variable which is limited to other teams.
+	errCatalogVariableScope = errors.New("catalog variable is not available to the team of the pipeline")
+)
+
+// ValidateVariables checks the keys of the given global or pipeline variables.
+func ValidateVariables(variables map[string]string) error {
+	for key := range variables {
+		if strings.TrimSpace(key) == "" || strings.HasPrefix(key, reservedArgPrefix) {
+			return errInvalidVariableKey
+		}
+	}
+	return nil
+}
+
+// ValidateCatalogVariable checks the name of the given catalog variable.
+func ValidateCatalogVariable(v *gaia.CatalogVariable) error {
+	return ValidateVariables(map[string]string{v.Name: v.Value})
+}
+
+// ValidateCatalogReferences checks that all given names reference
+// variables of the given catalog which are available to the given pipeline.
+func ValidateCatalogReferences(p *gaia.Pipeline, catalog []gaia.CatalogVariable, names []string) error {
+	for _, name := range names {
+		v := findCatalogVariable(catalog, name)
+		if v == nil {
+			return errCatalogVariableNotFound
+		} else if !catalogVariableAvailable(v, p) {
+			return errCatalogVariableScope
+		}
+	}
+	return nil
+}
+
+// CatalogValues returns the values of the catalog variables which are
+// referenced by the given pipeline. Variables which have been removed
+// from the catalog or limited to other teams are left out.
+func CatalogValues(p *gaia.Pipeline, catalog []gaia.CatalogVariable) map[string]string {
+	values := map[string]string{}
+	for _, name := range p.CatalogVariables {
+		if v := findCatalogVariable(catalog, name); v != nil && catalogVariableAvailable(v, p) {
+			values[v.Name] = v.Value
+		}
+	}
+	return values
+}
+
+// findCatalogVariable returns the variable with the given name of the
+// given catalog. Returns nil if it does not exist.
+func findCatalogVariable(catalog []gaia.CatalogVariable, name string) *gaia.CatalogVariable {
+	for i := range catalog {
+		if catalog[i].Name == name {
+			return &catalog[i]
+		}
+	}
+	return nil
+}
+
+// catalogVariableAvailable returns true if the given catalog variable
+// can be referenced by the given pipeline.
+func catalogVariableAvailable(v *gaia.CatalogVariable, p *gaia.Pipeline) bool {
+	return len(v.Teams) == 0 || contains(v.Teams, p.Team)
+}
+
+// ResolveVariables merges the given variable layers. Later layers
+// override earlier ones: global, catalog and pipeline variables are
+// overridden by run params.
+func ResolveVariables(layers ...map[string]string) map[string]string {
+	resolved := map[string]string{}
+	for _, layer := range layers {
+		for key, value := range layer {
+			resolved[key] = value
+		}
+	}
+	return resolved
+}
+
+// runVariables returns the resolved variables of the given run of the
+// given pipeline. The run is executed without the global or catalog
+// variables if they cannot be read.
+func (s *Scheduler) runVariables(r *gaia.PipelineRun, p *gaia.Pipeline) map[string]string {
+	global, err := s.storeService.VariablesGet()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get global variables from store", "error", err.Error(), "pipeline", p.Name)
+	}
+	catalog, err := s.storeService.CatalogVariableGetAll()
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get variable catalog from store", "error", err.Error(), "pipeline", p.Name)
+	}
+	return ResolveVariables(global, CatalogValues(p, catalog), p.Variables, r.Params)
+}
+
+// addVariableArgs passes the given variables to the jobs. Resolved
+// secrets and the args set by gaia take precedence.
+func addVariableArgs(variables, args map[string]string) {
+	for key, value := range variables {
+		if _, ok := args[key]; !ok {
+			args[key] = value
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// webhookTimeout is the maximum duration of one webhook request.
+	webhookTimeout = 10 * time.Second
+)
+
+var (
+	// errInvalidWebhookName is thrown when a webhook has an empty or duplicated name.
+	errInvalidWebhookName = errors.New("invalid or duplicated webhook name given")
+
+	// errInvalidWebhookEvent is thrown when a webhook listens to a run
+	// status which is not final.
+	errInvalidWebhookEvent = errors.New("invalid webhook event given. Must be success, failed, cancelled, quarantined or rolledback")
+
+	// errInvalidWebhookMethod is thrown when a webhook has an unknown method.
+	errInvalidWebhookMethod = errors.New("invalid webhook method given")
+
+	// webhookMethods are the methods a webhook can be sent with.
+	webhookMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch}
+)
+
+// webhookData is the data the templates of a webhook are rendered with.
+type webhookData struct {
+	Event    gaia.PipelineRunStatus `json:"event"`
+	Pipeline webhookPipeline        `json:"pipeline"`
+	Run      *gaia.PipelineRun      `json:"run"`
+}
+
+// webhookPipeline is the pipeline whose run finished.
+type webhookPipeline struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Team string `json:"team,omitempty"`
+}
+
+// webhookRequest is a rendered webhook.
+type webhookRequest struct {
+	method  string
+	url     string
+	headers map[string]string
+	body    []byte
+}
+
+// ValidateWebhooks checks the given webhooks. The templates are parsed
+// but not rendered.
+func ValidateWebhooks(webhooks []gaia.Webhook) error {
+	names := []string{}
+	for _, w := range webhooks {
+		if strings.TrimSpace(w.Name) == "" || contains(names, w.Name) {
+			return errInvalidWebhookName
+		}
+		names = append(names, w.Name)
+
+		if w.Method != "" && !contains(webhookMethods, w.Method) {
+			return errInvalidWebhookMethod
+		}
+		for _, e := range w.Events {
+			if e != gaia.RunSuccess && e != gaia.RunFailed && e != gaia.RunCancelled && e != eventQuarantined && e != eventRolledBack {
+				return errInvalidWebhookEvent
+			}
+		}
+
+		// The url is a template, only its scheme is checked
+		if !strings.HasPrefix(w.URL, "http://") && !strings.HasPrefix(w.URL, "https://") {
+			return fmt.Errorf("invalid url of webhook %s given", w.Name)
+		}
+		templates := map[string]string{"url": w.URL, "body": w.Body}
+		for name, value := range w.Headers {
+			templates["header "+name] = value
+		}
+		for name, text := range templates {
+			if _, err := parseWebhookTemplate(name, text, nil); err != nil {
+				return fmt.Errorf("invalid %s template of webhook %s: %s", name, w.Name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// notifyRun informs other systems about the given finished run.
+// Shadow runs are not visible to other systems.
+func (s *Scheduler) notifyRun(r *gaia.PipelineRun) {
+	if r.Shadow {
+		return
+	}
+	p, err := s.storeService.PipelineGet(r.PipelineID)
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot get pipeline from store", "error", err.Error(), "pipeline", r.PipelineID)
+		return
+	}
+
+	s.fireWebhooks(p, r, r.Status)
+	if r.Status == gaia.RunSuccess {
+		s.updateJiraIssues(p, r)
+	}
+	if r.Scheduled && r.Status == gaia.RunFailed {
+		s.checkQuarantine(p, r)
+	}
+}
+
+// fireWebhooks queues the webhooks of the given pipeline for the given
+// event of the given finished run. They are delivered in the background.
+func (s *Scheduler) fireWebhooks(p *gaia.Pipeline, r *gaia.PipelineRun, event gaia.PipelineRunStatus) {
+	if len(p.Webhooks) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(&webhookData{
+		Event:    event,
+		Pipeline: webhookPipeline{ID: p.ID, Name: p.Name, Team: p.Team},
+		Run:      r,
+	})
+	if err != nil {
+		gaia.Cfg.Logger.Error("cannot marshal webhook data", "error", err.Error(), "pipeline", p.Name)
+		return
+	}
+	for _, w := range p.Webhooks {
+		if len(w.Events) > 0 && !containsStatus(w.Events, event) {
+			continue
+		}
+
+		err = s.storeService.WebhookDeliveryPut(&gaia.WebhookDelivery{
+			PipelineID:  p.ID,
+			RunID:       r.ID,
+			Webhook:     w.Name,
+			Event:       event,
+			Status:      gaia.WebhookPending,
+			NextAttempt: time.Now(),
+			Data:        data,
+		})
+		if err != nil {
+			gaia.Cfg.Logger.Error("cannot queue webhook", "error", err.Error(), "webhook", w.Name, "pipeline", p.Name)
+		}
+	}
+	s.triggerDeliveries()
+}
+
+// webhookSecrets returns the template function which looks up the
+// secrets granted to the pipeline with the given id.
+func (s *Scheduler) webhookSecrets(pipelineID int) func(key string) (string, error) {
+	return func(key string) (string, error) {
+		granted, err := s.storeService.SecretGrantsGet(pipelineID)
+		if err != nil {
+			return "", err
+		} else if !contains(granted, key) {
+			return "", fmt.Errorf("secret %s is not granted to the pipeline", key)
+		}
+		value, err := s.storeService.VaultGet(key)
+		if err != nil {
+			return "", err
+		} else if value == nil {
+			return "", fmt.Errorf("secret %s does not exist", key)
+		}
+		return string(value), nil
+	}
+}
+
+// renderWebhook renders the templates of the given webhook. Webhooks
+// without body send the data as JSON.
+func renderWebhook(w *gaia.Webhook, data *webhookData, secret func(string) (string, error)) (*webhookRequest, error) {
+	req := &webhookRequest{method: w.Method, headers: map[string]string{}}
+	if req.method == "" {
+		req.method = http.MethodPost
+	}
+
+	rawURL, err := executeWebhookTemplate("url", w.URL, data, secret)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = url.Parse(rawURL); err != nil {
+		return nil, err
+	}
+	req.url = rawURL
+
+	for name, value := range w.Headers {
+		if req.headers[name], err = executeWebhookTemplate("header "+name, value, data, secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.Body == "" {
+		req.body, err = json.Marshal(data)
+		if _, ok := req.headers["Content-Type"]; !ok {
+			req.headers["Content-Type"] = "application/json"
+		}
+		return req, err
+	}
+	body, err := executeWebhookTemplate("body", w.Body, data, secret)
+	req.body = []byte(body)
+	return req, err
+}
+
+// parseWebhookTemplate parses the given webhook template. The json
+// function quotes values for JSON bodies and the secret function
+// looks up a granted secret.
+func parseWebhookTemplate(name, text string, secret func(string) (string, error)) (*template.Template, error) {
+	if secret == nil {
+		secret = func(string) (string, error) { return "", nil }
+	}
+	return template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"secret": secret,
+	}).Parse(text)
+}
+
+// executeWebhookTemplate renders the given webhook template with the given data.
+func executeWebhookTemplate(name, text string, data *webhookData, secret func(string) (string, error)) (string, error) {
+	t, err := parseWebhookTemplate(name, text, secret)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sendWebhook sends the given rendered webhook.
+func sendWebhook(w *webhookRequest) error {
+	req, err := http.NewRequest(w.method, w.url, bytes.NewReader(w.body))
+	if err != nil {
+		return err
+	}
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	client := gaia.Cfg.HTTPClient(webhookTimeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// containsStatus returns true if the given run status is in the given list.
+func containsStatus(l []gaia.PipelineRunStatus, status gaia.PipelineRunStatus) bool {
+	for _, s := range l {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// WorkerIdle means the worker waits for work.
+	WorkerIdle = "idle"
+
+	// WorkerBusy means the worker executes a pipeline run.
+	WorkerBusy = "busy"
+)
+
+// WorkerState represents the current state of a single worker.
+type WorkerState struct {
+	ID      int       `json:"id"`
+	Status  string    `json:"status"`
+	Version string    `json:"version"`
+	Started time.Time `json:"started"`
+	Since   time.Time `json:"since"`
+
+	// PipelineID and RunID of the current run if busy
+	PipelineID int `json:"pipelineid,omitempty"`
+	RunID      int `json:"runid,omitempty"`
+}
+
+// setWorkerIdle marks the given worker as idle.
+func (s *Scheduler) setWorkerIdle(id int) {
+	s.workersLock.Lock()
+	defer s.workersLock.Unlock()
+
+	w, ok := s.workers[id]
+	if !ok {
+		w = &WorkerState{
+			ID:      id,
+			Version: gaia.Cfg.Version,
+			Started: time.Now(),
+		}
+		s.workers[id] = w
+	}
+	w.Status = WorkerIdle
+	w.Since = time.Now()
+	w.PipelineID = 0
+	w.RunID = 0
+}
+
+// setWorkerBusy marks the given worker as busy with the given run.
+func (s *Scheduler) setWorkerBusy(id int, r *gaia.PipelineRun) {
+	s.workersLock.Lock()
+	defer s.workersLock.Unlock()
+
+	w, ok := s.workers[id]
+	if !ok {
+		return
+	}
+	w.Status = WorkerBusy
+	w.Since = time.Now()
+	w.PipelineID = r.PipelineID
+	w.RunID = r.ID
+}
+
+// Workers returns a snapshot of the state of all workers ordered by id.
+func (s *Scheduler) Workers() []WorkerState {
+	s.workersLock.RLock()
+	defer s.workersLock.RUnlock()
+
+	workers := []WorkerState{}
+	for _, w := range s.workers {
+		workers = append(workers, *w)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].ID < workers[j].ID
+	})
+	return workers
+}
+
+// hostPlatform returns the platform of the host of gaia. Workers run
+// in the gaia process and always execute the binary built for it.
+func hostPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// sshBinary returns the binary of the given pipeline which has been
+// built for the platform of its SSH host. The binary built for the host
+// of gaia is used if the SSH host has no platform or the same platform.
+func sshBinary(p *gaia.Pipeline) (string, error) {
+	platform := p.SSH.Platform
+	if platform == "" || platform == hostPlatform() {
+		return p.ExecPath, nil
+	}
+	if path, ok := p.Binaries[platform]; ok {
+		return path, nil
+	}
+	return "", fmt.Errorf("pipeline has not been built for platform %s of the ssh host", platform)
+}
+
+// pinBinaries makes the given pipeline execute the pinned binaries of
+// the given run. The binary of the SSH host is pinned on a copy of the
+// binaries of the pipeline.
+func pinBinaries(p *gaia.Pipeline, r *gaia.PipelineRun) {
+	if r.PinnedBinary != "" {
+		p.ExecPath = r.PinnedBinary
+	}
+	if r.PinnedSSHBinary != "" && p.SSH != nil {
+		binaries := map[string]string{}
+		for platform, path := range p.Binaries {
+			binaries[platform] = path
+		}
+		binaries[p.SSH.Platform] = r.PinnedSSHBinary
+		p.Binaries = binaries
+	}
+}
+
+// pinnedBinariesExist checks that the pinned binaries of the given run
+// have not been removed since the run has been scheduled.
+func pinnedBinariesExist(r *gaia.PipelineRun) bool {
+	for _, path := range []string{r.PinnedBinary, r.PinnedSSHBinary} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+// AuditPut appends the given entry to the audit log.
+// The entry will get a unique id and the creation date.
+func (s *Store) AuditPut(e *gaia.AuditEntry) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(auditBucket)
+
+		// Generate ID for the entry.
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = int(id)
+		e.Created = time.Now()
+
+		// Marshal entry
+		m, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		// Put entry
+		return b.Put(itob(e.ID), m)
+	})
+}
+
+// AuditGetAll returns all entries of the audit log
+// ordered by creation.
+func (s *Store) AuditGetAll() ([]gaia.AuditEntry, error) {
+	var entries []gaia.AuditEntry
+
+	return entries, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(auditBucket)
+
+		// Iterate all entries
+		return b.ForEach(func(k, v []byte) error {
+			// Unmarshal
+			e := &gaia.AuditEntry{}
+			err := json.Unmarshal(v, e)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, *e)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/gaia-pipeline/gaia"
+)
+
+// readCache holds the results of hot read paths which are polled by the UI.
+// The results are kept encoded so every read returns an independent copy.
+// Every write which touches cached data updates or invalidates it.
+type readCache struct {
+	sync.Mutex
+
+	// pipelines holds all encoded pipelines. Nil if not cached.
+	pipelines [][]byte
+
+	// pipelinesGen is incremented on every pipeline write.
+	pipelinesGen uint64
+
+	// latestRuns holds the encoded latest run by pipeline id.
+	// An empty value means that the pipeline has no runs.
+	latestRuns map[int][]byte
+
+	// runsGen is incremented on every run write.
+	runsGen uint64
+}
+
+// reset drops all cached data.
+func (c *readCache) reset() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.pipelines = nil
+	c.pipelinesGen++
+	c.latestRuns = make(map[int][]byte)
+	c.runsGen++
+}
+
+// getPipelines returns all cached pipelines and the current generation.
+// Returns false if the pipelines are not cached.
+func (c *readCache) getPipelines() ([]gaia.Pipeline, uint64, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.pipelines == nil {
+		return nil, c.pipelinesGen, false
+	}
+
+	var pipelines []gaia.Pipeline
+	for _, v := range c.pipelines {
+		p := gaia.Pipeline{}
+		if err := json.Unmarshal(v, &p); err != nil {
+			return nil, c.pipelinesGen, false
+		}
+		pipelines = append(pipelines, p)
+	}
+	return pipelines, c.pipelinesGen, true
+}
+
+// putPipelines caches the given pipelines if no pipeline has been
+// written since the given generation.
+func (c *readCache) putPipelines(pipelines []gaia.Pipeline, gen uint64) {
+	encoded := [][]byte{}
+	for _, p := range pipelines {
+		v, err := json.Marshal(p)
+		if err != nil {
+			return
+		}
+		encoded = append(encoded, v)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.pipelinesGen == gen {
+		c.pipelines = encoded
+	}
+}
+
+// invalidatePipelines drops the cached pipelines.
+func (c *readCache) invalidatePipelines() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.pipelines = nil
+	c.pipelinesGen++
+}
+
+// getLatestRun returns the cached latest run of the given pipeline and
+// the current generation. Returns false if the run is not cached.
+func (c *readCache) getLatestRun(pipelineID int) (*gaia.PipelineRun, uint64, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	v, ok := c.latestRuns[pipelineID]
+	if !ok {
+		return nil, c.runsGen, false
+	}
+	if len(v) == 0 {
+		return nil, c.runsGen, true
+	}
+
+	r := &gaia.PipelineRun{}
+	if err := json.Unmarshal(v, r); err != nil {
+		return nil, c.runsGen, false
+	}
+	return r, c.runsGen, true
+}
+
+// putLatestRun caches the given latest run of the given pipeline
+// if no run has been written since the given generation.
+func (c *readCache) putLatestRun(pipelineID int, r *gaia.PipelineRun, gen uint64) {
+	v := []byte{}
+	if r != nil {
+		var err error
+		if v, err = json.Marshal(r); err != nil {
+			return
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.runsGen == gen {
+		c.latestRuns[pipelineID] = v
+	}
+}
+
+// updateLatestRun applies the given written run to the cached latest run
+// of its pipeline. The cache entry is dropped if the latest run cannot be
+// determined without reading all runs.
+func (c *readCache) updateLatestRun(r *gaia.PipelineRun) {
+	v, err := json.Marshal(r)
+
+	c.Lock()
+	defer c.Unlock()
+	c.runsGen++
+
+	cached, ok := c.latestRuns[r.PipelineID]
+	if !ok {
+		return
+	}
+	if err != nil {
+		delete(c.latestRuns, r.PipelineID)
+		return
+	}
+
+	// First run of this pipeline
+	if len(cached) == 0 {
+		c.latestRuns[r.PipelineID] = v
+		return
+	}
+
+	latest := &gaia.PipelineRun{}
+	if err := json.Unmarshal(cached, latest); err != nil {
+		delete(c.latestRuns, r.PipelineID)
+		return
+	}
+
+	switch {
+	case latest.StartDate.Before(r.StartDate):
+		// Newer run
+		c.latestRuns[r.PipelineID] = v
+	case latest.UniqueID == r.UniqueID && latest.StartDate.Equal(r.StartDate):
+		// Update of the latest run
+		c.latestRuns[r.PipelineID] = v
+	case latest.UniqueID == r.UniqueID || latest.StartDate.Equal(r.StartDate):
+		// The latest run moved back or another run has the same date
+		delete(c.latestRuns, r.PipelineID)
+	}
+}
+
+// invalidateLatestRun drops the cached latest run of the given pipeline.
+func (c *readCache) invalidateLatestRun(pipelineID int) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.latestRuns, pipelineID)
+	c.runsGen++
+}
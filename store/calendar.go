@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+// CalendarPut stores the given calendar.
+// An existing calendar with the same name is replaced.
+func (s *Store) CalendarPut(c *gaia.Calendar) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(calendarBucket)
+
+		// Marshal calendar
+		m, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+
+		// Put calendar
+		return b.Put([]byte(c.Name), m)
+	})
+}
+
+// CalendarGet returns the calendar with the given name.
+// Returns nil if the calendar does not exist.
+func (s *Store) CalendarGet(name string) (*gaia.Calendar, error) {
+	var c *gaia.Calendar
+
+	return c, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(calendarBucket)
+
+		v := b.Get([]byte(name))
+		if v == nil {
+			return nil
+		}
+
+		// Unmarshal
+		c = &gaia.Calendar{}
+		return json.Unmarshal(v, c)
+	})
+}
+
+// CalendarGetAll returns all calendars ordered by name.
+func (s *Store) CalendarGetAll() ([]gaia.Calendar, error) {
+	calendars := []gaia.Calendar{}
+
+	return calendars, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(calendarBucket)
+
+		// Iterate all calendars
+		return b.ForEach(func(k, v []byte) error {
+			// Unmarshal
+			c := gaia.Calendar{}
+			err := json.Unmarshal(v, &c)
+			if err != nil {
+				return err
+			}
+
+			calendars = append(calendars, c)
+			return nil
+		})
+	})
+}
+
+// CalendarDelete deletes the calendar with the given name.
+func (s *Store) CalendarDelete(name string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(calendarBucket)
+
+		// Delete calendar
+		return b.Delete([]byte(name))
+	})
+}
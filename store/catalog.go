@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+// CatalogVariablePut stores the given catalog variable.
+// An existing variable with the same name is replaced.
+func (s *Store) CatalogVariablePut(v *gaia.CatalogVariable) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(catalogBucket)
+
+		// Marshal variable
+		m, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		// Put variable
+		return b.Put([]byte(v.Name), m)
+	})
+}
+
+// CatalogVariableGet returns the catalog variable with the given name.
+// Returns nil if the variable does not exist.
+func (s *Store) CatalogVariableGet(name string) (*gaia.CatalogVariable, error) {
+	var v *gaia.CatalogVariable
+
+	return v, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(catalogBucket)
+
+		value := b.Get([]byte(name))
+		if value == nil {
+			return nil
+		}
+
+		// Unmarshal
+		v = &gaia.CatalogVariable{}
+		return json.Unmarshal(value, v)
+	})
+}
+
+// CatalogVariableGetAll returns all catalog variables ordered by name.
+func (s *Store) CatalogVariableGetAll() ([]gaia.CatalogVariable, error) {
+	variables := []gaia.CatalogVariable{}
+
+	return variables, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(catalogBucket)
+
+		// Iterate all variables
+		return b.ForEach(func(k, value []byte) error {
+			// Unmarshal
+			v := gaia.CatalogVariable{}
+			err := json.Unmarshal(value, &v)
+			if err != nil {
+				return err
+			}
+
+			variables = append(variables, v)
+			return nil
+		})
+	})
+}
+
+// CatalogVariableDelete deletes the catalog variable with the given name.
+func (s *Store) CatalogVariableDelete(name string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(catalogBucket)
+
+		// Delete variable
+		return b.Delete([]byte(name))
+	})
+}
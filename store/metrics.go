@@ -0,0 +1,117 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+const (
+	// metricsDayFormat is the format of the day of run metrics.
+	metricsDayFormat = "2006-01-02"
+)
+
+// MetricsAddRun rolls the given finished run up into the metrics
+// of the day it has been finished.
+func (s *Store) MetricsAddRun(r *gaia.PipelineRun) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return addRunMetrics(tx.Bucket(metricsBucket), r)
+	})
+}
+
+// addRunMetrics rolls the given finished run up into the given
+// metrics bucket.
+func addRunMetrics(b *bolt.Bucket, r *gaia.PipelineRun) error {
+	day := r.FinishDate.Format(metricsDayFormat)
+	seconds := r.FinishDate.Sub(r.StartDate).Seconds()
+
+	// Get metrics of the day
+	key := metricsKey(r.PipelineID, day)
+	m := &gaia.RunMetrics{PipelineID: r.PipelineID, Day: day}
+	if v := b.Get(key); v != nil {
+		if err := json.Unmarshal(v, m); err != nil {
+			return err
+		}
+	}
+
+	m.Runs++
+	if r.Slow {
+		m.Slow++
+	}
+	if r.Status != gaia.RunSuccess {
+		m.Failed++
+	} else {
+		if m.Runs-m.Failed == 1 || seconds < m.MinSeconds {
+			m.MinSeconds = seconds
+		}
+		if seconds > m.MaxSeconds {
+			m.MaxSeconds = seconds
+		}
+		m.Seconds += seconds
+		m.SquaredSeconds += seconds * seconds
+	}
+
+	// Marshal metrics
+	v, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// Put metrics
+	return b.Put(key, v)
+}
+
+// MetricsGet returns the daily metrics of the given pipeline since
+// the given time ordered by day.
+func (s *Store) MetricsGet(pipelineID int, since time.Time) ([]gaia.RunMetrics, error) {
+	metrics := []gaia.RunMetrics{}
+
+	return metrics, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(metricsBucket)
+
+		// Keys are ordered by day
+		prefix := metricsKey(pipelineID, "")
+		c := b.Cursor()
+		for k, v := c.Seek(metricsKey(pipelineID, since.Format(metricsDayFormat))); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			m := gaia.RunMetrics{}
+			if err := json.Unmarshal(v, &m); err != nil {
+				return err
+			}
+			metrics = append(metrics, m)
+		}
+		return nil
+	})
+}
+
+// MetricsDelete deletes all metrics of the given pipeline.
+func (s *Store) MetricsDelete(pipelineID int) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(metricsBucket)
+
+		// Collect keys first. Deleting while iterating is not supported.
+		var keys [][]byte
+		prefix := metricsKey(pipelineID, "")
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// metricsKey returns the key of the metrics of the given pipeline and day.
+func metricsKey(pipelineID int, day string) []byte {
+	return []byte(fmt.Sprintf("%d/%s", pipelineID, day))
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"encoding/json"
+	"path/filepath"
+	"strings"
 
 	bolt "github.com/coreos/bbolt"
 	"github.com/gaia-pipeline/gaia"
@@ -10,7 +12,7 @@ import (
 // CreatePipelinePut adds a pipeline which
 // is not yet compiled but is about to.
 func (s *Store) CreatePipelinePut(p *gaia.CreatePipeline) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		// Get bucket
 		b := tx.Bucket(createPipelineBucket)
 
@@ -57,7 +59,9 @@ func (s *Store) CreatePipelineGet() ([]gaia.CreatePipeline, error) {
 // PipelinePut puts a pipeline into the store.
 // On persist, the pipeline will get a unique id.
 func (s *Store) PipelinePut(p *gaia.Pipeline) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	defer s.cache.invalidatePipelines()
+
+	return s.update(func(tx *bolt.Tx) error {
 		// Get pipeline bucket
 		b := tx.Bucket(pipelineBucket)
 
@@ -79,6 +83,26 @@ func (s *Store) PipelinePut(p *gaia.Pipeline) error {
 	})
 }
 
+// PipelineUpdate overwrites the stored pipeline which has
+// the same id as the given pipeline.
+func (s *Store) PipelineUpdate(p *gaia.Pipeline) error {
+	defer s.cache.invalidatePipelines()
+
+	return s.update(func(tx *bolt.Tx) error {
+		// Get pipeline bucket
+		b := tx.Bucket(pipelineBucket)
+
+		// Marshal pipeline data into bytes.
+		buf, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+
+		// Persist bytes to pipelines bucket.
+		return b.Put(itob(p.ID), buf)
+	})
+}
+
 // PipelineGet gets a pipeline by given id.
 func (s *Store) PipelineGet(id int) (*gaia.Pipeline, error) {
 	var pipeline = &gaia.Pipeline{}
@@ -170,7 +194,7 @@ func (s *Store) PipelineGetRunHighestID(p *gaia.Pipeline) (int, error) {
 // PipelinePutRun takes the given pipeline run and puts it into the store.
 // If a pipeline run already exists in the store it will be overwritten.
 func (s *Store) PipelinePutRun(r *gaia.PipelineRun) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	err := s.update(func(tx *bolt.Tx) error {
 		// Get bucket
 		b := tx.Bucket(pipelineRunBucket)
 
@@ -183,6 +207,13 @@ func (s *Store) PipelinePutRun(r *gaia.PipelineRun) error {
 		// Persist bytes into bucket.
 		return b.Put([]byte(r.UniqueID), buf)
 	})
+	if err != nil {
+		s.cache.invalidateLatestRun(r.PipelineID)
+		return err
+	}
+
+	s.cache.updateLatestRun(r)
+	return nil
 }
 
 // PipelineGetScheduled returns the scheduled pipelines with a return limit.
@@ -279,7 +310,22 @@ func (s *Store) PipelineGetAllRuns(pipelineID int) ([]gaia.PipelineRun, error) {
 }
 
 // PipelineGetLatestRun returns the latest run by the given pipeline id.
+// The result is cached until a run of the pipeline is written.
 func (s *Store) PipelineGetLatestRun(pipelineID int) (*gaia.PipelineRun, error) {
+	run, gen, ok := s.cache.getLatestRun(pipelineID)
+	if ok {
+		return run, nil
+	}
+
+	run, err := s.pipelineReadLatestRun(pipelineID)
+	if err == nil {
+		s.cache.putLatestRun(pipelineID, run, gen)
+	}
+	return run, err
+}
+
+// pipelineReadLatestRun reads the latest run by the given pipeline id.
+func (s *Store) pipelineReadLatestRun(pipelineID int) (*gaia.PipelineRun, error) {
 	var run *gaia.PipelineRun
 
 	return run, s.db.View(func(tx *bolt.Tx) error {
@@ -310,3 +356,195 @@ func (s *Store) PipelineGetLatestRun(pipelineID int) (*gaia.PipelineRun, error)
 		})
 	})
 }
+
+// PipelineGetAll returns all pipelines which are stored in the store.
+// The result is cached until a pipeline is written.
+func (s *Store) PipelineGetAll() ([]gaia.Pipeline, error) {
+	pipelines, gen, ok := s.cache.getPipelines()
+	if ok {
+		return pipelines, nil
+	}
+
+	pipelines, err := s.pipelineReadAll()
+	if err == nil {
+		s.cache.putPipelines(pipelines, gen)
+	}
+	return pipelines, err
+}
+
+// pipelineReadAll reads all pipelines which are stored in the store.
+func (s *Store) pipelineReadAll() ([]gaia.Pipeline, error) {
+	var pipelines []gaia.Pipeline
+
+	return pipelines, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(pipelineBucket)
+
+		// Iterate all pipelines.
+		return b.ForEach(func(k, v []byte) error {
+			// create single pipeline object
+			p := &gaia.Pipeline{}
+
+			// Unmarshal
+			err := json.Unmarshal(v, p)
+			if err != nil {
+				return err
+			}
+
+			pipelines = append(pipelines, *p)
+			return nil
+		})
+	})
+}
+
+// PipelineDelete deletes the pipeline with the given id.
+func (s *Store) PipelineDelete(id int) error {
+	defer s.cache.invalidatePipelines()
+	defer s.cache.invalidateLatestRun(id)
+
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(pipelineBucket)
+
+		// Delete pipeline
+		return b.Delete(itob(id))
+	})
+}
+
+// PipelineDeleteRuns deletes all runs of the pipeline with the given id.
+func (s *Store) PipelineDeleteRuns(pipelineID int) error {
+	defer s.cache.invalidateLatestRun(pipelineID)
+
+	return s.update(func(tx *bolt.Tx) error {
+		// Get Bucket
+		b := tx.Bucket(pipelineRunBucket)
+
+		// Collect keys first. Deleting during ForEach is not supported.
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			r := &gaia.PipelineRun{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+
+			if r.PipelineID == pipelineID {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err = b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PipelineGetRunsByStatus returns all pipeline runs which have
+// one of the given status.
+func (s *Store) PipelineGetRunsByStatus(status ...gaia.PipelineRunStatus) ([]gaia.PipelineRun, error) {
+	var runs []gaia.PipelineRun
+
+	return runs, s.db.View(func(tx *bolt.Tx) error {
+		// Get Bucket
+		b := tx.Bucket(pipelineRunBucket)
+
+		// Iterate all pipeline runs.
+		return b.ForEach(func(k, v []byte) error {
+			// create single run object
+			r := &gaia.PipelineRun{}
+
+			// Unmarshal
+			err := json.Unmarshal(v, r)
+			if err != nil {
+				return err
+			}
+
+			// Does the run have one of the given status?
+			for _, st := range status {
+				if r.Status == st {
+					runs = append(runs, *r)
+					break
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// PipelineRelocate rewrites all stored paths of pipelines and created
+// pipelines which are below the given folder to the given destination.
+// It is used after a folder has been moved to another location.
+func (s *Store) PipelineRelocate(from, to string) error {
+	defer s.cache.invalidatePipelines()
+
+	relocate := func(p *gaia.Pipeline) {
+		p.ExecPath = relocatePath(p.ExecPath, from, to)
+		p.ShadowExecPath = relocatePath(p.ShadowExecPath, from, to)
+		p.Repo.LocalDest = relocatePath(p.Repo.LocalDest, from, to)
+		for platform, path := range p.Binaries {
+			p.Binaries[platform] = relocatePath(path, from, to)
+		}
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		err := rewriteBucket(tx.Bucket(pipelineBucket), func(v []byte) ([]byte, error) {
+			p := &gaia.Pipeline{}
+			if err := json.Unmarshal(v, p); err != nil {
+				return nil, err
+			}
+			relocate(p)
+			return json.Marshal(p)
+		})
+		if err != nil {
+			return err
+		}
+		return rewriteBucket(tx.Bucket(createPipelineBucket), func(v []byte) ([]byte, error) {
+			p := &gaia.CreatePipeline{}
+			if err := json.Unmarshal(v, p); err != nil {
+				return nil, err
+			}
+			relocate(&p.Pipeline)
+			return json.Marshal(p)
+		})
+	})
+}
+
+// rewriteBucket replaces every value of the given bucket with the
+// result of the given function.
+func rewriteBucket(b *bolt.Bucket, rewrite func(v []byte) ([]byte, error)) error {
+	// Buckets must not be modified while they are iterated
+	values := map[string][]byte{}
+	err := b.ForEach(func(k, v []byte) error {
+		buf, err := rewrite(v)
+		values[string(k)] = buf
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		if err = b.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relocatePath returns the given path below the given destination
+// if it is below the given folder. Otherwise it is returned as is.
+func relocatePath(path, from, to string) string {
+	if path == from {
+		return to
+	}
+	if !strings.HasPrefix(path, from+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(to, strings.TrimPrefix(path, from))
+}
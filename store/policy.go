@@ -0,0 +1,45 @@
+package store
+
+import (
+	"encoding/json"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+// PolicyPut stores the given dependency policy.
+func (s *Store) PolicyPut(policy *gaia.DependencyPolicy) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(policyBucket)
+
+		// Marshal policy
+		m, err := json.Marshal(policy)
+		if err != nil {
+			return err
+		}
+
+		// Put policy
+		return b.Put(policyKey, m)
+	})
+}
+
+// PolicyGet returns the stored dependency policy.
+// Returns nil if no policy has been stored yet.
+func (s *Store) PolicyGet() (*gaia.DependencyPolicy, error) {
+	var policy *gaia.DependencyPolicy
+
+	return policy, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(policyBucket)
+
+		v := b.Get(policyKey)
+		if v == nil {
+			return nil
+		}
+
+		// Unmarshal
+		policy = &gaia.DependencyPolicy{}
+		return json.Unmarshal(v, policy)
+	})
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+// replicatedBuckets are the buckets which are copied to standby
+// instances. The audit log and the settings stay with each instance.
+// The metrics are derived from the runs and are rebuilt by the standby.
+var replicatedBuckets = [][]byte{
+	userBucket,
+	pipelineBucket,
+	createPipelineBucket,
+	pipelineRunBucket,
+	vaultBucket,
+	vaultMetaBucket,
+	secretGrantBucket,
+	calendarBucket,
+	variablesBucket,
+	catalogBucket,
+	policyBucket,
+	signingKeyBucket,
+}
+
+// ReplicationExport returns a consistent snapshot of all replicated buckets.
+func (s *Store) ReplicationExport() (*gaia.ReplicationSnapshot, error) {
+	snapshot := &gaia.ReplicationSnapshot{Created: time.Now()}
+	return snapshot, s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range replicatedBuckets {
+			b := tx.Bucket(name)
+			rb := gaia.ReplicationBucket{Name: string(name), Entries: []gaia.ReplicationEntry{}}
+			err := b.ForEach(func(k, v []byte) error {
+				rb.Entries = append(rb.Entries, gaia.ReplicationEntry{
+					Key:   append([]byte(nil), k...),
+					Value: append([]byte(nil), v...),
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			snapshot.Buckets = append(snapshot.Buckets, rb)
+		}
+		return nil
+	})
+}
+
+// ReplicationImport replaces the replicated buckets with the buckets of
+// the given snapshot. Buckets which are not replicated are ignored.
+// The vault is unlocked again if the master key of the primary
+// replaced the own master key. The vault stays locked if the primary
+// uses another passphrase.
+func (s *Store) ReplicationImport(snapshot *gaia.ReplicationSnapshot) error {
+	var oldKey, newKey []byte
+	err := s.update(func(tx *bolt.Tx) error {
+		oldKey = append(oldKey, tx.Bucket(vaultMetaBucket).Get([]byte(vaultMasterKeyName))...)
+		for _, rb := range snapshot.Buckets {
+			if !isReplicatedBucket([]byte(rb.Name)) {
+				continue
+			}
+			if err := tx.DeleteBucket([]byte(rb.Name)); err != nil {
+				return err
+			}
+			b, err := tx.CreateBucket([]byte(rb.Name))
+			if err != nil {
+				return err
+			}
+			for _, e := range rb.Entries {
+				if err = b.Put(e.Key, e.Value); err != nil {
+					return err
+				}
+			}
+
+			if bytes.Equal([]byte(rb.Name), pipelineRunBucket) {
+				if err = rebuildMetrics(tx); err != nil {
+					return err
+				}
+			}
+		}
+		newKey = append(newKey, tx.Bucket(vaultMetaBucket).Get([]byte(vaultMasterKeyName))...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.cache.reset()
+
+	if bytes.Equal(oldKey, newKey) || gaia.Cfg.VaultPassphrase == "" {
+		return nil
+	}
+	if err = s.unlockVault(gaia.Cfg.VaultPassphrase); err != nil {
+		s.vaultLock.Lock()
+		s.vaultKey = nil
+		s.vaultLock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// ReplicationPromote prepares the replicated state for a promoted
+// standby. New pipelines continue the ids of the primary.
+func (s *Store) ReplicationPromote() error {
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pipelineBucket)
+		k, _ := b.Cursor().Last()
+		if len(k) != 8 {
+			return nil
+		}
+
+		// The vendored bolt version cannot set the sequence directly.
+		// The bucket has been recreated by the import, so this only
+		// happens once per promotion.
+		last := binary.BigEndian.Uint64(k)
+		for {
+			id, err := b.NextSequence()
+			if err != nil || id >= last {
+				return err
+			}
+		}
+	})
+}
+
+// rebuildMetrics recreates the metrics bucket from the stored runs.
+func rebuildMetrics(tx *bolt.Tx) error {
+	if err := tx.DeleteBucket(metricsBucket); err != nil {
+		return err
+	}
+	b, err := tx.CreateBucket(metricsBucket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(pipelineRunBucket).ForEach(func(k, v []byte) error {
+		r := &gaia.PipelineRun{}
+		if err := json.Unmarshal(v, r); err != nil {
+			return err
+		}
+
+		// Only the runs which the scheduler rolls up
+		if r.Shadow || r.StartDate.IsZero() || (r.Status != gaia.RunSuccess && r.Status != gaia.RunFailed) {
+			return nil
+		}
+		return addRunMetrics(b, r)
+	})
+}
+
+// isReplicatedBucket returns true if the bucket with the given name is
+// copied to standby instances.
+func isReplicatedBucket(name []byte) bool {
+	for _, b := range replicatedBuckets {
+		if bytes.Equal(b, name) {
+			return true
+		}
+	}
+	return false
+}
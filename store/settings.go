@@ -0,0 +1,47 @@
+package store
+
+import (
+	"encoding/json"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+// SettingsPut stores the given server settings.
+func (s *Store) SettingsPut(settings *gaia.Settings) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(settingsBucket)
+
+		// Marshal settings
+		m, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+
+		// Put settings
+		return b.Put(settingsKey, m)
+	})
+}
+
+// SettingsGet returns the stored server settings. Settings which are
+// missing in the stored blob, e.g. because they have been added in a
+// later version, keep the value of the given defaults.
+// Returns nil if no settings have been stored yet.
+func (s *Store) SettingsGet(defaults gaia.Settings) (*gaia.Settings, error) {
+	var settings *gaia.Settings
+
+	return settings, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(settingsBucket)
+
+		v := b.Get(settingsKey)
+		if v == nil {
+			return nil
+		}
+
+		// Unmarshal over the defaults
+		settings = &defaults
+		return json.Unmarshal(v, settings)
+	})
+}
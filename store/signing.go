@@ -0,0 +1,34 @@
+package store
+
+import (
+	bolt "github.com/coreos/bbolt"
+)
+
+// ManifestKeyPut stores the seed of the key run manifests are signed
+// with. The key is replicated so standby instances sign with the same key.
+func (s *Store) ManifestKeyPut(seed []byte) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(signingKeyBucket)
+
+		// Put seed
+		return b.Put(manifestKeyName, seed)
+	})
+}
+
+// ManifestKeyGet returns the seed of the key run manifests are signed
+// with. Returns nil if no key has been stored yet.
+func (s *Store) ManifestKeyGet() ([]byte, error) {
+	var seed []byte
+
+	return seed, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(signingKeyBucket)
+
+		// Copy seed because the value is only valid in the transaction
+		if v := b.Get(manifestKeyName); v != nil {
+			seed = append([]byte(nil), v...)
+		}
+		return nil
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/binary"
 	"fmt"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	bolt "github.com/coreos/bbolt"
 	"github.com/gaia-pipeline/gaia"
@@ -22,6 +25,56 @@ var (
 
 	// Name of the bucket where we store all pipeline runs.
 	pipelineRunBucket = []byte("PipelineRun")
+
+	// Name of the bucket where we store the encrypted vault entries.
+	vaultBucket = []byte("Vault")
+
+	// Name of the bucket where we store the wrapped vault master key.
+	vaultMetaBucket = []byte("VaultMeta")
+
+	// Name of the bucket where we store which vault keys are
+	// granted to which pipeline.
+	secretGrantBucket = []byte("SecretGrants")
+
+	// Name of the bucket where we store the audit log.
+	auditBucket = []byte("AuditLog")
+
+	// Name of the bucket where we store the maintenance calendars.
+	calendarBucket = []byte("Calendars")
+
+	// Name of the bucket where we store the rolled-up run metrics.
+	metricsBucket = []byte("Metrics")
+
+	// Name of the bucket where we store the server settings.
+	settingsBucket = []byte("Settings")
+
+	// settingsKey is the key of the server settings in the settings bucket.
+	settingsKey = []byte("settings")
+
+	// Name of the bucket where we store the global variables.
+	variablesBucket = []byte("Variables")
+
+	// variablesKey is the key of the global variables in the variables bucket.
+	variablesKey = []byte("variables")
+
+	// Name of the bucket where we store the variable catalog.
+	catalogBucket = []byte("VariableCatalog")
+
+	// Name of the bucket where we store the webhook deliveries.
+	webhookDeliveryBucket = []byte("WebhookDeliveries")
+
+	// Name of the bucket where we store the dependency policy.
+	policyBucket = []byte("Policy")
+
+	// policyKey is the key of the dependency policy in the policy bucket.
+	policyKey = []byte("policy")
+
+	// Name of the bucket where we store the signing keys.
+	signingKeyBucket = []byte("SigningKeys")
+
+	// manifestKeyName is the key of the run manifest signing key in the
+	// signing key bucket.
+	manifestKeyName = []byte("manifest")
 )
 
 const (
@@ -36,6 +89,18 @@ const (
 // Store represents the access type for store
 type Store struct {
 	db *bolt.DB
+
+	// vaultKey is the master key used to encrypt vault entries.
+	vaultKey []byte
+
+	// vaultLock protects vaultKey during key rotation.
+	vaultLock sync.RWMutex
+
+	// cache holds the results of hot read paths.
+	cache readCache
+
+	// writeDelay is the injected delay of every write in nanoseconds.
+	writeDelay int64
 }
 
 // NewStore creates a new instance of Store.
@@ -45,6 +110,22 @@ func NewStore() *Store {
 	return s
 }
 
+// SetWriteDelay delays every following write by the given duration.
+// It is used by the fault injection to test the recovery of the
+// scheduler from a slow store. A zero duration removes the delay.
+func (s *Store) SetWriteDelay(d time.Duration) {
+	atomic.StoreInt64(&s.writeDelay, int64(d))
+}
+
+// update executes the given function in a read-write transaction
+// after the injected write delay.
+func (s *Store) update(fn func(*bolt.Tx) error) error {
+	if d := atomic.LoadInt64(&s.writeDelay); d > 0 {
+		time.Sleep(time.Duration(d))
+	}
+	return s.db.Update(fn)
+}
+
 // Init creates the data folder if not exists,
 // generates private key and bolt database.
 // This should be called only once per database
@@ -57,9 +138,24 @@ func (s *Store) Init() error {
 		return err
 	}
 	s.db = db
+	s.cache.reset()
 
 	// Setup database
-	return s.setupDatabase()
+	if err = s.setupDatabase(); err != nil {
+		return err
+	}
+
+	// Unlock vault. The vault stays locked without passphrase.
+	s.vaultKey = nil
+	if gaia.Cfg.VaultPassphrase != "" {
+		return s.unlockVault(gaia.Cfg.VaultPassphrase)
+	}
+	return nil
+}
+
+// Close closes the bolt database and releases its lock.
+func (s *Store) Close() error {
+	return s.db.Close()
 }
 
 // setupDatabase create all buckets in the db.
@@ -76,25 +172,29 @@ func (s *Store) setupDatabase() error {
 	}
 
 	// Make sure buckets exist
-	bucketName = userBucket
-	err := s.db.Update(c)
-	if err != nil {
-		return err
-	}
-	bucketName = pipelineBucket
-	err = s.db.Update(c)
-	if err != nil {
-		return err
-	}
-	bucketName = createPipelineBucket
-	err = s.db.Update(c)
-	if err != nil {
-		return err
+	buckets := [][]byte{
+		userBucket,
+		pipelineBucket,
+		createPipelineBucket,
+		pipelineRunBucket,
+		vaultBucket,
+		vaultMetaBucket,
+		secretGrantBucket,
+		auditBucket,
+		calendarBucket,
+		settingsBucket,
+		variablesBucket,
+		catalogBucket,
+		policyBucket,
+		metricsBucket,
+		webhookDeliveryBucket,
+		signingKeyBucket,
 	}
-	bucketName = pipelineRunBucket
-	err = s.db.Update(c)
-	if err != nil {
-		return err
+	for _, bucketName = range buckets {
+		err := s.db.Update(c)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Make sure that the user "admin" does exist
@@ -109,8 +209,16 @@ func (s *Store) setupDatabase() error {
 			DisplayName: adminUsername,
 			Username:    adminUsername,
 			Password:    adminPassword,
+			Admin:       true,
 		}, true)
 
+		if err != nil {
+			return err
+		}
+	} else if !admin.Admin {
+		// Databases created by older versions do not know roles yet
+		admin.Admin = true
+		err = s.UserPut(admin, false)
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -8,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	bolt "github.com/coreos/bbolt"
 	"github.com/gaia-pipeline/gaia"
 	uuid "github.com/satori/go.uuid"
 )
@@ -556,3 +559,762 @@ func TestPipelineGetLatestRun(t *testing.T) {
 	}
 
 }
+
+func TestPipelineDelete(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	p := &gaia.Pipeline{
+		Name:    "Test Pipeline",
+		Type:    gaia.PTypeGolang,
+		Created: time.Now(),
+	}
+
+	err = store.PipelinePut(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.PipelineDelete(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipelines, err := store.PipelineGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipelines) != 0 {
+		t.Fatalf("expected %d pipelines, got %d", 0, len(pipelines))
+	}
+}
+
+func TestPipelineDeleteRuns(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	for i, pipelineID := range []int{1, 2, 1} {
+		r := &gaia.PipelineRun{
+			UniqueID:   fmt.Sprintf("run-%d", i),
+			ID:         i + 1,
+			PipelineID: pipelineID,
+			Status:     gaia.RunSuccess,
+		}
+		if err = store.PipelinePutRun(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = store.PipelineDeleteRuns(1); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := store.PipelineGetRunsByStatus(gaia.RunSuccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].PipelineID != 2 {
+		t.Fatalf("expected only the run of pipeline %d, got %+v", 2, runs)
+	}
+}
+
+func TestPipelineGetRunsByStatus(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	for i, status := range []gaia.PipelineRunStatus{gaia.RunRunning, gaia.RunScheduled, gaia.RunSuccess} {
+		r := &gaia.PipelineRun{
+			ID:         i,
+			PipelineID: 1,
+			Status:     status,
+			UniqueID:   uuid.Must(uuid.NewV4(), nil).String(),
+		}
+		err = store.PipelinePutRun(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := store.PipelineGetRunsByStatus(gaia.RunRunning, gaia.RunScheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != 2 {
+		t.Fatalf("expected %d runs, got %d", 2, len(runs))
+	}
+}
+
+func TestVaultPutGet(t *testing.T) {
+	gaia.Cfg.VaultPassphrase = "testpassphrase"
+	defer func() { gaia.Cfg.VaultPassphrase = "" }()
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	err = store.VaultPut("db-password", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := store.VaultGet("db-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "secret" {
+		t.Fatalf("expected value %s, got %s", "secret", string(value))
+	}
+
+	keys, err := store.VaultKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "db-password" {
+		t.Fatalf("expected keys %v, got %v", []string{"db-password"}, keys)
+	}
+
+	err = store.VaultDelete("db-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err = store.VaultGet("db-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != nil {
+		t.Fatalf("expected nil value after delete, got %s", string(value))
+	}
+}
+
+func TestVaultLocked(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	err = store.VaultPut("db-password", []byte("secret"))
+	if err != errVaultLocked {
+		t.Fatalf("expected error %v, got %v", errVaultLocked, err)
+	}
+}
+
+func TestSecretGrants(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	keys, err := store.SecretGrantsGet(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected no grants, got %v", keys)
+	}
+
+	err = store.SecretGrantsPut(1, []string{"db-password", "api-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err = store.SecretGrantsGet(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected %d grants, got %d", 2, len(keys))
+	}
+}
+
+func TestAuditPut(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	for i := 0; i < 2; i++ {
+		err = store.AuditPut(&gaia.AuditEntry{
+			Actor:  "admin",
+			Action: gaia.AuditSecretGrant,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := store.AuditGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected %d entries, got %d", 2, len(entries))
+	}
+	if entries[0].ID != 1 || entries[1].ID != 2 {
+		t.Fatalf("expected ordered ids, got %d and %d", entries[0].ID, entries[1].ID)
+	}
+}
+
+func TestVaultRotateKey(t *testing.T) {
+	gaia.Cfg.VaultPassphrase = "testpassphrase"
+	defer func() { gaia.Cfg.VaultPassphrase = "" }()
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	err = store.VaultPut("db-password", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := store.vaultKey
+
+	err = store.VaultRotateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(oldKey) == hex.EncodeToString(store.vaultKey) {
+		t.Fatal("master key has not been changed")
+	}
+
+	// Master key is wrapped by an Argon2id derived key
+	m := &vaultMasterKey{}
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return json.Unmarshal(tx.Bucket(vaultMetaBucket).Get([]byte(vaultMasterKeyName)), m)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.KDF != vaultKDFArgon2id || m.Memory != vaultKDFMemory || len(m.Salt) != vaultSaltLength {
+		t.Fatalf("expected argon2id wrapped master key, got %+v", m)
+	}
+
+	// Unlock again with the new wrapped master key
+	store.db.Close()
+	err = store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := store.VaultGet("db-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "secret" {
+		t.Fatalf("expected value %s, got %s", "secret", string(value))
+	}
+
+	// Wrong passphrase must not unlock the vault
+	gaia.Cfg.VaultPassphrase = "wrongpassphrase"
+	store.db.Close()
+	err = store.Init()
+	if err != errVaultPassphrase {
+		t.Fatalf("expected error %v, got %v", errVaultPassphrase, err)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	c := &gaia.Calendar{
+		Name:   "holidays",
+		Policy: gaia.CalendarHold,
+		Windows: []gaia.CalendarWindow{
+			{Start: time.Now(), End: time.Now().Add(time.Hour), Reason: "christmas"},
+		},
+	}
+	if err = store.CalendarPut(c); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.CalendarGet("holidays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || len(stored.Windows) != 1 || stored.Windows[0].Reason != "christmas" {
+		t.Fatalf("expected stored calendar, got %+v", stored)
+	}
+
+	calendars, err := store.CalendarGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendars) != 1 {
+		t.Fatalf("expected %d calendars, got %d", 1, len(calendars))
+	}
+
+	if err = store.CalendarDelete("holidays"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = store.CalendarGet("holidays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != nil {
+		t.Fatal("calendar has not been deleted")
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	d := &gaia.WebhookDelivery{PipelineID: 1, RunID: 2, Webhook: "chat", Status: gaia.WebhookPending}
+	if err = store.WebhookDeliveryPut(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.ID == 0 || d.Created.IsZero() {
+		t.Fatalf("expected id and creation date, got %+v", d)
+	}
+
+	d.Status = gaia.WebhookDead
+	if err = store.WebhookDeliveryPut(d); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.WebhookDeliveryGet(d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.Status != gaia.WebhookDead {
+		t.Fatalf("expected dead delivery, got %+v", stored)
+	}
+
+	deliveries, err := store.WebhookDeliveryGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected %d deliveries, got %d", 1, len(deliveries))
+	}
+
+	if err = store.WebhookDeliveryDelete(d.ID); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = store.WebhookDeliveryGet(d.ID); err != nil || stored != nil {
+		t.Fatalf("delivery has not been deleted, got %+v", stored)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	stored, err := store.SettingsGet(gaia.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != nil {
+		t.Fatalf("expected no settings, got %+v", stored)
+	}
+
+	settings := &gaia.Settings{
+		PollInterval: 10 * time.Second,
+		Worker:       4,
+	}
+	if err = store.SettingsPut(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = store.SettingsGet(gaia.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || *stored != *settings {
+		t.Fatalf("expected %+v, got %+v", settings, stored)
+	}
+
+	// Settings missing in an older blob keep their default
+	if err = store.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(settingsBucket).Put(settingsKey, []byte(`{"pollinterval":10000000000,"worker":4}`))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = store.SettingsGet(gaia.Settings{JobLogColors: true, QuarantineAfter: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Worker != 4 || !stored.JobLogColors || stored.QuarantineAfter != 3 {
+		t.Fatalf("expected defaults for missing settings, got %+v", stored)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	stored, err := store.VariablesGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 0 {
+		t.Fatalf("expected no variables, got %v", stored)
+	}
+
+	if err = store.VariablesPut(map[string]string{"region": "eu-west-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = store.VariablesGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored["region"] != "eu-west-1" {
+		t.Fatalf("expected region variable, got %v", stored)
+	}
+}
+
+func TestCatalogVariables(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	v := &gaia.CatalogVariable{Name: "registry", Value: "registry.example.com", Teams: []string{"platform"}}
+	if err = store.CatalogVariablePut(v); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.CatalogVariableGet("registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.Value != "registry.example.com" || len(stored.Teams) != 1 {
+		t.Fatalf("expected stored catalog variable, got %+v", stored)
+	}
+
+	variables, err := store.CatalogVariableGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variables) != 1 {
+		t.Fatalf("expected %d catalog variables, got %d", 1, len(variables))
+	}
+
+	if err = store.CatalogVariableDelete("registry"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = store.CatalogVariableGet("registry"); err != nil || stored != nil {
+		t.Fatalf("expected deleted catalog variable, got %+v (%v)", stored, err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	now := time.Now()
+	runs := []gaia.PipelineRun{
+		{PipelineID: 1, Status: gaia.RunSuccess, StartDate: now.Add(-10 * time.Second), FinishDate: now},
+		{PipelineID: 1, Status: gaia.RunSuccess, StartDate: now.Add(-20 * time.Second), FinishDate: now},
+		{PipelineID: 1, Status: gaia.RunFailed, StartDate: now.Add(-time.Second), FinishDate: now},
+		{PipelineID: 1, Status: gaia.RunSuccess, StartDate: now.AddDate(0, 0, -3), FinishDate: now.AddDate(0, 0, -3).Add(time.Second)},
+		{PipelineID: 11, Status: gaia.RunSuccess, StartDate: now.Add(-time.Second), FinishDate: now},
+	}
+	for i := range runs {
+		if err = store.MetricsAddRun(&runs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics, err := store.MetricsGet(1, now.AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("expected %d metrics, got %d", 1, len(metrics))
+	}
+	m := metrics[0]
+	if m.Runs != 3 || m.Failed != 1 || m.Seconds != 30 || m.MinSeconds != 10 || m.MaxSeconds != 20 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+
+	// Older metrics are kept
+	metrics, err = store.MetricsGet(1, now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("expected %d metrics, got %d", 2, len(metrics))
+	}
+
+	if err = store.MetricsDelete(1); err != nil {
+		t.Fatal(err)
+	}
+	metrics, err = store.MetricsGet(1, now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 0 {
+		t.Fatalf("expected no metrics, got %d", len(metrics))
+	}
+	metrics, err = store.MetricsGet(11, now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("expected metrics of other pipeline to be kept, got %d", len(metrics))
+	}
+}
+
+func TestReadCache(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	pipeline := &gaia.Pipeline{Name: "Test Pipeline", Type: gaia.PTypeGolang}
+	if err = store.PipelinePut(pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the cache and modify the returned copy
+	pipelines, err := store.PipelineGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipelines[0].Name = "Modified"
+	pipelines, _ = store.PipelineGetAll()
+	if pipelines[0].Name != "Test Pipeline" {
+		t.Fatalf("expected cached name %s, got %s", "Test Pipeline", pipelines[0].Name)
+	}
+
+	// Writes invalidate the cache
+	pipeline.Team = "team"
+	if err = store.PipelineUpdate(pipeline); err != nil {
+		t.Fatal(err)
+	}
+	pipelines, _ = store.PipelineGetAll()
+	if pipelines[0].Team != "team" {
+		t.Fatalf("expected team %s, got %s", "team", pipelines[0].Team)
+	}
+
+	// No runs yet
+	latest, err := store.PipelineGetLatestRun(pipeline.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != nil {
+		t.Fatalf("expected no latest run, got %+v", latest)
+	}
+
+	run := &gaia.PipelineRun{
+		ID:         1,
+		PipelineID: pipeline.ID,
+		UniqueID:   uuid.Must(uuid.NewV4(), nil).String(),
+		Status:     gaia.RunNotScheduled,
+	}
+	if err = store.PipelinePutRun(run); err != nil {
+		t.Fatal(err)
+	}
+	latest, _ = store.PipelineGetLatestRun(pipeline.ID)
+	if latest == nil || latest.UniqueID != run.UniqueID {
+		t.Fatalf("expected latest run %s, got %+v", run.UniqueID, latest)
+	}
+
+	// Updates of the latest run are visible
+	run.Status = gaia.RunRunning
+	run.StartDate = time.Now()
+	if err = store.PipelinePutRun(run); err != nil {
+		t.Fatal(err)
+	}
+	latest, _ = store.PipelineGetLatestRun(pipeline.ID)
+	if latest.Status != gaia.RunRunning {
+		t.Fatalf("expected status %s, got %s", gaia.RunRunning, latest.Status)
+	}
+
+	// Older runs do not replace the latest run
+	older := &gaia.PipelineRun{
+		ID:         2,
+		PipelineID: pipeline.ID,
+		UniqueID:   uuid.Must(uuid.NewV4(), nil).String(),
+		StartDate:  run.StartDate.Add(-time.Hour),
+	}
+	if err = store.PipelinePutRun(older); err != nil {
+		t.Fatal(err)
+	}
+	latest, _ = store.PipelineGetLatestRun(pipeline.ID)
+	if latest.UniqueID != run.UniqueID {
+		t.Fatalf("expected latest run %s, got %s", run.UniqueID, latest.UniqueID)
+	}
+
+	if err = store.PipelineDeleteRuns(pipeline.ID); err != nil {
+		t.Fatal(err)
+	}
+	latest, _ = store.PipelineGetLatestRun(pipeline.ID)
+	if latest != nil {
+		t.Fatalf("expected no latest run after delete, got %+v", latest)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+
+	stored, err := store.PolicyGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != nil {
+		t.Fatalf("expected no policy, got %+v", stored)
+	}
+
+	policy := &gaia.DependencyPolicy{
+		ForbiddenLicenses: []string{"AGPL-3.0"},
+		Waivers:           []gaia.PolicyWaiver{{ID: "1", Pipeline: "release", Dependency: "github.com/gaia/agpl"}},
+	}
+	if err = store.PolicyPut(policy); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = store.PolicyGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || len(stored.ForbiddenLicenses) != 1 || len(stored.Waivers) != 1 || stored.Waivers[0].Pipeline != "release" {
+		t.Fatalf("expected %+v, got %+v", policy, stored)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	gaia.Cfg.VaultPassphrase = "testpassphrase"
+	defer func() { gaia.Cfg.VaultPassphrase = "" }()
+	err := store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &gaia.Pipeline{Name: "replicated pipeline"}
+	if err = store.PipelinePut(p); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.VaultPut("db-password", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.AuditPut(&gaia.AuditEntry{Actor: "admin", Action: gaia.AuditVaultRotate}); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.ManifestKeyPut([]byte("primary key")); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	runs := []*gaia.PipelineRun{
+		{UniqueID: "run-1", ID: 1, PipelineID: p.ID, Status: gaia.RunSuccess, StartDate: start, FinishDate: start.Add(time.Minute)},
+		{UniqueID: "run-2", ID: 2, PipelineID: p.ID, Status: gaia.RunCancelled, StartDate: start, FinishDate: start.Add(time.Minute)},
+	}
+	for _, r := range runs {
+		if err = store.PipelinePutRun(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot, err := store.ReplicationExport()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The standby starts with an own database and master key
+	store.db.Close()
+	os.Remove("data/gaia.db")
+	if err = store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("data/gaia.db")
+	if err = store.PipelinePut(&gaia.Pipeline{Name: "standby pipeline"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.ManifestKeyPut([]byte("standby key")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.ReplicationImport(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	pipelines, err := store.PipelineGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pipelines) != 1 || pipelines[0].Name != p.Name || pipelines[0].ID != p.ID {
+		t.Fatalf("expected replicated pipeline, got %v", pipelines)
+	}
+	value, err := store.VaultGet("db-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "secret" {
+		t.Fatalf("expected replicated secret, got %q", value)
+	}
+	key, err := store.ManifestKeyGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != "primary key" {
+		t.Fatalf("expected manifest key of the primary, got %q", key)
+	}
+	entries, err := store.AuditGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected audit log not to be replicated, got %v", entries)
+	}
+	for _, rb := range snapshot.Buckets {
+		if rb.Name == string(metricsBucket) {
+			t.Fatal("expected metrics not to be replicated")
+		}
+	}
+
+	// The standby rebuilds the metrics from the replicated runs
+	metrics, err := store.MetricsGet(p.ID, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics[0].Runs != 1 || metrics[0].Seconds != 60 {
+		t.Fatalf("expected rebuilt metrics, got %+v", metrics)
+	}
+
+	// New pipelines continue the ids of the primary once promoted
+	if err = store.ReplicationPromote(); err != nil {
+		t.Fatal(err)
+	}
+	next := &gaia.Pipeline{Name: "next pipeline"}
+	if err = store.PipelinePut(next); err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != p.ID+1 {
+		t.Fatalf("expected id %d, got %d", p.ID+1, next.ID)
+	}
+}
@@ -23,7 +23,7 @@ func (s *Store) UserPut(u *gaia.User, encryptPassword bool) error {
 		u.Password = string(hash)
 	}
 
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		// Get bucket
 		b := tx.Bucket(userBucket)
 
@@ -131,7 +131,7 @@ func (s *Store) UserGetAll() ([]gaia.User, error) {
 
 // UserDelete deletes the given user.
 func (s *Store) UserDelete(u string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		// Get bucket
 		b := tx.Bucket(userBucket)
 
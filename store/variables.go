@@ -0,0 +1,42 @@
+package store
+
+import (
+	"encoding/json"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+// VariablesPut stores the given global variables.
+func (s *Store) VariablesPut(variables map[string]string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(variablesBucket)
+
+		// Marshal variables
+		m, err := json.Marshal(variables)
+		if err != nil {
+			return err
+		}
+
+		// Put variables
+		return b.Put(variablesKey, m)
+	})
+}
+
+// VariablesGet returns the global variables.
+func (s *Store) VariablesGet() (map[string]string, error) {
+	variables := map[string]string{}
+
+	return variables, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(variablesBucket)
+
+		v := b.Get(variablesKey)
+		if v == nil {
+			return nil
+		}
+
+		// Unmarshal
+		return json.Unmarshal(v, &variables)
+	})
+}
@@ -0,0 +1,392 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// vaultMasterKeyName is the key in the vault meta bucket
+	// which holds the wrapped master key.
+	vaultMasterKeyName = "masterkey"
+
+	// vaultKDFArgon2id identifies Argon2id as key derivation function.
+	vaultKDFArgon2id = "argon2id"
+
+	// Argon2id parameters for new master keys as recommended by RFC 9106.
+	// The memory is given in KiB.
+	vaultKDFTime    = 3
+	vaultKDFMemory  = 64 * 1024
+	vaultKDFThreads = 4
+
+	// vaultKeyLength is the length of the master key and the derived key.
+	// 32 bytes selects AES-256.
+	vaultKeyLength = 32
+
+	// vaultSaltLength is the length of the random KDF salt.
+	vaultSaltLength = 16
+)
+
+var (
+	// errVaultLocked is thrown when the vault is used without a passphrase.
+	errVaultLocked = errors.New("vault is locked. No vault passphrase has been configured")
+
+	// errVaultCorrupted is thrown when a vault entry cannot be decrypted.
+	errVaultCorrupted = errors.New("vault entry is corrupted or has been encrypted with another key")
+
+	// errVaultPassphrase is thrown when the master key cannot be unwrapped.
+	errVaultPassphrase = errors.New("cannot unlock vault. Wrong vault passphrase given")
+
+	// errVaultUnknownKDF is thrown when the master key has been wrapped
+	// with an unsupported key derivation function.
+	errVaultUnknownKDF = errors.New("vault master key uses an unknown key derivation function")
+)
+
+// vaultMasterKey represents the master key wrapped by a key
+// which is derived from the vault passphrase.
+// The KDF and its parameters are stored so they can be upgraded later.
+type vaultMasterKey struct {
+	KDF        string `json:"kdf"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	Salt       []byte `json:"salt"`
+	WrappedKey []byte `json:"wrappedkey"`
+}
+
+// randomBytes returns n cryptographically secure random bytes.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(rand.Reader, b)
+	return b, err
+}
+
+// deriveWrappingKey derives the key which wraps the master key.
+func (m *vaultMasterKey) deriveWrappingKey(passphrase string) ([]byte, error) {
+	switch m.KDF {
+	case vaultKDFArgon2id:
+		return argon2.IDKey([]byte(passphrase), m.Salt, m.Time, m.Memory, m.Threads, vaultKeyLength), nil
+	}
+	return nil, errVaultUnknownKDF
+}
+
+// wrapMasterKey wraps the given master key with a key derived
+// from the given passphrase and a fresh salt.
+func wrapMasterKey(master []byte, passphrase string) (*vaultMasterKey, error) {
+	salt, err := randomBytes(vaultSaltLength)
+	if err != nil {
+		return nil, err
+	}
+	m := &vaultMasterKey{
+		KDF:     vaultKDFArgon2id,
+		Time:    vaultKDFTime,
+		Memory:  vaultKDFMemory,
+		Threads: vaultKDFThreads,
+		Salt:    salt,
+	}
+
+	kek, err := m.deriveWrappingKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	m.WrappedKey, err = encrypt(kek, master)
+	return m, err
+}
+
+// unlockVault unwraps the master key with the given passphrase.
+// A new master key is created for vaults which have none yet.
+func (s *Store) unlockVault(passphrase string) error {
+	s.vaultLock.Lock()
+	defer s.vaultLock.Unlock()
+
+	// Get wrapped master key
+	var raw []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(vaultMetaBucket).Get([]byte(vaultMasterKeyName))
+		if v != nil {
+			raw = append(raw, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// No master key exists yet
+	if raw == nil {
+		master, err := randomBytes(vaultKeyLength)
+		if err != nil {
+			return err
+		}
+		err = s.update(func(tx *bolt.Tx) error {
+			return putMasterKey(tx, master, passphrase)
+		})
+		if err != nil {
+			return err
+		}
+		s.vaultKey = master
+		return nil
+	}
+
+	// Unwrap master key
+	m := &vaultMasterKey{}
+	if err = json.Unmarshal(raw, m); err != nil {
+		return err
+	}
+	kek, err := m.deriveWrappingKey(passphrase)
+	if err != nil {
+		return err
+	}
+	master, err := decrypt(kek, m.WrappedKey)
+	if err != nil {
+		return errVaultPassphrase
+	}
+	s.vaultKey = master
+	return nil
+}
+
+// putMasterKey wraps the given master key and stores it.
+func putMasterKey(tx *bolt.Tx, master []byte, passphrase string) error {
+	m, err := wrapMasterKey(master, passphrase)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(vaultMetaBucket).Put([]byte(vaultMasterKeyName), buf)
+}
+
+// reencryptVault decrypts all vault entries with the old key and
+// encrypts them with the new key inside the given transaction.
+func reencryptVault(tx *bolt.Tx, oldKey, newKey []byte) error {
+	b := tx.Bucket(vaultBucket)
+
+	// Bolt does not allow to modify a bucket while iterating it.
+	entries := make(map[string][]byte)
+	err := b.ForEach(func(k, v []byte) error {
+		plain, err := decrypt(oldKey, v)
+		if err != nil {
+			return err
+		}
+		entries[string(k)] = plain
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, plain := range entries {
+		enc, err := encrypt(newKey, plain)
+		if err != nil {
+			return err
+		}
+		if err = b.Put([]byte(k), enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VaultRotateKey generates a new master key and re-encrypts all
+// vault entries with it. All entries are re-encrypted in one transaction,
+// readers see either the old or the new state and are only blocked
+// for the duration of the transaction.
+func (s *Store) VaultRotateKey() error {
+	s.vaultLock.Lock()
+	defer s.vaultLock.Unlock()
+
+	if s.vaultKey == nil {
+		return errVaultLocked
+	}
+
+	master, err := randomBytes(vaultKeyLength)
+	if err != nil {
+		return err
+	}
+	err = s.update(func(tx *bolt.Tx) error {
+		if err := reencryptVault(tx, s.vaultKey, master); err != nil {
+			return err
+		}
+		return putMasterKey(tx, master, gaia.Cfg.VaultPassphrase)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.vaultKey = master
+	return nil
+}
+
+// encrypt encrypts the given data with AES-GCM.
+// The random nonce is prepended to the returned cipher text.
+func encrypt(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decrypt decrypts data which has been encrypted by encrypt.
+func decrypt(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errVaultCorrupted
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errVaultCorrupted
+	}
+
+	return plain, nil
+}
+
+// VaultPut encrypts the given value and stores it under the given key.
+// An existing entry will be overwritten.
+func (s *Store) VaultPut(key string, value []byte) error {
+	s.vaultLock.RLock()
+	defer s.vaultLock.RUnlock()
+
+	if s.vaultKey == nil {
+		return errVaultLocked
+	}
+
+	// Encrypt value
+	enc, err := encrypt(s.vaultKey, value)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(vaultBucket)
+
+		// Put entry
+		return b.Put([]byte(key), enc)
+	})
+}
+
+// VaultGet returns the decrypted value of the given key.
+// Returns nil if the key does not exist.
+func (s *Store) VaultGet(key string) ([]byte, error) {
+	s.vaultLock.RLock()
+	defer s.vaultLock.RUnlock()
+
+	if s.vaultKey == nil {
+		return nil, errVaultLocked
+	}
+
+	var value []byte
+	return value, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(vaultBucket)
+
+		// Lookup entry
+		enc := b.Get([]byte(key))
+		if enc == nil {
+			return nil
+		}
+
+		// Decrypt
+		var err error
+		value, err = decrypt(s.vaultKey, enc)
+		return err
+	})
+}
+
+// VaultKeys returns the sorted list of all keys stored in the vault.
+// Values are never returned by this function.
+func (s *Store) VaultKeys() ([]string, error) {
+	keys := []string{}
+
+	return keys, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(vaultBucket)
+
+		// Iterate all entries
+		err := b.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+		sort.Strings(keys)
+		return err
+	})
+}
+
+// VaultDelete deletes the given key from the vault.
+func (s *Store) VaultDelete(key string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(vaultBucket)
+
+		// Delete entry
+		return b.Delete([]byte(key))
+	})
+}
+
+// SecretGrantsPut stores the list of vault keys the given pipeline
+// is allowed to use. Existing grants will be overwritten.
+func (s *Store) SecretGrantsPut(pipelineID int, keys []string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(secretGrantBucket)
+
+		// Marshal keys
+		m, err := json.Marshal(keys)
+		if err != nil {
+			return err
+		}
+
+		// Put grants
+		return b.Put(itob(pipelineID), m)
+	})
+}
+
+// SecretGrantsGet returns the list of vault keys the given pipeline
+// is allowed to use.
+func (s *Store) SecretGrantsGet(pipelineID int) ([]string, error) {
+	keys := []string{}
+
+	return keys, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(secretGrantBucket)
+
+		// Lookup grants
+		v := b.Get(itob(pipelineID))
+		if v == nil {
+			return nil
+		}
+
+		// Unmarshal
+		return json.Unmarshal(v, &keys)
+	})
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/gaia-pipeline/gaia"
+)
+
+// WebhookDeliveryPut stores the given webhook delivery. New deliveries
+// get a unique id and the creation date.
+func (s *Store) WebhookDeliveryPut(d *gaia.WebhookDelivery) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(webhookDeliveryBucket)
+
+		// Generate ID for new deliveries.
+		if d.ID == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			d.ID = int(id)
+			d.Created = time.Now()
+		}
+
+		// Marshal delivery
+		m, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+
+		// Put delivery
+		return b.Put(itob(d.ID), m)
+	})
+}
+
+// WebhookDeliveryGet returns the webhook delivery with the given id.
+// Returns nil if the delivery does not exist.
+func (s *Store) WebhookDeliveryGet(id int) (*gaia.WebhookDelivery, error) {
+	var d *gaia.WebhookDelivery
+
+	return d, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(webhookDeliveryBucket)
+
+		v := b.Get(itob(id))
+		if v == nil {
+			return nil
+		}
+
+		// Unmarshal
+		d = &gaia.WebhookDelivery{}
+		return json.Unmarshal(v, d)
+	})
+}
+
+// WebhookDeliveryGetAll returns all webhook deliveries ordered by creation.
+func (s *Store) WebhookDeliveryGetAll() ([]gaia.WebhookDelivery, error) {
+	deliveries := []gaia.WebhookDelivery{}
+
+	return deliveries, s.db.View(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(webhookDeliveryBucket)
+
+		// Iterate all deliveries
+		return b.ForEach(func(k, v []byte) error {
+			// Unmarshal
+			d := gaia.WebhookDelivery{}
+			err := json.Unmarshal(v, &d)
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, d)
+			return nil
+		})
+	})
+}
+
+// WebhookDeliveryDelete deletes the webhook delivery with the given id.
+func (s *Store) WebhookDeliveryDelete(id int) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Get bucket
+		b := tx.Bucket(webhookDeliveryBucket)
+
+		// Delete delivery
+		return b.Delete(itob(id))
+	})
+}
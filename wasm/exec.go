@@ -0,0 +1,1276 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"runtime"
+)
+
+// Opcodes which are referenced by name. Numeric instructions are
+// documented in place.
+const (
+	opUnreachable  = 0x00
+	opNop          = 0x01
+	opBlock        = 0x02
+	opLoop         = 0x03
+	opIf           = 0x04
+	opElse         = 0x05
+	opEnd          = 0x0b
+	opBr           = 0x0c
+	opBrIf         = 0x0d
+	opBrTable      = 0x0e
+	opReturn       = 0x0f
+	opCall         = 0x10
+	opCallIndirect = 0x11
+	opDrop         = 0x1a
+	opSelect       = 0x1b
+	opSelectTyped  = 0x1c
+	opLocalGet     = 0x20
+	opLocalSet     = 0x21
+	opLocalTee     = 0x22
+	opGlobalGet    = 0x23
+	opGlobalSet    = 0x24
+	opI32Load      = 0x28
+	opI64Store32   = 0x3e
+	opMemorySize   = 0x3f
+	opMemoryGrow   = 0x40
+	opI32Const     = 0x41
+	opI64Const     = 0x42
+	opF32Const     = 0x43
+	opF64Const     = 0x44
+	opNumericFirst = 0x45
+	opNumericLast  = 0xc4
+	opPrefixed     = 0xfc
+
+	// Sub opcodes of the prefixed instructions.
+	opTruncSatLast = 0x07
+	opMemoryCopy   = 0x0a
+	opMemoryFill   = 0x0b
+)
+
+const (
+	// defaultMemoryPages limits the memory of instances which have no
+	// other limit configured to 16 MiB.
+	defaultMemoryPages = 256
+
+	// maxCallDepth limits the recursion of functions.
+	maxCallDepth = 10000
+
+	// interruptInterval is the number of calls and loop iterations
+	// after which the context of the instance is checked.
+	interruptInterval = 1024
+)
+
+// Trap is thrown when the execution of a function is aborted.
+type Trap struct {
+	Reason string
+}
+
+// Error returns the reason of the trap.
+func (t *Trap) Error() string {
+	return "wasm trap: " + t.Reason
+}
+
+// trap aborts the execution with the given reason.
+func trap(format string, args ...interface{}) {
+	panic(&Trap{Reason: fmt.Sprintf(format, args...)})
+}
+
+// HostFunc is a function which is imported by a module.
+// Returned errors abort the execution.
+type HostFunc struct {
+	Type FuncType
+	Call func(inst *Instance, args []uint64) ([]uint64, error)
+}
+
+// Config holds the environment of an instance.
+type Config struct {
+	// Imports are the functions the module may import by module
+	// name and function name, e.g. "gaia.log".
+	Imports map[string]HostFunc
+
+	// MaxMemoryPages limits the linear memory. Defaults to 16 MiB.
+	MaxMemoryPages uint32
+}
+
+// function is a function of an instance.
+type function struct {
+	typ    *FuncType
+	host   *HostFunc
+	code   *Code
+	blocks map[int]block
+}
+
+// block holds the positions of the else and end instructions of a block.
+// elseAt is negative for blocks without else.
+type block struct {
+	elseAt int
+	end    int
+}
+
+// label is the target of a branch.
+type label struct {
+	cont   int
+	arity  int
+	height int
+	loop   bool
+}
+
+// Instance represents an instantiated module.
+type Instance struct {
+	module   *Module
+	funcs    []function
+	table    []int64
+	memory   []byte
+	maxPages uint32
+	globals  []uint64
+	stack    []uint64
+	depth    int
+	steps    int
+	ctx      context.Context
+}
+
+// Instantiate creates an instance of the given module. Every import of
+// the module must be provided by the given configuration. The given
+// context aborts the execution of the instance.
+func Instantiate(ctx context.Context, m *Module, cfg *Config) (*Instance, error) {
+	inst := &Instance{module: m, ctx: ctx, maxPages: cfg.MaxMemoryPages}
+	if inst.maxPages == 0 {
+		inst.maxPages = defaultMemoryPages
+	}
+
+	for _, imp := range m.Imports {
+		host, ok := cfg.Imports[imp.Module+"."+imp.Name]
+		if !ok {
+			return nil, fmt.Errorf("import %s.%s is not available", imp.Module, imp.Name)
+		}
+		t, err := m.funcTypeByIndex(imp.Type)
+		if err != nil {
+			return nil, err
+		} else if !t.equal(&host.Type) {
+			return nil, fmt.Errorf("import %s.%s has signature %s instead of %s", imp.Module, imp.Name, t.String(), host.Type.String())
+		}
+		inst.funcs = append(inst.funcs, function{typ: t, host: &host})
+	}
+	for i := range m.Funcs {
+		t, err := m.funcType(uint32(len(m.Imports) + i))
+		if err != nil {
+			return nil, err
+		}
+		inst.funcs = append(inst.funcs, function{typ: t, code: &m.Codes[i]})
+	}
+
+	for _, g := range m.Globals {
+		v, err := constExpr(g.Init)
+		if err != nil {
+			return nil, err
+		}
+		inst.globals = append(inst.globals, v)
+	}
+
+	if m.Memory != nil {
+		pages := m.Memory.Min
+		if pages > inst.maxPages {
+			return nil, fmt.Errorf("module requires %d memory pages but only %d are allowed", pages, inst.maxPages)
+		}
+		if m.Memory.Max != nil && *m.Memory.Max < inst.maxPages {
+			inst.maxPages = *m.Memory.Max
+		}
+		inst.memory = make([]byte, int(pages)*pageSize)
+	} else {
+		inst.maxPages = 0
+	}
+
+	if m.Table != nil {
+		inst.table = make([]int64, m.Table.Min)
+		for i := range inst.table {
+			inst.table[i] = -1
+		}
+	}
+	for _, e := range m.Elements {
+		offset, err := constExpr(e.Offset)
+		if err != nil {
+			return nil, err
+		}
+		start := uint64(uint32(offset))
+		if start+uint64(len(e.Funcs)) > uint64(len(inst.table)) {
+			return nil, fmt.Errorf("element segment does not fit into table")
+		}
+		for i, f := range e.Funcs {
+			if int(f) >= len(inst.funcs) {
+				return nil, fmt.Errorf("%s: unknown function %d", errInvalidModule.Error(), f)
+			}
+			inst.table[start+uint64(i)] = int64(f)
+		}
+	}
+	for _, d := range m.Data {
+		offset, err := constExpr(d.Offset)
+		if err != nil {
+			return nil, err
+		}
+		start := uint64(uint32(offset))
+		if start+uint64(len(d.Bytes)) > uint64(len(inst.memory)) {
+			return nil, fmt.Errorf("data segment does not fit into memory")
+		}
+		copy(inst.memory[start:], d.Bytes)
+	}
+
+	if m.Start != nil {
+		if _, err := inst.invoke(*m.Start, nil); err != nil {
+			return nil, err
+		}
+	}
+	return inst, nil
+}
+
+// constExpr evaluates the given constant expression.
+func constExpr(expr []byte) (uint64, error) {
+	r := &reader{buf: expr}
+	op, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	switch op {
+	case opI32Const:
+		v, err = r.leb(32, true)
+		v = uint64(uint32(v))
+	case opI64Const:
+		v, err = r.leb(64, true)
+	case opF32Const:
+		var b []byte
+		b, err = r.bytes(4)
+		if err == nil {
+			v = uint64(binary.LittleEndian.Uint32(b))
+		}
+	case opF64Const:
+		var b []byte
+		b, err = r.bytes(8)
+		if err == nil {
+			v = binary.LittleEndian.Uint64(b)
+		}
+	default:
+		// Globals cannot be imported so global.get has no target
+		return 0, fmt.Errorf("%s: constant expression with opcode 0x%x", errUnsupported.Error(), op)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if op, err = r.byte(); err != nil || op != opEnd {
+		return 0, fmt.Errorf("%s: invalid constant expression", errInvalidModule.Error())
+	}
+	return v, nil
+}
+
+// Memory returns the linear memory of the instance.
+func (inst *Instance) Memory() []byte {
+	return inst.memory
+}
+
+// Read returns a copy of the given range of the memory. Traps if the
+// range is out of bounds. Should only be used by host functions.
+func (inst *Instance) Read(ptr, length uint32) []byte {
+	end := uint64(ptr) + uint64(length)
+	if end > uint64(len(inst.memory)) {
+		trap("out of bounds memory access")
+	}
+	b := make([]byte, length)
+	copy(b, inst.memory[ptr:end])
+	return b
+}
+
+// Write copies the given bytes into the memory at the given address.
+// Traps if the range is out of bounds. Should only be used by host
+// functions.
+func (inst *Instance) Write(ptr uint32, b []byte) {
+	if uint64(ptr)+uint64(len(b)) > uint64(len(inst.memory)) {
+		trap("out of bounds memory access")
+	}
+	copy(inst.memory[ptr:], b)
+}
+
+// Call calls the exported function with the given name with the given
+// arguments. Integers are passed as is and floats by their bits.
+func (inst *Instance) Call(name string, args ...uint64) ([]uint64, error) {
+	e, ok := inst.module.Exports[name]
+	if !ok || e.Kind != ExternalFunc {
+		return nil, fmt.Errorf("function %s is not exported", name)
+	}
+	return inst.invoke(e.Index, args)
+}
+
+// FuncType returns the signature of the exported function with the
+// given name.
+func (inst *Instance) FuncType(name string) (*FuncType, error) {
+	e, ok := inst.module.Exports[name]
+	if !ok || e.Kind != ExternalFunc {
+		return nil, fmt.Errorf("function %s is not exported", name)
+	}
+	return inst.module.funcType(e.Index)
+}
+
+// invoke calls the function with the given index. Traps are returned
+// as error.
+func (inst *Instance) invoke(index uint32, args []uint64) (results []uint64, err error) {
+	if int(index) >= len(inst.funcs) {
+		return nil, fmt.Errorf("%s: unknown function %d", errInvalidModule.Error(), index)
+	}
+	f := &inst.funcs[index]
+	if len(args) != len(f.typ.Params) {
+		return nil, fmt.Errorf("function expects %d arguments but got %d", len(f.typ.Params), len(args))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			// Modules are validated on decode, runtime errors are
+			// still turned into traps so a bug of the interpreter
+			// cannot take down the process
+			t, ok := r.(*Trap)
+			if rErr, isRuntime := r.(runtime.Error); isRuntime {
+				t, ok = &Trap{Reason: rErr.Error()}, true
+			}
+			if !ok {
+				panic(r)
+			}
+			inst.stack = inst.stack[:0]
+			inst.depth = 0
+			err = t
+		}
+	}()
+
+	inst.stack = append(inst.stack[:0], args...)
+	inst.call(index)
+	results = make([]uint64, len(f.typ.Results))
+	copy(results, inst.stack)
+	inst.stack = inst.stack[:0]
+	return results, nil
+}
+
+// call calls the function with the given index. The arguments are
+// taken from the stack and the results are pushed to the stack.
+func (inst *Instance) call(index uint32) {
+	f := &inst.funcs[index]
+	params := len(f.typ.Params)
+	base := len(inst.stack) - params
+
+	inst.checkInterrupt()
+	if f.host != nil {
+		args := make([]uint64, params)
+		copy(args, inst.stack[base:])
+		inst.stack = inst.stack[:base]
+		results, err := f.host.Call(inst, args)
+		if err != nil {
+			trap("%s", err.Error())
+		} else if len(results) != len(f.typ.Results) {
+			trap("host function returned %d instead of %d results", len(results), len(f.typ.Results))
+		}
+		inst.stack = append(inst.stack, results...)
+		return
+	}
+
+	if f.blocks == nil {
+		blocks, err := scanBlocks(inst.module, f.code.Body)
+		if err != nil {
+			trap("%s", err.Error())
+		}
+		f.blocks = blocks
+	}
+
+	inst.depth++
+	if inst.depth > maxCallDepth {
+		trap("call stack exhausted")
+	}
+	locals := make([]uint64, params+len(f.code.Locals))
+	copy(locals, inst.stack[base:])
+	inst.stack = inst.stack[:base]
+	inst.execute(f, locals)
+	inst.depth--
+}
+
+// checkInterrupt traps if the context of the instance is done.
+func (inst *Instance) checkInterrupt() {
+	inst.steps++
+	if inst.steps%interruptInterval != 0 || inst.ctx == nil {
+		return
+	}
+	select {
+	case <-inst.ctx.Done():
+		trap("execution interrupted: %s", inst.ctx.Err().Error())
+	default:
+	}
+}
+
+// scanBlocks finds the else and end instructions of all blocks of the
+// given function body. Unsupported instructions are rejected.
+func scanBlocks(m *Module, body []byte) (map[int]block, error) {
+	blocks := map[int]block{}
+	open := []int{}
+	r := &reader{buf: body}
+	for r.pos < len(body) {
+		pos := r.pos
+		op, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opBlock, opLoop, opIf:
+			if _, _, err = blockType(m, r); err != nil {
+				return nil, err
+			}
+			open = append(open, pos)
+			blocks[pos] = block{elseAt: -1}
+		case opElse:
+			if len(open) == 0 {
+				return nil, fmt.Errorf("%s: else outside of block", errInvalidModule.Error())
+			}
+			b := blocks[open[len(open)-1]]
+			b.elseAt = pos
+			blocks[open[len(open)-1]] = b
+		case opEnd:
+			if len(open) == 0 {
+				if r.pos != len(body) {
+					return nil, fmt.Errorf("%s: instructions after end of function", errInvalidModule.Error())
+				}
+				return blocks, nil
+			}
+			b := blocks[open[len(open)-1]]
+			b.end = pos
+			blocks[open[len(open)-1]] = b
+			open = open[:len(open)-1]
+		default:
+			if err = skipImmediates(op, r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: missing end of function", errInvalidModule.Error())
+}
+
+// skipImmediates skips the immediates of the given instruction.
+func skipImmediates(op byte, r *reader) error {
+	var err error
+	switch {
+	case op == opUnreachable || op == opNop || op == opReturn || op == opDrop || op == opSelect:
+	case op == opBr || op == opBrIf || op == opCall || (op >= opLocalGet && op <= opGlobalSet):
+		_, err = r.u32()
+	case op == opBrTable:
+		err = r.vec(func() error {
+			_, err := r.u32()
+			return err
+		})
+		if err == nil {
+			_, err = r.u32()
+		}
+	case op == opCallIndirect:
+		if _, err = r.u32(); err == nil {
+			_, err = r.u32()
+		}
+	case op == opSelectTyped:
+		_, err = r.valueTypes()
+	case op >= opI32Load && op <= opI64Store32:
+		if _, err = r.u32(); err == nil {
+			_, err = r.u32()
+		}
+	case op == opMemorySize || op == opMemoryGrow:
+		_, err = r.byte()
+	case op == opI32Const:
+		_, err = r.leb(32, true)
+	case op == opI64Const:
+		_, err = r.leb(64, true)
+	case op == opF32Const:
+		_, err = r.bytes(4)
+	case op == opF64Const:
+		_, err = r.bytes(8)
+	case op >= opNumericFirst && op <= opNumericLast:
+	case op == opPrefixed:
+		var sub uint32
+		if sub, err = r.u32(); err != nil {
+			return err
+		}
+		switch {
+		case sub <= opTruncSatLast:
+		case sub == opMemoryCopy:
+			_, err = r.bytes(2)
+		case sub == opMemoryFill:
+			_, err = r.byte()
+		default:
+			return fmt.Errorf("%s: opcode 0xfc 0x%x", errUnsupported.Error(), sub)
+		}
+	default:
+		return fmt.Errorf("%s: opcode 0x%x", errUnsupported.Error(), op)
+	}
+	return err
+}
+
+// blockType reads the type of a block and returns the number of its
+// parameters and results.
+func blockType(m *Module, r *reader) (int, int, error) {
+	if r.pos >= len(r.buf) {
+		return 0, 0, fmt.Errorf("%s: missing block type", errInvalidModule.Error())
+	}
+	switch ValueType(r.buf[r.pos]) {
+	case empty:
+		r.pos++
+		return 0, 0, nil
+	case I32, I64, F32, F64:
+		r.pos++
+		return 0, 1, nil
+	}
+	index, err := r.leb(33, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	t, err := m.funcTypeByIndex(uint32(index))
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(t.Params), len(t.Results), nil
+}
+
+// immediate reads an unsigned immediate of an instruction.
+func (r *reader) immediate() uint32 {
+	v, err := r.u32()
+	if err != nil {
+		trap("%s", err.Error())
+	}
+	return v
+}
+
+func (inst *Instance) push(v uint64) {
+	inst.stack = append(inst.stack, v)
+}
+
+func (inst *Instance) pop() uint64 {
+	v := inst.stack[len(inst.stack)-1]
+	inst.stack = inst.stack[:len(inst.stack)-1]
+	return v
+}
+
+func (inst *Instance) pushBool(b bool) {
+	if b {
+		inst.push(1)
+	} else {
+		inst.push(0)
+	}
+}
+
+func (inst *Instance) pushF32(f float32) {
+	inst.push(uint64(math.Float32bits(f)))
+}
+
+func (inst *Instance) pushF64(f float64) {
+	inst.push(math.Float64bits(f))
+}
+
+func (inst *Instance) popF32() float32 {
+	return math.Float32frombits(uint32(inst.pop()))
+}
+
+func (inst *Instance) popF64() float64 {
+	return math.Float64frombits(inst.pop())
+}
+
+// branch unwinds the stack to the label with the given depth and
+// returns the position to continue at.
+func (inst *Instance) branch(labels *[]label, depth uint32) int {
+	if int(depth) >= len(*labels) {
+		trap("invalid branch depth %d", depth)
+	}
+	l := (*labels)[len(*labels)-1-int(depth)]
+	copy(inst.stack[l.height:], inst.stack[len(inst.stack)-l.arity:])
+	inst.stack = inst.stack[:l.height+l.arity]
+	if l.loop {
+		*labels = (*labels)[:len(*labels)-int(depth)]
+		inst.checkInterrupt()
+	} else {
+		*labels = (*labels)[:len(*labels)-1-int(depth)]
+	}
+	return l.cont
+}
+
+// execute executes the body of the given function with the given locals.
+func (inst *Instance) execute(f *function, locals []uint64) {
+	body := f.code.Body
+	r := &reader{buf: body}
+
+	// Branching to the label of the function returns
+	labels := []label{{cont: len(body), arity: len(f.typ.Results), height: len(inst.stack)}}
+	for len(labels) > 0 {
+		op := body[r.pos]
+		r.pos++
+		switch op {
+		case opUnreachable:
+			trap("unreachable")
+		case opNop:
+		case opBlock, opLoop, opIf:
+			b := f.blocks[r.pos-1]
+			params, results, err := blockType(inst.module, r)
+			if err != nil {
+				trap("%s", err.Error())
+			}
+			switch op {
+			case opBlock:
+				labels = append(labels, label{cont: b.end + 1, arity: results, height: len(inst.stack) - params})
+			case opLoop:
+				labels = append(labels, label{cont: r.pos, arity: params, height: len(inst.stack) - params, loop: true})
+			case opIf:
+				cond := uint32(inst.pop())
+				labels = append(labels, label{cont: b.end + 1, arity: results, height: len(inst.stack) - params})
+				if cond == 0 {
+					if b.elseAt >= 0 {
+						r.pos = b.elseAt + 1
+					} else {
+						r.pos = b.end + 1
+						labels = labels[:len(labels)-1]
+					}
+				}
+			}
+		case opElse:
+			// The then branch is done
+			r.pos = labels[len(labels)-1].cont
+			labels = labels[:len(labels)-1]
+		case opEnd:
+			labels = labels[:len(labels)-1]
+		case opBr:
+			r.pos = inst.branch(&labels, r.immediate())
+		case opBrIf:
+			depth := r.immediate()
+			if uint32(inst.pop()) != 0 {
+				r.pos = inst.branch(&labels, depth)
+			}
+		case opBrTable:
+			n := r.immediate()
+			targets := make([]uint32, n+1)
+			for i := range targets {
+				targets[i] = r.immediate()
+			}
+			i := uint32(inst.pop())
+			if i > n {
+				i = n
+			}
+			r.pos = inst.branch(&labels, targets[i])
+		case opReturn:
+			r.pos = inst.branch(&labels, uint32(len(labels)-1))
+		case opCall:
+			index := r.immediate()
+			if int(index) >= len(inst.funcs) {
+				trap("unknown function %d", index)
+			}
+			inst.call(index)
+		case opCallIndirect:
+			t, err := inst.module.funcTypeByIndex(r.immediate())
+			if err != nil {
+				trap("%s", err.Error())
+			}
+			r.immediate()
+			i := uint32(inst.pop())
+			if uint64(i) >= uint64(len(inst.table)) {
+				trap("undefined element")
+			}
+			index := inst.table[i]
+			if index < 0 {
+				trap("uninitialized element")
+			} else if !inst.funcs[index].typ.equal(t) {
+				trap("indirect call type mismatch")
+			}
+			inst.call(uint32(index))
+		case opDrop:
+			inst.pop()
+		case opSelect, opSelectTyped:
+			if op == opSelectTyped {
+				r.valueTypes()
+			}
+			cond := uint32(inst.pop())
+			b := inst.pop()
+			a := inst.pop()
+			if cond != 0 {
+				inst.push(a)
+			} else {
+				inst.push(b)
+			}
+		case opLocalGet:
+			inst.push(locals[r.immediate()])
+		case opLocalSet:
+			locals[r.immediate()] = inst.pop()
+		case opLocalTee:
+			locals[r.immediate()] = inst.stack[len(inst.stack)-1]
+		case opGlobalGet:
+			inst.push(inst.globals[r.immediate()])
+		case opGlobalSet:
+			inst.globals[r.immediate()] = inst.pop()
+		case opMemorySize:
+			r.pos++
+			inst.push(uint64(len(inst.memory) / pageSize))
+		case opMemoryGrow:
+			r.pos++
+			delta := uint32(inst.pop())
+			pages := uint32(len(inst.memory) / pageSize)
+			if uint64(pages)+uint64(delta) > uint64(inst.maxPages) {
+				inst.push(uint64(math.MaxUint32))
+				break
+			}
+			inst.memory = append(inst.memory, make([]byte, int(delta)*pageSize)...)
+			inst.push(uint64(pages))
+		case opI32Const:
+			v, _ := r.leb(32, true)
+			inst.push(uint64(uint32(v)))
+		case opI64Const:
+			v, _ := r.leb(64, true)
+			inst.push(v)
+		case opF32Const:
+			b, _ := r.bytes(4)
+			inst.push(uint64(binary.LittleEndian.Uint32(b)))
+		case opF64Const:
+			b, _ := r.bytes(8)
+			inst.push(binary.LittleEndian.Uint64(b))
+		case opPrefixed:
+			inst.prefixed(r.immediate(), r)
+		default:
+			if op >= opI32Load && op <= opI64Store32 {
+				inst.memoryAccess(op, r)
+			} else {
+				inst.numeric(op)
+			}
+		}
+	}
+}
+
+// address returns the effective address of a memory access with the
+// given size. Traps if the access is out of bounds.
+func (inst *Instance) address(r *reader, size uint64) uint64 {
+	r.immediate()
+	offset := uint64(r.immediate())
+	ea := uint64(uint32(inst.pop())) + offset
+	if ea+size > uint64(len(inst.memory)) {
+		trap("out of bounds memory access")
+	}
+	return ea
+}
+
+// memoryAccess executes the given load or store instruction.
+func (inst *Instance) memoryAccess(op byte, r *reader) {
+	mem := inst.memory
+	switch op {
+	case 0x28, 0x2a: // i32.load, f32.load
+		inst.push(uint64(binary.LittleEndian.Uint32(mem[inst.address(r, 4):])))
+	case 0x29, 0x2b: // i64.load, f64.load
+		inst.push(binary.LittleEndian.Uint64(mem[inst.address(r, 8):]))
+	case 0x2c: // i32.load8_s
+		inst.push(uint64(uint32(int32(int8(mem[inst.address(r, 1)])))))
+	case 0x2d, 0x31: // i32.load8_u, i64.load8_u
+		inst.push(uint64(mem[inst.address(r, 1)]))
+	case 0x2e: // i32.load16_s
+		inst.push(uint64(uint32(int32(int16(binary.LittleEndian.Uint16(mem[inst.address(r, 2):]))))))
+	case 0x2f, 0x33: // i32.load16_u, i64.load16_u
+		inst.push(uint64(binary.LittleEndian.Uint16(mem[inst.address(r, 2):])))
+	case 0x30: // i64.load8_s
+		inst.push(uint64(int64(int8(mem[inst.address(r, 1)]))))
+	case 0x32: // i64.load16_s
+		inst.push(uint64(int64(int16(binary.LittleEndian.Uint16(mem[inst.address(r, 2):])))))
+	case 0x34: // i64.load32_s
+		inst.push(uint64(int64(int32(binary.LittleEndian.Uint32(mem[inst.address(r, 4):])))))
+	case 0x35: // i64.load32_u
+		inst.push(uint64(binary.LittleEndian.Uint32(mem[inst.address(r, 4):])))
+	default:
+		// Stores take the value from the top of the stack
+		v := inst.pop()
+		switch op {
+		case 0x36, 0x38, 0x3e: // i32.store, f32.store, i64.store32
+			binary.LittleEndian.PutUint32(mem[inst.address(r, 4):], uint32(v))
+		case 0x37, 0x39: // i64.store, f64.store
+			binary.LittleEndian.PutUint64(mem[inst.address(r, 8):], v)
+		case 0x3a, 0x3c: // i32.store8, i64.store8
+			mem[inst.address(r, 1)] = byte(v)
+		case 0x3b, 0x3d: // i32.store16, i64.store16
+			binary.LittleEndian.PutUint16(mem[inst.address(r, 2):], uint16(v))
+		}
+	}
+}
+
+// prefixed executes the given prefixed instruction.
+func (inst *Instance) prefixed(sub uint32, r *reader) {
+	switch sub {
+	case 0x00: // i32.trunc_sat_f32_s
+		inst.push(uint64(uint32(satS(float64(inst.popF32()), math.MinInt32, math.MaxInt32))))
+	case 0x01: // i32.trunc_sat_f32_u
+		inst.push(satU(float64(inst.popF32()), math.MaxUint32))
+	case 0x02: // i32.trunc_sat_f64_s
+		inst.push(uint64(uint32(satS(inst.popF64(), math.MinInt32, math.MaxInt32))))
+	case 0x03: // i32.trunc_sat_f64_u
+		inst.push(satU(inst.popF64(), math.MaxUint32))
+	case 0x04: // i64.trunc_sat_f32_s
+		inst.push(uint64(satS(float64(inst.popF32()), math.MinInt64, math.MaxInt64)))
+	case 0x05: // i64.trunc_sat_f32_u
+		inst.push(satU(float64(inst.popF32()), math.MaxUint64))
+	case 0x06: // i64.trunc_sat_f64_s
+		inst.push(uint64(satS(inst.popF64(), math.MinInt64, math.MaxInt64)))
+	case 0x07: // i64.trunc_sat_f64_u
+		inst.push(satU(inst.popF64(), math.MaxUint64))
+	case opMemoryCopy:
+		r.pos += 2
+		n, src, dst := uint64(uint32(inst.pop())), uint64(uint32(inst.pop())), uint64(uint32(inst.pop()))
+		if src+n > uint64(len(inst.memory)) || dst+n > uint64(len(inst.memory)) {
+			trap("out of bounds memory access")
+		}
+		copy(inst.memory[dst:dst+n], inst.memory[src:src+n])
+	case opMemoryFill:
+		r.pos++
+		n, v, dst := uint64(uint32(inst.pop())), byte(inst.pop()), uint64(uint32(inst.pop()))
+		if dst+n > uint64(len(inst.memory)) {
+			trap("out of bounds memory access")
+		}
+		for i := dst; i < dst+n; i++ {
+			inst.memory[i] = v
+		}
+	default:
+		trap("unsupported opcode 0xfc 0x%x", sub)
+	}
+}
+
+// numeric executes the given numeric instruction.
+func (inst *Instance) numeric(op byte) {
+	switch {
+	case op == 0x45: // i32.eqz
+		inst.pushBool(uint32(inst.pop()) == 0)
+	case op >= 0x46 && op <= 0x4f:
+		b, a := uint32(inst.pop()), uint32(inst.pop())
+		inst.pushBool(compareI32(op, a, b))
+	case op == 0x50: // i64.eqz
+		inst.pushBool(inst.pop() == 0)
+	case op >= 0x51 && op <= 0x5a:
+		b, a := inst.pop(), inst.pop()
+		inst.pushBool(compareI64(op, a, b))
+	case op >= 0x5b && op <= 0x60:
+		b, a := inst.popF32(), inst.popF32()
+		inst.pushBool(compareFloat(op-0x5b, float64(a), float64(b)))
+	case op >= 0x61 && op <= 0x66:
+		b, a := inst.popF64(), inst.popF64()
+		inst.pushBool(compareFloat(op-0x61, a, b))
+	case op >= 0x67 && op <= 0x69:
+		a := uint32(inst.pop())
+		switch op {
+		case 0x67: // i32.clz
+			inst.push(uint64(bits.LeadingZeros32(a)))
+		case 0x68: // i32.ctz
+			inst.push(uint64(bits.TrailingZeros32(a)))
+		case 0x69: // i32.popcnt
+			inst.push(uint64(bits.OnesCount32(a)))
+		}
+	case op >= 0x6a && op <= 0x78:
+		b, a := uint32(inst.pop()), uint32(inst.pop())
+		inst.push(uint64(binaryI32(op, a, b)))
+	case op >= 0x79 && op <= 0x7b:
+		a := inst.pop()
+		switch op {
+		case 0x79: // i64.clz
+			inst.push(uint64(bits.LeadingZeros64(a)))
+		case 0x7a: // i64.ctz
+			inst.push(uint64(bits.TrailingZeros64(a)))
+		case 0x7b: // i64.popcnt
+			inst.push(uint64(bits.OnesCount64(a)))
+		}
+	case op >= 0x7c && op <= 0x8a:
+		b, a := inst.pop(), inst.pop()
+		inst.push(binaryI64(op, a, b))
+	case op >= 0x8b && op <= 0x91:
+		inst.push(uint64(unaryF32(op, uint32(inst.pop()))))
+	case op >= 0x92 && op <= 0x98:
+		b, a := uint32(inst.pop()), uint32(inst.pop())
+		inst.push(uint64(binaryF32(op, a, b)))
+	case op >= 0x99 && op <= 0x9f:
+		inst.push(unaryF64(op, inst.pop()))
+	case op >= 0xa0 && op <= 0xa6:
+		b, a := inst.pop(), inst.pop()
+		inst.push(binaryF64(op, a, b))
+	default:
+		inst.convert(op)
+	}
+}
+
+// compareI32 executes the given i32 comparison.
+func compareI32(op byte, a, b uint32) bool {
+	switch op {
+	case 0x46: // i32.eq
+		return a == b
+	case 0x47: // i32.ne
+		return a != b
+	case 0x48: // i32.lt_s
+		return int32(a) < int32(b)
+	case 0x49: // i32.lt_u
+		return a < b
+	case 0x4a: // i32.gt_s
+		return int32(a) > int32(b)
+	case 0x4b: // i32.gt_u
+		return a > b
+	case 0x4c: // i32.le_s
+		return int32(a) <= int32(b)
+	case 0x4d: // i32.le_u
+		return a <= b
+	case 0x4e: // i32.ge_s
+		return int32(a) >= int32(b)
+	}
+	return a >= b // i32.ge_u
+}
+
+// compareI64 executes the given i64 comparison.
+func compareI64(op byte, a, b uint64) bool {
+	switch op {
+	case 0x51: // i64.eq
+		return a == b
+	case 0x52: // i64.ne
+		return a != b
+	case 0x53: // i64.lt_s
+		return int64(a) < int64(b)
+	case 0x54: // i64.lt_u
+		return a < b
+	case 0x55: // i64.gt_s
+		return int64(a) > int64(b)
+	case 0x56: // i64.gt_u
+		return a > b
+	case 0x57: // i64.le_s
+		return int64(a) <= int64(b)
+	case 0x58: // i64.le_u
+		return a <= b
+	case 0x59: // i64.ge_s
+		return int64(a) >= int64(b)
+	}
+	return a >= b // i64.ge_u
+}
+
+// compareFloat executes the float comparison with the given offset
+// in the order eq, ne, lt, gt, le and ge.
+func compareFloat(op byte, a, b float64) bool {
+	switch op {
+	case 0:
+		return a == b
+	case 1:
+		return a != b
+	case 2:
+		return a < b
+	case 3:
+		return a > b
+	case 4:
+		return a <= b
+	}
+	return a >= b
+}
+
+// binaryI32 executes the given binary i32 instruction.
+func binaryI32(op byte, a, b uint32) uint32 {
+	switch op {
+	case 0x6a: // i32.add
+		return a + b
+	case 0x6b: // i32.sub
+		return a - b
+	case 0x6c: // i32.mul
+		return a * b
+	case 0x6d: // i32.div_s
+		if b == 0 {
+			trap("integer divide by zero")
+		} else if int32(a) == math.MinInt32 && int32(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint32(int32(a) / int32(b))
+	case 0x6e: // i32.div_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case 0x6f: // i32.rem_s
+		if b == 0 {
+			trap("integer divide by zero")
+		} else if int32(b) == -1 {
+			return 0
+		}
+		return uint32(int32(a) % int32(b))
+	case 0x70: // i32.rem_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case 0x71: // i32.and
+		return a & b
+	case 0x72: // i32.or
+		return a | b
+	case 0x73: // i32.xor
+		return a ^ b
+	case 0x74: // i32.shl
+		return a << (b & 31)
+	case 0x75: // i32.shr_s
+		return uint32(int32(a) >> (b & 31))
+	case 0x76: // i32.shr_u
+		return a >> (b & 31)
+	case 0x77: // i32.rotl
+		return bits.RotateLeft32(a, int(b&31))
+	}
+	return bits.RotateLeft32(a, -int(b&31)) // i32.rotr
+}
+
+// binaryI64 executes the given binary i64 instruction.
+func binaryI64(op byte, a, b uint64) uint64 {
+	switch op {
+	case 0x7c: // i64.add
+		return a + b
+	case 0x7d: // i64.sub
+		return a - b
+	case 0x7e: // i64.mul
+		return a * b
+	case 0x7f: // i64.div_s
+		if b == 0 {
+			trap("integer divide by zero")
+		} else if int64(a) == math.MinInt64 && int64(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(int64(a) / int64(b))
+	case 0x80: // i64.div_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case 0x81: // i64.rem_s
+		if b == 0 {
+			trap("integer divide by zero")
+		} else if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case 0x82: // i64.rem_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case 0x83: // i64.and
+		return a & b
+	case 0x84: // i64.or
+		return a | b
+	case 0x85: // i64.xor
+		return a ^ b
+	case 0x86: // i64.shl
+		return a << (b & 63)
+	case 0x87: // i64.shr_s
+		return uint64(int64(a) >> (b & 63))
+	case 0x88: // i64.shr_u
+		return a >> (b & 63)
+	case 0x89: // i64.rotl
+		return bits.RotateLeft64(a, int(b&63))
+	}
+	return bits.RotateLeft64(a, -int(b&63)) // i64.rotr
+}
+
+// unaryF32 executes the given unary f32 instruction on the given bits.
+func unaryF32(op byte, a uint32) uint32 {
+	switch op {
+	case 0x8b: // f32.abs
+		return a &^ (1 << 31)
+	case 0x8c: // f32.neg
+		return a ^ (1 << 31)
+	}
+	f := float64(math.Float32frombits(a))
+	switch op {
+	case 0x8d: // f32.ceil
+		f = math.Ceil(f)
+	case 0x8e: // f32.floor
+		f = math.Floor(f)
+	case 0x8f: // f32.trunc
+		f = math.Trunc(f)
+	case 0x90: // f32.nearest
+		f = math.RoundToEven(f)
+	case 0x91: // f32.sqrt
+		f = math.Sqrt(f)
+	}
+	return math.Float32bits(float32(f))
+}
+
+// binaryF32 executes the given binary f32 instruction on the given bits.
+func binaryF32(op byte, a, b uint32) uint32 {
+	if op == 0x98 { // f32.copysign
+		return a&^(1<<31) | b&(1<<31)
+	}
+	x, y := math.Float32frombits(a), math.Float32frombits(b)
+	var z float32
+	switch op {
+	case 0x92: // f32.add
+		z = x + y
+	case 0x93: // f32.sub
+		z = x - y
+	case 0x94: // f32.mul
+		z = x * y
+	case 0x95: // f32.div
+		z = x / y
+	case 0x96: // f32.min
+		z = float32(math.Min(float64(x), float64(y)))
+	case 0x97: // f32.max
+		z = float32(math.Max(float64(x), float64(y)))
+	}
+	return math.Float32bits(z)
+}
+
+// unaryF64 executes the given unary f64 instruction on the given bits.
+func unaryF64(op byte, a uint64) uint64 {
+	switch op {
+	case 0x99: // f64.abs
+		return a &^ (1 << 63)
+	case 0x9a: // f64.neg
+		return a ^ (1 << 63)
+	}
+	f := math.Float64frombits(a)
+	switch op {
+	case 0x9b: // f64.ceil
+		f = math.Ceil(f)
+	case 0x9c: // f64.floor
+		f = math.Floor(f)
+	case 0x9d: // f64.trunc
+		f = math.Trunc(f)
+	case 0x9e: // f64.nearest
+		f = math.RoundToEven(f)
+	case 0x9f: // f64.sqrt
+		f = math.Sqrt(f)
+	}
+	return math.Float64bits(f)
+}
+
+// binaryF64 executes the given binary f64 instruction on the given bits.
+func binaryF64(op byte, a, b uint64) uint64 {
+	if op == 0xa6 { // f64.copysign
+		return a&^(1<<63) | b&(1<<63)
+	}
+	x, y := math.Float64frombits(a), math.Float64frombits(b)
+	var z float64
+	switch op {
+	case 0xa0: // f64.add
+		z = x + y
+	case 0xa1: // f64.sub
+		z = x - y
+	case 0xa2: // f64.mul
+		z = x * y
+	case 0xa3: // f64.div
+		z = x / y
+	case 0xa4: // f64.min
+		z = math.Min(x, y)
+	case 0xa5: // f64.max
+		z = math.Max(x, y)
+	}
+	return math.Float64bits(z)
+}
+
+// convert executes the given conversion instruction.
+func (inst *Instance) convert(op byte) {
+	switch op {
+	case 0xa7: // i32.wrap_i64
+		inst.push(uint64(uint32(inst.pop())))
+	case 0xa8: // i32.trunc_f32_s
+		inst.push(uint64(uint32(truncS(float64(inst.popF32()), -2147483649, 2147483648))))
+	case 0xa9: // i32.trunc_f32_u
+		inst.push(truncU(float64(inst.popF32()), 4294967296))
+	case 0xaa: // i32.trunc_f64_s
+		inst.push(uint64(uint32(truncS(inst.popF64(), -2147483649, 2147483648))))
+	case 0xab: // i32.trunc_f64_u
+		inst.push(truncU(inst.popF64(), 4294967296))
+	case 0xac: // i64.extend_i32_s
+		inst.push(uint64(int64(int32(inst.pop()))))
+	case 0xad: // i64.extend_i32_u
+		inst.push(uint64(uint32(inst.pop())))
+	case 0xae: // i64.trunc_f32_s
+		inst.push(uint64(truncS(float64(inst.popF32()), -9223372036854777856, 9223372036854775808)))
+	case 0xaf: // i64.trunc_f32_u
+		inst.push(truncU(float64(inst.popF32()), 18446744073709551616))
+	case 0xb0: // i64.trunc_f64_s
+		inst.push(uint64(truncS(inst.popF64(), -9223372036854777856, 9223372036854775808)))
+	case 0xb1: // i64.trunc_f64_u
+		inst.push(truncU(inst.popF64(), 18446744073709551616))
+	case 0xb2: // f32.convert_i32_s
+		inst.pushF32(float32(int32(inst.pop())))
+	case 0xb3: // f32.convert_i32_u
+		inst.pushF32(float32(uint32(inst.pop())))
+	case 0xb4: // f32.convert_i64_s
+		inst.pushF32(float32(int64(inst.pop())))
+	case 0xb5: // f32.convert_i64_u
+		inst.pushF32(float32(inst.pop()))
+	case 0xb6: // f32.demote_f64
+		inst.pushF32(float32(inst.popF64()))
+	case 0xb7: // f64.convert_i32_s
+		inst.pushF64(float64(int32(inst.pop())))
+	case 0xb8: // f64.convert_i32_u
+		inst.pushF64(float64(uint32(inst.pop())))
+	case 0xb9: // f64.convert_i64_s
+		inst.pushF64(float64(int64(inst.pop())))
+	case 0xba: // f64.convert_i64_u
+		inst.pushF64(float64(inst.pop()))
+	case 0xbb: // f64.promote_f32
+		inst.pushF64(float64(inst.popF32()))
+	case 0xbc, 0xbd, 0xbe, 0xbf:
+		// Reinterpretations keep the bits
+	case 0xc0: // i32.extend8_s
+		inst.push(uint64(uint32(int32(int8(inst.pop())))))
+	case 0xc1: // i32.extend16_s
+		inst.push(uint64(uint32(int32(int16(inst.pop())))))
+	case 0xc2: // i64.extend8_s
+		inst.push(uint64(int64(int8(inst.pop()))))
+	case 0xc3: // i64.extend16_s
+		inst.push(uint64(int64(int16(inst.pop()))))
+	case 0xc4: // i64.extend32_s
+		inst.push(uint64(int64(int32(inst.pop()))))
+	default:
+		trap("unsupported opcode 0x%x", op)
+	}
+}
+
+// truncS truncates the given float to a signed integer. Traps if the
+// value is not between the given exclusive bounds.
+func truncS(f, min, max float64) int64 {
+	if math.IsNaN(f) {
+		trap("invalid conversion to integer")
+	} else if f <= min || f >= max {
+		trap("integer overflow")
+	}
+	return int64(f)
+}
+
+// truncU truncates the given float to an unsigned integer. Traps if the
+// value is not between -1 and the given bound.
+func truncU(f, max float64) uint64 {
+	if math.IsNaN(f) {
+		trap("invalid conversion to integer")
+	} else if f <= -1 || f >= max {
+		trap("integer overflow")
+	}
+	return floatToUint64(math.Trunc(f))
+}
+
+// satS truncates the given float to a signed integer which saturates at
+// the given bounds.
+func satS(f float64, min, max int64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f <= float64(min):
+		return min
+	case f >= float64(max):
+		return max
+	}
+	return int64(f)
+}
+
+// satU truncates the given float to an unsigned integer which saturates
+// at zero and the given bound.
+func satU(f float64, max uint64) uint64 {
+	switch {
+	case math.IsNaN(f) || f <= 0:
+		return 0
+	case f >= float64(max):
+		return max
+	}
+	return floatToUint64(math.Trunc(f))
+}
+
+// floatToUint64 converts the given non-negative integral float which
+// is below 2^64.
+func floatToUint64(f float64) uint64 {
+	if f >= 9223372036854775808 {
+		return uint64(int64(f-9223372036854775808)) + 1<<63
+	}
+	return uint64(int64(f))
+}
@@ -0,0 +1,277 @@
+package wasm
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// moduleBuilder assembles binary modules for tests. Every function
+// gets its own type.
+type moduleBuilder struct {
+	types   [][]byte
+	imports [][]byte
+	funcs   [][]byte
+	exports [][]byte
+	codes   [][]byte
+	data    [][]byte
+	custom  [][]byte
+	table   []byte
+	memory  []byte
+}
+
+func leb(v uint32) []byte {
+	b := []byte{}
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func vector(items [][]byte) []byte {
+	b := leb(uint32(len(items)))
+	for _, i := range items {
+		b = append(b, i...)
+	}
+	return b
+}
+
+func name(s string) []byte {
+	return append(leb(uint32(len(s))), s...)
+}
+
+func (b *moduleBuilder) addType(params, results []ValueType) uint32 {
+	t := append([]byte{0x60}, append(leb(uint32(len(params))), valueTypeBytes(params)...)...)
+	t = append(t, append(leb(uint32(len(results))), valueTypeBytes(results)...)...)
+	b.types = append(b.types, t)
+	return uint32(len(b.types) - 1)
+}
+
+func (b *moduleBuilder) addImport(module, field string, params, results []ValueType) uint32 {
+	t := b.addType(params, results)
+	imp := append(append(name(module), name(field)...), ExternalFunc)
+	b.imports = append(b.imports, append(imp, leb(t)...))
+	return uint32(len(b.imports) - 1)
+}
+
+// addFunc adds a function with the given locals and returns its index.
+func (b *moduleBuilder) addFunc(export string, params, results, locals []ValueType, body ...byte) uint32 {
+	index := uint32(len(b.imports) + len(b.funcs))
+	b.funcs = append(b.funcs, leb(b.addType(params, results)))
+	code := leb(uint32(len(locals)))
+	for _, l := range locals {
+		code = append(code, 0x01, byte(l))
+	}
+	code = append(code, body...)
+	b.codes = append(b.codes, append(leb(uint32(len(code))), code...))
+	if export != "" {
+		b.exports = append(b.exports, append(append(name(export), ExternalFunc), leb(index)...))
+	}
+	return index
+}
+
+func (b *moduleBuilder) bytes() []byte {
+	raw := []byte("\x00asm\x01\x00\x00\x00")
+	section := func(id byte, content []byte) {
+		raw = append(append(append(raw, id), leb(uint32(len(content)))...), content...)
+	}
+	for _, c := range b.custom {
+		section(sectionCustom, c)
+	}
+	section(sectionType, vector(b.types))
+	if len(b.imports) > 0 {
+		section(sectionImport, vector(b.imports))
+	}
+	section(sectionFunction, vector(b.funcs))
+	if b.table != nil {
+		section(sectionTable, vector([][]byte{b.table}))
+	}
+	if b.memory != nil {
+		section(sectionMemory, vector([][]byte{b.memory}))
+	}
+	section(sectionExport, vector(b.exports))
+	section(sectionCode, vector(b.codes))
+	if len(b.data) > 0 {
+		section(sectionData, vector(b.data))
+	}
+	return raw
+}
+
+func (b *moduleBuilder) instantiate(t *testing.T, cfg *Config) *Instance {
+	m, err := Decode(b.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := Instantiate(context.Background(), m, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inst
+}
+
+func call(t *testing.T, inst *Instance, fn string, args ...uint64) uint64 {
+	results, err := inst.Call(fn, args...)
+	if err != nil {
+		t.Fatalf("cannot call %s%v: %s", fn, args, err.Error())
+	}
+	return results[0]
+}
+
+func expectTrap(t *testing.T, inst *Instance, fn string, args ...uint64) {
+	_, err := inst.Call(fn, args...)
+	if _, ok := err.(*Trap); !ok {
+		t.Fatalf("expected trap of %s%v, got %v", fn, args, err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	b := &moduleBuilder{}
+	b.addFunc("add", []ValueType{I32, I32}, []ValueType{I32}, nil, 0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b)
+	b.addFunc("div", []ValueType{I32, I32}, []ValueType{I32}, nil, 0x20, 0x00, 0x20, 0x01, 0x6d, 0x0b)
+	b.addFunc("trunc", []ValueType{F64}, []ValueType{I32}, nil, 0x20, 0x00, 0xaa, 0x0b)
+	b.addFunc("truncsat", []ValueType{F64}, []ValueType{I32}, nil, 0x20, 0x00, 0xfc, 0x02, 0x0b)
+	inst := b.instantiate(t, &Config{})
+
+	if r := call(t, inst, "add", 2, 3); r != 5 {
+		t.Fatalf("expected 5, got %d", r)
+	}
+	if r := call(t, inst, "add", math.MaxUint32, 2); r != 1 {
+		t.Fatalf("expected wrap around to 1, got %d", r)
+	}
+	if r := int32(call(t, inst, "div", uint64(uint32(-7&math.MaxUint32)), 2)); r != -3 {
+		t.Fatalf("expected -3, got %d", r)
+	}
+	expectTrap(t, inst, "div", 1, 0)
+	expectTrap(t, inst, "div", uint64(uint32(math.MaxInt32+1)), math.MaxUint32)
+
+	if r := int32(call(t, inst, "trunc", math.Float64bits(-3.9))); r != -3 {
+		t.Fatalf("expected -3, got %d", r)
+	}
+	expectTrap(t, inst, "trunc", math.Float64bits(math.NaN()))
+	expectTrap(t, inst, "trunc", math.Float64bits(1e20))
+	if r := int32(call(t, inst, "truncsat", math.Float64bits(1e20))); r != math.MaxInt32 {
+		t.Fatalf("expected saturation, got %d", r)
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	b := &moduleBuilder{}
+
+	// Iterative factorial with loop and br_if
+	b.addFunc("fac", []ValueType{I64}, []ValueType{I64}, []ValueType{I64},
+		0x42, 0x01, 0x21, 0x01,
+		0x02, 0x40, 0x03, 0x40,
+		0x20, 0x00, 0x50, 0x0d, 0x01,
+		0x20, 0x01, 0x20, 0x00, 0x7e, 0x21, 0x01,
+		0x20, 0x00, 0x42, 0x01, 0x7d, 0x21, 0x00,
+		0x0c, 0x00, 0x0b, 0x0b,
+		0x20, 0x01, 0x0b)
+
+	// Recursive fibonacci with if and else
+	fib := uint32(len(b.funcs))
+	b.addFunc("fib", []ValueType{I32}, []ValueType{I32}, nil,
+		0x20, 0x00, 0x41, 0x02, 0x48, 0x04, 0x7f,
+		0x20, 0x00,
+		0x05,
+		0x20, 0x00, 0x41, 0x01, 0x6b, 0x10, byte(fib),
+		0x20, 0x00, 0x41, 0x02, 0x6b, 0x10, byte(fib),
+		0x6a, 0x0b, 0x0b)
+
+	// Switch with br_table
+	b.addFunc("switch", []ValueType{I32}, []ValueType{I32}, nil,
+		0x02, 0x40, 0x02, 0x40, 0x02, 0x40,
+		0x20, 0x00, 0x0e, 0x02, 0x00, 0x01, 0x02,
+		0x0b, 0x41, 0x0a, 0x0f,
+		0x0b, 0x41, 0x14, 0x0f,
+		0x0b, 0x41, 0x1e, 0x0b)
+	inst := b.instantiate(t, &Config{})
+
+	if r := call(t, inst, "fac", 10); r != 3628800 {
+		t.Fatalf("expected 3628800, got %d", r)
+	}
+	if r := call(t, inst, "fib", 20); r != 6765 {
+		t.Fatalf("expected 6765, got %d", r)
+	}
+	for arg, expected := range map[uint64]uint64{0: 10, 1: 20, 2: 30, 9: 30} {
+		if r := call(t, inst, "switch", arg); r != expected {
+			t.Fatalf("expected %d for %d, got %d", expected, arg, r)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	b := &moduleBuilder{memory: []byte{0x00, 0x01}}
+	b.addFunc("store", []ValueType{I32, I32}, []ValueType{I32}, nil,
+		0x20, 0x00, 0x20, 0x01, 0x36, 0x02, 0x00,
+		0x20, 0x00, 0x28, 0x02, 0x00, 0x0b)
+	b.addFunc("grow", []ValueType{I32}, []ValueType{I32}, nil, 0x20, 0x00, 0x40, 0x00, 0x0b)
+	inst := b.instantiate(t, &Config{MaxMemoryPages: 2})
+
+	if r := call(t, inst, "store", 100, 42); r != 42 {
+		t.Fatalf("expected 42, got %d", r)
+	}
+	expectTrap(t, inst, "store", pageSize-2, 1)
+	if r := call(t, inst, "grow", 1); r != 1 {
+		t.Fatalf("expected previous size 1, got %d", r)
+	}
+	if r := call(t, inst, "store", pageSize, 7); r != 7 {
+		t.Fatalf("expected 7, got %d", r)
+	}
+	if r := call(t, inst, "grow", 1); r != math.MaxUint32 {
+		t.Fatalf("expected memory limit, got %d", r)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	b := &moduleBuilder{}
+	b.addFunc("spin", []ValueType{}, []ValueType{}, nil, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b)
+	m, err := Decode(b.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	inst, err := Instantiate(ctx, m, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTrap(t, inst, "spin")
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode([]byte("\x7fELF")); err != errInvalidModule {
+		t.Fatalf("expected %v, got %v", errInvalidModule, err)
+	}
+
+	// Function without body
+	b := &moduleBuilder{}
+	b.addFunc("f", []ValueType{}, []ValueType{}, nil, 0x0b)
+	b.codes = nil
+	if _, err := Decode(b.bytes()); err == nil {
+		t.Fatal("expected error for missing body")
+	}
+
+	// Oversized tables and memories are rejected before they are allocated
+	b = &moduleBuilder{table: append([]byte{funcRef, 0x00}, leb(math.MaxUint32)...)}
+	if _, err := Decode(b.bytes()); err == nil {
+		t.Fatal("expected error for oversized table")
+	}
+	b = &moduleBuilder{memory: append([]byte{0x00}, leb(maxPages+1)...)}
+	if _, err := Decode(b.bytes()); err == nil {
+		t.Fatal("expected error for oversized memory")
+	}
+
+	// Tables within the limit are allocated
+	b = &moduleBuilder{table: append([]byte{funcRef, 0x00}, leb(16)...)}
+	if inst := b.instantiate(t, &Config{}); len(inst.table) != 16 {
+		t.Fatalf("expected table of 16 elements, got %d", len(inst.table))
+	}
+}
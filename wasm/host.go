@@ -0,0 +1,138 @@
+package wasm
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// hostModule is the module name of the host functions.
+	hostModule = "gaia"
+
+	// CapabilityLog allows writing to the job log.
+	CapabilityLog = "log"
+
+	// CapabilityArgs allows reading the arguments of the job.
+	CapabilityArgs = "args"
+
+	// CapabilityClock allows reading the wall clock.
+	CapabilityClock = "clock"
+
+	// CapabilityRandom allows reading cryptographically secure random bytes.
+	CapabilityRandom = "random"
+)
+
+// HostEnv holds the state the host functions of a job execution work on.
+type HostEnv struct {
+	Log  io.Writer
+	Args map[string]string
+}
+
+// hostCapabilities maps every capability to its host functions.
+var hostCapabilities = map[string]map[string]func(env *HostEnv) HostFunc{
+	CapabilityLog: {
+		// log(ptr, len) writes one line to the job log
+		"log": func(env *HostEnv) HostFunc {
+			return HostFunc{
+				Type: FuncType{Params: []ValueType{I32, I32}, Results: []ValueType{}},
+				Call: func(inst *Instance, args []uint64) ([]uint64, error) {
+					line := inst.Read(uint32(args[0]), uint32(args[1]))
+					_, err := fmt.Fprintf(env.Log, "%s\n", line)
+					return nil, err
+				},
+			}
+		},
+	},
+	CapabilityArgs: {
+		// arg(keyPtr, keyLen, bufPtr, bufLen) copies the value of the
+		// argument into the buffer and returns its length or -1 if the
+		// argument does not exist. Values longer than the buffer are
+		// truncated.
+		"arg": func(env *HostEnv) HostFunc {
+			return HostFunc{
+				Type: FuncType{Params: []ValueType{I32, I32, I32, I32}, Results: []ValueType{I32}},
+				Call: func(inst *Instance, args []uint64) ([]uint64, error) {
+					key := inst.Read(uint32(args[0]), uint32(args[1]))
+					value, ok := env.Args[string(key)]
+					if !ok {
+						return []uint64{uint64(math.MaxUint32)}, nil
+					}
+					b := []byte(value)
+					if uint64(len(b)) > uint64(uint32(args[3])) {
+						b = b[:uint32(args[3])]
+					}
+					inst.Write(uint32(args[2]), b)
+					return []uint64{uint64(uint32(len(value)))}, nil
+				},
+			}
+		},
+	},
+	CapabilityClock: {
+		// time_now() returns the unix time in nanoseconds
+		"time_now": func(env *HostEnv) HostFunc {
+			return HostFunc{
+				Type: FuncType{Params: []ValueType{}, Results: []ValueType{I64}},
+				Call: func(inst *Instance, args []uint64) ([]uint64, error) {
+					return []uint64{uint64(time.Now().UnixNano())}, nil
+				},
+			}
+		},
+	},
+	CapabilityRandom: {
+		// random(ptr, len) fills the given range with random bytes
+		"random": func(env *HostEnv) HostFunc {
+			return HostFunc{
+				Type: FuncType{Params: []ValueType{I32, I32}, Results: []ValueType{}},
+				Call: func(inst *Instance, args []uint64) ([]uint64, error) {
+					// Check the range before allocating the buffer so a
+					// guest cannot request more than its memory
+					ptr, length := uint64(uint32(args[0])), uint64(uint32(args[1]))
+					if ptr+length > uint64(len(inst.Memory())) {
+						trap("out of bounds memory access")
+					}
+					if _, err := rand.Read(inst.Memory()[ptr : ptr+length]); err != nil {
+						return nil, err
+					}
+					return nil, nil
+				},
+			}
+		},
+	},
+}
+
+// Capabilities returns the names of all capabilities.
+func Capabilities() []string {
+	names := []string{}
+	for name := range hostCapabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateCapabilities checks the given capabilities.
+func ValidateCapabilities(capabilities []string) error {
+	for _, c := range capabilities {
+		if _, ok := hostCapabilities[c]; !ok {
+			return fmt.Errorf("unknown wasm capability %s. Must be one of %v", c, Capabilities())
+		}
+	}
+	return nil
+}
+
+// hostImports returns the host functions of the given capabilities.
+// Modules which import functions of other capabilities cannot be
+// instantiated.
+func hostImports(capabilities []string, env *HostEnv) map[string]HostFunc {
+	imports := map[string]HostFunc{}
+	for _, c := range capabilities {
+		for name, f := range hostCapabilities[c] {
+			imports[hostModule+"."+name] = f(env)
+		}
+	}
+	return imports
+}
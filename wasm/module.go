@@ -0,0 +1,580 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// magic and version start every binary module.
+	magic   = "\x00asm"
+	version = 1
+
+	// pageSize is the size of one page of linear memory.
+	pageSize = 65536
+
+	// maxPages is the largest number of pages a memory can have.
+	maxPages = 65536
+
+	// maxTableSize is the largest number of elements a table can have.
+	// Tables are allocated on instantiation, larger ones are rejected
+	// before an untrusted module can exhaust the memory of the server.
+	maxTableSize = 1 << 20
+)
+
+// Value types of the MVP and the empty block type.
+const (
+	I32   ValueType = 0x7f
+	I64   ValueType = 0x7e
+	F32   ValueType = 0x7d
+	F64   ValueType = 0x7c
+	empty ValueType = 0x40
+
+	funcRef byte = 0x70
+)
+
+// Section ids of the binary format.
+const (
+	sectionCustom = iota
+	sectionType
+	sectionImport
+	sectionFunction
+	sectionTable
+	sectionMemory
+	sectionGlobal
+	sectionExport
+	sectionStart
+	sectionElement
+	sectionCode
+	sectionData
+	sectionDataCount
+)
+
+// External kinds of imports and exports.
+const (
+	ExternalFunc   byte = 0x00
+	ExternalTable  byte = 0x01
+	ExternalMemory byte = 0x02
+	ExternalGlobal byte = 0x03
+)
+
+var (
+	// errInvalidModule is thrown when a binary is not a WebAssembly module.
+	errInvalidModule = errors.New("invalid webassembly module")
+
+	// errUnsupported is thrown when a module uses a feature which is
+	// not implemented by the runtime.
+	errUnsupported = errors.New("unsupported webassembly feature")
+)
+
+// ValueType represents the type of a value.
+type ValueType byte
+
+// FuncType represents the signature of a function.
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+// equal returns true if both signatures are the same.
+func (t *FuncType) equal(o *FuncType) bool {
+	return bytes.Equal(valueTypeBytes(t.Params), valueTypeBytes(o.Params)) && bytes.Equal(valueTypeBytes(t.Results), valueTypeBytes(o.Results))
+}
+
+// String returns the signature in text format.
+func (t *FuncType) String() string {
+	return fmt.Sprintf("%v -> %v", t.Params, t.Results)
+}
+
+// String returns the name of the value type in text format.
+func (v ValueType) String() string {
+	switch v {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	}
+	return fmt.Sprintf("0x%x", byte(v))
+}
+
+// Import represents an imported function.
+type Import struct {
+	Module string
+	Name   string
+	Type   uint32
+}
+
+// Export represents an exported item.
+type Export struct {
+	Kind  byte
+	Index uint32
+}
+
+// Limits represents the size of a memory or table.
+type Limits struct {
+	Min uint32
+	Max *uint32
+}
+
+// Global represents a global variable with its initial value.
+type Global struct {
+	Type    ValueType
+	Mutable bool
+	Init    []byte
+}
+
+// Element represents an active element segment of the table.
+type Element struct {
+	Offset []byte
+	Funcs  []uint32
+}
+
+// Data represents an active data segment of the memory.
+type Data struct {
+	Offset []byte
+	Bytes  []byte
+}
+
+// Code represents the body of a function.
+type Code struct {
+	Locals []ValueType
+	Body   []byte
+}
+
+// Module represents a decoded WebAssembly module.
+type Module struct {
+	Types    []FuncType
+	Imports  []Import
+	Funcs    []uint32
+	Table    *Limits
+	Memory   *Limits
+	Globals  []Global
+	Exports  map[string]Export
+	Start    *uint32
+	Elements []Element
+	Codes    []Code
+	Data     []Data
+
+	// Custom holds the content of the custom sections by name.
+	Custom map[string][]byte
+}
+
+// funcType returns the signature of the function with the given index.
+// Imported functions come first.
+func (m *Module) funcType(index uint32) (*FuncType, error) {
+	var typeIndex uint32
+	if int(index) < len(m.Imports) {
+		typeIndex = m.Imports[index].Type
+	} else if i := int(index) - len(m.Imports); i < len(m.Funcs) {
+		typeIndex = m.Funcs[i]
+	} else {
+		return nil, fmt.Errorf("%s: unknown function %d", errInvalidModule.Error(), index)
+	}
+	return m.funcTypeByIndex(typeIndex)
+}
+
+// funcTypeByIndex returns the type with the given index.
+func (m *Module) funcTypeByIndex(index uint32) (*FuncType, error) {
+	if int(index) >= len(m.Types) {
+		return nil, fmt.Errorf("%s: unknown type %d", errInvalidModule.Error(), index)
+	}
+	return &m.Types[index], nil
+}
+
+// Decode decodes and validates the given binary module. Only the
+// features of the MVP, sign extension, saturating truncation and bulk
+// memory copy and fill are supported.
+func Decode(raw []byte) (*Module, error) {
+	if len(raw) < 8 || string(raw[:4]) != magic || binary.LittleEndian.Uint32(raw[4:8]) != version {
+		return nil, errInvalidModule
+	}
+
+	m := &Module{Exports: map[string]Export{}, Custom: map[string][]byte{}}
+	r := &reader{buf: raw, pos: 8}
+	for r.pos < len(r.buf) {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		content, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+		if err = m.decodeSection(id, &reader{buf: content}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(m.Funcs) != len(m.Codes) {
+		return nil, fmt.Errorf("%s: %d functions but %d bodies", errInvalidModule.Error(), len(m.Funcs), len(m.Codes))
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodeSection decodes the section with the given id.
+func (m *Module) decodeSection(id byte, r *reader) error {
+	switch id {
+	case sectionCustom:
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+		m.Custom[name] = r.buf[r.pos:]
+		return nil
+	case sectionType:
+		return r.vec(func() error {
+			form, err := r.byte()
+			if err != nil {
+				return err
+			} else if form != 0x60 {
+				return errInvalidModule
+			}
+			t := FuncType{}
+			if t.Params, err = r.valueTypes(); err != nil {
+				return err
+			}
+			if t.Results, err = r.valueTypes(); err != nil {
+				return err
+			}
+			m.Types = append(m.Types, t)
+			return nil
+		})
+	case sectionImport:
+		return r.vec(func() error {
+			module, err := r.name()
+			if err != nil {
+				return err
+			}
+			name, err := r.name()
+			if err != nil {
+				return err
+			}
+			kind, err := r.byte()
+			if err != nil {
+				return err
+			} else if kind != ExternalFunc {
+				return fmt.Errorf("%s: import %s.%s is not a function", errUnsupported.Error(), module, name)
+			}
+			t, err := r.u32()
+			m.Imports = append(m.Imports, Import{Module: module, Name: name, Type: t})
+			return err
+		})
+	case sectionFunction:
+		return r.vec(func() error {
+			t, err := r.u32()
+			m.Funcs = append(m.Funcs, t)
+			return err
+		})
+	case sectionTable:
+		return r.vec(func() error {
+			if t, err := r.byte(); err != nil {
+				return err
+			} else if t != funcRef || m.Table != nil {
+				return fmt.Errorf("%s: multiple tables", errUnsupported.Error())
+			}
+			l, err := r.limits()
+			if err != nil {
+				return err
+			} else if l.Min > maxTableSize {
+				return fmt.Errorf("%s: table of %d elements exceeds %d", errUnsupported.Error(), l.Min, maxTableSize)
+			}
+			m.Table = l
+			return nil
+		})
+	case sectionMemory:
+		return r.vec(func() error {
+			if m.Memory != nil {
+				return fmt.Errorf("%s: multiple memories", errUnsupported.Error())
+			}
+			l, err := r.limits()
+			if err != nil {
+				return err
+			} else if l.Min > maxPages {
+				return fmt.Errorf("%s: memory of %d pages exceeds %d", errInvalidModule.Error(), l.Min, maxPages)
+			}
+			m.Memory = l
+			return nil
+		})
+	case sectionGlobal:
+		return r.vec(func() error {
+			t, err := r.byte()
+			if err != nil {
+				return err
+			}
+			mutable, err := r.byte()
+			if err != nil {
+				return err
+			}
+			init, err := r.expr()
+			m.Globals = append(m.Globals, Global{Type: ValueType(t), Mutable: mutable == 1, Init: init})
+			return err
+		})
+	case sectionExport:
+		return r.vec(func() error {
+			name, err := r.name()
+			if err != nil {
+				return err
+			}
+			kind, err := r.byte()
+			if err != nil {
+				return err
+			}
+			index, err := r.u32()
+			m.Exports[name] = Export{Kind: kind, Index: index}
+			return err
+		})
+	case sectionStart:
+		index, err := r.u32()
+		m.Start = &index
+		return err
+	case sectionElement:
+		return r.vec(func() error {
+			if flags, err := r.u32(); err != nil {
+				return err
+			} else if flags != 0 {
+				return fmt.Errorf("%s: element segment %d", errUnsupported.Error(), flags)
+			}
+			offset, err := r.expr()
+			if err != nil {
+				return err
+			}
+			e := Element{Offset: offset}
+			err = r.vec(func() error {
+				f, err := r.u32()
+				e.Funcs = append(e.Funcs, f)
+				return err
+			})
+			m.Elements = append(m.Elements, e)
+			return err
+		})
+	case sectionCode:
+		return r.vec(func() error {
+			size, err := r.u32()
+			if err != nil {
+				return err
+			}
+			body, err := r.bytes(int(size))
+			if err != nil {
+				return err
+			}
+			br := &reader{buf: body}
+			c := Code{}
+			err = br.vec(func() error {
+				n, err := br.u32()
+				if err != nil {
+					return err
+				}
+				t, err := br.byte()
+				if err != nil {
+					return err
+				} else if uint64(len(c.Locals))+uint64(n) > math.MaxUint16 {
+					return fmt.Errorf("%s: too many locals", errUnsupported.Error())
+				}
+				for i := uint32(0); i < n; i++ {
+					c.Locals = append(c.Locals, ValueType(t))
+				}
+				return nil
+			})
+			c.Body = body[br.pos:]
+			m.Codes = append(m.Codes, c)
+			return err
+		})
+	case sectionData:
+		return r.vec(func() error {
+			if flags, err := r.u32(); err != nil {
+				return err
+			} else if flags != 0 {
+				return fmt.Errorf("%s: data segment %d", errUnsupported.Error(), flags)
+			}
+			offset, err := r.expr()
+			if err != nil {
+				return err
+			}
+			size, err := r.u32()
+			if err != nil {
+				return err
+			}
+			b, err := r.bytes(int(size))
+			m.Data = append(m.Data, Data{Offset: offset, Bytes: b})
+			return err
+		})
+	case sectionDataCount:
+		// Only needed for validation of bulk memory instructions
+		return nil
+	}
+	return fmt.Errorf("%s: unknown section %d", errInvalidModule.Error(), id)
+}
+
+// reader decodes the primitive values of the binary format.
+type reader struct {
+	buf []byte
+	pos int
+}
+
+// byte reads a single byte.
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// bytes reads the given number of bytes.
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// u32 reads an unsigned LEB128 encoded 32 bit integer.
+func (r *reader) u32() (uint32, error) {
+	v, err := r.leb(32, false)
+	return uint32(v), err
+}
+
+// leb reads a LEB128 encoded integer with the given number of bits.
+func (r *reader) leb(bits uint, signed bool) (uint64, error) {
+	var result uint64
+	var shift uint
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if signed && shift < 64 && b&0x40 != 0 {
+				result |= ^uint64(0) << shift
+			}
+			return result, nil
+		}
+		if shift >= bits+7 {
+			return 0, fmt.Errorf("%s: integer too long", errInvalidModule.Error())
+		}
+	}
+}
+
+// name reads an UTF-8 name.
+func (r *reader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	return string(b), err
+}
+
+// vec reads a vector whose elements are read by the given function.
+func (r *reader) vec(element func() error) error {
+	n, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		if err = element(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueTypes reads a vector of value types.
+func (r *reader) valueTypes() ([]ValueType, error) {
+	types := []ValueType{}
+	err := r.vec(func() error {
+		t, err := r.byte()
+		if err != nil {
+			return err
+		}
+		switch ValueType(t) {
+		case I32, I64, F32, F64:
+		default:
+			return fmt.Errorf("%s: value type %s", errUnsupported.Error(), ValueType(t))
+		}
+		types = append(types, ValueType(t))
+		return nil
+	})
+	return types, err
+}
+
+// limits reads the limits of a memory or table.
+func (r *reader) limits() (*Limits, error) {
+	flag, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	l := &Limits{}
+	if l.Min, err = r.u32(); err != nil {
+		return nil, err
+	}
+	if flag == 1 {
+		max, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		l.Max = &max
+	} else if flag != 0 {
+		return nil, fmt.Errorf("%s: limits %d", errUnsupported.Error(), flag)
+	}
+	return l, nil
+}
+
+// expr reads a constant expression including its end.
+func (r *reader) expr() ([]byte, error) {
+	start := r.pos
+	for {
+		op, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opEnd:
+			return r.buf[start:r.pos], nil
+		case opI32Const:
+			_, err = r.leb(32, true)
+		case opI64Const:
+			_, err = r.leb(64, true)
+		case opF32Const:
+			_, err = r.bytes(4)
+		case opF64Const:
+			_, err = r.bytes(8)
+		case opGlobalGet:
+			_, err = r.u32()
+		default:
+			return nil, fmt.Errorf("%s: constant expression with opcode 0x%x", errUnsupported.Error(), op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// valueTypeBytes returns the given value types as bytes.
+func valueTypeBytes(types []ValueType) []byte {
+	b := make([]byte, len(types))
+	for i, t := range types {
+		b[i] = byte(t)
+	}
+	return b
+}
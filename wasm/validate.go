@@ -0,0 +1,671 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// unknown is the type of operands of unreachable code. It matches
+// every value type.
+const unknown ValueType = 0
+
+// ctrlFrame is a block of a function body during validation.
+type ctrlFrame struct {
+	op          byte
+	params      []ValueType
+	results     []ValueType
+	height      int
+	unreachable bool
+}
+
+// labelTypes returns the types a branch to the frame must provide.
+func (f *ctrlFrame) labelTypes() []ValueType {
+	if f.op == opLoop {
+		return f.params
+	}
+	return f.results
+}
+
+// validator type checks a function body with the algorithm of the
+// validation appendix of the specification.
+type validator struct {
+	m      *Module
+	locals []ValueType
+	vals   []ValueType
+	ctrls  []ctrlFrame
+}
+
+// invalidf returns an error which marks the module as invalid.
+func invalidf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", errInvalidModule.Error(), fmt.Sprintf(format, args...))
+}
+
+// valid returns true for the value types of the MVP.
+func (v ValueType) valid() bool {
+	return v == I32 || v == I64 || v == F32 || v == F64
+}
+
+// validate checks the decoded module. The interpreter relies on it:
+// every function body is type checked, so operand stack, locals,
+// globals and branch targets are always in range during execution.
+func (m *Module) validate() error {
+	for i := range m.Types {
+		for _, t := range append(append([]ValueType{}, m.Types[i].Params...), m.Types[i].Results...) {
+			if !t.valid() {
+				return invalidf("type %d has invalid value type %s", i, t.String())
+			}
+		}
+	}
+	for _, imp := range m.Imports {
+		if _, err := m.funcTypeByIndex(imp.Type); err != nil {
+			return err
+		}
+	}
+	for _, f := range m.Funcs {
+		if _, err := m.funcTypeByIndex(f); err != nil {
+			return err
+		}
+	}
+	for i, g := range m.Globals {
+		if !g.Type.valid() {
+			return invalidf("global %d has invalid value type %s", i, g.Type.String())
+		}
+		if err := validateConstExpr(g.Init, g.Type); err != nil {
+			return err
+		}
+	}
+	for name, e := range m.Exports {
+		var ok bool
+		switch e.Kind {
+		case ExternalFunc:
+			ok = int(e.Index) < len(m.Imports)+len(m.Funcs)
+		case ExternalTable:
+			ok = e.Index == 0 && m.Table != nil
+		case ExternalMemory:
+			ok = e.Index == 0 && m.Memory != nil
+		case ExternalGlobal:
+			ok = int(e.Index) < len(m.Globals)
+		}
+		if !ok {
+			return invalidf("export %s has unknown target", name)
+		}
+	}
+	if m.Start != nil {
+		t, err := m.funcType(*m.Start)
+		if err != nil {
+			return err
+		} else if len(t.Params) != 0 || len(t.Results) != 0 {
+			return invalidf("start function has signature %s", t.String())
+		}
+	}
+	for _, e := range m.Elements {
+		if m.Table == nil {
+			return invalidf("element segment without table")
+		} else if err := validateConstExpr(e.Offset, I32); err != nil {
+			return err
+		}
+		for _, f := range e.Funcs {
+			if int(f) >= len(m.Imports)+len(m.Funcs) {
+				return invalidf("element segment references unknown function %d", f)
+			}
+		}
+	}
+	for _, d := range m.Data {
+		if m.Memory == nil {
+			return invalidf("data segment without memory")
+		} else if err := validateConstExpr(d.Offset, I32); err != nil {
+			return err
+		}
+	}
+	for i := range m.Codes {
+		if err := m.validateCode(i); err != nil {
+			return fmt.Errorf("function %d: %s", len(m.Imports)+i, err.Error())
+		}
+	}
+	return nil
+}
+
+// validateConstExpr checks that the given constant expression
+// produces a value of the given type.
+func validateConstExpr(expr []byte, t ValueType) error {
+	if _, err := constExpr(expr); err != nil {
+		return err
+	}
+	if op := expr[0]; op != constOpcodes[t] {
+		return invalidf("constant expression with opcode 0x%x for %s", op, t.String())
+	}
+	return nil
+}
+
+// constOpcodes maps the value types to their const instruction.
+var constOpcodes = map[ValueType]byte{I32: opI32Const, I64: opI64Const, F32: opF32Const, F64: opF64Const}
+
+// validateCode type checks the body of the function with the given
+// index into the code section.
+func (m *Module) validateCode(index int) error {
+	t, err := m.funcType(uint32(len(m.Imports) + index))
+	if err != nil {
+		return err
+	}
+	c := &m.Codes[index]
+	for _, l := range c.Locals {
+		if !l.valid() {
+			return invalidf("local has invalid value type %s", l.String())
+		}
+	}
+
+	v := &validator{m: m, locals: append(append([]ValueType{}, t.Params...), c.Locals...)}
+	v.pushCtrl(opBlock, nil, t.Results)
+	r := &reader{buf: c.Body}
+	for len(v.ctrls) > 0 {
+		pos := r.pos
+		op, err := r.byte()
+		if err != nil {
+			return invalidf("missing end of function")
+		}
+		if err = v.instruction(op, r); err != nil {
+			return fmt.Errorf("offset %d: %s", pos, err.Error())
+		}
+	}
+	if r.pos != len(c.Body) {
+		return invalidf("instructions after end of function")
+	}
+	return nil
+}
+
+func (v *validator) push(t ValueType) {
+	v.vals = append(v.vals, t)
+}
+
+func (v *validator) pushAll(types []ValueType) {
+	v.vals = append(v.vals, types...)
+}
+
+// pop pops an operand. Unreachable code may pop operands of any type.
+func (v *validator) pop() (ValueType, error) {
+	f := &v.ctrls[len(v.ctrls)-1]
+	if len(v.vals) == f.height {
+		if f.unreachable {
+			return unknown, nil
+		}
+		return 0, invalidf("operand stack underflow")
+	}
+	t := v.vals[len(v.vals)-1]
+	v.vals = v.vals[:len(v.vals)-1]
+	return t, nil
+}
+
+// popExpect pops an operand of the given type.
+func (v *validator) popExpect(want ValueType) (ValueType, error) {
+	t, err := v.pop()
+	if err != nil {
+		return 0, err
+	} else if t != want && t != unknown && want != unknown {
+		return 0, invalidf("type mismatch: expected %s but got %s", want.String(), t.String())
+	}
+	return t, nil
+}
+
+// popAll pops operands of the given types in reverse order.
+func (v *validator) popAll(types []ValueType) error {
+	for i := len(types) - 1; i >= 0; i-- {
+		if _, err := v.popExpect(types[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unary checks an instruction which replaces one operand.
+func (v *validator) unary(in, out ValueType) error {
+	if _, err := v.popExpect(in); err != nil {
+		return err
+	}
+	v.push(out)
+	return nil
+}
+
+// binary checks an instruction which replaces two operands of the
+// same type.
+func (v *validator) binary(in, out ValueType) error {
+	if _, err := v.popExpect(in); err != nil {
+		return err
+	}
+	return v.unary(in, out)
+}
+
+func (v *validator) pushCtrl(op byte, params, results []ValueType) {
+	v.ctrls = append(v.ctrls, ctrlFrame{op: op, params: params, results: results, height: len(v.vals)})
+	v.pushAll(params)
+}
+
+func (v *validator) popCtrl() (ctrlFrame, error) {
+	f := v.ctrls[len(v.ctrls)-1]
+	if err := v.popAll(f.results); err != nil {
+		return f, err
+	} else if len(v.vals) != f.height {
+		return f, invalidf("%d operands left at end of block", len(v.vals)-f.height)
+	}
+	v.ctrls = v.ctrls[:len(v.ctrls)-1]
+	return f, nil
+}
+
+// setUnreachable marks the rest of the current block as unreachable.
+func (v *validator) setUnreachable() {
+	f := &v.ctrls[len(v.ctrls)-1]
+	v.vals = v.vals[:f.height]
+	f.unreachable = true
+}
+
+// label reads a branch depth and returns the types of its target.
+func (v *validator) label(r *reader) ([]ValueType, error) {
+	depth, err := r.u32()
+	if err != nil {
+		return nil, err
+	} else if int(depth) >= len(v.ctrls) {
+		return nil, invalidf("invalid branch depth %d", depth)
+	}
+	return v.ctrls[len(v.ctrls)-1-int(depth)].labelTypes(), nil
+}
+
+// local reads a local index and returns the type of the local.
+func (v *validator) local(r *reader) (ValueType, error) {
+	index, err := r.u32()
+	if err != nil {
+		return 0, err
+	} else if int(index) >= len(v.locals) {
+		return 0, invalidf("unknown local %d", index)
+	}
+	return v.locals[index], nil
+}
+
+// reserved reads a reserved zero byte of a memory or table instruction.
+func (v *validator) reserved(r *reader) error {
+	if b, err := r.byte(); err != nil {
+		return err
+	} else if b != 0 {
+		return invalidf("reserved byte 0x%x", b)
+	}
+	return nil
+}
+
+// requireMemory fails if the module has no memory.
+func (v *validator) requireMemory() error {
+	if v.m.Memory == nil {
+		return invalidf("memory instruction without memory")
+	}
+	return nil
+}
+
+// instruction type checks the given instruction and its immediates.
+func (v *validator) instruction(op byte, r *reader) error {
+	switch {
+	case op == opUnreachable:
+		v.setUnreachable()
+	case op == opNop:
+	case op == opBlock || op == opLoop || op == opIf:
+		params, results, err := blockSignature(v.m, r)
+		if err != nil {
+			return err
+		}
+		if op == opIf {
+			if _, err = v.popExpect(I32); err != nil {
+				return err
+			}
+		}
+		if err = v.popAll(params); err != nil {
+			return err
+		}
+		v.pushCtrl(op, params, results)
+	case op == opElse:
+		f, err := v.popCtrl()
+		if err != nil {
+			return err
+		} else if f.op != opIf {
+			return invalidf("else outside of if")
+		}
+		v.pushCtrl(opElse, f.params, f.results)
+	case op == opEnd:
+		f, err := v.popCtrl()
+		if err != nil {
+			return err
+		} else if f.op == opIf && !bytes.Equal(valueTypeBytes(f.params), valueTypeBytes(f.results)) {
+			// The missing else passes the parameters through
+			return invalidf("if without else must not change the operand types")
+		}
+		v.pushAll(f.results)
+	case op == opBr:
+		types, err := v.label(r)
+		if err != nil {
+			return err
+		} else if err = v.popAll(types); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	case op == opBrIf:
+		types, err := v.label(r)
+		if err != nil {
+			return err
+		} else if _, err = v.popExpect(I32); err != nil {
+			return err
+		} else if err = v.popAll(types); err != nil {
+			return err
+		}
+		v.pushAll(types)
+	case op == opBrTable:
+		targets := [][]ValueType{}
+		err := r.vec(func() error {
+			types, err := v.label(r)
+			targets = append(targets, types)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		def, err := v.label(r)
+		if err != nil {
+			return err
+		} else if _, err = v.popExpect(I32); err != nil {
+			return err
+		}
+		for _, types := range targets {
+			if len(types) != len(def) {
+				return invalidf("br_table targets have different arities")
+			} else if err = v.popAll(types); err != nil {
+				return err
+			}
+			v.pushAll(types)
+		}
+		if err = v.popAll(def); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	case op == opReturn:
+		if err := v.popAll(v.ctrls[0].results); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	case op == opCall:
+		index, err := r.u32()
+		if err != nil {
+			return err
+		}
+		t, err := v.m.funcType(index)
+		if err != nil {
+			return err
+		} else if err = v.popAll(t.Params); err != nil {
+			return err
+		}
+		v.pushAll(t.Results)
+	case op == opCallIndirect:
+		index, err := r.u32()
+		if err != nil {
+			return err
+		}
+		t, err := v.m.funcTypeByIndex(index)
+		if err != nil {
+			return err
+		} else if err = v.reserved(r); err != nil {
+			return err
+		} else if v.m.Table == nil {
+			return invalidf("call_indirect without table")
+		} else if _, err = v.popExpect(I32); err != nil {
+			return err
+		} else if err = v.popAll(t.Params); err != nil {
+			return err
+		}
+		v.pushAll(t.Results)
+	case op == opDrop:
+		_, err := v.pop()
+		return err
+	case op == opSelect:
+		if _, err := v.popExpect(I32); err != nil {
+			return err
+		}
+		a, err := v.pop()
+		if err != nil {
+			return err
+		}
+		b, err := v.popExpect(a)
+		if err != nil {
+			return err
+		}
+		if a == unknown {
+			a = b
+		}
+		v.push(a)
+	case op == opSelectTyped:
+		types, err := r.valueTypes()
+		if err != nil {
+			return err
+		} else if len(types) != 1 || !types[0].valid() {
+			return invalidf("select with types %v", types)
+		} else if _, err = v.popExpect(I32); err != nil {
+			return err
+		}
+		return v.binary(types[0], types[0])
+	case op == opLocalGet:
+		t, err := v.local(r)
+		if err != nil {
+			return err
+		}
+		v.push(t)
+	case op == opLocalSet:
+		t, err := v.local(r)
+		if err != nil {
+			return err
+		}
+		_, err = v.popExpect(t)
+		return err
+	case op == opLocalTee:
+		t, err := v.local(r)
+		if err != nil {
+			return err
+		}
+		return v.unary(t, t)
+	case op == opGlobalGet || op == opGlobalSet:
+		index, err := r.u32()
+		if err != nil {
+			return err
+		} else if int(index) >= len(v.m.Globals) {
+			return invalidf("unknown global %d", index)
+		}
+		g := v.m.Globals[index]
+		if op == opGlobalGet {
+			v.push(g.Type)
+			return nil
+		} else if !g.Mutable {
+			return invalidf("global %d is immutable", index)
+		}
+		_, err = v.popExpect(g.Type)
+		return err
+	case op >= opI32Load && op <= opI64Store32:
+		return v.memoryAccess(op, r)
+	case op == opMemorySize || op == opMemoryGrow:
+		if err := v.requireMemory(); err != nil {
+			return err
+		} else if err = v.reserved(r); err != nil {
+			return err
+		}
+		if op == opMemorySize {
+			v.push(I32)
+			return nil
+		}
+		return v.unary(I32, I32)
+	case op == opI32Const:
+		_, err := r.leb(32, true)
+		v.push(I32)
+		return err
+	case op == opI64Const:
+		_, err := r.leb(64, true)
+		v.push(I64)
+		return err
+	case op == opF32Const:
+		_, err := r.bytes(4)
+		v.push(F32)
+		return err
+	case op == opF64Const:
+		_, err := r.bytes(8)
+		v.push(F64)
+		return err
+	case op >= opNumericFirst && op <= opNumericLast:
+		return v.numeric(op)
+	case op == opPrefixed:
+		return v.prefixed(r)
+	default:
+		return fmt.Errorf("%s: opcode 0x%x", errUnsupported.Error(), op)
+	}
+	return nil
+}
+
+// memoryAccess checks the given load or store instruction.
+func (v *validator) memoryAccess(op byte, r *reader) error {
+	// Value type and natural alignment of every load and store
+	t, align := I32, uint32(2)
+	switch op {
+	case 0x29, 0x37:
+		t, align = I64, 3
+	case 0x2a, 0x38:
+		t = F32
+	case 0x2b, 0x39:
+		t, align = F64, 3
+	case 0x2c, 0x2d, 0x3a:
+		align = 0
+	case 0x2e, 0x2f, 0x3b:
+		align = 1
+	case 0x30, 0x31, 0x3c:
+		t, align = I64, 0
+	case 0x32, 0x33, 0x3d:
+		t, align = I64, 1
+	case 0x34, 0x35, 0x3e:
+		t = I64
+	}
+
+	if err := v.requireMemory(); err != nil {
+		return err
+	}
+	a, err := r.u32()
+	if err != nil {
+		return err
+	} else if a > align {
+		return invalidf("alignment 2^%d exceeds natural alignment", a)
+	} else if _, err = r.u32(); err != nil {
+		return err
+	}
+
+	if op <= 0x35 {
+		return v.unary(I32, t)
+	}
+	if _, err = v.popExpect(t); err != nil {
+		return err
+	}
+	_, err = v.popExpect(I32)
+	return err
+}
+
+// numeric checks the given numeric instruction.
+func (v *validator) numeric(op byte) error {
+	switch {
+	case op == 0x45:
+		return v.unary(I32, I32)
+	case op >= 0x46 && op <= 0x4f:
+		return v.binary(I32, I32)
+	case op == 0x50:
+		return v.unary(I64, I32)
+	case op >= 0x51 && op <= 0x5a:
+		return v.binary(I64, I32)
+	case op >= 0x5b && op <= 0x60:
+		return v.binary(F32, I32)
+	case op >= 0x61 && op <= 0x66:
+		return v.binary(F64, I32)
+	case op >= 0x67 && op <= 0x69:
+		return v.unary(I32, I32)
+	case op >= 0x6a && op <= 0x78:
+		return v.binary(I32, I32)
+	case op >= 0x79 && op <= 0x7b:
+		return v.unary(I64, I64)
+	case op >= 0x7c && op <= 0x8a:
+		return v.binary(I64, I64)
+	case op >= 0x8b && op <= 0x91:
+		return v.unary(F32, F32)
+	case op >= 0x92 && op <= 0x98:
+		return v.binary(F32, F32)
+	case op >= 0x99 && op <= 0x9f:
+		return v.unary(F64, F64)
+	case op >= 0xa0 && op <= 0xa6:
+		return v.binary(F64, F64)
+	}
+
+	// Operand and result type of the conversions
+	conversions := map[byte][2]ValueType{
+		0xa7: {I64, I32}, 0xa8: {F32, I32}, 0xa9: {F32, I32}, 0xaa: {F64, I32}, 0xab: {F64, I32},
+		0xac: {I32, I64}, 0xad: {I32, I64}, 0xae: {F32, I64}, 0xaf: {F32, I64}, 0xb0: {F64, I64}, 0xb1: {F64, I64},
+		0xb2: {I32, F32}, 0xb3: {I32, F32}, 0xb4: {I64, F32}, 0xb5: {I64, F32}, 0xb6: {F64, F32},
+		0xb7: {I32, F64}, 0xb8: {I32, F64}, 0xb9: {I64, F64}, 0xba: {I64, F64}, 0xbb: {F32, F64},
+		0xbc: {F32, I32}, 0xbd: {F64, I64}, 0xbe: {I32, F32}, 0xbf: {I64, F64},
+		0xc0: {I32, I32}, 0xc1: {I32, I32}, 0xc2: {I64, I64}, 0xc3: {I64, I64}, 0xc4: {I64, I64},
+	}
+	c := conversions[op]
+	return v.unary(c[0], c[1])
+}
+
+// prefixed checks the prefixed instruction which follows.
+func (v *validator) prefixed(r *reader) error {
+	sub, err := r.u32()
+	if err != nil {
+		return err
+	}
+	switch {
+	case sub <= opTruncSatLast:
+		// i32 and i64 results from f32 and f64 operands, signed and unsigned
+		in, out := F32, I32
+		if sub&2 != 0 {
+			in = F64
+		}
+		if sub&4 != 0 {
+			out = I64
+		}
+		return v.unary(in, out)
+	case sub == opMemoryCopy || sub == opMemoryFill:
+		if err = v.requireMemory(); err != nil {
+			return err
+		} else if err = v.reserved(r); err != nil {
+			return err
+		}
+		if sub == opMemoryCopy {
+			if err = v.reserved(r); err != nil {
+				return err
+			}
+		}
+		// Destination, source or value, and length
+		return v.popAll([]ValueType{I32, I32, I32})
+	}
+	return fmt.Errorf("%s: opcode 0xfc 0x%x", errUnsupported.Error(), sub)
+}
+
+// blockSignature reads the type of a block and returns the types of
+// its parameters and results.
+func blockSignature(m *Module, r *reader) ([]ValueType, []ValueType, error) {
+	if r.pos >= len(r.buf) {
+		return nil, nil, invalidf("missing block type")
+	}
+	switch t := ValueType(r.buf[r.pos]); t {
+	case empty:
+		r.pos++
+		return nil, nil, nil
+	case I32, I64, F32, F64:
+		r.pos++
+		return nil, []ValueType{t}, nil
+	}
+	index, err := r.leb(33, true)
+	if err != nil {
+		return nil, nil, err
+	} else if int64(index) < 0 {
+		return nil, nil, invalidf("invalid block type")
+	}
+	t, err := m.funcTypeByIndex(uint32(index))
+	if err != nil {
+		return nil, nil, err
+	}
+	return t.Params, t.Results, nil
+}
@@ -0,0 +1,76 @@
+package wasm
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestValidateCode(t *testing.T) {
+	i32 := []ValueType{I32}
+	valid := map[string][]byte{
+		"polymorphic stack after unreachable": {0x00, 0x6a, 0x0b},
+		"branch out of a block with result":   {0x02, 0x7f, 0x41, 0x01, 0x0c, 0x00, 0x0b, 0x0b},
+		"if with else":                        {0x41, 0x01, 0x04, 0x7f, 0x41, 0x02, 0x05, 0x41, 0x03, 0x0b, 0x0b},
+	}
+	for desc, body := range valid {
+		b := &moduleBuilder{}
+		b.addFunc("f", nil, i32, nil, body...)
+		if _, err := Decode(b.bytes()); err != nil {
+			t.Fatalf("%s: %s", desc, err.Error())
+		}
+	}
+
+	invalid := map[string][]byte{
+		"type mismatch":                {0x41, 0x00, 0x42, 0x00, 0x6a, 0x0b},
+		"operand stack underflow":      {0x6a, 0x0b},
+		"unknown local":                {0x20, 0x05, 0x0b},
+		"invalid branch depth":         {0x0c, 0x03, 0x0b},
+		"operands left in block":       {0x02, 0x40, 0x41, 0x01, 0x0b, 0x41, 0x00, 0x0b},
+		"missing end":                  {0x41, 0x00},
+		"instructions after end":       {0x41, 0x00, 0x0b, 0x01},
+		"if without else changes type": {0x41, 0x01, 0x04, 0x7f, 0x41, 0x02, 0x0b, 0x0b},
+		"else outside of if":           {0x02, 0x7f, 0x41, 0x01, 0x05, 0x0b, 0x0b},
+		"memory access without memory": {0x41, 0x00, 0x28, 0x02, 0x00, 0x0b},
+		"call of unknown function":     {0x10, 0x07, 0x0b},
+		"unsupported opcode":           {0xd0, 0x0b},
+	}
+	for desc, body := range invalid {
+		b := &moduleBuilder{}
+		b.addFunc("f", nil, i32, nil, body...)
+		if _, err := Decode(b.bytes()); err == nil {
+			t.Fatalf("expected error for %s", desc)
+		}
+	}
+
+	// Alignment must not exceed the natural alignment of the access
+	b := &moduleBuilder{memory: []byte{0x00, 0x01}}
+	b.addFunc("f", nil, i32, nil, 0x41, 0x00, 0x2c, 0x01, 0x00, 0x0b)
+	if _, err := Decode(b.bytes()); err == nil {
+		t.Fatal("expected error for overaligned access")
+	}
+}
+
+func TestRandomBounds(t *testing.T) {
+	b := &moduleBuilder{memory: []byte{0x00, 0x01}}
+	random := b.addImport(hostModule, "random", []ValueType{I32, I32}, []ValueType{})
+	b.addFunc("fill", []ValueType{I32, I32}, []ValueType{I32}, nil,
+		0x20, 0x00, 0x20, 0x01, 0x10, byte(random), 0x41, 0x00, 0x0b)
+	m, err := Decode(b.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := Instantiate(context.Background(), m, &Config{Imports: hostImports([]string{CapabilityRandom}, &HostEnv{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call(t, inst, "fill", 0, 64)
+	if r := inst.Memory()[:64]; string(r) == string(make([]byte, 64)) {
+		t.Fatal("expected random bytes in memory")
+	}
+
+	// Ranges outside of the memory trap instead of being allocated
+	expectTrap(t, inst, "fill", 0, math.MaxUint32)
+	expectTrap(t, inst, "fill", pageSize-8, 16)
+}
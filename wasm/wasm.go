@@ -0,0 +1,270 @@
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gaia-pipeline/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// launcherArg is the first argument which switches the gaia
+	// binary into WASM runtime mode.
+	launcherArg = "__gaia-wasm-exec"
+
+	// configEnvKey is the environment variable which holds the
+	// configuration of the runtime.
+	configEnvKey = "GAIA_WASM_CONFIG"
+
+	// jobsSection is the custom section which defines the jobs of a
+	// pipeline module.
+	jobsSection = "gaia.jobs"
+
+	// handshake is the plugin handshake printed with the network and
+	// the address of the listener.
+	handshake = "1|1|%s|%s|grpc\n"
+)
+
+var (
+	// errNoJobs is thrown when a module has no jobs section.
+	errNoJobs = errors.New("module has no " + jobsSection + " custom section")
+
+	// errJobNotFound is thrown when an unknown job should be executed.
+	errJobNotFound = errors.New("job not found in module")
+)
+
+// Job represents a job of a pipeline module. Export names a function
+// without parameters which returns zero on success.
+type Job struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Priority    int64             `json:"priority,omitempty"`
+	Export      string            `json:"export"`
+	Args        map[string]string `json:"args,omitempty"`
+}
+
+// launchConfig is passed to the runtime process.
+type launchConfig struct {
+	Capabilities   []string `json:"capabilities"`
+	MaxMemoryPages uint32   `json:"maxmemorypages,omitempty"`
+}
+
+// Validate checks that the given binary is a pipeline module whose jobs
+// can be executed by the runtime.
+func Validate(raw []byte) error {
+	m, err := Decode(raw)
+	if err != nil {
+		return err
+	}
+	_, err = Jobs(m)
+	return err
+}
+
+// Jobs returns the jobs defined by the given module. Jobs are executed
+// in order unless they have priorities.
+func Jobs(m *Module) ([]Job, error) {
+	raw, ok := m.Custom[jobsSection]
+	if !ok {
+		return nil, errNoJobs
+	}
+	jobs := []Job{}
+	if err := json.Unmarshal(raw, &jobs); err != nil {
+		return nil, fmt.Errorf("invalid %s section: %s", jobsSection, err.Error())
+	}
+	if len(jobs) == 0 {
+		return nil, errNoJobs
+	}
+
+	titles := map[string]bool{}
+	prioritized := false
+	for _, j := range jobs {
+		if strings.TrimSpace(j.Title) == "" || titles[j.Title] {
+			return nil, fmt.Errorf("invalid or duplicated job title %q", j.Title)
+		}
+		titles[j.Title] = true
+		prioritized = prioritized || j.Priority != 0
+
+		e, ok := m.Exports[j.Export]
+		if !ok || e.Kind != ExternalFunc {
+			return nil, fmt.Errorf("function %s of job %s is not exported", j.Export, j.Title)
+		}
+		t, err := m.funcType(e.Index)
+		if err != nil {
+			return nil, err
+		} else if len(t.Params) != 0 || len(t.Results) != 1 || t.Results[0] != I32 {
+			return nil, fmt.Errorf("function %s of job %s must have the signature [] -> [i32]", j.Export, j.Title)
+		}
+	}
+	if !prioritized {
+		for i := range jobs {
+			jobs[i].Priority = int64(i)
+		}
+	}
+	return jobs, nil
+}
+
+// jobID returns the id of the job with the given title. It is the same
+// id the go sdk uses.
+func jobID(title string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(title))
+	return h.Sum32()
+}
+
+// Wrap rewrites the given pipeline command so that the module is
+// executed by the runtime of the gaia binary. The module can only
+// import the host functions of the given capabilities.
+func Wrap(c *exec.Cmd, capabilities []string) error {
+	// The runtime is the gaia binary itself
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(launchConfig{Capabilities: capabilities})
+	if err != nil {
+		return err
+	}
+
+	c.Args = []string{self, launcherArg, c.Path}
+	c.Path = self
+	c.Env = append(c.Env, configEnvKey+"="+string(raw))
+	return nil
+}
+
+// Launch turns the current process into the WASM runtime if it has
+// been started by a wrapped command. In that case Launch never returns.
+// Otherwise it returns immediately.
+// This should be called at the very beginning of main.
+func Launch() {
+	if len(os.Args) != 3 || os.Args[1] != launcherArg {
+		return
+	}
+
+	// Get configuration and remove it from the pipeline environment
+	cfg := &launchConfig{}
+	if err := json.Unmarshal([]byte(os.Getenv(configEnvKey)), cfg); err != nil {
+		fail(err)
+	}
+	os.Unsetenv(configEnvKey)
+
+	fail(serve(cfg, os.Args[2]))
+}
+
+// fail writes the error to stderr which ends up in the job logs
+// and exits the runtime.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "gaia wasm: %s\n", err.Error())
+	os.Exit(1)
+}
+
+// serve loads the given module and serves its jobs to gaia like a
+// pipeline built with the go sdk.
+func serve(cfg *launchConfig, path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m, err := Decode(raw)
+	if err != nil {
+		return err
+	}
+	jobs, err := Jobs(m)
+	if err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "gaia-wasm")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	ln, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+	if err != nil {
+		return err
+	}
+
+	hs := health.NewServer()
+	hs.SetServingStatus("plugin", healthpb.HealthCheckResponse_SERVING)
+	s := grpc.NewServer()
+	proto.RegisterPluginServer(s, &pluginServer{module: m, jobs: jobs, cfg: cfg})
+	healthpb.RegisterHealthServer(s, hs)
+
+	fmt.Printf(handshake, ln.Addr().Network(), ln.Addr().String())
+	os.Stdout.Sync()
+	return s.Serve(ln)
+}
+
+// pluginServer executes the jobs of a module.
+type pluginServer struct {
+	module *Module
+	jobs   []Job
+	cfg    *launchConfig
+}
+
+// GetJobs streams all jobs of the module.
+func (s *pluginServer) GetJobs(empty *proto.Empty, stream proto.Plugin_GetJobsServer) error {
+	for _, j := range s.jobs {
+		err := stream.Send(&proto.Job{
+			UniqueId:    jobID(j.Title),
+			Title:       j.Title,
+			Description: j.Description,
+			Priority:    j.Priority,
+			Args:        j.Args,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecuteJob executes the given job in a fresh instance of the module.
+// Traps and non-zero results fail the job.
+func (s *pluginServer) ExecuteJob(ctx context.Context, j *proto.Job) (*proto.JobResult, error) {
+	var job *Job
+	for i := range s.jobs {
+		if jobID(s.jobs[i].Title) == j.UniqueId {
+			job = &s.jobs[i]
+		}
+	}
+	if job == nil {
+		return nil, errJobNotFound
+	}
+
+	code, err := runJob(ctx, s.module, job, &HostEnv{Log: os.Stdout, Args: j.Args}, s.cfg)
+	r := &proto.JobResult{UniqueId: j.UniqueId}
+	if err != nil {
+		r.Failed, r.ExitPipeline, r.Message = true, true, err.Error()
+	} else if code != 0 {
+		r.Failed, r.ExitPipeline, r.Message = true, true, fmt.Sprintf("job %s returned %d", job.Title, code)
+	}
+	return r, nil
+}
+
+// runJob executes the given job and returns its result.
+func runJob(ctx context.Context, m *Module, job *Job, env *HostEnv, cfg *launchConfig) (int32, error) {
+	inst, err := Instantiate(ctx, m, &Config{
+		Imports:        hostImports(cfg.Capabilities, env),
+		MaxMemoryPages: cfg.MaxMemoryPages,
+	})
+	if err != nil {
+		return 0, err
+	}
+	results, err := inst.Call(job.Export)
+	if err != nil {
+		return 0, err
+	}
+	return int32(results[0]), nil
+}
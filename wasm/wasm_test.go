@@ -0,0 +1,94 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// greetModule returns a module whose job logs the value of the name
+// argument.
+func greetModule(jobs string) *moduleBuilder {
+	b := &moduleBuilder{memory: []byte{0x00, 0x01}}
+	b.custom = append(b.custom, append(name(jobsSection), jobs...))
+	logIndex := b.addImport(hostModule, "log", []ValueType{I32, I32}, []ValueType{})
+	argIndex := b.addImport(hostModule, "arg", []ValueType{I32, I32, I32, I32}, []ValueType{I32})
+
+	// Key "name" at 0, value buffer at 16
+	b.data = append(b.data, append([]byte{0x00, 0x41, 0x00, 0x0b}, name("name")...))
+	b.addFunc("greet", []ValueType{}, []ValueType{I32}, []ValueType{I32},
+		0x41, 0x00, 0x41, 0x04, 0x41, 0x10, 0x41, 0x20, 0x10, byte(argIndex), 0x21, 0x00,
+		0x41, 0x10, 0x20, 0x00, 0x10, byte(logIndex),
+		0x41, 0x00, 0x0b)
+	b.addFunc("fail", []ValueType{}, []ValueType{I32}, nil, 0x41, 0x07, 0x0b)
+	b.addFunc("add", []ValueType{I32, I32}, []ValueType{I32}, nil, 0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b)
+	return b
+}
+
+func TestJobs(t *testing.T) {
+	m, err := Decode(greetModule(`[{"title":"Greet","export":"greet"},{"title":"Fail","export":"fail"}]`).bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := Jobs(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].Priority != 0 || jobs[1].Priority != 1 {
+		t.Fatalf("expected two jobs in order, got %v", jobs)
+	}
+
+	invalid := []string{
+		`[]`,
+		`[{"title":"Greet","export":"greet"},{"title":"Greet","export":"fail"}]`,
+		`[{"title":"Missing","export":"missing"}]`,
+		`[{"title":"Add","export":"add"}]`,
+	}
+	for _, jobs := range invalid {
+		if err = Validate(greetModule(jobs).bytes()); err == nil {
+			t.Fatalf("expected error for jobs %s", jobs)
+		}
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	m, err := Decode(greetModule(`[{"title":"Greet","export":"greet"},{"title":"Fail","export":"fail"}]`).bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := Jobs(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Modules cannot import functions which have not been granted
+	out := &bytes.Buffer{}
+	env := &HostEnv{Log: out, Args: map[string]string{"name": "gaia"}}
+	_, err = runJob(context.Background(), m, &jobs[0], env, &launchConfig{Capabilities: []string{CapabilityLog}})
+	if err == nil || !strings.Contains(err.Error(), "gaia.arg") {
+		t.Fatalf("expected error for missing capability, got %v", err)
+	}
+
+	cfg := &launchConfig{Capabilities: []string{CapabilityLog, CapabilityArgs}}
+	code, err := runJob(context.Background(), m, &jobs[0], env, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 0 || out.String() != "gaia\n" {
+		t.Fatalf("expected greeting, got %d %q", code, out.String())
+	}
+
+	if code, err = runJob(context.Background(), m, &jobs[1], env, cfg); err != nil || code != 7 {
+		t.Fatalf("expected result 7, got %d %v", code, err)
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	if err := ValidateCapabilities(Capabilities()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateCapabilities([]string{"network"}); err == nil {
+		t.Fatal("expected error for unknown capability")
+	}
+}